@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"cdk-office/pkg/config"
+)
+
+// loadConfig loads the configuration of the profile named by APP_ENV from the
+// directory named by CONFIG_DIR, with the environment overrides applied
+func loadConfig() (*config.Config, error) {
+	profile := os.Getenv("APP_ENV")
+	if profile == "" {
+		profile = config.ProfileDevelopment
+	}
+	return config.Load(config.Options{
+		Files:     config.ProfileFiles(configDir(), profile),
+		Profile:   profile,
+		LookupEnv: os.LookupEnv,
+	})
+}
+
+// configDir returns the directory of the configuration files, CONFIG_DIR or
+// config in the working directory
+func configDir() string {
+	if dir := os.Getenv("CONFIG_DIR"); dir != "" {
+		return dir
+	}
+	return "config"
+}
+
+// runConfigCommand runs the config subcommand and returns the exit code:
+//
+//	cdk-office config check [-profile name] [-env] [file ...]
+//
+// check validates configuration files without connecting to any service.
+// Without files it checks the files of the profile in CONFIG_DIR.
+func runConfigCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprintln(stderr, "usage: cdk-office config check [-profile name] [-env] [file ...]")
+		return 2
+	}
+
+	flags := flag.NewFlagSet("config check", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	profile := flags.String("profile", "", "profile to validate for, overriding the profile of the files")
+	useEnv := flags.Bool("env", false, "apply the environment variable overrides of the current environment")
+	dir := flags.String("dir", configDir(), "config directory of the profile files, used without file arguments")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	opts := config.Options{Files: flags.Args(), Profile: *profile}
+	if len(opts.Files) == 0 {
+		name := *profile
+		if name == "" {
+			name = config.ProfileDevelopment
+		}
+		opts.Files = config.ProfileFiles(*dir, name)
+	}
+	if *useEnv {
+		opts.LookupEnv = os.LookupEnv
+	}
+
+	cfg, err := config.Load(opts)
+	if err != nil {
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			fmt.Fprintln(stdout, err)
+		} else {
+			fmt.Fprintln(stderr, err)
+		}
+		return 1
+	}
+
+	fmt.Fprintf(stdout, "configuration is valid for profile %s\n", cfg.Profile)
+	return 0
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunConfigCommandCheck(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.yaml")
+	require.NoError(t, os.WriteFile(valid, []byte("jwt:\n  secret: s\n"), 0600))
+	invalid := filepath.Join(dir, "invalid.yaml")
+	require.NoError(t, os.WriteFile(invalid, []byte("server:\n  port: 0\n"), 0600))
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(t, 0, runConfigCommand([]string{"check", valid}, &stdout, &stderr))
+	assert.Equal(t, "configuration is valid for profile development\n", stdout.String())
+
+	stdout.Reset()
+	assert.Equal(t, 1, runConfigCommand([]string{"check", "-profile", "production", valid}, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), "jwt.secret: must be at least 32 characters in production")
+
+	stdout.Reset()
+	assert.Equal(t, 1, runConfigCommand([]string{"check", invalid}, &stdout, &stderr))
+	assert.Contains(t, stdout.String(), "server.port")
+	assert.Contains(t, stdout.String(), "jwt.secret: is required")
+
+	stderr.Reset()
+	assert.Equal(t, 1, runConfigCommand([]string{"check", filepath.Join(dir, "missing.yaml")}, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "failed to read config file")
+
+	assert.Equal(t, 2, runConfigCommand([]string{"validate"}, &stdout, &stderr))
+}
@@ -1,17 +1,33 @@
 package main
 
 import (
+	"context"
+	"log"
+	"os"
 	"time"
 
 	app_handler "cdk-office/internal/app/handler"
+	app_service "cdk-office/internal/app/service"
+	approval_handler "cdk-office/internal/approval/handler"
+	approval_service "cdk-office/internal/approval/service"
 	auth_handler "cdk-office/internal/auth/handler"
 	"cdk-office/internal/auth/service"
 	document_handler "cdk-office/internal/document/handler"
+	document_service "cdk-office/internal/document/service"
 	employee_handler "cdk-office/internal/employee/handler"
+	employee_service "cdk-office/internal/employee/service"
 	business_handler "cdk-office/internal/business/handler"
+	business_service "cdk-office/internal/business/service"
+	"cdk-office/internal/dify/agent"
+	agent_tools "cdk-office/internal/dify/agent/tools"
+	dify_client "cdk-office/internal/dify/client"
+	dify_handler "cdk-office/internal/dify/handler"
+	"cdk-office/internal/dify/rag"
+	"cdk-office/internal/dify/workflow"
 	"cdk-office/internal/shared/cache"
 	"cdk-office/internal/shared/database"
 	"cdk-office/internal/shared/middleware"
+	"cdk-office/internal/shared/pdf"
 	"cdk-office/pkg/config"
 	"cdk-office/pkg/jwt"
 
@@ -19,29 +35,51 @@ import (
 )
 
 func main() {
-	// Initialize configuration
-	config.Init()
+	// Offline configuration commands
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfigCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	// Load and validate the configuration
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	config.Init(cfg)
 
 	// Initialize logger
 	// logger.Init() // Logger doesn't have Init function
 
 	// Initialize database
-	db := config.InitDatabase()
+	db := config.InitDatabase(&cfg.Database)
 	database.InitDB(db)
 
 	// Initialize Redis cache
-	cache.InitRedis()
+	cache.InitRedis(&cfg.Redis)
+
+	// Load the font of Chinese text in generated PDFs
+	if cfg.Font.Path != "" {
+		font, err := pdf.LoadFont(cfg.Font.Path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		pdf.SetFont(font)
+	}
 
 	// Initialize JWT manager
 	jwtConfig := &jwt.JWTConfig{
-		SecretKey:       "cdk-office-secret-key", // In production, use environment variable
-		AccessTokenExp:  time.Hour * 2,
-		RefreshTokenExp: time.Hour * 24 * 7,
+		SecretKey:       cfg.JWT.Secret,
+		AccessTokenExp:  cfg.JWT.AccessTokenTTL,
+		RefreshTokenExp: cfg.JWT.RefreshTokenTTL,
 	}
 	jwtManager := jwt.NewJWTManager(jwtConfig)
 
-	// Create Gin engine
+	// Create Gin engine. Client addresses are taken from X-Forwarded-For only
+	// behind the configured proxies.
 	r := gin.New()
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatal(err)
+	}
 
 	// Use middleware
 	r.Use(gin.Logger())
@@ -53,37 +91,181 @@ func main() {
 
 	// Initialize services for middleware
 	permissionService := service.NewPermissionService()
-	authMiddleware := middleware.NewAuthMiddleware(jwtManager)
+	sessionService := service.NewSessionService(jwtManager)
+	authMiddleware := middleware.NewAuthMiddlewareWithSessions(jwtManager, sessionService)
 	_ = middleware.NewPermissionMiddleware(jwtManager, permissionService) // Not used yet
 
+	// Public short links of dynamic QR codes
+	scanHandler := app_handler.NewQRCodeScanHandler()
+	r.GET("/q/:code", scanHandler.Redirect)
+
 	// API v1 group
 	v1 := r.Group("/api/v1")
 	{
 		// Authentication routes
 		auth := v1.Group("/auth")
 		{
-			authHandler := auth_handler.NewAuthHandler(jwtManager)
+			authHandler := auth_handler.NewAuthHandler(jwtManager, cfg)
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
 			auth.GET("/user/:id", authHandler.GetUserInfo)
 			auth.POST("/logout", authHandler.Logout)
 			auth.POST("/refresh", authHandler.RefreshToken)
+
+			// Second phase of logins that require a second factor
+			mfaHandler := auth_handler.NewMFAHandler(jwtManager, &cfg.MFA)
+			auth.POST("/login/mfa", mfaHandler.CompleteLogin)
+			auth.POST("/login/mfa/setup", mfaHandler.SetupLogin)
+
+			// Two-factor authentication management
+			mfa := auth.Group("/mfa")
+			mfa.Use(authMiddleware.Authenticate())
+			{
+				mfa.GET("", mfaHandler.GetStatus)
+				mfa.POST("/enroll", mfaHandler.Enroll)
+				mfa.POST("/enable", mfaHandler.Enable)
+				mfa.POST("/disable", mfaHandler.Disable)
+				mfa.POST("/recovery-codes", mfaHandler.RegenerateRecoveryCodes)
+				mfa.GET("/policies", mfaHandler.ListRolePolicies)
+				mfa.PUT("/policies/:role", mfaHandler.SetRolePolicy)
+			}
+
+			// Login sessions
+			sessionHandler := auth_handler.NewSessionHandlerWithService(sessionService)
+			sessions := auth.Group("/sessions")
+			sessions.Use(authMiddleware.Authenticate())
+			{
+				sessions.GET("", sessionHandler.ListSessions)
+				sessions.DELETE("/:id", sessionHandler.RevokeSession)
+			}
+			users := auth.Group("/users")
+			users.Use(authMiddleware.Authenticate())
+			{
+				users.DELETE("/:id/sessions", sessionHandler.ForceLogout)
+				users.POST("/:id/unlock", authHandler.UnlockUser)
+			}
+
+			// Password change and self-service reset
+			passwordHandler := auth_handler.NewPasswordHandler(jwtManager, cfg)
+			auth.POST("/password/forgot", passwordHandler.ForgotPassword)
+			auth.POST("/password/reset", passwordHandler.ResetPassword)
+			auth.POST("/password/change", authMiddleware.Authenticate(), passwordHandler.ChangePassword)
 			
 			// WeChat login route
-			wechatHandler := auth_handler.NewWeChatHandler()
+			wechatHandler := auth_handler.NewWeChatHandler(jwtManager, cfg)
 			auth.POST("/wechat/login", wechatHandler.WeChatLogin)
+			auth.POST("/wechat/bind-login", wechatHandler.BindLogin)
+			wechat := auth.Group("/wechat")
+			wechat.Use(authMiddleware.Authenticate())
+			{
+				wechat.POST("/bind", wechatHandler.BindWeChat)
+				wechat.DELETE("/bind", wechatHandler.UnbindWeChat)
+				wechat.POST("/phone", wechatHandler.BindPhoneNumber)
+			}
+
+			// Single sign-on with OIDC and LDAP identity providers
+			ssoHandler := auth_handler.NewSSOHandler(jwtManager, cfg)
+			auth.GET("/sso/providers", ssoHandler.ListProviders)
+			auth.POST("/sso/:provider/authorize", ssoHandler.Authorize)
+			auth.POST("/sso/:provider/callback", ssoHandler.Callback)
+			auth.POST("/sso/:provider/login", ssoHandler.PasswordLogin)
+		}
+
+		// Document processing queue
+		difyClient := dify_client.NewDifyClientWithTimeouts(cfg.Dify.BaseURL, cfg.Dify.APIKey, cfg.Dify.Timeout, cfg.Dify.StreamIdleTimeout)
+		documentStorage, err := document_service.NewStorageService(&cfg.Storage)
+		if err != nil {
+			log.Fatal(err)
+		}
+		documentService, err := document_service.NewDocumentService(cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		knowledgeBase := document_service.NewKnowledgeBaseWithStorage(database.GetDB(), documentStorage, &cfg.Dify)
+		documentWorkflow := workflow.NewDocumentWorkflow(
+			difyClient,
+			documentService,
+			document_service.NewContentExtractorWithStorage(documentStorage),
+			document_service.NewOCRExtractorWithStorage(documentStorage),
+			document_service.NewClassifier(difyClient),
+			document_service.NewTagExtractor(difyClient),
+			document_service.NewSummarizer(difyClient),
+			knowledgeBase,
+		)
+		processingService := workflow.NewProcessingService(documentWorkflow, &cfg.Processing)
+		processingService.Start(context.Background())
+
+		// Streamed AI assistant answers
+		ai := v1.Group("/ai")
+		ai.Use(authMiddleware.Authenticate())
+		{
+			chatHandler := dify_handler.NewChatHandler(difyClient)
+			ai.POST("/chat/stream", chatHandler.StreamChat)
+			ai.POST("/completion/stream", chatHandler.StreamCompletion)
+
+			// Questions answered from the documents of a team, with citations
+			qaHandler := dify_handler.NewQAHandler(rag.NewQAService(knowledgeBase, difyClient))
+			ai.POST("/ask", qaHandler.Ask)
+			ai.GET("/conversations", qaHandler.ListConversations)
+			ai.GET("/conversations/:id", qaHandler.GetConversation)
+			ai.DELETE("/conversations/:id", qaHandler.DeleteConversation)
+
+			// Agents calling CDK-Office tools with the permissions of the user
+			agentTools := agent.NewToolRegistry()
+			if err := agent_tools.Register(agentTools, &cfg.Storage); err != nil {
+				log.Fatal(err)
+			}
+			agentHandler := dify_handler.NewAgentHandler(agent.NewAgentRunner(agentTools, agent.NewDifyLLM(difyClient)))
+			ai.POST("/agent/runs", agentHandler.Run)
+			ai.GET("/agent/runs", agentHandler.ListRuns)
+			ai.GET("/agent/runs/:id", agentHandler.GetRun)
+			ai.GET("/agent/tools", agentHandler.ListTools)
 		}
 
 		// Document routes
 		documents := v1.Group("/documents")
 		documents.Use(authMiddleware.Authenticate())
 		{
-			documentHandler := document_handler.NewDocumentHandler()
+			documentHandler, err := document_handler.NewDocumentHandlerWithProcessing(processingService, cfg)
+			if err != nil {
+				log.Fatal(err)
+			}
 			documents.POST("", documentHandler.Upload)
 			documents.GET("/:id", documentHandler.GetDocument)
 			documents.PUT("/:id", documentHandler.UpdateDocument)
 			documents.DELETE("/:id", documentHandler.DeleteDocument)
 			documents.GET("/:id/versions", documentHandler.GetDocumentVersions)
+
+			semanticHandler := document_handler.NewSemanticHandler(&cfg.Vector)
+			documents.GET("/:id/similar", semanticHandler.SimilarDocuments)
+			documents.GET("/:id/duplicates", semanticHandler.NearDuplicates)
+
+			processingHandler := document_handler.NewProcessingHandler(processingService)
+			documents.GET("/:id/processing", processingHandler.GetProcessingStatus)
+			documents.POST("/:id/processing", processingHandler.StartProcessing)
+			documents.POST("/:id/processing/retry", processingHandler.RetryProcessing)
+
+			// Knowledge base sync status, and a full sync repairing missed updates
+			knowledgeHandler := document_handler.NewKnowledgeHandler(knowledgeBase, document_service.NewDocumentAccess())
+			documents.GET("/:id/knowledge", knowledgeHandler.GetSyncStatus)
+			documents.POST("/knowledge/sync", knowledgeHandler.SyncKnowledgeBase)
+		}
+
+		// Resumable upload routes
+		uploads := v1.Group("/uploads")
+		uploads.Use(authMiddleware.Authenticate())
+		{
+			uploadService, err := document_service.NewUploadService(cfg)
+			if err != nil {
+				log.Fatal(err)
+			}
+			uploadService.StartCleanup(context.Background(), time.Hour)
+			uploadHandler := document_handler.NewUploadHandlerWithService(uploadService)
+			uploads.POST("", uploadHandler.InitUpload)
+			uploads.GET("/:id", uploadHandler.GetUploadStatus)
+			uploads.PUT("/:id/chunks/:index", uploadHandler.UploadChunk)
+			uploads.POST("/:id/complete", uploadHandler.CompleteUpload)
+			uploads.DELETE("/:id", uploadHandler.AbortUpload)
 		}
 
 		// Document category routes
@@ -100,7 +282,10 @@ func main() {
 		// Document version routes
 		versions := v1.Group("/versions")
 		{
-			versionHandler := document_handler.NewVersionHandler()
+			versionHandler, err := document_handler.NewVersionHandler(cfg)
+			if err != nil {
+				log.Fatal(err)
+			}
 			versions.POST("", versionHandler.CreateVersion)
 			versions.GET("/:id", versionHandler.GetVersion)
 			versions.GET("/document/:docId", versionHandler.ListVersions)
@@ -108,9 +293,17 @@ func main() {
 
 		// Document search routes
 		search := v1.Group("/search")
+		search.Use(authMiddleware.Authenticate())
 		{
-			searchHandler := document_handler.NewSearchHandler()
+			searchHandler, err := document_handler.NewSearchHandler(&cfg.Storage)
+			if err != nil {
+				log.Fatal(err)
+			}
 			search.GET("", searchHandler.SearchDocuments)
+			search.POST("/reindex", searchHandler.ReindexDocuments)
+
+			semanticHandler := document_handler.NewSemanticHandler(&cfg.Vector)
+			search.GET("/semantic", semanticHandler.SemanticSearch)
 		}
 
 		// Employee routes
@@ -150,10 +343,17 @@ func main() {
 			analytics.GET("/employee/survey-analysis", analyticsHandler.GetSurveyAnalysis)
 		}
 
+		// Approval engine, gating the actions of teams with approval definitions
+		approvalService := approval_service.NewApprovalService()
+		approvalService.StartSLAMonitor(context.Background(), time.Minute)
+
 		// Employee lifecycle routes
+		lifecycleService := employee_service.NewLifecycleService()
+		lifecycleService.UseApprovals(approvalService)
 		lifecycle := v1.Group("/lifecycle")
+		lifecycle.Use(authMiddleware.Authenticate())
 		{
-			lifecycleHandler := employee_handler.NewLifecycleHandler()
+			lifecycleHandler := employee_handler.NewLifecycleHandlerWithService(lifecycleService)
 			lifecycle.POST("/promote", lifecycleHandler.PromoteEmployee)
 			lifecycle.POST("/transfer", lifecycleHandler.TransferEmployee)
 			lifecycle.POST("/terminate", lifecycleHandler.TerminateEmployee)
@@ -183,15 +383,62 @@ func main() {
 			plugins.POST("/:id/disable", pluginHandler.DisablePlugin)
 		}
 
+		// Approval routes
+		approvals := v1.Group("/approvals")
+		approvals.Use(authMiddleware.Authenticate())
+		{
+			approvalHandler := approval_handler.NewApprovalHandlerWithService(approvalService)
+			approvals.POST("/definitions", approvalHandler.CreateDefinition)
+			approvals.GET("/definitions/:id", approvalHandler.GetDefinition)
+			approvals.PUT("/definitions/:id", approvalHandler.UpdateDefinition)
+			approvals.DELETE("/definitions/:id", approvalHandler.DeleteDefinition)
+			approvals.GET("/definitions", approvalHandler.ListDefinitions)
+			approvals.GET("/requests", approvalHandler.ListRequests)
+			approvals.GET("/requests/:id", approvalHandler.GetRequest)
+			approvals.POST("/requests/:id/approve", approvalHandler.ApproveRequest)
+			approvals.POST("/requests/:id/reject", approvalHandler.RejectRequest)
+			approvals.POST("/requests/:id/cancel", approvalHandler.CancelRequest)
+			approvals.GET("/tasks", approvalHandler.ListPendingTasks)
+			approvals.POST("/delegations", approvalHandler.CreateDelegation)
+			approvals.GET("/delegations", approvalHandler.ListDelegations)
+			approvals.DELETE("/delegations/:id", approvalHandler.DeleteDelegation)
+		}
+
 		// Business contract routes
+		contractService, err := business_service.NewContractService(cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		contractService.StartExpiry(context.Background(), time.Minute)
+		contractService.UseApprovals(approvalService)
 		contracts := v1.Group("/contracts")
+		contracts.Use(authMiddleware.Authenticate())
 		{
-			contractHandler := business_handler.NewContractHandler()
+			contractHandler := business_handler.NewContractHandlerWithService(contractService)
 			contracts.POST("", contractHandler.CreateContract)
 			contracts.GET("/:id", contractHandler.GetContract)
 			contracts.PUT("/:id", contractHandler.UpdateContract)
 			contracts.DELETE("/:id", contractHandler.DeleteContract)
 			contracts.GET("", contractHandler.ListContracts)
+			contracts.POST("/:id/send", contractHandler.SendContract)
+			contracts.POST("/:id/sign", contractHandler.SignContract)
+			contracts.POST("/:id/decline", contractHandler.DeclineContract)
+			contracts.POST("/:id/void", contractHandler.VoidContract)
+			contracts.GET("/:id/audit", contractHandler.GetContractAudit)
+			contracts.GET("/:id/certificate", contractHandler.GetContractCertificate)
+		}
+
+		// Business contract template routes
+		contractTemplates := v1.Group("/contract-templates")
+		{
+			contractTemplateHandler := business_handler.NewContractTemplateHandlerWithService(
+				business_service.NewContractTemplateServiceWithService(database.GetDB(), contractService))
+			contractTemplates.POST("", contractTemplateHandler.CreateTemplate)
+			contractTemplates.GET("/:id", contractTemplateHandler.GetTemplate)
+			contractTemplates.PUT("/:id", contractTemplateHandler.UpdateTemplate)
+			contractTemplates.DELETE("/:id", contractTemplateHandler.DeleteTemplate)
+			contractTemplates.GET("", contractTemplateHandler.ListTemplates)
+			contractTemplates.POST("/:id/generate", contractTemplateHandler.GenerateContract)
 		}
 
 		// Business survey routes
@@ -203,6 +450,7 @@ func main() {
 			surveys.PUT("/:id", surveyHandler.UpdateSurvey)
 			surveys.DELETE("/:id", surveyHandler.DeleteSurvey)
 			surveys.GET("", surveyHandler.ListSurveys)
+			surveys.GET("/:id/responses/export", surveyHandler.ExportSurveyResponses)
 		}
 
 		// Business permission routes
@@ -230,13 +478,14 @@ func main() {
 		// QR code routes
 		qrcodes := v1.Group("/qrcodes")
 		{
-			qrCodeHandler := app_handler.NewQRCodeHandler()
+			qrCodeHandler := app_handler.NewQRCodeHandler(&cfg.QRCode)
 			qrcodes.POST("", qrCodeHandler.CreateQRCode)
 			qrcodes.GET("/:id", qrCodeHandler.GetQRCode)
 			qrcodes.PUT("/:id", qrCodeHandler.UpdateQRCode)
 			qrcodes.DELETE("/:id", qrCodeHandler.DeleteQRCode)
 			qrcodes.GET("", qrCodeHandler.ListQRCodes)
 			qrcodes.POST("/:id/generate", qrCodeHandler.GenerateQRCodeImage)
+			qrcodes.GET("/:id/stats", scanHandler.GetQRCodeStats)
 		}
 
 		// Form routes
@@ -254,6 +503,7 @@ func main() {
 			{
 				formData.POST("", formHandler.SubmitFormData)
 				formData.GET("", formHandler.ListFormDataEntries)
+				formData.GET("/export/:id", formHandler.ExportFormDataEntries)
 			}
 		}
 
@@ -275,19 +525,29 @@ func main() {
 		// Batch QR code routes
 		batchQRCodes := v1.Group("/batch-qrcodes")
 		{
-			batchHandler := app_handler.NewBatchQRCodeHandler()
+			batchService := app_service.NewBatchQRCodeService(cfg)
+			batchService.Start(context.Background())
+			batchHandler := app_handler.NewBatchQRCodeHandlerWithService(batchService)
 			batchQRCodes.POST("", batchHandler.CreateBatchQRCode)
 			batchQRCodes.GET("/:id", batchHandler.GetBatchQRCode)
 			batchQRCodes.PUT("/:id", batchHandler.UpdateBatchQRCode)
 			batchQRCodes.DELETE("/:id", batchHandler.DeleteBatchQRCode)
 			batchQRCodes.GET("", batchHandler.ListBatchQRCodes)
 			batchQRCodes.POST("/:id/generate", batchHandler.GenerateBatchQRCodes)
+			batchQRCodes.POST("/:id/retry", batchHandler.RetryBatchQRCodes)
+			batchQRCodes.GET("/:id/download", batchHandler.DownloadBatchQRCodes)
+			batchQRCodes.POST("/:id/data", batchHandler.ImportBatchData)
+			batchQRCodes.GET("/:id/sheet", batchHandler.DownloadPrintSheet)
+			batchQRCodes.GET("/:id/stats", scanHandler.GetBatchStats)
 		}
 
 		// Form designer routes
+		formDesignerService := app_service.NewFormDesignerService()
+		formDesignerService.UseApprovals(approvalService)
 		formDesigns := v1.Group("/form-designs")
+		formDesigns.Use(authMiddleware.Authenticate())
 		{
-			formDesignerHandler := app_handler.NewFormDesignerHandler()
+			formDesignerHandler := app_handler.NewFormDesignerHandlerWithService(formDesignerService)
 			formDesigns.POST("", formDesignerHandler.CreateFormDesign)
 			formDesigns.GET("/:id", formDesignerHandler.GetFormDesign)
 			formDesigns.PUT("/:id", formDesignerHandler.UpdateFormDesign)
@@ -317,8 +577,5 @@ func main() {
 	}
 
 	// Start server
-	port := "8080" // Using default port since config.Get() is not available
-	
-	// logger.Info("Starting server on port " + port) // Logger doesn't have Info method
-	r.Run(":" + port)
+	r.Run(cfg.Server.Address())
 }
\ No newline at end of file
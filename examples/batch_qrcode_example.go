@@ -7,11 +7,12 @@ import (
 	"time"
 
 	"cdk-office/internal/app/service"
+	"cdk-office/pkg/config"
 )
 
 func main() {
 	// 创建批量二维码服务
-	batchService := service.NewBatchQRCodeService()
+	batchService := service.NewBatchQRCodeService(config.Get())
 
 	// 创建批量二维码请求
 	req := &service.CreateBatchQRCodeRequest{
@@ -39,6 +39,7 @@ CREATE TABLE IF NOT EXISTS departments (
     description TEXT,
     team_id VARCHAR(36),
     parent_id VARCHAR(36),
+    manager_id VARCHAR(36) REFERENCES users(id),
     level INTEGER DEFAULT 0,
     sort_order INTEGER DEFAULT 0,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
@@ -82,6 +83,97 @@ CREATE TABLE IF NOT EXISTS user_roles (
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- User MFA table
+CREATE TABLE IF NOT EXISTS user_mfas (
+    user_id VARCHAR(36) PRIMARY KEY REFERENCES users(id),
+    secret VARCHAR(64) NOT NULL,
+    enabled BOOLEAN DEFAULT FALSE,
+    enabled_at TIMESTAMP,
+    last_used_step BIGINT DEFAULT 0,
+    failed_attempts INTEGER DEFAULT 0,
+    locked_until TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- MFA recovery codes table
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) REFERENCES users(id),
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user_id ON mfa_recovery_codes(user_id, code_hash);
+
+-- MFA role policies table
+CREATE TABLE IF NOT EXISTS mfa_role_policies (
+    role VARCHAR(20) PRIMARY KEY,
+    required BOOLEAN DEFAULT FALSE,
+    updated_by VARCHAR(36) REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Sessions table
+CREATE TABLE IF NOT EXISTS sessions (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) REFERENCES users(id),
+    device_name VARCHAR(100),
+    user_agent VARCHAR(255),
+    ip_address VARCHAR(45),
+    current_token_id VARCHAR(64) NOT NULL,
+    last_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP,
+    revoked_reason VARCHAR(50),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id, revoked_at);
+
+-- Password histories table
+CREATE TABLE IF NOT EXISTS password_histories (
+    id VARCHAR(50) PRIMARY KEY,
+    user_id VARCHAR(36) REFERENCES users(id),
+    password_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_histories_user_id ON password_histories(user_id, created_at);
+
+-- Password reset tokens table
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id VARCHAR(50) PRIMARY KEY,
+    user_id VARCHAR(36) REFERENCES users(id),
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    channel VARCHAR(20),
+    requested_ip VARCHAR(45),
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user_id ON password_reset_tokens(user_id);
+
+-- User identities table
+CREATE TABLE IF NOT EXISTS user_identities (
+    id VARCHAR(50) PRIMARY KEY,
+    user_id VARCHAR(36) REFERENCES users(id),
+    provider VARCHAR(50) NOT NULL,
+    open_id VARCHAR(255) NOT NULL,
+    union_id VARCHAR(64),
+    last_login_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(provider, open_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+CREATE INDEX IF NOT EXISTS idx_user_identities_union_id ON user_identities(union_id);
+
 -- Documents table
 CREATE TABLE IF NOT EXISTS documents (
     id VARCHAR(36) PRIMARY KEY,
@@ -89,6 +181,7 @@ CREATE TABLE IF NOT EXISTS documents (
     description TEXT,
     file_path VARCHAR(500),
     file_size BIGINT,
+    content_hash VARCHAR(64),
     mime_type VARCHAR(100),
     team_id VARCHAR(36),
     created_by VARCHAR(36) REFERENCES users(id),
@@ -103,11 +196,253 @@ CREATE TABLE IF NOT EXISTS document_versions (
     document_id VARCHAR(36) REFERENCES documents(id),
     file_path VARCHAR(500),
     file_size BIGINT,
+    content_hash VARCHAR(64),
     version_number INTEGER NOT NULL,
     created_by VARCHAR(36) REFERENCES users(id),
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Upload sessions table
+CREATE TABLE IF NOT EXISTS upload_sessions (
+    id VARCHAR(50) PRIMARY KEY,
+    owner_id VARCHAR(50),
+    team_id VARCHAR(50),
+    title VARCHAR(200),
+    description TEXT,
+    tags JSONB,
+    file_name VARCHAR(255),
+    mime_type VARCHAR(100),
+    total_size BIGINT,
+    chunk_size BIGINT,
+    total_chunks INTEGER,
+    file_hash VARCHAR(64),
+    status VARCHAR(20),
+    document_id VARCHAR(50),
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Upload chunks table
+CREATE TABLE IF NOT EXISTS upload_chunks (
+    id VARCHAR(50) PRIMARY KEY,
+    session_id VARCHAR(50) REFERENCES upload_sessions(id) ON DELETE CASCADE,
+    chunk_index INTEGER NOT NULL,
+    size BIGINT,
+    checksum VARCHAR(64),
+    storage_key VARCHAR(500),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (session_id, chunk_index)
+);
+
+-- Document search index table
+CREATE TABLE IF NOT EXISTS search_index_entries (
+    document_id VARCHAR(50) PRIMARY KEY REFERENCES documents(id) ON DELETE CASCADE,
+    title VARCHAR(200),
+    description TEXT,
+    tags TEXT,
+    body TEXT,
+    content_hash VARCHAR(64),
+    length INTEGER DEFAULT 0,
+    indexed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    search_vector TSVECTOR
+);
+
+-- Inverted index postings used when Postgres full-text search is unavailable
+CREATE TABLE IF NOT EXISTS search_postings (
+    term VARCHAR(100),
+    document_id VARCHAR(50),
+    frequency INTEGER DEFAULT 0,
+    PRIMARY KEY (term, document_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_search_index_entries_vector ON search_index_entries USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_search_postings_document_id ON search_postings(document_id);
+
+-- Document processing jobs table
+CREATE TABLE IF NOT EXISTS processing_jobs (
+    id VARCHAR(50) PRIMARY KEY,
+    document_id VARCHAR(50) REFERENCES documents(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER DEFAULT 0,
+    max_attempts INTEGER DEFAULT 5,
+    next_run_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    locked_by VARCHAR(100),
+    locked_until TIMESTAMP,
+    last_error TEXT,
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_processing_jobs_document_id ON processing_jobs(document_id);
+CREATE INDEX IF NOT EXISTS idx_processing_jobs_due ON processing_jobs(status, next_run_at);
+
+-- Document processing steps table
+CREATE TABLE IF NOT EXISTS processing_steps (
+    id VARCHAR(50) PRIMARY KEY,
+    job_id VARCHAR(50) REFERENCES processing_jobs(id) ON DELETE CASCADE,
+    document_id VARCHAR(50),
+    stage VARCHAR(50) NOT NULL,
+    position INTEGER,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER DEFAULT 0,
+    output TEXT,
+    error TEXT,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (job_id, stage)
+);
+
+CREATE INDEX IF NOT EXISTS idx_processing_steps_document_id ON processing_steps(document_id);
+
+-- Dify datasets documents are mirrored into, one per team
+CREATE TABLE IF NOT EXISTS knowledge_datasets (
+    team_id VARCHAR(50) PRIMARY KEY,
+    dataset_id VARCHAR(100) NOT NULL,
+    name VARCHAR(100),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Dify documents mirroring documents
+CREATE TABLE IF NOT EXISTS knowledge_documents (
+    document_id VARCHAR(50) PRIMARY KEY,
+    team_id VARCHAR(50),
+    dataset_id VARCHAR(100),
+    dify_document_id VARCHAR(100),
+    version_id VARCHAR(50),
+    content_hash VARCHAR(64),
+    source VARCHAR(20),
+    fingerprint VARCHAR(64),
+    batch VARCHAR(100),
+    indexing_status VARCHAR(20),
+    error TEXT,
+    synced_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_knowledge_documents_team_id ON knowledge_documents(team_id);
+CREATE INDEX IF NOT EXISTS idx_knowledge_documents_dify_document_id ON knowledge_documents(dify_document_id);
+
+-- Document chunks embedded for semantic search. With pgvector the server adds
+-- an embedding_vector column sized to the configured dimensions.
+CREATE TABLE IF NOT EXISTS document_chunks (
+    id VARCHAR(100) PRIMARY KEY,
+    document_id VARCHAR(50),
+    team_id VARCHAR(50),
+    position INTEGER,
+    content TEXT,
+    embedding BYTEA,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_document_chunks_document_id ON document_chunks(document_id);
+CREATE INDEX IF NOT EXISTS idx_document_chunks_team_id ON document_chunks(team_id);
+
+-- Embedded documents table
+CREATE TABLE IF NOT EXISTS document_embeddings (
+    document_id VARCHAR(50) PRIMARY KEY,
+    team_id VARCHAR(50),
+    model VARCHAR(100),
+    fingerprint VARCHAR(64),
+    chunks INTEGER,
+    centroid BYTEA,
+    embedded_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_document_embeddings_team_id ON document_embeddings(team_id);
+
+-- Near-duplicate documents table
+CREATE TABLE IF NOT EXISTS document_duplicates (
+    document_id VARCHAR(50),
+    duplicate_id VARCHAR(50),
+    similarity DOUBLE PRECISION,
+    detected_at TIMESTAMP,
+    PRIMARY KEY (document_id, duplicate_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_document_duplicates_duplicate_id ON document_duplicates(duplicate_id);
+
+-- Document Q&A conversations table
+CREATE TABLE IF NOT EXISTS conversations (
+    id VARCHAR(50) PRIMARY KEY,
+    user_id VARCHAR(50),
+    team_id VARCHAR(50),
+    title VARCHAR(200),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversations_user_id ON conversations(user_id);
+CREATE INDEX IF NOT EXISTS idx_conversations_team_id ON conversations(team_id);
+
+-- Conversation questions and answers table
+CREATE TABLE IF NOT EXISTS conversation_messages (
+    id VARCHAR(50) PRIMARY KEY,
+    conversation_id VARCHAR(50) REFERENCES conversations(id),
+    role VARCHAR(20),
+    content TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversation_messages_conversation_id ON conversation_messages(conversation_id);
+
+-- Document passages cited by answers table
+CREATE TABLE IF NOT EXISTS conversation_citations (
+    id VARCHAR(50) PRIMARY KEY,
+    message_id VARCHAR(50) REFERENCES conversation_messages(id),
+    number INTEGER,
+    document_id VARCHAR(50),
+    title VARCHAR(200),
+    version_id VARCHAR(50),
+    version INTEGER,
+    snippet TEXT,
+    page INTEGER,
+    score DOUBLE PRECISION
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversation_citations_message_id ON conversation_citations(message_id);
+CREATE INDEX IF NOT EXISTS idx_conversation_citations_document_id ON conversation_citations(document_id);
+
+-- Agent runs table, kept for audit
+CREATE TABLE IF NOT EXISTS agent_runs (
+    id VARCHAR(50) PRIMARY KEY,
+    agent_id VARCHAR(50),
+    user_id VARCHAR(50),
+    role VARCHAR(20),
+    message TEXT,
+    answer TEXT,
+    status VARCHAR(20),
+    steps INTEGER DEFAULT 0,
+    error TEXT,
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_agent_runs_user_id ON agent_runs(user_id);
+
+-- Agent tool calls and answers table
+CREATE TABLE IF NOT EXISTS agent_run_steps (
+    id VARCHAR(100) PRIMARY KEY,
+    run_id VARCHAR(50) REFERENCES agent_runs(id),
+    step INTEGER,
+    kind VARCHAR(20),
+    thought TEXT,
+    tool_call_id VARCHAR(100),
+    tool_name VARCHAR(100),
+    arguments TEXT,
+    result TEXT,
+    status VARCHAR(20),
+    error TEXT,
+    duration_ms BIGINT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_agent_run_steps_run_id ON agent_run_steps(run_id);
+
 -- Document categories table
 CREATE TABLE IF NOT EXISTS document_categories (
     id VARCHAR(36) PRIMARY KEY,
@@ -131,7 +466,12 @@ CREATE TABLE IF NOT EXISTS document_category_relations (
 CREATE TABLE IF NOT EXISTS qrcodes (
     id VARCHAR(36) PRIMARY KEY,
     content TEXT NOT NULL,
+    variables JSONB DEFAULT '{}',
     file_path VARCHAR(500),
+    type VARCHAR(20) DEFAULT 'static',
+    url VARCHAR(500),
+    short_code VARCHAR(16) UNIQUE,
+    batch_id VARCHAR(50),
     team_id VARCHAR(36),
     created_by VARCHAR(36) REFERENCES users(id),
     status VARCHAR(20) DEFAULT 'active',
@@ -139,27 +479,55 @@ CREATE TABLE IF NOT EXISTS qrcodes (
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- QR code scans table
+CREATE TABLE IF NOT EXISTS qrcode_scans (
+    id VARCHAR(50) PRIMARY KEY,
+    qr_code_id VARCHAR(50) REFERENCES qrcodes(id) ON DELETE CASCADE,
+    batch_id VARCHAR(50),
+    scanned_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    scan_date VARCHAR(10) NOT NULL,
+    visitor_id VARCHAR(32),
+    user_agent VARCHAR(500),
+    referrer VARCHAR(500),
+    language VARCHAR(50),
+    device VARCHAR(20),
+    os VARCHAR(20),
+    browser VARCHAR(20)
+);
+
+CREATE INDEX IF NOT EXISTS idx_qrcode_scans_qr_code_date ON qrcode_scans(qr_code_id, scan_date);
+CREATE INDEX IF NOT EXISTS idx_qrcode_scans_batch_date ON qrcode_scans(batch_id, scan_date);
+
 -- Batch QR Codes table
 CREATE TABLE IF NOT EXISTS batch_qrcodes (
     id VARCHAR(36) PRIMARY KEY,
     name VARCHAR(255) NOT NULL,
     description TEXT,
     team_id VARCHAR(36),
+    name_template VARCHAR(200),
+    columns JSONB DEFAULT '[]',
     created_by VARCHAR(36) REFERENCES users(id),
-    status VARCHAR(20) DEFAULT 'active',
+    status VARCHAR(20) DEFAULT 'pending',
+    locked_by VARCHAR(100),
+    locked_until TIMESTAMP,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
 -- Batch QR Code items table
 CREATE TABLE IF NOT EXISTS batch_qrcode_items (
-    id VARCHAR(36) PRIMARY KEY,
-    batch_id VARCHAR(36) REFERENCES batch_qrcodes(id),
+    id VARCHAR(50) PRIMARY KEY,
+    batch_id VARCHAR(36) REFERENCES batch_qrcodes(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    qr_code_id VARCHAR(50),
     content TEXT NOT NULL,
     file_path VARCHAR(500),
-    status VARCHAR(20) DEFAULT 'active',
+    status VARCHAR(20) DEFAULT 'pending',
+    attempts INTEGER DEFAULT 0,
+    error TEXT,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (batch_id, position)
 );
 
 -- Forms table
@@ -223,6 +591,152 @@ CREATE TABLE IF NOT EXISTS employee_lifecycle_events (
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Contracts table
+CREATE TABLE IF NOT EXISTS contracts (
+    id VARCHAR(50) PRIMARY KEY,
+    team_id VARCHAR(36),
+    title VARCHAR(200) NOT NULL,
+    description TEXT,
+    content TEXT NOT NULL,
+    status VARCHAR(20) DEFAULT 'draft',
+    created_by VARCHAR(50),
+    signers JSONB,
+    signed_by JSONB,
+    signing_order VARCHAR(20) DEFAULT 'parallel',
+    content_hash VARCHAR(64),
+    sent_at TIMESTAMP,
+    completed_at TIMESTAMP,
+    certificate_document_id VARCHAR(50),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Contract signers table
+CREATE TABLE IF NOT EXISTS contract_signers (
+    id VARCHAR(50) PRIMARY KEY,
+    contract_id VARCHAR(50) REFERENCES contracts(id) ON DELETE CASCADE,
+    signer_id VARCHAR(50) NOT NULL,
+    position INTEGER NOT NULL,
+    status VARCHAR(20) DEFAULT 'pending',
+    deadline TIMESTAMP,
+    signed_at TIMESTAMP,
+    signature VARCHAR(100),
+    signing_key_id VARCHAR(16),
+    declined_at TIMESTAMP,
+    decline_reason TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (contract_id, signer_id)
+);
+
+-- Contract events table (audit trail, insert only)
+CREATE TABLE IF NOT EXISTS contract_events (
+    id VARCHAR(50) PRIMARY KEY,
+    contract_id VARCHAR(50) NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    actor VARCHAR(50),
+    ip_address VARCHAR(45),
+    user_agent VARCHAR(500),
+    content_hash VARCHAR(64),
+    detail TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_contract_events_contract_id ON contract_events(contract_id, created_at);
+
+-- Contract templates table
+CREATE TABLE IF NOT EXISTS contract_templates (
+    id VARCHAR(50) PRIMARY KEY,
+    team_id VARCHAR(36),
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    title VARCHAR(200) NOT NULL,
+    content TEXT NOT NULL,
+    fields JSONB,
+    created_by VARCHAR(50),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_contract_templates_team_id ON contract_templates(team_id);
+
+-- Approval definitions table
+CREATE TABLE IF NOT EXISTS approval_definitions (
+    id VARCHAR(50) PRIMARY KEY,
+    team_id VARCHAR(36),
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    entity_type VARCHAR(50) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    step_order VARCHAR(20) DEFAULT 'sequential',
+    steps JSONB NOT NULL,
+    is_active BOOLEAN DEFAULT FALSE,
+    created_by VARCHAR(50),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_approval_definitions_active ON approval_definitions(team_id, entity_type, action) WHERE is_active;
+
+-- Approval requests table
+CREATE TABLE IF NOT EXISTS approval_requests (
+    id VARCHAR(50) PRIMARY KEY,
+    definition_id VARCHAR(50),
+    team_id VARCHAR(36),
+    entity_type VARCHAR(50) NOT NULL,
+    entity_id VARCHAR(50) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    department_id VARCHAR(36),
+    requested_by VARCHAR(50),
+    payload TEXT,
+    status VARCHAR(20) DEFAULT 'pending',
+    step_order VARCHAR(20),
+    steps JSONB NOT NULL,
+    current_step INTEGER DEFAULT 0,
+    apply_error TEXT,
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_approval_requests_team_id ON approval_requests(team_id, status);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_approval_requests_pending ON approval_requests(entity_type, entity_id, action) WHERE status = 'pending';
+
+-- Approval tasks table
+CREATE TABLE IF NOT EXISTS approval_tasks (
+    id VARCHAR(50) PRIMARY KEY,
+    request_id VARCHAR(50) REFERENCES approval_requests(id) ON DELETE CASCADE,
+    step INTEGER NOT NULL,
+    approver_id VARCHAR(50) NOT NULL,
+    delegated_from VARCHAR(50),
+    escalated_from VARCHAR(50),
+    status VARCHAR(20) DEFAULT 'pending',
+    due_at TIMESTAMP,
+    escalated_at TIMESTAMP,
+    comment TEXT,
+    decided_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_approval_tasks_request_id ON approval_tasks(request_id, step);
+CREATE INDEX IF NOT EXISTS idx_approval_tasks_approver_id ON approval_tasks(approver_id, status);
+CREATE INDEX IF NOT EXISTS idx_approval_tasks_due ON approval_tasks(status, due_at);
+
+-- Approval delegations table
+CREATE TABLE IF NOT EXISTS approval_delegations (
+    id VARCHAR(50) PRIMARY KEY,
+    delegator_id VARCHAR(50) NOT NULL,
+    delegate_id VARCHAR(50) NOT NULL,
+    entity_type VARCHAR(50),
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP NOT NULL,
+    reason TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_approval_delegations_delegator_id ON approval_delegations(delegator_id);
+
 -- Insert default roles
 INSERT INTO roles (id, name, description) VALUES 
 ('role_admin', 'admin', 'System administrator with full access'),
@@ -236,7 +750,9 @@ INSERT INTO permissions (id, name, resource, action, description) VALUES
 ('perm_write_document', 'write_document', 'document', 'write', 'Create and edit documents'),
 ('perm_delete_document', 'delete_document', 'document', 'delete', 'Delete documents'),
 ('perm_manage_user', 'manage_user', 'user', 'manage', 'Manage users'),
-('perm_manage_role', 'manage_role', 'role', 'manage', 'Manage roles and permissions')
+('perm_manage_role', 'manage_role', 'role', 'manage', 'Manage roles and permissions'),
+('perm_read_employee', 'read_employee', 'employee', 'read', 'Look up employees and departments'),
+('perm_read_contract', 'read_contract', 'contract', 'read', 'Read contracts')
 ON CONFLICT (name) DO NOTHING;
 
 -- Assign permissions to admin role
@@ -245,5 +761,17 @@ INSERT INTO role_permissions (id, role_id, permission_id) VALUES
 ('rp_admin_2', 'role_admin', 'perm_write_document'),
 ('rp_admin_3', 'role_admin', 'perm_delete_document'),
 ('rp_admin_4', 'role_admin', 'perm_manage_user'),
-('rp_admin_5', 'role_admin', 'perm_manage_role')
+('rp_admin_5', 'role_admin', 'perm_manage_role'),
+('rp_admin_6', 'role_admin', 'perm_read_employee'),
+('rp_admin_7', 'role_admin', 'perm_read_contract')
+ON CONFLICT DO NOTHING;
+
+-- Assign read permissions to users and managers, used by agent tools
+INSERT INTO role_permissions (id, role_id, permission_id) VALUES 
+('rp_user_1', 'role_user', 'perm_read_document'),
+('rp_user_2', 'role_user', 'perm_read_employee'),
+('rp_user_3', 'role_user', 'perm_read_contract'),
+('rp_manager_1', 'role_manager', 'perm_read_document'),
+('rp_manager_2', 'role_manager', 'perm_read_employee'),
+('rp_manager_3', 'role_manager', 'perm_read_contract')
 ON CONFLICT DO NOTHING;
\ No newline at end of file
@@ -0,0 +1,38 @@
+package batchdata
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// parseCSV reads the records of a UTF-8 CSV file, placing each record at the
+// index of its line so that row numbers match the file. Rows may have different
+// numbers of cells.
+func parseCSV(data []byte) ([][]string, error) {
+	data = trimBOM(data)
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("CSV file must be UTF-8 encoded")
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+
+	var records [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV file: %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+		for len(records) < line-1 {
+			records = append(records, nil) // blank lines are not returned by the reader
+		}
+		records = append(records, record)
+	}
+}
@@ -0,0 +1,169 @@
+// Package batchdata reads the rows of CSV and XLSX files that supply template
+// variables for batch QR codes, and expands {variable} templates with them.
+package batchdata
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// Limits of a data file
+const (
+	MaxFileSize = 10 << 20
+	MaxRows     = 10000
+)
+
+// Table holds the rows of a data file keyed by column name
+type Table struct {
+	Columns []string
+	Rows    []*Row
+}
+
+// Row is one non-blank row of a data file
+type Row struct {
+	Number int               // row number in the file, counting from 1
+	Values map[string]string // cell values keyed by column name, trimmed
+}
+
+// Parse reads a CSV or XLSX file, chosen by the extension of filename. The
+// first row names the columns; names are lower-cased and spaces or dashes
+// become underscores, so "Asset No" is used as {asset_no}. Blank rows are skipped.
+func Parse(filename string, r io.Reader) (*Table, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxFileSize+1))
+	if err != nil {
+		return nil, errors.New("failed to read file")
+	}
+	if len(data) > MaxFileSize {
+		return nil, fmt.Errorf("file must be at most %d MB", MaxFileSize>>20)
+	}
+
+	var records [][]string
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		records, err = parseCSV(data)
+	case ".xlsx":
+		records, err = parseXLSX(data)
+	default:
+		return nil, errors.New("file must be a .csv or .xlsx file")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newTable(records)
+}
+
+// newTable builds a table from records indexed by row, whose first non-blank
+// record is the header
+func newTable(records [][]string) (*Table, error) {
+	headerIndex := 0
+	for headerIndex < len(records) && isBlank(records[headerIndex]) {
+		headerIndex++
+	}
+	if headerIndex == len(records) {
+		return nil, errors.New("file is empty")
+	}
+	headers := records[headerIndex]
+
+	table := &Table{}
+	seen := make(map[string]bool)
+	for i, header := range headers {
+		column := normalizeColumn(header)
+		if column == "" {
+			// Trailing empty header cells are common in spreadsheets
+			if isBlank(headers[i:]) {
+				break
+			}
+			return nil, fmt.Errorf("column %d has no name", i+1)
+		}
+		if !isIdentifier(column) {
+			return nil, fmt.Errorf("column name %q may only contain letters, digits and underscores", header)
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("duplicate column %q", column)
+		}
+		seen[column] = true
+		table.Columns = append(table.Columns, column)
+	}
+	if len(table.Columns) == 0 {
+		return nil, errors.New("file has no columns")
+	}
+
+	for i := headerIndex + 1; i < len(records); i++ {
+		record := records[i]
+		if isBlank(record) {
+			continue
+		}
+		if len(table.Rows) == MaxRows {
+			return nil, fmt.Errorf("file must have at most %d rows", MaxRows)
+		}
+		row := &Row{Number: i + 1, Values: make(map[string]string, len(table.Columns))}
+		for j, column := range table.Columns {
+			if j < len(record) {
+				row.Values[column] = strings.TrimSpace(record[j])
+			} else {
+				row.Values[column] = ""
+			}
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	if len(table.Rows) == 0 {
+		return nil, errors.New("file has no rows")
+	}
+
+	return table, nil
+}
+
+// HasColumn reports whether the table has a column
+func (t *Table) HasColumn(column string) bool {
+	for _, c := range t.Columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeColumn turns a header into a variable name
+func normalizeColumn(header string) string {
+	header = strings.TrimSpace(header)
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return '_'
+		}
+		return unicode.ToLower(r)
+	}, header)
+}
+
+// isIdentifier reports whether s is a valid variable name
+func isIdentifier(s string) bool {
+	for _, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// isBlank reports whether every cell of a record is empty
+func isBlank(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// utf8BOM is written at the start of CSV files by Excel
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// trimBOM removes a leading UTF-8 byte order mark
+func trimBOM(data []byte) []byte {
+	return bytes.TrimPrefix(data, utf8BOM)
+}
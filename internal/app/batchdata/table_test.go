@@ -0,0 +1,119 @@
+package batchdata
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testXLSX builds a minimal XLSX file with a worksheet and shared strings
+func testXLSX(t *testing.T, sheet, sharedStrings string) []byte {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	parts := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+			`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Assets" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Target="worksheets/data.xml"/></Relationships>`,
+		"xl/worksheets/data.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<sheetData>` + sheet + `</sheetData></worksheet>`,
+		"xl/sharedStrings.xml": `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			sharedStrings + `</sst>`,
+	}
+	for name, content := range parts {
+		w, err := archive.Create(name)
+		assert.NoError(t, err)
+		w.Write([]byte(content))
+	}
+	assert.NoError(t, archive.Close())
+	return buf.Bytes()
+}
+
+// TestParseCSV tests reading CSV files
+func TestParseCSV(t *testing.T) {
+	// Test headers are normalized and row numbers match the file
+	t.Run("Parse", func(t *testing.T) {
+		data := "\xef\xbb\xbfAsset No,Room-Name\n A-1 ,Lab\n\n\"A-2\",\"Office, 2nd floor\"\n,\n"
+		table, err := Parse("assets.CSV", strings.NewReader(data))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"asset_no", "room_name"}, table.Columns)
+		assert.Len(t, table.Rows, 2)
+		assert.Equal(t, 2, table.Rows[0].Number)
+		assert.Equal(t, map[string]string{"asset_no": "A-1", "room_name": "Lab"}, table.Rows[0].Values)
+		assert.Equal(t, 4, table.Rows[1].Number)
+		assert.Equal(t, "Office, 2nd floor", table.Rows[1].Values["room_name"])
+		assert.True(t, table.HasColumn("room_name"))
+	})
+
+	// Test short rows get empty values for the missing cells
+	t.Run("ShortRows", func(t *testing.T) {
+		table, err := Parse("assets.csv", strings.NewReader("a,b,c\n1\n"))
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"a": "1", "b": "", "c": ""}, table.Rows[0].Values)
+	})
+
+	// Test invalid files
+	t.Run("Errors", func(t *testing.T) {
+		tests := map[string]string{
+			"":                   "file is empty",
+			"name\n":             "file has no rows",
+			"name,name\nx,y\n":   `duplicate column "name"`,
+			"name,,url\nx,y,z\n": "column 2 has no name",
+			"name,url!\nx,y\n":   `column name "url!" may only contain letters, digits and underscores`,
+			"name\n\xff\xfe\n":   "CSV file must be UTF-8 encoded",
+			"name\n\"unclosed\n": "invalid CSV file",
+		}
+		for data, expected := range tests {
+			_, err := Parse("data.csv", strings.NewReader(data))
+			if assert.Error(t, err, data) {
+				assert.Contains(t, err.Error(), expected)
+			}
+		}
+
+		_, err := Parse("data.txt", strings.NewReader("name\nx\n"))
+		assert.EqualError(t, err, "file must be a .csv or .xlsx file")
+	})
+}
+
+// TestParseXLSX tests reading XLSX files
+func TestParseXLSX(t *testing.T) {
+	// Test shared, inline, numeric and boolean cells of the first worksheet
+	t.Run("Parse", func(t *testing.T) {
+		data := testXLSX(t,
+			`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="inlineStr"><is><t>Active</t></is></c></row>`+
+				`<row r="3"><c r="A3" t="s"><v>2</v></c><c r="B3"><v>0.30000000000000004</v></c><c r="C3" t="b"><v>1</v></c></row>`+
+				`<row r="4"><c r="A4" t="inlineStr"><is><r><t>A-</t></r><r><t>2</t></r></is></c><c r="C4" t="n"><v>1E+3</v></c></row>`,
+			`<si><t>Asset No</t></si><si><t>Weight</t></si><si><t>A-1</t></si>`)
+
+		table, err := Parse("assets.xlsx", bytes.NewReader(data))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"asset_no", "weight", "active"}, table.Columns)
+		assert.Len(t, table.Rows, 2)
+		assert.Equal(t, 3, table.Rows[0].Number)
+		assert.Equal(t, map[string]string{"asset_no": "A-1", "weight": "0.3", "active": "TRUE"}, table.Rows[0].Values)
+		assert.Equal(t, map[string]string{"asset_no": "A-2", "weight": "", "active": "1000"}, table.Rows[1].Values)
+	})
+
+	// Test files that are not XLSX archives
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := Parse("assets.xlsx", strings.NewReader("name\nx\n"))
+		assert.EqualError(t, err, "invalid XLSX file")
+	})
+}
+
+// TestTemplate tests finding and expanding template variables
+func TestTemplate(t *testing.T) {
+	template := "https://example.com/{room}/{asset_no}?n={index}&r={room}"
+	assert.Equal(t, []string{"room", "asset_no", "index"}, Variables(template))
+	assert.Equal(t, "https://example.com/Lab/A-1?n=3&r=Lab",
+		Expand(template, map[string]string{"room": "Lab", "asset_no": "A-1", "index": "3"}))
+
+	// Test unknown placeholders and other braces are left as they are
+	assert.Equal(t, "A-1 {missing} {not a variable}",
+		Expand("{asset_no} {missing} {not a variable}", map[string]string{"asset_no": "A-1"}))
+	assert.Empty(t, Variables("no variables"))
+}
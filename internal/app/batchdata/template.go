@@ -0,0 +1,37 @@
+package batchdata
+
+import (
+	"regexp"
+	"strings"
+)
+
+// placeholder matches a {variable} in a template
+var placeholder = regexp.MustCompile(`\{([\pL\pN_]+)\}`)
+
+// Variables returns the names of the variables used in a template, in order of
+// first use
+func Variables(template string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range placeholder.FindAllStringSubmatch(template, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// Expand replaces the {variable} placeholders of a template with their values.
+// Placeholders without a value are left as they are.
+func Expand(template string, values map[string]string) string {
+	if !strings.Contains(template, "{") {
+		return template
+	}
+	return placeholder.ReplaceAllStringFunc(template, func(match string) string {
+		if value, ok := values[match[1:len(match)-1]]; ok {
+			return value
+		}
+		return match
+	})
+}
@@ -0,0 +1,227 @@
+package batchdata
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// maxPartSize limits the uncompressed size of a part of an XLSX file
+const maxPartSize = 64 << 20
+
+// xlsxWorkbook is the part of xl/workbook.xml listing the worksheets
+type xlsxWorkbook struct {
+	Sheets []struct {
+		RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+// xlsxRelationships is the content of xl/_rels/workbook.xml.rels
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// xlsxText is a string made of plain text or formatted runs
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+// String joins the text and the runs
+func (t *xlsxText) String() string {
+	var b strings.Builder
+	b.WriteString(t.T)
+	for _, run := range t.Runs {
+		b.WriteString(run.T)
+	}
+	return b.String()
+}
+
+// xlsxSharedStrings is the content of xl/sharedStrings.xml
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+// xlsxWorksheet is the part of a worksheet holding the cells
+type xlsxWorksheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			R      string   `xml:"r,attr"`
+			T      string   `xml:"t,attr"`
+			V      string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// parseXLSX reads the cell values of the first worksheet of an XLSX file,
+// placing each row at the index of its row number
+func parseXLSX(data []byte) ([][]string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errors.New("invalid XLSX file")
+	}
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		files[file.Name] = file
+	}
+
+	sheetPath, err := firstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var shared xlsxSharedStrings
+	if file, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decodePart(file, &shared); err != nil {
+			return nil, err
+		}
+	}
+
+	var sheet xlsxWorksheet
+	file, ok := files[sheetPath]
+	if !ok {
+		return nil, errors.New("invalid XLSX file: worksheet not found")
+	}
+	if err := decodePart(file, &sheet); err != nil {
+		return nil, err
+	}
+
+	var records [][]string
+	for _, row := range sheet.Rows {
+		index := len(records)
+		if row.R > 0 {
+			index = row.R - 1
+		}
+		if index > MaxRows*2 {
+			return nil, errors.New("file has too many rows")
+		}
+		for len(records) <= index {
+			records = append(records, nil)
+		}
+
+		var record []string
+		for _, cell := range row.Cells {
+			column := len(record)
+			if cell.R != "" {
+				column = columnIndex(cell.R)
+			}
+			if column < 0 || column > 1000 {
+				return nil, errors.New("invalid XLSX file: bad cell reference " + cell.R)
+			}
+			for len(record) <= column {
+				record = append(record, "")
+			}
+
+			switch cell.T {
+			case "s":
+				i, err := strconv.Atoi(cell.V)
+				if err != nil || i < 0 || i >= len(shared.Items) {
+					return nil, errors.New("invalid XLSX file: bad shared string")
+				}
+				record[column] = shared.Items[i].String()
+			case "inlineStr":
+				record[column] = cell.Inline.String()
+			case "b":
+				if cell.V == "1" {
+					record[column] = "TRUE"
+				} else {
+					record[column] = "FALSE"
+				}
+			case "", "n":
+				record[column] = formatNumber(cell.V)
+			default:
+				record[column] = cell.V
+			}
+		}
+		records[index] = record
+	}
+
+	return records, nil
+}
+
+// firstSheetPath finds the part holding the first worksheet of the workbook
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	const fallback = "xl/worksheets/sheet1.xml"
+
+	workbookFile, ok := files["xl/workbook.xml"]
+	if !ok {
+		return "", errors.New("invalid XLSX file: workbook not found")
+	}
+	var workbook xlsxWorkbook
+	if err := decodePart(workbookFile, &workbook); err != nil {
+		return "", err
+	}
+	relsFile, ok := files["xl/_rels/workbook.xml.rels"]
+	if len(workbook.Sheets) == 0 || !ok {
+		return fallback, nil
+	}
+	var rels xlsxRelationships
+	if err := decodePart(relsFile, &rels); err != nil {
+		return "", err
+	}
+
+	for _, rel := range rels.Relationships {
+		if rel.ID != workbook.Sheets[0].RelID {
+			continue
+		}
+		// Targets are relative to xl/ unless they are absolute
+		if strings.HasPrefix(rel.Target, "/") {
+			return strings.TrimPrefix(rel.Target, "/"), nil
+		}
+		return path.Join("xl", rel.Target), nil
+	}
+	return fallback, nil
+}
+
+// decodePart unmarshals an XML part of the archive
+func decodePart(file *zip.File, v interface{}) error {
+	if file.UncompressedSize64 > maxPartSize {
+		return errors.New("invalid XLSX file: part too large")
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return errors.New("invalid XLSX file")
+	}
+	defer reader.Close()
+
+	if err := xml.NewDecoder(io.LimitReader(reader, maxPartSize)).Decode(v); err != nil {
+		return errors.New("invalid XLSX file")
+	}
+	return nil
+}
+
+// columnIndex returns the zero-based column of a cell reference such as "AB12"
+func columnIndex(ref string) int {
+	column := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		column = column*26 + int(r-'A'+1)
+	}
+	return column - 1
+}
+
+// formatNumber formats a numeric cell the way spreadsheets display it, with at
+// most 15 significant digits and without exponent, so a stored 0.30000000000000004
+// reads as "0.3" and 1E+3 as "1000"
+func formatNumber(value string) string {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value
+	}
+	f, _ = strconv.ParseFloat(strconv.FormatFloat(f, 'g', 15, 64), 64)
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
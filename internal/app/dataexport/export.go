@@ -0,0 +1,270 @@
+// Package dataexport streams the entries of forms, data collections and
+// surveys as CSV, XLSX or JSON Lines files, with a column per field of the
+// schema the entries were submitted against.
+package dataexport
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"cdk-office/internal/app/formschema"
+	"gorm.io/gorm"
+)
+
+// Export formats
+const (
+	FormatCSV    = "csv"
+	FormatXLSX   = "xlsx"
+	FormatNDJSON = "ndjson"
+)
+
+// Keys of the columns describing the entries rather than their data
+const (
+	ColumnID        = "_id"
+	ColumnCreatedBy = "_created_by"
+	ColumnCreatedAt = "_created_at"
+	ColumnData      = "_data" // the whole data, when the schema declares no fields
+)
+
+// Column is a column of an export
+type Column struct {
+	Key   string `json:"key"` // names of the properties of the field joined by dots
+	Label string `json:"label"`
+	Type  string `json:"type,omitempty"`
+	path  []string
+}
+
+// Entry is a row of the entries table
+type Entry struct {
+	ID        string
+	CreatedBy string
+	CreatedAt time.Time
+	Data      string
+}
+
+// Table names the columns of an entries table holding the submitter and the
+// data. The table must also have id and created_at columns.
+type Table struct {
+	CreatedBy string
+	Data      string
+}
+
+// Options filter the entries of an export and choose its format and columns
+type Options struct {
+	Format    string
+	From      *time.Time // entries created at or after From
+	To        *time.Time // entries created before To
+	CreatedBy string
+	Columns   []string // keys of the columns in order, all columns when empty
+}
+
+// Export is an export of entries ready to be streamed
+type Export struct {
+	Format  string
+	Columns []*Column
+	query   *gorm.DB
+}
+
+// Columns lists the columns of the entries of a schema: the entry ID, submitter
+// and submission time, then a column per field. Schemas that declare no fields
+// or can not be compiled export the whole data in one column.
+func Columns(schema string) []*Column {
+	columns := []*Column{
+		{Key: ColumnID, Label: "ID", Type: "string"},
+		{Key: ColumnCreatedBy, Label: "Submitted by", Type: "string"},
+		{Key: ColumnCreatedAt, Label: "Submitted at", Type: "string"},
+	}
+	var fields []*formschema.Field
+	if strings.TrimSpace(schema) != "" {
+		if compiled, err := formschema.Compile(schema); err == nil {
+			fields = compiled.Fields()
+		}
+	}
+	if len(fields) == 0 {
+		return append(columns, &Column{Key: ColumnData, Label: "Data", Type: "object"})
+	}
+	for _, field := range fields {
+		columns = append(columns, &Column{
+			Key:   strings.Join(field.Path, "."),
+			Label: field.Title,
+			Type:  field.Type,
+			path:  field.Path,
+		})
+	}
+	return columns
+}
+
+// New prepares an export of the entries selected by query, filtered by opts.
+// Errors start with "invalid export".
+func New(query *gorm.DB, table Table, schema string, opts *Options) (*Export, error) {
+	format := opts.Format
+	if format == "jsonl" {
+		format = FormatNDJSON
+	}
+	if format != FormatCSV && format != FormatXLSX && format != FormatNDJSON {
+		return nil, fmt.Errorf("invalid export format %q", opts.Format)
+	}
+
+	columns := Columns(schema)
+	if len(opts.Columns) > 0 {
+		byKey := make(map[string]*Column, len(columns))
+		for _, column := range columns {
+			byKey[column.Key] = column
+		}
+		selected := make([]*Column, 0, len(opts.Columns))
+		for _, key := range opts.Columns {
+			column, ok := byKey[key]
+			if !ok {
+				return nil, fmt.Errorf("invalid export column %q", key)
+			}
+			selected = append(selected, column)
+		}
+		columns = selected
+	}
+
+	// Work on a copy so the conditions are not added to the caller's query
+	query = query.Session(&gorm.Session{})
+	if opts.From != nil {
+		query = query.Where("created_at >= ?", *opts.From)
+	}
+	if opts.To != nil {
+		query = query.Where("created_at < ?", *opts.To)
+	}
+	if opts.CreatedBy != "" {
+		query = query.Where(table.CreatedBy+" = ?", opts.CreatedBy)
+	}
+	query = query.Select(fmt.Sprintf("id, %s AS created_by, created_at, %s AS data", table.CreatedBy, table.Data)).
+		Order("created_at asc, id asc")
+
+	return &Export{Format: format, Columns: columns, query: query}, nil
+}
+
+// OptionsFromQuery reads the options of an export from URL query parameters:
+// format (csv by default), from and to as dates or RFC 3339 times, created_by
+// and columns as a comma-separated list of column keys. A to date includes the
+// whole day.
+func OptionsFromQuery(values url.Values) (*Options, error) {
+	opts := &Options{Format: values.Get("format"), CreatedBy: values.Get("created_by")}
+	if opts.Format == "" {
+		opts.Format = FormatCSV
+	}
+	for _, bound := range []struct {
+		name   string
+		target **time.Time
+	}{{"from", &opts.From}, {"to", &opts.To}} {
+		value := values.Get(bound.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			if t, err = time.Parse("2006-01-02", value); err != nil {
+				return nil, fmt.Errorf("invalid export filter: %s must be a date or an RFC 3339 time", bound.name)
+			}
+			if bound.name == "to" {
+				t = t.AddDate(0, 0, 1)
+			}
+		}
+		*bound.target = &t
+	}
+	if columns := values.Get("columns"); columns != "" {
+		for _, key := range strings.Split(columns, ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				opts.Columns = append(opts.Columns, key)
+			}
+		}
+	}
+	return opts, nil
+}
+
+// ContentType returns the MIME type of the exported file
+func (e *Export) ContentType() string {
+	switch e.Format {
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	default:
+		return "text/csv; charset=utf-8"
+	}
+}
+
+// FileName returns the name of the exported file
+func (e *Export) FileName(name string) string {
+	return name + "." + e.Format
+}
+
+// Stream writes the entries to w, reading them one at a time from the database
+func (e *Export) Stream(w io.Writer) error {
+	out, err := newRowWriter(e.Format, w, e.Columns)
+	if err != nil {
+		return err
+	}
+
+	rows, err := e.query.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	values := make([]interface{}, len(e.Columns))
+	for rows.Next() {
+		var entry Entry
+		if err := e.query.ScanRows(rows, &entry); err != nil {
+			return err
+		}
+		e.values(&entry, values)
+		if err := out.row(values); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return out.close()
+}
+
+// values fills the values of the columns of an entry
+func (e *Export) values(entry *Entry, values []interface{}) {
+	var data interface{}
+	decoder := json.NewDecoder(strings.NewReader(entry.Data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		data = nil
+	}
+
+	for i, column := range e.Columns {
+		switch column.Key {
+		case ColumnID:
+			values[i] = entry.ID
+		case ColumnCreatedBy:
+			values[i] = entry.CreatedBy
+		case ColumnCreatedAt:
+			values[i] = entry.CreatedAt.UTC().Format(time.RFC3339)
+		case ColumnData:
+			if data != nil {
+				values[i] = data
+			} else {
+				values[i] = entry.Data
+			}
+		default:
+			values[i] = lookup(data, column.path)
+		}
+	}
+}
+
+// lookup returns the value at a path of properties, or nil
+func lookup(data interface{}, path []string) interface{} {
+	for _, name := range path {
+		obj, ok := data.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		data = obj[name]
+	}
+	return data
+}
@@ -0,0 +1,176 @@
+package dataexport_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"cdk-office/internal/app/batchdata"
+	"cdk-office/internal/app/dataexport"
+	"cdk-office/internal/app/domain"
+	"cdk-office/internal/shared/testutils"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+const testSchema = `{"fields": [
+	{"name": "name", "type": "text", "label": "Name"},
+	{"name": "age", "type": "integer", "label": "Age"},
+	{"name": "toppings", "type": "checkbox", "label": "Toppings", "options": ["cheese", "ham"]},
+	{"name": "agree", "type": "switch", "label": "Agree"}
+]}`
+
+// setupEntries stores entries of a data collection and returns the query selecting them
+func setupEntries(t *testing.T) (*gorm.DB, func()) {
+	db := testutils.SetupTestDB()
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	for i, entry := range []*domain.DataCollectionEntry{
+		{ID: "entry-1", Data: `{"name": "Ann", "age": 31, "toppings": ["cheese", "ham"], "agree": true}`, CreatedBy: "user-1"},
+		{ID: "entry-2", Data: `{"name": "Bo, \"Jr\"", "age": 4.5}`, CreatedBy: "user-2"},
+		{ID: "entry-3", Data: `{"name": "Cy", "extra": 1}`, CreatedBy: "user-1"},
+	} {
+		entry.CollectionID = "collection-1"
+		entry.CreatedAt = start.AddDate(0, 0, i)
+		assert.NoError(t, db.Create(entry).Error)
+	}
+	query := db.Table("data_collection_entries").Where("collection_id = ?", "collection-1")
+	return query, func() { db.Migrator().DropTable(&domain.DataCollectionEntry{}) }
+}
+
+// export streams an export and returns the file
+func export(t *testing.T, query *gorm.DB, schema string, opts *dataexport.Options) string {
+	e, err := dataexport.New(query, dataexport.Table{CreatedBy: "created_by", Data: "data"}, schema, opts)
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	var out bytes.Buffer
+	assert.NoError(t, e.Stream(&out))
+	return out.String()
+}
+
+// TestColumns tests the columns of schemas
+func TestColumns(t *testing.T) {
+	var labels []string
+	for _, column := range dataexport.Columns(testSchema) {
+		labels = append(labels, column.Key+":"+column.Label)
+	}
+	assert.Equal(t, []string{"_id:ID", "_created_by:Submitted by", "_created_at:Submitted at",
+		"name:Name", "age:Age", "toppings:Toppings", "agree:Agree"}, labels)
+
+	// Test the data is exported whole without fields
+	for _, schema := range []string{"", "{}", "not json"} {
+		columns := dataexport.Columns(schema)
+		assert.Len(t, columns, 4)
+		assert.Equal(t, dataexport.ColumnData, columns[3].Key)
+	}
+}
+
+// TestExportCSV tests exporting entries as CSV with filters and columns
+func TestExportCSV(t *testing.T) {
+	query, teardown := setupEntries(t)
+	defer teardown()
+
+	out := export(t, query, testSchema, &dataexport.Options{Format: dataexport.FormatCSV})
+	assert.True(t, strings.HasPrefix(out, "\ufeff"))
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(out, "\ufeff"))).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"ID", "Submitted by", "Submitted at", "Name", "Age", "Toppings", "Agree"},
+		{"entry-1", "user-1", "2024-05-01T09:00:00Z", "Ann", "31", "cheese, ham", "true"},
+		{"entry-2", "user-2", "2024-05-02T09:00:00Z", `Bo, "Jr"`, "4.5", "", ""},
+		{"entry-3", "user-1", "2024-05-03T09:00:00Z", "Cy", "", "", ""},
+	}, records)
+
+	// Test filtering by submitter and date, and choosing columns
+	opts, err := dataexport.OptionsFromQuery(url.Values{
+		"created_by": {"user-1"},
+		"to":         {"2024-05-02"},
+		"columns":    {"name, _id"},
+	})
+	assert.NoError(t, err)
+	out = export(t, query, testSchema, opts)
+	assert.Equal(t, "\ufeffName,ID\nAnn,entry-1\n", out)
+}
+
+// TestExportXLSX tests exporting entries as an XLSX workbook
+func TestExportXLSX(t *testing.T) {
+	query, teardown := setupEntries(t)
+	defer teardown()
+
+	out := export(t, query, testSchema, &dataexport.Options{Format: dataexport.FormatXLSX, Columns: []string{"name", "age", "agree"}})
+	table, err := batchdata.Parse("entries.xlsx", strings.NewReader(out))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"name", "age", "agree"}, table.Columns)
+	assert.Len(t, table.Rows, 3)
+	assert.Equal(t, map[string]string{"name": "Ann", "age": "31", "agree": "TRUE"}, table.Rows[0].Values)
+	assert.Equal(t, map[string]string{"name": `Bo, "Jr"`, "age": "4.5", "agree": ""}, table.Rows[1].Values)
+}
+
+// TestExportFormulas tests text that spreadsheets would evaluate is exported as text
+func TestExportFormulas(t *testing.T) {
+	db := testutils.SetupTestDB()
+	defer db.Migrator().DropTable(&domain.DataCollectionEntry{})
+	assert.NoError(t, db.Create(&domain.DataCollectionEntry{
+		ID:           "entry-1",
+		CollectionID: "collection-1",
+		Data:         `{"name": "=HYPERLINK(\"https://evil.example\",\"open\")", "note": "@SUM(A1)", "age": -3}`,
+		CreatedBy:    "-user",
+		CreatedAt:    time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC),
+	}).Error)
+	query := db.Table("data_collection_entries").Where("collection_id = ?", "collection-1")
+	schema := `{"fields": [
+		{"name": "name", "type": "text", "label": "+Name"},
+		{"name": "note", "type": "text", "label": "Note"},
+		{"name": "age", "type": "integer", "label": "Age"}
+	]}`
+
+	// CSV cells get a leading apostrophe, numbers stay numbers
+	out := export(t, query, schema, &dataexport.Options{Format: dataexport.FormatCSV, Columns: []string{"name", "note", "age", "_created_by"}})
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(out, "\ufeff"))).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"'+Name", "Note", "Age", "Submitted by"},
+		{`'=HYPERLINK("https://evil.example","open")`, "'@SUM(A1)", "-3", "'-user"},
+	}, records)
+
+	// XLSX cells keep their text with the quote prefix style
+	out = export(t, query, schema, &dataexport.Options{Format: dataexport.FormatXLSX, Columns: []string{"name", "note", "age"}})
+	archive, err := zip.NewReader(strings.NewReader(out), int64(len(out)))
+	assert.NoError(t, err)
+	sheet, err := archive.Open("xl/worksheets/sheet1.xml")
+	assert.NoError(t, err)
+	data, err := io.ReadAll(sheet)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `<c r="A1" s="3" t="inlineStr"><is><t xml:space="preserve">+Name</t>`)
+	assert.Contains(t, string(data), `<c r="A2" s="2" t="inlineStr"><is><t xml:space="preserve">=HYPERLINK(`)
+	assert.Contains(t, string(data), `<c r="B2" s="2" t="inlineStr"><is><t xml:space="preserve">@SUM(A1)</t>`)
+	assert.Contains(t, string(data), `<c r="C2"><v>-3</v></c>`)
+}
+
+// TestExportNDJSON tests exporting entries as JSON Lines
+func TestExportNDJSON(t *testing.T) {
+	query, teardown := setupEntries(t)
+	defer teardown()
+
+	out := export(t, query, "", &dataexport.Options{Format: "jsonl", Columns: []string{"_id", "_data"}})
+	assert.Equal(t, `{"_id":"entry-1","_data":{"age":31,"agree":true,"name":"Ann","toppings":["cheese","ham"]}}
+{"_id":"entry-2","_data":{"age":4.5,"name":"Bo, \"Jr\""}}
+{"_id":"entry-3","_data":{"extra":1,"name":"Cy"}}
+`, out)
+}
+
+// TestInvalidOptions tests invalid options are rejected
+func TestInvalidOptions(t *testing.T) {
+	table := dataexport.Table{CreatedBy: "created_by", Data: "data"}
+	_, err := dataexport.New(nil, table, testSchema, &dataexport.Options{Format: "pdf"})
+	assert.EqualError(t, err, `invalid export format "pdf"`)
+	_, err = dataexport.New(nil, table, testSchema, &dataexport.Options{Format: "csv", Columns: []string{"email"}})
+	assert.EqualError(t, err, `invalid export column "email"`)
+	_, err = dataexport.OptionsFromQuery(url.Values{"from": {"yesterday"}})
+	assert.EqualError(t, err, "invalid export filter: from must be a date or an RFC 3339 time")
+}
@@ -0,0 +1,153 @@
+package dataexport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// rowWriter writes the rows of an export in a file format
+type rowWriter interface {
+	row(values []interface{}) error
+	close() error
+}
+
+// newRowWriter starts a file of the format and writes its header
+func newRowWriter(format string, w io.Writer, columns []*Column) (rowWriter, error) {
+	switch format {
+	case FormatXLSX:
+		return newXLSXWriter(w, columns)
+	case FormatNDJSON:
+		return &ndjsonWriter{w: bufio.NewWriter(w), columns: columns}, nil
+	default:
+		return newCSVWriter(w, columns)
+	}
+}
+
+// csvWriter writes a CSV file with a header row of column labels. The file
+// starts with a byte order mark so spreadsheets read it as UTF-8.
+type csvWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+// newCSVWriter starts a CSV file
+func newCSVWriter(w io.Writer, columns []*Column) (*csvWriter, error) {
+	if _, err := w.Write([]byte("\ufeff")); err != nil {
+		return nil, err
+	}
+	out := &csvWriter{w: csv.NewWriter(w), record: make([]string, len(columns))}
+	for i, column := range columns {
+		out.record[i] = EscapeFormula(column.Label)
+	}
+	if err := out.w.Write(out.record); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// row writes a record, escaping text that spreadsheets would evaluate as a
+// formula. Numbers are written as they are, so negative numbers stay numbers.
+func (c *csvWriter) row(values []interface{}) error {
+	for i, value := range values {
+		c.record[i] = cellText(value)
+		if _, number := value.(json.Number); !number {
+			c.record[i] = EscapeFormula(c.record[i])
+		}
+	}
+	return c.w.Write(c.record)
+}
+
+func (c *csvWriter) close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ndjsonWriter writes a JSON object per line, with the values of the columns
+// keyed by column key in column order
+type ndjsonWriter struct {
+	w       *bufio.Writer
+	columns []*Column
+	line    bytes.Buffer
+}
+
+func (n *ndjsonWriter) row(values []interface{}) error {
+	n.line.Reset()
+	n.line.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			n.line.WriteByte(',')
+		}
+		key, _ := json.Marshal(n.columns[i].Key)
+		n.line.Write(key)
+		n.line.WriteByte(':')
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		n.line.Write(encoded)
+	}
+	n.line.WriteString("}\n")
+	_, err := n.w.Write(n.line.Bytes())
+	return err
+}
+
+func (n *ndjsonWriter) close() error {
+	return n.w.Flush()
+}
+
+// cellText formats a value as the text of a cell. Lists of plain values are
+// joined by commas, other lists and objects are written as JSON.
+func cellText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		texts := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				return jsonText(v)
+			}
+			texts = append(texts, cellText(item))
+		}
+		return strings.Join(texts, ", ")
+	default:
+		return jsonText(v)
+	}
+}
+
+// isFormula reports whether spreadsheets would evaluate text as a formula, or
+// as the start of one
+func isFormula(text string) bool {
+	return text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0]))
+}
+
+// EscapeFormula prefixes text that spreadsheets would evaluate as a formula
+// with an apostrophe, so that it is shown as text. Cells of CSV files written
+// from entered data, such as =HYPERLINK(...), must not run when opened.
+func EscapeFormula(text string) string {
+	if isFormula(text) {
+		return "'" + text
+	}
+	return text
+}
+
+// jsonText encodes a value as compact JSON
+func jsonText(value interface{}) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(encoded)
+}
@@ -0,0 +1,153 @@
+package dataexport
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// Limits of a worksheet
+const (
+	maxXLSXRows     = 1048576
+	maxXLSXCellText = 32767
+)
+
+// Parts of the workbook written before the worksheet
+var xlsxParts = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Entries" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`},
+	{"xl/styles.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts><fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills><borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders><cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs><cellXfs count="4"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0" quotePrefix="1"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1" quotePrefix="1"/></cellXfs></styleSheet>`},
+}
+
+// xlsxWriter writes a workbook with a single worksheet, streaming the rows
+// into the worksheet part. The header row is bold and frozen.
+type xlsxWriter struct {
+	archive *zip.Writer
+	sheet   *bufio.Writer
+	columns []string // cell references of the columns, A, B, ...
+	rows    int
+}
+
+// newXLSXWriter starts a workbook and writes the header row
+func newXLSXWriter(w io.Writer, columns []*Column) (*xlsxWriter, error) {
+	archive := zip.NewWriter(w)
+	for _, part := range xlsxParts {
+		file, err := archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(file, part.content); err != nil {
+			return nil, err
+		}
+	}
+	file, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	x := &xlsxWriter{archive: archive, sheet: bufio.NewWriter(file), columns: make([]string, len(columns))}
+	for i := range columns {
+		x.columns[i] = columnName(i)
+	}
+	x.sheet.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews><sheetData>`)
+
+	header := make([]interface{}, len(columns))
+	for i, column := range columns {
+		header[i] = column.Label
+	}
+	if err := x.writeRow(header, true); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+func (x *xlsxWriter) row(values []interface{}) error {
+	return x.writeRow(values, false)
+}
+
+// writeRow writes a row of cells, in bold for the header. Numbers and booleans
+// keep their type, other values are written as inline strings. Strings that
+// look like formulas get the quote prefix style, so that editing them in a
+// spreadsheet keeps them as text.
+func (x *xlsxWriter) writeRow(values []interface{}, header bool) error {
+	if x.rows == maxXLSXRows {
+		return errors.New("too many entries for an XLSX worksheet")
+	}
+	x.rows++
+	row := strconv.Itoa(x.rows)
+
+	style := ""
+	if header {
+		style = ` s="1"`
+	}
+
+	x.sheet.WriteString(`<row r="` + row + `">`)
+	for i, value := range values {
+		ref := x.columns[i] + row
+		switch v := value.(type) {
+		case nil:
+			continue
+		case json.Number:
+			if _, err := strconv.ParseFloat(v.String(), 64); err == nil {
+				x.sheet.WriteString(`<c r="` + ref + `"` + style + `><v>` + v.String() + `</v></c>`)
+				continue
+			}
+		case bool:
+			b := "0"
+			if v {
+				b = "1"
+			}
+			x.sheet.WriteString(`<c r="` + ref + `"` + style + ` t="b"><v>` + b + `</v></c>`)
+			continue
+		}
+
+		text := []rune(cellText(value))
+		if len(text) > maxXLSXCellText {
+			text = text[:maxXLSXCellText]
+		}
+		textStyle := style
+		if isFormula(string(text)) {
+			textStyle = ` s="2"`
+			if header {
+				textStyle = ` s="3"`
+			}
+		}
+		x.sheet.WriteString(`<c r="` + ref + `"` + textStyle + ` t="inlineStr"><is><t xml:space="preserve">`)
+		xml.EscapeText(x.sheet, []byte(string(text)))
+		x.sheet.WriteString(`</t></is></c>`)
+	}
+	_, err := x.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (x *xlsxWriter) close() error {
+	x.sheet.WriteString(`</sheetData></worksheet>`)
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	return x.archive.Close()
+}
+
+// columnName returns the letters of a column, A for 0
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
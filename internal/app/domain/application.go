@@ -18,8 +18,6 @@ type Application struct {
 	UpdatedAt   time.Time `json:"updated_at"`
 }
 
-
-
 // FormData represents a form in the system
 type FormData struct {
 	ID          string    `json:"id" gorm:"primaryKey"`
@@ -64,36 +62,67 @@ type AppUserPermission struct {
 	CreatedAt    time.Time `json:"created_at"`
 }
 
+// Batch QR code statuses
+const (
+	BatchQRCodePending    = "pending"
+	BatchQRCodeQueued     = "queued" // waiting for a background worker
+	BatchQRCodeGenerating = "generating"
+	BatchQRCodeCompleted  = "completed"
+	BatchQRCodeFailed     = "failed" // finished with at least one failed item
+)
+
+// Batch QR code item statuses
+const (
+	BatchQRCodeItemPending   = "pending"
+	BatchQRCodeItemCompleted = "completed"
+	BatchQRCodeItemFailed    = "failed"
+)
+
 // BatchQRCode represents a batch of QR codes in the system
 type BatchQRCode struct {
-	ID          string    `json:"id" gorm:"primaryKey"`
-	AppID       string    `json:"app_id" gorm:"index"`
-	Name        string    `json:"name" gorm:"size:100"`
-	Description string    `json:"description" gorm:"type:text"`
-	Prefix      string    `json:"prefix" gorm:"size:50"`
-	Count       int       `json:"count"`
-	Type        string    `json:"type" gorm:"size:20"` // static or dynamic
-	URLTemplate string    `json:"url_template" gorm:"size:500"`
-	Config      string    `json:"config" gorm:"type:jsonb"`
-	Status      string    `json:"status" gorm:"size:20"` // pending, generating, completed, failed
-	CreatedBy   string    `json:"created_by" gorm:"size:50"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID           string     `json:"id" gorm:"primaryKey"`
+	AppID        string     `json:"app_id" gorm:"index"`
+	Name         string     `json:"name" gorm:"size:100"`
+	Description  string     `json:"description" gorm:"type:text"`
+	Prefix       string     `json:"prefix" gorm:"size:50"`
+	Count        int        `json:"count"`
+	Type         string     `json:"type" gorm:"size:20"` // static or dynamic
+	URLTemplate  string     `json:"url_template" gorm:"size:500"`
+	NameTemplate string     `json:"name_template" gorm:"size:200"`
+	Columns      string     `json:"columns" gorm:"type:jsonb"` // columns of the imported data, as a JSON array
+	Config       string     `json:"config" gorm:"type:jsonb"`
+	Status       string     `json:"status" gorm:"size:20;index"` // pending, queued, generating, completed, failed
+	LockedBy     string     `json:"locked_by" gorm:"size:100"`
+	LockedUntil  *time.Time `json:"locked_until"`
+	CreatedBy    string     `json:"created_by" gorm:"size:50"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
 }
 
 // BatchQRCodeItem represents an item in a batch of QR codes
 type BatchQRCodeItem struct {
 	ID        string    `json:"id" gorm:"primaryKey"`
-	BatchID   string    `json:"batch_id" gorm:"index"`
+	BatchID   string    `json:"batch_id" gorm:"uniqueIndex:idx_batch_item"`
+	Position  int       `json:"position" gorm:"uniqueIndex:idx_batch_item"`
+	QRCodeID  string    `json:"qr_code_id" gorm:"size:50"`
 	Name      string    `json:"name" gorm:"size:100"`
 	Content   string    `json:"content" gorm:"type:text"`
 	URL       string    `json:"url" gorm:"size:500"`
+	Variables string    `json:"variables" gorm:"type:jsonb"` // row of the imported data, as a JSON object
 	ImagePath string    `json:"image_path" gorm:"size:500"`
-	Status    string    `json:"status" gorm:"size:20"` // pending, generating, completed, failed
+	Status    string    `json:"status" gorm:"size:20"` // pending, completed, failed
+	Attempts  int       `json:"attempts"`
+	Error     string    `json:"error" gorm:"type:text"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// Approval entity type and action of form designs, for approval definitions
+const (
+	ApprovalEntityFormDesign = "form_design"
+	ApprovalActionPublish    = "publish"
+)
+
 // FormDesign represents a form design in the system
 type FormDesign struct {
 	ID          string    `json:"id" gorm:"primaryKey"`
@@ -125,9 +154,9 @@ type DataCollection struct {
 
 // DataCollectionEntry represents a data entry in a collection
 type DataCollectionEntry struct {
-	ID          string    `json:"id" gorm:"primaryKey"`
+	ID           string    `json:"id" gorm:"primaryKey"`
 	CollectionID string    `json:"collection_id" gorm:"index"`
-	Data        string    `json:"data" gorm:"type:jsonb"`
-	CreatedBy   string    `json:"created_by" gorm:"size:50"`
-	CreatedAt   time.Time `json:"created_at"`
-}
\ No newline at end of file
+	Data         string    `json:"data" gorm:"type:jsonb"`
+	CreatedBy    string    `json:"created_by" gorm:"size:50"`
+	CreatedAt    time.Time `json:"created_at"`
+}
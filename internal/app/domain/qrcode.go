@@ -4,16 +4,40 @@ import (
 	"time"
 )
 
+// QR code types
+const (
+	QRCodeStatic  = "static"
+	QRCodeDynamic = "dynamic" // encodes a short link whose target URL can change
+)
+
 // QRCode represents a QR code entity
 type QRCode struct {
 	ID        string    `json:"id" gorm:"primaryKey"`
 	AppID     string    `json:"app_id" gorm:"index"`
+	BatchID   string    `json:"batch_id,omitempty" gorm:"size:50;index"`
 	Name      string    `json:"name"`
 	Content   string    `json:"content"`
-	Type      string    `json:"type"` // static or dynamic
-	URL       string    `json:"url"`
+	Type      string    `json:"type"`                                            // static or dynamic
+	URL       string    `json:"url"`                                             // redirect target of dynamic codes
+	ShortCode *string   `json:"short_code,omitempty" gorm:"size:16;uniqueIndex"` // only set for dynamic codes
 	ImagePath string    `json:"image_path"`
 	CreatedBy string    `json:"created_by"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
-}
\ No newline at end of file
+}
+
+// QRCodeScan records one scan of a dynamic QR code
+type QRCodeScan struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	QRCodeID  string    `json:"qr_code_id" gorm:"size:50;index"`
+	BatchID   string    `json:"batch_id" gorm:"size:50;index"`
+	ScannedAt time.Time `json:"scanned_at" gorm:"index"`
+	ScanDate  string    `json:"scan_date" gorm:"size:10;index"` // UTC day, used for daily statistics
+	VisitorID string    `json:"visitor_id" gorm:"size:32"`      // hash of the client address and user agent
+	UserAgent string    `json:"user_agent" gorm:"size:500"`
+	Referrer  string    `json:"referrer" gorm:"size:500"`
+	Language  string    `json:"language" gorm:"size:50"`
+	Device    string    `json:"device" gorm:"size:20"` // mobile, tablet, desktop or bot
+	OS        string    `json:"os" gorm:"size:20"`
+	Browser   string    `json:"browser" gorm:"size:20"`
+}
@@ -0,0 +1,226 @@
+package formschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// designerDocument is a schema saved by the form designer
+type designerDocument struct {
+	Fields []*designerField `json:"fields"`
+}
+
+// designerField is a field of a form designer document. Group fields hold an
+// object of nested fields, and table fields a list of rows of nested fields.
+type designerField struct {
+	Name      string           `json:"name"`
+	Type      string           `json:"type"`
+	Label     string           `json:"label"`
+	Required  bool             `json:"required"`
+	Multiple  bool             `json:"multiple"`
+	Options   []interface{}    `json:"options"`
+	Min       *json.Number     `json:"min"`
+	Max       *json.Number     `json:"max"`
+	MinLength *int             `json:"minLength"`
+	MaxLength *int             `json:"maxLength"`
+	Pattern   string           `json:"pattern"`
+	Fields    []*designerField `json:"fields"`
+}
+
+// designerTypes map the field types of the designer to JSON Schema
+var designerTypes = map[string]map[string]interface{}{
+	"text":      {"type": "string"},
+	"textarea":  {"type": "string"},
+	"password":  {"type": "string"},
+	"richtext":  {"type": "string"},
+	"number":    {"type": "number"},
+	"integer":   {"type": "integer"},
+	"slider":    {"type": "number"},
+	"rating":    {"type": "integer"},
+	"email":     {"type": "string", "format": "email"},
+	"phone":     {"type": "string", "format": "phone"},
+	"url":       {"type": "string", "format": "uri"},
+	"date":      {"type": "string", "format": "date"},
+	"datetime":  {"type": "string", "format": "date-time"},
+	"time":      {"type": "string", "format": "time"},
+	"select":    {"type": "string"},
+	"radio":     {"type": "string"},
+	"checkbox":  {"type": "boolean"},
+	"switch":    {"type": "boolean"},
+	"file":      {"type": "string"},
+	"image":     {"type": "string"},
+	"signature": {"type": "string"},
+	"group":     {"type": "object"},
+	"table":     {"type": "array"},
+}
+
+// isDesignerDocument reports whether a schema is a form designer document
+// rather than a JSON Schema
+func isDesignerDocument(doc interface{}) bool {
+	obj, ok := doc.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	if _, ok := obj["fields"].([]interface{}); !ok {
+		return false
+	}
+	for _, keyword := range []string{"$schema", "$ref", "type", "properties"} {
+		if _, ok := obj[keyword]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+// designerSchema translates a form designer document to JSON Schema. It also
+// returns the order of the fields, by JSON pointer of their properties keyword.
+func designerSchema(schema string) (interface{}, map[string][]string, error) {
+	decoder := json.NewDecoder(strings.NewReader(schema))
+	decoder.UseNumber()
+	var doc designerDocument
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("fields: %v", err)
+	}
+	t := &designerTranslation{order: make(map[string][]string)}
+	root, err := t.object(doc.Fields, "/fields", "")
+	if err != nil {
+		return nil, nil, err
+	}
+	return root, t.order, nil
+}
+
+// designerTranslation translates the fields of a designer document
+type designerTranslation struct {
+	order map[string][]string
+}
+
+// object builds the schema of an object with a property per field. ptr locates
+// the fields in the designer document and schemaPtr the object in the schema.
+func (t *designerTranslation) object(fields []*designerField, ptr, schemaPtr string) (map[string]interface{}, error) {
+	properties := make(map[string]interface{}, len(fields))
+	required := []interface{}{}
+	for i, field := range fields {
+		fieldPtr := ptr + "/" + strconv.Itoa(i)
+		if field == nil || field.Name == "" {
+			return nil, fmt.Errorf("%s/name: field name is required", fieldPtr)
+		}
+		if _, ok := properties[field.Name]; ok {
+			return nil, fmt.Errorf("%s/name: duplicate field %q", fieldPtr, field.Name)
+		}
+		property, err := t.property(field, fieldPtr, schemaPtr+"/properties/"+escape(field.Name))
+		if err != nil {
+			return nil, err
+		}
+		properties[field.Name] = property
+		t.order[schemaPtr+"/properties"] = append(t.order[schemaPtr+"/properties"], field.Name)
+		if field.Required {
+			required = append(required, field.Name)
+		}
+	}
+	return map[string]interface{}{"type": "object", "properties": properties, "required": required}, nil
+}
+
+// property builds the schema of a field
+func (t *designerTranslation) property(field *designerField, ptr, schemaPtr string) (map[string]interface{}, error) {
+	base, ok := designerTypes[field.Type]
+	if !ok {
+		return nil, fmt.Errorf("%s/type: unknown field type %q", ptr, field.Type)
+	}
+	property := make(map[string]interface{}, len(base)+4)
+	for keyword, value := range base {
+		property[keyword] = value
+	}
+	if field.Label != "" {
+		property["title"] = field.Label
+	}
+
+	switch property["type"] {
+	case "string":
+		if field.MinLength != nil {
+			property["minLength"] = json.Number(strconv.Itoa(*field.MinLength))
+		} else if field.Required {
+			property["minLength"] = json.Number("1")
+		}
+		if field.MaxLength != nil {
+			property["maxLength"] = json.Number(strconv.Itoa(*field.MaxLength))
+		}
+		if field.Pattern != "" {
+			if _, err := regexp.Compile(field.Pattern); err != nil {
+				return nil, fmt.Errorf("%s/pattern: unsupported regular expression %q", ptr, field.Pattern)
+			}
+			property["pattern"] = field.Pattern
+		}
+	case "number", "integer":
+		if field.Min != nil {
+			property["minimum"] = *field.Min
+		}
+		if field.Max != nil {
+			property["maximum"] = *field.Max
+		}
+	case "object":
+		nested, err := t.object(field.Fields, ptr+"/fields", schemaPtr)
+		if err != nil {
+			return nil, err
+		}
+		for keyword, value := range nested {
+			property[keyword] = value
+		}
+	case "array":
+		row, err := t.object(field.Fields, ptr+"/fields", schemaPtr+"/items")
+		if err != nil {
+			return nil, err
+		}
+		property["items"] = row
+		if field.Required {
+			property["minItems"] = json.Number("1")
+		}
+	}
+
+	// Choice fields take one of their options, or several with multiple
+	if len(field.Options) > 0 {
+		values := make([]interface{}, len(field.Options))
+		for i, option := range field.Options {
+			value, err := optionValue(option)
+			if err != nil {
+				return nil, fmt.Errorf("%s/options/%d: %v", ptr, i, err)
+			}
+			values[i] = value
+		}
+		choice := map[string]interface{}{"enum": values}
+		if field.Multiple || field.Type == "checkbox" {
+			property = map[string]interface{}{"type": "array", "items": choice, "uniqueItems": true}
+			if field.Required {
+				property["minItems"] = json.Number("1")
+			}
+			if field.Label != "" {
+				property["title"] = field.Label
+			}
+		} else {
+			delete(property, "type")
+			delete(property, "minLength")
+			property["enum"] = values
+		}
+	}
+
+	return property, nil
+}
+
+// optionValue returns the value of an option, given as a value or as an object
+// with a value and a label
+func optionValue(option interface{}) (interface{}, error) {
+	switch o := option.(type) {
+	case string, json.Number, bool:
+		return o, nil
+	case map[string]interface{}:
+		if value, ok := o["value"]; ok {
+			return value, nil
+		}
+		if label, ok := o["label"]; ok {
+			return label, nil
+		}
+	}
+	return nil, fmt.Errorf("option must be a value or an object with a value")
+}
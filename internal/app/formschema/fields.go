@@ -0,0 +1,73 @@
+package formschema
+
+import "strings"
+
+// Field is a property declared by a schema, for listing data as columns
+type Field struct {
+	Path   []string // names of the properties from the root
+	Title  string   // titles or names of the properties, joined by " / "
+	Type   string   // declared type, empty when none or several are declared
+	Format string
+}
+
+// Fields lists the properties declared by the root schema in document order.
+// The properties of nested object schemas are listed in place of the objects,
+// so that each field holds a single value or list.
+func (s *Schema) Fields() []*Field {
+	var fields []*Field
+	collectFields(s.root, nil, nil, map[*node]bool{}, &fields)
+	return fields
+}
+
+// collectFields appends the properties of an object schema to fields
+func collectFields(n *node, path, titles []string, visiting map[*node]bool, fields *[]*Field) {
+	n = resolveRef(n)
+	if n == nil || visiting[n] {
+		return
+	}
+	visiting[n] = true
+	defer delete(visiting, n)
+
+	for _, name := range n.propertyOrder {
+		property := n.properties[name]
+		target := resolveRef(property)
+		if target == nil {
+			continue
+		}
+		title := property.title
+		if title == "" {
+			title = target.title
+		}
+		if title == "" {
+			title = name
+		}
+		fieldPath := append(path[:len(path):len(path)], name)
+		fieldTitles := append(titles[:len(titles):len(titles)], title)
+
+		fieldType := ""
+		if len(target.types) == 1 {
+			fieldType = target.types[0]
+		}
+		if len(target.properties) > 0 && (fieldType == "object" || len(target.types) == 0) && !visiting[target] {
+			collectFields(target, fieldPath, fieldTitles, visiting, fields)
+			continue
+		}
+		*fields = append(*fields, &Field{
+			Path:   fieldPath,
+			Title:  strings.Join(fieldTitles, " / "),
+			Type:   fieldType,
+			Format: target.format,
+		})
+	}
+}
+
+// resolveRef follows the $ref of a schema that declares no properties itself
+func resolveRef(n *node) *node {
+	for i := 0; n != nil && n.ref != nil && n.properties == nil && len(n.types) == 0; i++ {
+		if i > maxDepth {
+			return nil
+		}
+		n = n.ref
+	}
+	return n
+}
@@ -0,0 +1,152 @@
+package formschema
+
+import (
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// format checks the values of a format keyword. Formats are asserted, unlike
+// in plain JSON Schema where they are annotations, and unknown formats are
+// ignored.
+type format struct {
+	description string
+	valid       func(string) bool
+	normalize   func(string) (string, bool) // converts other spellings of a valid value
+}
+
+// formats are the supported values of the format keyword. Besides the JSON
+// Schema formats, "phone" checks the phone numbers of designer phone fields.
+var formats = map[string]*format{
+	"date":      {description: "a date (YYYY-MM-DD)", valid: isDate, normalize: normalizeDate},
+	"date-time": {description: "a date and time (RFC 3339)", valid: isDateTime, normalize: normalizeDateTime},
+	"time":      {description: "a time (HH:MM:SS)", valid: isTime, normalize: normalizeTime},
+	"email":     {description: "an email address", valid: isEmail},
+	"uri":       {description: "an absolute URI", valid: isURI},
+	"uuid":      {description: "a UUID", valid: uuidPattern.MatchString},
+	"ipv4":      {description: "an IPv4 address", valid: isIPv4},
+	"ipv6":      {description: "an IPv6 address", valid: isIPv6},
+	"hostname":  {description: "a host name", valid: isHostname},
+	"regex":     {description: "a regular expression", valid: isRegex},
+	"phone":     {description: "a phone number", valid: isPhone},
+}
+
+var (
+	uuidPattern     = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hostnamePattern = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+	phonePattern    = regexp.MustCompile(`^\+?[0-9 ()./-]+$`)
+)
+
+// Accepted spellings of dates and times, which are normalized to RFC 3339.
+// Date-times without a time zone are taken as UTC.
+var (
+	dateLayouts     = []string{"2006-1-2", "2006/1/2", "2006.1.2"}
+	dateTimeLayouts = []string{"2006-1-2T15:04:05", "2006-1-2 15:04:05", "2006-1-2T15:04", "2006-1-2 15:04", "2006-1-2 15:04:05Z07:00", "2006/1/2 15:04:05", "2006/1/2 15:04"}
+	timeLayouts     = []string{"15:04", "15:04:05", "15:04:05.999999999"}
+)
+
+// isDate reports whether s is an RFC 3339 full-date
+func isDate(s string) bool {
+	_, err := time.Parse("2006-01-02", s)
+	return err == nil
+}
+
+// isDateTime reports whether s is an RFC 3339 date-time
+func isDateTime(s string) bool {
+	_, err := time.Parse(time.RFC3339Nano, s)
+	return err == nil
+}
+
+// isTime reports whether s is a time of day with seconds and an optional offset
+func isTime(s string) bool {
+	for _, layout := range []string{"15:04:05Z07:00", "15:04:05.999999999Z07:00", "15:04:05", "15:04:05.999999999"} {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeDate converts other spellings of a date to YYYY-MM-DD
+func normalizeDate(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format("2006-01-02"), true
+		}
+	}
+	return "", false
+}
+
+// normalizeDateTime converts other spellings of a date and time to RFC 3339
+func normalizeDateTime(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format(time.RFC3339Nano), true
+		}
+	}
+	return "", false
+}
+
+// normalizeTime converts times without seconds to HH:MM:SS
+func normalizeTime(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format("15:04:05.999999999"), true
+		}
+	}
+	return "", false
+}
+
+// isEmail reports whether s is a bare email address
+func isEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s && addr.Name == ""
+}
+
+// isURI reports whether s is an absolute URI
+func isURI(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != "" && !strings.ContainsAny(s, " \t\n")
+}
+
+// isIPv4 reports whether s is a dotted IPv4 address
+func isIPv4(s string) bool {
+	ip := net.ParseIP(s)
+	return ip != nil && ip.To4() != nil && !strings.Contains(s, ":")
+}
+
+// isIPv6 reports whether s is an IPv6 address
+func isIPv6(s string) bool {
+	return net.ParseIP(s) != nil && strings.Contains(s, ":")
+}
+
+// isHostname reports whether s is a host name
+func isHostname(s string) bool {
+	return len(s) <= 253 && hostnamePattern.MatchString(s)
+}
+
+// isRegex reports whether s is a supported regular expression
+func isRegex(s string) bool {
+	_, err := regexp.Compile(s)
+	return err == nil
+}
+
+// isPhone reports whether s looks like a phone number of 6 to 15 digits
+func isPhone(s string) bool {
+	if !phonePattern.MatchString(s) {
+		return false
+	}
+	digits := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	return digits >= 6 && digits <= 15
+}
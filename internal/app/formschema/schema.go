@@ -0,0 +1,634 @@
+// Package formschema compiles the schemas of forms and data collections and
+// validates submitted data against them. A schema is either a JSON Schema draft
+// 2020-12 document or a form designer document listing fields, which is
+// translated to JSON Schema.
+package formschema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema is a compiled schema that can validate data
+type Schema struct {
+	root *node
+}
+
+// node is a compiled schema object or boolean schema
+type node struct {
+	always *bool // set for the boolean schemas true and false
+
+	ref   *node
+	title string
+
+	types      []string
+	enum       []interface{}
+	hasConst   bool
+	constValue interface{}
+	format     string
+
+	multipleOf       *big.Rat
+	maximum          *big.Rat
+	exclusiveMaximum *big.Rat
+	minimum          *big.Rat
+	exclusiveMinimum *big.Rat
+
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+
+	prefixItems []*node
+	items       *node
+	contains    *node
+	minContains *int
+	maxContains *int
+	minItems    *int
+	maxItems    *int
+	uniqueItems bool
+
+	properties           map[string]*node
+	propertyOrder        []string // names of the properties in document order
+	patternProperties    []*patternNode
+	additionalProperties *node
+	propertyNames        *node
+	required             []string
+	dependentRequired    map[string][]string
+	dependentSchemas     map[string]*node
+	minProperties        *int
+	maxProperties        *int
+
+	allOf    []*node
+	anyOf    []*node
+	oneOf    []*node
+	not      *node
+	ifNode   *node
+	thenNode *node
+	elseNode *node
+}
+
+// patternNode is a schema applied to the properties whose names match a pattern
+type patternNode struct {
+	pattern *regexp.Regexp
+	schema  *node
+}
+
+// ErrInvalidSchema is wrapped by the errors of schemas that can not be compiled
+var ErrInvalidSchema = errors.New("invalid schema")
+
+// unsupportedKeywords are 2020-12 keywords the engine can not evaluate. They
+// are rejected instead of being ignored, which would accept invalid data.
+var unsupportedKeywords = []string{"unevaluatedProperties", "unevaluatedItems", "$dynamicRef", "$recursiveRef"}
+
+// validTypes are the values of the type keyword
+var validTypes = map[string]bool{
+	"null": true, "boolean": true, "object": true, "array": true, "number": true, "string": true, "integer": true,
+}
+
+// Compile parses and compiles a schema. Errors wrap ErrInvalidSchema and name
+// the location of the problem as a JSON pointer into the schema.
+func Compile(schema string) (*Schema, error) {
+	doc, err := decodeJSON(schema)
+	if err != nil {
+		return nil, fmt.Errorf("%w: schema must be a JSON document", ErrInvalidSchema)
+	}
+	var order map[string][]string
+	if isDesignerDocument(doc) {
+		if doc, order, err = designerSchema(schema); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+		}
+	} else {
+		order = keyOrder(schema)
+	}
+
+	c := &compiler{doc: doc, order: order, nodes: make(map[string]*node), anchors: make(map[string]string)}
+	root, err := c.compile(doc, "")
+	if err == nil {
+		err = c.resolveRefs()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchema, err)
+	}
+	return &Schema{root: root}, nil
+}
+
+// decodeJSON decodes a single JSON document, keeping numbers exact
+func decodeJSON(data string) (interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after JSON document")
+	}
+	return v, nil
+}
+
+// keyOrder records the order of the keys of every object of a JSON document by
+// JSON pointer, which decoding to maps loses
+func keyOrder(data string) map[string][]string {
+	order := make(map[string][]string)
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	var walk func(ptr string) error
+	walk = func(ptr string) error {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'):
+			for decoder.More() {
+				key, err := decoder.Token()
+				if err != nil {
+					return err
+				}
+				name, _ := key.(string)
+				order[ptr] = append(order[ptr], name)
+				if err := walk(ptr + "/" + escape(name)); err != nil {
+					return err
+				}
+			}
+		case json.Delim('['):
+			for i := 0; decoder.More(); i++ {
+				if err := walk(ptr + "/" + strconv.Itoa(i)); err != nil {
+					return err
+				}
+			}
+		default:
+			return nil
+		}
+		_, err = decoder.Token()
+		return err
+	}
+	walk("")
+	return order
+}
+
+// compiler compiles the subschemas of a document, once per location
+type compiler struct {
+	doc     interface{}
+	order   map[string][]string // key order of the objects of the document by JSON pointer
+	nodes   map[string]*node    // compiled subschemas by JSON pointer
+	anchors map[string]string   // JSON pointers by $anchor
+	refs    []*pendingRef
+}
+
+// pendingRef is a $ref resolved after the whole document was compiled
+type pendingRef struct {
+	node *node
+	ref  string
+	ptr  string
+}
+
+// compile compiles the subschema v found at the JSON pointer ptr
+func (c *compiler) compile(v interface{}, ptr string) (*node, error) {
+	if n, ok := c.nodes[ptr]; ok {
+		return n, nil
+	}
+	n := &node{}
+	c.nodes[ptr] = n
+
+	switch schema := v.(type) {
+	case bool:
+		n.always = &schema
+		return n, nil
+	case map[string]interface{}:
+		return n, c.compileObject(n, schema, ptr)
+	default:
+		return nil, fmt.Errorf("%s: schema must be an object or a boolean", location(ptr))
+	}
+}
+
+// compileObject compiles the keywords of a schema object
+func (c *compiler) compileObject(n *node, obj map[string]interface{}, ptr string) error {
+	for _, keyword := range unsupportedKeywords {
+		if _, ok := obj[keyword]; ok {
+			return fmt.Errorf("%s: keyword %s is not supported", location(ptr), keyword)
+		}
+	}
+	if s, ok := obj["$schema"].(string); ok && ptr == "" && !strings.Contains(s, "2020-12") {
+		return fmt.Errorf("%s: only JSON Schema draft 2020-12 is supported", location(ptr+"/$schema"))
+	}
+	if anchor, ok := obj["$anchor"].(string); ok {
+		c.anchors[anchor] = ptr
+	}
+	if ref, ok := obj["$ref"]; ok {
+		s, ok := ref.(string)
+		if !ok {
+			return fmt.Errorf("%s: must be a string", location(ptr+"/$ref"))
+		}
+		c.refs = append(c.refs, &pendingRef{node: n, ref: s, ptr: ptr + "/$ref"})
+	}
+
+	var err error
+	if n.types, err = compileTypes(obj, ptr); err != nil {
+		return err
+	}
+	if enum, ok := obj["enum"]; ok {
+		values, ok := enum.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: must be an array", location(ptr+"/enum"))
+		}
+		n.enum = values
+	}
+	n.constValue, n.hasConst = obj["const"]
+	if title, ok := obj["title"].(string); ok {
+		n.title = title
+	}
+	if format, ok := obj["format"].(string); ok {
+		n.format = format
+	}
+
+	for _, k := range []struct {
+		keyword string
+		target  **big.Rat
+	}{
+		{"multipleOf", &n.multipleOf}, {"maximum", &n.maximum}, {"exclusiveMaximum", &n.exclusiveMaximum},
+		{"minimum", &n.minimum}, {"exclusiveMinimum", &n.exclusiveMinimum},
+	} {
+		if *k.target, err = numberKeyword(obj, k.keyword, ptr); err != nil {
+			return err
+		}
+	}
+	if n.multipleOf != nil && n.multipleOf.Sign() <= 0 {
+		return fmt.Errorf("%s: must be greater than 0", location(ptr+"/multipleOf"))
+	}
+
+	for _, k := range []struct {
+		keyword string
+		target  **int
+	}{
+		{"minLength", &n.minLength}, {"maxLength", &n.maxLength}, {"minItems", &n.minItems}, {"maxItems", &n.maxItems},
+		{"minContains", &n.minContains}, {"maxContains", &n.maxContains},
+		{"minProperties", &n.minProperties}, {"maxProperties", &n.maxProperties},
+	} {
+		if *k.target, err = countKeyword(obj, k.keyword, ptr); err != nil {
+			return err
+		}
+	}
+
+	if pattern, ok := obj["pattern"]; ok {
+		if n.pattern, err = compilePattern(pattern, ptr+"/pattern"); err != nil {
+			return err
+		}
+	}
+	if unique, ok := obj["uniqueItems"].(bool); ok {
+		n.uniqueItems = unique
+	}
+
+	if n.required, err = stringArray(obj["required"], ptr+"/required"); err != nil {
+		return err
+	}
+	if deps, ok := obj["dependentRequired"]; ok {
+		m, ok := deps.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: must be an object", location(ptr+"/dependentRequired"))
+		}
+		n.dependentRequired = make(map[string][]string, len(m))
+		for _, name := range sortedKeys(m) {
+			if n.dependentRequired[name], err = stringArray(m[name], ptr+"/dependentRequired/"+escape(name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Subschemas
+	if _, ok := obj["items"].([]interface{}); ok {
+		return fmt.Errorf("%s: must be a schema, use prefixItems for tuples", location(ptr+"/items"))
+	}
+	for _, k := range []struct {
+		keyword string
+		target  **node
+	}{
+		{"items", &n.items}, {"contains", &n.contains}, {"additionalProperties", &n.additionalProperties},
+		{"propertyNames", &n.propertyNames}, {"not", &n.not}, {"if", &n.ifNode}, {"then", &n.thenNode}, {"else", &n.elseNode},
+	} {
+		if *k.target, err = c.subschema(obj, k.keyword, ptr); err != nil {
+			return err
+		}
+	}
+	for _, k := range []struct {
+		keyword string
+		target  *[]*node
+	}{
+		{"prefixItems", &n.prefixItems}, {"allOf", &n.allOf}, {"anyOf", &n.anyOf}, {"oneOf", &n.oneOf},
+	} {
+		if *k.target, err = c.subschemaArray(obj, k.keyword, ptr); err != nil {
+			return err
+		}
+	}
+	if n.properties, err = c.subschemaMap(obj, "properties", ptr); err != nil {
+		return err
+	}
+	n.propertyOrder = c.keysInOrder(ptr+"/properties", n.properties)
+	if n.dependentSchemas, err = c.subschemaMap(obj, "dependentSchemas", ptr); err != nil {
+		return err
+	}
+	patterns, err := c.subschemaMap(obj, "patternProperties", ptr)
+	if err != nil {
+		return err
+	}
+	for _, pattern := range sortedKeys(patterns) {
+		re, err := compilePattern(pattern, ptr+"/patternProperties/"+escape(pattern))
+		if err != nil {
+			return err
+		}
+		n.patternProperties = append(n.patternProperties, &patternNode{pattern: re, schema: patterns[pattern]})
+	}
+
+	return nil
+}
+
+// resolveRefs links each $ref to the subschema it points to. Only references
+// within the document are supported, by JSON pointer or $anchor.
+func (c *compiler) resolveRefs() error {
+	for len(c.refs) > 0 {
+		ref := c.refs[0]
+		c.refs = c.refs[1:]
+
+		if !strings.HasPrefix(ref.ref, "#") {
+			return fmt.Errorf("%s: only references within the schema are supported", location(ref.ptr))
+		}
+		fragment, err := url.PathUnescape(ref.ref[1:])
+		if err != nil {
+			return fmt.Errorf("%s: invalid reference %s", location(ref.ptr), ref.ref)
+		}
+		ptr, ok := c.anchors[fragment]
+		if !ok {
+			tokens, err := parsePointer(fragment)
+			if err != nil {
+				return fmt.Errorf("%s: %v", location(ref.ptr), err)
+			}
+			ptr = ""
+			for _, token := range tokens {
+				ptr += "/" + escape(token)
+			}
+		}
+
+		target, ok := lookup(c.doc, ptr)
+		if !ok {
+			return fmt.Errorf("%s: reference %s not found", location(ref.ptr), ref.ref)
+		}
+		n, err := c.compile(target, ptr)
+		if err != nil {
+			return err
+		}
+		ref.node.ref = n
+	}
+	return nil
+}
+
+// subschema compiles the schema of a keyword, if present
+func (c *compiler) subschema(obj map[string]interface{}, keyword, ptr string) (*node, error) {
+	v, ok := obj[keyword]
+	if !ok {
+		return nil, nil
+	}
+	return c.compile(v, ptr+"/"+keyword)
+}
+
+// subschemaArray compiles the array of schemas of a keyword, if present
+func (c *compiler) subschemaArray(obj map[string]interface{}, keyword, ptr string) ([]*node, error) {
+	v, ok := obj[keyword]
+	if !ok {
+		return nil, nil
+	}
+	values, ok := v.([]interface{})
+	if !ok || len(values) == 0 {
+		return nil, fmt.Errorf("%s: must be a non-empty array", location(ptr+"/"+keyword))
+	}
+	nodes := make([]*node, len(values))
+	for i, value := range values {
+		n, err := c.compile(value, ptr+"/"+keyword+"/"+strconv.Itoa(i))
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = n
+	}
+	return nodes, nil
+}
+
+// subschemaMap compiles the object of schemas of a keyword, if present
+func (c *compiler) subschemaMap(obj map[string]interface{}, keyword, ptr string) (map[string]*node, error) {
+	v, ok := obj[keyword]
+	if !ok {
+		return nil, nil
+	}
+	values, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: must be an object", location(ptr+"/"+keyword))
+	}
+	nodes := make(map[string]*node, len(values))
+	for _, name := range sortedKeys(values) {
+		n, err := c.compile(values[name], ptr+"/"+keyword+"/"+escape(name))
+		if err != nil {
+			return nil, err
+		}
+		nodes[name] = n
+	}
+	return nodes, nil
+}
+
+// keysInOrder returns the names of a map of subschemas in document order
+func (c *compiler) keysInOrder(ptr string, nodes map[string]*node) []string {
+	names := make([]string, 0, len(nodes))
+	seen := make(map[string]bool, len(nodes))
+	for _, name := range c.order[ptr] {
+		if _, ok := nodes[name]; ok && !seen[name] {
+			names = append(names, name)
+			seen[name] = true
+		}
+	}
+	for _, name := range sortedKeys(nodes) {
+		if !seen[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// compileTypes reads the type keyword, a type name or an array of them
+func compileTypes(obj map[string]interface{}, ptr string) ([]string, error) {
+	v, ok := obj["type"]
+	if !ok {
+		return nil, nil
+	}
+	var types []string
+	switch t := v.(type) {
+	case string:
+		types = []string{t}
+	case []interface{}:
+		for _, item := range t {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: must be a string or an array of strings", location(ptr+"/type"))
+			}
+			types = append(types, name)
+		}
+	default:
+		return nil, fmt.Errorf("%s: must be a string or an array of strings", location(ptr+"/type"))
+	}
+	for _, name := range types {
+		if !validTypes[name] {
+			return nil, fmt.Errorf("%s: unknown type %q", location(ptr+"/type"), name)
+		}
+	}
+	return types, nil
+}
+
+// numberKeyword reads a keyword whose value is a number
+func numberKeyword(obj map[string]interface{}, keyword, ptr string) (*big.Rat, error) {
+	v, ok := obj[keyword]
+	if !ok {
+		return nil, nil
+	}
+	number, ok := v.(json.Number)
+	if !ok {
+		return nil, fmt.Errorf("%s: must be a number", location(ptr+"/"+keyword))
+	}
+	r, ok := parseNumber(number)
+	if !ok {
+		return nil, fmt.Errorf("%s: number is out of range", location(ptr+"/"+keyword))
+	}
+	return r, nil
+}
+
+// countKeyword reads a keyword whose value is a non-negative integer
+func countKeyword(obj map[string]interface{}, keyword, ptr string) (*int, error) {
+	v, ok := obj[keyword]
+	if !ok {
+		return nil, nil
+	}
+	number, ok := v.(json.Number)
+	if !ok {
+		return nil, fmt.Errorf("%s: must be a non-negative integer", location(ptr+"/"+keyword))
+	}
+	// Integers may be written as 2.0
+	r, ok := parseNumber(number)
+	if !ok || !r.IsInt() || r.Sign() < 0 || !r.Num().IsInt64() || r.Num().Int64() > 1<<31 {
+		return nil, fmt.Errorf("%s: must be a non-negative integer", location(ptr+"/"+keyword))
+	}
+	count := int(r.Num().Int64())
+	return &count, nil
+}
+
+// compilePattern compiles a regular expression. Patterns are compiled with Go's
+// RE2 syntax, which covers the commonly used part of ECMA-262 expressions.
+func compilePattern(v interface{}, ptr string) (*regexp.Regexp, error) {
+	pattern, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s: must be a string", location(ptr))
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s: unsupported regular expression %q", location(ptr), pattern)
+	}
+	return re, nil
+}
+
+// stringArray reads an array of strings
+func stringArray(v interface{}, ptr string) ([]string, error) {
+	if v == nil {
+		return nil, nil
+	}
+	values, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: must be an array of strings", location(ptr))
+	}
+	strs := make([]string, len(values))
+	for i, value := range values {
+		if strs[i], ok = value.(string); !ok {
+			return nil, fmt.Errorf("%s: must be an array of strings", location(ptr))
+		}
+	}
+	return strs, nil
+}
+
+// parseNumber converts a JSON number to an exact rational. Numbers with huge
+// exponents are rejected rather than expanded.
+func parseNumber(n json.Number) (*big.Rat, bool) {
+	s := string(n)
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		exp, err := strconv.Atoi(s[i+1:])
+		if err != nil || exp > 400 || exp < -400 {
+			return nil, false
+		}
+	}
+	return new(big.Rat).SetString(s)
+}
+
+// lookup finds the value at a JSON pointer in a document
+func lookup(doc interface{}, ptr string) (interface{}, bool) {
+	tokens, err := parsePointer(ptr)
+	if err != nil {
+		return nil, false
+	}
+	v := doc
+	for _, token := range tokens {
+		switch container := v.(type) {
+		case map[string]interface{}:
+			next, ok := container[token]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(container) {
+				return nil, false
+			}
+			v = container[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// parsePointer splits a JSON pointer into its unescaped reference tokens
+func parsePointer(ptr string) ([]string, error) {
+	if ptr == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(ptr, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", ptr)
+	}
+	tokens := strings.Split(ptr[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+// escape escapes a reference token of a JSON pointer
+func escape(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// location describes a JSON pointer in messages
+func location(ptr string) string {
+	if ptr == "" {
+		return "schema"
+	}
+	return ptr
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,286 @@
+package formschema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// validationErrors validates data and returns the field errors
+func validationErrors(t *testing.T, schema *Schema, data string) []*FieldError {
+	_, err := schema.Validate(data)
+	if err == nil {
+		return nil
+	}
+	validationErr, ok := err.(*ValidationError)
+	if !assert.True(t, ok, "unexpected error %v", err) {
+		return nil
+	}
+	return validationErr.Errors
+}
+
+// TestCompile tests compiling schemas
+func TestCompile(t *testing.T) {
+	// Test valid schemas
+	for _, schema := range []string{
+		`{}`,
+		`true`,
+		`{"$schema": "https://json-schema.org/draft/2020-12/schema", "type": "object"}`,
+		`{"type": ["string", "null"], "maxLength": 2.0}`,
+		`{"$defs": {"node": {"type": "object", "properties": {"children": {"type": "array", "items": {"$ref": "#/$defs/node"}}}}}, "$ref": "#/$defs/node"}`,
+		`{"fields": [{"name": "name", "type": "text"}]}`,
+	} {
+		_, err := Compile(schema)
+		assert.NoError(t, err, schema)
+	}
+
+	// Test invalid schemas report the location of the problem
+	tests := map[string]string{
+		`not json`:          "invalid schema: schema must be a JSON document",
+		`{} {}`:             "invalid schema: schema must be a JSON document",
+		`[]`:                "invalid schema: schema: schema must be an object or a boolean",
+		`{"type": "numbr"}`: `invalid schema: /type: unknown type "numbr"`,
+		`{"properties": {"age": {"minimum": "1"}}}`:                                  "invalid schema: /properties/age/minimum: must be a number",
+		`{"minLength": -1}`:                                                          "invalid schema: /minLength: must be a non-negative integer",
+		`{"pattern": "(?<=a)b"}`:                                                     `invalid schema: /pattern: unsupported regular expression "(?<=a)b"`,
+		`{"$ref": "#/$defs/missing"}`:                                                "invalid schema: /$ref: reference #/$defs/missing not found",
+		`{"$ref": "https://example.com/schema"}`:                                     "invalid schema: /$ref: only references within the schema are supported",
+		`{"unevaluatedProperties": false}`:                                           "invalid schema: schema: keyword unevaluatedProperties is not supported",
+		`{"items": [{"type": "string"}]}`:                                            "invalid schema: /items: must be a schema, use prefixItems for tuples",
+		`{"$schema": "http://json-schema.org/draft-07/schema#"}`:                     "invalid schema: /$schema: only JSON Schema draft 2020-12 is supported",
+		`{"fields": [{"name": "a", "type": "text"}, {"name": "a", "type": "text"}]}`: `invalid schema: /fields/1/name: duplicate field "a"`,
+		`{"fields": [{"name": "a", "type": "colour"}]}`:                              `invalid schema: /fields/0/type: unknown field type "colour"`,
+	}
+	for schema, expected := range tests {
+		_, err := Compile(schema)
+		if assert.Error(t, err, schema) {
+			assert.Equal(t, expected, err.Error())
+			assert.ErrorIs(t, err, ErrInvalidSchema)
+		}
+	}
+}
+
+// TestValidate tests validating data against JSON Schema keywords
+func TestValidate(t *testing.T) {
+	schema, err := Compile(`{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string", "minLength": 2, "maxLength": 10},
+			"age": {"type": "integer", "minimum": 0, "exclusiveMaximum": 150},
+			"price": {"type": "number", "multipleOf": 0.01},
+			"email": {"type": "string", "format": "email"},
+			"tags": {"type": "array", "items": {"type": "string"}, "uniqueItems": true, "maxItems": 3},
+			"status": {"enum": ["draft", "done"]},
+			"address": {
+				"type": "object",
+				"properties": {"city": {"type": "string"}},
+				"required": ["city"],
+				"additionalProperties": false
+			}
+		},
+		"required": ["name", "email"],
+		"dependentRequired": {"price": ["status"]}
+	}`)
+	assert.NoError(t, err)
+
+	// Test valid data is returned unchanged
+	t.Run("Valid", func(t *testing.T) {
+		data := `{"name": "Jo", "age": 30, "price": 9.99, "email": "jo@example.com", "tags": ["a"], "status": "done", "address": {"city": "Paris"}, "extra": true}`
+		result, err := schema.Validate(data)
+		assert.NoError(t, err)
+		assert.Equal(t, data, result)
+	})
+
+	// Test every invalid value is reported with its path
+	t.Run("Invalid", func(t *testing.T) {
+		errs := validationErrors(t, schema, `{
+			"name": "J",
+			"age": 150,
+			"price": 1.005,
+			"tags": ["a", "a", "b", "c"],
+			"address": {"zip": "75001"}
+		}`)
+		assert.Equal(t, []*FieldError{
+			{Path: "/email", Message: "is required"},
+			{Path: "/status", Message: "is required when price is present"},
+			{Path: "/address/city", Message: "is required"},
+			{Path: "/address/zip", Message: "is not allowed"},
+			{Path: "/age", Message: "must be less than 150"},
+			{Path: "/name", Message: "must be at least 2 characters"},
+			{Path: "/price", Message: "must be a multiple of 0.01"},
+			{Path: "/tags", Message: "must have at most 3 items"},
+			{Path: "/tags", Message: "must not contain duplicate items"},
+		}, errs)
+	})
+
+	// Test the error message names the first error
+	t.Run("ErrorMessage", func(t *testing.T) {
+		_, err := schema.Validate(`{"name": "Jo"}`)
+		assert.EqualError(t, err, "invalid data: /email: is required")
+		_, err = schema.Validate(`[]`)
+		assert.EqualError(t, err, "invalid data: must be an object")
+		_, err = schema.Validate(`{"name": `)
+		assert.EqualError(t, err, "invalid data: must be a JSON document")
+	})
+}
+
+// TestValidateApplicators tests combining schemas
+func TestValidateApplicators(t *testing.T) {
+	schema, err := Compile(`{
+		"$defs": {
+			"positive": {"type": "number", "exclusiveMinimum": 0},
+			"node": {"type": "object", "properties": {"value": {"$ref": "#/$defs/positive"}, "children": {"type": "array", "items": {"$ref": "#/$defs/node"}}}}
+		},
+		"type": "object",
+		"properties": {
+			"tree": {"$ref": "#/$defs/node"},
+			"contact": {"oneOf": [{"type": "string", "format": "email"}, {"type": "string", "format": "phone"}]},
+			"id": {"anyOf": [{"type": "integer"}, {"type": "string", "format": "uuid"}]},
+			"kind": {"type": "string"},
+			"list": {"type": "array", "contains": {"const": 1}, "maxContains": 1}
+		},
+		"if": {"properties": {"kind": {"const": "company"}}, "required": ["kind"]},
+		"then": {"required": ["vat"]},
+		"not": {"required": ["forbidden"]}
+	}`)
+	assert.NoError(t, err)
+
+	errs := validationErrors(t, schema, `{"tree": {"value": 1, "children": [{"value": 2}, {"value": -1}]}, "contact": "jo@example.com", "id": 7, "list": [0, 1]}`)
+	assert.Equal(t, []*FieldError{{Path: "/tree/children/1/value", Message: "must be greater than 0"}}, errs)
+
+	errs = validationErrors(t, schema, `{"contact": "call me", "id": "abc", "kind": "company", "list": [1, 1], "forbidden": true}`)
+	assert.Equal(t, []*FieldError{
+		{Path: "/contact", Message: "must match exactly one of the allowed schemas"},
+		{Path: "/id", Message: "must match at least one of the allowed schemas"},
+		{Path: "/list", Message: "must contain at most 1 matching items"},
+		{Path: "", Message: "must not match the disallowed schema"},
+		{Path: "/vat", Message: "is required"},
+	}, errs)
+}
+
+// TestValidateCoercion tests values are converted to the declared types
+func TestValidateCoercion(t *testing.T) {
+	schema, err := Compile(`{
+		"type": "object",
+		"properties": {
+			"age": {"type": "integer"},
+			"weight": {"type": ["number", "null"]},
+			"active": {"type": "boolean"},
+			"code": {"type": "string"},
+			"born": {"type": "string", "format": "date"},
+			"start": {"type": "string", "format": "date-time"},
+			"opens": {"type": "string", "format": "time"},
+			"email": {"type": "string", "format": "email"},
+			"rooms": {"type": "array", "items": {"type": "integer"}}
+		},
+		"required": ["age"]
+	}`)
+	assert.NoError(t, err)
+
+	result, err := schema.Validate(`{"age": " 30 ", "weight": "72.5", "active": "true", "code": 42, "born": "2024/5/1",
+		"start": "2024-05-01 09:30", "opens": "08:00", "email": "", "rooms": ["1", 2.0]}`)
+	assert.NoError(t, err)
+	var values map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(result), &values))
+	assert.Equal(t, map[string]interface{}{
+		"age":    float64(30),
+		"weight": 72.5,
+		"active": true,
+		"code":   "42",
+		"born":   "2024-05-01",
+		"start":  "2024-05-01T09:30:00Z",
+		"opens":  "08:00:00",
+		"rooms":  []interface{}{float64(1), float64(2)},
+	}, values)
+
+	// Test values that can not be converted are reported
+	errs := validationErrors(t, schema, `{"age": "thirty", "active": "maybe", "born": "May 1st", "weight": "1e1000"}`)
+	assert.Equal(t, []*FieldError{
+		{Path: "/active", Message: "must be a boolean"},
+		{Path: "/age", Message: "must be an integer"},
+		{Path: "/born", Message: "must be a date (YYYY-MM-DD)"},
+		{Path: "/weight", Message: "is out of range"},
+	}, errs)
+
+	// Test a blank required field is reported as missing
+	errs = validationErrors(t, schema, `{"age": ""}`)
+	assert.Equal(t, []*FieldError{{Path: "/age", Message: "is required"}}, errs)
+}
+
+// TestDesignerSchema tests validating data against form designer documents
+func TestDesignerSchema(t *testing.T) {
+	schema, err := Compile(`{"fields": [
+		{"name": "name", "type": "text", "label": "Name", "required": true, "maxLength": 20},
+		{"name": "email", "type": "email", "required": true},
+		{"name": "phone", "type": "phone"},
+		{"name": "age", "type": "integer", "min": 18, "max": 99},
+		{"name": "visit", "type": "date"},
+		{"name": "size", "type": "select", "options": [{"label": "Small", "value": "s"}, {"label": "Large", "value": "l"}]},
+		{"name": "toppings", "type": "checkbox", "options": ["cheese", "ham"], "required": true},
+		{"name": "agree", "type": "switch"},
+		{"name": "address", "type": "group", "fields": [{"name": "city", "type": "text", "required": true}]},
+		{"name": "guests", "type": "table", "fields": [{"name": "name", "type": "text", "required": true}]}
+	]}`)
+	assert.NoError(t, err)
+
+	// Test a valid submission with blank optional fields and values to coerce
+	result, err := schema.Validate(`{"name": "Jo", "email": "jo@example.com", "phone": "", "age": "30", "visit": "2024-05-01",
+		"size": "", "toppings": ["ham"], "agree": "1", "address": {"city": "Paris"}, "guests": [{"name": "Al"}]}`)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"name": "Jo", "email": "jo@example.com", "age": 30, "visit": "2024-05-01",
+		"toppings": ["ham"], "agree": true, "address": {"city": "Paris"}, "guests": [{"name": "Al"}]}`, result)
+
+	// Test invalid fields
+	errs := validationErrors(t, schema, `{"name": "", "email": "jo", "phone": "12", "age": 17, "size": "m",
+		"toppings": [], "address": {}, "guests": [{"name": ""}]}`)
+	assert.Equal(t, []*FieldError{
+		{Path: "/name", Message: "is required"},
+		{Path: "/address/city", Message: "is required"},
+		{Path: "/age", Message: "must be at least 18"},
+		{Path: "/email", Message: "must be an email address"},
+		{Path: "/guests/0/name", Message: "is required"},
+		{Path: "/phone", Message: "must be a phone number"},
+		{Path: "/size", Message: `must be one of "s", "l"`},
+		{Path: "/toppings", Message: "must have at least 1 items"},
+	}, errs)
+}
+
+// TestFields tests listing the fields of schemas in document order
+func TestFields(t *testing.T) {
+	schema, err := Compile(`{
+		"type": "object",
+		"$defs": {"address": {"type": "object", "properties": {"street": {"type": "string"}, "city": {"type": "string", "title": "City"}}}},
+		"properties": {
+			"name": {"type": "string", "title": "Full name"},
+			"born": {"type": "string", "format": "date"},
+			"address": {"$ref": "#/$defs/address", "title": "Address"},
+			"tags": {"type": "array", "items": {"type": "string"}},
+			"score": {"type": ["number", "null"]}
+		}
+	}`)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Field{
+		{Path: []string{"name"}, Title: "Full name", Type: "string"},
+		{Path: []string{"born"}, Title: "born", Type: "string", Format: "date"},
+		{Path: []string{"address", "street"}, Title: "Address / street", Type: "string"},
+		{Path: []string{"address", "city"}, Title: "Address / City", Type: "string"},
+		{Path: []string{"tags"}, Title: "tags", Type: "array"},
+		{Path: []string{"score"}, Title: "score"},
+	}, schema.Fields())
+
+	// Test designer fields keep the order of the designer
+	schema, err = Compile(`{"fields": [
+		{"name": "zip", "type": "text", "label": "Zip code"},
+		{"name": "age", "type": "integer"},
+		{"name": "contact", "type": "group", "label": "Contact", "fields": [{"name": "phone", "type": "phone", "label": "Phone"}]},
+		{"name": "guests", "type": "table", "fields": [{"name": "name", "type": "text"}]}
+	]}`)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Field{
+		{Path: []string{"zip"}, Title: "Zip code", Type: "string"},
+		{Path: []string{"age"}, Title: "age", Type: "integer"},
+		{Path: []string{"contact", "phone"}, Title: "Contact / Phone", Type: "string", Format: "phone"},
+		{Path: []string{"guests"}, Title: "guests", Type: "array"},
+	}, schema.Fields())
+}
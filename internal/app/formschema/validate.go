@@ -0,0 +1,549 @@
+package formschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits of a validation
+const (
+	maxDepth  = 64  // nesting of schemas applied to one value, which bounds recursive references
+	maxErrors = 100 // errors reported for one document
+)
+
+// FieldError describes why a value of the submitted data is invalid
+type FieldError struct {
+	Path    string `json:"path"` // JSON pointer to the value, empty for the whole document
+	Message string `json:"message"`
+}
+
+// ValidationError is returned for data that does not match a schema
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+// Error describes the first error
+func (e *ValidationError) Error() string {
+	first := e.Errors[0]
+	msg := "invalid data: " + first.Message
+	if first.Path != "" {
+		msg = "invalid data: " + first.Path + ": " + first.Message
+	}
+	switch len(e.Errors) {
+	case 1:
+	case 2:
+		msg += " (and 1 more error)"
+	default:
+		msg += fmt.Sprintf(" (and %d more errors)", len(e.Errors)-1)
+	}
+	return msg
+}
+
+// Validate checks a JSON document against the schema and returns the document
+// to store. Values are coerced to the type the schema declares for them:
+// numeric and boolean strings become numbers and booleans, numbers and booleans
+// become strings, and dates and times are normalized to their RFC 3339 form.
+// An empty string that a property's schema rejects counts as a missing
+// property, so blank optional form fields are accepted and dropped. The document is returned unchanged if nothing was coerced.
+func (s *Schema) Validate(data string) (string, error) {
+	doc, err := decodeJSON(data)
+	if err != nil {
+		return "", &ValidationError{Errors: []*FieldError{{Message: "must be a JSON document"}}}
+	}
+
+	v := &validator{}
+	doc = v.validate(s.root, doc, "", 0)
+	if len(v.errors) > 0 {
+		return "", &ValidationError{Errors: v.errors}
+	}
+	if !v.coerced {
+		return data, nil
+	}
+	normalized, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	return string(normalized), nil
+}
+
+// validator collects the errors of one validation. A dry run only checks
+// whether a value matches, for the subschemas of anyOf, oneOf, not, if and
+// contains, and works on copies so its coercions are not kept.
+type validator struct {
+	errors  []*FieldError
+	coerced bool
+	dryRun  bool
+}
+
+// fail records an error
+func (v *validator) fail(path, format string, args ...interface{}) {
+	if len(v.errors) < maxErrors {
+		v.errors = append(v.errors, &FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+// matches reports whether a value is valid against a subschema
+func (v *validator) matches(n *node, value interface{}, path string, depth int) bool {
+	sub := &validator{dryRun: true}
+	sub.validate(n, value, path, depth)
+	return len(sub.errors) == 0
+}
+
+// validate checks a value against a schema and returns the value, coerced
+func (v *validator) validate(n *node, value interface{}, path string, depth int) interface{} {
+	if depth > maxDepth {
+		v.fail(path, "is nested too deeply")
+		return value
+	}
+	if n.always != nil {
+		if !*n.always {
+			v.fail(path, "is not allowed")
+		}
+		return value
+	}
+	if n.ref != nil {
+		value = v.validate(n.ref, value, path, depth+1)
+	}
+
+	value = v.coerce(n, value)
+	if len(n.types) > 0 && !hasType(value, n.types) {
+		v.fail(path, "must be %s", describeTypes(n.types))
+		return value
+	}
+	if n.hasConst && !equal(value, n.constValue) {
+		v.fail(path, "must be %s", formatValue(n.constValue))
+	}
+	if n.enum != nil && !contains(n.enum, value) {
+		v.fail(path, "must be one of %s", formatValues(n.enum))
+	}
+
+	switch val := value.(type) {
+	case string:
+		v.validateString(n, val, path)
+	case json.Number:
+		v.validateNumber(n, val, path)
+	case []interface{}:
+		value = v.validateArray(n, val, path, depth)
+	case map[string]interface{}:
+		value = v.validateObject(n, val, path, depth)
+	}
+
+	for _, sub := range n.allOf {
+		value = v.validate(sub, value, path, depth+1)
+	}
+	if n.anyOf != nil {
+		matched := false
+		for _, sub := range n.anyOf {
+			if v.matches(sub, value, path, depth+1) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(path, "must match at least one of the allowed schemas")
+		}
+	}
+	if n.oneOf != nil {
+		count := 0
+		for _, sub := range n.oneOf {
+			if v.matches(sub, value, path, depth+1) {
+				count++
+			}
+		}
+		if count != 1 {
+			v.fail(path, "must match exactly one of the allowed schemas")
+		}
+	}
+	if n.not != nil && v.matches(n.not, value, path, depth+1) {
+		v.fail(path, "must not match the disallowed schema")
+	}
+	if n.ifNode != nil {
+		if v.matches(n.ifNode, value, path, depth+1) {
+			if n.thenNode != nil {
+				value = v.validate(n.thenNode, value, path, depth+1)
+			}
+		} else if n.elseNode != nil {
+			value = v.validate(n.elseNode, value, path, depth+1)
+		}
+	}
+
+	return value
+}
+
+// validateString checks the string keywords
+func (v *validator) validateString(n *node, s, path string) {
+	length := utf8.RuneCountInString(s)
+	if n.minLength != nil && length < *n.minLength {
+		if *n.minLength == 1 {
+			v.fail(path, "must not be empty")
+		} else {
+			v.fail(path, "must be at least %d characters", *n.minLength)
+		}
+	}
+	if n.maxLength != nil && length > *n.maxLength {
+		v.fail(path, "must be at most %d characters", *n.maxLength)
+	}
+	if n.pattern != nil && !n.pattern.MatchString(s) {
+		v.fail(path, "must match the pattern %s", n.pattern.String())
+	}
+	if n.format != "" {
+		if f, ok := formats[n.format]; ok && !f.valid(s) {
+			v.fail(path, "must be %s", f.description)
+		}
+	}
+}
+
+// validateNumber checks the numeric keywords
+func (v *validator) validateNumber(n *node, number json.Number, path string) {
+	r, ok := parseNumber(number)
+	if !ok {
+		v.fail(path, "is out of range")
+		return
+	}
+	if n.minimum != nil && r.Cmp(n.minimum) < 0 {
+		v.fail(path, "must be at least %s", formatRat(n.minimum))
+	}
+	if n.exclusiveMinimum != nil && r.Cmp(n.exclusiveMinimum) <= 0 {
+		v.fail(path, "must be greater than %s", formatRat(n.exclusiveMinimum))
+	}
+	if n.maximum != nil && r.Cmp(n.maximum) > 0 {
+		v.fail(path, "must be at most %s", formatRat(n.maximum))
+	}
+	if n.exclusiveMaximum != nil && r.Cmp(n.exclusiveMaximum) >= 0 {
+		v.fail(path, "must be less than %s", formatRat(n.exclusiveMaximum))
+	}
+	if n.multipleOf != nil && !new(big.Rat).Quo(r, n.multipleOf).IsInt() {
+		v.fail(path, "must be a multiple of %s", formatRat(n.multipleOf))
+	}
+}
+
+// validateArray checks the array keywords and the items
+func (v *validator) validateArray(n *node, items []interface{}, path string, depth int) []interface{} {
+	if v.dryRun {
+		items = append([]interface{}(nil), items...)
+	}
+
+	if n.minItems != nil && len(items) < *n.minItems {
+		v.fail(path, "must have at least %d items", *n.minItems)
+	}
+	if n.maxItems != nil && len(items) > *n.maxItems {
+		v.fail(path, "must have at most %d items", *n.maxItems)
+	}
+	if n.uniqueItems {
+	unique:
+		for i := range items {
+			for j := i + 1; j < len(items); j++ {
+				if equal(items[i], items[j]) {
+					v.fail(path, "must not contain duplicate items")
+					break unique
+				}
+			}
+		}
+	}
+
+	for i := range items {
+		itemPath := path + "/" + strconv.Itoa(i)
+		if i < len(n.prefixItems) {
+			items[i] = v.validate(n.prefixItems[i], items[i], itemPath, depth+1)
+		} else if n.items != nil {
+			items[i] = v.validate(n.items, items[i], itemPath, depth+1)
+		}
+	}
+
+	if n.contains != nil {
+		count := 0
+		for i, item := range items {
+			if v.matches(n.contains, item, path+"/"+strconv.Itoa(i), depth+1) {
+				count++
+			}
+		}
+		minContains := 1
+		if n.minContains != nil {
+			minContains = *n.minContains
+		}
+		if count < minContains {
+			v.fail(path, "must contain at least %d matching items", minContains)
+		}
+		if n.maxContains != nil && count > *n.maxContains {
+			v.fail(path, "must contain at most %d matching items", *n.maxContains)
+		}
+	}
+
+	return items
+}
+
+// validateObject checks the object keywords and the properties
+func (v *validator) validateObject(n *node, obj map[string]interface{}, path string, depth int) map[string]interface{} {
+	if v.dryRun {
+		copied := make(map[string]interface{}, len(obj))
+		for key, value := range obj {
+			copied[key] = value
+		}
+		obj = copied
+	}
+
+	// Forms submit empty fields as empty strings
+	for _, name := range sortedKeys(n.properties) {
+		if s, ok := obj[name].(string); ok && s == "" && !v.matches(n.properties[name], s, path, depth+1) {
+			delete(obj, name)
+			v.coerced = true
+		}
+	}
+
+	for _, name := range n.required {
+		if _, ok := obj[name]; !ok {
+			v.fail(path+"/"+escape(name), "is required")
+		}
+	}
+	if n.minProperties != nil && len(obj) < *n.minProperties {
+		v.fail(path, "must have at least %d properties", *n.minProperties)
+	}
+	if n.maxProperties != nil && len(obj) > *n.maxProperties {
+		v.fail(path, "must have at most %d properties", *n.maxProperties)
+	}
+	for _, name := range sortedKeys(n.dependentRequired) {
+		if _, ok := obj[name]; !ok {
+			continue
+		}
+		for _, dependent := range n.dependentRequired[name] {
+			if _, ok := obj[dependent]; !ok {
+				v.fail(path+"/"+escape(dependent), "is required when %s is present", name)
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(obj) {
+		propPath := path + "/" + escape(name)
+		if n.propertyNames != nil && !v.matches(n.propertyNames, name, propPath, depth+1) {
+			v.fail(propPath, "is not an allowed property name")
+		}
+
+		evaluated := false
+		if prop, ok := n.properties[name]; ok {
+			obj[name] = v.validate(prop, obj[name], propPath, depth+1)
+			evaluated = true
+		}
+		for _, pp := range n.patternProperties {
+			if pp.pattern.MatchString(name) {
+				obj[name] = v.validate(pp.schema, obj[name], propPath, depth+1)
+				evaluated = true
+			}
+		}
+		if !evaluated && n.additionalProperties != nil {
+			obj[name] = v.validate(n.additionalProperties, obj[name], propPath, depth+1)
+		}
+	}
+
+	var result interface{} = obj
+	for _, name := range sortedKeys(n.dependentSchemas) {
+		if _, ok := obj[name]; ok {
+			result = v.validate(n.dependentSchemas[name], result, path, depth+1)
+		}
+	}
+	return result.(map[string]interface{})
+}
+
+// coerce converts a value to the single type a schema declares for it, and
+// normalizes dates and times
+func (v *validator) coerce(n *node, value interface{}) interface{} {
+	switch target := n.singleType(); target {
+	case "number", "integer":
+		if s, ok := value.(string); ok && numberPattern.MatchString(strings.TrimSpace(s)) {
+			number := json.Number(strings.TrimSpace(s))
+			if r, ok := parseNumber(number); ok && target == "integer" && r.IsInt() {
+				number = json.Number(r.Num().String())
+			}
+			value = number
+			v.coerced = true
+		}
+	case "boolean":
+		if s, ok := value.(string); ok {
+			if b, err := strconv.ParseBool(strings.TrimSpace(s)); err == nil {
+				value = b
+				v.coerced = true
+			}
+		}
+	case "string":
+		switch val := value.(type) {
+		case json.Number:
+			value = string(val)
+			v.coerced = true
+		case bool:
+			value = strconv.FormatBool(val)
+			v.coerced = true
+		}
+	}
+
+	if s, ok := value.(string); ok && n.format != "" {
+		if f, ok := formats[n.format]; ok && f.normalize != nil && !f.valid(s) {
+			if normalized, ok := f.normalize(s); ok {
+				value = normalized
+				v.coerced = true
+			}
+		}
+	}
+	return value
+}
+
+// numberPattern matches the JSON number syntax
+var numberPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// singleType returns the type a schema declares, ignoring null, or "" if it
+// declares none or several
+func (n *node) singleType() string {
+	single := ""
+	for _, t := range n.types {
+		if t == "null" {
+			continue
+		}
+		if single != "" {
+			return ""
+		}
+		single = t
+	}
+	return single
+}
+
+// hasType reports whether a value is of one of the types
+func hasType(value interface{}, types []string) bool {
+	for _, t := range types {
+		switch val := value.(type) {
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		case json.Number:
+			if t == "number" {
+				return true
+			}
+			if t == "integer" {
+				if r, ok := parseNumber(val); ok && r.IsInt() {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// equal reports whether two JSON values are equal, comparing numbers by value
+func equal(a, b interface{}) bool {
+	switch x := a.(type) {
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		rx, okx := parseNumber(x)
+		ry, oky := parseNumber(y)
+		if !okx || !oky {
+			return x == y
+		}
+		return rx.Cmp(ry) == 0
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for key, value := range x {
+			other, ok := y[key]
+			if !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+// contains reports whether values contains a value equal to value
+func contains(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if equal(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// describeTypes describes the types of the type keyword
+func describeTypes(types []string) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		switch t {
+		case "null":
+			names[i] = "null"
+		case "integer":
+			names[i] = "an integer"
+		case "array", "object":
+			names[i] = "an " + t
+		default:
+			names[i] = "a " + t
+		}
+	}
+	if len(names) == 1 {
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
+
+// formatValue formats a value of const or enum for messages
+func formatValue(value interface{}) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// formatValues formats the values of enum for messages, up to ten of them
+func formatValues(values []interface{}) string {
+	formatted := make([]string, 0, len(values))
+	for i, value := range values {
+		if i == 10 {
+			formatted = append(formatted, "...")
+			break
+		}
+		formatted = append(formatted, formatValue(value))
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// formatRat formats a number of the schema for messages
+func formatRat(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	f, _ := r.Float64()
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
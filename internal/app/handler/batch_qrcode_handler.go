@@ -1,10 +1,15 @@
 package handler
 
 import (
+	"bytes"
 	"net/http"
 	"strconv"
+	"strings"
 
+	"cdk-office/internal/app/qrrender"
 	"cdk-office/internal/app/service"
+	"cdk-office/pkg/config"
+	"cdk-office/pkg/logger"
 	"github.com/gin-gonic/gin"
 )
 
@@ -16,6 +21,10 @@ type BatchQRCodeHandlerInterface interface {
 	ListBatchQRCodes(c *gin.Context)
 	GetBatchQRCode(c *gin.Context)
 	GenerateBatchQRCodes(c *gin.Context)
+	RetryBatchQRCodes(c *gin.Context)
+	DownloadBatchQRCodes(c *gin.Context)
+	DownloadPrintSheet(c *gin.Context)
+	ImportBatchData(c *gin.Context)
 }
 
 // BatchQRCodeHandler implements the BatchQRCodeHandlerInterface
@@ -24,32 +33,41 @@ type BatchQRCodeHandler struct {
 }
 
 // NewBatchQRCodeHandler creates a new instance of BatchQRCodeHandler
-func NewBatchQRCodeHandler() *BatchQRCodeHandler {
+func NewBatchQRCodeHandler(cfg *config.Config) *BatchQRCodeHandler {
 	return &BatchQRCodeHandler{
-		batchService: service.NewBatchQRCodeService(),
+		batchService: service.NewBatchQRCodeService(cfg),
+	}
+}
+
+// NewBatchQRCodeHandlerWithService creates a new instance of BatchQRCodeHandler with a specific batch service
+func NewBatchQRCodeHandlerWithService(batchService service.BatchQRCodeServiceInterface) *BatchQRCodeHandler {
+	return &BatchQRCodeHandler{
+		batchService: batchService,
 	}
 }
 
 // CreateBatchQRCodeRequest represents the request for creating a batch QR code
 type CreateBatchQRCodeRequest struct {
-	AppID       string            `json:"app_id" binding:"required"`
-	Name        string            `json:"name" binding:"required"`
-	Description string            `json:"description"`
-	Prefix      string            `json:"prefix"`
-	Count       int               `json:"count" binding:"required"`
-	Type        string            `json:"type" binding:"required"` // static or dynamic
-	URLTemplate string            `json:"url_template"`
-	Config      map[string]string `json:"config"`
-	CreatedBy   string            `json:"created_by" binding:"required"`
+	AppID        string            `json:"app_id" binding:"required"`
+	Name         string            `json:"name" binding:"required"`
+	Description  string            `json:"description"`
+	Prefix       string            `json:"prefix"`
+	Count        int               `json:"count" binding:"required"`
+	Type         string            `json:"type" binding:"required"` // static or dynamic
+	URLTemplate  string            `json:"url_template"`
+	NameTemplate string            `json:"name_template"`
+	Config       map[string]string `json:"config"`
+	CreatedBy    string            `json:"created_by" binding:"required"`
 }
 
 // UpdateBatchQRCodeRequest represents the request for updating a batch QR code
 type UpdateBatchQRCodeRequest struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Prefix      string `json:"prefix"`
-	URLTemplate string `json:"url_template"`
-	Config      string `json:"config"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	Prefix       string `json:"prefix"`
+	URLTemplate  string `json:"url_template"`
+	NameTemplate string `json:"name_template"`
+	Config       string `json:"config"`
 }
 
 // ListBatchQRCodesRequest represents the request for listing batch QR codes
@@ -69,15 +87,16 @@ func (h *BatchQRCodeHandler) CreateBatchQRCode(c *gin.Context) {
 
 	// Call service to create batch QR code
 	batch, err := h.batchService.CreateBatchQRCode(c.Request.Context(), &service.CreateBatchQRCodeRequest{
-		AppID:       req.AppID,
-		Name:        req.Name,
-		Description: req.Description,
-		Prefix:      req.Prefix,
-		Count:       req.Count,
-		Type:        req.Type,
-		URLTemplate: req.URLTemplate,
-		Config:      req.Config,
-		CreatedBy:   req.CreatedBy,
+		AppID:        req.AppID,
+		Name:         req.Name,
+		Description:  req.Description,
+		Prefix:       req.Prefix,
+		Count:        req.Count,
+		Type:         req.Type,
+		URLTemplate:  req.URLTemplate,
+		NameTemplate: req.NameTemplate,
+		Config:       req.Config,
+		CreatedBy:    req.CreatedBy,
 	})
 	if err != nil {
 		if err.Error() == "invalid QR code type" {
@@ -88,6 +107,10 @@ func (h *BatchQRCodeHandler) CreateBatchQRCode(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid count, must be between 1 and 10000"})
 			return
 		}
+		if strings.HasPrefix(err.Error(), "invalid config") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -111,16 +134,22 @@ func (h *BatchQRCodeHandler) UpdateBatchQRCode(c *gin.Context) {
 
 	// Call service to update batch QR code
 	if err := h.batchService.UpdateBatchQRCode(c.Request.Context(), batchID, &service.UpdateBatchQRCodeRequest{
-		Name:        req.Name,
-		Description: req.Description,
-		Prefix:      req.Prefix,
-		URLTemplate: req.URLTemplate,
-		Config:      req.Config,
+		Name:         req.Name,
+		Description:  req.Description,
+		Prefix:       req.Prefix,
+		URLTemplate:  req.URLTemplate,
+		NameTemplate: req.NameTemplate,
+		Config:       req.Config,
 	}); err != nil {
 		if err.Error() == "batch QR code not found" {
 			c.JSON(http.StatusNotFound, gin.H{"error": "batch QR code not found"})
 			return
 		}
+		if strings.HasPrefix(err.Error(), "invalid config") || strings.HasPrefix(err.Error(), "invalid template") ||
+			strings.HasPrefix(err.Error(), "invalid data:") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -142,6 +171,10 @@ func (h *BatchQRCodeHandler) DeleteBatchQRCode(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "batch QR code not found"})
 			return
 		}
+		if err.Error() == "batch QR code is generating" {
+			c.JSON(http.StatusConflict, gin.H{"error": "batch QR code is generating"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -213,7 +246,7 @@ func (h *BatchQRCodeHandler) GetBatchQRCode(c *gin.Context) {
 	c.JSON(http.StatusOK, batch)
 }
 
-// GenerateBatchQRCodes handles generating QR codes for a batch
+// GenerateBatchQRCodes handles queueing a batch for background generation
 func (h *BatchQRCodeHandler) GenerateBatchQRCodes(c *gin.Context) {
 	batchID := c.Param("id")
 	if batchID == "" {
@@ -221,18 +254,150 @@ func (h *BatchQRCodeHandler) GenerateBatchQRCodes(c *gin.Context) {
 		return
 	}
 
-	// Call service to generate batch QR codes
-	qrCodes, err := h.batchService.GenerateBatchQRCodes(c.Request.Context(), batchID)
+	// Call service to start batch generation
+	batch, err := h.batchService.StartBatchGeneration(c.Request.Context(), batchID)
 	if err != nil {
-		if err.Error() == "batch QR code not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "batch QR code not found"})
+		c.JSON(batchErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, batch)
+}
+
+// RetryBatchQRCodes handles queueing the failed items of a batch for another attempt
+func (h *BatchQRCodeHandler) RetryBatchQRCodes(c *gin.Context) {
+	batchID := c.Param("id")
+	if batchID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "batch id is required"})
+		return
+	}
+
+	// Call service to retry failed items
+	batch, err := h.batchService.RetryFailedItems(c.Request.Context(), batchID)
+	if err != nil {
+		c.JSON(batchErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, batch)
+}
+
+// DownloadBatchQRCodes handles downloading a generated batch as a ZIP archive of
+// images with a CSV manifest
+func (h *BatchQRCodeHandler) DownloadBatchQRCodes(c *gin.Context) {
+	batchID := c.Param("id")
+	if batchID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "batch id is required"})
+		return
+	}
+
+	// Stream the archive. The batch is checked before anything is written, so
+	// those errors can still be reported as JSON; later ones can only be logged.
+	c.Header("Content-Disposition", `attachment; filename="`+batchID+`.zip"`)
+	c.Header("Content-Type", "application/zip")
+	if err := h.batchService.WriteBatchArchive(c.Request.Context(), batchID, c.Writer); err != nil {
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Disposition")
+			c.JSON(batchErrorStatus(err), gin.H{"error": err.Error()})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		logger.Error("failed to stream batch QR code archive", "error", err, "batch_id", batchID)
+		c.Abort()
+	}
+}
+
+// DownloadPrintSheet handles downloading the codes of a generated batch as an A4
+// PDF of labels. The grid defaults to 3 columns by 8 rows without page margin.
+func (h *BatchQRCodeHandler) DownloadPrintSheet(c *gin.Context) {
+	batchID := c.Param("id")
+	if batchID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "batch id is required"})
 		return
 	}
 
-	c.JSON(http.StatusOK, qrCodes)
+	columns, err := strconv.Atoi(c.DefaultQuery("columns", "3"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid columns"})
+		return
+	}
+	rows, err := strconv.Atoi(c.DefaultQuery("rows", "8"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rows"})
+		return
+	}
+	margin, err := strconv.ParseFloat(c.DefaultQuery("margin", "0"), 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid margin"})
+		return
+	}
+	layout, err := qrrender.NewA4Layout(columns, rows, margin)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Build the sheet before responding so errors can still be reported as JSON
+	var sheet bytes.Buffer
+	if err := h.batchService.WritePrintSheet(c.Request.Context(), batchID, layout, &sheet); err != nil {
+		c.JSON(batchErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+batchID+`-sheet.pdf"`)
+	c.Data(http.StatusOK, "application/pdf", sheet.Bytes())
+}
+
+// ImportBatchData handles uploading a CSV or XLSX file whose rows become the
+// items of a pending batch. With dry_run=true the file is only validated. Rows
+// that fail validation are reported with status 422 and nothing is saved.
+func (h *BatchQRCodeHandler) ImportBatchData(c *gin.Context) {
+	batchID := c.Param("id")
+	if batchID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "batch id is required"})
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	dryRun := c.Query("dry_run") == "true"
+	report, err := h.batchService.ImportBatchData(c.Request.Context(), batchID, header.Filename, file, dryRun)
+	if err != nil {
+		if err.Error() == "invalid data" {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "report": report})
+			return
+		}
+		c.JSON(batchErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// batchErrorStatus maps batch QR code service errors to HTTP status codes
+func batchErrorStatus(err error) int {
+	switch msg := err.Error(); {
+	case msg == "batch QR code not found":
+		return http.StatusNotFound
+	case msg == "batch QR code is generating" || msg == "batch QR codes already generated" ||
+		msg == "no failed items to retry" || msg == "batch QR codes are not generated" ||
+		msg == "no generated items to print" || msg == "batch QR code already started":
+		return http.StatusConflict
+	case strings.HasPrefix(msg, "invalid config") || strings.HasPrefix(msg, "invalid template") ||
+		strings.HasPrefix(msg, "invalid data file") || strings.HasPrefix(msg, "invalid data:"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
 }
 
 // ListBatchQRCodesResponse represents the response for listing batch QR codes
@@ -241,4 +406,4 @@ type ListBatchQRCodesResponse struct {
 	Total int64                  `json:"total"`
 	Page  int                    `json:"page"`
 	Size  int                    `json:"size"`
-}
\ No newline at end of file
+}
@@ -4,14 +4,18 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
 	"cdk-office/internal/app/domain"
+	"cdk-office/internal/app/qrrender"
 	"cdk-office/internal/app/service"
 	"cdk-office/internal/shared/testutils"
+	"cdk-office/pkg/config"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -48,14 +52,43 @@ func (m *MockBatchQRCodeService) GetBatchQRCode(ctx context.Context, batchID str
 	return args.Get(0).(*service.BatchQRCode), args.Error(1)
 }
 
-func (m *MockBatchQRCodeService) GenerateBatchQRCodes(ctx context.Context, batchID string) ([]*domain.QRCode, error) {
+func (m *MockBatchQRCodeService) StartBatchGeneration(ctx context.Context, batchID string) (*service.BatchQRCode, error) {
 	args := m.Called(ctx, batchID)
-	return args.Get(0).([]*domain.QRCode), args.Error(1)
+	return args.Get(0).(*service.BatchQRCode), args.Error(1)
+}
+
+func (m *MockBatchQRCodeService) RetryFailedItems(ctx context.Context, batchID string) (*service.BatchQRCode, error) {
+	args := m.Called(ctx, batchID)
+	return args.Get(0).(*service.BatchQRCode), args.Error(1)
+}
+
+func (m *MockBatchQRCodeService) WritePrintSheet(ctx context.Context, batchID string, layout *qrrender.SheetLayout, w io.Writer) error {
+	args := m.Called(ctx, batchID, layout, w)
+	if args.Error(0) == nil {
+		w.Write([]byte("%PDF"))
+	}
+	return args.Error(0)
+}
+
+func (m *MockBatchQRCodeService) ImportBatchData(ctx context.Context, batchID, filename string, r io.Reader, dryRun bool) (*service.BatchDataReport, error) {
+	args := m.Called(ctx, batchID, filename, r, dryRun)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.BatchDataReport), args.Error(1)
+}
+
+func (m *MockBatchQRCodeService) WriteBatchArchive(ctx context.Context, batchID string, w io.Writer) error {
+	args := m.Called(ctx, batchID, w)
+	if args.Error(0) == nil {
+		w.Write([]byte("archive"))
+	}
+	return args.Error(0)
 }
 
 // TestNewBatchQRCodeHandler tests the NewBatchQRCodeHandler function
 func TestNewBatchQRCodeHandler(t *testing.T) {
-	handler := NewBatchQRCodeHandler()
+	handler := NewBatchQRCodeHandler(config.Default())
 	assert.NotNil(t, handler)
 	assert.NotNil(t, handler.batchService)
 }
@@ -772,38 +805,26 @@ func TestGenerateBatchQRCodes(t *testing.T) {
 	router := gin.New()
 	router.POST("/batches/:id/generate", handler.GenerateBatchQRCodes)
 
-	// Test successful generation
+	// Test successful queueing
 	t.Run("SuccessfulGeneration", func(t *testing.T) {
 		// Prepare test data
 		batchID := "batch_123"
 
 		// Mock service response
-		expectedQRCodes := []*domain.QRCode{
-			{
-				ID:        "qr_123",
-				AppID:     "app_123",
-				Name:      "Test QR 1",
-				Content:   "https://example.com/batch_123/1",
-				Type:      "static",
-				URL:       "https://example.com/batch_123/1",
-				CreatedBy: "user_123",
-				CreatedAt: time.Now(),
-				UpdatedAt: time.Now(),
-			},
-			{
-				ID:        "qr_456",
-				AppID:     "app_123",
-				Name:      "Test QR 2",
-				Content:   "https://example.com/batch_123/2",
-				Type:      "static",
-				URL:       "https://example.com/batch_123/2",
-				CreatedBy: "user_123",
-				CreatedAt: time.Now(),
-				UpdatedAt: time.Now(),
+		expectedBatch := &service.BatchQRCode{
+			ID:     batchID,
+			AppID:  "app_123",
+			Name:   "Test Batch",
+			Count:  2,
+			Type:   "static",
+			Status: domain.BatchQRCodeQueued,
+			Progress: &service.BatchQRCodeProgress{
+				Total:   2,
+				Pending: 2,
 			},
 		}
 
-		mockService.On("GenerateBatchQRCodes", mock.Anything, batchID).Return(expectedQRCodes, nil).Once()
+		mockService.On("StartBatchGeneration", mock.Anything, batchID).Return(expectedBatch, nil).Once()
 
 		// Create request
 		req, _ := http.NewRequest(http.MethodPost, "/batches/"+batchID+"/generate", nil)
@@ -815,14 +836,14 @@ func TestGenerateBatchQRCodes(t *testing.T) {
 		router.ServeHTTP(w, req)
 
 		// Assert response
-		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, http.StatusAccepted, w.Code)
 
 		// Parse response
-		var response []*domain.QRCode
+		var response service.BatchQRCode
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Equal(t, 2, len(response))
-		assert.Equal(t, "Test QR 1", response[0].Name)
+		assert.Equal(t, domain.BatchQRCodeQueued, response.Status)
+		assert.Equal(t, int64(2), response.Progress.Total)
 
 		// Assert mock expectations
 		mockService.AssertExpectations(t)
@@ -840,7 +861,7 @@ func TestGenerateBatchQRCodes(t *testing.T) {
 		router.ServeHTTP(w, req)
 
 		// Assert response
-		assert.Equal(t, http.StatusBadRequest, w.Code) // Changed to StatusBadRequest since the handler checks for empty ID
+		assert.Equal(t, http.StatusBadRequest, w.Code)
 	})
 
 	// Test service error - batch not found
@@ -849,7 +870,7 @@ func TestGenerateBatchQRCodes(t *testing.T) {
 		batchID := "batch_456"
 
 		// Mock service response
-		mockService.On("GenerateBatchQRCodes", mock.Anything, batchID).Return([]*domain.QRCode(nil), testutils.NewError("batch QR code not found")).Once()
+		mockService.On("StartBatchGeneration", mock.Anything, batchID).Return((*service.BatchQRCode)(nil), testutils.NewError("batch QR code not found")).Once()
 
 		// Create request
 		req, _ := http.NewRequest(http.MethodPost, "/batches/"+batchID+"/generate", nil)
@@ -868,13 +889,37 @@ func TestGenerateBatchQRCodes(t *testing.T) {
 		mockService.AssertExpectations(t)
 	})
 
+	// Test service error - already generated
+	t.Run("AlreadyGenerated", func(t *testing.T) {
+		// Prepare test data
+		batchID := "batch_789"
+
+		// Mock service response
+		mockService.On("StartBatchGeneration", mock.Anything, batchID).Return((*service.BatchQRCode)(nil), testutils.NewError("batch QR codes already generated")).Once()
+
+		// Create request
+		req, _ := http.NewRequest(http.MethodPost, "/batches/"+batchID+"/generate", nil)
+
+		// Create response recorder
+		w := httptest.NewRecorder()
+
+		// Perform request
+		router.ServeHTTP(w, req)
+
+		// Assert response
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		// Assert mock expectations
+		mockService.AssertExpectations(t)
+	})
+
 	// Test service error - internal error
 	t.Run("InternalServerError", func(t *testing.T) {
 		// Prepare test data
 		batchID := "batch_123"
 
 		// Mock service response
-		mockService.On("GenerateBatchQRCodes", mock.Anything, batchID).Return([]*domain.QRCode(nil), testutils.NewError("internal error")).Once()
+		mockService.On("StartBatchGeneration", mock.Anything, batchID).Return((*service.BatchQRCode)(nil), testutils.NewError("internal error")).Once()
 
 		// Create request
 		req, _ := http.NewRequest(http.MethodPost, "/batches/"+batchID+"/generate", nil)
@@ -892,4 +937,246 @@ func TestGenerateBatchQRCodes(t *testing.T) {
 		// Assert mock expectations
 		mockService.AssertExpectations(t)
 	})
-}
\ No newline at end of file
+}
+
+// TestRetryBatchQRCodes tests the RetryBatchQRCodes handler
+func TestRetryBatchQRCodes(t *testing.T) {
+	// Set up test environment
+	gin.SetMode(gin.TestMode)
+
+	// Create mock service
+	mockService := new(MockBatchQRCodeService)
+
+	// Create handler with mock service
+	handler := &BatchQRCodeHandler{
+		batchService: mockService,
+	}
+
+	// Create test router with route parameter
+	router := gin.New()
+	router.POST("/batches/:id/retry", handler.RetryBatchQRCodes)
+
+	// Test successful retry
+	t.Run("SuccessfulRetry", func(t *testing.T) {
+		batchID := "batch_123"
+		mockService.On("RetryFailedItems", mock.Anything, batchID).Return(&service.BatchQRCode{
+			ID:     batchID,
+			Status: domain.BatchQRCodeQueued,
+		}, nil).Once()
+
+		req, _ := http.NewRequest(http.MethodPost, "/batches/"+batchID+"/retry", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Contains(t, w.Body.String(), domain.BatchQRCodeQueued)
+		mockService.AssertExpectations(t)
+	})
+
+	// Test retry without failed items
+	t.Run("NoFailedItems", func(t *testing.T) {
+		batchID := "batch_456"
+		mockService.On("RetryFailedItems", mock.Anything, batchID).Return((*service.BatchQRCode)(nil), testutils.NewError("no failed items to retry")).Once()
+
+		req, _ := http.NewRequest(http.MethodPost, "/batches/"+batchID+"/retry", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		mockService.AssertExpectations(t)
+	})
+}
+
+// TestDownloadBatchQRCodes tests the DownloadBatchQRCodes handler
+func TestDownloadBatchQRCodes(t *testing.T) {
+	// Set up test environment
+	gin.SetMode(gin.TestMode)
+
+	// Create mock service
+	mockService := new(MockBatchQRCodeService)
+
+	// Create handler with mock service
+	handler := &BatchQRCodeHandler{
+		batchService: mockService,
+	}
+
+	// Create test router with route parameter
+	router := gin.New()
+	router.GET("/batches/:id/download", handler.DownloadBatchQRCodes)
+
+	// Test successful download
+	t.Run("SuccessfulDownload", func(t *testing.T) {
+		batchID := "batch_123"
+		mockService.On("WriteBatchArchive", mock.Anything, batchID, mock.Anything).Return(nil).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/batches/"+batchID+"/download", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Header().Get("Content-Disposition"), batchID+".zip")
+		assert.Equal(t, "archive", w.Body.String())
+		mockService.AssertExpectations(t)
+	})
+
+	// Test download before generation finished
+	t.Run("NotGenerated", func(t *testing.T) {
+		batchID := "batch_456"
+		mockService.On("WriteBatchArchive", mock.Anything, batchID, mock.Anything).Return(testutils.NewError("batch QR codes are not generated")).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/batches/"+batchID+"/download", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), "batch QR codes are not generated")
+		assert.Empty(t, w.Header().Get("Content-Disposition"))
+		mockService.AssertExpectations(t)
+	})
+}
+
+// TestDownloadPrintSheet tests the DownloadPrintSheet handler
+func TestDownloadPrintSheet(t *testing.T) {
+	// Set up test environment
+	gin.SetMode(gin.TestMode)
+
+	// Create mock service
+	mockService := new(MockBatchQRCodeService)
+
+	// Create handler with mock service
+	handler := &BatchQRCodeHandler{
+		batchService: mockService,
+	}
+
+	// Create test router with route parameter
+	router := gin.New()
+	router.GET("/batches/:id/sheet", handler.DownloadPrintSheet)
+
+	// Test successful download with the default 3x8 layout
+	t.Run("SuccessfulDownload", func(t *testing.T) {
+		batchID := "batch_123"
+		mockService.On("WritePrintSheet", mock.Anything, batchID, mock.MatchedBy(func(layout *qrrender.SheetLayout) bool {
+			return layout.Columns == 3 && layout.Rows == 8
+		}), mock.Anything).Return(nil).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/batches/"+batchID+"/sheet", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/pdf", w.Header().Get("Content-Type"))
+		assert.Equal(t, "%PDF", w.Body.String())
+		mockService.AssertExpectations(t)
+	})
+
+	// Test invalid layout
+	t.Run("InvalidLayout", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/batches/batch_123/sheet?columns=0", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "columns must be between")
+	})
+
+	// Test batch without generated items
+	t.Run("NoGeneratedItems", func(t *testing.T) {
+		batchID := "batch_456"
+		mockService.On("WritePrintSheet", mock.Anything, batchID, mock.Anything, mock.Anything).Return(testutils.NewError("no generated items to print")).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/batches/"+batchID+"/sheet?columns=2&rows=5&margin=10", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		mockService.AssertExpectations(t)
+	})
+}
+
+// newDataUploadRequest builds a multipart request uploading a data file
+func newDataUploadRequest(url, filename, content string) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("file", filename)
+	part.Write([]byte(content))
+	writer.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, url, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+// TestImportBatchData tests the ImportBatchData handler
+func TestImportBatchData(t *testing.T) {
+	// Set up test environment
+	gin.SetMode(gin.TestMode)
+
+	// Create mock service
+	mockService := new(MockBatchQRCodeService)
+
+	// Create handler with mock service
+	handler := &BatchQRCodeHandler{
+		batchService: mockService,
+	}
+
+	// Create test router with route parameter
+	router := gin.New()
+	router.POST("/batches/:id/data", handler.ImportBatchData)
+
+	// Test successful import
+	t.Run("SuccessfulImport", func(t *testing.T) {
+		mockService.On("ImportBatchData", mock.Anything, "batch_123", "assets.csv", mock.Anything, false).
+			Return(&service.BatchDataReport{Rows: 2, Columns: []string{"name", "url"}}, nil).Once()
+
+		req := newDataUploadRequest("/batches/batch_123/data", "assets.csv", "name,url\nA,https://example.com/a\nB,https://example.com/b\n")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var report service.BatchDataReport
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		assert.Equal(t, 2, report.Rows)
+		mockService.AssertExpectations(t)
+	})
+
+	// Test dry run with invalid rows
+	t.Run("InvalidRows", func(t *testing.T) {
+		mockService.On("ImportBatchData", mock.Anything, "batch_123", "assets.csv", mock.Anything, true).
+			Return(&service.BatchDataReport{
+				Rows:       1,
+				ErrorCount: 1,
+				Errors:     []*service.BatchDataRowError{{Row: 2, Column: "url", Message: "value is required"}},
+			}, testutils.NewError("invalid data")).Once()
+
+		req := newDataUploadRequest("/batches/batch_123/data?dry_run=true", "assets.csv", "name,url\nA,\n")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), `"errors":[{"row":2,"column":"url","message":"value is required"}]`)
+		mockService.AssertExpectations(t)
+	})
+
+	// Test missing file
+	t.Run("MissingFile", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, "/batches/batch_123/data", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	// Test batch already started
+	t.Run("AlreadyStarted", func(t *testing.T) {
+		mockService.On("ImportBatchData", mock.Anything, "batch_456", "assets.xlsx", mock.Anything, false).
+			Return(nil, testutils.NewError("batch QR code already started")).Once()
+
+		req := newDataUploadRequest("/batches/batch_456/data", "assets.xlsx", "data")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		mockService.AssertExpectations(t)
+	})
+}
@@ -3,7 +3,9 @@ package handler
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
+	"cdk-office/internal/app/dataexport"
 	"cdk-office/internal/app/service"
 	"github.com/gin-gonic/gin"
 )
@@ -90,6 +92,9 @@ func (h *DataCollectionHandler) CreateDataCollection(c *gin.Context) {
 		CreatedBy:   req.CreatedBy,
 	})
 	if err != nil {
+		if writeFormSchemaError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -123,6 +128,9 @@ func (h *DataCollectionHandler) UpdateDataCollection(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "data collection not found"})
 			return
 		}
+		if writeFormSchemaError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -234,6 +242,9 @@ func (h *DataCollectionHandler) SubmitDataEntry(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "data collection not found or inactive"})
 			return
 		}
+		if writeFormSchemaError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -287,7 +298,9 @@ func (h *DataCollectionHandler) ListDataEntries(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// ExportDataEntries handles exporting all data entries from a collection
+// ExportDataEntries handles exporting all data entries from a collection. With a
+// format query parameter (csv, xlsx or ndjson) the entries are streamed as a
+// file, see dataexport.OptionsFromQuery for the filters.
 func (h *DataCollectionHandler) ExportDataEntries(c *gin.Context) {
 	collectionID := c.Param("id")
 	if collectionID == "" {
@@ -295,6 +308,29 @@ func (h *DataCollectionHandler) ExportDataEntries(c *gin.Context) {
 		return
 	}
 
+	if c.Query("format") != "" {
+		opts, err := dataexport.OptionsFromQuery(c.Request.URL.Query())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		export, err := h.dataService.OpenDataEntriesExport(c.Request.Context(), collectionID, opts)
+		if err != nil {
+			if err.Error() == "data collection not found" {
+				c.JSON(http.StatusNotFound, gin.H{"error": "data collection not found"})
+				return
+			}
+			if strings.HasPrefix(err.Error(), "invalid export") {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		writeExport(c, export, collectionID)
+		return
+	}
+
 	// Call service to export data entries
 	entries, err := h.dataService.ExportDataEntries(c.Request.Context(), collectionID)
 	if err != nil {
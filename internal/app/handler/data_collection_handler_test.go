@@ -9,6 +9,8 @@ import (
 	"testing"
 	"time"
 
+	"cdk-office/internal/app/dataexport"
+	"cdk-office/internal/app/domain"
 	"cdk-office/internal/app/service"
 	"cdk-office/internal/shared/testutils"
 
@@ -62,6 +64,14 @@ func (m *MockDataCollectionService) ExportDataEntries(ctx context.Context, colle
 	return args.Get(0).([]*service.DataCollectionEntry), args.Error(1)
 }
 
+func (m *MockDataCollectionService) OpenDataEntriesExport(ctx context.Context, collectionID string, opts *dataexport.Options) (*dataexport.Export, error) {
+	args := m.Called(ctx, collectionID, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dataexport.Export), args.Error(1)
+}
+
 // TestNewDataCollectionHandler tests the NewDataCollectionHandler function
 func TestNewDataCollectionHandler(t *testing.T) {
 	handler := NewDataCollectionHandler()
@@ -1156,4 +1166,63 @@ func TestExportDataEntries(t *testing.T) {
 		// Assert mock expectations
 		mockService.AssertExpectations(t)
 	})
-}
\ No newline at end of file
+}
+// TestExportDataEntriesFile tests the ExportDataEntries handler streaming a file
+func TestExportDataEntriesFile(t *testing.T) {
+	// Set up test environment
+	gin.SetMode(gin.TestMode)
+	db := testutils.SetupTestDB()
+	defer db.Migrator().DropTable(&domain.DataCollectionEntry{})
+
+	mockService := new(MockDataCollectionService)
+	handler := &DataCollectionHandler{
+		dataService: mockService,
+	}
+	router := gin.New()
+	router.GET("/collections/:id/export", handler.ExportDataEntries)
+
+	// Test streaming the entries as NDJSON
+	t.Run("SuccessfulExport", func(t *testing.T) {
+		assert.NoError(t, db.Create(&domain.DataCollectionEntry{ID: "entry_123", CollectionID: "col_123", Data: `{"name": "John Doe"}`, CreatedBy: "user_123"}).Error)
+		query := db.Table("data_collection_entries").Where("collection_id = ?", "col_123")
+		export, err := dataexport.New(query, dataexport.Table{CreatedBy: "created_by", Data: "data"},
+			`{"type": "object", "properties": {"name": {"type": "string", "title": "Name"}}}`, &dataexport.Options{Format: "ndjson", Columns: []string{"_id", "name"}})
+		assert.NoError(t, err)
+
+		mockService.On("OpenDataEntriesExport", mock.Anything, "col_123", mock.MatchedBy(func(opts *dataexport.Options) bool {
+			return opts.Format == "ndjson" && opts.CreatedBy == "user_123"
+		})).Return(export, nil).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/collections/col_123/export?format=ndjson&created_by=user_123", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="col_123.ndjson"`, w.Header().Get("Content-Disposition"))
+		assert.Equal(t, "{\"_id\":\"entry_123\",\"name\":\"John Doe\"}\n", w.Body.String())
+		mockService.AssertExpectations(t)
+	})
+
+	// Test invalid filters are rejected before calling the service
+	t.Run("InvalidFilter", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/collections/col_123/export?format=csv&from=yesterday", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid export filter")
+	})
+
+	// Test invalid export options reported by the service
+	t.Run("InvalidColumn", func(t *testing.T) {
+		mockService.On("OpenDataEntriesExport", mock.Anything, "col_456", mock.Anything).Return(nil, testutils.NewError(`invalid export column "email"`)).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/collections/col_456/export?format=xlsx&columns=email", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockService.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,21 @@
+package handler
+
+import (
+	"net/http"
+
+	"cdk-office/internal/app/dataexport"
+	"cdk-office/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// writeExport streams an export as a file download named after name. Errors
+// after the download started can only be logged.
+func writeExport(c *gin.Context, export *dataexport.Export, name string) {
+	c.Header("Content-Disposition", `attachment; filename="`+export.FileName(name)+`"`)
+	c.Header("Content-Type", export.ContentType())
+	c.Status(http.StatusOK)
+	if err := export.Stream(c.Writer); err != nil {
+		logger.Error("failed to stream export", "error", err, "name", name)
+		c.Abort()
+	}
+}
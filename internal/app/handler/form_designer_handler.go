@@ -1,11 +1,14 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"cdk-office/internal/app/domain"
 	"cdk-office/internal/app/service"
+	approvalservice "cdk-office/internal/approval/service"
 	"github.com/gin-gonic/gin"
 )
 
@@ -31,6 +34,13 @@ func NewFormDesignerHandler() *FormDesignerHandler {
 	}
 }
 
+// NewFormDesignerHandlerWithService creates a new instance of FormDesignerHandler with a specific form designer service
+func NewFormDesignerHandlerWithService(formService service.FormDesignerServiceInterface) *FormDesignerHandler {
+	return &FormDesignerHandler{
+		formService: formService,
+	}
+}
+
 // CreateFormDesignRequest represents the request for creating a form design
 type CreateFormDesignRequest struct {
 	AppID       string `json:"app_id" binding:"required"`
@@ -217,7 +227,16 @@ func (h *FormDesignerHandler) PublishFormDesign(c *gin.Context) {
 	}
 
 	// Call service to publish form design
-	if err := h.formService.PublishFormDesign(c.Request.Context(), formID); err != nil {
+	if err := h.formService.PublishFormDesign(c.Request.Context(), formID, c.GetString("user_id")); err != nil {
+		var required *approvalservice.ApprovalRequiredError
+		if errors.As(err, &required) {
+			c.JSON(http.StatusAccepted, gin.H{"message": "form design submitted for approval", "approval_request": required.Request})
+			return
+		}
+		if err.Error() == "approval is already pending" || strings.HasPrefix(err.Error(), "no approvers found") {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		if err.Error() == "form design not found" {
 			c.JSON(http.StatusNotFound, gin.H{"error": "form design not found"})
 			return
@@ -226,6 +245,9 @@ func (h *FormDesignerHandler) PublishFormDesign(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "form design is already published"})
 			return
 		}
+		if writeFormSchemaError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
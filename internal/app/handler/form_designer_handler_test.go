@@ -11,6 +11,8 @@ import (
 
 	"cdk-office/internal/app/domain"
 	"cdk-office/internal/app/service"
+	approvaldomain "cdk-office/internal/approval/domain"
+	approvalservice "cdk-office/internal/approval/service"
 	"cdk-office/internal/shared/testutils"
 
 	"github.com/gin-gonic/gin"
@@ -48,8 +50,8 @@ func (m *MockFormDesignerService) GetFormDesign(ctx context.Context, formID stri
 	return args.Get(0).(*domain.FormDesign), args.Error(1)
 }
 
-func (m *MockFormDesignerService) PublishFormDesign(ctx context.Context, formID string) error {
-	args := m.Called(ctx, formID)
+func (m *MockFormDesignerService) PublishFormDesign(ctx context.Context, formID, userID string) error {
+	args := m.Called(ctx, formID, userID)
 	return args.Error(0)
 }
 
@@ -790,6 +792,9 @@ func TestPublishFormDesign(t *testing.T) {
 
 	// Create test router with route parameter
 	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", "user_1")
+	})
 	router.POST("/forms/:id/publish", handler.PublishFormDesign)
 
 	// Test successful publish
@@ -798,7 +803,7 @@ func TestPublishFormDesign(t *testing.T) {
 		formID := "form_123"
 
 		// Mock service response
-		mockService.On("PublishFormDesign", mock.Anything, formID).Return(nil).Once()
+		mockService.On("PublishFormDesign", mock.Anything, formID, "user_1").Return(nil).Once()
 
 		// Create request
 		req, _ := http.NewRequest(http.MethodPost, "/forms/"+formID+"/publish", nil)
@@ -839,7 +844,7 @@ func TestPublishFormDesign(t *testing.T) {
 		formID := "form_456"
 
 		// Mock service response
-		mockService.On("PublishFormDesign", mock.Anything, formID).Return(testutils.NewError("form design not found")).Once()
+		mockService.On("PublishFormDesign", mock.Anything, formID, "user_1").Return(testutils.NewError("form design not found")).Once()
 
 		// Create request
 		req, _ := http.NewRequest(http.MethodPost, "/forms/"+formID+"/publish", nil)
@@ -864,7 +869,7 @@ func TestPublishFormDesign(t *testing.T) {
 		formID := "form_123"
 
 		// Mock service response
-		mockService.On("PublishFormDesign", mock.Anything, formID).Return(testutils.NewError("form design is already published")).Once()
+		mockService.On("PublishFormDesign", mock.Anything, formID, "user_1").Return(testutils.NewError("form design is already published")).Once()
 
 		// Create request
 		req, _ := http.NewRequest(http.MethodPost, "/forms/"+formID+"/publish", nil)
@@ -889,7 +894,7 @@ func TestPublishFormDesign(t *testing.T) {
 		formID := "form_123"
 
 		// Mock service response
-		mockService.On("PublishFormDesign", mock.Anything, formID).Return(testutils.NewError("internal error")).Once()
+		mockService.On("PublishFormDesign", mock.Anything, formID, "user_1").Return(testutils.NewError("internal error")).Once()
 
 		// Create request
 		req, _ := http.NewRequest(http.MethodPost, "/forms/"+formID+"/publish", nil)
@@ -907,4 +912,24 @@ func TestPublishFormDesign(t *testing.T) {
 		// Assert mock expectations
 		mockService.AssertExpectations(t)
 	})
+	// Test publishing submitted for approval
+	t.Run("ApprovalRequired", func(t *testing.T) {
+		formID := "form_789"
+		request := &approvaldomain.ApprovalRequest{ID: "apr_1", EntityID: formID, Status: approvaldomain.ApprovalPending}
+		mockService.On("PublishFormDesign", mock.Anything, formID, "user_1").Return(&approvalservice.ApprovalRequiredError{Request: request}).Once()
+
+		req, _ := http.NewRequest(http.MethodPost, "/forms/"+formID+"/publish", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Contains(t, w.Body.String(), "form design submitted for approval")
+		assert.Contains(t, w.Body.String(), "apr_1")
+
+		mockService.On("PublishFormDesign", mock.Anything, formID, "user_1").Return(testutils.NewError("approval is already pending")).Once()
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		mockService.AssertExpectations(t)
+	})
 }
\ No newline at end of file
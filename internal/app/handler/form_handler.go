@@ -3,7 +3,9 @@ package handler
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
+	"cdk-office/internal/app/dataexport"
 	"cdk-office/internal/app/domain"
 	"cdk-office/internal/app/service"
 	"github.com/gin-gonic/gin"
@@ -18,6 +20,7 @@ type FormHandlerInterface interface {
 	GetForm(c *gin.Context)
 	SubmitFormData(c *gin.Context)
 	ListFormDataEntries(c *gin.Context)
+	ExportFormDataEntries(c *gin.Context)
 }
 
 // FormHandler implements the FormHandlerInterface
@@ -87,6 +90,9 @@ func (h *FormHandler) CreateForm(c *gin.Context) {
 		CreatedBy:   req.CreatedBy,
 	})
 	if err != nil {
+		if writeFormSchemaError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -119,6 +125,9 @@ func (h *FormHandler) UpdateForm(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "form not found"})
 			return
 		}
+		if writeFormSchemaError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -230,6 +239,9 @@ func (h *FormHandler) SubmitFormData(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "form not found or inactive"})
 			return
 		}
+		if writeFormSchemaError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -279,6 +291,39 @@ func (h *FormHandler) ListFormDataEntries(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// ExportFormDataEntries handles exporting the data entries of a form as a CSV,
+// XLSX or NDJSON file, see dataexport.OptionsFromQuery for the filters
+func (h *FormHandler) ExportFormDataEntries(c *gin.Context) {
+	formID := c.Param("id")
+	if formID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "form id is required"})
+		return
+	}
+
+	opts, err := dataexport.OptionsFromQuery(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Call service to prepare the export
+	export, err := h.formService.OpenFormDataExport(c.Request.Context(), formID, opts)
+	if err != nil {
+		if err.Error() == "form not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "form not found"})
+			return
+		}
+		if strings.HasPrefix(err.Error(), "invalid export") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	writeExport(c, export, formID)
+}
+
 // ListFormsResponse represents the response for listing forms
 type ListFormsResponse struct {
 	Items []*domain.FormData `json:"items"`
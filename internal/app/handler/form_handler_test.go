@@ -3,12 +3,15 @@ package handler
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"cdk-office/internal/app/dataexport"
 	"cdk-office/internal/app/domain"
+	"cdk-office/internal/app/formschema"
 	"cdk-office/internal/app/service"
 	"cdk-office/internal/shared/testutils"
 
@@ -72,6 +75,14 @@ func (m *MockFormService) ListFormDataEntries(ctx context.Context, formID string
 	return args.Get(0).([]*domain.FormDataEntry), args.Get(1).(int64), args.Error(2)
 }
 
+func (m *MockFormService) OpenFormDataExport(ctx context.Context, formID string, opts *dataexport.Options) (*dataexport.Export, error) {
+	args := m.Called(ctx, formID, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*dataexport.Export), args.Error(1)
+}
+
 func TestNewFormHandler(t *testing.T) {
 	handler := NewFormHandler()
 	assert.NotNil(t, handler)
@@ -770,4 +781,65 @@ func TestFormHandler_ListFormDataEntries(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
 		mockService.AssertExpectations(t)
 	})
-}
\ No newline at end of file
+}
+func TestFormHandler_SubmitFormDataValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("invalid data", func(t *testing.T) {
+		// Setup
+		mockService := new(MockFormService)
+		handler := &FormHandler{formService: mockService}
+
+		// Mock service to reject the data
+		validationErr := &formschema.ValidationError{Errors: []*formschema.FieldError{
+			{Path: "/name", Message: "is required"},
+			{Path: "/age", Message: "must be an integer"},
+		}}
+		mockService.On("SubmitFormData", mock.Anything, mock.Anything).Return((*domain.FormDataEntry)(nil), validationErr)
+
+		// Create request
+		reqBody := `{"form_id":"form_123","data":"{\"age\": \"thirty\"}","created_by":"user_123"}`
+		req, _ := http.NewRequest(http.MethodPost, "/forms/data", bytes.NewBufferString(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		// Create context and call handler
+		c, _ := gin.CreateTestContext(w)
+		c.Request = req
+
+		handler.SubmitFormData(c)
+
+		// Assertions
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.JSONEq(t, `{"error": "invalid data: /name: is required (and 1 more error)", "errors": [
+			{"path": "/name", "message": "is required"},
+			{"path": "/age", "message": "must be an integer"}
+		]}`, w.Body.String())
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("invalid schema", func(t *testing.T) {
+		// Setup
+		mockService := new(MockFormService)
+		handler := &FormHandler{formService: mockService}
+
+		// Mock service to reject the schema
+		mockService.On("CreateForm", mock.Anything, mock.Anything).Return((*domain.FormData)(nil), fmt.Errorf(`%w: /type: unknown type "numbr"`, formschema.ErrInvalidSchema))
+
+		// Create request
+		reqBody := `{"app_id":"app_123","name":"Test Form","schema":"{\"type\": \"numbr\"}","created_by":"user_123"}`
+		req, _ := http.NewRequest(http.MethodPost, "/forms", bytes.NewBufferString(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		// Create context and call handler
+		c, _ := gin.CreateTestContext(w)
+		c.Request = req
+
+		handler.CreateForm(c)
+
+		// Assertions
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockService.AssertExpectations(t)
+	})
+}
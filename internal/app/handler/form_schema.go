@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"cdk-office/internal/app/formschema"
+	"github.com/gin-gonic/gin"
+)
+
+// writeFormSchemaError responds to invalid schemas and to data failing schema
+// validation, with the path of every invalid field, and reports whether err
+// was one of them
+func writeFormSchemaError(c *gin.Context, err error) bool {
+	var validationErr *formschema.ValidationError
+	if errors.As(err, &validationErr) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "errors": validationErr.Errors})
+		return true
+	}
+	if errors.Is(err, formschema.ErrInvalidSchema) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return true
+	}
+	return false
+}
@@ -6,6 +6,7 @@ import (
 
 	"cdk-office/internal/app/domain"
 	"cdk-office/internal/app/service"
+	"cdk-office/pkg/config"
 	"github.com/gin-gonic/gin"
 )
 
@@ -25,9 +26,9 @@ type QRCodeHandler struct {
 }
 
 // NewQRCodeHandler creates a new instance of QRCodeHandler
-func NewQRCodeHandler() *QRCodeHandler {
+func NewQRCodeHandler(cfg *config.QRCodeConfig) *QRCodeHandler {
 	return &QRCodeHandler{
-		qrCodeService: service.NewQRCodeService(),
+		qrCodeService: service.NewQRCodeService(cfg),
 	}
 }
 
@@ -77,6 +78,10 @@ func (h *QRCodeHandler) CreateQRCode(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid QR code type"})
 			return
 		}
+		if err.Error() == "invalid target URL" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid target URL"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -108,6 +113,10 @@ func (h *QRCodeHandler) UpdateQRCode(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "QR code not found"})
 			return
 		}
+		if err.Error() == "invalid target URL" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid target URL"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -12,6 +12,7 @@ import (
 	"cdk-office/internal/app/domain"
 	"cdk-office/internal/app/service"
 	"cdk-office/internal/shared/testutils"
+	"cdk-office/pkg/config"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -64,7 +65,7 @@ func (m *MockQRCodeService) GenerateQRCodeImage(ctx context.Context, qrCodeID st
 
 // TestNewQRCodeHandler tests the NewQRCodeHandler function
 func TestNewQRCodeHandler(t *testing.T) {
-	handler := NewQRCodeHandler()
+	handler := NewQRCodeHandler(&config.Default().QRCode)
 	assert.NotNil(t, handler)
 	assert.NotNil(t, handler.qrCodeService)
 }
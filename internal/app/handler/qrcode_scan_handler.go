@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"cdk-office/internal/app/service"
+	"github.com/gin-gonic/gin"
+)
+
+// statsDays is the number of days covered by scan statistics when no range is given
+const statsDays = 30
+
+// QRCodeScanHandlerInterface defines the interface for QR code scan handler
+type QRCodeScanHandlerInterface interface {
+	Redirect(c *gin.Context)
+	GetQRCodeStats(c *gin.Context)
+	GetBatchStats(c *gin.Context)
+}
+
+// QRCodeScanHandler implements the QRCodeScanHandlerInterface
+type QRCodeScanHandler struct {
+	scanService service.QRCodeScanServiceInterface
+}
+
+// NewQRCodeScanHandler creates a new instance of QRCodeScanHandler
+func NewQRCodeScanHandler() *QRCodeScanHandler {
+	return NewQRCodeScanHandlerWithService(service.NewQRCodeScanService())
+}
+
+// NewQRCodeScanHandlerWithService creates a new instance of QRCodeScanHandler with a specific service
+func NewQRCodeScanHandlerWithService(scanService service.QRCodeScanServiceInterface) *QRCodeScanHandler {
+	return &QRCodeScanHandler{
+		scanService: scanService,
+	}
+}
+
+// Redirect handles a scan of a dynamic QR code by redirecting to its current target URL
+func (h *QRCodeScanHandler) Redirect(c *gin.Context) {
+	shortCode := c.Param("code")
+	if shortCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "short code is required"})
+		return
+	}
+
+	target, err := h.scanService.ResolveShortCode(c.Request.Context(), shortCode, &service.ScanClient{
+		IP:             c.ClientIP(),
+		UserAgent:      c.Request.UserAgent(),
+		Referrer:       c.Request.Referer(),
+		AcceptLanguage: c.GetHeader("Accept-Language"),
+	})
+	if err != nil {
+		if err.Error() == "QR code not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "QR code not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The target can change at any time, so the redirect must not be cached
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, target)
+}
+
+// GetQRCodeStats handles retrieving the scan statistics of a QR code
+func (h *QRCodeScanHandler) GetQRCodeStats(c *gin.Context) {
+	qrCodeID := c.Param("id")
+	if qrCodeID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "QR code id is required"})
+		return
+	}
+
+	from, to, ok := statsRange(c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.scanService.GetQRCodeStats(c.Request.Context(), qrCodeID, from, to)
+	if err != nil {
+		c.JSON(statsErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetBatchStats handles retrieving the scan statistics of a batch
+func (h *QRCodeScanHandler) GetBatchStats(c *gin.Context) {
+	batchID := c.Param("id")
+	if batchID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "batch id is required"})
+		return
+	}
+
+	from, to, ok := statsRange(c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.scanService.GetBatchStats(c.Request.Context(), batchID, from, to)
+	if err != nil {
+		c.JSON(statsErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// statsRange reads the from and to query parameters as YYYY-MM-DD days in UTC.
+// The range defaults to the last 30 days up to today. It responds with an error
+// and returns false when a parameter is invalid.
+func statsRange(c *gin.Context) (time.Time, time.Time, bool) {
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date"})
+			return time.Time{}, time.Time{}, false
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(0, 0, -(statsDays - 1))
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date"})
+			return time.Time{}, time.Time{}, false
+		}
+		from = parsed
+	}
+
+	return from, to, true
+}
+
+// statsErrorStatus maps scan statistics errors to HTTP status codes
+func statsErrorStatus(err error) int {
+	switch err.Error() {
+	case "QR code not found", "batch QR code not found":
+		return http.StatusNotFound
+	case "invalid date range":
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
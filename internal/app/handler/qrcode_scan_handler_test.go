@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cdk-office/internal/app/service"
+	"cdk-office/internal/shared/testutils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockQRCodeScanService is a mock implementation of QRCodeScanServiceInterface
+type MockQRCodeScanService struct {
+	mock.Mock
+}
+
+func (m *MockQRCodeScanService) ResolveShortCode(ctx context.Context, shortCode string, client *service.ScanClient) (string, error) {
+	args := m.Called(ctx, shortCode, client)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockQRCodeScanService) GetQRCodeStats(ctx context.Context, qrCodeID string, from, to time.Time) (*service.ScanStats, error) {
+	args := m.Called(ctx, qrCodeID, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.ScanStats), args.Error(1)
+}
+
+func (m *MockQRCodeScanService) GetBatchStats(ctx context.Context, batchID string, from, to time.Time) (*service.ScanStats, error) {
+	args := m.Called(ctx, batchID, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*service.ScanStats), args.Error(1)
+}
+
+// TestRedirect tests the Redirect handler
+func TestRedirect(t *testing.T) {
+	// Set up test environment
+	gin.SetMode(gin.TestMode)
+
+	// Create handler with mock service
+	mockService := new(MockQRCodeScanService)
+	handler := NewQRCodeScanHandlerWithService(mockService)
+
+	// Create test router with route parameter
+	router := gin.New()
+	router.GET("/q/:code", handler.Redirect)
+
+	// Test successful redirect passes the client details to the service
+	t.Run("SuccessfulRedirect", func(t *testing.T) {
+		mockService.On("ResolveShortCode", mock.Anything, "Ab3dE6gH", mock.MatchedBy(func(client *service.ScanClient) bool {
+			return client.UserAgent == "test-agent" && client.Referrer == "https://example.com/" && client.AcceptLanguage == "en-US"
+		})).Return("https://example.com/assets/1", nil).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/q/Ab3dE6gH", nil)
+		req.Header.Set("User-Agent", "test-agent")
+		req.Header.Set("Referer", "https://example.com/")
+		req.Header.Set("Accept-Language", "en-US")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "https://example.com/assets/1", w.Header().Get("Location"))
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		mockService.AssertExpectations(t)
+	})
+
+	// Test unknown short code
+	t.Run("NotFound", func(t *testing.T) {
+		mockService.On("ResolveShortCode", mock.Anything, "missing", mock.Anything).Return("", testutils.NewError("QR code not found")).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/q/missing", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		mockService.AssertExpectations(t)
+	})
+}
+
+// TestGetQRCodeStats tests the GetQRCodeStats handler
+func TestGetQRCodeStats(t *testing.T) {
+	// Set up test environment
+	gin.SetMode(gin.TestMode)
+
+	// Create handler with mock service
+	mockService := new(MockQRCodeScanService)
+	handler := NewQRCodeScanHandlerWithService(mockService)
+
+	// Create test router with route parameter
+	router := gin.New()
+	router.GET("/qrcodes/:id/stats", handler.GetQRCodeStats)
+
+	// Test statistics for an explicit range
+	t.Run("SuccessfulGet", func(t *testing.T) {
+		from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+		to := time.Date(2024, 5, 7, 0, 0, 0, 0, time.UTC)
+		mockService.On("GetQRCodeStats", mock.Anything, "qrcode_123", from, to).Return(&service.ScanStats{
+			From:           "2024-05-01",
+			To:             "2024-05-07",
+			TotalScans:     12,
+			UniqueVisitors: 5,
+		}, nil).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/qrcodes/qrcode_123/stats?from=2024-05-01&to=2024-05-07", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var stats service.ScanStats
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+		assert.Equal(t, int64(12), stats.TotalScans)
+		assert.Equal(t, int64(5), stats.UniqueVisitors)
+		mockService.AssertExpectations(t)
+	})
+
+	// Test the range defaults to the last 30 days
+	t.Run("DefaultRange", func(t *testing.T) {
+		mockService.On("GetQRCodeStats", mock.Anything, "qrcode_123", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			from := args.Get(2).(time.Time)
+			to := args.Get(3).(time.Time)
+			assert.Equal(t, 29*24*time.Hour, to.Sub(from))
+		}).Return(&service.ScanStats{}, nil).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/qrcodes/qrcode_123/stats", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	// Test invalid dates
+	t.Run("InvalidDate", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/qrcodes/qrcode_123/stats?from=05/01/2024", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	// Test QR code not found
+	t.Run("NotFound", func(t *testing.T) {
+		mockService.On("GetQRCodeStats", mock.Anything, "qrcode_missing", mock.Anything, mock.Anything).Return(nil, testutils.NewError("QR code not found")).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/qrcodes/qrcode_missing/stats", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		mockService.AssertExpectations(t)
+	})
+}
+
+// TestGetBatchStats tests the GetBatchStats handler
+func TestGetBatchStats(t *testing.T) {
+	// Set up test environment
+	gin.SetMode(gin.TestMode)
+
+	// Create handler with mock service
+	mockService := new(MockQRCodeScanService)
+	handler := NewQRCodeScanHandlerWithService(mockService)
+
+	// Create test router with route parameter
+	router := gin.New()
+	router.GET("/batches/:id/stats", handler.GetBatchStats)
+
+	// Test statistics with top codes
+	t.Run("SuccessfulGet", func(t *testing.T) {
+		mockService.On("GetBatchStats", mock.Anything, "batch_123", mock.Anything, mock.Anything).Return(&service.ScanStats{
+			TotalScans: 4,
+			TopCodes:   []*service.QRCodeScanCount{{QRCodeID: "qrcode_1", Name: "Tag 1", Scans: 4}},
+		}, nil).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/batches/batch_123/stats", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"top_codes":[{"qr_code_id":"qrcode_1"`)
+		mockService.AssertExpectations(t)
+	})
+
+	// Test invalid range
+	t.Run("InvalidRange", func(t *testing.T) {
+		mockService.On("GetBatchStats", mock.Anything, "batch_123", mock.Anything, mock.Anything).Return(nil, testutils.NewError("invalid date range")).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/batches/batch_123/stats?from=2024-05-07&to=2024-05-01", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockService.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,220 @@
+package qrrender
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg" // logo decoding
+	_ "image/png"  // logo decoding
+	"strconv"
+	"strings"
+
+	"github.com/yougg/go-qrcode"
+)
+
+// Output formats
+const (
+	FormatPNG = "png"
+	FormatSVG = "svg"
+	FormatPDF = "pdf"
+)
+
+// Limits of the rendering options
+const (
+	minSize        = 64
+	maxSize        = 4096
+	maxMargin      = 16
+	maxLogoPercent = 30
+	maxLabelLength = 200
+)
+
+// Options controls how a QR code is rendered
+type Options struct {
+	Format     string
+	Size       int // width of the code in pixels, or points for PDF
+	Margin     int // quiet zone around the code in modules
+	Foreground color.RGBA
+	Background color.RGBA
+	Level      qrcode.RecoveryLevel
+	Logo       image.Image // drawn in the centre of the code when set
+	LogoSize   int         // percentage of the code width covered by the logo
+	Label      string      // caption drawn under the code
+}
+
+// DefaultOptions returns the options used when no configuration is given
+func DefaultOptions() *Options {
+	return &Options{
+		Format:     FormatPNG,
+		Size:       256,
+		Margin:     4,
+		Foreground: color.RGBA{A: 0xff},
+		Background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		Level:      qrcode.High,
+		LogoSize:   20,
+	}
+}
+
+// ParseConfig builds options from a configuration map. Recognised keys are
+// format, size, margin, foreground, background, error_correction, logo,
+// logo_size and label; other keys are ignored. The logo is a base64 encoded
+// PNG or JPEG image, optionally given as a data URI.
+func ParseConfig(config map[string]string) (*Options, error) {
+	opts := DefaultOptions()
+	var err error
+
+	if value := config["format"]; value != "" {
+		switch format := strings.ToLower(value); format {
+		case FormatPNG, FormatSVG, FormatPDF:
+			opts.Format = format
+		default:
+			return nil, fmt.Errorf("unsupported format %q", value)
+		}
+	}
+	if opts.Size, err = parseInt(config, "size", opts.Size, minSize, maxSize); err != nil {
+		return nil, err
+	}
+	if opts.Margin, err = parseInt(config, "margin", opts.Margin, 0, maxMargin); err != nil {
+		return nil, err
+	}
+	if value := config["foreground"]; value != "" {
+		if opts.Foreground, err = parseColor(value); err != nil {
+			return nil, err
+		}
+	}
+	if value := config["background"]; value != "" {
+		if opts.Background, err = parseColor(value); err != nil {
+			return nil, err
+		}
+	}
+	if value := config["error_correction"]; value != "" {
+		if opts.Level, err = parseLevel(value); err != nil {
+			return nil, err
+		}
+	}
+	if opts.LogoSize, err = parseInt(config, "logo_size", opts.LogoSize, 5, maxLogoPercent); err != nil {
+		return nil, err
+	}
+	if value := config["logo"]; value != "" {
+		if opts.Logo, err = parseLogo(value); err != nil {
+			return nil, err
+		}
+		// The logo hides modules, which only the higher levels can recover
+		if opts.Level < qrcode.High {
+			return nil, errors.New("error_correction must be Q or H when a logo is used")
+		}
+	}
+	opts.Label = config["label"]
+	if len(opts.Label) > maxLabelLength {
+		return nil, fmt.Errorf("label must be at most %d characters", maxLabelLength)
+	}
+
+	return opts, nil
+}
+
+// ParseConfigJSON builds options from a JSON object as stored in a batch's config
+func ParseConfigJSON(config string) (*Options, error) {
+	if strings.TrimSpace(config) == "" {
+		return DefaultOptions(), nil
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(config), &raw); err != nil {
+		return nil, errors.New("config must be a JSON object")
+	}
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		if value != nil {
+			values[key] = fmt.Sprint(value)
+		}
+	}
+	return ParseConfig(values)
+}
+
+// WithLabel returns a copy of the options with a different caption
+func (o *Options) WithLabel(label string) *Options {
+	copied := *o
+	copied.Label = label
+	return &copied
+}
+
+// Extension returns the file extension of the output format, including the dot
+func (o *Options) Extension() string {
+	return "." + o.Format
+}
+
+// ContentType returns the MIME type of the output format
+func (o *Options) ContentType() string {
+	switch o.Format {
+	case FormatSVG:
+		return "image/svg+xml"
+	case FormatPDF:
+		return "application/pdf"
+	default:
+		return "image/png"
+	}
+}
+
+// parseInt reads an integer option and checks that it lies within [min, max]
+func parseInt(config map[string]string, key string, defaultValue, min, max int) (int, error) {
+	value := config[key]
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%s must be a number between %d and %d", key, min, max)
+	}
+	return n, nil
+}
+
+// parseColor reads a colour in #rgb or #rrggbb notation
+func parseColor(value string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(value, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid colour %q", value)
+	}
+	return color.RGBA{R: uint8(n >> 16), G: uint8(n >> 8), B: uint8(n), A: 0xff}, nil
+}
+
+// parseLevel reads an error-correction level given as L, M, Q or H
+func parseLevel(value string) (qrcode.RecoveryLevel, error) {
+	switch strings.ToUpper(value) {
+	case "L":
+		return qrcode.Low, nil
+	case "M":
+		return qrcode.Medium, nil
+	case "Q":
+		return qrcode.High, nil
+	case "H":
+		return qrcode.Highest, nil
+	}
+	return 0, fmt.Errorf("invalid error_correction %q, must be L, M, Q or H", value)
+}
+
+// parseLogo decodes a base64 encoded image, with or without a data URI prefix
+func parseLogo(value string) (image.Image, error) {
+	if strings.HasPrefix(value, "data:") {
+		comma := strings.Index(value, ",")
+		if comma < 0 || !strings.HasSuffix(value[:comma], ";base64") {
+			return nil, errors.New("logo must be a base64 data URI")
+		}
+		value = value[comma+1:]
+	}
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.New("logo must be base64 encoded")
+	}
+	logo, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("logo must be a PNG or JPEG image")
+	}
+	return logo, nil
+}
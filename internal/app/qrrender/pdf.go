@@ -0,0 +1,67 @@
+package qrrender
+
+import (
+	"io"
+
+	"cdk-office/internal/shared/pdf"
+)
+
+// newPDFDocument creates an empty document with the logo of the options, named
+// Logo, composited onto the background colour
+func newPDFDocument(opts *Options) *pdf.Document {
+	doc := pdf.NewDocument()
+	if opts.Logo != nil {
+		doc.AddImage("Logo", opts.Logo, opts.Background)
+	}
+	return doc
+}
+
+// drawCode draws a code as a square with the given side at (x, y)
+func drawCode(p *pdf.Canvas, c *code, opts *Options, x, y, side float64) {
+	module := side / float64(c.size)
+
+	p.SetFill(opts.Background)
+	p.Rect(x, y, side, side)
+	p.Fill()
+
+	p.SetFill(opts.Foreground)
+	for row := 0; row < c.size; row++ {
+		for _, run := range c.runs(row) {
+			p.Rect(x+float64(run[0])*module, y+float64(row)*module, float64(run[1])*module, module)
+		}
+	}
+	p.Fill()
+
+	if opts.Logo != nil {
+		lx, ly, lside := c.logoRect()
+		ix, iy, iw, ih := fitRect(opts.Logo, lx, ly, lside)
+		p.Image("Logo", x+ix*module, y+iy*module, iw*module, ih*module)
+	}
+}
+
+// writePDF writes a code as a single page PDF opts.Size points wide
+func writePDF(w io.Writer, c *code, opts *Options) error {
+	side := float64(opts.Size)
+	height := side
+	if opts.Label != "" {
+		height += side * labelBand
+	}
+
+	doc := newPDFDocument(opts)
+	canvas := pdf.NewCanvas(height)
+	if opts.Label != "" {
+		// Paint the caption band in the background colour as well
+		canvas.SetFill(opts.Background)
+		canvas.Rect(0, side, side, height-side)
+		canvas.Fill()
+	}
+	drawCode(canvas, c, opts, 0, 0, side)
+	if opts.Label != "" {
+		band := side * labelBand
+		canvas.SetFill(opts.Foreground)
+		canvas.CenteredText(opts.Label, pdf.Regular, band*0.6, side/2, side+band*0.7, side)
+	}
+	doc.AddPage(side, height, canvas)
+
+	return doc.Write(w)
+}
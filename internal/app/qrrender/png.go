@@ -0,0 +1,157 @@
+package qrrender
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"sync"
+
+	"cdk-office/internal/shared/pdf"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// captionSize is the pixel size of captions in a 128 pixel image, growing
+// with the image
+const captionSize = 13
+
+// goRegular draws the Latin-1 characters of captions
+var goRegular = sync.OnceValues(func() (*opentype.Font, error) {
+	return opentype.Parse(goregular.TTF)
+})
+
+// writePNG rasterises a code into a PNG image opts.Size pixels wide. The image is
+// enlarged when it is too small to give every module at least one pixel.
+func writePNG(w io.Writer, c *code, opts *Options) error {
+	size := opts.Size
+	if size < c.size {
+		size = c.size
+	}
+	scale := size / c.size
+	offset := (size - c.size*scale) / 2
+
+	// Captions grow with the image
+	textScale := 0
+	labelHeight := 0
+	if opts.Label != "" {
+		textScale = size / 128
+		if textScale < 1 {
+			textScale = 1
+		}
+		labelHeight = (captionSize + 4) * textScale
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, size, size+labelHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(opts.Background), image.Point{}, draw.Src)
+
+	foreground := image.NewUniform(opts.Foreground)
+	for y := 0; y < c.size; y++ {
+		for _, run := range c.runs(y) {
+			rect := image.Rect(offset+run[0]*scale, offset+y*scale, offset+(run[0]+run[1])*scale, offset+(y+1)*scale)
+			draw.Draw(img, rect, foreground, image.Point{}, draw.Src)
+		}
+	}
+
+	if opts.Logo != nil {
+		x, y, side := c.logoRect()
+		lx, ly, lw, lh := fitRect(opts.Logo, x, y, side)
+		rect := image.Rect(
+			offset+int(math.Round(lx*float64(scale))), offset+int(math.Round(ly*float64(scale))),
+			offset+int(math.Round((lx+lw)*float64(scale))), offset+int(math.Round((ly+lh)*float64(scale))),
+		)
+		xdraw.CatmullRom.Scale(img, rect, opts.Logo, opts.Logo.Bounds(), xdraw.Over, nil)
+	}
+
+	if opts.Label != "" {
+		if err := drawPNGLabel(img, opts.Label, opts.Foreground, size, textScale); err != nil {
+			return err
+		}
+	}
+
+	return png.Encode(w, img)
+}
+
+// drawPNGLabel draws a caption centred in the band under the code, truncating it
+// to the image width. Latin-1 characters are drawn in Go Regular and the others
+// in the font set with pdf.SetFont; characters neither covers are drawn as '?'.
+func drawPNGLabel(img *image.RGBA, label string, fg color.RGBA, size, textScale int) error {
+	pixels := float64(captionSize * textScale)
+	regular, err := goRegular()
+	if err != nil {
+		return err
+	}
+	latin, err := opentype.NewFace(regular, &opentype.FaceOptions{Size: pixels, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return err
+	}
+	defer latin.Close()
+
+	// Pick the face of every character, pdf.Latin1 keeping those Go Regular draws
+	var unicode font.Face
+	f := pdf.CurrentFont()
+	if f != nil {
+		if unicode, err = f.Face(pixels); err != nil {
+			return err
+		}
+		defer unicode.Close()
+	}
+	runes := []rune(label)
+	replaced := []rune(pdf.Latin1(label))
+	faces := make([]font.Face, len(runes))
+	for i, r := range runes {
+		switch {
+		case replaced[i] == r:
+			faces[i] = latin
+		case f != nil && f.Covers(r):
+			faces[i] = unicode
+		default:
+			runes[i], faces[i] = '?', latin
+		}
+	}
+
+	// Drop characters until the caption and an ellipsis fit
+	maxWidth := fixed.I(size)
+	width := captionWidth(runes, faces)
+	if width > maxWidth {
+		ellipsis := font.MeasureString(latin, "...")
+		for len(runes) > 0 && width+ellipsis > maxWidth {
+			runes, faces = runes[:len(runes)-1], faces[:len(faces)-1]
+			width = captionWidth(runes, faces)
+		}
+		runes, faces = append(runes, '.', '.', '.'), append(faces, latin, latin, latin)
+		width += ellipsis
+	}
+	if len(runes) == 0 {
+		return nil
+	}
+
+	drawer := &font.Drawer{
+		Dst: img,
+		Src: image.NewUniform(fg),
+		Dot: fixed.Point26_6{
+			X: (maxWidth - width) / 2,
+			Y: fixed.I(size+2*textScale+captionSize*textScale) - latin.Metrics().Descent,
+		},
+	}
+	for i, r := range runes {
+		drawer.Face = faces[i]
+		drawer.DrawString(string(r))
+	}
+	return nil
+}
+
+// captionWidth measures characters drawn in their faces
+func captionWidth(runes []rune, faces []font.Face) fixed.Int26_6 {
+	var width fixed.Int26_6
+	for i, r := range runes {
+		advance, _ := faces[i].GlyphAdvance(r)
+		width += advance
+	}
+	return width
+}
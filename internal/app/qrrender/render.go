@@ -0,0 +1,116 @@
+// Package qrrender renders QR codes as PNG, SVG or PDF documents, with optional
+// colours, centre logo and caption, and lays codes out on printable label sheets.
+package qrrender
+
+import (
+	"errors"
+	"image"
+	"io"
+	"math"
+
+	"github.com/yougg/go-qrcode"
+)
+
+// code is an encoded QR code symbol surrounded by its quiet zone
+type code struct {
+	modules [][]bool // modules[y][x] is true for a dark module
+	size    int      // width of the symbol in modules, including the quiet zone
+	logoLo  int      // first module hidden behind the logo on each axis
+	logoHi  int      // module after the last one hidden behind the logo
+}
+
+// Render writes the QR code of content to w in the format selected by opts
+func Render(w io.Writer, content string, opts *Options) error {
+	c, err := encode(content, opts)
+	if err != nil {
+		return err
+	}
+
+	switch opts.Format {
+	case FormatPNG:
+		return writePNG(w, c, opts)
+	case FormatSVG:
+		return writeSVG(w, c, opts)
+	case FormatPDF:
+		return writePDF(w, c, opts)
+	}
+	return errors.New("unsupported format " + opts.Format)
+}
+
+// encode builds the module matrix of content and reserves the logo area
+func encode(content string, opts *Options) (*code, error) {
+	qr, err := qrcode.New(content, qrcode.Level(opts.Level))
+	if err != nil {
+		return nil, err
+	}
+	bitmap := qr.Bitmap()
+
+	symbol := len(bitmap)
+	size := symbol + 2*opts.Margin
+	modules := make([][]bool, size)
+	for y := range modules {
+		modules[y] = make([]bool, size)
+	}
+	for y, row := range bitmap {
+		copy(modules[y+opts.Margin][opts.Margin:], row)
+	}
+
+	c := &code{modules: modules, size: size}
+	if opts.Logo != nil {
+		// Keep the logo area centred by giving it the same parity as the symbol
+		side := int(math.Round(float64(symbol*opts.LogoSize) / 100))
+		if (symbol-side)%2 != 0 {
+			side++
+		}
+		c.logoLo = opts.Margin + (symbol-side)/2
+		c.logoHi = c.logoLo + side
+	}
+	return c, nil
+}
+
+// hidden reports whether the module at (x, y) is covered by the logo
+func (c *code) hidden(x, y int) bool {
+	return x >= c.logoLo && x < c.logoHi && y >= c.logoLo && y < c.logoHi
+}
+
+// runs returns the horizontal runs of visible dark modules in row y as
+// pairs of start column and length
+func (c *code) runs(y int) [][2]int {
+	var runs [][2]int
+	start := -1
+	for x := 0; x <= c.size; x++ {
+		dark := x < c.size && c.modules[y][x] && !c.hidden(x, y)
+		if dark && start < 0 {
+			start = x
+		}
+		if !dark && start >= 0 {
+			runs = append(runs, [2]int{start, x - start})
+			start = -1
+		}
+	}
+	return runs
+}
+
+// logoRect returns the area the logo is drawn in, in modules, keeping one
+// module of background around it when there is room
+func (c *code) logoRect() (x, y, side float64) {
+	side = float64(c.logoHi - c.logoLo)
+	pad := 0.0
+	if side > 4 {
+		pad = 1
+	}
+	return float64(c.logoLo) + pad, float64(c.logoLo) + pad, side - 2*pad
+}
+
+// fitRect returns the largest rectangle with the aspect ratio of img that fits
+// centred in the square at (x, y) with the given side
+func fitRect(img image.Image, x, y, side float64) (float64, float64, float64, float64) {
+	bounds := img.Bounds()
+	w, h := float64(bounds.Dx()), float64(bounds.Dy())
+	if w <= 0 || h <= 0 {
+		return x, y, 0, 0
+	}
+	scale := math.Min(side/w, side/h)
+	w, h = w*scale, h*scale
+	return x + (side-w)/2, y + (side-h)/2, w, h
+}
@@ -0,0 +1,246 @@
+package qrrender
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"cdk-office/internal/shared/pdf"
+	"cdk-office/internal/shared/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/yougg/go-qrcode"
+)
+
+// testLogo returns a small base64 encoded PNG
+func testLogo(t *testing.T) string {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, img))
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// TestParseConfig tests building rendering options from a batch config
+func TestParseConfig(t *testing.T) {
+	// Test the defaults are used for an empty config
+	t.Run("Defaults", func(t *testing.T) {
+		opts, err := ParseConfig(nil)
+		assert.NoError(t, err)
+		assert.Equal(t, DefaultOptions(), opts)
+	})
+
+	// Test every option is read
+	t.Run("AllOptions", func(t *testing.T) {
+		opts, err := ParseConfig(map[string]string{
+			"format":           "SVG",
+			"size":             "512",
+			"margin":           "2",
+			"foreground":       "#036",
+			"background":       "#fafafa",
+			"error_correction": "h",
+			"logo":             "data:image/png;base64," + testLogo(t),
+			"logo_size":        "25",
+			"label":            "{name}",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, FormatSVG, opts.Format)
+		assert.Equal(t, 512, opts.Size)
+		assert.Equal(t, 2, opts.Margin)
+		assert.Equal(t, color.RGBA{R: 0x00, G: 0x33, B: 0x66, A: 0xff}, opts.Foreground)
+		assert.Equal(t, color.RGBA{R: 0xfa, G: 0xfa, B: 0xfa, A: 0xff}, opts.Background)
+		assert.Equal(t, qrcode.Highest, opts.Level)
+		assert.NotNil(t, opts.Logo)
+		assert.Equal(t, 25, opts.LogoSize)
+		assert.Equal(t, "{name}", opts.Label)
+		assert.Equal(t, "image/svg+xml", opts.ContentType())
+	})
+
+	// Test invalid values are rejected
+	t.Run("InvalidValues", func(t *testing.T) {
+		cases := map[string]map[string]string{
+			`unsupported format "gif"`:                            {"format": "gif"},
+			"size must be a number between 64 and 4096":           {"size": "10"},
+			"margin must be a number between 0 and 16":            {"margin": "x"},
+			`invalid colour "#12345"`:                             {"foreground": "#12345"},
+			`invalid error_correction "X", must be L, M, Q or H`:  {"error_correction": "X"},
+			"logo must be base64 encoded":                         {"logo": "not base64!"},
+			"error_correction must be Q or H when a logo is used": {"logo": testLogo(t), "error_correction": "M"},
+			"label must be at most 200 characters":                {"label": strings.Repeat("a", 201)},
+		}
+		for message, config := range cases {
+			_, err := ParseConfig(config)
+			assert.EqualError(t, err, message)
+		}
+	})
+
+	// Test JSON configs may hold non-string values
+	t.Run("ParseConfigJSON", func(t *testing.T) {
+		opts, err := ParseConfigJSON(`{"size": 128, "format": "pdf"}`)
+		assert.NoError(t, err)
+		assert.Equal(t, 128, opts.Size)
+		assert.Equal(t, ".pdf", opts.Extension())
+
+		_, err = ParseConfigJSON("[]")
+		assert.EqualError(t, err, "config must be a JSON object")
+	})
+}
+
+// TestRender tests rendering a code in each format
+func TestRender(t *testing.T) {
+	// Test PNG output has the requested size and colours
+	t.Run("PNG", func(t *testing.T) {
+		opts := DefaultOptions()
+		opts.Foreground = color.RGBA{R: 0xff, A: 0xff}
+
+		var buf bytes.Buffer
+		assert.NoError(t, Render(&buf, "https://example.com", opts))
+		img, err := png.Decode(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, 256, img.Bounds().Dx())
+		assert.Equal(t, 256, img.Bounds().Dy())
+
+		r, g, b, _ := img.At(0, 0).RGBA()
+		assert.Equal(t, []uint32{0xffff, 0xffff, 0xffff}, []uint32{r, g, b})
+
+		hasForeground := false
+		bounds := img.Bounds()
+		for y := bounds.Min.Y; y < bounds.Max.Y && !hasForeground; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				if r, g, b, _ := img.At(x, y).RGBA(); r == 0xffff && g == 0 && b == 0 {
+					hasForeground = true
+					break
+				}
+			}
+		}
+		assert.True(t, hasForeground)
+	})
+
+	// Test a caption adds a band under the PNG code
+	t.Run("PNGLabel", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, Render(&buf, "content", DefaultOptions().WithLabel("Asset 1")))
+		img, err := png.Decode(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, 256, img.Bounds().Dx())
+		assert.Greater(t, img.Bounds().Dy(), 256)
+	})
+
+	// Test Chinese captions are drawn with the configured font, not as '?'
+	t.Run("PNGChineseLabel", func(t *testing.T) {
+		label := "会议室 A"
+		var fallback bytes.Buffer
+		assert.NoError(t, Render(&fallback, "content", DefaultOptions().WithLabel(label)))
+
+		font, err := pdf.LoadFont(testutils.CJKFontPath())
+		assert.NoError(t, err)
+		pdf.SetFont(font)
+		defer pdf.SetFont(nil)
+
+		var buf bytes.Buffer
+		assert.NoError(t, Render(&buf, "content", DefaultOptions().WithLabel(label)))
+		img, err := png.Decode(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, 256, img.Bounds().Dx())
+		assert.NotEqual(t, fallback.Bytes(), buf.Bytes())
+
+		// The box glyphs of the test font fill the middle of the caption band
+		dark := 0
+		middle := 256 + (img.Bounds().Dy()-256)/2
+		for x := 0; x < 256; x++ {
+			if r, _, _, _ := img.At(x, middle).RGBA(); r < 0x8000 {
+				dark++
+			}
+		}
+		assert.Greater(t, dark, 40)
+	})
+
+	// Test SVG output contains the code path, logo and escaped caption
+	t.Run("SVG", func(t *testing.T) {
+		opts, err := ParseConfig(map[string]string{"format": "svg", "logo": testLogo(t), "label": "A & B"})
+		assert.NoError(t, err)
+
+		var buf bytes.Buffer
+		assert.NoError(t, Render(&buf, "content", opts))
+		svg := buf.String()
+		assert.Contains(t, svg, `<svg xmlns="http://www.w3.org/2000/svg" width="256"`)
+		assert.Contains(t, svg, `<path fill="#000000" d="M`)
+		assert.Contains(t, svg, `href="data:image/png;base64,`)
+		assert.Contains(t, svg, "A &amp; B</text>")
+	})
+
+	// Test PDF output is a single page document
+	t.Run("PDF", func(t *testing.T) {
+		opts, err := ParseConfig(map[string]string{"format": "pdf", "logo": testLogo(t), "label": "(1)"})
+		assert.NoError(t, err)
+
+		var buf bytes.Buffer
+		assert.NoError(t, Render(&buf, "content", opts))
+		pdf := buf.String()
+		assert.True(t, strings.HasPrefix(pdf, "%PDF-1.4"))
+		assert.Contains(t, pdf, "/Count 1")
+		assert.Contains(t, pdf, "/Subtype /Image")
+		assert.True(t, strings.HasSuffix(pdf, "%%EOF\n"))
+	})
+}
+
+// TestWriteSheet tests laying out codes on print sheets
+func TestWriteSheet(t *testing.T) {
+	// Test invalid layouts are rejected
+	t.Run("InvalidLayout", func(t *testing.T) {
+		_, err := NewA4Layout(0, 8, 0)
+		assert.EqualError(t, err, "columns must be between 1 and 10")
+		_, err = NewA4Layout(3, 21, 0)
+		assert.EqualError(t, err, "rows must be between 1 and 20")
+		_, err = NewA4Layout(3, 8, 60)
+		assert.EqualError(t, err, "margin must be between 0 and 50mm")
+	})
+
+	// Test items overflow onto additional pages
+	t.Run("MultiplePages", func(t *testing.T) {
+		layout, err := NewA4Layout(3, 8, 10)
+		assert.NoError(t, err)
+
+		items := make([]SheetItem, 25)
+		for i := range items {
+			items[i] = SheetItem{Content: "https://example.com/" + string(rune('a'+i)), Label: "Item"}
+		}
+		var buf bytes.Buffer
+		assert.NoError(t, WriteSheet(&buf, items, DefaultOptions(), layout))
+		assert.True(t, strings.HasPrefix(buf.String(), "%PDF-1.4"))
+		assert.Contains(t, buf.String(), "/Count 2")
+		assert.Contains(t, buf.String(), "/MediaBox [0 0 595.28 841.89]")
+	})
+
+	// Test Chinese captions embed the configured font
+	t.Run("ChineseLabels", func(t *testing.T) {
+		font, err := pdf.LoadFont(testutils.CJKFontPath())
+		assert.NoError(t, err)
+		pdf.SetFont(font)
+		defer pdf.SetFont(nil)
+
+		layout, _ := NewA4Layout(3, 8, 10)
+		var buf bytes.Buffer
+		assert.NoError(t, WriteSheet(&buf, []SheetItem{{Content: "a", Label: "会议室 101"}, {Content: "b", Label: "张伟"}}, DefaultOptions(), layout))
+		assert.Contains(t, buf.String(), "/Subtype /Type0")
+		assert.Contains(t, buf.String(), "/CIDToGIDMap /Identity")
+
+		buf.Reset()
+		opts, err := ParseConfig(map[string]string{"format": "pdf", "label": "王芳"})
+		assert.NoError(t, err)
+		assert.NoError(t, Render(&buf, "content", opts))
+		assert.Contains(t, buf.String(), "/Subtype /Type0")
+	})
+
+	// Test an empty sheet is rejected
+	t.Run("NoItems", func(t *testing.T) {
+		layout, _ := NewA4Layout(3, 8, 0)
+		err := WriteSheet(&bytes.Buffer{}, nil, DefaultOptions(), layout)
+		assert.EqualError(t, err, "no items to print")
+	})
+}
@@ -0,0 +1,116 @@
+package qrrender
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"cdk-office/internal/shared/pdf"
+)
+
+// mmToPt converts millimetres to points
+const mmToPt = 72 / 25.4
+
+// Limits of the sheet layout
+const (
+	maxSheetColumns = 10
+	maxSheetRows    = 20
+)
+
+// SheetLayout describes a page divided into equally sized labels. Sizes are in points.
+type SheetLayout struct {
+	Columns    int
+	Rows       int
+	PageWidth  float64
+	PageHeight float64
+	Margin     float64 // blank border around the label grid
+	Padding    float64 // blank space inside each label
+}
+
+// SheetItem is one label on a print sheet
+type SheetItem struct {
+	Content string
+	Label   string
+}
+
+// NewA4Layout returns an A4 layout with the given grid and page margin in
+// millimetres. Each label keeps 2mm of padding.
+func NewA4Layout(columns, rows int, marginMM float64) (*SheetLayout, error) {
+	if columns < 1 || columns > maxSheetColumns {
+		return nil, fmt.Errorf("columns must be between 1 and %d", maxSheetColumns)
+	}
+	if rows < 1 || rows > maxSheetRows {
+		return nil, fmt.Errorf("rows must be between 1 and %d", maxSheetRows)
+	}
+	if marginMM < 0 || marginMM > 50 {
+		return nil, errors.New("margin must be between 0 and 50mm")
+	}
+	return &SheetLayout{
+		Columns:    columns,
+		Rows:       rows,
+		PageWidth:  pdf.A4Width,
+		PageHeight: pdf.A4Height,
+		Margin:     marginMM * mmToPt,
+		Padding:    2 * mmToPt,
+	}, nil
+}
+
+// WriteSheet writes a PDF with the items laid out across as many pages as
+// needed, one code per label with its caption underneath. The format and size
+// of opts are ignored since codes are drawn as vectors to fit the labels.
+func WriteSheet(w io.Writer, items []SheetItem, opts *Options, layout *SheetLayout) error {
+	if len(items) == 0 {
+		return errors.New("no items to print")
+	}
+
+	cellWidth := (layout.PageWidth - 2*layout.Margin) / float64(layout.Columns)
+	cellHeight := (layout.PageHeight - 2*layout.Margin) / float64(layout.Rows)
+	innerWidth := cellWidth - 2*layout.Padding
+	innerHeight := cellHeight - 2*layout.Padding
+
+	hasLabels := false
+	for _, item := range items {
+		if item.Label != "" {
+			hasLabels = true
+			break
+		}
+	}
+	fontSize := 0.0
+	band := 0.0
+	if hasLabels {
+		fontSize = math.Min(10, innerHeight*0.12)
+		band = fontSize * 1.6
+	}
+	side := math.Min(innerWidth, innerHeight-band)
+	if side <= 0 {
+		return errors.New("labels are too small for the layout")
+	}
+
+	perPage := layout.Columns * layout.Rows
+	doc := newPDFDocument(opts)
+	for start := 0; start < len(items); start += perPage {
+		canvas := pdf.NewCanvas(layout.PageHeight)
+		for i := start; i < len(items) && i < start+perPage; i++ {
+			column := (i - start) % layout.Columns
+			row := (i - start) / layout.Columns
+			cellX := layout.Margin + float64(column)*cellWidth
+			cellY := layout.Margin + float64(row)*cellHeight
+
+			c, err := encode(items[i].Content, opts)
+			if err != nil {
+				return err
+			}
+			// Centre the code and its caption vertically in the label
+			top := cellY + layout.Padding + (innerHeight-side-band)/2
+			drawCode(canvas, c, opts, cellX+(cellWidth-side)/2, top, side)
+			if items[i].Label != "" {
+				canvas.SetFill(opts.Foreground)
+				canvas.CenteredText(items[i].Label, pdf.Regular, fontSize, cellX+cellWidth/2, top+side+band*0.7, innerWidth)
+			}
+		}
+		doc.AddPage(layout.PageWidth, layout.PageHeight, canvas)
+	}
+
+	return doc.Write(w)
+}
@@ -0,0 +1,72 @@
+package qrrender
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+)
+
+// labelBand is the height of the caption band as a fraction of the code width
+const labelBand = 0.14
+
+// writeSVG writes a code as an SVG document. Coordinates are in modules and the
+// document is scaled to opts.Size pixels wide.
+func writeSVG(w io.Writer, c *code, opts *Options) error {
+	size := float64(c.size)
+	height := size
+	if opts.Label != "" {
+		height += size * labelBand
+	}
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(out, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%s" viewBox="0 0 %d %s" shape-rendering="crispEdges">`+"\n",
+		opts.Size, num(height*float64(opts.Size)/size), c.size, num(height))
+	fmt.Fprintf(out, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", hexColor(opts.Background))
+
+	fmt.Fprintf(out, `<path fill="%s" d="`, hexColor(opts.Foreground))
+	for y := 0; y < c.size; y++ {
+		for _, run := range c.runs(y) {
+			fmt.Fprintf(out, "M%d %dh%dv1h-%dz", run[0], y, run[1], run[1])
+		}
+	}
+	fmt.Fprint(out, `"/>`+"\n")
+
+	if opts.Logo != nil {
+		var logo bytes.Buffer
+		if err := png.Encode(&logo, opts.Logo); err != nil {
+			return err
+		}
+		x, y, side := c.logoRect()
+		fmt.Fprintf(out, `<image x="%s" y="%s" width="%s" height="%s" preserveAspectRatio="xMidYMid meet" href="data:image/png;base64,%s"/>`+"\n",
+			num(x), num(y), num(side), num(side), base64.StdEncoding.EncodeToString(logo.Bytes()))
+	}
+
+	if opts.Label != "" {
+		band := size * labelBand
+		fmt.Fprintf(out, `<text x="%s" y="%s" font-family="Helvetica, Arial, sans-serif" font-size="%s" text-anchor="middle" fill="%s">`,
+			num(size/2), num(size+band*0.7), num(band*0.6), hexColor(opts.Foreground))
+		xml.EscapeText(out, []byte(opts.Label))
+		fmt.Fprint(out, "</text>\n")
+	}
+
+	fmt.Fprint(out, "</svg>\n")
+	return out.Flush()
+}
+
+// hexColor formats a colour as #rrggbb
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// num formats a coordinate with at most three decimals
+func num(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+}
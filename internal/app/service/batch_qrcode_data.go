@@ -0,0 +1,306 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"cdk-office/internal/app/batchdata"
+	"cdk-office/internal/app/domain"
+	"cdk-office/internal/app/qrrender"
+	"cdk-office/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// Limits of the items built from imported data
+const (
+	maxItemNameLength = 100
+	maxItemURLLength  = 500
+	maxReportedErrors = 1000
+	previewItemCount  = 5
+)
+
+// BatchDataReport describes the validation of an imported data file
+type BatchDataReport struct {
+	Rows       int                       `json:"rows"`
+	Columns    []string                  `json:"columns"`
+	ErrorCount int                       `json:"error_count"`
+	Errors     []*BatchDataRowError      `json:"errors"` // at most the first 1000 errors
+	Preview    []*domain.BatchQRCodeItem `json:"preview"`
+}
+
+// BatchDataRowError describes a problem with one row of an imported data file
+type BatchDataRowError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportBatchData replaces the items of a pending batch with one item per row of
+// a CSV or XLSX file. The columns of each row are variables for the URL and name
+// templates and the caption, and the number of rows becomes the batch's count.
+// Every row is validated first; if any is invalid nothing is saved and the
+// report lists the errors. With dryRun the data is only validated.
+func (s *BatchQRCodeService) ImportBatchData(ctx context.Context, batchID, filename string, r io.Reader, dryRun bool) (*BatchDataReport, error) {
+	batch, err := s.findBatch(batchID, "failed to import batch data")
+	if err != nil {
+		return nil, err
+	}
+	if batch.Status != domain.BatchQRCodePending {
+		return nil, errBatchStarted
+	}
+
+	table, err := batchdata.Parse(filename, r)
+	if err != nil {
+		return nil, errors.New("invalid data file: " + err.Error())
+	}
+	if table.HasColumn("index") {
+		return nil, errors.New(`invalid data file: column "index" is reserved`)
+	}
+	templates, err := newBatchTemplates(batch, table.Columns)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &BatchDataReport{
+		Rows:    len(table.Rows),
+		Columns: table.Columns,
+		Errors:  []*BatchDataRowError{},
+	}
+	items := make([]*domain.BatchQRCodeItem, len(table.Rows))
+	for i, row := range table.Rows {
+		item, rowErrors := templates.newItem(batch, i+1, row.Values)
+		for _, rowError := range rowErrors {
+			rowError.Row = row.Number
+			report.ErrorCount++
+			if len(report.Errors) < maxReportedErrors {
+				report.Errors = append(report.Errors, rowError)
+			}
+		}
+		items[i] = item
+		if i < previewItemCount {
+			report.Preview = append(report.Preview, item)
+		}
+	}
+	if report.ErrorCount > 0 {
+		return report, errors.New("invalid data")
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	columns, _ := json.Marshal(table.Columns)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// Only replace the data while the batch has not been started meanwhile
+		result := tx.Table("batch_qr_codes").Where("id = ? AND status = ?", batchID, domain.BatchQRCodePending).
+			Updates(map[string]interface{}{
+				"count":      len(items),
+				"columns":    string(columns),
+				"updated_at": time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errBatchStarted
+		}
+		if err := tx.Where("batch_id = ?", batchID).Delete(&domain.BatchQRCodeItem{}).Error; err != nil {
+			return err
+		}
+		return tx.CreateInBatches(items, 500).Error
+	})
+	if errors.Is(err, errBatchStarted) {
+		return nil, err
+	}
+	if err != nil {
+		logger.Error("failed to import batch data", "error", err, "batch_id", batchID)
+		return nil, errors.New("failed to import batch data")
+	}
+
+	return report, nil
+}
+
+// errBatchStarted is returned when data is changed for a batch that was started
+var errBatchStarted = errors.New("batch QR code already started")
+
+// rebuildItems renders the items of a pending batch with imported data again,
+// after its templates changed
+func (s *BatchQRCodeService) rebuildItems(tx *gorm.DB, batch *BatchQRCode) error {
+	var columns []string
+	if err := json.Unmarshal([]byte(batch.Columns), &columns); err != nil || len(columns) == 0 {
+		return nil
+	}
+	templates, err := newBatchTemplates(batch, columns)
+	if err != nil {
+		return err
+	}
+
+	var items []*domain.BatchQRCodeItem
+	if err := tx.Where("batch_id = ?", batch.ID).Order("position").Find(&items).Error; err != nil {
+		return err
+	}
+	for _, item := range items {
+		rebuilt, rowErrors := templates.newItem(batch, item.Position, itemVariables(item))
+		if len(rowErrors) > 0 {
+			return fmt.Errorf("invalid data: item %d: %s", item.Position, rowErrors[0].Message)
+		}
+		if err := tx.Model(item).Updates(map[string]interface{}{
+			"name":       rebuilt.Name,
+			"content":    rebuilt.Content,
+			"url":        rebuilt.URL,
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// batchTemplates builds the items of a batch from its name and URL templates
+type batchTemplates struct {
+	name     string   // empty for names made of the prefix, batch name and index
+	url      string   // empty for placeholder URLs
+	required []string // data columns the templates refer to
+}
+
+// newBatchTemplates checks that the templates of a batch only refer to the data
+// columns and the built-in variables. Without a name template the items are
+// named by a "name" column, and without a URL template they use a "url" column.
+func newBatchTemplates(batch *BatchQRCode, columns []string) (*batchTemplates, error) {
+	known := make(map[string]bool, len(columns)+3)
+	for _, column := range columns {
+		known[column] = true
+	}
+
+	t := &batchTemplates{name: batch.NameTemplate, url: batch.URLTemplate}
+	if t.name == "" && known["name"] {
+		t.name = "{name}"
+	}
+	if t.url == "" && known["url"] {
+		t.url = "{url}"
+	}
+
+	// {name} refers to the item name everywhere but in the name template itself
+	known["index"] = true
+	if err := checkVariables(t.name, "name_template", known); err != nil {
+		return nil, err
+	}
+	known["name"] = true
+	if err := checkVariables(t.url, "url_template", known); err != nil {
+		return nil, err
+	}
+	if opts, err := qrrender.ParseConfigJSON(batch.Config); err == nil {
+		known["content"] = true
+		if err := checkVariables(opts.Label, "label", known); err != nil {
+			return nil, err
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, variable := range append(batchdata.Variables(t.name), batchdata.Variables(t.url)...) {
+		if !seen[variable] && contains(columns, variable) {
+			seen[variable] = true
+			t.required = append(t.required, variable)
+		}
+	}
+
+	return t, nil
+}
+
+// newItem builds the item at index of a batch from the values of a data row,
+// which is nil for batches without data. It also returns the problems found.
+func (t *batchTemplates) newItem(batch *BatchQRCode, index int, values map[string]string) (*domain.BatchQRCodeItem, []*BatchDataRowError) {
+	var rowErrors []*BatchDataRowError
+	for _, column := range t.required {
+		if values[column] == "" {
+			rowErrors = append(rowErrors, &BatchDataRowError{Column: column, Message: "value is required"})
+		}
+	}
+
+	vars := make(map[string]string, len(values)+2)
+	for column, value := range values {
+		vars[column] = value
+	}
+	vars["index"] = strconv.Itoa(index)
+
+	name := batchdata.Expand(t.name, vars)
+	if t.name == "" {
+		name = batch.Name
+		if batch.Prefix != "" {
+			name = batch.Prefix + "_" + name
+		}
+		name = fmt.Sprintf("%s_%d", name, index)
+	}
+	vars["name"] = name
+
+	url := batchdata.Expand(t.url, vars)
+	if t.url == "" {
+		url = fmt.Sprintf("https://example.com/%s/%d", batch.ID, index)
+	}
+
+	switch {
+	case name == "":
+		rowErrors = append(rowErrors, &BatchDataRowError{Message: "name is empty"})
+	case len([]rune(name)) > maxItemNameLength:
+		rowErrors = append(rowErrors, &BatchDataRowError{Message: fmt.Sprintf("name must be at most %d characters", maxItemNameLength)})
+	}
+	switch {
+	case url == "":
+		rowErrors = append(rowErrors, &BatchDataRowError{Message: "url is empty"})
+	case len(url) > maxItemURLLength:
+		rowErrors = append(rowErrors, &BatchDataRowError{Message: fmt.Sprintf("url must be at most %d characters", maxItemURLLength)})
+	case batch.Type == domain.QRCodeDynamic && !isRedirectURL(url):
+		rowErrors = append(rowErrors, &BatchDataRowError{Message: "url must be an absolute http or https URL"})
+	}
+
+	variables := []byte("{}")
+	if values != nil {
+		variables, _ = json.Marshal(values)
+	}
+	now := time.Now()
+	return &domain.BatchQRCodeItem{
+		ID:        fmt.Sprintf("%s_%05d", batch.ID, index),
+		BatchID:   batch.ID,
+		Position:  index,
+		Name:      name,
+		Content:   url,
+		URL:       url,
+		Variables: string(variables),
+		Status:    domain.BatchQRCodeItemPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, rowErrors
+}
+
+// itemVariables returns the data columns of an item
+func itemVariables(item *domain.BatchQRCodeItem) map[string]string {
+	values := make(map[string]string)
+	if item.Variables != "" {
+		json.Unmarshal([]byte(item.Variables), &values)
+	}
+	return values
+}
+
+// checkVariables checks that a template only uses known variables
+func checkVariables(template, field string, known map[string]bool) error {
+	for _, variable := range batchdata.Variables(template) {
+		if !known[variable] {
+			return fmt.Errorf("invalid template: unknown variable {%s} in %s", variable, field)
+		}
+	}
+	return nil
+}
+
+// contains reports whether values contains value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
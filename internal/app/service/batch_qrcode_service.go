@@ -1,19 +1,27 @@
 package service
 
 import (
+	"archive/zip"
 	"context"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"cdk-office/internal/app/batchdata"
+	"cdk-office/internal/app/dataexport"
 	"cdk-office/internal/app/domain"
+	"cdk-office/internal/app/qrrender"
 	"cdk-office/internal/shared/database"
 	"cdk-office/internal/shared/utils"
+	"cdk-office/pkg/config"
 	"cdk-office/pkg/logger"
-	"github.com/yougg/go-qrcode"
 	"gorm.io/gorm"
 )
 
@@ -24,65 +32,91 @@ type BatchQRCodeServiceInterface interface {
 	DeleteBatchQRCode(ctx context.Context, batchID string) error
 	ListBatchQRCodes(ctx context.Context, appID string, page, size int) ([]*BatchQRCode, int64, error)
 	GetBatchQRCode(ctx context.Context, batchID string) (*BatchQRCode, error)
-	GenerateBatchQRCodes(ctx context.Context, batchID string) ([]*domain.QRCode, error)
+	StartBatchGeneration(ctx context.Context, batchID string) (*BatchQRCode, error)
+	RetryFailedItems(ctx context.Context, batchID string) (*BatchQRCode, error)
+	WriteBatchArchive(ctx context.Context, batchID string, w io.Writer) error
+	WritePrintSheet(ctx context.Context, batchID string, layout *qrrender.SheetLayout, w io.Writer) error
+	ImportBatchData(ctx context.Context, batchID, filename string, r io.Reader, dryRun bool) (*BatchDataReport, error)
 }
 
-// BatchQRCodeService implements the BatchQRCodeServiceInterface
+// lockRenewInterval is the number of items a worker generates between lock renewals
+const lockRenewInterval = 25
+
+// BatchQRCodeService implements the BatchQRCodeServiceInterface. Generation runs
+// in background workers that claim queued batches from the database.
 type BatchQRCodeService struct {
-	db *gorm.DB
+	db           *gorm.DB
+	config       *config.BatchQRCodeConfig
+	qrCodeConfig *config.QRCodeConfig
+	wake         chan struct{}
 }
 
 // NewBatchQRCodeService creates a new instance of BatchQRCodeService
-func NewBatchQRCodeService() *BatchQRCodeService {
-	return &BatchQRCodeService{
-		db: database.GetDB(),
-	}
+func NewBatchQRCodeService(cfg *config.Config) *BatchQRCodeService {
+	return NewBatchQRCodeServiceWithDB(database.GetDB(), cfg)
 }
 
 // NewBatchQRCodeServiceWithDB creates a new instance of BatchQRCodeService with a specific database connection
-func NewBatchQRCodeServiceWithDB(db *gorm.DB) *BatchQRCodeService {
+func NewBatchQRCodeServiceWithDB(db *gorm.DB, cfg *config.Config) *BatchQRCodeService {
 	return &BatchQRCodeService{
-		db: db,
+		db:           db,
+		config:       &cfg.BatchQRCode,
+		qrCodeConfig: &cfg.QRCode,
+		wake:         make(chan struct{}, 1),
 	}
 }
 
 // CreateBatchQRCodeRequest represents the request for creating a batch QR code
 type CreateBatchQRCodeRequest struct {
-	AppID       string            `json:"app_id" binding:"required"`
-	Name        string            `json:"name" binding:"required"`
-	Description string            `json:"description"`
-	Prefix      string            `json:"prefix"`
-	Count       int               `json:"count" binding:"required"`
-	Type        string            `json:"type" binding:"required"` // static or dynamic
-	URLTemplate string            `json:"url_template"`
-	Config      map[string]string `json:"config"`
-	CreatedBy   string            `json:"created_by" binding:"required"`
+	AppID        string            `json:"app_id" binding:"required"`
+	Name         string            `json:"name" binding:"required"`
+	Description  string            `json:"description"`
+	Prefix       string            `json:"prefix"`
+	Count        int               `json:"count" binding:"required"`
+	Type         string            `json:"type" binding:"required"` // static or dynamic
+	URLTemplate  string            `json:"url_template"`
+	NameTemplate string            `json:"name_template"`
+	Config       map[string]string `json:"config"`
+	CreatedBy    string            `json:"created_by" binding:"required"`
 }
 
 // UpdateBatchQRCodeRequest represents the request for updating a batch QR code
 type UpdateBatchQRCodeRequest struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Prefix      string `json:"prefix"`
-	URLTemplate string `json:"url_template"`
-	Config      string `json:"config"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	Prefix       string `json:"prefix"`
+	URLTemplate  string `json:"url_template"`
+	NameTemplate string `json:"name_template"`
+	Config       string `json:"config"`
 }
 
 // BatchQRCode represents the batch QR code entity
 type BatchQRCode struct {
-	ID          string    `json:"id"`
-	AppID       string    `json:"app_id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Prefix      string    `json:"prefix"`
-	Count       int       `json:"count"`
-	Type        string    `json:"type"` // static or dynamic
-	URLTemplate string    `json:"url_template"`
-	Config      string    `json:"config"`
-	Status      string    `json:"status"` // pending, generating, completed, failed
-	CreatedBy   string    `json:"created_by"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID           string    `json:"id"`
+	AppID        string    `json:"app_id"`
+	Name         string    `json:"name"`
+	Description  string    `json:"description"`
+	Prefix       string    `json:"prefix"`
+	Count        int       `json:"count"`
+	Type         string    `json:"type"` // static or dynamic
+	URLTemplate  string    `json:"url_template"`
+	NameTemplate string    `json:"name_template"`
+	Columns      string    `json:"columns"` // columns of the imported data, as a JSON array
+	Config       string    `json:"config"`
+	Status       string    `json:"status"` // pending, queued, generating, completed, failed
+	CreatedBy    string    `json:"created_by"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	Progress *BatchQRCodeProgress `json:"progress,omitempty" gorm:"-"`
+}
+
+// BatchQRCodeProgress represents the generation progress of a batch
+type BatchQRCodeProgress struct {
+	Total   int64 `json:"total"`
+	Done    int64 `json:"done"`
+	Failed  int64 `json:"failed"`
+	Pending int64 `json:"pending"`
 }
 
 // CreateBatchQRCode creates a new batch QR code
@@ -97,20 +131,32 @@ func (s *BatchQRCodeService) CreateBatchQRCode(ctx context.Context, req *CreateB
 		return nil, errors.New("invalid count, must be between 1 and 10000")
 	}
 
+	// Validate rendering config
+	if _, err := qrrender.ParseConfig(req.Config); err != nil {
+		return nil, errors.New("invalid config: " + err.Error())
+	}
+	configJSON, err := json.Marshal(req.Config)
+	if err != nil || req.Config == nil {
+		configJSON = []byte("{}")
+	}
+
 	// Create new batch QR code
 	batch := &BatchQRCode{
-		ID:          utils.GenerateBatchID(),
-		AppID:       req.AppID,
-		Name:        req.Name,
-		Description: req.Description,
-		Prefix:      req.Prefix,
-		Count:       req.Count,
-		Type:        req.Type,
-		URLTemplate: req.URLTemplate,
-		Status:      "pending",
-		CreatedBy:   req.CreatedBy,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		ID:           utils.GenerateBatchID(),
+		AppID:        req.AppID,
+		Name:         req.Name,
+		Description:  req.Description,
+		Prefix:       req.Prefix,
+		Count:        req.Count,
+		Type:         req.Type,
+		URLTemplate:  req.URLTemplate,
+		NameTemplate: req.NameTemplate,
+		Columns:      "[]",
+		Config:       string(configJSON),
+		Status:       domain.BatchQRCodePending,
+		CreatedBy:    req.CreatedBy,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
 	}
 
 	// Save batch QR code to database
@@ -147,18 +193,40 @@ func (s *BatchQRCodeService) UpdateBatchQRCode(ctx context.Context, batchID stri
 		batch.Prefix = req.Prefix
 	}
 
-	if req.URLTemplate != "" {
+	templatesChanged := false
+	if req.URLTemplate != "" && req.URLTemplate != batch.URLTemplate {
 		batch.URLTemplate = req.URLTemplate
+		templatesChanged = true
+	}
+
+	if req.NameTemplate != "" && req.NameTemplate != batch.NameTemplate {
+		batch.NameTemplate = req.NameTemplate
+		templatesChanged = true
 	}
 
 	if req.Config != "" {
+		if _, err := qrrender.ParseConfigJSON(req.Config); err != nil {
+			return errors.New("invalid config: " + err.Error())
+		}
 		batch.Config = req.Config
 	}
 
 	batch.UpdatedAt = time.Now()
 
-	// Save updated batch QR code to database
-	if err := s.db.Table("batch_qr_codes").Save(&batch).Error; err != nil {
+	// Save updated batch QR code to database, rendering the items of imported
+	// data again while the batch has not been generated
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if templatesChanged && batch.Status == domain.BatchQRCodePending {
+			if err := s.rebuildItems(tx, &batch); err != nil {
+				return err
+			}
+		}
+		return tx.Table("batch_qr_codes").Save(&batch).Error
+	})
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "invalid template") || strings.HasPrefix(err.Error(), "invalid data:") {
+			return err
+		}
 		logger.Error("failed to update batch QR code", "error", err)
 		return errors.New("failed to update batch QR code")
 	}
@@ -177,9 +245,18 @@ func (s *BatchQRCodeService) DeleteBatchQRCode(ctx context.Context, batchID stri
 		logger.Error("failed to find batch QR code", "error", err)
 		return errors.New("failed to delete batch QR code")
 	}
+	if batch.Status == domain.BatchQRCodeQueued || batch.Status == domain.BatchQRCodeGenerating {
+		return errors.New("batch QR code is generating")
+	}
 
-	// Delete batch QR code from database
-	if err := s.db.Table("batch_qr_codes").Delete(&batch).Error; err != nil {
+	// Delete batch QR code and its items from database
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("batch_id = ?", batchID).Delete(&domain.BatchQRCodeItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Table("batch_qr_codes").Delete(&batch).Error
+	})
+	if err != nil {
 		logger.Error("failed to delete batch QR code", "error", err)
 		return errors.New("failed to delete batch QR code")
 	}
@@ -221,144 +298,595 @@ func (s *BatchQRCodeService) ListBatchQRCodes(ctx context.Context, appID string,
 	return batches, total, nil
 }
 
-// GetBatchQRCode retrieves a batch QR code by ID
+// GetBatchQRCode retrieves a batch QR code by ID together with its generation progress
 func (s *BatchQRCodeService) GetBatchQRCode(ctx context.Context, batchID string) (*BatchQRCode, error) {
-	var batch BatchQRCode
-	if err := s.db.Table("batch_qr_codes").Where("id = ?", batchID).First(&batch).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("batch QR code not found")
-		}
-		logger.Error("failed to find batch QR code", "error", err)
+	batch, err := s.findBatch(batchID, "failed to get batch QR code")
+	if err != nil {
+		return nil, err
+	}
+
+	progress, err := s.batchProgress(batch)
+	if err != nil {
+		logger.Error("failed to count batch QR code items", "error", err, "batch_id", batchID)
 		return nil, errors.New("failed to get batch QR code")
 	}
+	batch.Progress = progress
 
-	return &batch, nil
+	return batch, nil
+}
+
+// StartBatchGeneration queues a batch for generation by the background workers.
+// A batch that is already queued or generating is returned unchanged, and a
+// batch that finished with failed items has those items retried.
+func (s *BatchQRCodeService) StartBatchGeneration(ctx context.Context, batchID string) (*BatchQRCode, error) {
+	batch, err := s.findBatch(batchID, "failed to start batch QR code generation")
+	if err != nil {
+		return nil, err
+	}
+
+	switch batch.Status {
+	case domain.BatchQRCodeQueued, domain.BatchQRCodeGenerating:
+		return s.GetBatchQRCode(ctx, batchID)
+	case domain.BatchQRCodeCompleted:
+		return nil, errors.New("batch QR codes already generated")
+	case domain.BatchQRCodeFailed:
+		return s.RetryFailedItems(ctx, batchID)
+	}
+
+	if err := s.ensureItems(batch); err != nil {
+		if strings.HasPrefix(err.Error(), "invalid template") {
+			return nil, err
+		}
+		logger.Error("failed to create batch QR code items", "error", err, "batch_id", batchID)
+		return nil, errors.New("failed to start batch QR code generation")
+	}
+	if err := s.setStatus(batchID, domain.BatchQRCodeQueued); err != nil {
+		logger.Error("failed to queue batch QR code", "error", err, "batch_id", batchID)
+		return nil, errors.New("failed to start batch QR code generation")
+	}
+	s.notify()
+
+	return s.GetBatchQRCode(ctx, batchID)
+}
+
+// RetryFailedItems queues the failed items of a finished batch for another attempt.
+// Completed items are kept as they are.
+func (s *BatchQRCodeService) RetryFailedItems(ctx context.Context, batchID string) (*BatchQRCode, error) {
+	batch, err := s.findBatch(batchID, "failed to retry batch QR codes")
+	if err != nil {
+		return nil, err
+	}
+	if batch.Status == domain.BatchQRCodeQueued || batch.Status == domain.BatchQRCodeGenerating {
+		return nil, errors.New("batch QR code is generating")
+	}
+
+	result := s.db.Model(&domain.BatchQRCodeItem{}).
+		Where("batch_id = ? AND status = ?", batchID, domain.BatchQRCodeItemFailed).
+		Updates(map[string]interface{}{
+			"status":     domain.BatchQRCodeItemPending,
+			"error":      "",
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		logger.Error("failed to reset batch QR code items", "error", result.Error, "batch_id", batchID)
+		return nil, errors.New("failed to retry batch QR codes")
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("no failed items to retry")
+	}
+
+	if err := s.setStatus(batchID, domain.BatchQRCodeQueued); err != nil {
+		logger.Error("failed to queue batch QR code", "error", err, "batch_id", batchID)
+		return nil, errors.New("failed to retry batch QR codes")
+	}
+	s.notify()
+
+	return s.GetBatchQRCode(ctx, batchID)
 }
 
-// GenerateBatchQRCodes generates QR codes for a batch
+// GenerateBatchQRCodes generates the pending items of a batch synchronously and
+// returns the QR codes of every completed item. Items that already completed in
+// an earlier run are not generated again.
 func (s *BatchQRCodeService) GenerateBatchQRCodes(ctx context.Context, batchID string) ([]*domain.QRCode, error) {
-	// Find batch QR code by ID
-	var batch BatchQRCode
-	if err := s.db.Table("batch_qr_codes").Where("id = ?", batchID).First(&batch).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("batch QR code not found")
+	batch, err := s.findBatch(batchID, "failed to generate batch QR codes")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.ensureItems(batch); err != nil {
+		if strings.HasPrefix(err.Error(), "invalid template") {
+			return nil, err
 		}
-		logger.Error("failed to find batch QR code", "error", err)
+		logger.Error("failed to create batch QR code items", "error", err, "batch_id", batchID)
 		return nil, errors.New("failed to generate batch QR codes")
 	}
+	if err := s.setStatus(batchID, domain.BatchQRCodeGenerating); err != nil {
+		logger.Error("failed to update batch status", "error", err, "batch_id", batchID)
+	}
 
-	// Update batch status to generating
-	batch.Status = "generating"
-	batch.UpdatedAt = time.Now()
-	if err := s.db.Table("batch_qr_codes").Save(&batch).Error; err != nil {
-		logger.Error("failed to update batch status", "error", err)
+	if err := s.runBatch(ctx, batch, ""); err != nil {
+		return nil, err
 	}
 
-	// Generate QR codes
-	var qrCodes []*domain.QRCode
-	for i := 1; i <= batch.Count; i++ {
-		// Create QR code name
-		name := batch.Name
-		if batch.Prefix != "" {
-			name = batch.Prefix + "_" + name
+	return s.completedQRCodes(batchID)
+}
+
+// WriteBatchArchive streams a ZIP archive of a finished batch to w. The archive
+// holds the image of every completed item and a CSV manifest of all items. The
+// batch and its items are loaded before anything is written to w.
+func (s *BatchQRCodeService) WriteBatchArchive(ctx context.Context, batchID string, w io.Writer) error {
+	batch, err := s.findBatch(batchID, "failed to export batch QR codes")
+	if err != nil {
+		return err
+	}
+	if batch.Status != domain.BatchQRCodeCompleted && batch.Status != domain.BatchQRCodeFailed {
+		return errors.New("batch QR codes are not generated")
+	}
+
+	var items []*domain.BatchQRCodeItem
+	if err := s.db.Where("batch_id = ?", batchID).Order("position").Find(&items).Error; err != nil {
+		logger.Error("failed to find batch QR code items", "error", err, "batch_id", batchID)
+		return errors.New("failed to export batch QR codes")
+	}
+
+	qrCodes, err := s.completedQRCodesByID(batchID)
+	if err != nil {
+		return errors.New("failed to export batch QR codes")
+	}
+
+	archive := zip.NewWriter(w)
+	manifest := [][]string{{"position", "name", "content", "url", "status", "file", "error", "short_url"}}
+	for _, item := range items {
+		shortURL := ""
+		if qrCode, ok := qrCodes[item.QRCodeID]; ok && qrCode.ShortCode != nil {
+			shortURL = encodedContent(s.qrCodeConfig, qrCode)
+		}
+		file := ""
+		if item.Status == domain.BatchQRCodeItemCompleted {
+			file = archiveFileName(item)
+			if err := addArchiveFile(archive, file, item.ImagePath); err != nil {
+				logger.Error("failed to add QR code image to archive", "error", err, "item_id", item.ID)
+				return errors.New("failed to export batch QR codes")
+			}
+		}
+		// Names and contents come from imported data, keep spreadsheets from running them
+		manifest = append(manifest, []string{
+			strconv.Itoa(item.Position), dataexport.EscapeFormula(item.Name), dataexport.EscapeFormula(item.Content),
+			dataexport.EscapeFormula(item.URL), item.Status, dataexport.EscapeFormula(file), dataexport.EscapeFormula(item.Error), shortURL,
+		})
+	}
+
+	manifestFile, err := archive.Create("manifest.csv")
+	if err == nil {
+		err = csv.NewWriter(manifestFile).WriteAll(manifest)
+	}
+	if err == nil {
+		err = archive.Close()
+	}
+	if err != nil {
+		logger.Error("failed to write batch QR code archive", "error", err, "batch_id", batchID)
+		return errors.New("failed to export batch QR codes")
+	}
+
+	return nil
+}
+
+// WritePrintSheet writes an A4 PDF of the completed items of a finished batch,
+// laid out as a grid of labels with the given number of columns and rows
+func (s *BatchQRCodeService) WritePrintSheet(ctx context.Context, batchID string, layout *qrrender.SheetLayout, w io.Writer) error {
+	batch, err := s.findBatch(batchID, "failed to export batch QR codes")
+	if err != nil {
+		return err
+	}
+	if batch.Status != domain.BatchQRCodeCompleted && batch.Status != domain.BatchQRCodeFailed {
+		return errors.New("batch QR codes are not generated")
+	}
+
+	opts, err := qrrender.ParseConfigJSON(batch.Config)
+	if err != nil {
+		return errors.New("invalid config: " + err.Error())
+	}
+
+	var items []*domain.BatchQRCodeItem
+	if err := s.db.Where("batch_id = ? AND status = ?", batchID, domain.BatchQRCodeItemCompleted).
+		Order("position").Find(&items).Error; err != nil {
+		logger.Error("failed to find batch QR code items", "error", err, "batch_id", batchID)
+		return errors.New("failed to export batch QR codes")
+	}
+	if len(items) == 0 {
+		return errors.New("no generated items to print")
+	}
+	qrCodes, err := s.completedQRCodesByID(batchID)
+	if err != nil {
+		return errors.New("failed to export batch QR codes")
+	}
+
+	// Print what the images encode, which is the short link for dynamic codes
+	labels := make([]qrrender.SheetItem, len(items))
+	for i, item := range items {
+		content := item.Content
+		if qrCode, ok := qrCodes[item.QRCodeID]; ok {
+			content = encodedContent(s.qrCodeConfig, qrCode)
+		}
+		labels[i] = qrrender.SheetItem{Content: content, Label: itemLabel(opts.Label, item)}
+	}
+	if err := qrrender.WriteSheet(w, labels, opts, layout); err != nil {
+		logger.Error("failed to write batch QR code print sheet", "error", err, "batch_id", batchID)
+		return errors.New("failed to export batch QR codes")
+	}
+
+	return nil
+}
+
+// Start launches the generation workers and the recovery loop. Workers stop when ctx is cancelled.
+func (s *BatchQRCodeService) Start(ctx context.Context) {
+	hostname, _ := os.Hostname()
+	for i := 0; i < s.config.Workers; i++ {
+		workerID := fmt.Sprintf("%s-%d-qr-%d", hostname, os.Getpid(), i)
+		go s.work(ctx, workerID)
+	}
+	go s.recover(ctx)
+}
+
+// ProcessNext claims and generates the next queued batch. It reports whether a batch was run.
+func (s *BatchQRCodeService) ProcessNext(ctx context.Context, workerID string) (bool, error) {
+	var candidates []*BatchQRCode
+	if err := s.db.Table("batch_qr_codes").Select("id").
+		Where("status = ?", domain.BatchQRCodeQueued).
+		Order("updated_at").Limit(10).Find(&candidates).Error; err != nil {
+		logger.Error("failed to find queued batch QR codes", "error", err)
+		return false, errors.New("failed to claim batch QR code")
+	}
+
+	for _, candidate := range candidates {
+		lockedUntil := time.Now().Add(s.config.LockTimeout)
+		result := s.db.Table("batch_qr_codes").
+			Where("id = ? AND status = ?", candidate.ID, domain.BatchQRCodeQueued).
+			Updates(map[string]interface{}{
+				"status":       domain.BatchQRCodeGenerating,
+				"locked_by":    workerID,
+				"locked_until": lockedUntil,
+				"updated_at":   time.Now(),
+			})
+		if result.Error != nil {
+			logger.Error("failed to claim batch QR code", "error", result.Error, "batch_id", candidate.ID)
+			return false, errors.New("failed to claim batch QR code")
+		}
+		if result.RowsAffected == 0 {
+			// Another worker claimed the batch first
+			continue
+		}
+
+		batch, err := s.findBatch(candidate.ID, "failed to claim batch QR code")
+		if err != nil {
+			return false, err
 		}
-		name = fmt.Sprintf("%s_%d", name, i)
-
-		// Create QR code content
-		content := batch.URLTemplate
-		if content == "" {
-			content = fmt.Sprintf("https://example.com/%s/%d", batch.ID, i)
-		} else {
-			content = strings.ReplaceAll(batch.URLTemplate, "{index}", fmt.Sprintf("%d", i))
+		s.runBatch(ctx, batch, workerID)
+		return true, nil
+	}
+	return false, nil
+}
+
+// RequeueStaleBatches returns batches whose worker lock expired to the queue
+func (s *BatchQRCodeService) RequeueStaleBatches(ctx context.Context) error {
+	return s.db.Table("batch_qr_codes").
+		Where("status = ? AND locked_until < ?", domain.BatchQRCodeGenerating, time.Now()).
+		Updates(map[string]interface{}{
+			"status":       domain.BatchQRCodeQueued,
+			"locked_by":    "",
+			"locked_until": nil,
+			"updated_at":   time.Now(),
+		}).Error
+}
+
+// work generates batches until ctx is cancelled, sleeping while none are queued
+func (s *BatchQRCodeService) work(ctx context.Context, workerID string) {
+	for {
+		processed, _ := s.ProcessNext(ctx, workerID)
+		if processed {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-time.After(s.config.PollInterval):
 		}
+	}
+}
+
+// recover periodically requeues batches abandoned by crashed workers
+func (s *BatchQRCodeService) recover(ctx context.Context) {
+	ticker := time.NewTicker(s.config.LockTimeout / 2)
+	defer ticker.Stop()
+	for {
+		if err := s.RequeueStaleBatches(ctx); err != nil {
+			logger.Error("failed to requeue stale batch QR codes", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runBatch generates every item of a batch that has not completed yet, then
+// marks the batch completed, or failed if any item failed. A worker holding the
+// batch extends its lock as it goes.
+func (s *BatchQRCodeService) runBatch(ctx context.Context, batch *BatchQRCode, workerID string) error {
+	opts, err := qrrender.ParseConfigJSON(batch.Config)
+	if err != nil {
+		logger.Error("invalid batch QR code config", "error", err, "batch_id", batch.ID)
+		s.finishBatch(batch.ID, domain.BatchQRCodeFailed)
+		return errors.New("invalid config: " + err.Error())
+	}
 
-		// Create QR code URL
-		url := batch.URLTemplate
-		if url == "" {
-			url = fmt.Sprintf("https://example.com/%s/%d", batch.ID, i)
-		} else {
-			url = strings.ReplaceAll(batch.URLTemplate, "{index}", fmt.Sprintf("%d", i))
+	var items []*domain.BatchQRCodeItem
+	if err := s.db.Where("batch_id = ? AND status <> ?", batch.ID, domain.BatchQRCodeItemCompleted).
+		Order("position").Find(&items).Error; err != nil {
+		logger.Error("failed to find batch QR code items", "error", err, "batch_id", batch.ID)
+		s.finishBatch(batch.ID, domain.BatchQRCodeFailed)
+		return errors.New("failed to generate batch QR codes")
+	}
+
+	failed := 0
+	for i, item := range items {
+		if ctx.Err() != nil {
+			// Leave the batch generating so the recovery loop requeues it
+			return ctx.Err()
+		}
+		if err := s.generateItem(batch, item, opts); err != nil {
+			logger.Error("failed to generate batch QR code item", "error", err, "item_id", item.ID)
+			failed++
 		}
+		if workerID != "" && (i+1)%lockRenewInterval == 0 {
+			s.renewLock(batch.ID, workerID)
+		}
+	}
+
+	if failed > 0 {
+		s.finishBatch(batch.ID, domain.BatchQRCodeFailed)
+		return fmt.Errorf("failed to generate %d batch QR codes", failed)
+	}
+	s.finishBatch(batch.ID, domain.BatchQRCodeCompleted)
+	return nil
+}
 
-		// Create new QR code
-		qrCode := &domain.QRCode{
+// generateItem creates the QR code of an item and renders its image with the
+// batch's options. The QR code record is created once, so a retried item reuses it.
+func (s *BatchQRCodeService) generateItem(batch *BatchQRCode, item *domain.BatchQRCodeItem, opts *qrrender.Options) error {
+	item.Attempts++
+
+	var qrCode domain.QRCode
+	if item.QRCodeID == "" {
+		qrCode = domain.QRCode{
 			ID:        utils.GenerateQRCodeID(),
 			AppID:     batch.AppID,
-			Name:      name,
-			Content:   content,
+			BatchID:   batch.ID,
+			Name:      item.Name,
+			Content:   item.Content,
 			Type:      batch.Type,
-			URL:       url,
+			URL:       item.URL,
 			CreatedBy: batch.CreatedBy,
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
 		}
-
-		// Save QR code to database
-		if err := s.db.Create(qrCode).Error; err != nil {
-			logger.Error("failed to create QR code", "error", err)
-			// Update batch status to failed
-			batch.Status = "failed"
-			batch.UpdatedAt = time.Now()
-			if err := s.db.Table("batch_qr_codes").Save(&batch).Error; err != nil {
-				logger.Error("failed to update batch status", "error", err)
+		if batch.Type == domain.QRCodeDynamic {
+			qrCode.ShortCode = newShortCode()
+		}
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&qrCode).Error; err != nil {
+				return err
 			}
-			return nil, errors.New("failed to generate batch QR codes")
+			return tx.Model(item).Update("qr_code_id", qrCode.ID).Error
+		})
+		if err != nil {
+			return s.failItem(item, err)
 		}
+		item.QRCodeID = qrCode.ID
+	} else if err := s.db.Where("id = ?", item.QRCodeID).First(&qrCode).Error; err != nil {
+		return s.failItem(item, err)
+	}
 
-		qrCodes = append(qrCodes, qrCode)
+	imagePath, err := writeQRCodeImage(s.qrCodeConfig, &qrCode, opts.WithLabel(itemLabel(opts.Label, item)))
+	if err != nil {
+		return s.failItem(item, err)
 	}
 
-	// Generate QR code images
-	qrService := NewQRCodeService()
-	for _, qrCode := range qrCodes {
-		imagePath, err := qrService.GenerateQRCodeImage(ctx, qrCode.ID)
-		if err != nil {
-			logger.Error("failed to generate QR code image", "error", err)
-			// Continue with other QR codes even if one fails
-			continue
-		}
+	qrCode.ImagePath = imagePath
+	qrCode.UpdatedAt = time.Now()
+	if err := s.db.Save(&qrCode).Error; err != nil {
+		return s.failItem(item, err)
+	}
+
+	item.ImagePath = imagePath
+	item.Status = domain.BatchQRCodeItemCompleted
+	item.Error = ""
+	item.UpdatedAt = time.Now()
+	if err := s.db.Save(item).Error; err != nil {
+		logger.Error("failed to save batch QR code item", "error", err, "item_id", item.ID)
+		return err
+	}
+	return nil
+}
 
-		// Update QR code with image path
-		qrCode.ImagePath = imagePath
-		if err := s.db.Save(qrCode).Error; err != nil {
-			logger.Error("failed to update QR code with image path", "error", err)
+// failItem records the error of an item and returns it
+func (s *BatchQRCodeService) failItem(item *domain.BatchQRCodeItem, cause error) error {
+	item.Status = domain.BatchQRCodeItemFailed
+	item.Error = cause.Error()
+	item.UpdatedAt = time.Now()
+	if err := s.db.Save(item).Error; err != nil {
+		logger.Error("failed to save batch QR code item", "error", err, "item_id", item.ID)
+	}
+	return cause
+}
+
+// ensureItems creates the items of a batch on its first run, numbered from 1 to
+// the batch's count. Batches with imported data already have their items.
+func (s *BatchQRCodeService) ensureItems(batch *BatchQRCode) error {
+	var count int64
+	if err := s.db.Model(&domain.BatchQRCodeItem{}).Where("batch_id = ?", batch.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	templates, err := newBatchTemplates(batch, nil)
+	if err != nil {
+		return err
+	}
+	items := make([]*domain.BatchQRCodeItem, batch.Count)
+	for i := range items {
+		item, rowErrors := templates.newItem(batch, i+1, nil)
+		if len(rowErrors) > 0 {
+			return fmt.Errorf("invalid template: item %d: %s", i+1, rowErrors[0].Message)
 		}
+		items[i] = item
 	}
+	return s.db.CreateInBatches(items, 500).Error
+}
 
-	// Update batch status to completed
-	batch.Status = "completed"
-	batch.UpdatedAt = time.Now()
-	if err := s.db.Table("batch_qr_codes").Save(&batch).Error; err != nil {
-		logger.Error("failed to update batch status", "error", err)
+// batchProgress counts the items of a batch by status. Before the first run
+// every item of the batch is pending.
+func (s *BatchQRCodeService) batchProgress(batch *BatchQRCode) (*BatchQRCodeProgress, error) {
+	var counts []struct {
+		Status string
+		Count  int64
+	}
+	if err := s.db.Model(&domain.BatchQRCodeItem{}).Select("status, count(*) as count").
+		Where("batch_id = ?", batch.ID).Group("status").Scan(&counts).Error; err != nil {
+		return nil, err
 	}
 
+	progress := &BatchQRCodeProgress{}
+	for _, c := range counts {
+		progress.Total += c.Count
+		switch c.Status {
+		case domain.BatchQRCodeItemCompleted:
+			progress.Done = c.Count
+		case domain.BatchQRCodeItemFailed:
+			progress.Failed = c.Count
+		default:
+			progress.Pending += c.Count
+		}
+	}
+	if progress.Total == 0 {
+		progress.Total = int64(batch.Count)
+		progress.Pending = int64(batch.Count)
+	}
+	return progress, nil
+}
+
+// completedQRCodes returns the QR codes of the completed items of a batch in item order
+func (s *BatchQRCodeService) completedQRCodes(batchID string) ([]*domain.QRCode, error) {
+	var qrCodes []*domain.QRCode
+	if err := s.db.Table("qr_codes").
+		Joins("JOIN batch_qr_code_items ON batch_qr_code_items.qr_code_id = qr_codes.id").
+		Where("batch_qr_code_items.batch_id = ? AND batch_qr_code_items.status = ?", batchID, domain.BatchQRCodeItemCompleted).
+		Order("batch_qr_code_items.position").Find(&qrCodes).Error; err != nil {
+		logger.Error("failed to find batch QR codes", "error", err, "batch_id", batchID)
+		return nil, errors.New("failed to generate batch QR codes")
+	}
 	return qrCodes, nil
 }
 
+// completedQRCodesByID returns the QR codes of the completed items of a batch keyed by ID
+func (s *BatchQRCodeService) completedQRCodesByID(batchID string) (map[string]*domain.QRCode, error) {
+	qrCodes, err := s.completedQRCodes(batchID)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*domain.QRCode, len(qrCodes))
+	for _, qrCode := range qrCodes {
+		byID[qrCode.ID] = qrCode
+	}
+	return byID, nil
+}
+
+// findBatch loads a batch by ID, reporting failMsg on database errors
+func (s *BatchQRCodeService) findBatch(batchID, failMsg string) (*BatchQRCode, error) {
+	var batch BatchQRCode
+	if err := s.db.Table("batch_qr_codes").Where("id = ?", batchID).First(&batch).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("batch QR code not found")
+		}
+		logger.Error("failed to find batch QR code", "error", err)
+		return nil, errors.New(failMsg)
+	}
+	return &batch, nil
+}
 
+// setStatus updates the status of a batch
+func (s *BatchQRCodeService) setStatus(batchID, status string) error {
+	return s.db.Table("batch_qr_codes").Where("id = ?", batchID).Updates(map[string]interface{}{
+		"status":     status,
+		"updated_at": time.Now(),
+	}).Error
+}
 
-// generateQRCodeImage generates a QR code image and returns the image path
-func (s *BatchQRCodeService) generateQRCodeImage(qrCode *domain.QRCode) (string, error) {
-	// Create the directory for QR code images if it doesn't exist
-	imageDir := "/tmp/qrcodes"
-	if err := os.MkdirAll(imageDir, 0755); err != nil {
-		logger.Error("failed to create QR code directory", "error", err)
-		return "", errors.New("failed to create QR code directory")
+// finishBatch sets the final status of a batch and releases its worker lock
+func (s *BatchQRCodeService) finishBatch(batchID, status string) {
+	if err := s.db.Table("batch_qr_codes").Where("id = ?", batchID).Updates(map[string]interface{}{
+		"status":       status,
+		"locked_by":    "",
+		"locked_until": nil,
+		"updated_at":   time.Now(),
+	}).Error; err != nil {
+		logger.Error("failed to update batch status", "error", err, "batch_id", batchID)
 	}
+}
 
-	// Generate QR code image using the go-qrcode library
-	qr, err := qrcode.New(qrCode.Content, qrcode.Level(qrcode.High))
-	if err != nil {
-		logger.Error("failed to create QR code", "error", err)
-		return "", errors.New("failed to generate QR code image")
+// renewLock extends the lock a worker holds on a batch
+func (s *BatchQRCodeService) renewLock(batchID, workerID string) {
+	if err := s.db.Table("batch_qr_codes").Where("id = ? AND locked_by = ?", batchID, workerID).
+		Update("locked_until", time.Now().Add(s.config.LockTimeout)).Error; err != nil {
+		logger.Error("failed to renew batch QR code lock", "error", err, "batch_id", batchID)
 	}
+}
 
-	// Save the QR code image to a file
-	imagePath := filepath.Join(imageDir, qrCode.ID+".png")
-	if err := qr.WriteFile(imagePath); err != nil {
-		logger.Error("failed to save QR code image", "error", err)
-		return "", errors.New("failed to save QR code image")
+// notify wakes an idle worker
+func (s *BatchQRCodeService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
 	}
+}
+
+// itemLabel expands the caption template of an item with its data columns and
+// the {name}, {index} and {content} variables
+func itemLabel(template string, item *domain.BatchQRCodeItem) string {
+	values := itemVariables(item)
+	values["name"] = item.Name
+	values["index"] = strconv.Itoa(item.Position)
+	values["content"] = item.Content
+	return batchdata.Expand(template, values)
+}
+
+// archiveFileName returns the path of an item's image inside the batch archive
+func archiveFileName(item *domain.BatchQRCodeItem) string {
+	name := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, item.Name)
+	return fmt.Sprintf("images/%05d_%s%s", item.Position, name, filepath.Ext(item.ImagePath))
+}
 
-	return imagePath, nil
-}
\ No newline at end of file
+// addArchiveFile copies the file at path into the archive under name
+func addArchiveFile(archive *zip.Writer, name, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	entry, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, file)
+	return err
+}
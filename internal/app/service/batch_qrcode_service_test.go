@@ -1,11 +1,21 @@
 package service
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"cdk-office/internal/app/domain"
+	"cdk-office/internal/app/qrrender"
 	"cdk-office/internal/shared/testutils"
+	"cdk-office/pkg/config"
 )
 
 // TestBatchQRCodeService tests the BatchQRCodeService
@@ -14,7 +24,7 @@ func TestBatchQRCodeService(t *testing.T) {
 	testDB := testutils.SetupTestDB()
 
 	// Create batch QR code service with database connection
-	batchQRCodeService := NewBatchQRCodeServiceWithDB(testDB)
+	batchQRCodeService := NewBatchQRCodeServiceWithDB(testDB, config.Default())
 
 	// Test CreateBatchQRCode
 	t.Run("CreateBatchQRCode", func(t *testing.T) {
@@ -154,7 +164,7 @@ func TestBatchQRCodeServiceAdditional(t *testing.T) {
 	testDB := testutils.SetupTestDB()
 
 	// Create batch QR code service with database connection
-	batchQRCodeService := NewBatchQRCodeServiceWithDB(testDB)
+	batchQRCodeService := NewBatchQRCodeServiceWithDB(testDB, config.Default())
 
 	// Test CreateBatchQRCode with invalid type
 	t.Run("CreateBatchQRCodeInvalidType", func(t *testing.T) {
@@ -374,4 +384,395 @@ func TestBatchQRCodeServiceAdditional(t *testing.T) {
 			assert.Equal(t, "batch QR code not found", err.Error())
 		}
 	})
-}
\ No newline at end of file
+}
+// TestBatchQRCodeGenerationJob tests background generation, retries and archive export
+func TestBatchQRCodeGenerationJob(t *testing.T) {
+	// Set up test environment
+	testDB := testutils.SetupTestDB()
+
+	// Create batch QR code service with database connection
+	batchQRCodeService := NewBatchQRCodeServiceWithDB(testDB, config.Default())
+	ctx := context.Background()
+
+	batch, err := batchQRCodeService.CreateBatchQRCode(ctx, &CreateBatchQRCodeRequest{
+		AppID:       "app_job",
+		Name:        "Job Batch",
+		Prefix:      "JOB",
+		Count:       3,
+		Type:        "static",
+		URLTemplate: "https://example.com/assets/{index}",
+		CreatedBy:   "user_123",
+	})
+	assert.NoError(t, err)
+
+	// Test StartBatchGeneration queues the batch without generating it
+	t.Run("StartBatchGeneration", func(t *testing.T) {
+		queued, err := batchQRCodeService.StartBatchGeneration(ctx, batch.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.BatchQRCodeQueued, queued.Status)
+		assert.Equal(t, int64(3), queued.Progress.Total)
+		assert.Equal(t, int64(3), queued.Progress.Pending)
+
+		// Starting again returns the queued batch
+		again, err := batchQRCodeService.StartBatchGeneration(ctx, batch.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.BatchQRCodeQueued, again.Status)
+	})
+
+	// Test ProcessNext generates the queued batch
+	t.Run("ProcessNext", func(t *testing.T) {
+		processed, err := batchQRCodeService.ProcessNext(ctx, "worker-1")
+		assert.NoError(t, err)
+		assert.True(t, processed)
+
+		generated, err := batchQRCodeService.GetBatchQRCode(ctx, batch.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.BatchQRCodeCompleted, generated.Status)
+		assert.Equal(t, int64(3), generated.Progress.Done)
+		assert.Equal(t, int64(0), generated.Progress.Pending)
+
+		var items []*domain.BatchQRCodeItem
+		testDB.Where("batch_id = ?", batch.ID).Order("position").Find(&items)
+		assert.Len(t, items, 3)
+		assert.Equal(t, "JOB_Job Batch_2", items[1].Name)
+		assert.Equal(t, "https://example.com/assets/2", items[1].Content)
+		assert.NotEmpty(t, items[1].QRCodeID)
+		assert.NotEmpty(t, items[1].ImagePath)
+
+		// Nothing is left in the queue
+		processed, err = batchQRCodeService.ProcessNext(ctx, "worker-1")
+		assert.NoError(t, err)
+		assert.False(t, processed)
+
+		_, err = batchQRCodeService.StartBatchGeneration(ctx, batch.ID)
+		assert.Error(t, err)
+		assert.Equal(t, "batch QR codes already generated", err.Error())
+	})
+
+	// Test RetryFailedItems only regenerates failed items
+	t.Run("RetryFailedItems", func(t *testing.T) {
+		_, err := batchQRCodeService.RetryFailedItems(ctx, batch.ID)
+		assert.Error(t, err)
+		assert.Equal(t, "no failed items to retry", err.Error())
+
+		testDB.Model(&domain.BatchQRCodeItem{}).Where("batch_id = ? AND position = ?", batch.ID, 2).
+			Updates(map[string]interface{}{"status": domain.BatchQRCodeItemFailed, "error": "disk full"})
+		testDB.Table("batch_qr_codes").Where("id = ?", batch.ID).Update("status", domain.BatchQRCodeFailed)
+
+		retried, err := batchQRCodeService.RetryFailedItems(ctx, batch.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.BatchQRCodeQueued, retried.Status)
+		assert.Equal(t, int64(1), retried.Progress.Pending)
+
+		processed, err := batchQRCodeService.ProcessNext(ctx, "worker-1")
+		assert.NoError(t, err)
+		assert.True(t, processed)
+
+		var items []*domain.BatchQRCodeItem
+		testDB.Where("batch_id = ?", batch.ID).Order("position").Find(&items)
+		assert.Equal(t, 1, items[0].Attempts)
+		assert.Equal(t, 2, items[1].Attempts)
+		assert.Equal(t, domain.BatchQRCodeItemCompleted, items[1].Status)
+		assert.Empty(t, items[1].Error)
+
+		// The retried item reuses its QR code
+		var count int64
+		testDB.Model(&domain.QRCode{}).Where("app_id = ?", "app_job").Count(&count)
+		assert.Equal(t, int64(3), count)
+	})
+
+	// Test WriteBatchArchive writes the images and the manifest
+	t.Run("WriteBatchArchive", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := batchQRCodeService.WriteBatchArchive(ctx, batch.ID, &buf)
+		assert.NoError(t, err)
+
+		archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		assert.NoError(t, err)
+		var names []string
+		for _, file := range archive.File {
+			names = append(names, file.Name)
+		}
+		assert.Equal(t, []string{
+			"images/00001_JOB_Job Batch_1.png",
+			"images/00002_JOB_Job Batch_2.png",
+			"images/00003_JOB_Job Batch_3.png",
+			"manifest.csv",
+		}, names)
+
+		manifestFile, err := archive.Open("manifest.csv")
+		assert.NoError(t, err)
+		records, err := csv.NewReader(manifestFile).ReadAll()
+		assert.NoError(t, err)
+		assert.Len(t, records, 4)
+		assert.Equal(t, "https://example.com/assets/3", records[3][2])
+		assert.Equal(t, domain.BatchQRCodeItemCompleted, records[3][4])
+
+		// Imported names that look like formulas are written as text
+		assert.NoError(t, testDB.Model(&domain.BatchQRCodeItem{}).Where("batch_id = ? AND position = ?", batch.ID, 1).
+			Update("name", "=HYPERLINK(\"https://evil.example\")").Error)
+		buf.Reset()
+		assert.NoError(t, batchQRCodeService.WriteBatchArchive(ctx, batch.ID, &buf))
+		archive, err = zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		assert.NoError(t, err)
+		manifestFile, err = archive.Open("manifest.csv")
+		assert.NoError(t, err)
+		records, err = csv.NewReader(manifestFile).ReadAll()
+		assert.NoError(t, err)
+		assert.Equal(t, `'=HYPERLINK("https://evil.example")`, records[1][1])
+	})
+
+	// Test WriteBatchArchive rejects batches that were not generated
+	t.Run("WriteBatchArchiveNotGenerated", func(t *testing.T) {
+		pending, err := batchQRCodeService.CreateBatchQRCode(ctx, &CreateBatchQRCodeRequest{
+			AppID:     "app_job",
+			Name:      "Pending Batch",
+			Count:     1,
+			Type:      "static",
+			CreatedBy: "user_123",
+		})
+		assert.NoError(t, err)
+
+		var buf bytes.Buffer
+		err = batchQRCodeService.WriteBatchArchive(ctx, pending.ID, &buf)
+		assert.Error(t, err)
+		assert.Equal(t, "batch QR codes are not generated", err.Error())
+	})
+
+	// Test RequeueStaleBatches requeues batches whose worker lock expired
+	t.Run("RequeueStaleBatches", func(t *testing.T) {
+		stale, err := batchQRCodeService.CreateBatchQRCode(ctx, &CreateBatchQRCodeRequest{
+			AppID:     "app_job",
+			Name:      "Stale Batch",
+			Count:     1,
+			Type:      "static",
+			CreatedBy: "user_123",
+		})
+		assert.NoError(t, err)
+		testDB.Table("batch_qr_codes").Where("id = ?", stale.ID).Updates(map[string]interface{}{
+			"status":       domain.BatchQRCodeGenerating,
+			"locked_by":    "crashed-worker",
+			"locked_until": time.Now().Add(-time.Minute),
+		})
+
+		err = batchQRCodeService.RequeueStaleBatches(ctx)
+		assert.NoError(t, err)
+
+		requeued, err := batchQRCodeService.GetBatchQRCode(ctx, stale.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.BatchQRCodeQueued, requeued.Status)
+
+		err = batchQRCodeService.DeleteBatchQRCode(ctx, stale.ID)
+		assert.Error(t, err)
+		assert.Equal(t, "batch QR code is generating", err.Error())
+	})
+}
+
+// TestBatchQRCodeRendering tests that batches are rendered with their config
+func TestBatchQRCodeRendering(t *testing.T) {
+	// Set up test environment
+	testDB := testutils.SetupTestDB()
+
+	// Create batch QR code service with database connection
+	batchQRCodeService := NewBatchQRCodeServiceWithDB(testDB, config.Default())
+	ctx := context.Background()
+
+	// Test CreateBatchQRCode rejects invalid rendering options
+	t.Run("CreateBatchQRCodeInvalidConfig", func(t *testing.T) {
+		_, err := batchQRCodeService.CreateBatchQRCode(ctx, &CreateBatchQRCodeRequest{
+			AppID:     "app_render",
+			Name:      "Invalid Batch",
+			Count:     1,
+			Type:      "static",
+			Config:    map[string]string{"format": "gif"},
+			CreatedBy: "user_123",
+		})
+		assert.Error(t, err)
+		assert.Equal(t, `invalid config: unsupported format "gif"`, err.Error())
+	})
+
+	batch, err := batchQRCodeService.CreateBatchQRCode(ctx, &CreateBatchQRCodeRequest{
+		AppID:     "app_render",
+		Name:      "Assets",
+		Count:     4,
+		Type:      "static",
+		Config:    map[string]string{"format": "svg", "foreground": "#336699", "label": "Asset {index}"},
+		CreatedBy: "user_123",
+	})
+	assert.NoError(t, err)
+
+	// Test WritePrintSheet rejects batches that were not generated
+	t.Run("WritePrintSheetNotGenerated", func(t *testing.T) {
+		layout, _ := qrrender.NewA4Layout(3, 8, 0)
+		var buf bytes.Buffer
+		err := batchQRCodeService.WritePrintSheet(ctx, batch.ID, layout, &buf)
+		assert.Error(t, err)
+		assert.Equal(t, "batch QR codes are not generated", err.Error())
+	})
+
+	// Test the generated images use the configured format, colour and caption
+	t.Run("GenerateSVG", func(t *testing.T) {
+		_, err := batchQRCodeService.StartBatchGeneration(ctx, batch.ID)
+		assert.NoError(t, err)
+		processed, err := batchQRCodeService.ProcessNext(ctx, "worker-1")
+		assert.NoError(t, err)
+		assert.True(t, processed)
+
+		var item domain.BatchQRCodeItem
+		testDB.Where("batch_id = ? AND position = ?", batch.ID, 2).First(&item)
+		assert.Equal(t, ".svg", filepath.Ext(item.ImagePath))
+
+		data, err := os.ReadFile(item.ImagePath)
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), `fill="#336699"`)
+		assert.Contains(t, string(data), "Asset 2</text>")
+	})
+
+	// Test WritePrintSheet lays out the generated codes on A4 pages
+	t.Run("WritePrintSheet", func(t *testing.T) {
+		layout, _ := qrrender.NewA4Layout(2, 1, 10)
+		var buf bytes.Buffer
+		err := batchQRCodeService.WritePrintSheet(ctx, batch.ID, layout, &buf)
+		assert.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")))
+		assert.Contains(t, buf.String(), "/Count 2")
+	})
+}
+
+// TestBatchQRCodeData tests batches whose items come from an imported data file
+func TestBatchQRCodeData(t *testing.T) {
+	// Set up test environment
+	testDB := testutils.SetupTestDB()
+
+	// Create batch QR code service with database connection
+	batchQRCodeService := NewBatchQRCodeServiceWithDB(testDB, config.Default())
+	ctx := context.Background()
+
+	batch, err := batchQRCodeService.CreateBatchQRCode(ctx, &CreateBatchQRCodeRequest{
+		AppID:        "app_data",
+		Name:         "Assets",
+		Count:        1,
+		Type:         "dynamic",
+		URLTemplate:  "https://assets.example.com/{asset_no}",
+		NameTemplate: "{room}-{asset_no}",
+		Config:       map[string]string{"format": "svg", "label": "{room} #{index}"},
+		CreatedBy:    "user_123",
+	})
+	assert.NoError(t, err)
+
+	data := "\xef\xbb\xbfAsset No,Room\nA-1,Lab\n\nA-2,Office\n"
+
+	// Test a file with an unsupported extension is rejected
+	t.Run("ImportInvalidFile", func(t *testing.T) {
+		_, err := batchQRCodeService.ImportBatchData(ctx, batch.ID, "assets.txt", strings.NewReader(data), false)
+		assert.Error(t, err)
+		assert.Equal(t, "invalid data file: file must be a .csv or .xlsx file", err.Error())
+	})
+
+	// Test templates may only use the columns of the file
+	t.Run("ImportUnknownVariable", func(t *testing.T) {
+		_, err := batchQRCodeService.ImportBatchData(ctx, batch.ID, "assets.csv", strings.NewReader("asset_no\nA-1\n"), false)
+		assert.Error(t, err)
+		assert.Equal(t, "invalid template: unknown variable {room} in name_template", err.Error())
+	})
+
+	// Test invalid rows are reported with their row numbers and nothing is saved
+	t.Run("ImportInvalidRows", func(t *testing.T) {
+		report, err := batchQRCodeService.ImportBatchData(ctx, batch.ID, "assets.csv", strings.NewReader("asset_no,room\nA-1,Lab\n,Office\n"), false)
+		assert.Error(t, err)
+		assert.Equal(t, "invalid data", err.Error())
+		assert.Equal(t, 1, report.ErrorCount)
+		assert.Equal(t, &BatchDataRowError{Row: 3, Column: "asset_no", Message: "value is required"}, report.Errors[0])
+
+		var count int64
+		testDB.Model(&domain.BatchQRCodeItem{}).Where("batch_id = ?", batch.ID).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
+	// Test a dry run validates the file without saving it
+	t.Run("ImportDryRun", func(t *testing.T) {
+		report, err := batchQRCodeService.ImportBatchData(ctx, batch.ID, "assets.csv", strings.NewReader(data), true)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, report.Rows)
+		assert.Equal(t, []string{"asset_no", "room"}, report.Columns)
+		assert.Equal(t, "Lab-A-1", report.Preview[0].Name)
+		assert.Equal(t, "https://assets.example.com/A-2", report.Preview[1].URL)
+
+		var count int64
+		testDB.Model(&domain.BatchQRCodeItem{}).Where("batch_id = ?", batch.ID).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
+	// Test the import replaces the items and the count of the batch
+	t.Run("Import", func(t *testing.T) {
+		_, err := batchQRCodeService.ImportBatchData(ctx, batch.ID, "assets.csv", strings.NewReader(data), false)
+		assert.NoError(t, err)
+
+		updated, err := batchQRCodeService.GetBatchQRCode(ctx, batch.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, updated.Count)
+		assert.Equal(t, int64(2), updated.Progress.Total)
+	})
+
+	// Test changing a template renders the imported items again
+	t.Run("UpdateTemplate", func(t *testing.T) {
+		err := batchQRCodeService.UpdateBatchQRCode(ctx, batch.ID, &UpdateBatchQRCodeRequest{
+			URLTemplate: "https://assets.example.com/{room}/{asset_no}",
+		})
+		assert.NoError(t, err)
+
+		var item domain.BatchQRCodeItem
+		testDB.Where("batch_id = ? AND position = ?", batch.ID, 2).First(&item)
+		assert.Equal(t, "https://assets.example.com/Office/A-2", item.URL)
+
+		err = batchQRCodeService.UpdateBatchQRCode(ctx, batch.ID, &UpdateBatchQRCodeRequest{NameTemplate: "{floor}"})
+		assert.Error(t, err)
+		assert.Equal(t, "invalid template: unknown variable {floor} in name_template", err.Error())
+	})
+
+	// Test the generated codes use the row variables
+	t.Run("Generate", func(t *testing.T) {
+		_, err := batchQRCodeService.StartBatchGeneration(ctx, batch.ID)
+		assert.NoError(t, err)
+		processed, err := batchQRCodeService.ProcessNext(ctx, "worker-1")
+		assert.NoError(t, err)
+		assert.True(t, processed)
+
+		var item domain.BatchQRCodeItem
+		testDB.Where("batch_id = ? AND position = ?", batch.ID, 2).First(&item)
+		assert.Equal(t, domain.BatchQRCodeItemCompleted, item.Status)
+		assert.Equal(t, "Office-A-2", item.Name)
+
+		image, err := os.ReadFile(item.ImagePath)
+		assert.NoError(t, err)
+		assert.Contains(t, string(image), "Office #2</text>")
+
+		var qrCode domain.QRCode
+		testDB.Where("id = ?", item.QRCodeID).First(&qrCode)
+		assert.Equal(t, "https://assets.example.com/Office/A-2", qrCode.URL)
+	})
+
+	// Test data can not be imported once the batch was started
+	t.Run("ImportStarted", func(t *testing.T) {
+		_, err := batchQRCodeService.ImportBatchData(ctx, batch.ID, "assets.csv", strings.NewReader(data), false)
+		assert.Error(t, err)
+		assert.Equal(t, "batch QR code already started", err.Error())
+	})
+
+	// Test batches without data can only use the built-in variables
+	t.Run("GenerateUnknownVariable", func(t *testing.T) {
+		countBatch, err := batchQRCodeService.CreateBatchQRCode(ctx, &CreateBatchQRCodeRequest{
+			AppID:       "app_data",
+			Name:        "Count Batch",
+			Count:       2,
+			Type:        "static",
+			URLTemplate: "https://example.com/{asset_no}",
+			CreatedBy:   "user_123",
+		})
+		assert.NoError(t, err)
+
+		_, err = batchQRCodeService.StartBatchGeneration(ctx, countBatch.ID)
+		assert.Error(t, err)
+		assert.Equal(t, "invalid template: unknown variable {asset_no} in url_template", err.Error())
+	})
+}
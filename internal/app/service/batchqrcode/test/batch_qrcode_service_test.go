@@ -8,6 +8,7 @@ import (
 	"cdk-office/internal/app/domain"
 	"cdk-office/internal/app/service"
 	"cdk-office/internal/shared/testutils"
+	"cdk-office/pkg/config"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,7 +17,7 @@ func TestBatchQRCodeService_CreateBatchQRCode(t *testing.T) {
 	db := testutils.SetupTestDB()
 	defer db.Migrator().DropTable(&domain.BatchQRCode{}, &domain.BatchQRCodeItem{}, &domain.Application{})
 
-	batchQRCodeService := service.NewBatchQRCodeServiceWithDB(db)
+	batchQRCodeService := service.NewBatchQRCodeServiceWithDB(db, config.Default())
 
 	// Create a test application first
 	app := &domain.Application{
@@ -160,7 +161,7 @@ func TestBatchQRCodeService_UpdateBatchQRCode(t *testing.T) {
 	db := testutils.SetupTestDB()
 	defer db.Migrator().DropTable(&domain.BatchQRCode{}, &domain.BatchQRCodeItem{}, &domain.Application{})
 
-	batchQRCodeService := service.NewBatchQRCodeServiceWithDB(db)
+	batchQRCodeService := service.NewBatchQRCodeServiceWithDB(db, config.Default())
 
 	// Create a test application
 	app := &domain.Application{
@@ -270,7 +271,7 @@ func TestBatchQRCodeService_DeleteBatchQRCode(t *testing.T) {
 	db := testutils.SetupTestDB()
 	defer db.Migrator().DropTable(&domain.BatchQRCode{}, &domain.BatchQRCodeItem{}, &domain.Application{})
 
-	batchQRCodeService := service.NewBatchQRCodeServiceWithDB(db)
+	batchQRCodeService := service.NewBatchQRCodeServiceWithDB(db, config.Default())
 
 	// Create a test application
 	app := &domain.Application{
@@ -344,7 +345,7 @@ func TestBatchQRCodeService_ListBatchQRCodes(t *testing.T) {
 	db := testutils.SetupTestDB()
 	defer db.Migrator().DropTable(&domain.BatchQRCode{}, &domain.BatchQRCodeItem{}, &domain.Application{})
 
-	batchQRCodeService := service.NewBatchQRCodeServiceWithDB(db)
+	batchQRCodeService := service.NewBatchQRCodeServiceWithDB(db, config.Default())
 
 	// Create a test application
 	app := &domain.Application{
@@ -483,7 +484,7 @@ func TestBatchQRCodeService_GetBatchQRCode(t *testing.T) {
 	db := testutils.SetupTestDB()
 	defer db.Migrator().DropTable(&domain.BatchQRCode{}, &domain.BatchQRCodeItem{}, &domain.Application{})
 
-	batchQRCodeService := service.NewBatchQRCodeServiceWithDB(db)
+	batchQRCodeService := service.NewBatchQRCodeServiceWithDB(db, config.Default())
 
 	// Create a test application
 	app := &domain.Application{
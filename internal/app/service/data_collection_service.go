@@ -7,6 +7,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"cdk-office/internal/app/dataexport"
 	"cdk-office/internal/shared/database"
 	"cdk-office/pkg/logger"
 	"gorm.io/gorm"
@@ -22,6 +23,7 @@ type DataCollectionServiceInterface interface {
 	SubmitDataEntry(ctx context.Context, req *SubmitDataEntryRequest) (*DataCollectionEntry, error)
 	ListDataEntries(ctx context.Context, collectionID string, page, size int) ([]*DataCollectionEntry, int64, error)
 	ExportDataEntries(ctx context.Context, collectionID string) ([]*DataCollectionEntry, error)
+	OpenDataEntriesExport(ctx context.Context, collectionID string, opts *dataexport.Options) (*dataexport.Export, error)
 }
 
 // DataCollectionService implements the DataCollectionServiceInterface
@@ -98,6 +100,10 @@ var entryIDCounter int64
 
 // CreateDataCollection creates a new data collection
 func (s *DataCollectionService) CreateDataCollection(ctx context.Context, req *CreateDataCollectionRequest) (*DataCollection, error) {
+	if err := checkSchema(req.Schema); err != nil {
+		return nil, err
+	}
+
 	// Create new data collection
 	collection := &DataCollection{
 		ID:          generateCollectionID(),
@@ -143,6 +149,9 @@ func (s *DataCollectionService) UpdateDataCollection(ctx context.Context, collec
 	}
 
 	if req.Schema != "" {
+		if err := checkSchema(req.Schema); err != nil {
+			return err
+		}
 		collection.Schema = req.Schema
 	}
 
@@ -252,11 +261,17 @@ func (s *DataCollectionService) SubmitDataEntry(ctx context.Context, req *Submit
 		return nil, errors.New("failed to submit data entry")
 	}
 
+	// Validate data against the collection schema
+	data, err := validateSubmission(collection.Schema, req.Data)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create new data entry
 	entry := &DataCollectionEntry{
 		ID:          generateEntryID(),
 		CollectionID: req.CollectionID,
-		Data:        req.Data,
+		Data:        data,
 		CreatedBy:   req.CreatedBy,
 		CreatedAt:   time.Now(),
 	}
@@ -336,6 +351,24 @@ func (s *DataCollectionService) ExportDataEntries(ctx context.Context, collectio
 	return entries, nil
 }
 
+// OpenDataEntriesExport prepares an export of the entries of a collection with a
+// column per field of the collection schema. The entries are read from the
+// database while the export is streamed.
+func (s *DataCollectionService) OpenDataEntriesExport(ctx context.Context, collectionID string, opts *dataexport.Options) (*dataexport.Export, error) {
+	// Verify data collection exists
+	var collection DataCollection
+	if err := s.db.Table("data_collections").Where("id = ?", collectionID).First(&collection).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("data collection not found")
+		}
+		logger.Error("failed to find data collection", "error", err)
+		return nil, errors.New("failed to export data entries")
+	}
+
+	query := s.db.WithContext(ctx).Table("data_collection_entries").Where("collection_id = ?", collectionID)
+	return dataexport.New(query, dataexport.Table{CreatedBy: "created_by", Data: "data"}, collection.Schema, opts)
+}
+
 // generateCollectionID generates a unique collection ID
 func generateCollectionID() string {
 	// In a real application, use a proper ID generation library like uuid
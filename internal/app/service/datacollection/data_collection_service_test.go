@@ -1,10 +1,12 @@
 package datacollection_test
 
 import (
+	"bytes"
 	"context"
 	"testing"
 	"time"
 
+	"cdk-office/internal/app/dataexport"
 	"cdk-office/internal/app/domain"
 	"cdk-office/internal/app/service"
 	"cdk-office/internal/shared/testutils"
@@ -724,4 +726,86 @@ func TestDataCollectionService_ListDataEntries(t *testing.T) {
 // Helper function to create a pointer to a bool
 func boolPtr(b bool) *bool {
 	return &b
-}
\ No newline at end of file
+}
+func TestDataCollectionService_SubmitDataEntryValidation(t *testing.T) {
+	// Setup
+	db := testutils.SetupTestDB()
+	defer db.Migrator().DropTable(&domain.DataCollection{}, &domain.DataCollectionEntry{}, &domain.Application{})
+
+	dataCollectionService := service.NewDataCollectionServiceWithDB(db)
+
+	// Test collections with invalid schemas are rejected
+	_, err := dataCollectionService.CreateDataCollection(context.Background(), &service.CreateDataCollectionRequest{
+		AppID:     "app-001",
+		Name:      "Invalid Collection",
+		Schema:    `{"type": "object", "required": "name"}`,
+		CreatedBy: "user-001",
+	})
+	assert.EqualError(t, err, "invalid schema: /required: must be an array of strings")
+
+	collection, err := dataCollectionService.CreateDataCollection(context.Background(), &service.CreateDataCollectionRequest{
+		AppID:     "app-001",
+		Name:      "Survey",
+		Schema:    `{"type": "object", "properties": {"email": {"type": "string", "format": "email"}, "score": {"type": "number", "maximum": 10}}, "required": ["email"]}`,
+		Config:    `{}`,
+		CreatedBy: "user-001",
+	})
+	assert.NoError(t, err)
+
+	// Test values are coerced to the declared types
+	entry, err := dataCollectionService.SubmitDataEntry(context.Background(), &service.SubmitDataEntryRequest{
+		CollectionID: collection.ID,
+		Data:         `{"email": "jane@example.com", "score": "7.5"}`,
+		CreatedBy:    "user-001",
+	})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"email": "jane@example.com", "score": 7.5}`, entry.Data)
+
+	// Test invalid data is rejected with the path of every invalid field
+	_, err = dataCollectionService.SubmitDataEntry(context.Background(), &service.SubmitDataEntryRequest{
+		CollectionID: collection.ID,
+		Data:         `{"email": "jane", "score": 11}`,
+		CreatedBy:    "user-001",
+	})
+	assert.EqualError(t, err, "invalid data: /email: must be an email address (and 1 more error)")
+}
+
+func TestDataCollectionService_OpenDataEntriesExport(t *testing.T) {
+	// Setup
+	db := testutils.SetupTestDB()
+	defer db.Migrator().DropTable(&domain.DataCollection{}, &domain.DataCollectionEntry{}, &domain.Application{})
+
+	dataCollectionService := service.NewDataCollectionServiceWithDB(db)
+
+	collection, err := dataCollectionService.CreateDataCollection(context.Background(), &service.CreateDataCollectionRequest{
+		AppID:     "app-001",
+		Name:      "Survey",
+		Schema:    `{"fields": [{"name": "email", "type": "email", "label": "Email"}, {"name": "score", "type": "number", "label": "Score"}]}`,
+		Config:    `{}`,
+		CreatedBy: "user-001",
+	})
+	assert.NoError(t, err)
+	for i, data := range []string{`{"email": "ann@example.com", "score": 7}`, `{"email": "bo@example.com", "score": "8.5"}`} {
+		assert.NoError(t, db.Create(&domain.DataCollectionEntry{
+			ID:           "entry-" + string(rune('a'+i)),
+			CollectionID: collection.ID,
+			Data:         data,
+			CreatedBy:    "user-001",
+			CreatedAt:    time.Date(2024, 5, 1+i, 9, 0, 0, 0, time.UTC),
+		}).Error)
+	}
+
+	// Test the entries are exported with a column per field
+	export, err := dataCollectionService.OpenDataEntriesExport(context.Background(), collection.ID, &dataexport.Options{
+		Format:  dataexport.FormatCSV,
+		Columns: []string{"email", "score"},
+	})
+	assert.NoError(t, err)
+	var out bytes.Buffer
+	assert.NoError(t, export.Stream(&out))
+	assert.Equal(t, "\ufeffEmail,Score\nann@example.com,7\nbo@example.com,8.5\n", out.String())
+
+	// Test exporting a non-existent collection
+	_, err = dataCollectionService.OpenDataEntriesExport(context.Background(), "non-existent-id", &dataexport.Options{Format: dataexport.FormatCSV})
+	assert.EqualError(t, err, "data collection not found")
+}
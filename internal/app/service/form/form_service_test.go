@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"cdk-office/internal/app/domain"
+	"cdk-office/internal/app/formschema"
 	"cdk-office/internal/app/service"
 	"cdk-office/internal/shared/testutils"
 	"github.com/stretchr/testify/assert"
@@ -737,4 +738,59 @@ func TestFormService_ListFormDataEntries(t *testing.T) {
 // Helper function to create a pointer to a bool
 func boolPtr(b bool) *bool {
 	return &b
-}
\ No newline at end of file
+}
+func TestFormService_SubmitFormDataValidation(t *testing.T) {
+	// Setup
+	db := testutils.SetupTestDB()
+	defer db.Migrator().DropTable(&domain.FormData{}, &domain.FormDataEntry{}, &domain.Application{})
+
+	formService := service.NewFormService()
+
+	// Test forms with invalid schemas are rejected
+	_, err := formService.CreateForm(context.Background(), &service.CreateFormRequest{
+		AppID:     "app-001",
+		Name:      "Invalid Form",
+		Schema:    `{"type": "object", "properties": {"age": {"type": "numbr"}}}`,
+		CreatedBy: "user-001",
+	})
+	assert.EqualError(t, err, `invalid schema: /properties/age/type: unknown type "numbr"`)
+
+	form, err := formService.CreateForm(context.Background(), &service.CreateFormRequest{
+		AppID:     "app-001",
+		Name:      "Registration",
+		Schema:    `{"fields": [{"name": "name", "type": "text", "required": true}, {"name": "age", "type": "integer", "min": 18}, {"name": "visit", "type": "date"}]}`,
+		CreatedBy: "user-001",
+	})
+	assert.NoError(t, err)
+
+	err = formService.UpdateForm(context.Background(), form.ID, &service.UpdateFormRequest{Schema: `{"fields": [{"name": "age", "type": "colour"}]}`})
+	assert.EqualError(t, err, `invalid schema: /fields/0/type: unknown field type "colour"`)
+
+	// Test values are coerced to the declared types
+	entry, err := formService.SubmitFormData(context.Background(), &service.SubmitFormDataRequest{
+		FormID:    form.ID,
+		Data:      `{"name": "John Doe", "age": "30", "visit": "2024/5/1"}`,
+		CreatedBy: "user-001",
+	})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"name": "John Doe", "age": 30, "visit": "2024-05-01"}`, entry.Data)
+
+	// Test invalid data is rejected with the path of every invalid field
+	entry, err = formService.SubmitFormData(context.Background(), &service.SubmitFormDataRequest{
+		FormID:    form.ID,
+		Data:      `{"age": 17}`,
+		CreatedBy: "user-001",
+	})
+	assert.Nil(t, entry)
+	var validationErr *formschema.ValidationError
+	if assert.ErrorAs(t, err, &validationErr) {
+		assert.Equal(t, []*formschema.FieldError{
+			{Path: "/name", Message: "is required"},
+			{Path: "/age", Message: "must be at least 18"},
+		}, validationErr.Errors)
+	}
+
+	var count int64
+	db.Model(&domain.FormDataEntry{}).Where("form_id = ?", form.ID).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
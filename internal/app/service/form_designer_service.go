@@ -3,9 +3,14 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"cdk-office/internal/app/domain"
+	"cdk-office/internal/app/formschema"
+	approvaldomain "cdk-office/internal/approval/domain"
+	approvalservice "cdk-office/internal/approval/service"
 	"cdk-office/internal/shared/database"
 	"cdk-office/internal/shared/utils"
 	"cdk-office/pkg/logger"
@@ -19,12 +24,13 @@ type FormDesignerServiceInterface interface {
 	DeleteFormDesign(ctx context.Context, formID string) error
 	ListFormDesigns(ctx context.Context, appID string, page, size int) ([]*domain.FormDesign, int64, error)
 	GetFormDesign(ctx context.Context, formID string) (*domain.FormDesign, error)
-	PublishFormDesign(ctx context.Context, formID string) error
+	PublishFormDesign(ctx context.Context, formID, userID string) error
 }
 
 // FormDesignerService implements the FormDesignerServiceInterface
 type FormDesignerService struct {
-	db *gorm.DB
+	db        *gorm.DB
+	approvals approvalservice.ApprovalServiceInterface
 }
 
 // NewFormDesignerService creates a new instance of FormDesignerService
@@ -34,6 +40,13 @@ func NewFormDesignerService() *FormDesignerService {
 	}
 }
 
+// UseApprovals makes publishing form designs require approval in teams with an
+// approval definition for it, and publishes form designs once approved
+func (s *FormDesignerService) UseApprovals(approvals approvalservice.ApprovalServiceInterface) {
+	s.approvals = approvals
+	approvals.RegisterHook(domain.ApprovalEntityFormDesign, approvalservice.HookFuncs{OnApproved: s.publishApprovedFormDesign})
+}
+
 // CreateFormDesignRequest represents the request for creating a form design
 type CreateFormDesignRequest struct {
 	AppID       string `json:"app_id" binding:"required"`
@@ -217,29 +230,78 @@ func (s *FormDesignerService) GetFormDesign(ctx context.Context, formID string)
 	return &form, nil
 }
 
-// PublishFormDesign publishes a form design
-func (s *FormDesignerService) PublishFormDesign(ctx context.Context, formID string) error {
+// PublishFormDesign publishes a form design. When the team of its application
+// requires approval to publish form designs, the form design is submitted for
+// approval instead and published once approved.
+func (s *FormDesignerService) PublishFormDesign(ctx context.Context, formID, userID string) error {
+	form, err := s.checkPublishable(formID)
+	if err != nil {
+		return err
+	}
+
+	// Find the team of the form design's application
+	var app domain.Application
+	if err := s.db.Where("id = ?", form.AppID).First(&app).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Error("failed to find application", "error", err)
+		return errors.New("failed to publish form design")
+	}
+	if err := approvalservice.RequireApproval(ctx, s.approvals, &approvalservice.SubmitRequest{
+		TeamID:      app.TeamID,
+		EntityType:  domain.ApprovalEntityFormDesign,
+		EntityID:    form.ID,
+		Action:      domain.ApprovalActionPublish,
+		RequestedBy: userID,
+	}); err != nil {
+		return err
+	}
+	return s.publishFormDesign(form)
+}
+
+// publishApprovedFormDesign publishes a form design whose publishing was approved
+func (s *FormDesignerService) publishApprovedFormDesign(ctx context.Context, request *approvaldomain.ApprovalRequest) error {
+	form, err := s.checkPublishable(request.EntityID)
+	if err != nil {
+		return err
+	}
+	return s.publishFormDesign(form)
+}
+
+// checkPublishable finds a form design that is not published yet and whose
+// schema submissions can be validated against
+func (s *FormDesignerService) checkPublishable(formID string) (*domain.FormDesign, error) {
 	// Find form design by ID
 	var form domain.FormDesign
 	if err := s.db.Table("form_designs").Where("id = ?", formID).First(&form).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New("form design not found")
+			return nil, errors.New("form design not found")
 		}
 		logger.Error("failed to find form design", "error", err)
-		return errors.New("failed to publish form design")
+		return nil, errors.New("failed to publish form design")
 	}
 
 	// Check if form is already published
 	if form.IsPublished {
-		return errors.New("form design is already published")
+		return nil, errors.New("form design is already published")
+	}
+
+	// Refuse to publish a schema submissions can not be validated against
+	if strings.TrimSpace(form.Schema) == "" {
+		return nil, fmt.Errorf("%w: schema is empty", formschema.ErrInvalidSchema)
+	}
+	if err := checkSchema(form.Schema); err != nil {
+		return nil, err
 	}
+	return &form, nil
+}
 
+// publishFormDesign marks a form design published
+func (s *FormDesignerService) publishFormDesign(form *domain.FormDesign) error {
 	// Update form design status to published
 	form.IsPublished = true
 	form.UpdatedAt = time.Now()
 
 	// Save updated form design to database
-	if err := s.db.Table("form_designs").Save(&form).Error; err != nil {
+	if err := s.db.Table("form_designs").Save(form).Error; err != nil {
 		logger.Error("failed to publish form design", "error", err)
 		return errors.New("failed to publish form design")
 	}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"strings"
+	"sync"
+
+	"cdk-office/internal/app/formschema"
+)
+
+// maxCompiledSchemas bounds the number of compiled schemas kept in memory
+const maxCompiledSchemas = 256
+
+// compiledSchemas caches compiled schemas by their text, so each version of a
+// form design is compiled once rather than on every submission. Editing a
+// schema changes its text, so stale versions are never served.
+var compiledSchemas = &schemaCache{schemas: make(map[string]*formschema.Schema)}
+
+// schemaCache is a bounded, concurrency safe cache of compiled schemas
+type schemaCache struct {
+	mu      sync.Mutex
+	schemas map[string]*formschema.Schema
+}
+
+// compile returns the compiled schema, compiling and caching it on a miss.
+// Schemas that fail to compile are not cached.
+func (c *schemaCache) compile(schema string) (*formschema.Schema, error) {
+	c.mu.Lock()
+	compiled, ok := c.schemas[schema]
+	c.mu.Unlock()
+	if ok {
+		return compiled, nil
+	}
+
+	compiled, err := formschema.Compile(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.schemas) >= maxCompiledSchemas {
+		// Drop an arbitrary entry, the evicted schema is recompiled on its next use
+		for key := range c.schemas {
+			delete(c.schemas, key)
+			break
+		}
+	}
+	c.schemas[schema] = compiled
+	return compiled, nil
+}
+
+// checkSchema rejects form schemas that can not be compiled. An empty schema
+// accepts any data.
+func checkSchema(schema string) error {
+	if strings.TrimSpace(schema) == "" {
+		return nil
+	}
+	_, err := compiledSchemas.compile(schema)
+	return err
+}
+
+// validateSubmission validates submitted data against a form schema and
+// returns the data to store, with values coerced to the declared types.
+// Invalid data is reported as a *formschema.ValidationError.
+func validateSubmission(schema, data string) (string, error) {
+	if strings.TrimSpace(schema) == "" {
+		return data, nil
+	}
+	compiled, err := compiledSchemas.compile(schema)
+	if err != nil {
+		return "", err
+	}
+	return compiled.Validate(data)
+}
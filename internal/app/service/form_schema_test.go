@@ -0,0 +1,39 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"cdk-office/internal/app/formschema"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSchemaCache tests that schemas are compiled once per version and that
+// the cache stays bounded
+func TestSchemaCache(t *testing.T) {
+	cache := &schemaCache{schemas: make(map[string]*formschema.Schema)}
+
+	first, err := cache.compile(`{"type": "object"}`)
+	require.NoError(t, err)
+	second, err := cache.compile(`{"type": "object"}`)
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+
+	// An edited schema is a new version and is compiled again
+	edited, err := cache.compile(`{"type": "object", "required": ["name"]}`)
+	require.NoError(t, err)
+	assert.NotSame(t, first, edited)
+
+	// Invalid schemas are reported and not cached
+	_, err = cache.compile(`{"type": "numbr"}`)
+	assert.ErrorIs(t, err, formschema.ErrInvalidSchema)
+	assert.Len(t, cache.schemas, 2)
+
+	for i := 0; i < maxCompiledSchemas+10; i++ {
+		_, err := cache.compile(fmt.Sprintf(`{"maxLength": %d}`, i))
+		require.NoError(t, err)
+	}
+	assert.Len(t, cache.schemas, maxCompiledSchemas)
+}
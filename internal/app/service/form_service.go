@@ -5,6 +5,7 @@ import (
 	"errors"
 	"time"
 
+	"cdk-office/internal/app/dataexport"
 	"cdk-office/internal/app/domain"
 	"cdk-office/internal/shared/database"
 	"cdk-office/internal/shared/utils"
@@ -21,6 +22,7 @@ type FormServiceInterface interface {
 	GetForm(ctx context.Context, formID string) (*domain.FormData, error)
 	SubmitFormData(ctx context.Context, req *SubmitFormDataRequest) (*domain.FormDataEntry, error)
 	ListFormDataEntries(ctx context.Context, formID string, page, size int) ([]*domain.FormDataEntry, int64, error)
+	OpenFormDataExport(ctx context.Context, formID string, opts *dataexport.Options) (*dataexport.Export, error)
 }
 
 // FormService implements the FormServiceInterface
@@ -61,6 +63,10 @@ type SubmitFormDataRequest struct {
 
 // CreateForm creates a new form
 func (s *FormService) CreateForm(ctx context.Context, req *CreateFormRequest) (*domain.FormData, error) {
+	if err := checkSchema(req.Schema); err != nil {
+		return nil, err
+	}
+
 	// Create new form
 	form := &domain.FormData{
 		ID:          utils.GenerateFormID(),
@@ -105,6 +111,9 @@ func (s *FormService) UpdateForm(ctx context.Context, formID string, req *Update
 	}
 
 	if req.Schema != "" {
+		if err := checkSchema(req.Schema); err != nil {
+			return err
+		}
 		form.Schema = req.Schema
 	}
 
@@ -204,11 +213,17 @@ func (s *FormService) SubmitFormData(ctx context.Context, req *SubmitFormDataReq
 		return nil, errors.New("failed to submit form data")
 	}
 
+	// Validate data against the form schema
+	data, err := validateSubmission(form.Schema, req.Data)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create form data entry
 	entry := &domain.FormDataEntry{
 		ID:        generateFormEntryID(),
 		FormID:    req.FormID,
-		Data:      req.Data,
+		Data:      data,
 		CreatedBy: req.CreatedBy,
 		CreatedAt: time.Now(),
 	}
@@ -256,6 +271,23 @@ func (s *FormService) ListFormDataEntries(ctx context.Context, formID string, pa
 	return entries, total, nil
 }
 
+// OpenFormDataExport prepares an export of the data entries of a form with a
+// column per field of the form schema
+func (s *FormService) OpenFormDataExport(ctx context.Context, formID string, opts *dataexport.Options) (*dataexport.Export, error) {
+	// Verify form exists
+	var form domain.FormData
+	if err := s.db.Where("id = ?", formID).First(&form).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("form not found")
+		}
+		logger.Error("failed to find form", "error", err)
+		return nil, errors.New("failed to export form data entries")
+	}
+
+	query := s.db.WithContext(ctx).Model(&domain.FormDataEntry{}).Where("form_id = ?", formID)
+	return dataexport.New(query, dataexport.Table{CreatedBy: "created_by", Data: "data"}, form.Schema, opts)
+}
+
 
 
 // generateFormEntryID generates a unique form entry ID
@@ -2,11 +2,14 @@ package formdesigner_test
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
 	"cdk-office/internal/app/domain"
 	"cdk-office/internal/app/service"
+	approvaldomain "cdk-office/internal/approval/domain"
+	approvalservice "cdk-office/internal/approval/service"
 	"cdk-office/internal/shared/testutils"
 	"github.com/stretchr/testify/assert"
 )
@@ -634,7 +637,7 @@ func TestFormDesignerService_PublishFormDesign(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Execute
-			err := formDesignerService.PublishFormDesign(context.Background(), tt.formID)
+			err := formDesignerService.PublishFormDesign(context.Background(), tt.formID, "user-001")
 
 			// Assert
 			if tt.expectError {
@@ -655,7 +658,86 @@ func TestFormDesignerService_PublishFormDesign(t *testing.T) {
 	}
 }
 
+func TestFormDesignerService_PublishWithApproval(t *testing.T) {
+	// Setup
+	db := testutils.SetupTestDB()
+	defer db.Migrator().DropTable(&domain.FormDesign{}, &domain.Application{})
+
+	approvals := approvalservice.NewApprovalServiceWithDB(db)
+	formDesignerService := service.NewFormDesignerService()
+	formDesignerService.UseApprovals(approvals)
+	ctx := context.Background()
+
+	assert.NoError(t, db.Create(&domain.Application{ID: "app-001", TeamID: "team-001", Name: "Test App", Type: "form", CreatedBy: "user-001"}).Error)
+	assert.NoError(t, db.Table("form_designs").Create(&domain.FormDesign{
+		ID:        "form-001",
+		AppID:     "app-001",
+		Name:      "Test Form Design",
+		Schema:    `{"type": "object", "properties": {"name": {"type": "string"}}}`,
+		Config:    `{}`,
+		IsActive:  true,
+		CreatedBy: "user-001",
+	}).Error)
+	_, err := approvals.CreateDefinition(ctx, &approvalservice.CreateDefinitionRequest{
+		TeamID: "team-001", Name: "Forms", EntityType: domain.ApprovalEntityFormDesign, Action: domain.ApprovalActionPublish,
+		IsActive: true, CreatedBy: "admin",
+		Steps: []approvaldomain.ApprovalStep{{Name: "Owner", Approvers: []approvaldomain.ApproverRule{{Type: approvaldomain.ApproverUser, UserID: "user-002"}}}},
+	})
+	assert.NoError(t, err)
+
+	// Publishing is submitted for approval and the form design stays unpublished
+	err = formDesignerService.PublishFormDesign(ctx, "form-001", "user-001")
+	var required *approvalservice.ApprovalRequiredError
+	if !assert.ErrorAs(t, err, &required) {
+		return
+	}
+	form, err := formDesignerService.GetFormDesign(ctx, "form-001")
+	assert.NoError(t, err)
+	assert.False(t, form.IsPublished)
+	assert.EqualError(t, formDesignerService.PublishFormDesign(ctx, "form-001", "user-001"), "approval is already pending")
+
+	// The form design is published once approved
+	assert.NoError(t, approvals.Approve(ctx, required.Request.ID, "user-002", ""))
+	form, err = formDesignerService.GetFormDesign(ctx, "form-001")
+	assert.NoError(t, err)
+	assert.True(t, form.IsPublished)
+}
+
 // Helper function to create a pointer to a bool
 func boolPtr(b bool) *bool {
 	return &b
-}
\ No newline at end of file
+}
+func TestFormDesignerService_PublishInvalidSchema(t *testing.T) {
+	// Setup
+	db := testutils.SetupTestDB()
+	defer db.Migrator().DropTable(&domain.FormDesign{}, &domain.Application{})
+
+	formDesignerService := service.NewFormDesignerService()
+
+	// Test designs whose schema can not be compiled are not published
+	for id, schema := range map[string]string{
+		"form-001": `{"fields": [{"name": "email", "type": "emial"}]}`,
+		"form-002": `{"type": "object", "properties": {"name": {"type": "string", "maxLength": "ten"}}}`,
+		"form-003": "",
+	} {
+		form := &domain.FormDesign{
+			ID:        id,
+			AppID:     "app-001",
+			Name:      "Invalid Form Design",
+			Schema:    schema,
+			Config:    `{}`,
+			IsActive:  true,
+			CreatedBy: "user-001",
+		}
+		assert.NoError(t, db.Table("form_designs").Create(form).Error)
+
+		err := formDesignerService.PublishFormDesign(context.Background(), id, "user-001")
+		if assert.Error(t, err, id) {
+			assert.True(t, strings.HasPrefix(err.Error(), "invalid schema: "), err.Error())
+		}
+
+		var stored domain.FormDesign
+		assert.NoError(t, db.Table("form_designs").Where("id = ?", id).First(&stored).Error)
+		assert.False(t, stored.IsPublished)
+	}
+}
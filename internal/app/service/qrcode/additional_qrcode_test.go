@@ -8,6 +8,7 @@ import (
 	"cdk-office/internal/app/domain"
 	"cdk-office/internal/app/service"
 	"cdk-office/internal/shared/testutils"
+	"cdk-office/pkg/config"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,7 +17,7 @@ func TestQRCodeService_CreateQRCode_Additional(t *testing.T) {
 	db := testutils.SetupTestDB()
 	defer db.Migrator().DropTable(&domain.QRCode{}, &domain.Application{})
 
-	qrCodeService := service.NewQRCodeService()
+	qrCodeService := service.NewQRCodeService(&config.Default().QRCode)
 
 	// Create a test application first
 	app := &domain.Application{
@@ -109,7 +110,7 @@ func TestQRCodeService_UpdateQRCode_Additional(t *testing.T) {
 	db := testutils.SetupTestDB()
 	defer db.Migrator().DropTable(&domain.QRCode{}, &domain.Application{})
 
-	qrCodeService := service.NewQRCodeService()
+	qrCodeService := service.NewQRCodeService(&config.Default().QRCode)
 
 	// Create a test application
 	app := &domain.Application{
@@ -215,7 +216,7 @@ func TestQRCodeService_DeleteQRCode_Additional(t *testing.T) {
 	db := testutils.SetupTestDB()
 	defer db.Migrator().DropTable(&domain.QRCode{}, &domain.Application{})
 
-	qrCodeService := service.NewQRCodeService()
+	qrCodeService := service.NewQRCodeService(&config.Default().QRCode)
 
 	// Create a test application
 	app := &domain.Application{
@@ -261,7 +262,7 @@ func TestQRCodeService_ListQRCodes_Additional(t *testing.T) {
 	db := testutils.SetupTestDB()
 	defer db.Migrator().DropTable(&domain.QRCode{}, &domain.Application{})
 
-	qrCodeService := service.NewQRCodeService()
+	qrCodeService := service.NewQRCodeService(&config.Default().QRCode)
 
 	// Create a test application
 	app := &domain.Application{
@@ -361,7 +362,7 @@ func TestQRCodeService_GenerateQRCodeImage_Additional(t *testing.T) {
 	db := testutils.SetupTestDB()
 	defer db.Migrator().DropTable(&domain.QRCode{}, &domain.Application{})
 
-	qrCodeService := service.NewQRCodeService()
+	qrCodeService := service.NewQRCodeService(&config.Default().QRCode)
 
 	// Create a test application
 	app := &domain.Application{
@@ -8,6 +8,7 @@ import (
 	"cdk-office/internal/app/domain"
 	"cdk-office/internal/app/service"
 	"cdk-office/internal/shared/testutils"
+	"cdk-office/pkg/config"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -16,7 +17,7 @@ func TestQRCodeService_CreateQRCode(t *testing.T) {
 	db := testutils.SetupTestDB()
 	defer db.Migrator().DropTable(&domain.QRCode{}, &domain.Application{})
 
-	qrCodeService := service.NewQRCodeService()
+	qrCodeService := service.NewQRCodeService(&config.Default().QRCode)
 
 	// Create a test application first
 	app := &domain.Application{
@@ -107,7 +108,7 @@ func TestQRCodeService_UpdateQRCode(t *testing.T) {
 	db := testutils.SetupTestDB()
 	defer db.Migrator().DropTable(&domain.QRCode{}, &domain.Application{})
 
-	qrCodeService := service.NewQRCodeService()
+	qrCodeService := service.NewQRCodeService(&config.Default().QRCode)
 
 	// Create a test application
 	app := &domain.Application{
@@ -209,7 +210,7 @@ func TestQRCodeService_DeleteQRCode(t *testing.T) {
 	db := testutils.SetupTestDB()
 	defer db.Migrator().DropTable(&domain.QRCode{}, &domain.Application{})
 
-	qrCodeService := service.NewQRCodeService()
+	qrCodeService := service.NewQRCodeService(&config.Default().QRCode)
 
 	// Create a test application
 	app := &domain.Application{
@@ -281,7 +282,7 @@ func TestQRCodeService_ListQRCodes(t *testing.T) {
 	db := testutils.SetupTestDB()
 	defer db.Migrator().DropTable(&domain.QRCode{}, &domain.Application{})
 
-	qrCodeService := service.NewQRCodeService()
+	qrCodeService := service.NewQRCodeService(&config.Default().QRCode)
 
 	// Create a test application
 	app := &domain.Application{
@@ -420,7 +421,7 @@ func TestQRCodeService_GetQRCode(t *testing.T) {
 	db := testutils.SetupTestDB()
 	defer db.Migrator().DropTable(&domain.QRCode{}, &domain.Application{})
 
-	qrCodeService := service.NewQRCodeService()
+	qrCodeService := service.NewQRCodeService(&config.Default().QRCode)
 
 	// Create a test application
 	app := &domain.Application{
@@ -500,7 +501,7 @@ func TestQRCodeService_GenerateQRCodeImage(t *testing.T) {
 	db := testutils.SetupTestDB()
 	defer db.Migrator().DropTable(&domain.QRCode{}, &domain.Application{})
 
-	qrCodeService := service.NewQRCodeService()
+	qrCodeService := service.NewQRCodeService(&config.Default().QRCode)
 
 	// Create a test application
 	app := &domain.Application{
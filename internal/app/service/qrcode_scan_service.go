@@ -0,0 +1,307 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"cdk-office/internal/app/domain"
+	"cdk-office/internal/shared/database"
+	"cdk-office/internal/shared/utils"
+	"cdk-office/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// Limits of the scan statistics
+const (
+	maxStatsDays  = 366
+	topCodeLimit  = 10
+	scanDayFormat = "2006-01-02"
+)
+
+// QRCodeScanServiceInterface defines the interface for QR code scan service
+type QRCodeScanServiceInterface interface {
+	ResolveShortCode(ctx context.Context, shortCode string, client *ScanClient) (string, error)
+	GetQRCodeStats(ctx context.Context, qrCodeID string, from, to time.Time) (*ScanStats, error)
+	GetBatchStats(ctx context.Context, batchID string, from, to time.Time) (*ScanStats, error)
+}
+
+// QRCodeScanService implements the QRCodeScanServiceInterface
+type QRCodeScanService struct {
+	db *gorm.DB
+}
+
+// NewQRCodeScanService creates a new instance of QRCodeScanService
+func NewQRCodeScanService() *QRCodeScanService {
+	return NewQRCodeScanServiceWithDB(database.GetDB())
+}
+
+// NewQRCodeScanServiceWithDB creates a new instance of QRCodeScanService with a specific database connection
+func NewQRCodeScanServiceWithDB(db *gorm.DB) *QRCodeScanService {
+	return &QRCodeScanService{
+		db: db,
+	}
+}
+
+// ScanClient describes the client that scanned a QR code
+type ScanClient struct {
+	IP             string
+	UserAgent      string
+	Referrer       string
+	AcceptLanguage string
+}
+
+// ScanStats holds the scan statistics of a QR code or a batch over a range of days
+type ScanStats struct {
+	From           string             `json:"from"`
+	To             string             `json:"to"`
+	TotalScans     int64              `json:"total_scans"`
+	UniqueVisitors int64              `json:"unique_visitors"`
+	Daily          []*DailyScanCount  `json:"daily"`
+	TopCodes       []*QRCodeScanCount `json:"top_codes,omitempty"`
+}
+
+// DailyScanCount holds the scans of one day
+type DailyScanCount struct {
+	Date           string `json:"date"`
+	Scans          int64  `json:"scans"`
+	UniqueVisitors int64  `json:"unique_visitors"`
+}
+
+// QRCodeScanCount holds the scans of one QR code
+type QRCodeScanCount struct {
+	QRCodeID       string `json:"qr_code_id"`
+	Name           string `json:"name"`
+	Scans          int64  `json:"scans"`
+	UniqueVisitors int64  `json:"unique_visitors"`
+}
+
+// ResolveShortCode returns the target URL of a dynamic QR code and records the scan.
+// A scan that cannot be recorded is logged but still redirected.
+func (s *QRCodeScanService) ResolveShortCode(ctx context.Context, shortCode string, client *ScanClient) (string, error) {
+	var qrCode domain.QRCode
+	if err := s.db.Where("short_code = ? AND type = ?", shortCode, domain.QRCodeDynamic).First(&qrCode).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", errors.New("QR code not found")
+		}
+		logger.Error("failed to find QR code", "error", err)
+		return "", errors.New("failed to resolve QR code")
+	}
+	if qrCode.URL == "" {
+		return "", errors.New("QR code not found")
+	}
+
+	now := time.Now().UTC()
+	device, os, browser := parseUserAgent(client.UserAgent)
+	scan := &domain.QRCodeScan{
+		ID:        utils.GenerateQRCodeScanID(),
+		QRCodeID:  qrCode.ID,
+		BatchID:   qrCode.BatchID,
+		ScannedAt: now,
+		ScanDate:  now.Format(scanDayFormat),
+		VisitorID: visitorID(client.IP, client.UserAgent),
+		UserAgent: truncate(client.UserAgent, 500),
+		Referrer:  truncate(client.Referrer, 500),
+		Language:  primaryLanguage(client.AcceptLanguage),
+		Device:    device,
+		OS:        os,
+		Browser:   browser,
+	}
+	if err := s.db.Create(scan).Error; err != nil {
+		logger.Error("failed to record QR code scan", "error", err, "qr_code_id", qrCode.ID)
+	}
+
+	return qrCode.URL, nil
+}
+
+// GetQRCodeStats returns the scan statistics of a QR code between two days inclusive
+func (s *QRCodeScanService) GetQRCodeStats(ctx context.Context, qrCodeID string, from, to time.Time) (*ScanStats, error) {
+	if err := validateStatsRange(from, to); err != nil {
+		return nil, err
+	}
+
+	var qrCode domain.QRCode
+	if err := s.db.Where("id = ?", qrCodeID).First(&qrCode).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("QR code not found")
+		}
+		logger.Error("failed to find QR code", "error", err)
+		return nil, errors.New("failed to get scan statistics")
+	}
+
+	return s.scanStats("qr_code_scans.qr_code_id", qrCodeID, from, to, false)
+}
+
+// GetBatchStats returns the scan statistics of a batch between two days
+// inclusive, including its most scanned QR codes
+func (s *QRCodeScanService) GetBatchStats(ctx context.Context, batchID string, from, to time.Time) (*ScanStats, error) {
+	if err := validateStatsRange(from, to); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if err := s.db.Table("batch_qr_codes").Where("id = ?", batchID).Count(&count).Error; err != nil {
+		logger.Error("failed to find batch QR code", "error", err)
+		return nil, errors.New("failed to get scan statistics")
+	}
+	if count == 0 {
+		return nil, errors.New("batch QR code not found")
+	}
+
+	return s.scanStats("qr_code_scans.batch_id", batchID, from, to, true)
+}
+
+// scanStats aggregates the scans whose column equals value between two days inclusive
+func (s *QRCodeScanService) scanStats(column, value string, from, to time.Time, withTopCodes bool) (*ScanStats, error) {
+	stats := &ScanStats{
+		From: from.Format(scanDayFormat),
+		To:   to.Format(scanDayFormat),
+	}
+	query := func() *gorm.DB {
+		return s.db.Model(&domain.QRCodeScan{}).Where(column+" = ?", value).
+			Where("qr_code_scans.scan_date BETWEEN ? AND ?", stats.From, stats.To)
+	}
+
+	var total DailyScanCount
+	if err := query().Select("COUNT(*) AS scans, COUNT(DISTINCT qr_code_scans.visitor_id) AS unique_visitors").
+		Scan(&total).Error; err != nil {
+		logger.Error("failed to count QR code scans", "error", err)
+		return nil, errors.New("failed to get scan statistics")
+	}
+	stats.TotalScans = total.Scans
+	stats.UniqueVisitors = total.UniqueVisitors
+
+	var days []*DailyScanCount
+	if err := query().Select("qr_code_scans.scan_date AS date, COUNT(*) AS scans, COUNT(DISTINCT qr_code_scans.visitor_id) AS unique_visitors").
+		Group("qr_code_scans.scan_date").Scan(&days).Error; err != nil {
+		logger.Error("failed to count daily QR code scans", "error", err)
+		return nil, errors.New("failed to get scan statistics")
+	}
+	byDate := make(map[string]*DailyScanCount, len(days))
+	for _, day := range days {
+		byDate[day.Date] = day
+	}
+	// Report every day of the range so gaps show up as zero
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format(scanDayFormat)
+		if count, ok := byDate[date]; ok {
+			stats.Daily = append(stats.Daily, count)
+		} else {
+			stats.Daily = append(stats.Daily, &DailyScanCount{Date: date})
+		}
+	}
+
+	if withTopCodes {
+		if err := query().Select("qr_code_scans.qr_code_id, qr_codes.name, COUNT(*) AS scans, COUNT(DISTINCT qr_code_scans.visitor_id) AS unique_visitors").
+			Joins("JOIN qr_codes ON qr_codes.id = qr_code_scans.qr_code_id").
+			Group("qr_code_scans.qr_code_id, qr_codes.name").
+			Order("scans DESC, qr_code_scans.qr_code_id").Limit(topCodeLimit).
+			Scan(&stats.TopCodes).Error; err != nil {
+			logger.Error("failed to find top QR codes", "error", err)
+			return nil, errors.New("failed to get scan statistics")
+		}
+	}
+
+	return stats, nil
+}
+
+// validateStatsRange checks that a statistics range is ordered and not too long
+func validateStatsRange(from, to time.Time) error {
+	if to.Before(from) || to.Sub(from) > maxStatsDays*24*time.Hour {
+		return errors.New("invalid date range")
+	}
+	return nil
+}
+
+// visitorID identifies a visitor without storing the client address
+func visitorID(ip, userAgent string) string {
+	sum := sha256.Sum256([]byte(ip + "\n" + userAgent))
+	return hex.EncodeToString(sum[:16])
+}
+
+// primaryLanguage returns the preferred language of an Accept-Language header
+func primaryLanguage(acceptLanguage string) string {
+	language := strings.TrimSpace(strings.Split(acceptLanguage, ",")[0])
+	language = strings.Split(language, ";")[0]
+	return truncate(language, 50)
+}
+
+// parseUserAgent classifies a user agent into coarse device, OS and browser names
+func parseUserAgent(userAgent string) (device, os, browser string) {
+	ua := strings.ToLower(userAgent)
+	if ua == "" {
+		return "unknown", "unknown", "unknown"
+	}
+
+	switch {
+	case containsAny(ua, "bot", "crawler", "spider", "curl", "wget"):
+		device = "bot"
+	case containsAny(ua, "ipad", "tablet") || (strings.Contains(ua, "android") && !strings.Contains(ua, "mobile")):
+		device = "tablet"
+	case containsAny(ua, "mobi", "iphone", "android"):
+		device = "mobile"
+	default:
+		device = "desktop"
+	}
+
+	// iOS agents mention Mac OS and Android agents mention Linux, so check them first
+	switch {
+	case containsAny(ua, "iphone", "ipad", "ipod"):
+		os = "iOS"
+	case strings.Contains(ua, "android"):
+		os = "Android"
+	case strings.Contains(ua, "windows"):
+		os = "Windows"
+	case strings.Contains(ua, "mac os"):
+		os = "macOS"
+	case strings.Contains(ua, "linux"):
+		os = "Linux"
+	default:
+		os = "other"
+	}
+
+	// Most browsers also claim to be Safari or Chrome, so check the specific ones first
+	switch {
+	case strings.Contains(ua, "micromessenger"):
+		browser = "WeChat"
+	case strings.Contains(ua, "edg"):
+		browser = "Edge"
+	case containsAny(ua, "opr/", "opera"):
+		browser = "Opera"
+	case containsAny(ua, "chrome", "crios"):
+		browser = "Chrome"
+	case containsAny(ua, "firefox", "fxios"):
+		browser = "Firefox"
+	case strings.Contains(ua, "safari"):
+		browser = "Safari"
+	default:
+		browser = "other"
+	}
+
+	return device, os, browser
+}
+
+// containsAny reports whether s contains any of the substrings
+func containsAny(s string, substrings ...string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+	return false
+}
+
+// truncate shortens s to at most n bytes without splitting a character
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cdk-office/internal/app/domain"
+	"cdk-office/internal/shared/testutils"
+	"cdk-office/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestQRCodeScanService tests the QRCodeScanService
+func TestQRCodeScanService(t *testing.T) {
+	// Set up test environment
+	testDB := testutils.SetupTestDB()
+	qrCodeService := NewQRCodeService(&config.Default().QRCode)
+	qrCodeService.db = testDB
+	scanService := NewQRCodeScanServiceWithDB(testDB)
+	ctx := context.Background()
+
+	dynamic, err := qrCodeService.CreateQRCode(ctx, &CreateQRCodeRequest{
+		AppID:     "app_scan",
+		Name:      "Asset 1",
+		Content:   "Asset 1",
+		Type:      domain.QRCodeDynamic,
+		URL:       "https://example.com/assets/1",
+		CreatedBy: "user_123",
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, dynamic.ShortCode)
+
+	iphone := &ScanClient{
+		IP:             "203.0.113.7",
+		UserAgent:      "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1",
+		Referrer:       "https://intranet.example.com/",
+		AcceptLanguage: "zh-CN,zh;q=0.9,en;q=0.8",
+	}
+
+	// Test ResolveShortCode redirects to the target URL and records the scan
+	t.Run("ResolveShortCode", func(t *testing.T) {
+		target, err := scanService.ResolveShortCode(ctx, *dynamic.ShortCode, iphone)
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/assets/1", target)
+
+		var scan domain.QRCodeScan
+		assert.NoError(t, testDB.Where("qr_code_id = ?", dynamic.ID).First(&scan).Error)
+		assert.Equal(t, "mobile", scan.Device)
+		assert.Equal(t, "iOS", scan.OS)
+		assert.Equal(t, "Safari", scan.Browser)
+		assert.Equal(t, "zh-CN", scan.Language)
+		assert.Equal(t, "https://intranet.example.com/", scan.Referrer)
+		assert.Equal(t, time.Now().UTC().Format("2006-01-02"), scan.ScanDate)
+		assert.NotContains(t, scan.VisitorID, "203.0.113.7")
+	})
+
+	// Test the target URL can change after the code is printed
+	t.Run("ChangeTargetURL", func(t *testing.T) {
+		err := qrCodeService.UpdateQRCode(ctx, dynamic.ID, &UpdateQRCodeRequest{URL: "https://example.com/assets/1/v2"})
+		assert.NoError(t, err)
+
+		target, err := scanService.ResolveShortCode(ctx, *dynamic.ShortCode, &ScanClient{IP: "198.51.100.1", UserAgent: "curl/8.0"})
+		assert.NoError(t, err)
+		assert.Equal(t, "https://example.com/assets/1/v2", target)
+
+		err = qrCodeService.UpdateQRCode(ctx, dynamic.ID, &UpdateQRCodeRequest{URL: "javascript:alert(1)"})
+		assert.EqualError(t, err, "invalid target URL")
+	})
+
+	// Test unknown short codes are not found
+	t.Run("ResolveShortCodeNotFound", func(t *testing.T) {
+		_, err := scanService.ResolveShortCode(ctx, "missing", iphone)
+		assert.EqualError(t, err, "QR code not found")
+	})
+
+	// Test GetQRCodeStats counts scans and unique visitors per day
+	t.Run("GetQRCodeStats", func(t *testing.T) {
+		_, err := scanService.ResolveShortCode(ctx, *dynamic.ShortCode, iphone)
+		assert.NoError(t, err)
+
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		stats, err := scanService.GetQRCodeStats(ctx, dynamic.ID, today.AddDate(0, 0, -6), today)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), stats.TotalScans)
+		assert.Equal(t, int64(2), stats.UniqueVisitors)
+		assert.Len(t, stats.Daily, 7)
+		assert.Equal(t, int64(0), stats.Daily[0].Scans)
+		assert.Equal(t, today.Format("2006-01-02"), stats.Daily[6].Date)
+		assert.Equal(t, int64(3), stats.Daily[6].Scans)
+		assert.Equal(t, int64(2), stats.Daily[6].UniqueVisitors)
+		assert.Empty(t, stats.TopCodes)
+
+		_, err = scanService.GetQRCodeStats(ctx, dynamic.ID, today, today.AddDate(0, 0, -1))
+		assert.EqualError(t, err, "invalid date range")
+
+		_, err = scanService.GetQRCodeStats(ctx, "qrcode_missing", today, today)
+		assert.EqualError(t, err, "QR code not found")
+	})
+
+	// Test GetBatchStats ranks the codes of a dynamic batch
+	t.Run("GetBatchStats", func(t *testing.T) {
+		batchService := NewBatchQRCodeServiceWithDB(testDB, config.Default())
+		batch, err := batchService.CreateBatchQRCode(ctx, &CreateBatchQRCodeRequest{
+			AppID:       "app_scan",
+			Name:        "Tags",
+			Count:       3,
+			Type:        domain.QRCodeDynamic,
+			URLTemplate: "https://example.com/tags/{index}",
+			CreatedBy:   "user_123",
+		})
+		assert.NoError(t, err)
+		_, err = batchService.GenerateBatchQRCodes(ctx, batch.ID)
+		assert.NoError(t, err)
+
+		var qrCodes []*domain.QRCode
+		testDB.Where("batch_id = ?", batch.ID).Order("name").Find(&qrCodes)
+		assert.Len(t, qrCodes, 3)
+		for i, qrCode := range qrCodes {
+			assert.NotNil(t, qrCode.ShortCode)
+			for j := 0; j <= i; j++ {
+				_, err := scanService.ResolveShortCode(ctx, *qrCode.ShortCode, iphone)
+				assert.NoError(t, err)
+			}
+		}
+
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		stats, err := scanService.GetBatchStats(ctx, batch.ID, today, today)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(6), stats.TotalScans)
+		assert.Equal(t, int64(1), stats.UniqueVisitors)
+		assert.Len(t, stats.TopCodes, 3)
+		assert.Equal(t, qrCodes[2].ID, stats.TopCodes[0].QRCodeID)
+		assert.Equal(t, "Tags_3", stats.TopCodes[0].Name)
+		assert.Equal(t, int64(3), stats.TopCodes[0].Scans)
+
+		_, err = scanService.GetBatchStats(ctx, "batch_missing", today, today)
+		assert.EqualError(t, err, "batch QR code not found")
+	})
+}
+
+// TestParseUserAgent tests classifying user agents
+func TestParseUserAgent(t *testing.T) {
+	cases := []struct {
+		userAgent string
+		device    string
+		os        string
+		browser   string
+	}{
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36 Edg/126.0", "desktop", "Windows", "Edge"},
+		{"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Mobile Safari/537.36", "mobile", "Android", "Chrome"},
+		{"Mozilla/5.0 (Linux; Android 13; SM-X710) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36", "tablet", "Android", "Chrome"},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 MicroMessenger/8.0.47", "mobile", "iOS", "WeChat"},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_5; rv:127.0) Gecko/20100101 Firefox/127.0", "desktop", "macOS", "Firefox"},
+		{"Googlebot/2.1 (+http://www.google.com/bot.html)", "bot", "other", "other"},
+		{"", "unknown", "unknown", "unknown"},
+	}
+	for _, tc := range cases {
+		device, os, browser := parseUserAgent(tc.userAgent)
+		assert.Equal(t, tc.device, device, tc.userAgent)
+		assert.Equal(t, tc.os, os, tc.userAgent)
+		assert.Equal(t, tc.browser, browser, tc.userAgent)
+	}
+}
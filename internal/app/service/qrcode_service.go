@@ -3,15 +3,18 @@ package service
 import (
 	"context"
 	"errors"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"cdk-office/internal/app/domain"
+	"cdk-office/internal/app/qrrender"
 	"cdk-office/internal/shared/database"
 	"cdk-office/internal/shared/utils"
+	"cdk-office/pkg/config"
 	"cdk-office/pkg/logger"
-	"github.com/yougg/go-qrcode"
 	"gorm.io/gorm"
 )
 
@@ -25,15 +28,20 @@ type QRCodeServiceInterface interface {
 	GenerateQRCodeImage(ctx context.Context, qrCodeID string) (string, error)
 }
 
+// shortCodeLength is the length of the short codes of dynamic QR codes
+const shortCodeLength = 8
+
 // QRCodeService implements the QRCodeServiceInterface
 type QRCodeService struct {
-	db *gorm.DB
+	db     *gorm.DB
+	config *config.QRCodeConfig
 }
 
 // NewQRCodeService creates a new instance of QRCodeService
-func NewQRCodeService() *QRCodeService {
+func NewQRCodeService(cfg *config.QRCodeConfig) *QRCodeService {
 	return &QRCodeService{
-		db: database.GetDB(),
+		db:     database.GetDB(),
+		config: cfg,
 	}
 }
 
@@ -57,10 +65,21 @@ type UpdateQRCodeRequest struct {
 // CreateQRCode creates a new QR code
 func (s *QRCodeService) CreateQRCode(ctx context.Context, req *CreateQRCodeRequest) (*domain.QRCode, error) {
 	// Validate QR code type
-	if req.Type != "static" && req.Type != "dynamic" {
+	if req.Type != domain.QRCodeStatic && req.Type != domain.QRCodeDynamic {
 		return nil, errors.New("invalid QR code type")
 	}
 
+	// Dynamic QR codes redirect to their URL, falling back to the content
+	target := req.URL
+	if req.Type == domain.QRCodeDynamic {
+		if target == "" {
+			target = req.Content
+		}
+		if !isRedirectURL(target) {
+			return nil, errors.New("invalid target URL")
+		}
+	}
+
 	// Create new QR code
 	qrCode := &domain.QRCode{
 		ID:        utils.GenerateQRCodeID(),
@@ -68,11 +87,14 @@ func (s *QRCodeService) CreateQRCode(ctx context.Context, req *CreateQRCodeReque
 		Name:      req.Name,
 		Content:   req.Content,
 		Type:      req.Type,
-		URL:       req.URL,
+		URL:       target,
 		CreatedBy: req.CreatedBy,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
+	if req.Type == domain.QRCodeDynamic {
+		qrCode.ShortCode = newShortCode()
+	}
 
 	// Save QR code to database
 	if err := s.db.Create(qrCode).Error; err != nil {
@@ -118,6 +140,10 @@ func (s *QRCodeService) UpdateQRCode(ctx context.Context, qrCodeID string, req *
 	}
 	
 	if req.URL != "" {
+		// The target of a dynamic QR code can change without reprinting it
+		if qrCode.Type == domain.QRCodeDynamic && !isRedirectURL(req.URL) {
+			return errors.New("invalid target URL")
+		}
 		qrCode.URL = req.URL
 	}
 	
@@ -226,27 +252,52 @@ func (s *QRCodeService) GenerateQRCodeImage(ctx context.Context, qrCodeID string
 		return "", errors.New("failed to generate QR code image")
 	}
 
+	return writeQRCodeImage(s.config, &qrCode, qrrender.DefaultOptions())
+}
+
+// writeQRCodeImage renders a QR code into the image directory and returns the image path
+func writeQRCodeImage(cfg *config.QRCodeConfig, qrCode *domain.QRCode, opts *qrrender.Options) (string, error) {
 	// Create the directory for QR code images if it doesn't exist
-	imageDir := "/tmp/qrcodes"
-	if err := os.MkdirAll(imageDir, 0755); err != nil {
+	if err := os.MkdirAll(cfg.ImageDir, 0755); err != nil {
 		logger.Error("failed to create QR code directory", "error", err)
 		return "", errors.New("failed to create QR code directory")
 	}
 
-	// Generate QR code image using the go-qrcode library
-	qr, err := qrcode.New(qrCode.Content, qrcode.Level(qrcode.High))
+	// Render the QR code image into a file
+	imagePath := filepath.Join(cfg.ImageDir, qrCode.ID+opts.Extension())
+	file, err := os.Create(imagePath)
 	if err != nil {
-		logger.Error("failed to create QR code", "error", err)
-		return "", errors.New("failed to generate QR code image")
+		logger.Error("failed to create QR code image", "error", err)
+		return "", errors.New("failed to save QR code image")
 	}
+	defer file.Close()
 
-	// Save the QR code image to a file
-	imagePath := filepath.Join(imageDir, qrCode.ID+".png")
-	if err := qr.WriteFile(imagePath); err != nil {
-		logger.Error("failed to save QR code image", "error", err)
-		return "", errors.New("failed to save QR code image")
+	if err := qrrender.Render(file, encodedContent(cfg, qrCode), opts); err != nil {
+		logger.Error("failed to render QR code image", "error", err)
+		return "", errors.New("failed to generate QR code image")
 	}
 
 	return imagePath, nil
 }
 
+
+// encodedContent returns the data encoded in a QR code's image. Dynamic codes
+// encode their short link instead of the content.
+func encodedContent(cfg *config.QRCodeConfig, qrCode *domain.QRCode) string {
+	if qrCode.Type == domain.QRCodeDynamic && qrCode.ShortCode != nil {
+		return strings.TrimSuffix(cfg.ShortLinkBaseURL, "/") + "/q/" + *qrCode.ShortCode
+	}
+	return qrCode.Content
+}
+
+// newShortCode returns a new short code for a dynamic QR code
+func newShortCode() *string {
+	code := utils.GenerateShortCode(shortCodeLength)
+	return &code
+}
+
+// isRedirectURL reports whether u is an absolute http or https URL
+func isRedirectURL(u string) bool {
+	parsed, err := url.Parse(u)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"cdk-office/internal/shared/testutils"
+	"cdk-office/pkg/config"
 )
 
 // TestQRCodeService tests the QRCodeService
@@ -14,7 +15,7 @@ func TestQRCodeService(t *testing.T) {
 	testDB := testutils.SetupTestDB()
 
 	// Create QR code service with database connection
-	qrCodeService := NewQRCodeService()
+	qrCodeService := NewQRCodeService(&config.Default().QRCode)
 
 	// Replace the database connection with the test database
 	qrCodeService.db = testDB
@@ -0,0 +1,131 @@
+package domain
+
+import (
+	"time"
+)
+
+// Approval request statuses
+const (
+	ApprovalPending   = "pending"
+	ApprovalApproved  = "approved"
+	ApprovalRejected  = "rejected"
+	ApprovalCancelled = "cancelled"
+)
+
+// Approval step orders
+const (
+	StepOrderSequential = "sequential" // each step starts when the previous one is approved
+	StepOrderParallel   = "parallel"   // every step starts at once
+)
+
+// Approval step modes
+const (
+	StepModeAny = "any" // one approval completes the step
+	StepModeAll = "all" // every approver of the step must approve
+)
+
+// Approver rule types
+const (
+	ApproverRole           = "role"            // users holding a role
+	ApproverDepartmentHead = "department_head" // the manager of a department up the department tree
+	ApproverUser           = "user"            // a named user
+)
+
+// Approval task statuses
+const (
+	TaskPending  = "pending"
+	TaskApproved = "approved"
+	TaskRejected = "rejected"
+	TaskSkipped  = "skipped" // closed because the step or request was decided without it
+)
+
+// ApprovalDefinition defines the approval steps an action on an entity type
+// requires within a team
+type ApprovalDefinition struct {
+	ID          string    `json:"id" gorm:"primaryKey"`
+	TeamID      string    `json:"team_id" gorm:"index"`
+	Name        string    `json:"name" gorm:"size:100"`
+	Description string    `json:"description" gorm:"type:text"`
+	EntityType  string    `json:"entity_type" gorm:"size:50"`
+	Action      string    `json:"action" gorm:"size:50"`
+	StepOrder   string    `json:"step_order" gorm:"size:20"`
+	Steps       string    `json:"steps" gorm:"type:jsonb"` // []ApprovalStep
+	IsActive    bool      `json:"is_active"`
+	CreatedBy   string    `json:"created_by" gorm:"size:50"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ApprovalStep is a step of an approval definition
+type ApprovalStep struct {
+	Name      string         `json:"name"`
+	Mode      string         `json:"mode"` // any (the default) or all
+	Approvers []ApproverRule `json:"approvers"`
+	// SLAHours is the time approvers have to decide, 0 for no limit
+	SLAHours int `json:"sla_hours"`
+	// EscalateTo are the approvers who may decide in place of an overdue approver
+	EscalateTo []ApproverRule `json:"escalate_to"`
+}
+
+// ApproverRule resolves the approvers of a step
+type ApproverRule struct {
+	Type   string `json:"type"`
+	Role   string `json:"role,omitempty"`
+	UserID string `json:"user_id,omitempty"`
+	// Level selects the department head: 1 heads the department of the request,
+	// 2 the closest department above it with a manager, and so on
+	Level int `json:"level,omitempty"`
+}
+
+// ApprovalRequest is an action on an entity awaiting approval
+type ApprovalRequest struct {
+	ID           string `json:"id" gorm:"primaryKey"`
+	DefinitionID string `json:"definition_id" gorm:"index"`
+	TeamID       string `json:"team_id" gorm:"index"`
+	// An entity has at most one pending request per action
+	EntityType   string `json:"entity_type" gorm:"size:50;uniqueIndex:idx_approval_requests_pending,where:status = 'pending'"`
+	EntityID     string `json:"entity_id" gorm:"size:50;uniqueIndex:idx_approval_requests_pending"`
+	Action       string `json:"action" gorm:"size:50;uniqueIndex:idx_approval_requests_pending"`
+	DepartmentID string `json:"department_id" gorm:"size:36"` // department whose heads approve
+	RequestedBy  string `json:"requested_by" gorm:"size:50"`
+	Payload      string `json:"payload" gorm:"type:text"` // data the action is applied with
+	Status       string `json:"status" gorm:"size:20"`
+	StepOrder    string `json:"step_order" gorm:"size:20"`
+	Steps        string `json:"steps" gorm:"type:jsonb"` // the definition steps when submitted
+	CurrentStep  int    `json:"current_step"`
+	// ApplyError is the error of the registered hook applying the outcome, if it failed
+	ApplyError  string     `json:"apply_error" gorm:"type:text"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// ApprovalTask is the decision of one approver on a step of a request
+type ApprovalTask struct {
+	ID            string `json:"id" gorm:"primaryKey"`
+	RequestID     string `json:"request_id" gorm:"index"`
+	Step          int    `json:"step"`
+	ApproverID    string `json:"approver_id" gorm:"size:50;index"`
+	DelegatedFrom string `json:"delegated_from" gorm:"size:50"` // approver who delegated the task
+	// EscalatedFrom is the overdue task this task may decide in place of
+	EscalatedFrom string     `json:"escalated_from" gorm:"size:50"`
+	Status        string     `json:"status" gorm:"size:20"`
+	DueAt         *time.Time `json:"due_at"`
+	EscalatedAt   *time.Time `json:"escalated_at"`
+	Comment       string     `json:"comment" gorm:"type:text"`
+	DecidedAt     *time.Time `json:"decided_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// ApprovalDelegation hands the approvals of a user to another user for a period
+type ApprovalDelegation struct {
+	ID          string    `json:"id" gorm:"primaryKey"`
+	DelegatorID string    `json:"delegator_id" gorm:"size:50;index"`
+	DelegateID  string    `json:"delegate_id" gorm:"size:50"`
+	EntityType  string    `json:"entity_type" gorm:"size:50"` // empty for every entity type
+	StartsAt    time.Time `json:"starts_at"`
+	EndsAt      time.Time `json:"ends_at"`
+	Reason      string    `json:"reason" gorm:"type:text"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,419 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cdk-office/internal/approval/domain"
+	"cdk-office/internal/approval/service"
+	"github.com/gin-gonic/gin"
+)
+
+// adminRole is the role allowed to manage approval definitions
+const adminRole = "admin"
+
+// ApprovalHandlerInterface defines the interface for approval handler
+type ApprovalHandlerInterface interface {
+	CreateDefinition(c *gin.Context)
+	UpdateDefinition(c *gin.Context)
+	DeleteDefinition(c *gin.Context)
+	ListDefinitions(c *gin.Context)
+	GetDefinition(c *gin.Context)
+	ListRequests(c *gin.Context)
+	GetRequest(c *gin.Context)
+	ApproveRequest(c *gin.Context)
+	RejectRequest(c *gin.Context)
+	CancelRequest(c *gin.Context)
+	ListPendingTasks(c *gin.Context)
+	CreateDelegation(c *gin.Context)
+	DeleteDelegation(c *gin.Context)
+	ListDelegations(c *gin.Context)
+}
+
+// ApprovalHandler implements the ApprovalHandlerInterface
+type ApprovalHandler struct {
+	approvalService service.ApprovalServiceInterface
+}
+
+// NewApprovalHandler creates a new instance of ApprovalHandler
+func NewApprovalHandler() *ApprovalHandler {
+	return NewApprovalHandlerWithService(service.NewApprovalService())
+}
+
+// NewApprovalHandlerWithService creates a new instance of ApprovalHandler with a specific approval service
+func NewApprovalHandlerWithService(approvalService service.ApprovalServiceInterface) *ApprovalHandler {
+	return &ApprovalHandler{
+		approvalService: approvalService,
+	}
+}
+
+// CreateDefinitionRequest represents the request for creating an approval definition
+type CreateDefinitionRequest struct {
+	TeamID      string                `json:"team_id" binding:"required"`
+	Name        string                `json:"name" binding:"required"`
+	Description string                `json:"description"`
+	EntityType  string                `json:"entity_type" binding:"required"`
+	Action      string                `json:"action" binding:"required"`
+	StepOrder   string                `json:"step_order"`
+	Steps       []domain.ApprovalStep `json:"steps" binding:"required"`
+	IsActive    bool                  `json:"is_active"`
+}
+
+// UpdateDefinitionRequest represents the request for updating an approval definition
+type UpdateDefinitionRequest struct {
+	Name        string                `json:"name"`
+	Description string                `json:"description"`
+	StepOrder   string                `json:"step_order"`
+	Steps       []domain.ApprovalStep `json:"steps"`
+	IsActive    *bool                 `json:"is_active"`
+}
+
+// DecisionRequest represents the request for approving or rejecting an approval request
+type DecisionRequest struct {
+	Comment string `json:"comment"`
+}
+
+// CreateDelegationRequest represents the request for delegating the approvals of the current user
+type CreateDelegationRequest struct {
+	DelegateID string    `json:"delegate_id" binding:"required"`
+	EntityType string    `json:"entity_type"`
+	StartsAt   time.Time `json:"starts_at" binding:"required"`
+	EndsAt     time.Time `json:"ends_at" binding:"required"`
+	Reason     string    `json:"reason"`
+}
+
+// ListRequestsResponse represents the response for listing approval requests
+type ListRequestsResponse struct {
+	Items []*domain.ApprovalRequest `json:"items"`
+	Total int64                     `json:"total"`
+	Page  int                       `json:"page"`
+	Size  int                       `json:"size"`
+}
+
+// CreateDefinition handles creating a new approval definition
+func (h *ApprovalHandler) CreateDefinition(c *gin.Context) {
+	if !checkDefinitionManager(c) {
+		return
+	}
+
+	var req CreateDefinitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Call service to create definition
+	definition, err := h.approvalService.CreateDefinition(c.Request.Context(), &service.CreateDefinitionRequest{
+		TeamID:      req.TeamID,
+		Name:        req.Name,
+		Description: req.Description,
+		EntityType:  req.EntityType,
+		Action:      req.Action,
+		StepOrder:   req.StepOrder,
+		Steps:       req.Steps,
+		IsActive:    req.IsActive,
+		CreatedBy:   c.GetString("user_id"),
+	})
+	if err != nil {
+		c.JSON(approvalErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, definition)
+}
+
+// UpdateDefinition handles updating an existing approval definition
+func (h *ApprovalHandler) UpdateDefinition(c *gin.Context) {
+	if !checkDefinitionManager(c) {
+		return
+	}
+
+	definitionID := c.Param("id")
+	if definitionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "definition id is required"})
+		return
+	}
+
+	var req UpdateDefinitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Call service to update definition
+	if err := h.approvalService.UpdateDefinition(c.Request.Context(), definitionID, &service.UpdateDefinitionRequest{
+		Name:        req.Name,
+		Description: req.Description,
+		StepOrder:   req.StepOrder,
+		Steps:       req.Steps,
+		IsActive:    req.IsActive,
+	}); err != nil {
+		c.JSON(approvalErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "approval definition updated successfully"})
+}
+
+// DeleteDefinition handles deleting an approval definition
+func (h *ApprovalHandler) DeleteDefinition(c *gin.Context) {
+	if !checkDefinitionManager(c) {
+		return
+	}
+
+	definitionID := c.Param("id")
+	if definitionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "definition id is required"})
+		return
+	}
+
+	// Call service to delete definition
+	if err := h.approvalService.DeleteDefinition(c.Request.Context(), definitionID); err != nil {
+		c.JSON(approvalErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "approval definition deleted successfully"})
+}
+
+// ListDefinitions handles listing the approval definitions of a team
+func (h *ApprovalHandler) ListDefinitions(c *gin.Context) {
+	teamID := c.Query("team_id")
+	if teamID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id is required"})
+		return
+	}
+
+	// Call service to list definitions
+	definitions, err := h.approvalService.ListDefinitions(c.Request.Context(), teamID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, definitions)
+}
+
+// GetDefinition handles retrieving an approval definition by ID
+func (h *ApprovalHandler) GetDefinition(c *gin.Context) {
+	definitionID := c.Param("id")
+	if definitionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "definition id is required"})
+		return
+	}
+
+	// Call service to get definition
+	definition, err := h.approvalService.GetDefinition(c.Request.Context(), definitionID)
+	if err != nil {
+		c.JSON(approvalErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, definition)
+}
+
+// ListRequests handles listing the approval requests of a team the current user
+// may see with pagination
+func (h *ApprovalHandler) ListRequests(c *gin.Context) {
+	teamID := c.Query("team_id")
+	if teamID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id is required"})
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	size, err := strconv.Atoi(c.DefaultQuery("size", "10"))
+	if err != nil || size < 1 || size > 100 {
+		size = 10
+	}
+
+	// Call service to list requests
+	requests, total, err := h.approvalService.ListRequests(c.Request.Context(), c.GetString("user_id"), c.GetString("role"), teamID, c.Query("status"), page, size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, ListRequestsResponse{
+		Items: requests,
+		Total: total,
+		Page:  page,
+		Size:  size,
+	})
+}
+
+// GetRequest handles retrieving an approval request the current user may see
+// with its tasks
+func (h *ApprovalHandler) GetRequest(c *gin.Context) {
+	requestID := c.Param("id")
+	if requestID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "request id is required"})
+		return
+	}
+
+	// Call service to get request
+	detail, err := h.approvalService.GetRequest(c.Request.Context(), requestID, c.GetString("user_id"), c.GetString("role"))
+	if err != nil {
+		c.JSON(approvalErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+// ApproveRequest handles the current user approving an approval request
+func (h *ApprovalHandler) ApproveRequest(c *gin.Context) {
+	h.decide(c, true)
+}
+
+// RejectRequest handles the current user rejecting an approval request
+func (h *ApprovalHandler) RejectRequest(c *gin.Context) {
+	h.decide(c, false)
+}
+
+// decide handles a decision on an approval request
+func (h *ApprovalHandler) decide(c *gin.Context, approve bool) {
+	requestID := c.Param("id")
+	if requestID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "request id is required"})
+		return
+	}
+
+	// The comment is optional, so an empty body is accepted
+	var req DecisionRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Call service to record the decision
+	decide, message := h.approvalService.Approve, "approval request approved successfully"
+	if !approve {
+		decide, message = h.approvalService.Reject, "approval request rejected successfully"
+	}
+	if err := decide(c.Request.Context(), requestID, c.GetString("user_id"), req.Comment); err != nil {
+		c.JSON(approvalErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// CancelRequest handles the current user cancelling an approval request they made
+func (h *ApprovalHandler) CancelRequest(c *gin.Context) {
+	requestID := c.Param("id")
+	if requestID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "request id is required"})
+		return
+	}
+
+	// Call service to cancel request
+	if err := h.approvalService.Cancel(c.Request.Context(), requestID, c.GetString("user_id")); err != nil {
+		c.JSON(approvalErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "approval request cancelled successfully"})
+}
+
+// ListPendingTasks handles listing the tasks awaiting a decision of the current user
+func (h *ApprovalHandler) ListPendingTasks(c *gin.Context) {
+	// Call service to list tasks
+	tasks, err := h.approvalService.ListPendingTasks(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tasks)
+}
+
+// CreateDelegation handles delegating the approvals of the current user
+func (h *ApprovalHandler) CreateDelegation(c *gin.Context) {
+	var req CreateDelegationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Call service to create delegation
+	delegation, err := h.approvalService.CreateDelegation(c.Request.Context(), &service.CreateDelegationRequest{
+		DelegatorID: c.GetString("user_id"),
+		DelegateID:  req.DelegateID,
+		EntityType:  req.EntityType,
+		StartsAt:    req.StartsAt,
+		EndsAt:      req.EndsAt,
+		Reason:      req.Reason,
+	})
+	if err != nil {
+		c.JSON(approvalErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, delegation)
+}
+
+// DeleteDelegation handles the current user ending a delegation they made
+func (h *ApprovalHandler) DeleteDelegation(c *gin.Context) {
+	delegationID := c.Param("id")
+	if delegationID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "delegation id is required"})
+		return
+	}
+
+	// Call service to delete delegation
+	if err := h.approvalService.DeleteDelegation(c.Request.Context(), delegationID, c.GetString("user_id")); err != nil {
+		c.JSON(approvalErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "approval delegation deleted successfully"})
+}
+
+// ListDelegations handles listing the delegations from and to the current user
+func (h *ApprovalHandler) ListDelegations(c *gin.Context) {
+	// Call service to list delegations
+	delegations, err := h.approvalService.ListDelegations(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, delegations)
+}
+
+// checkDefinitionManager responds with an error unless the current user is an
+// admin, and reports whether they are. Definitions decide who approves actions,
+// so other users may not change them.
+func checkDefinitionManager(c *gin.Context) bool {
+	if c.GetString("role") != adminRole {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only administrators can manage approval definitions"})
+		return false
+	}
+	return true
+}
+
+// approvalErrorStatus maps approval errors to HTTP status codes
+func approvalErrorStatus(err error) int {
+	switch msg := err.Error(); {
+	case msg == "approval definition not found" || msg == "approval request not found" || msg == "approval delegation not found":
+		return http.StatusNotFound
+	case msg == "user is not an approver of this request" || msg == "only the requester can cancel this approval request" ||
+		msg == "only the delegator can delete this delegation":
+		return http.StatusForbidden
+	case msg == "approval is already pending" || msg == "approval request is not pending" ||
+		strings.HasPrefix(msg, "no approvers found"):
+		return http.StatusConflict
+	case strings.HasPrefix(msg, "invalid definition") || strings.HasPrefix(msg, "invalid delegation"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cdk-office/internal/approval/domain"
+	"cdk-office/internal/approval/service"
+	employeedomain "cdk-office/internal/employee/domain"
+	"cdk-office/internal/shared/testutils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestApprovalHandlerActor tests that decisions, tasks and delegations act as
+// the authenticated user, whatever the request says, that only admins manage
+// definitions and that requests are only shown to those involved
+func TestApprovalHandlerActor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutils.SetupTestDB()
+	require.NoError(t, db.Create(&employeedomain.Department{ID: "dept_1", TeamID: "team_1", Name: "Sales", ManagerID: "carol"}).Error)
+	require.NoError(t, db.Create(&employeedomain.Employee{ID: "emp_1", UserID: "alice", TeamID: "team_1", DeptID: "dept_1", EmployeeID: "E1"}).Error)
+
+	approvalService := service.NewApprovalServiceWithDB(db)
+	ctx := context.Background()
+	_, err := approvalService.CreateDefinition(ctx, &service.CreateDefinitionRequest{
+		TeamID: "team_1", Name: "Contracts", EntityType: "contract", Action: "send", IsActive: true, CreatedBy: "admin",
+		Steps: []domain.ApprovalStep{{Name: "Manager", Approvers: []domain.ApproverRule{{Type: domain.ApproverDepartmentHead}}}},
+	})
+	require.NoError(t, err)
+	request, err := approvalService.Submit(ctx, &service.SubmitRequest{TeamID: "team_1", EntityType: "contract", EntityID: "c1", Action: "send", RequestedBy: "alice"})
+	require.NoError(t, err)
+
+	handler := NewApprovalHandlerWithService(approvalService)
+	routerAs := func(userID string) *gin.Engine {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", userID)
+			if userID == "admin" {
+				c.Set("role", "admin")
+			}
+		})
+		router.POST("/approvals/definitions", handler.CreateDefinition)
+		router.DELETE("/approvals/definitions/:id", handler.DeleteDefinition)
+		router.GET("/approvals/requests", handler.ListRequests)
+		router.GET("/approvals/requests/:id", handler.GetRequest)
+		router.POST("/approvals/requests/:id/approve", handler.ApproveRequest)
+		router.GET("/approvals/tasks", handler.ListPendingTasks)
+		router.POST("/approvals/delegations", handler.CreateDelegation)
+		return router
+	}
+
+	// Only admins manage definitions
+	definition := `{"team_id":"team_1","name":"Self approval","entity_type":"contract","action":"send","steps":[{"approvers":[{"type":"user","user_id":"mallory"}]}]}`
+	for userID, status := range map[string]int{"mallory": http.StatusForbidden, "admin": http.StatusOK} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/approvals/definitions", strings.NewReader(definition))
+		req.Header.Set("Content-Type", "application/json")
+		routerAs(userID).ServeHTTP(w, req)
+		assert.Equal(t, status, w.Code, userID)
+	}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodDelete, "/approvals/definitions/"+request.DefinitionID, nil)
+	routerAs("mallory").ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Requests are seen by the requester, their approvers and the team
+	for userID, status := range map[string]int{"alice": http.StatusOK, "carol": http.StatusOK, "admin": http.StatusOK, "mallory": http.StatusNotFound} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/approvals/requests/"+request.ID, nil)
+		routerAs(userID).ServeHTTP(w, req)
+		assert.Equal(t, status, w.Code, userID)
+	}
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/approvals/requests?team_id=team_1", nil)
+	routerAs("mallory").ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"total":0`)
+
+	// Naming the approver in the body or query string has no effect
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/approvals/requests/"+request.ID+"/approve", strings.NewReader(`{"approver_id":"carol"}`))
+	req.Header.Set("Content-Type", "application/json")
+	routerAs("mallory").ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/approvals/tasks?approver_id=carol", nil)
+	routerAs("mallory").ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "[]", w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/approvals/delegations", strings.NewReader(
+		`{"delegator_id":"carol","delegate_id":"mallory","starts_at":"2026-01-01T00:00:00Z","ends_at":"2026-12-31T00:00:00Z"}`))
+	req.Header.Set("Content-Type", "application/json")
+	routerAs("mallory").ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "delegator and delegate must be different users")
+
+	// The approver decides without a body
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/approvals/requests/"+request.ID+"/approve", http.NoBody)
+	routerAs("carol").ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
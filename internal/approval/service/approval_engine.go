@@ -0,0 +1,562 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"cdk-office/internal/approval/domain"
+	authdomain "cdk-office/internal/auth/domain"
+	employeedomain "cdk-office/internal/employee/domain"
+	"cdk-office/internal/shared/utils"
+	"cdk-office/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// SubmitRequest represents an action on an entity that may require approval
+type SubmitRequest struct {
+	TeamID      string
+	EntityType  string
+	EntityID    string
+	Action      string
+	RequestedBy string
+	// DepartmentID is the department whose heads approve. It defaults to the
+	// department of the requester's employee record in the team.
+	DepartmentID string
+	// Payload is the data the hook applies the action with
+	Payload string
+}
+
+// ApprovalRequiredError reports that an action was submitted for approval
+// instead of being performed
+type ApprovalRequiredError struct {
+	Request *domain.ApprovalRequest
+}
+
+// Error implements the error interface
+func (e *ApprovalRequiredError) Error() string {
+	return "approval required: request " + e.Request.ID + " is pending"
+}
+
+// RequireApproval submits an action for approval when the team requires one.
+// It returns nil when the action may be performed right away and an
+// *ApprovalRequiredError once it has been submitted.
+func RequireApproval(ctx context.Context, approvals ApprovalServiceInterface, req *SubmitRequest) error {
+	if approvals == nil {
+		return nil
+	}
+	request, err := approvals.Submit(ctx, req)
+	if err != nil {
+		return err
+	}
+	if request != nil {
+		return &ApprovalRequiredError{Request: request}
+	}
+	return nil
+}
+
+// Submit starts the approval of an action when the team has an active
+// definition for it. It returns nil when the action needs no approval.
+func (s *ApprovalService) Submit(ctx context.Context, req *SubmitRequest) (*domain.ApprovalRequest, error) {
+	var definition domain.ApprovalDefinition
+	err := s.db.Where("team_id = ? AND entity_type = ? AND action = ? AND is_active = ?", req.TeamID, req.EntityType, req.Action, true).
+		First(&definition).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		logger.Error("failed to find approval definition", "error", err)
+		return nil, errors.New("failed to submit approval request")
+	}
+	steps, err := definitionSteps(definition.Steps)
+	if err != nil {
+		return nil, errors.New("failed to submit approval request")
+	}
+
+	now := time.Now()
+	request := &domain.ApprovalRequest{
+		ID:           utils.GenerateApprovalRequestID(),
+		DefinitionID: definition.ID,
+		TeamID:       req.TeamID,
+		EntityType:   req.EntityType,
+		EntityID:     req.EntityID,
+		Action:       req.Action,
+		DepartmentID: req.DepartmentID,
+		RequestedBy:  req.RequestedBy,
+		Payload:      req.Payload,
+		Status:       domain.ApprovalPending,
+		StepOrder:    definition.StepOrder,
+		Steps:        definition.Steps,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if request.DepartmentID == "" {
+		var employee employeedomain.Employee
+		err := s.db.Where("user_id = ? AND team_id = ?", req.RequestedBy, req.TeamID).First(&employee).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Error("failed to find requester employee", "error", err)
+			return nil, errors.New("failed to submit approval request")
+		}
+		request.DepartmentID = employee.DeptID
+	}
+
+	// The unique index on pending requests refuses a second one for the action
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(request).Error; err != nil {
+			return err
+		}
+		if request.StepOrder == domain.StepOrderParallel {
+			for i := range steps {
+				if err := s.startStep(tx, request, steps, i, now); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return s.startStep(tx, request, steps, 0, now)
+	})
+	if err != nil {
+		if isApprovalError(err) {
+			return nil, err
+		}
+		var pending int64
+		if s.db.Model(&domain.ApprovalRequest{}).Where("entity_type = ? AND entity_id = ? AND action = ? AND status = ?",
+			req.EntityType, req.EntityID, req.Action, domain.ApprovalPending).Count(&pending).Error == nil && pending > 0 {
+			return nil, errors.New("approval is already pending")
+		}
+		logger.Error("failed to submit approval request", "error", err)
+		return nil, errors.New("failed to submit approval request")
+	}
+
+	return request, nil
+}
+
+// Approve records the approval of an approver. Completing the last step
+// approves the request and applies it through the hook of its entity type.
+func (s *ApprovalService) Approve(ctx context.Context, requestID, approverID, comment string) error {
+	return s.decide(ctx, requestID, approverID, comment, true)
+}
+
+// Reject records the rejection of an approver, which rejects the request
+func (s *ApprovalService) Reject(ctx context.Context, requestID, approverID, comment string) error {
+	return s.decide(ctx, requestID, approverID, comment, false)
+}
+
+// Cancel withdraws a pending request. Only the requester can cancel it.
+func (s *ApprovalService) Cancel(ctx context.Context, requestID, userID string) error {
+	return s.withPendingRequest(requestID, "failed to cancel approval request", func(tx *gorm.DB, request *domain.ApprovalRequest, now time.Time) error {
+		if request.RequestedBy != userID {
+			return errors.New("only the requester can cancel this approval request")
+		}
+		request.Status = domain.ApprovalCancelled
+		request.CompletedAt = &now
+		if err := skipPendingTasks(tx, request.ID, -1, now); err != nil {
+			return err
+		}
+		return tx.Save(request).Error
+	})
+}
+
+// decide records the decision of an approver on every pending task they hold
+// on a request
+func (s *ApprovalService) decide(ctx context.Context, requestID, approverID, comment string, approve bool) error {
+	var decided *domain.ApprovalRequest
+	err := s.withPendingRequest(requestID, "failed to decide approval request", func(tx *gorm.DB, request *domain.ApprovalRequest, now time.Time) error {
+		var tasks []*domain.ApprovalTask
+		if err := tx.Where("request_id = ? AND status = ? AND (approver_id = ? OR delegated_from = ?)",
+			request.ID, domain.TaskPending, approverID, approverID).Find(&tasks).Error; err != nil {
+			return err
+		}
+		if len(tasks) == 0 {
+			return errors.New("user is not an approver of this request")
+		}
+
+		status := domain.TaskApproved
+		if !approve {
+			status = domain.TaskRejected
+		}
+		for _, task := range tasks {
+			if err := decideTask(tx, task.ID, status, comment, now); err != nil {
+				return err
+			}
+			original := task.ID
+			if task.EscalatedFrom != "" {
+				// An escalation decides the overdue task in its approver's place
+				original = task.EscalatedFrom
+				if err := decideTask(tx, original, status, "decided by "+approverID+" after escalation", now); err != nil {
+					return err
+				}
+			}
+			// The other escalations of the task are no longer needed
+			if err := tx.Model(&domain.ApprovalTask{}).Where("escalated_from = ? AND status = ?", original, domain.TaskPending).
+				Updates(map[string]interface{}{"status": domain.TaskSkipped, "updated_at": now}).Error; err != nil {
+				return err
+			}
+		}
+
+		if !approve {
+			request.Status = domain.ApprovalRejected
+			request.CompletedAt = &now
+			decided = request
+			if err := skipPendingTasks(tx, request.ID, -1, now); err != nil {
+				return err
+			}
+			return tx.Save(request).Error
+		}
+
+		if err := s.advance(tx, request, now); err != nil {
+			return err
+		}
+		if request.Status == domain.ApprovalApproved {
+			decided = request
+		}
+		return tx.Save(request).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if decided != nil {
+		s.applyOutcome(ctx, decided)
+	}
+	return nil
+}
+
+// advance completes the decided steps of a request, starting the next step of
+// a sequential request and approving the request after its last step
+func (s *ApprovalService) advance(tx *gorm.DB, request *domain.ApprovalRequest, now time.Time) error {
+	steps, err := definitionSteps(request.Steps)
+	if err != nil {
+		return err
+	}
+
+	if request.StepOrder == domain.StepOrderParallel {
+		for i, step := range steps {
+			done, err := stepDone(tx, request.ID, i, step)
+			if err != nil {
+				return err
+			}
+			if !done {
+				return nil
+			}
+			if err := skipPendingTasks(tx, request.ID, i, now); err != nil {
+				return err
+			}
+		}
+	} else {
+		for {
+			done, err := stepDone(tx, request.ID, request.CurrentStep, steps[request.CurrentStep])
+			if err != nil {
+				return err
+			}
+			if !done {
+				return nil
+			}
+			if err := skipPendingTasks(tx, request.ID, request.CurrentStep, now); err != nil {
+				return err
+			}
+			if request.CurrentStep == len(steps)-1 {
+				break
+			}
+			request.CurrentStep++
+			if err := s.startStep(tx, request, steps, request.CurrentStep, now); err != nil {
+				return err
+			}
+		}
+	}
+
+	request.Status = domain.ApprovalApproved
+	request.CompletedAt = &now
+	return nil
+}
+
+// stepDone reports whether a step has the approvals its mode requires. Only
+// the tasks of the step's own approvers count, escalations decide for them.
+func stepDone(tx *gorm.DB, requestID string, index int, step domain.ApprovalStep) (bool, error) {
+	var tasks []*domain.ApprovalTask
+	if err := tx.Where("request_id = ? AND step = ? AND escalated_from = ?", requestID, index, "").Find(&tasks).Error; err != nil {
+		return false, err
+	}
+	approved := 0
+	for _, task := range tasks {
+		if task.Status == domain.TaskApproved {
+			approved++
+		}
+	}
+	if step.Mode == domain.StepModeAll {
+		return approved == len(tasks), nil
+	}
+	return approved > 0, nil
+}
+
+// startStep creates the tasks of the approvers of a step
+func (s *ApprovalService) startStep(tx *gorm.DB, request *domain.ApprovalRequest, steps []domain.ApprovalStep, index int, now time.Time) error {
+	step := steps[index]
+	approvers, err := s.resolveApprovers(tx, request, step.Approvers, nil)
+	if err != nil {
+		return err
+	}
+	if len(approvers) == 0 {
+		return fmt.Errorf("no approvers found for step %s", step.Name)
+	}
+
+	var dueAt *time.Time
+	if step.SLAHours > 0 {
+		due := now.Add(time.Duration(step.SLAHours) * time.Hour)
+		dueAt = &due
+	}
+	for _, approver := range approvers {
+		task, err := s.newTask(tx, request, index, approver, now)
+		if err != nil {
+			return err
+		}
+		task.DueAt = dueAt
+		if err := tx.Create(task).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newTask creates the task of an approver, handing it to their delegate when
+// they have delegated their approvals
+func (s *ApprovalService) newTask(tx *gorm.DB, request *domain.ApprovalRequest, step int, approverID string, now time.Time) (*domain.ApprovalTask, error) {
+	task := &domain.ApprovalTask{
+		ID:         utils.GenerateApprovalTaskID(),
+		RequestID:  request.ID,
+		Step:       step,
+		ApproverID: approverID,
+		Status:     domain.TaskPending,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	var delegation domain.ApprovalDelegation
+	err := tx.Where("delegator_id = ? AND starts_at <= ? AND ends_at > ? AND (entity_type = ? OR entity_type = ?)",
+		approverID, now, now, "", request.EntityType).Order("starts_at desc").First(&delegation).Error
+	if err == nil && delegation.DelegateID != request.RequestedBy {
+		task.ApproverID = delegation.DelegateID
+		task.DelegatedFrom = approverID
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return task, nil
+}
+
+// resolveApprovers resolves approver rules to user IDs in rule order. The
+// requester and excluded users never approve their own request.
+func (s *ApprovalService) resolveApprovers(tx *gorm.DB, request *domain.ApprovalRequest, rules []domain.ApproverRule, exclude map[string]bool) ([]string, error) {
+	seen := map[string]bool{request.RequestedBy: true}
+	for user := range exclude {
+		seen[user] = true
+	}
+	var approvers []string
+	add := func(users ...string) {
+		for _, user := range users {
+			if user != "" && !seen[user] {
+				seen[user] = true
+				approvers = append(approvers, user)
+			}
+		}
+	}
+
+	for _, rule := range rules {
+		switch rule.Type {
+		case domain.ApproverUser:
+			add(rule.UserID)
+		case domain.ApproverRole:
+			var users []string
+			roleUsers := tx.Model(&authdomain.UserRole{}).Select("user_id").Where("role = ?", rule.Role)
+			if err := tx.Model(&authdomain.User{}).Where("status = ? AND (role = ? OR id IN (?))", "active", rule.Role, roleUsers).
+				Order("id asc").Pluck("id", &users).Error; err != nil {
+				return nil, err
+			}
+			add(users...)
+		case domain.ApproverDepartmentHead:
+			head, err := departmentHead(tx, request.DepartmentID, rule.Level, seen)
+			if err != nil {
+				return nil, err
+			}
+			add(head)
+		}
+	}
+	return approvers, nil
+}
+
+// departmentHead walks up the department tree from a department and returns
+// the manager of the level-th department with a manager, skipping managers
+// who may not approve
+func departmentHead(tx *gorm.DB, departmentID string, level int, skip map[string]bool) (string, error) {
+	if level < 1 {
+		level = 1
+	}
+	visited := make(map[string]bool)
+	for id := departmentID; id != "" && !visited[id]; {
+		visited[id] = true
+		var department employeedomain.Department
+		if err := tx.Where("id = ?", id).First(&department).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return "", nil
+			}
+			return "", err
+		}
+		if department.ManagerID != "" && !skip[department.ManagerID] {
+			if level--; level == 0 {
+				return department.ManagerID, nil
+			}
+		}
+		id = department.ParentID
+	}
+	return "", nil
+}
+
+// EscalateOverdueTasks flags the pending tasks past their SLA and hands them to
+// the escalation approvers of their step, returning the number of tasks escalated
+func (s *ApprovalService) EscalateOverdueTasks(ctx context.Context) (int, error) {
+	var overdue []*domain.ApprovalTask
+	if err := s.db.Where("status = ? AND escalated_from = ? AND escalated_at IS NULL AND due_at < ?", domain.TaskPending, "", time.Now()).
+		Find(&overdue).Error; err != nil {
+		logger.Error("failed to find overdue approval tasks", "error", err)
+		return 0, errors.New("failed to escalate approval tasks")
+	}
+
+	escalated := 0
+	for _, task := range overdue {
+		err := s.withPendingRequest(task.RequestID, "failed to escalate approval task", func(tx *gorm.DB, request *domain.ApprovalRequest, now time.Time) error {
+			result := tx.Model(&domain.ApprovalTask{}).Where("id = ? AND status = ? AND escalated_at IS NULL", task.ID, domain.TaskPending).
+				Updates(map[string]interface{}{"escalated_at": now, "updated_at": now})
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			escalated++
+
+			steps, err := definitionSteps(request.Steps)
+			if err != nil {
+				return err
+			}
+			approvers, err := s.resolveApprovers(tx, request, steps[task.Step].EscalateTo, map[string]bool{task.ApproverID: true})
+			if err != nil {
+				return err
+			}
+			for _, approver := range approvers {
+				escalation, err := s.newTask(tx, request, task.Step, approver, now)
+				if err != nil {
+					return err
+				}
+				escalation.EscalatedFrom = task.ID
+				if err := tx.Create(escalation).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil && err.Error() != "approval request is not pending" {
+			return escalated, err
+		}
+	}
+	return escalated, nil
+}
+
+// StartSLAMonitor escalates overdue approval tasks at an interval until the context is done
+func (s *ApprovalService) StartSLAMonitor(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if escalated, err := s.EscalateOverdueTasks(ctx); err == nil && escalated > 0 {
+					logger.Info("escalated overdue approval tasks", "count", escalated)
+				}
+			}
+		}
+	}()
+}
+
+// withPendingRequest runs fn in a transaction on a pending request. The request
+// row is written first, so concurrent decisions on a request run one after the other.
+func (s *ApprovalService) withPendingRequest(requestID, failure string, fn func(tx *gorm.DB, request *domain.ApprovalRequest, now time.Time) error) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&domain.ApprovalRequest{}).Where("id = ? AND status = ?", requestID, domain.ApprovalPending).
+			Update("updated_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		var request domain.ApprovalRequest
+		if err := tx.Where("id = ?", requestID).First(&request).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("approval request not found")
+			}
+			return err
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("approval request is not pending")
+		}
+		return fn(tx, &request, now)
+	})
+	if err != nil && !isApprovalError(err) {
+		logger.Error(failure, "request_id", requestID, "error", err)
+		return errors.New(failure)
+	}
+	return err
+}
+
+// applyOutcome calls the hook of a decided request, recording its error
+func (s *ApprovalService) applyOutcome(ctx context.Context, request *domain.ApprovalRequest) {
+	hook := s.hook(request.EntityType)
+	if hook == nil {
+		return
+	}
+
+	var err error
+	if request.Status == domain.ApprovalApproved {
+		err = hook.Approved(ctx, request)
+	} else {
+		err = hook.Rejected(ctx, request)
+	}
+	if err != nil {
+		logger.Error("failed to apply approval outcome", "request_id", request.ID, "status", request.Status, "error", err)
+		request.ApplyError = err.Error()
+		if err := s.db.Model(&domain.ApprovalRequest{}).Where("id = ?", request.ID).Update("apply_error", request.ApplyError).Error; err != nil {
+			logger.Error("failed to record approval outcome error", "request_id", request.ID, "error", err)
+		}
+	}
+}
+
+// decideTask records a decision on a task
+func decideTask(tx *gorm.DB, taskID, status, comment string, now time.Time) error {
+	return tx.Model(&domain.ApprovalTask{}).Where("id = ?", taskID).Updates(map[string]interface{}{
+		"status":     status,
+		"comment":    comment,
+		"decided_at": now,
+		"updated_at": now,
+	}).Error
+}
+
+// skipPendingTasks closes the pending tasks of a step, or of every step when step is negative
+func skipPendingTasks(tx *gorm.DB, requestID string, step int, now time.Time) error {
+	query := tx.Model(&domain.ApprovalTask{}).Where("request_id = ? AND status = ?", requestID, domain.TaskPending)
+	if step >= 0 {
+		query = query.Where("step = ?", step)
+	}
+	return query.Updates(map[string]interface{}{"status": domain.TaskSkipped, "updated_at": now}).Error
+}
+
+// isApprovalError reports whether an error is meant for the caller rather than
+// an internal failure
+func isApprovalError(err error) bool {
+	switch msg := err.Error(); {
+	case msg == "approval is already pending" || msg == "approval request not found" ||
+		msg == "approval request is not pending" || msg == "user is not an approver of this request" ||
+		msg == "only the requester can cancel this approval request":
+		return true
+	default:
+		return strings.HasPrefix(msg, "no approvers found")
+	}
+}
@@ -0,0 +1,528 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"cdk-office/internal/approval/domain"
+	employeedomain "cdk-office/internal/employee/domain"
+	"cdk-office/internal/shared/database"
+	"cdk-office/internal/shared/utils"
+	"cdk-office/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// adminRole is the role allowed to see every approval request
+const adminRole = "admin"
+
+// ApprovalServiceInterface defines the interface for approval service
+type ApprovalServiceInterface interface {
+	CreateDefinition(ctx context.Context, req *CreateDefinitionRequest) (*domain.ApprovalDefinition, error)
+	UpdateDefinition(ctx context.Context, definitionID string, req *UpdateDefinitionRequest) error
+	DeleteDefinition(ctx context.Context, definitionID string) error
+	ListDefinitions(ctx context.Context, teamID string) ([]*domain.ApprovalDefinition, error)
+	GetDefinition(ctx context.Context, definitionID string) (*domain.ApprovalDefinition, error)
+	Submit(ctx context.Context, req *SubmitRequest) (*domain.ApprovalRequest, error)
+	Approve(ctx context.Context, requestID, approverID, comment string) error
+	Reject(ctx context.Context, requestID, approverID, comment string) error
+	Cancel(ctx context.Context, requestID, userID string) error
+	GetRequest(ctx context.Context, requestID, userID, role string) (*RequestDetail, error)
+	ListRequests(ctx context.Context, userID, role, teamID, status string, page, size int) ([]*domain.ApprovalRequest, int64, error)
+	ListPendingTasks(ctx context.Context, approverID string) ([]*domain.ApprovalTask, error)
+	CreateDelegation(ctx context.Context, req *CreateDelegationRequest) (*domain.ApprovalDelegation, error)
+	DeleteDelegation(ctx context.Context, delegationID, userID string) error
+	ListDelegations(ctx context.Context, userID string) ([]*domain.ApprovalDelegation, error)
+	RegisterHook(entityType string, hook Hook)
+}
+
+// Hook applies the outcome of the approval requests of an entity type. The
+// action an approval was requested for is usually performed by Approved.
+type Hook interface {
+	Approved(ctx context.Context, request *domain.ApprovalRequest) error
+	Rejected(ctx context.Context, request *domain.ApprovalRequest) error
+}
+
+// HookFuncs adapts functions to a Hook. Nil functions do nothing.
+type HookFuncs struct {
+	OnApproved func(ctx context.Context, request *domain.ApprovalRequest) error
+	OnRejected func(ctx context.Context, request *domain.ApprovalRequest) error
+}
+
+// Approved calls OnApproved
+func (h HookFuncs) Approved(ctx context.Context, request *domain.ApprovalRequest) error {
+	if h.OnApproved == nil {
+		return nil
+	}
+	return h.OnApproved(ctx, request)
+}
+
+// Rejected calls OnRejected
+func (h HookFuncs) Rejected(ctx context.Context, request *domain.ApprovalRequest) error {
+	if h.OnRejected == nil {
+		return nil
+	}
+	return h.OnRejected(ctx, request)
+}
+
+// ApprovalService implements the ApprovalServiceInterface
+type ApprovalService struct {
+	db *gorm.DB
+
+	mu    sync.RWMutex
+	hooks map[string]Hook
+}
+
+// NewApprovalService creates a new instance of ApprovalService
+func NewApprovalService() *ApprovalService {
+	return NewApprovalServiceWithDB(database.GetDB())
+}
+
+// NewApprovalServiceWithDB creates a new instance of ApprovalService with a specific database connection
+func NewApprovalServiceWithDB(db *gorm.DB) *ApprovalService {
+	return &ApprovalService{
+		db:    db,
+		hooks: make(map[string]Hook),
+	}
+}
+
+// RegisterHook registers the hook applying the outcome of approvals of an
+// entity type, replacing any earlier one
+func (s *ApprovalService) RegisterHook(entityType string, hook Hook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks[entityType] = hook
+}
+
+// hook returns the hook of an entity type
+func (s *ApprovalService) hook(entityType string) Hook {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hooks[entityType]
+}
+
+// CreateDefinitionRequest represents the request for creating an approval definition
+type CreateDefinitionRequest struct {
+	TeamID      string                `json:"team_id"`
+	Name        string                `json:"name"`
+	Description string                `json:"description"`
+	EntityType  string                `json:"entity_type"`
+	Action      string                `json:"action"`
+	StepOrder   string                `json:"step_order"`
+	Steps       []domain.ApprovalStep `json:"steps"`
+	IsActive    bool                  `json:"is_active"`
+	CreatedBy   string                `json:"created_by"`
+}
+
+// UpdateDefinitionRequest represents the request for updating an approval definition.
+// Steps replace the steps of the definition when set.
+type UpdateDefinitionRequest struct {
+	Name        string                `json:"name"`
+	Description string                `json:"description"`
+	StepOrder   string                `json:"step_order"`
+	Steps       []domain.ApprovalStep `json:"steps"`
+	IsActive    *bool                 `json:"is_active"`
+}
+
+// CreateDelegationRequest represents the request for delegating approvals
+type CreateDelegationRequest struct {
+	DelegatorID string    `json:"delegator_id"`
+	DelegateID  string    `json:"delegate_id"`
+	EntityType  string    `json:"entity_type"`
+	StartsAt    time.Time `json:"starts_at"`
+	EndsAt      time.Time `json:"ends_at"`
+	Reason      string    `json:"reason"`
+}
+
+// RequestDetail is an approval request with its tasks
+type RequestDetail struct {
+	Request *domain.ApprovalRequest `json:"request"`
+	Steps   []domain.ApprovalStep   `json:"steps"`
+	Tasks   []*domain.ApprovalTask  `json:"tasks"`
+}
+
+// CreateDefinition creates a new approval definition
+func (s *ApprovalService) CreateDefinition(ctx context.Context, req *CreateDefinitionRequest) (*domain.ApprovalDefinition, error) {
+	if req.EntityType == "" || req.Action == "" {
+		return nil, errors.New("invalid definition: entity type and action are required")
+	}
+	stepOrder, err := checkSteps(req.StepOrder, req.Steps)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create new definition
+	now := time.Now()
+	definition := &domain.ApprovalDefinition{
+		ID:          utils.GenerateApprovalDefinitionID(),
+		TeamID:      req.TeamID,
+		Name:        req.Name,
+		Description: req.Description,
+		EntityType:  req.EntityType,
+		Action:      req.Action,
+		StepOrder:   stepOrder,
+		Steps:       convertStepsToJSON(req.Steps),
+		IsActive:    req.IsActive,
+		CreatedBy:   req.CreatedBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if definition.IsActive {
+		if err := s.checkSingleActive(definition); err != nil {
+			return nil, err
+		}
+	}
+
+	// Save definition to database
+	if err := s.db.Create(definition).Error; err != nil {
+		logger.Error("failed to create approval definition", "error", err)
+		return nil, errors.New("failed to create approval definition")
+	}
+
+	return definition, nil
+}
+
+// UpdateDefinition updates an existing approval definition. Pending requests
+// keep the steps they were submitted with.
+func (s *ApprovalService) UpdateDefinition(ctx context.Context, definitionID string, req *UpdateDefinitionRequest) error {
+	definition, err := s.findDefinition(definitionID, "failed to update approval definition")
+	if err != nil {
+		return err
+	}
+
+	// Update definition fields
+	if req.Name != "" {
+		definition.Name = req.Name
+	}
+	if req.Description != "" {
+		definition.Description = req.Description
+	}
+	steps, err := definitionSteps(definition.Steps)
+	if err != nil {
+		return errors.New("failed to update approval definition")
+	}
+	if req.Steps != nil {
+		steps = req.Steps
+	}
+	stepOrder := definition.StepOrder
+	if req.StepOrder != "" {
+		stepOrder = req.StepOrder
+	}
+	if definition.StepOrder, err = checkSteps(stepOrder, steps); err != nil {
+		return err
+	}
+	definition.Steps = convertStepsToJSON(steps)
+	if req.IsActive != nil {
+		definition.IsActive = *req.IsActive
+	}
+	if definition.IsActive {
+		if err := s.checkSingleActive(definition); err != nil {
+			return err
+		}
+	}
+	definition.UpdatedAt = time.Now()
+
+	// Save updated definition to database
+	if err := s.db.Save(definition).Error; err != nil {
+		logger.Error("failed to update approval definition", "error", err)
+		return errors.New("failed to update approval definition")
+	}
+
+	return nil
+}
+
+// DeleteDefinition deletes an approval definition. Pending requests keep the
+// steps they were submitted with.
+func (s *ApprovalService) DeleteDefinition(ctx context.Context, definitionID string) error {
+	definition, err := s.findDefinition(definitionID, "failed to delete approval definition")
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.Delete(definition).Error; err != nil {
+		logger.Error("failed to delete approval definition", "error", err)
+		return errors.New("failed to delete approval definition")
+	}
+
+	return nil
+}
+
+// ListDefinitions lists the approval definitions of a team
+func (s *ApprovalService) ListDefinitions(ctx context.Context, teamID string) ([]*domain.ApprovalDefinition, error) {
+	var definitions []*domain.ApprovalDefinition
+	if err := s.db.Where("team_id = ?", teamID).Order("entity_type asc, action asc").Find(&definitions).Error; err != nil {
+		logger.Error("failed to list approval definitions", "error", err)
+		return nil, errors.New("failed to list approval definitions")
+	}
+
+	return definitions, nil
+}
+
+// GetDefinition retrieves an approval definition by ID
+func (s *ApprovalService) GetDefinition(ctx context.Context, definitionID string) (*domain.ApprovalDefinition, error) {
+	return s.findDefinition(definitionID, "failed to get approval definition")
+}
+
+// GetRequest retrieves an approval request with its tasks, if the user may see it
+func (s *ApprovalService) GetRequest(ctx context.Context, requestID, userID, role string) (*RequestDetail, error) {
+	var request domain.ApprovalRequest
+	if err := s.visibleRequests(userID, role).Where("id = ?", requestID).First(&request).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("approval request not found")
+		}
+		logger.Error("failed to find approval request", "error", err)
+		return nil, errors.New("failed to get approval request")
+	}
+
+	detail := &RequestDetail{Request: &request}
+	steps, err := definitionSteps(request.Steps)
+	if err != nil {
+		return nil, errors.New("failed to get approval request")
+	}
+	detail.Steps = steps
+	if err := s.db.Where("request_id = ?", requestID).Order("step asc, created_at asc").Find(&detail.Tasks).Error; err != nil {
+		logger.Error("failed to find approval tasks", "error", err)
+		return nil, errors.New("failed to get approval request")
+	}
+
+	return detail, nil
+}
+
+// ListRequests lists the approval requests of a team the user may see with
+// pagination, optionally filtered by status
+func (s *ApprovalService) ListRequests(ctx context.Context, userID, role, teamID, status string, page, size int) ([]*domain.ApprovalRequest, int64, error) {
+	// Validate pagination parameters
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 || size > 100 {
+		size = 10
+	}
+
+	// Build query
+	dbQuery := s.visibleRequests(userID, role).Where("team_id = ?", teamID)
+	if status != "" {
+		dbQuery = dbQuery.Where("status = ?", status)
+	}
+
+	// Count total results
+	var total int64
+	if err := dbQuery.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		logger.Error("failed to count approval requests", "error", err)
+		return nil, 0, errors.New("failed to list approval requests")
+	}
+
+	// Execute query
+	var requests []*domain.ApprovalRequest
+	if err := dbQuery.Offset((page - 1) * size).Limit(size).Order("created_at desc").Find(&requests).Error; err != nil {
+		logger.Error("failed to list approval requests", "error", err)
+		return nil, 0, errors.New("failed to list approval requests")
+	}
+
+	return requests, total, nil
+}
+
+// visibleRequests scopes a query to the approval requests a user may see: those
+// they made, those they approve or approved, directly or by delegation, and
+// those of the teams they are an active employee of. Admins see every request.
+func (s *ApprovalService) visibleRequests(userID, role string) *gorm.DB {
+	query := s.db.Model(&domain.ApprovalRequest{})
+	if role == adminRole {
+		return query
+	}
+	approved := s.db.Model(&domain.ApprovalTask{}).Select("request_id").Where("approver_id = ? OR delegated_from = ?", userID, userID)
+	teams := s.db.Model(&employeedomain.Employee{}).Select("team_id").Where("user_id = ? AND status = ?", userID, "active")
+	return query.Where("requested_by = ? OR id IN (?) OR team_id IN (?)", userID, approved, teams)
+}
+
+// ListPendingTasks lists the tasks awaiting a decision of an approver,
+// including the tasks delegated away from them
+func (s *ApprovalService) ListPendingTasks(ctx context.Context, approverID string) ([]*domain.ApprovalTask, error) {
+	var tasks []*domain.ApprovalTask
+	if err := s.db.Where("status = ? AND (approver_id = ? OR delegated_from = ?)", domain.TaskPending, approverID, approverID).
+		Order("created_at asc").Find(&tasks).Error; err != nil {
+		logger.Error("failed to list approval tasks", "error", err)
+		return nil, errors.New("failed to list approval tasks")
+	}
+
+	return tasks, nil
+}
+
+// CreateDelegation hands the approvals of a user to another user for a period.
+// Tasks created during the period go to the delegate.
+func (s *ApprovalService) CreateDelegation(ctx context.Context, req *CreateDelegationRequest) (*domain.ApprovalDelegation, error) {
+	if req.DelegatorID == "" || req.DelegateID == "" || req.DelegatorID == req.DelegateID {
+		return nil, errors.New("invalid delegation: delegator and delegate must be different users")
+	}
+	if !req.EndsAt.After(req.StartsAt) {
+		return nil, errors.New("invalid delegation: the period must end after it starts")
+	}
+
+	delegation := &domain.ApprovalDelegation{
+		ID:          utils.GenerateApprovalDelegationID(),
+		DelegatorID: req.DelegatorID,
+		DelegateID:  req.DelegateID,
+		EntityType:  req.EntityType,
+		StartsAt:    req.StartsAt,
+		EndsAt:      req.EndsAt,
+		Reason:      req.Reason,
+		CreatedAt:   time.Now(),
+	}
+	if err := s.db.Create(delegation).Error; err != nil {
+		logger.Error("failed to create approval delegation", "error", err)
+		return nil, errors.New("failed to create approval delegation")
+	}
+
+	return delegation, nil
+}
+
+// DeleteDelegation ends a delegation. Only the delegator can delete it.
+func (s *ApprovalService) DeleteDelegation(ctx context.Context, delegationID, userID string) error {
+	var delegation domain.ApprovalDelegation
+	if err := s.db.Where("id = ?", delegationID).First(&delegation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("approval delegation not found")
+		}
+		logger.Error("failed to find approval delegation", "error", err)
+		return errors.New("failed to delete approval delegation")
+	}
+	if delegation.DelegatorID != userID {
+		return errors.New("only the delegator can delete this delegation")
+	}
+
+	if err := s.db.Delete(&delegation).Error; err != nil {
+		logger.Error("failed to delete approval delegation", "error", err)
+		return errors.New("failed to delete approval delegation")
+	}
+
+	return nil
+}
+
+// ListDelegations lists the delegations from and to a user
+func (s *ApprovalService) ListDelegations(ctx context.Context, userID string) ([]*domain.ApprovalDelegation, error) {
+	var delegations []*domain.ApprovalDelegation
+	if err := s.db.Where("delegator_id = ? OR delegate_id = ?", userID, userID).Order("starts_at desc").Find(&delegations).Error; err != nil {
+		logger.Error("failed to list approval delegations", "error", err)
+		return nil, errors.New("failed to list approval delegations")
+	}
+
+	return delegations, nil
+}
+
+// findDefinition loads an approval definition, mapping lookup failures to the given error
+func (s *ApprovalService) findDefinition(definitionID, failure string) (*domain.ApprovalDefinition, error) {
+	var definition domain.ApprovalDefinition
+	if err := s.db.Where("id = ?", definitionID).First(&definition).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("approval definition not found")
+		}
+		logger.Error("failed to find approval definition", "error", err)
+		return nil, errors.New(failure)
+	}
+	return &definition, nil
+}
+
+// checkSingleActive ensures a team has one active definition per entity type and action
+func (s *ApprovalService) checkSingleActive(definition *domain.ApprovalDefinition) error {
+	var count int64
+	if err := s.db.Model(&domain.ApprovalDefinition{}).
+		Where("team_id = ? AND entity_type = ? AND action = ? AND is_active = ? AND id <> ?",
+			definition.TeamID, definition.EntityType, definition.Action, true, definition.ID).
+		Count(&count).Error; err != nil {
+		logger.Error("failed to count approval definitions", "error", err)
+		return errors.New("failed to save approval definition")
+	}
+	if count > 0 {
+		return fmt.Errorf("invalid definition: the team already has an active definition for %s %s", definition.EntityType, definition.Action)
+	}
+	return nil
+}
+
+// checkSteps validates the steps of a definition and returns its step order
+func checkSteps(stepOrder string, steps []domain.ApprovalStep) (string, error) {
+	if stepOrder == "" {
+		stepOrder = domain.StepOrderSequential
+	}
+	if stepOrder != domain.StepOrderSequential && stepOrder != domain.StepOrderParallel {
+		return "", errors.New("invalid definition: step order must be sequential or parallel")
+	}
+	if len(steps) == 0 {
+		return "", errors.New("invalid definition: at least one step is required")
+	}
+	for i := range steps {
+		step := &steps[i]
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("Step %d", i+1)
+		}
+		if step.Mode == "" {
+			step.Mode = domain.StepModeAny
+		}
+		if step.Mode != domain.StepModeAny && step.Mode != domain.StepModeAll {
+			return "", fmt.Errorf("invalid definition: mode of step %s must be any or all", step.Name)
+		}
+		if len(step.Approvers) == 0 {
+			return "", fmt.Errorf("invalid definition: step %s needs approvers", step.Name)
+		}
+		if step.SLAHours < 0 {
+			return "", fmt.Errorf("invalid definition: SLA of step %s can not be negative", step.Name)
+		}
+		if len(step.EscalateTo) > 0 && step.SLAHours == 0 {
+			return "", fmt.Errorf("invalid definition: step %s escalates without an SLA", step.Name)
+		}
+		for _, rules := range [][]domain.ApproverRule{step.Approvers, step.EscalateTo} {
+			for j := range rules {
+				if err := checkRule(&rules[j]); err != nil {
+					return "", fmt.Errorf("invalid definition: step %s %s", step.Name, err)
+				}
+			}
+		}
+	}
+	return stepOrder, nil
+}
+
+// checkRule validates an approver rule
+func checkRule(rule *domain.ApproverRule) error {
+	switch rule.Type {
+	case domain.ApproverRole:
+		if rule.Role == "" {
+			return errors.New("has a role approver without a role")
+		}
+	case domain.ApproverUser:
+		if rule.UserID == "" {
+			return errors.New("has a user approver without a user")
+		}
+	case domain.ApproverDepartmentHead:
+		if rule.Level < 0 {
+			return errors.New("has a department head approver with a negative level")
+		}
+		if rule.Level == 0 {
+			rule.Level = 1
+		}
+	default:
+		return fmt.Errorf("has an approver of unknown type %q", rule.Type)
+	}
+	return nil
+}
+
+// definitionSteps decodes the steps of a definition or request
+func definitionSteps(data string) ([]domain.ApprovalStep, error) {
+	var steps []domain.ApprovalStep
+	if err := json.Unmarshal([]byte(data), &steps); err != nil {
+		logger.Error("failed to unmarshal approval steps", "error", err)
+		return nil, err
+	}
+	return steps, nil
+}
+
+// convertStepsToJSON converts approval steps to a JSON string
+func convertStepsToJSON(steps []domain.ApprovalStep) string {
+	jsonData, err := json.Marshal(steps)
+	if err != nil {
+		logger.Error("failed to marshal approval steps to JSON", "error", err)
+		return "[]"
+	}
+	return string(jsonData)
+}
@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cdk-office/internal/approval/domain"
+	authdomain "cdk-office/internal/auth/domain"
+	employeedomain "cdk-office/internal/employee/domain"
+	"cdk-office/internal/shared/testutils"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// setupApprovalOrg creates a department tree and users. Engineering belongs to
+// R&D, managed by carol. Platform belongs to engineering, which has no
+// manager, and is managed by the requester alice.
+func setupApprovalOrg(t *testing.T, db *gorm.DB) {
+	for _, department := range []*employeedomain.Department{
+		{ID: "dept_rd", TeamID: "team_1", Name: "R&D", ManagerID: "carol"},
+		{ID: "dept_eng", TeamID: "team_1", Name: "Engineering", ParentID: "dept_rd"},
+		{ID: "dept_platform", TeamID: "team_1", Name: "Platform", ParentID: "dept_eng", ManagerID: "alice"},
+	} {
+		assert.NoError(t, db.Create(department).Error)
+	}
+	assert.NoError(t, db.Create(&employeedomain.Employee{ID: "emp_alice", UserID: "alice", TeamID: "team_1", DeptID: "dept_platform", EmployeeID: "E1"}).Error)
+	for _, user := range []*authdomain.User{
+		{ID: "fin_1", Username: "fin_1", Email: "fin_1@example.com", Role: "finance", Status: "active"},
+		{ID: "fin_2", Username: "fin_2", Email: "fin_2@example.com", Role: "user", Status: "active"},
+		{ID: "fin_3", Username: "fin_3", Email: "fin_3@example.com", Role: "finance", Status: "disabled"},
+	} {
+		assert.NoError(t, db.Create(user).Error)
+	}
+	assert.NoError(t, db.Create(&authdomain.UserRole{ID: "ur_1", UserID: "fin_2", Role: "finance"}).Error)
+}
+
+// recordingHook records the requests whose outcome it applied
+type recordingHook struct {
+	approved []string
+	rejected []string
+}
+
+func (h *recordingHook) Approved(ctx context.Context, request *domain.ApprovalRequest) error {
+	h.approved = append(h.approved, request.EntityID)
+	return nil
+}
+
+func (h *recordingHook) Rejected(ctx context.Context, request *domain.ApprovalRequest) error {
+	h.rejected = append(h.rejected, request.EntityID)
+	return nil
+}
+
+// pendingApprovers returns the approvers of the pending tasks of a request
+func pendingApprovers(t *testing.T, s *ApprovalService, requestID string) []string {
+	detail, err := s.GetRequest(context.Background(), requestID, "admin_1", "admin")
+	assert.NoError(t, err)
+	var approvers []string
+	for _, task := range detail.Tasks {
+		if task.Status == domain.TaskPending {
+			approvers = append(approvers, task.ApproverID)
+		}
+	}
+	return approvers
+}
+
+// TestApprovalWorkflow tests approving and rejecting requests step by step
+func TestApprovalWorkflow(t *testing.T) {
+	testDB := testutils.SetupTestDB()
+	setupApprovalOrg(t, testDB)
+	s := NewApprovalServiceWithDB(testDB)
+	hook := &recordingHook{}
+	s.RegisterHook("contract", hook)
+	ctx := context.Background()
+
+	// Without a definition no approval is required
+	request, err := s.Submit(ctx, &SubmitRequest{TeamID: "team_1", EntityType: "contract", EntityID: "c0", Action: "send", RequestedBy: "alice"})
+	assert.NoError(t, err)
+	assert.Nil(t, request)
+
+	_, err = s.CreateDefinition(ctx, &CreateDefinitionRequest{
+		TeamID: "team_1", Name: "Contracts", EntityType: "contract", Action: "send", IsActive: true, CreatedBy: "admin",
+		Steps: []domain.ApprovalStep{
+			{Name: "Manager", Approvers: []domain.ApproverRule{{Type: domain.ApproverDepartmentHead}}},
+			{Name: "Finance", Mode: domain.StepModeAll, Approvers: []domain.ApproverRule{{Type: domain.ApproverRole, Role: "finance"}}},
+		},
+	})
+	assert.NoError(t, err)
+
+	t.Run("Approve", func(t *testing.T) {
+		request, err := s.Submit(ctx, &SubmitRequest{TeamID: "team_1", EntityType: "contract", EntityID: "c1", Action: "send", RequestedBy: "alice"})
+		if !assert.NoError(t, err) || !assert.NotNil(t, request) {
+			return
+		}
+		assert.Equal(t, "dept_platform", request.DepartmentID)
+		_, err = s.Submit(ctx, &SubmitRequest{TeamID: "team_1", EntityType: "contract", EntityID: "c1", Action: "send", RequestedBy: "alice"})
+		assert.EqualError(t, err, "approval is already pending")
+		// The database refuses a second pending request even when the check is raced
+		duplicate := *request
+		duplicate.ID = "duplicate"
+		assert.Error(t, testDB.Create(&duplicate).Error)
+
+		// alice heads her own department, so the head of the closest managed department above approves
+		assert.Equal(t, []string{"carol"}, pendingApprovers(t, s, request.ID))
+		assert.EqualError(t, s.Approve(ctx, request.ID, "alice", ""), "user is not an approver of this request")
+
+		assert.NoError(t, s.Approve(ctx, request.ID, "carol", "Fine"))
+		// Active users with the role, directly or through user roles, approve the next step
+		assert.Equal(t, []string{"fin_1", "fin_2"}, pendingApprovers(t, s, request.ID))
+		assert.NoError(t, s.Approve(ctx, request.ID, "fin_1", ""))
+		assert.Empty(t, hook.approved)
+		assert.NoError(t, s.Approve(ctx, request.ID, "fin_2", ""))
+		assert.Equal(t, []string{"c1"}, hook.approved)
+
+		detail, err := s.GetRequest(ctx, request.ID, "alice", "user")
+		assert.NoError(t, err)
+		assert.Equal(t, domain.ApprovalApproved, detail.Request.Status)
+		assert.NotNil(t, detail.Request.CompletedAt)
+		assert.Len(t, detail.Tasks, 3)
+		assert.Equal(t, "Fine", detail.Tasks[0].Comment)
+		assert.EqualError(t, s.Approve(ctx, request.ID, "fin_1", ""), "approval request is not pending")
+	})
+
+	t.Run("Reject", func(t *testing.T) {
+		request, err := s.Submit(ctx, &SubmitRequest{TeamID: "team_1", EntityType: "contract", EntityID: "c2", Action: "send", RequestedBy: "alice"})
+		assert.NoError(t, err)
+		assert.NoError(t, s.Approve(ctx, request.ID, "carol", ""))
+		assert.NoError(t, s.Reject(ctx, request.ID, "fin_2", "Over budget"))
+		assert.Equal(t, []string{"c2"}, hook.rejected)
+		assert.Empty(t, pendingApprovers(t, s, request.ID))
+
+		requests, total, err := s.ListRequests(ctx, "admin_1", "admin", "team_1", domain.ApprovalRejected, 1, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, "c2", requests[0].EntityID)
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		request, err := s.Submit(ctx, &SubmitRequest{TeamID: "team_1", EntityType: "contract", EntityID: "c3", Action: "send", RequestedBy: "alice"})
+		assert.NoError(t, err)
+		assert.EqualError(t, s.Cancel(ctx, request.ID, "carol"), "only the requester can cancel this approval request")
+		assert.NoError(t, s.Cancel(ctx, request.ID, "alice"))
+		assert.Empty(t, pendingApprovers(t, s, request.ID))
+	})
+
+	t.Run("NoApprovers", func(t *testing.T) {
+		_, err := s.Submit(ctx, &SubmitRequest{TeamID: "team_1", EntityType: "contract", EntityID: "c4", Action: "send", RequestedBy: "carol"})
+		assert.EqualError(t, err, "no approvers found for step Manager")
+	})
+}
+
+// TestApprovalParallelSteps tests parallel steps, delegation and SLA escalation
+func TestApprovalParallelSteps(t *testing.T) {
+	testDB := testutils.SetupTestDB()
+	setupApprovalOrg(t, testDB)
+	s := NewApprovalServiceWithDB(testDB)
+	hook := &recordingHook{}
+	s.RegisterHook("form_design", hook)
+	ctx := context.Background()
+
+	_, err := s.CreateDefinition(ctx, &CreateDefinitionRequest{
+		TeamID: "team_1", Name: "Forms", EntityType: "form_design", Action: "publish", StepOrder: domain.StepOrderParallel,
+		IsActive: true, CreatedBy: "admin",
+		Steps: []domain.ApprovalStep{
+			{Name: "Legal", Approvers: []domain.ApproverRule{{Type: domain.ApproverUser, UserID: "dave"}}},
+			{
+				Name: "Security", Approvers: []domain.ApproverRule{{Type: domain.ApproverUser, UserID: "erin"}},
+				SLAHours: 24, EscalateTo: []domain.ApproverRule{{Type: domain.ApproverDepartmentHead, Level: 1}},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	// dave is away, frank decides for him
+	_, err = s.CreateDelegation(ctx, &CreateDelegationRequest{
+		DelegatorID: "dave", DelegateID: "frank", StartsAt: time.Now().Add(-time.Hour), EndsAt: time.Now().Add(time.Hour),
+	})
+	assert.NoError(t, err)
+
+	request, err := s.Submit(ctx, &SubmitRequest{TeamID: "team_1", EntityType: "form_design", EntityID: "f1", Action: "publish", RequestedBy: "alice"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"frank", "erin"}, pendingApprovers(t, s, request.ID))
+	tasks, err := s.ListPendingTasks(ctx, "dave")
+	assert.NoError(t, err)
+	if assert.Len(t, tasks, 1) {
+		assert.Equal(t, "dave", tasks[0].DelegatedFrom)
+	}
+	assert.NoError(t, s.Approve(ctx, request.ID, "frank", ""))
+
+	// erin misses the SLA, and the head of the requester's department may decide instead
+	escalated, err := s.EscalateOverdueTasks(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, escalated)
+	assert.NoError(t, testDB.Model(&domain.ApprovalTask{}).Where("approver_id = ?", "erin").Update("due_at", time.Now().Add(-time.Minute)).Error)
+	escalated, err = s.EscalateOverdueTasks(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, escalated)
+	escalated, err = s.EscalateOverdueTasks(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, escalated)
+	assert.Equal(t, []string{"erin", "carol"}, pendingApprovers(t, s, request.ID))
+
+	assert.NoError(t, s.Approve(ctx, request.ID, "carol", ""))
+	assert.Equal(t, []string{"f1"}, hook.approved)
+	detail, err := s.GetRequest(ctx, request.ID, "alice", "user")
+	assert.NoError(t, err)
+	for _, task := range detail.Tasks {
+		if task.ApproverID == "erin" {
+			assert.Equal(t, domain.TaskApproved, task.Status)
+			assert.NotNil(t, task.EscalatedAt)
+			assert.Equal(t, "decided by carol after escalation", task.Comment)
+		}
+	}
+}
+
+// TestApprovalDefinitions tests validating approval definitions
+func TestApprovalDefinitions(t *testing.T) {
+	testDB := testutils.SetupTestDB()
+	s := NewApprovalServiceWithDB(testDB)
+	ctx := context.Background()
+	user := []domain.ApproverRule{{Type: domain.ApproverUser, UserID: "dave"}}
+
+	for _, test := range []struct {
+		steps   []domain.ApprovalStep
+		message string
+	}{
+		{nil, "invalid definition: at least one step is required"},
+		{[]domain.ApprovalStep{{Name: "A"}}, "invalid definition: step A needs approvers"},
+		{[]domain.ApprovalStep{{Name: "A", Mode: "most", Approvers: user}}, "invalid definition: mode of step A must be any or all"},
+		{[]domain.ApprovalStep{{Name: "A", Approvers: []domain.ApproverRule{{Type: domain.ApproverRole}}}}, "invalid definition: step A has a role approver without a role"},
+		{[]domain.ApprovalStep{{Name: "A", Approvers: []domain.ApproverRule{{Type: "robot"}}}}, `invalid definition: step A has an approver of unknown type "robot"`},
+		{[]domain.ApprovalStep{{Name: "A", Approvers: user, EscalateTo: user}}, "invalid definition: step A escalates without an SLA"},
+	} {
+		_, err := s.CreateDefinition(ctx, &CreateDefinitionRequest{TeamID: "team_1", EntityType: "contract", Action: "send", Steps: test.steps})
+		assert.EqualError(t, err, test.message)
+	}
+
+	definition, err := s.CreateDefinition(ctx, &CreateDefinitionRequest{
+		TeamID: "team_1", EntityType: "contract", Action: "send", IsActive: true, Steps: []domain.ApprovalStep{{Approvers: user}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, domain.StepOrderSequential, definition.StepOrder)
+	assert.Contains(t, definition.Steps, `"name":"Step 1","mode":"any"`)
+
+	other, err := s.CreateDefinition(ctx, &CreateDefinitionRequest{
+		TeamID: "team_1", EntityType: "contract", Action: "send", Steps: []domain.ApprovalStep{{Approvers: user}},
+	})
+	assert.NoError(t, err)
+	active := true
+	assert.EqualError(t, s.UpdateDefinition(ctx, other.ID, &UpdateDefinitionRequest{IsActive: &active}),
+		"invalid definition: the team already has an active definition for contract send")
+	assert.NoError(t, s.DeleteDefinition(ctx, definition.ID))
+	assert.NoError(t, s.UpdateDefinition(ctx, other.ID, &UpdateDefinitionRequest{IsActive: &active}))
+}
@@ -0,0 +1,23 @@
+package domain
+
+import (
+	"time"
+)
+
+// Identity providers
+const (
+	IdentityProviderWeChat = "wechat" // WeChat mini-program; single sign-on providers use their configured name
+)
+
+// UserIdentity links a user to an account at an external identity provider.
+// OpenID holds the openid at WeChat, or the subject at a single sign-on provider.
+type UserIdentity struct {
+	ID          string     `json:"id" gorm:"primaryKey"`
+	UserID      string     `json:"user_id" gorm:"size:36;index"`
+	Provider    string     `json:"provider" gorm:"size:50;uniqueIndex:idx_user_identities_subject"`
+	OpenID      string     `json:"-" gorm:"size:255;uniqueIndex:idx_user_identities_subject"`
+	UnionID     string     `json:"-" gorm:"size:64;index"` // shared by the apps of one WeChat open platform account
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"time"
+)
+
+// UserMFA holds the TOTP second factor of a user. The factor is pending until
+// the user confirms it with a first code.
+type UserMFA struct {
+	UserID       string     `json:"user_id" gorm:"primaryKey;size:36"`
+	Secret       string     `json:"-" gorm:"size:64"` // base32 TOTP secret
+	Enabled      bool       `json:"enabled"`
+	EnabledAt    *time.Time `json:"enabled_at"`
+	LastUsedStep int64      `json:"-"` // time step of the last accepted code, refused afterwards
+	// FailedAttempts counts the wrong codes since the last accepted one
+	FailedAttempts int        `json:"-"`
+	LockedUntil    *time.Time `json:"-"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// MFARecoveryCode is a one-time code that stands in for a TOTP code
+type MFARecoveryCode struct {
+	ID        string     `json:"id" gorm:"primaryKey"`
+	UserID    string     `json:"user_id" gorm:"size:36;index"`
+	CodeHash  string     `json:"-" gorm:"size:64;index"` // hex SHA-256 of the code
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// MFARolePolicy states whether users holding a role must use a second factor
+type MFARolePolicy struct {
+	Role      string    `json:"role" gorm:"primaryKey;size:20"`
+	Required  bool      `json:"required"`
+	UpdatedBy string    `json:"updated_by" gorm:"size:36"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"time"
+)
+
+// PasswordHistory is a previous password of a user, kept to refuse its reuse
+type PasswordHistory struct {
+	ID           string    `json:"id" gorm:"primaryKey"`
+	UserID       string    `json:"user_id" gorm:"size:36;index"`
+	PasswordHash string    `json:"-" gorm:"size:255"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// PasswordResetToken is a single-use token letting a user choose a new password
+type PasswordResetToken struct {
+	ID          string     `json:"id" gorm:"primaryKey"`
+	UserID      string     `json:"user_id" gorm:"size:36;index"`
+	TokenHash   string     `json:"-" gorm:"size:64;uniqueIndex"` // hex SHA-256 of the token
+	Channel     string     `json:"channel" gorm:"size:20"`       // channel the token was sent through
+	RequestedIP string     `json:"requested_ip" gorm:"size:45"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	UsedAt      *time.Time `json:"used_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
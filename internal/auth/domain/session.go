@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"time"
+)
+
+// Session revocation reasons
+const (
+	SessionRevokedLogout        = "logout"
+	SessionRevokedByUser        = "revoked"
+	SessionRevokedReuse         = "refresh token reuse" // a rotated refresh token was presented again
+	SessionRevokedForced        = "forced logout"       // an administrator logged the user out
+	SessionRevokedPasswordReset = "password reset"      // the password was reset through a reset token
+)
+
+// Session is a login of a user on a device. Its refresh tokens form one family:
+// each refresh rotates the current token, and presenting a rotated token again
+// revokes the whole session.
+type Session struct {
+	ID         string `json:"id" gorm:"primaryKey"`
+	UserID     string `json:"user_id" gorm:"size:36;index"`
+	DeviceName string `json:"device_name" gorm:"size:100"`
+	UserAgent  string `json:"user_agent" gorm:"size:255"`
+	IPAddress  string `json:"ip_address" gorm:"size:45"`
+	// CurrentTokenID is the ID of the only refresh token of the session that may be used
+	CurrentTokenID string     `json:"-" gorm:"size:64"`
+	LastSeenAt     time.Time  `json:"last_seen_at"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+	RevokedReason  string     `json:"revoked_reason" gorm:"size:50"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
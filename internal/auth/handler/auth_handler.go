@@ -2,8 +2,10 @@ package handler
 
 import (
 	"net/http"
+	"strings"
 
 	"cdk-office/internal/auth/service"
+	"cdk-office/pkg/config"
 	"cdk-office/pkg/jwt"
 	"github.com/gin-gonic/gin"
 )
@@ -15,6 +17,7 @@ type AuthHandlerInterface interface {
 	GetUserInfo(c *gin.Context)
 	Logout(c *gin.Context)
 	RefreshToken(c *gin.Context)
+	UnlockUser(c *gin.Context)
 }
 
 // AuthHandler implements the AuthHandlerInterface
@@ -23,9 +26,9 @@ type AuthHandler struct {
 }
 
 // NewAuthHandler creates a new instance of AuthHandler
-func NewAuthHandler(jwtManager *jwt.JWTManager) *AuthHandler {
+func NewAuthHandler(jwtManager *jwt.JWTManager, cfg *config.Config) *AuthHandler {
 	return &AuthHandler{
-		authService: service.NewAuthService(jwtManager),
+		authService: service.NewAuthService(jwtManager, cfg),
 	}
 }
 
@@ -41,8 +44,9 @@ type RegisterRequest struct {
 
 // LoginRequest represents the request for user login
 type LoginRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username   string `json:"username" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+	DeviceName string `json:"device_name"` // shown in the session list
 }
 
 // Register handles user registration
@@ -62,7 +66,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		RealName: req.RealName,
 		IDCard:   req.IDCard,
 	}); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(registerErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -81,9 +85,10 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	resp, err := h.authService.Login(c.Request.Context(), &service.LoginRequest{
 		Username: req.Username,
 		Password: req.Password,
+		Client:   clientInfo(c, req.DeviceName),
 	})
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		c.JSON(loginErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -148,11 +153,72 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	}
 
 	// Call service to refresh token
-	resp, err := h.authService.RefreshToken(c.Request.Context(), req.RefreshToken)
+	resp, err := h.authService.RefreshToken(c.Request.Context(), req.RefreshToken, clientInfo(c, ""))
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, resp)
-}
\ No newline at end of file
+}
+
+// UnlockUserRequest represents the request for lifting a login lockout
+type UnlockUserRequest struct {
+	IPAddress string `json:"ip_address"` // also unlock logins from this address
+}
+
+// UnlockUser handles an administrator lifting the login lockout of a user
+func (h *AuthHandler) UnlockUser(c *gin.Context) {
+	if c.GetString("role") != adminRole {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only administrators can unlock users"})
+		return
+	}
+
+	var req UnlockUserRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := h.authService.UnlockUser(c.Request.Context(), c.Param("id"), req.IPAddress); err != nil {
+		if err.Error() == "user not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user unlocked successfully"})
+}
+
+// registerErrorStatus maps registration errors to HTTP status codes
+func registerErrorStatus(err error) int {
+	if strings.HasPrefix(err.Error(), "invalid password") {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// loginErrorStatus maps login errors to HTTP status codes
+func loginErrorStatus(err error) int {
+	switch err.Error() {
+	case "account is temporarily locked, try again later", "too many failed login attempts, try again later":
+		return http.StatusTooManyRequests
+	case "failed to login":
+		return http.StatusInternalServerError
+	default:
+		return http.StatusUnauthorized
+	}
+}
+
+// clientInfo describes the device a request comes from for its login session
+func clientInfo(c *gin.Context, deviceName string) *service.ClientInfo {
+	return &service.ClientInfo{
+		DeviceName: deviceName,
+		UserAgent:  c.Request.UserAgent(),
+		IPAddress:  c.ClientIP(),
+	}
+}
@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"cdk-office/internal/auth/service"
+	"cdk-office/pkg/config"
+	"cdk-office/pkg/jwt"
+	"github.com/gin-gonic/gin"
+)
+
+// adminRole is the role allowed to manage MFA policies and the sessions of other users
+const adminRole = "admin"
+
+// MFAHandlerInterface defines the interface for two-factor authentication handler
+type MFAHandlerInterface interface {
+	SetupLogin(c *gin.Context)
+	CompleteLogin(c *gin.Context)
+	GetStatus(c *gin.Context)
+	Enroll(c *gin.Context)
+	Enable(c *gin.Context)
+	Disable(c *gin.Context)
+	RegenerateRecoveryCodes(c *gin.Context)
+	ListRolePolicies(c *gin.Context)
+	SetRolePolicy(c *gin.Context)
+}
+
+// MFAHandler implements the MFAHandlerInterface
+type MFAHandler struct {
+	mfaService service.MFAServiceInterface
+}
+
+// NewMFAHandler creates a new instance of MFAHandler
+func NewMFAHandler(jwtManager *jwt.JWTManager, cfg *config.MFAConfig) *MFAHandler {
+	return &MFAHandler{
+		mfaService: service.NewMFAService(jwtManager, cfg),
+	}
+}
+
+// NewMFAHandlerWithService creates a new instance of MFAHandler with a custom service
+func NewMFAHandlerWithService(mfaService service.MFAServiceInterface) *MFAHandler {
+	return &MFAHandler{
+		mfaService: mfaService,
+	}
+}
+
+// MFASetupRequest represents the request for enrolling a second factor during login
+type MFASetupRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// MFALoginRequest represents the request for completing a login with a second factor
+type MFALoginRequest struct {
+	MFAToken   string `json:"mfa_token" binding:"required"`
+	Code       string `json:"code" binding:"required"` // TOTP code or recovery code
+	DeviceName string `json:"device_name"`             // shown in the session list
+}
+
+// MFACodeRequest represents a request confirmed with a TOTP or recovery code
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// MFARolePolicyRequest represents the request for setting the MFA policy of a role
+type MFARolePolicyRequest struct {
+	Required bool `json:"required"`
+}
+
+// SetupLogin handles enrolling the second factor required by the role of a user logging in
+func (h *MFAHandler) SetupLogin(c *gin.Context) {
+	var req MFASetupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	enrollment, err := h.mfaService.SetupLogin(c.Request.Context(), req.MFAToken)
+	if err != nil {
+		c.JSON(mfaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// CompleteLogin handles the second phase of a login
+func (h *MFAHandler) CompleteLogin(c *gin.Context) {
+	var req MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := h.mfaService.CompleteLogin(c.Request.Context(), req.MFAToken, req.Code, clientInfo(c, req.DeviceName))
+	if err != nil {
+		c.JSON(mfaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetStatus handles getting the second factor status of the current user
+func (h *MFAHandler) GetStatus(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	status, err := h.mfaService.GetStatus(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(mfaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// Enroll handles enrolling a TOTP secret for the current user
+func (h *MFAHandler) Enroll(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	enrollment, err := h.mfaService.Enroll(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(mfaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// Enable handles confirming the enrolled TOTP secret of the current user
+func (h *MFAHandler) Enable(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recoveryCodes, err := h.mfaService.Enable(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		c.JSON(mfaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": recoveryCodes})
+}
+
+// Disable handles removing the second factor of the current user
+func (h *MFAHandler) Disable(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.mfaService.Disable(c.Request.Context(), userID, req.Code); err != nil {
+		c.JSON(mfaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "mfa disabled successfully"})
+}
+
+// RegenerateRecoveryCodes handles replacing the recovery codes of the current user
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recoveryCodes, err := h.mfaService.RegenerateRecoveryCodes(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		c.JSON(mfaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": recoveryCodes})
+}
+
+// ListRolePolicies handles listing the MFA policies of roles
+func (h *MFAHandler) ListRolePolicies(c *gin.Context) {
+	if c.GetString("role") != adminRole {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only administrators can manage mfa policies"})
+		return
+	}
+
+	policies, err := h.mfaService.ListRolePolicies(c.Request.Context())
+	if err != nil {
+		c.JSON(mfaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, policies)
+}
+
+// SetRolePolicy handles setting whether a role requires a second factor
+func (h *MFAHandler) SetRolePolicy(c *gin.Context) {
+	if c.GetString("role") != adminRole {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only administrators can manage mfa policies"})
+		return
+	}
+
+	var req MFARolePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, err := h.mfaService.SetRolePolicy(c.Request.Context(), c.Param("role"), req.Required, c.GetString("user_id"))
+	if err != nil {
+		c.JSON(mfaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// mfaErrorStatus maps MFA service errors to HTTP status codes
+func mfaErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case msg == "invalid mfa token" || msg == "invalid mfa code":
+		return http.StatusUnauthorized
+	case strings.HasPrefix(msg, "too many failed mfa attempts"):
+		return http.StatusTooManyRequests
+	case msg == "user not found":
+		return http.StatusNotFound
+	case msg == "mfa is already enabled" || msg == "mfa is not enabled" ||
+		msg == "mfa enrollment not started" || msg == "mfa is required for your role":
+		return http.StatusConflict
+	case msg == "invalid role":
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -2,8 +2,11 @@ package handler
 
 import (
 	"net/http"
+	"strings"
 
 	"cdk-office/internal/auth/service"
+	"cdk-office/pkg/config"
+	"cdk-office/pkg/jwt"
 	"github.com/gin-gonic/gin"
 )
 
@@ -11,6 +14,8 @@ import (
 type PasswordHandlerInterface interface {
 	PasswordLogin(c *gin.Context)
 	ChangePassword(c *gin.Context)
+	ForgotPassword(c *gin.Context)
+	ResetPassword(c *gin.Context)
 }
 
 // PasswordHandler implements the PasswordHandlerInterface
@@ -19,16 +24,17 @@ type PasswordHandler struct {
 }
 
 // NewPasswordHandler creates a new instance of PasswordHandler
-func NewPasswordHandler() *PasswordHandler {
+func NewPasswordHandler(jwtManager *jwt.JWTManager, cfg *config.Config) *PasswordHandler {
 	return &PasswordHandler{
-		passwordService: service.NewPasswordService(),
+		passwordService: service.NewPasswordService(jwtManager, cfg),
 	}
 }
 
 // PasswordLoginRequest represents the request for password login
 type PasswordLoginRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username   string `json:"username" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+	DeviceName string `json:"device_name"` // shown in the session list
 }
 
 // ChangePasswordRequest represents the request for changing password
@@ -37,6 +43,18 @@ type ChangePasswordRequest struct {
 	NewPassword string `json:"new_password" binding:"required,min=6"`
 }
 
+// ForgotPasswordRequest represents the request for a password reset message
+type ForgotPasswordRequest struct {
+	Login   string `json:"login" binding:"required"` // username, email or phone
+	Channel string `json:"channel"`                  // email (default) or sms
+}
+
+// ResetPasswordRequest represents the request for setting a password with a reset token
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
 // PasswordLogin handles password login
 func (h *PasswordHandler) PasswordLogin(c *gin.Context) {
 	var req PasswordLoginRequest
@@ -49,9 +67,10 @@ func (h *PasswordHandler) PasswordLogin(c *gin.Context) {
 	resp, err := h.passwordService.PasswordLogin(c.Request.Context(), &service.PasswordLoginRequest{
 		Username: req.Username,
 		Password: req.Password,
+		Client:   clientInfo(c, req.DeviceName),
 	})
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		c.JSON(loginErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -74,9 +93,63 @@ func (h *PasswordHandler) ChangePassword(c *gin.Context) {
 
 	// Call service to change password
 	if err := h.passwordService.ChangePassword(c.Request.Context(), userID, req.OldPassword, req.NewPassword); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(passwordErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "password changed successfully"})
-}
\ No newline at end of file
+}
+
+// ForgotPassword handles sending a password reset message
+func (h *PasswordHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.passwordService.RequestPasswordReset(c.Request.Context(), &service.PasswordResetRequest{
+		Login:     req.Login,
+		Channel:   req.Channel,
+		IPAddress: c.ClientIP(),
+	}); err != nil {
+		c.JSON(passwordErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// The same answer whether or not the account exists
+	c.JSON(http.StatusOK, gin.H{"message": "if the account exists, a password reset message has been sent"})
+}
+
+// ResetPassword handles setting a new password with a reset token
+func (h *PasswordHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.passwordService.ResetPassword(c.Request.Context(), req.Token, req.NewPassword); err != nil {
+		c.JSON(passwordErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password reset successfully"})
+}
+
+// passwordErrorStatus maps password service errors to HTTP status codes
+func passwordErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.HasPrefix(msg, "invalid password") || msg == "invalid channel" || msg == "invalid or expired reset token":
+		return http.StatusBadRequest
+	case msg == "invalid old password":
+		return http.StatusUnauthorized
+	case msg == "user not found":
+		return http.StatusNotFound
+	case msg == "too many password reset requests, try again later":
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"net/http"
+
+	"cdk-office/internal/auth/domain"
+	"cdk-office/internal/auth/service"
+	"cdk-office/pkg/jwt"
+	"github.com/gin-gonic/gin"
+)
+
+// SessionHandlerInterface defines the interface for login session handler
+type SessionHandlerInterface interface {
+	ListSessions(c *gin.Context)
+	RevokeSession(c *gin.Context)
+	ForceLogout(c *gin.Context)
+}
+
+// SessionHandler implements the SessionHandlerInterface
+type SessionHandler struct {
+	sessionService service.SessionServiceInterface
+}
+
+// NewSessionHandler creates a new instance of SessionHandler
+func NewSessionHandler(jwtManager *jwt.JWTManager) *SessionHandler {
+	return &SessionHandler{
+		sessionService: service.NewSessionService(jwtManager),
+	}
+}
+
+// NewSessionHandlerWithService creates a new instance of SessionHandler with a custom service
+func NewSessionHandlerWithService(sessionService service.SessionServiceInterface) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+	}
+}
+
+// ListSessions handles listing the active sessions of the current user
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	sessions, err := h.sessionService.ListSessions(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions":           sessions,
+		"current_session_id": c.GetString("session_id"),
+	})
+}
+
+// RevokeSession handles revoking a session of the current user
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	if err := h.sessionService.RevokeSession(c.Request.Context(), userID, c.Param("id"), domain.SessionRevokedByUser); err != nil {
+		c.JSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "session revoked successfully"})
+}
+
+// ForceLogout handles an administrator revoking every session of a user
+func (h *SessionHandler) ForceLogout(c *gin.Context) {
+	if c.GetString("role") != adminRole {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only administrators can log other users out"})
+		return
+	}
+
+	revoked, err := h.sessionService.RevokeUserSessions(c.Request.Context(), c.Param("id"), domain.SessionRevokedForced)
+	if err != nil {
+		c.JSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user logged out successfully", "revoked_sessions": revoked})
+}
+
+// sessionErrorStatus maps session service errors to HTTP status codes
+func sessionErrorStatus(err error) int {
+	switch err.Error() {
+	case "session not found", "user not found":
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"net/http"
+
+	"cdk-office/internal/auth/service"
+	"cdk-office/pkg/config"
+	"cdk-office/pkg/jwt"
+	"github.com/gin-gonic/gin"
+)
+
+// SSOHandlerInterface defines the interface for single sign-on handler
+type SSOHandlerInterface interface {
+	ListProviders(c *gin.Context)
+	Authorize(c *gin.Context)
+	Callback(c *gin.Context)
+	PasswordLogin(c *gin.Context)
+}
+
+// SSOHandler implements the SSOHandlerInterface
+type SSOHandler struct {
+	ssoService service.SSOServiceInterface
+}
+
+// NewSSOHandler creates a new instance of SSOHandler
+func NewSSOHandler(jwtManager *jwt.JWTManager, cfg *config.Config) *SSOHandler {
+	return &SSOHandler{
+		ssoService: service.NewSSOService(jwtManager, cfg),
+	}
+}
+
+// SSOCallbackRequest represents the request for completing a single sign-on
+// with what the provider redirected back with
+type SSOCallbackRequest struct {
+	Code       string `json:"code" binding:"required"`
+	State      string `json:"state" binding:"required"`
+	DeviceName string `json:"device_name"` // shown in the session list
+}
+
+// SSOPasswordLoginRequest represents the request for logging in with the
+// password of a directory account
+type SSOPasswordLoginRequest struct {
+	Username   string `json:"username" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+	DeviceName string `json:"device_name"` // shown in the session list
+}
+
+// ListProviders handles listing the identity providers
+func (h *SSOHandler) ListProviders(c *gin.Context) {
+	providers := h.ssoService.ListProviders(c.Request.Context())
+	if providers == nil {
+		providers = []*service.SSOProviderInfo{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"providers": providers})
+}
+
+// Authorize handles starting a login at an identity provider
+func (h *SSOHandler) Authorize(c *gin.Context) {
+	authorization, err := h.ssoService.BeginLogin(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		c.JSON(ssoErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, authorization)
+}
+
+// Callback handles completing a login at an identity provider
+func (h *SSOHandler) Callback(c *gin.Context) {
+	var req SSOCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := h.ssoService.CompleteLogin(c.Request.Context(), c.Param("provider"), req.State, req.Code, clientInfo(c, req.DeviceName))
+	if err != nil {
+		c.JSON(ssoErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// PasswordLogin handles logging in with the password of a directory account
+func (h *SSOHandler) PasswordLogin(c *gin.Context) {
+	var req SSOPasswordLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := h.ssoService.PasswordLogin(c.Request.Context(), c.Param("provider"), &service.LoginRequest{
+		Username: req.Username,
+		Password: req.Password,
+		Client:   clientInfo(c, req.DeviceName),
+	})
+	if err != nil {
+		c.JSON(ssoErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ssoErrorStatus maps single sign-on service errors to HTTP status codes
+func ssoErrorStatus(err error) int {
+	switch err.Error() {
+	case "invalid or expired sso state", "invalid authorization code", "invalid username or password":
+		return http.StatusUnauthorized
+	case "account is temporarily locked, try again later", "too many failed login attempts, try again later":
+		return http.StatusTooManyRequests
+	case "identity provider not found":
+		return http.StatusNotFound
+	case "identity provider does not support redirect login", "identity provider does not support password login",
+		"identity provider did not return an email address":
+		return http.StatusBadRequest
+	case "no account is linked to this identity", "user account is not active":
+		return http.StatusForbidden
+	case "an account with this email already exists",
+		"user is already linked to another account of this identity provider":
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
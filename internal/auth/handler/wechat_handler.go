@@ -4,12 +4,18 @@ import (
 	"net/http"
 
 	"cdk-office/internal/auth/service"
+	"cdk-office/pkg/config"
+	"cdk-office/pkg/jwt"
 	"github.com/gin-gonic/gin"
 )
 
 // WeChatHandlerInterface defines the interface for WeChat authentication handler
 type WeChatHandlerInterface interface {
 	WeChatLogin(c *gin.Context)
+	BindLogin(c *gin.Context)
+	BindWeChat(c *gin.Context)
+	UnbindWeChat(c *gin.Context)
+	BindPhoneNumber(c *gin.Context)
 }
 
 // WeChatHandler implements the WeChatHandlerInterface
@@ -18,17 +24,38 @@ type WeChatHandler struct {
 }
 
 // NewWeChatHandler creates a new instance of WeChatHandler
-func NewWeChatHandler() *WeChatHandler {
+func NewWeChatHandler(jwtManager *jwt.JWTManager, cfg *config.Config) *WeChatHandler {
 	return &WeChatHandler{
-		wechatService: service.NewWeChatService(),
+		wechatService: service.NewWeChatService(jwtManager, cfg),
 	}
 }
 
 // WeChatLoginRequest represents the request for WeChat login
 type WeChatLoginRequest struct {
+	Code       string `json:"code" binding:"required"`
+	DeviceName string `json:"device_name"` // shown in the session list
+}
+
+// WeChatBindLoginRequest represents the request for binding a WeChat account while logging in
+type WeChatBindLoginRequest struct {
+	BindToken  string `json:"bind_token" binding:"required"`
+	Username   string `json:"username" binding:"required"`
+	Password   string `json:"password" binding:"required"`
+	DeviceName string `json:"device_name"` // shown in the session list
+}
+
+// WeChatBindRequest represents the request for binding a WeChat account to the current user
+type WeChatBindRequest struct {
 	Code string `json:"code" binding:"required"`
 }
 
+// WeChatPhoneRequest represents the request for binding the phone number shared from the mini-program
+type WeChatPhoneRequest struct {
+	Code          string `json:"code" binding:"required"`
+	EncryptedData string `json:"encrypted_data" binding:"required"`
+	IV            string `json:"iv" binding:"required"`
+}
+
 // WeChatLogin handles WeChat login
 func (h *WeChatHandler) WeChatLogin(c *gin.Context) {
 	var req WeChatLoginRequest
@@ -38,11 +65,119 @@ func (h *WeChatHandler) WeChatLogin(c *gin.Context) {
 	}
 
 	// Call service to login user via WeChat
-	resp, err := h.wechatService.WeChatLogin(c.Request.Context(), req.Code)
+	resp, err := h.wechatService.WeChatLogin(c.Request.Context(), req.Code, clientInfo(c, req.DeviceName))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		c.JSON(wechatErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, resp)
-}
\ No newline at end of file
+}
+
+// BindLogin handles binding a WeChat account to a password account while logging in
+func (h *WeChatHandler) BindLogin(c *gin.Context) {
+	var req WeChatBindLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := h.wechatService.BindLogin(c.Request.Context(), &service.WeChatBindLoginRequest{
+		BindToken: req.BindToken,
+		Username:  req.Username,
+		Password:  req.Password,
+		Client:    clientInfo(c, req.DeviceName),
+	})
+	if err != nil {
+		c.JSON(wechatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// BindWeChat handles binding a WeChat account to the current user
+func (h *WeChatHandler) BindWeChat(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req WeChatBindRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.wechatService.BindWeChat(c.Request.Context(), userID, req.Code); err != nil {
+		c.JSON(wechatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "WeChat account bound successfully"})
+}
+
+// UnbindWeChat handles removing the WeChat binding of the current user
+func (h *WeChatHandler) UnbindWeChat(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	if err := h.wechatService.UnbindWeChat(c.Request.Context(), userID); err != nil {
+		c.JSON(wechatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "WeChat account unbound successfully"})
+}
+
+// BindPhoneNumber handles storing the phone number shared from the mini-program
+func (h *WeChatHandler) BindPhoneNumber(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req WeChatPhoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	phone, err := h.wechatService.BindPhoneNumber(c.Request.Context(), userID, &service.WeChatPhoneRequest{
+		Code:          req.Code,
+		EncryptedData: req.EncryptedData,
+		IV:            req.IV,
+	})
+	if err != nil {
+		c.JSON(wechatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"phone": phone})
+}
+
+// wechatErrorStatus maps WeChat service errors to HTTP status codes
+func wechatErrorStatus(err error) int {
+	switch err.Error() {
+	case "invalid WeChat code", "invalid or expired bind token", "invalid username or password":
+		return http.StatusUnauthorized
+	case "account is temporarily locked, try again later", "too many failed login attempts, try again later":
+		return http.StatusTooManyRequests
+	case "user account is not active":
+		return http.StatusForbidden
+	case "user not found", "WeChat account not bound":
+		return http.StatusNotFound
+	case "WeChat account is already bound to a user", "user is already bound to a WeChat account",
+		"cannot unbind the only login method of the user":
+		return http.StatusConflict
+	case "invalid phone number data":
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"cdk-office/pkg/config"
+	"cdk-office/pkg/ldap"
+)
+
+// LDAPProvider signs users in by binding to a directory with their password
+type LDAPProvider struct {
+	config *config.SSOProviderConfig
+}
+
+// NewLDAPProvider creates a new instance of LDAPProvider
+func NewLDAPProvider(cfg *config.SSOProviderConfig) *LDAPProvider {
+	return &LDAPProvider{config: cfg}
+}
+
+// Name returns the name of the provider
+func (p *LDAPProvider) Name() string { return p.config.Name }
+
+// Type returns the type of the provider
+func (p *LDAPProvider) Type() string { return config.SSOProviderLDAP }
+
+// DisplayName returns the name shown on the login page
+func (p *LDAPProvider) DisplayName() string { return p.config.DisplayName }
+
+// Authenticate looks the user up with the service account and binds as the
+// entry found to check the password
+func (p *LDAPProvider) Authenticate(ctx context.Context, username, password string) (*Identity, error) {
+	// An empty password would be an unauthenticated bind, which servers accept
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := ldap.Dial(p.config.URL, p.config.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to directory: %w", err)
+	}
+	defer conn.Close()
+
+	if p.config.BindDN != "" {
+		if err := conn.Bind(p.config.BindDN, p.config.BindPassword); err != nil {
+			return nil, fmt.Errorf("failed to bind service account: %w", err)
+		}
+	}
+
+	entries, err := conn.Search(&ldap.SearchRequest{
+		BaseDN: p.config.BaseDN,
+		Scope:  ldap.ScopeWholeSubtree,
+		Filter: fmt.Sprintf(p.config.UserFilter, ldap.EscapeFilter(username)),
+		Attributes: []string{
+			p.config.SubjectAttr, p.config.UsernameAttr, p.config.EmailAttr,
+			p.config.NameAttr, p.config.PhoneAttr, p.config.GroupAttr,
+		},
+		SizeLimit: 2,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search directory: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, ErrInvalidCredentials
+	}
+	if len(entries) > 1 {
+		return nil, errors.New("user filter matches more than one entry")
+	}
+	entry := entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsInvalidCredentials(err) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("failed to bind user: %w", err)
+	}
+
+	identity := &Identity{
+		Provider:      p.config.Name,
+		Subject:       entry.Get(p.config.SubjectAttr),
+		Username:      entry.Get(p.config.UsernameAttr),
+		Email:         entry.Get(p.config.EmailAttr),
+		EmailVerified: true, // maintained by the directory administrators
+		RealName:      entry.Get(p.config.NameAttr),
+		Phone:         entry.Get(p.config.PhoneAttr),
+		Groups:        entry.GetAll(p.config.GroupAttr),
+	}
+	if identity.Subject == "" {
+		identity.Subject = entry.DN
+	}
+	if identity.Username == "" {
+		identity.Username = username
+	}
+	return identity, nil
+}
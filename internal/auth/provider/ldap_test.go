@@ -0,0 +1,81 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"cdk-office/pkg/config"
+	"cdk-office/pkg/ldap/ldaptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLDAPAuthenticate(t *testing.T) {
+	server := ldaptest.NewServer(
+		&ldaptest.Entry{DN: "cn=service,dc=example,dc=com", Password: "service-secret"},
+		&ldaptest.Entry{
+			DN:       "uid=alice,ou=people,dc=example,dc=com",
+			Password: "alice-secret",
+			Attributes: map[string][]string{
+				"uid":       {"alice"},
+				"entryUUID": {"uuid-alice"},
+				"mail":      {"alice@example.com"},
+				"cn":        {"Alice"},
+				"memberOf":  {"cn=hr,ou=groups,dc=example,dc=com"},
+			},
+		},
+		&ldaptest.Entry{
+			DN:         "uid=bob,ou=people,dc=example,dc=com",
+			Password:   "bob-secret",
+			Attributes: map[string][]string{"uid": {"bob"}},
+		},
+	)
+	defer server.Close()
+
+	p := NewLDAPProvider(&config.SSOProviderConfig{
+		Name:         "directory",
+		Type:         config.SSOProviderLDAP,
+		URL:          server.URL,
+		BindDN:       "cn=service,dc=example,dc=com",
+		BindPassword: "service-secret",
+		BaseDN:       "ou=people,dc=example,dc=com",
+		UserFilter:   "(uid=%s)",
+		UsernameAttr: "uid",
+		EmailAttr:    "mail",
+		NameAttr:     "cn",
+		PhoneAttr:    "telephoneNumber",
+		GroupAttr:    "memberOf",
+		SubjectAttr:  "entryUUID",
+	})
+	ctx := context.Background()
+
+	identity, err := p.Authenticate(ctx, "alice", "alice-secret")
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{
+		Provider:      "directory",
+		Subject:       "uuid-alice",
+		Username:      "alice",
+		Email:         "alice@example.com",
+		EmailVerified: true,
+		RealName:      "Alice",
+		Groups:        []string{"cn=hr,ou=groups,dc=example,dc=com"},
+	}, identity)
+
+	// Entries without the subject attribute are identified by their DN
+	identity, err = p.Authenticate(ctx, "bob", "bob-secret")
+	require.NoError(t, err)
+	assert.Equal(t, "uid=bob,ou=people,dc=example,dc=com", identity.Subject)
+
+	_, err = p.Authenticate(ctx, "alice", "wrong")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = p.Authenticate(ctx, "alice", "")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = p.Authenticate(ctx, "carol", "secret")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	// The username cannot inject filter syntax
+	_, err = p.Authenticate(ctx, "*", "alice-secret")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = p.Authenticate(ctx, "alice)(uid=*", "alice-secret")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
@@ -0,0 +1,354 @@
+package provider
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"cdk-office/pkg/config"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// ErrInvalidCode is returned when the provider rejects an authorization code
+var ErrInvalidCode = errors.New("invalid authorization code")
+
+// OIDCProvider signs users in with the OpenID Connect authorization code flow with PKCE
+type OIDCProvider struct {
+	config     *config.SSOProviderConfig
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]interface{} // signing keys by key ID
+}
+
+// oidcDiscovery is the part of the provider metadata in use
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// jsonWebKey is a key of a JWK set
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// NewOIDCProvider creates a new instance of OIDCProvider. The provider
+// metadata is discovered on first use.
+func NewOIDCProvider(cfg *config.SSOProviderConfig) *OIDCProvider {
+	return &OIDCProvider{
+		config:     cfg,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name returns the name of the provider
+func (p *OIDCProvider) Name() string { return p.config.Name }
+
+// Type returns the type of the provider
+func (p *OIDCProvider) Type() string { return config.SSOProviderOIDC }
+
+// DisplayName returns the name shown on the login page
+func (p *OIDCProvider) DisplayName() string { return p.config.DisplayName }
+
+// AuthCodeURL returns the authorization endpoint URL for a login
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange redeems an authorization code and verifies the ID token issued for it
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"client_secret": {p.config.ClientSecret},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+	if token.Error == "invalid_grant" {
+		return nil, ErrInvalidCode
+	}
+	if resp.StatusCode != http.StatusOK || token.Error != "" {
+		return nil, fmt.Errorf("token request failed with status %d: %s %s", resp.StatusCode, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	claims, err := p.verify(ctx, discovery, token.IDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return p.identity(claims)
+}
+
+// verify checks the signature, issuer, audience, expiry and nonce of an ID token
+func (p *OIDCProvider) verify(ctx context.Context, discovery *oidcDiscovery, idToken, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, discovery, kid)
+	}, jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}))
+	if err != nil {
+		return nil, fmt.Errorf("invalid id token: %w", err)
+	}
+
+	if !claims.VerifyIssuer(discovery.Issuer, true) {
+		return nil, errors.New("invalid id token: unexpected issuer")
+	}
+	if !claims.VerifyAudience(p.config.ClientID, true) {
+		return nil, errors.New("invalid id token: unexpected audience")
+	}
+	if _, ok := claims["exp"]; !ok {
+		return nil, errors.New("invalid id token: missing expiry")
+	}
+	if claimNonce, _ := claims["nonce"].(string); claimNonce != nonce {
+		return nil, errors.New("invalid id token: nonce mismatch")
+	}
+	return claims, nil
+}
+
+// identity maps the claims of an ID token to an identity
+func (p *OIDCProvider) identity(claims jwt.MapClaims) (*Identity, error) {
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, errors.New("invalid id token: missing subject")
+	}
+
+	identity := &Identity{
+		Provider:      p.config.Name,
+		Subject:       subject,
+		Username:      stringClaim(claims, "preferred_username"),
+		Email:         stringClaim(claims, "email"),
+		EmailVerified: boolClaim(claims, "email_verified"),
+		RealName:      stringClaim(claims, "name"),
+		Phone:         stringClaim(claims, "phone_number"),
+	}
+	switch groups := claims[p.config.GroupsClaim].(type) {
+	case string:
+		identity.Groups = []string{groups}
+	case []interface{}:
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				identity.Groups = append(identity.Groups, name)
+			}
+		}
+	}
+	return identity, nil
+}
+
+// discover fetches the provider metadata once
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	issuer := strings.TrimSuffix(p.config.IssuerURL, "/")
+	var discovery oidcDiscovery
+	if err := p.getJSON(ctx, issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("failed to discover provider: %w", err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("provider issuer %q does not match %q", discovery.Issuer, p.config.IssuerURL)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("provider metadata is incomplete")
+	}
+
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// key returns a signing key, refetching the key set for unknown key IDs to
+// follow key rotation. Tokens without a key ID need a single-key set.
+func (p *OIDCProvider) key(ctx context.Context, discovery *oidcDiscovery, kid string) (interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	lookup := func() interface{} {
+		if kid == "" && len(p.keys) == 1 {
+			for _, key := range p.keys {
+				return key
+			}
+		}
+		return p.keys[kid]
+	}
+	if key := lookup(); key != nil {
+		return key, nil
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(ctx, discovery.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+	keys := make(map[string]interface{})
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	p.keys = keys
+
+	if key := lookup(); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// getJSON fetches and decodes a JSON document
+func (p *OIDCProvider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// publicKey decodes an RSA or EC public key
+func (k *jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// decodeBigInt decodes a base64url encoded big-endian integer
+func decodeBigInt(value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// stringClaim returns a string claim, or an empty string
+func stringClaim(claims jwt.MapClaims, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// boolClaim returns a boolean claim, which some providers send as a string
+func boolClaim(claims jwt.MapClaims, name string) bool {
+	switch value := claims[name].(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	default:
+		return false
+	}
+}
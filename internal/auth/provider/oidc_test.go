@@ -0,0 +1,190 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"cdk-office/pkg/config"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeIdP is an OpenID provider issuing ID tokens for authorizations approved
+// through its authorization endpoint
+type fakeIdP struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims jwt.MapClaims // claims of the next approved authorization
+
+	mu     sync.Mutex
+	grants map[string]url.Values // authorization request by code
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	idp := &fakeIdP{key: key, grants: make(map[string]url.Values)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "key-1",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		code := "code-" + r.URL.Query().Get("state")
+		idp.mu.Lock()
+		idp.grants[code] = r.URL.Query()
+		idp.mu.Unlock()
+		http.Redirect(w, r, r.URL.Query().Get("redirect_uri")+"?code="+code+"&state="+r.URL.Query().Get("state"), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		idp.mu.Lock()
+		grant, ok := idp.grants[r.PostForm.Get("code")]
+		delete(idp.grants, r.PostForm.Get("code"))
+		idp.mu.Unlock()
+		if !ok || r.PostForm.Get("client_secret") != "client-secret" ||
+			CodeChallenge(r.PostForm.Get("code_verifier")) != grant.Get("code_challenge") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+
+		claims := jwt.MapClaims{
+			"iss":   idp.URL,
+			"aud":   grant.Get("client_id"),
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"nonce": grant.Get("nonce"),
+		}
+		for name, value := range idp.claims {
+			claims[name] = value
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "key-1"
+		signed, err := token.SignedString(idp.key)
+		require.NoError(t, err)
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "access", "id_token": signed})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+// authorize follows an authorization URL and returns the code issued
+func (idp *fakeIdP) authorize(t *testing.T, authURL string) string {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	return location.Query().Get("code")
+}
+
+func newTestOIDCProvider(idp *fakeIdP) *OIDCProvider {
+	return NewOIDCProvider(&config.SSOProviderConfig{
+		Name:         "corp",
+		Type:         config.SSOProviderOIDC,
+		IssuerURL:    idp.URL,
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		RedirectURL:  "https://office.example.com/sso/callback",
+		Scopes:       []string{"openid", "email"},
+		GroupsClaim:  "groups",
+	})
+}
+
+func TestOIDCExchange(t *testing.T) {
+	idp := newFakeIdP(t)
+	idp.claims = jwt.MapClaims{
+		"sub":                "subject-1",
+		"preferred_username": "alice",
+		"email":              "alice@example.com",
+		"email_verified":     true,
+		"name":               "Alice",
+		"groups":             []string{"hr", "staff"},
+	}
+	p := newTestOIDCProvider(idp)
+	ctx := context.Background()
+
+	verifier, err := NewCodeVerifier()
+	require.NoError(t, err)
+	authURL, err := p.AuthCodeURL(ctx, "state-1", "nonce-1", CodeChallenge(verifier))
+	require.NoError(t, err)
+	query, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, "S256", query.Query().Get("code_challenge_method"))
+	assert.Equal(t, "openid email", query.Query().Get("scope"))
+
+	identity, err := p.Exchange(ctx, idp.authorize(t, authURL), verifier, "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, &Identity{
+		Provider:      "corp",
+		Subject:       "subject-1",
+		Username:      "alice",
+		Email:         "alice@example.com",
+		EmailVerified: true,
+		RealName:      "Alice",
+		Groups:        []string{"hr", "staff"},
+	}, identity)
+
+	// Codes are single-use and bound to the verifier
+	_, err = p.Exchange(ctx, "code-state-1", verifier, "nonce-1")
+	assert.ErrorIs(t, err, ErrInvalidCode)
+	authURL, err = p.AuthCodeURL(ctx, "state-2", "nonce-2", CodeChallenge(verifier))
+	require.NoError(t, err)
+	_, err = p.Exchange(ctx, idp.authorize(t, authURL), "other-verifier", "nonce-2")
+	assert.ErrorIs(t, err, ErrInvalidCode)
+}
+
+func TestOIDCVerifiesIDToken(t *testing.T) {
+	idp := newFakeIdP(t)
+	p := newTestOIDCProvider(idp)
+	ctx := context.Background()
+	verifier, err := NewCodeVerifier()
+	require.NoError(t, err)
+
+	exchange := func(claims jwt.MapClaims, nonce string) error {
+		idp.claims = claims
+		authURL, err := p.AuthCodeURL(ctx, "state", "nonce", CodeChallenge(verifier))
+		require.NoError(t, err)
+		_, err = p.Exchange(ctx, idp.authorize(t, authURL), verifier, nonce)
+		return err
+	}
+
+	assert.NoError(t, exchange(jwt.MapClaims{"sub": "subject-1"}, "nonce"))
+	assert.ErrorContains(t, exchange(jwt.MapClaims{"sub": "subject-1"}, "other-nonce"), "nonce mismatch")
+	assert.ErrorContains(t, exchange(jwt.MapClaims{"sub": "subject-1", "aud": "other-client"}, "nonce"), "unexpected audience")
+	assert.ErrorContains(t, exchange(jwt.MapClaims{"sub": "subject-1", "iss": "https://evil.example.com"}, "nonce"), "unexpected issuer")
+	assert.ErrorContains(t, exchange(jwt.MapClaims{"sub": "subject-1", "exp": time.Now().Add(-time.Minute).Unix()}, "nonce"), "expired")
+	assert.ErrorContains(t, exchange(jwt.MapClaims{}, "nonce"), "missing subject")
+
+	// Tokens signed by another key are rejected
+	idp.key, err = rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	assert.ErrorContains(t, exchange(jwt.MapClaims{"sub": "subject-1"}, "nonce"), "invalid id token")
+}
@@ -0,0 +1,123 @@
+// Package provider implements the external identity providers users can sign in with.
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"cdk-office/pkg/config"
+	"cdk-office/pkg/logger"
+)
+
+// ErrInvalidCredentials is returned when a provider rejects the credentials of a user
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// Identity is a user as asserted by an identity provider
+type Identity struct {
+	Provider      string
+	Subject       string // stable identifier of the user at the provider
+	Username      string
+	Email         string
+	EmailVerified bool
+	RealName      string
+	Phone         string
+	Groups        []string
+}
+
+// Provider is an identity provider
+type Provider interface {
+	Name() string
+	Type() string
+	DisplayName() string
+}
+
+// RedirectProvider is a provider the browser is sent to for signing in
+type RedirectProvider interface {
+	Provider
+	// AuthCodeURL returns the URL to send the browser to
+	AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+	// Exchange redeems the code the provider redirected back with
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error)
+}
+
+// PasswordProvider is a provider checking a username and password
+type PasswordProvider interface {
+	Provider
+	Authenticate(ctx context.Context, username, password string) (*Identity, error)
+}
+
+// New creates the provider of a configuration
+func New(cfg *config.SSOProviderConfig) (Provider, error) {
+	switch cfg.Type {
+	case config.SSOProviderOIDC:
+		return NewOIDCProvider(cfg), nil
+	case config.SSOProviderLDAP:
+		return NewLDAPProvider(cfg), nil
+	default:
+		return nil, fmt.Errorf("unsupported identity provider type %q", cfg.Type)
+	}
+}
+
+// Registry holds the configured providers
+type Registry struct {
+	providers []Provider
+}
+
+// NewRegistry creates the providers of a configuration, skipping invalid ones
+func NewRegistry(cfg *config.SSOConfig) *Registry {
+	registry := &Registry{}
+	for _, providerConfig := range cfg.Providers {
+		provider, err := New(providerConfig)
+		if err != nil {
+			logger.Error("failed to create identity provider", "provider", providerConfig.Name, "error", err)
+			continue
+		}
+		registry.Register(provider)
+	}
+	return registry
+}
+
+// Register adds a provider, replacing the one with the same name
+func (r *Registry) Register(provider Provider) {
+	for i, existing := range r.providers {
+		if existing.Name() == provider.Name() {
+			r.providers[i] = provider
+			return
+		}
+	}
+	r.providers = append(r.providers, provider)
+}
+
+// Get returns a provider by name
+func (r *Registry) Get(name string) (Provider, bool) {
+	for _, provider := range r.providers {
+		if provider.Name() == name {
+			return provider, true
+		}
+	}
+	return nil, false
+}
+
+// List returns the providers in configuration order
+func (r *Registry) List() []Provider {
+	return append([]Provider(nil), r.providers...)
+}
+
+// NewCodeVerifier returns a random PKCE code verifier
+func NewCodeVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CodeChallenge returns the S256 PKCE challenge of a code verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -7,9 +7,9 @@ import (
 
 	"cdk-office/internal/auth/domain"
 	"cdk-office/internal/shared/database"
+	"cdk-office/pkg/config"
 	"cdk-office/pkg/jwt"
 	"cdk-office/pkg/logger"
-	jwtLib "github.com/golang-jwt/jwt/v4"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -20,20 +20,34 @@ type AuthServiceInterface interface {
 	Login(ctx context.Context, req *LoginRequest) (*LoginResponse, error)
 	GetUserInfo(ctx context.Context, userID string) (*domain.User, error)
 	Logout(ctx context.Context, token string) error
-	RefreshToken(ctx context.Context, refreshTokenString string) (*LoginResponse, error)
+	RefreshToken(ctx context.Context, refreshTokenString string, client *ClientInfo) (*LoginResponse, error)
+	UnlockUser(ctx context.Context, userID, ip string) error
 }
 
 // AuthService implements the AuthServiceInterface
 type AuthService struct {
 	db        *gorm.DB
 	jwtManager *jwt.JWTManager
+	sessions   SessionServiceInterface
+	mfa        MFAServiceInterface
+	throttle   *LoginThrottle
+	policy     *PasswordPolicy
 }
 
 // NewAuthService creates a new instance of AuthService
-func NewAuthService(jwtManager *jwt.JWTManager) *AuthService {
+func NewAuthService(jwtManager *jwt.JWTManager, cfg *config.Config) *AuthService {
+	return NewAuthServiceWithDB(database.GetDB(), jwtManager, cfg)
+}
+
+// NewAuthServiceWithDB creates a new instance of AuthService with a custom database connection
+func NewAuthServiceWithDB(db *gorm.DB, jwtManager *jwt.JWTManager, cfg *config.Config) *AuthService {
 	return &AuthService{
-		db:        database.GetDB(),
+		db:         db,
 		jwtManager: jwtManager,
+		sessions:   NewSessionServiceWithDB(db, jwtManager),
+		mfa:        NewMFAServiceWithDB(db, jwtManager, &cfg.MFA),
+		throttle:   NewLoginThrottle(&cfg.LoginThrottle),
+		policy:     NewPasswordPolicy(&cfg.PasswordPolicy),
 	}
 }
 
@@ -51,6 +65,7 @@ type RegisterRequest struct {
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	Client   *ClientInfo `json:"-"` // device the session is opened from
 }
 
 // LoginResponse represents the response for user login
@@ -58,6 +73,18 @@ type LoginResponse struct {
 	User         *domain.User `json:"user"`
 	AccessToken  string       `json:"access_token"`
 	RefreshToken string       `json:"refresh_token"`
+	SessionID    string       `json:"session_id,omitempty"`
+	// MFARequired is set instead of the tokens when the user has to present a
+	// second factor, together with the MFAToken to present it with
+	MFARequired      bool   `json:"mfa_required,omitempty"`
+	MFASetupRequired bool   `json:"mfa_setup_required,omitempty"` // the second factor has to be enrolled first
+	MFAToken         string `json:"mfa_token,omitempty"`
+	// RecoveryCodes are issued when the login enrolled the second factor
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+	// WeChatBindRequired is set instead of the tokens when a WeChat account is
+	// not bound to a user yet, together with the WeChatBindToken to bind it with
+	WeChatBindRequired bool   `json:"wechat_bind_required,omitempty"`
+	WeChatBindToken    string `json:"wechat_bind_token,omitempty"`
 }
 
 // Register registers a new user
@@ -68,6 +95,11 @@ func (s *AuthService) Register(ctx context.Context, req *RegisterRequest) error
 		return errors.New("user already exists")
 	}
 
+	// Enforce the password policy
+	if err := s.policy.Validate(req.Password, &domain.User{Username: req.Username, Email: req.Email, Phone: req.Phone}); err != nil {
+		return err
+	}
+
 	// Hash the password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -101,10 +133,30 @@ func (s *AuthService) Register(ctx context.Context, req *RegisterRequest) error
 
 // Login authenticates a user
 func (s *AuthService) Login(ctx context.Context, req *LoginRequest) (*LoginResponse, error) {
+	user, err := authenticate(s.db, s.throttle, req.Username, req.Password, req.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.mfa.BeginLogin(ctx, user, req.Client)
+}
+
+// authenticate checks the username and password of a login, counting failures
+// against the account and the client address and refusing locked out logins
+func authenticate(db *gorm.DB, throttle *LoginThrottle, username, password string, client *ClientInfo) (*domain.User, error) {
+	ip := ""
+	if client != nil {
+		ip = client.IPAddress
+	}
+	if err := throttle.Check(username, ip); err != nil {
+		return nil, err
+	}
+
 	// Find user by username
 	var user domain.User
-	if err := s.db.Where("username = ?", req.Username).First(&user).Error; err != nil {
+	if err := db.Where("username = ?", username).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
+			throttle.RecordFailure(username, ip)
 			return nil, errors.New("invalid username or password")
 		}
 		logger.Error("failed to find user", "error", err)
@@ -112,29 +164,27 @@ func (s *AuthService) Login(ctx context.Context, req *LoginRequest) (*LoginRespo
 	}
 
 	// Check password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		throttle.RecordFailure(username, ip)
 		return nil, errors.New("invalid username or password")
 	}
 
-	// Generate access token
-	accessToken, err := s.jwtManager.GenerateAccessToken(user.ID, user.Username, user.Role)
-	if err != nil {
-		logger.Error("failed to generate access token", "error", err)
-		return nil, errors.New("failed to login")
-	}
+	throttle.Reset(username)
+	return &user, nil
+}
 
-	// Generate refresh token
-	refreshToken, err := s.jwtManager.GenerateRefreshToken(user.ID)
-	if err != nil {
-		logger.Error("failed to generate refresh token", "error", err)
-		return nil, errors.New("failed to login")
+// UnlockUser lifts the login lockout of a user, and of a client address when one is given
+func (s *AuthService) UnlockUser(ctx context.Context, userID, ip string) error {
+	var user domain.User
+	if err := s.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("user not found")
+		}
+		logger.Error("failed to find user", "error", err)
+		return errors.New("failed to unlock user")
 	}
 
-	return &LoginResponse{
-		User:         &user,
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-	}, nil
+	return s.throttle.Unlock(user.Username, ip)
 }
 
 // GetUserInfo retrieves user information by user ID
@@ -157,45 +207,9 @@ func generateID() string {
 	return "user_" + time.Now().Format("20060102150405")
 }
 
-// RefreshToken generates new tokens using a refresh token
-func (s *AuthService) RefreshToken(ctx context.Context, refreshTokenString string) (*LoginResponse, error) {
-	// Verify and refresh the token
-	newAccessToken, newRefreshToken, err := s.jwtManager.RefreshToken(refreshTokenString)
-	if err != nil {
-		logger.Error("failed to refresh token", "error", err)
-		return nil, errors.New("failed to refresh token")
-	}
-
-	// Get user ID from refresh token
-	refreshToken, err := jwtLib.ParseWithClaims(refreshTokenString, &jwtLib.RegisteredClaims{}, func(token *jwtLib.Token) (interface{}, error) {
-		return s.jwtManager.SecretKey, nil
-	})
-
-	if err != nil {
-		logger.Error("failed to parse refresh token", "error", err)
-		return nil, errors.New("failed to refresh token")
-	}
-
-	claims, ok := refreshToken.Claims.(*jwtLib.RegisteredClaims)
-	if !ok || !refreshToken.Valid {
-		return nil, errors.New("invalid refresh token")
-	}
-
-	// Get user information
-	var user domain.User
-	if err := s.db.Where("id = ?", claims.Subject).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("user not found")
-		}
-		logger.Error("failed to find user", "error", err)
-		return nil, errors.New("failed to refresh token")
-	}
-
-	return &LoginResponse{
-		User:         &user,
-		AccessToken:  newAccessToken,
-		RefreshToken: newRefreshToken,
-	}, nil
+// RefreshToken rotates the refresh token of a session and issues new tokens
+func (s *AuthService) RefreshToken(ctx context.Context, refreshTokenString string, client *ClientInfo) (*LoginResponse, error) {
+	return s.sessions.RefreshSession(ctx, refreshTokenString, client)
 }
 
 // Logout invalidates a user's token
@@ -206,6 +220,13 @@ func (s *AuthService) Logout(ctx context.Context, tokenString string) error {
 		return errors.New("invalid token")
 	}
 
+	// End the session so that its refresh token stops working too
+	if claims.SessionID != "" {
+		if err := s.sessions.RevokeSession(ctx, claims.UserID, claims.SessionID, domain.SessionRevokedLogout); err != nil && err.Error() != "session not found" {
+			return errors.New("failed to logout")
+		}
+	}
+
 	// Get token expiration time
 	exp := claims.ExpiresAt.Time
 
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"cdk-office/internal/shared/cache"
+	"cdk-office/pkg/config"
+	"cdk-office/pkg/logger"
+)
+
+// LoginThrottle counts failed logins per account and per client address and
+// locks either out once too many fail. Each lockout within the lockout memory
+// doubles the length of the next one.
+type LoginThrottle struct {
+	store  cache.StoreInterface
+	config *config.LoginThrottleConfig
+}
+
+// NewLoginThrottle creates a new instance of LoginThrottle
+func NewLoginThrottle(cfg *config.LoginThrottleConfig) *LoginThrottle {
+	return NewLoginThrottleWithStore(cache.NewStore(), cfg)
+}
+
+// NewLoginThrottleWithStore creates a new instance of LoginThrottle with a custom store
+func NewLoginThrottleWithStore(store cache.StoreInterface, cfg *config.LoginThrottleConfig) *LoginThrottle {
+	return &LoginThrottle{
+		store:  store,
+		config: cfg,
+	}
+}
+
+// Check returns an error when the account or the address is locked out
+func (t *LoginThrottle) Check(username, ip string) error {
+	if t.locked(accountSubject(username)) {
+		return errors.New("account is temporarily locked, try again later")
+	}
+	if ip != "" && t.locked(ipSubject(ip)) {
+		return errors.New("too many failed login attempts, try again later")
+	}
+	return nil
+}
+
+// RecordFailure counts a failed login on the account and from the address
+func (t *LoginThrottle) RecordFailure(username, ip string) {
+	t.recordFailure(accountSubject(username), t.config.MaxAccountFailures)
+	if ip != "" {
+		t.recordFailure(ipSubject(ip), t.config.MaxIPFailures)
+	}
+}
+
+// Reset clears the failures of an account after a successful login
+func (t *LoginThrottle) Reset(username string) {
+	subject := accountSubject(username)
+	for _, key := range []string{"login:failures:" + subject, "login:lockouts:" + subject} {
+		if err := t.store.Delete(key); err != nil {
+			logger.Error("failed to reset login failures", "error", err, "key", key)
+		}
+	}
+}
+
+// Unlock lifts the lockout of an account, and of an address when one is given
+func (t *LoginThrottle) Unlock(username, ip string) error {
+	subjects := []string{accountSubject(username)}
+	if ip != "" {
+		subjects = append(subjects, ipSubject(ip))
+	}
+	for _, subject := range subjects {
+		for _, key := range []string{"login:lock:" + subject, "login:failures:" + subject, "login:lockouts:" + subject} {
+			if err := t.store.Delete(key); err != nil {
+				logger.Error("failed to unlock login", "error", err, "key", key)
+				return errors.New("failed to unlock user")
+			}
+		}
+	}
+	return nil
+}
+
+// locked reports whether a subject is locked out
+func (t *LoginThrottle) locked(subject string) bool {
+	var until time.Time
+	if err := t.store.Get("login:lock:"+subject, &until); err != nil {
+		return false
+	}
+	return time.Now().Before(until)
+}
+
+// recordFailure counts a failure of a subject and locks it out at the limit
+func (t *LoginThrottle) recordFailure(subject string, limit int) {
+	failures, err := t.store.Increment("login:failures:"+subject, t.config.Window)
+	if err != nil {
+		logger.Error("failed to count login failure", "error", err, "subject", subject)
+		return
+	}
+	if limit <= 0 || failures < int64(limit) {
+		return
+	}
+
+	lockouts, err := t.store.Increment("login:lockouts:"+subject, t.config.LockoutMemory)
+	if err != nil {
+		logger.Error("failed to count lockout", "error", err, "subject", subject)
+		lockouts = 1
+	}
+	duration := t.config.LockoutBase
+	for i := int64(1); i < lockouts && duration < t.config.LockoutMax; i++ {
+		duration *= 2
+	}
+	if duration > t.config.LockoutMax {
+		duration = t.config.LockoutMax
+	}
+
+	if err := t.store.Set("login:lock:"+subject, time.Now().Add(duration), duration); err != nil {
+		logger.Error("failed to lock login", "error", err, "subject", subject)
+		return
+	}
+	if err := t.store.Delete("login:failures:" + subject); err != nil {
+		logger.Error("failed to reset login failures", "error", err, "subject", subject)
+	}
+	logger.Warn("login locked after repeated failures", "subject", subject, "duration", duration.String())
+}
+
+// accountSubject is the throttle subject of an account
+func accountSubject(username string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(username))
+}
+
+// ipSubject is the throttle subject of a client address
+func ipSubject(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,570 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"cdk-office/internal/app/qrrender"
+	"cdk-office/internal/auth/domain"
+	"cdk-office/internal/shared/database"
+	"cdk-office/internal/shared/utils"
+	"cdk-office/pkg/config"
+	"cdk-office/pkg/jwt"
+	"cdk-office/pkg/logger"
+	"cdk-office/pkg/totp"
+	"gorm.io/gorm"
+)
+
+const (
+	// recoveryCodeCount is the number of recovery codes issued at a time
+	recoveryCodeCount = 10
+	// recoveryCodeAlphabet leaves out characters that are easily confused
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+// MFAServiceInterface defines the interface for two-factor authentication service
+type MFAServiceInterface interface {
+	BeginLogin(ctx context.Context, user *domain.User, client *ClientInfo) (*LoginResponse, error)
+	SetupLogin(ctx context.Context, mfaToken string) (*MFAEnrollment, error)
+	CompleteLogin(ctx context.Context, mfaToken, code string, client *ClientInfo) (*LoginResponse, error)
+	GetStatus(ctx context.Context, userID string) (*MFAStatus, error)
+	Enroll(ctx context.Context, userID string) (*MFAEnrollment, error)
+	Enable(ctx context.Context, userID, code string) ([]string, error)
+	Disable(ctx context.Context, userID, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error)
+	SetRolePolicy(ctx context.Context, role string, required bool, updatedBy string) (*domain.MFARolePolicy, error)
+	ListRolePolicies(ctx context.Context) ([]*domain.MFARolePolicy, error)
+}
+
+// MFAService implements the MFAServiceInterface
+type MFAService struct {
+	db         *gorm.DB
+	jwtManager *jwt.JWTManager
+	sessions   SessionServiceInterface
+	config     *config.MFAConfig
+}
+
+// NewMFAService creates a new instance of MFAService
+func NewMFAService(jwtManager *jwt.JWTManager, cfg *config.MFAConfig) *MFAService {
+	return &MFAService{
+		db:         database.GetDB(),
+		jwtManager: jwtManager,
+		sessions:   NewSessionService(jwtManager),
+		config:     cfg,
+	}
+}
+
+// NewMFAServiceWithDB creates a new instance of MFAService with a custom database connection
+func NewMFAServiceWithDB(db *gorm.DB, jwtManager *jwt.JWTManager, cfg *config.MFAConfig) *MFAService {
+	return &MFAService{
+		db:         db,
+		jwtManager: jwtManager,
+		sessions:   NewSessionServiceWithDB(db, jwtManager),
+		config:     cfg,
+	}
+}
+
+// MFAEnrollment is what an authenticator app needs to enrol a TOTP secret
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+	QRCode string `json:"qr_code"` // PNG data URI of the QR code of URI
+}
+
+// MFAStatus describes the second factor of a user
+type MFAStatus struct {
+	Enabled           bool  `json:"enabled"`
+	Pending           bool  `json:"pending"`  // enrolled but not confirmed with a code yet
+	Required          bool  `json:"required"` // a role of the user requires a second factor
+	RecoveryCodesLeft int64 `json:"recovery_codes_left"`
+}
+
+// BeginLogin finishes a login whose password was checked. Users with a second
+// factor, or whose role requires one, get an MFA challenge token instead of tokens.
+func (s *MFAService) BeginLogin(ctx context.Context, user *domain.User, client *ClientInfo) (*LoginResponse, error) {
+	mfa, err := s.findMFA(user.ID)
+	if err != nil {
+		return nil, errors.New("failed to login")
+	}
+	required, err := s.isRequired(user)
+	if err != nil {
+		return nil, errors.New("failed to login")
+	}
+
+	if (mfa == nil || !mfa.Enabled) && !required {
+		return s.sessions.CreateSession(ctx, user, client)
+	}
+
+	token, err := s.jwtManager.GenerateMFAToken(user.ID, s.config.ChallengeTTL)
+	if err != nil {
+		logger.Error("failed to generate mfa token", "error", err)
+		return nil, errors.New("failed to login")
+	}
+	return &LoginResponse{
+		MFARequired:      true,
+		MFASetupRequired: mfa == nil || !mfa.Enabled,
+		MFAToken:         token,
+	}, nil
+}
+
+// SetupLogin enrols a TOTP secret for a user whose role requires a second
+// factor they have not set up yet, during login
+func (s *MFAService) SetupLogin(ctx context.Context, mfaToken string) (*MFAEnrollment, error) {
+	user, err := s.challengeUser(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	return s.Enroll(ctx, user.ID)
+}
+
+// CompleteLogin checks the second factor of a login and issues the tokens. When
+// the login enrolled the factor, the code confirms it and recovery codes are issued.
+func (s *MFAService) CompleteLogin(ctx context.Context, mfaToken, code string, client *ClientInfo) (*LoginResponse, error) {
+	user, err := s.challengeUser(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	mfa, err := s.findMFA(user.ID)
+	if err != nil {
+		return nil, errors.New("failed to login")
+	}
+	if mfa == nil {
+		return nil, errors.New("mfa enrollment not started")
+	}
+
+	if mfa.Enabled {
+		if err := s.checkCode(mfa, code, true); err != nil {
+			return nil, err
+		}
+		return s.sessions.CreateSession(ctx, user, client)
+	}
+
+	recoveryCodes, err := s.Enable(ctx, user.ID, code)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.sessions.CreateSession(ctx, user, client)
+	if err != nil {
+		return nil, err
+	}
+	resp.RecoveryCodes = recoveryCodes
+	return resp, nil
+}
+
+// GetStatus returns the second factor status of a user
+func (s *MFAService) GetStatus(ctx context.Context, userID string) (*MFAStatus, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	mfa, err := s.findMFA(userID)
+	if err != nil {
+		return nil, errors.New("failed to get mfa status")
+	}
+	required, err := s.isRequired(user)
+	if err != nil {
+		return nil, errors.New("failed to get mfa status")
+	}
+
+	status := &MFAStatus{Required: required}
+	if mfa != nil {
+		status.Enabled = mfa.Enabled
+		status.Pending = !mfa.Enabled
+	}
+	if status.Enabled {
+		if err := s.db.Model(&domain.MFARecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&status.RecoveryCodesLeft).Error; err != nil {
+			logger.Error("failed to count recovery codes", "error", err)
+			return nil, errors.New("failed to get mfa status")
+		}
+	}
+	return status, nil
+}
+
+// Enroll generates a new TOTP secret for a user. It replaces any enrolment the
+// user has not confirmed yet and takes effect once Enable confirms it.
+func (s *MFAService) Enroll(ctx context.Context, userID string) (*MFAEnrollment, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	mfa, err := s.findMFA(userID)
+	if err != nil {
+		return nil, errors.New("failed to enroll mfa")
+	}
+	if mfa != nil && mfa.Enabled {
+		return nil, errors.New("mfa is already enabled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		logger.Error("failed to generate totp secret", "error", err)
+		return nil, errors.New("failed to enroll mfa")
+	}
+
+	if mfa == nil {
+		err = s.db.Create(&domain.UserMFA{UserID: userID, Secret: secret}).Error
+	} else {
+		// The lockout is kept so that enrolling again does not reset it
+		err = s.db.Model(&domain.UserMFA{}).Where("user_id = ? AND enabled = ?", userID, false).
+			Updates(map[string]interface{}{"secret": secret, "last_used_step": 0, "updated_at": time.Now()}).Error
+	}
+	if err != nil {
+		logger.Error("failed to save totp secret", "error", err)
+		return nil, errors.New("failed to enroll mfa")
+	}
+
+	uri := totp.URI(s.config.Issuer, user.Username, secret)
+	var qr bytes.Buffer
+	if err := qrrender.Render(&qr, uri, qrrender.DefaultOptions()); err != nil {
+		logger.Error("failed to render mfa qr code", "error", err)
+		return nil, errors.New("failed to enroll mfa")
+	}
+
+	return &MFAEnrollment{
+		Secret: secret,
+		URI:    uri,
+		QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(qr.Bytes()),
+	}, nil
+}
+
+// Enable confirms the enrolled TOTP secret of a user with a code from the
+// authenticator app and returns the user's recovery codes
+func (s *MFAService) Enable(ctx context.Context, userID, code string) ([]string, error) {
+	mfa, err := s.findMFA(userID)
+	if err != nil {
+		return nil, errors.New("failed to enable mfa")
+	}
+	if mfa == nil {
+		return nil, errors.New("mfa enrollment not started")
+	}
+	if mfa.Enabled {
+		return nil, errors.New("mfa is already enabled")
+	}
+	if err := s.checkCode(mfa, code, false); err != nil {
+		return nil, err
+	}
+
+	var recoveryCodes []string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&domain.UserMFA{}).Where("user_id = ? AND enabled = ?", userID, false).
+			Updates(map[string]interface{}{"enabled": true, "enabled_at": now, "updated_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("mfa is already enabled")
+		}
+		recoveryCodes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		if err.Error() == "mfa is already enabled" {
+			return nil, err
+		}
+		logger.Error("failed to enable mfa", "error", err)
+		return nil, errors.New("failed to enable mfa")
+	}
+
+	return recoveryCodes, nil
+}
+
+// Disable removes the second factor of a user after checking a code, unless a
+// role of the user requires one
+func (s *MFAService) Disable(ctx context.Context, userID, code string) error {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return err
+	}
+	mfa, err := s.findEnabledMFA(userID)
+	if err != nil {
+		return err
+	}
+	required, err := s.isRequired(user)
+	if err != nil {
+		return errors.New("failed to disable mfa")
+	}
+	if required {
+		return errors.New("mfa is required for your role")
+	}
+	if err := s.checkCode(mfa, code, true); err != nil {
+		return err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&domain.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&domain.UserMFA{}).Error
+	})
+	if err != nil {
+		logger.Error("failed to disable mfa", "error", err)
+		return errors.New("failed to disable mfa")
+	}
+
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of a user after checking a code
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error) {
+	mfa, err := s.findEnabledMFA(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkCode(mfa, code, true); err != nil {
+		return nil, err
+	}
+
+	var recoveryCodes []string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		recoveryCodes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		logger.Error("failed to regenerate recovery codes", "error", err)
+		return nil, errors.New("failed to regenerate recovery codes")
+	}
+
+	return recoveryCodes, nil
+}
+
+// SetRolePolicy sets whether users holding a role must use a second factor
+func (s *MFAService) SetRolePolicy(ctx context.Context, role string, required bool, updatedBy string) (*domain.MFARolePolicy, error) {
+	role = strings.TrimSpace(role)
+	if role == "" || len(role) > 20 {
+		return nil, errors.New("invalid role")
+	}
+
+	var policy domain.MFARolePolicy
+	err := s.db.Where("role = ?", role).First(&policy).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		policy = domain.MFARolePolicy{
+			Role:      role,
+			Required:  required,
+			UpdatedBy: updatedBy,
+		}
+		err = s.db.Create(&policy).Error
+	case err == nil:
+		policy.Required = required
+		policy.UpdatedBy = updatedBy
+		err = s.db.Save(&policy).Error
+	}
+	if err != nil {
+		logger.Error("failed to save mfa role policy", "error", err)
+		return nil, errors.New("failed to set mfa role policy")
+	}
+
+	return &policy, nil
+}
+
+// ListRolePolicies lists the second factor policies of roles
+func (s *MFAService) ListRolePolicies(ctx context.Context) ([]*domain.MFARolePolicy, error) {
+	var policies []*domain.MFARolePolicy
+	if err := s.db.Order("role").Find(&policies).Error; err != nil {
+		logger.Error("failed to list mfa role policies", "error", err)
+		return nil, errors.New("failed to list mfa role policies")
+	}
+	return policies, nil
+}
+
+// challengeUser returns the user an MFA challenge token was issued to
+func (s *MFAService) challengeUser(mfaToken string) (*domain.User, error) {
+	userID, err := s.jwtManager.VerifyMFAToken(mfaToken)
+	if err != nil {
+		return nil, errors.New("invalid mfa token")
+	}
+	user, err := s.findUser(userID)
+	if err != nil {
+		if err.Error() == "user not found" {
+			return nil, errors.New("invalid mfa token")
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// checkCode accepts a TOTP code, or an unused recovery code when allowRecovery
+// is set. Each TOTP code is accepted once, and too many wrong codes lock the
+// second factor for a while.
+func (s *MFAService) checkCode(mfa *domain.UserMFA, code string, allowRecovery bool) error {
+	now := time.Now()
+	if mfa.LockedUntil != nil && now.Before(*mfa.LockedUntil) {
+		return errors.New("too many failed mfa attempts, try again later")
+	}
+
+	accepted := false
+	if step, ok := totp.Validate(mfa.Secret, code, now); ok {
+		// Claim the time step so that the same code cannot be replayed
+		result := s.db.Model(&domain.UserMFA{}).Where("user_id = ? AND last_used_step < ?", mfa.UserID, step).
+			Update("last_used_step", step)
+		if result.Error != nil {
+			logger.Error("failed to record totp step", "error", result.Error)
+			return errors.New("failed to verify mfa code")
+		}
+		accepted = result.RowsAffected > 0
+	} else if allowRecovery {
+		result := s.db.Model(&domain.MFARecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", mfa.UserID, hashRecoveryCode(code)).
+			Update("used_at", now)
+		if result.Error != nil {
+			logger.Error("failed to use recovery code", "error", result.Error)
+			return errors.New("failed to verify mfa code")
+		}
+		accepted = result.RowsAffected > 0
+	}
+
+	if accepted {
+		if mfa.FailedAttempts > 0 {
+			if err := s.db.Model(&domain.UserMFA{}).Where("user_id = ?", mfa.UserID).
+				Updates(map[string]interface{}{"failed_attempts": 0, "locked_until": nil}).Error; err != nil {
+				logger.Error("failed to reset mfa attempts", "error", err)
+			}
+		}
+		return nil
+	}
+
+	s.recordFailure(mfa.UserID, now)
+	return errors.New("invalid mfa code")
+}
+
+// recordFailure counts a wrong code and locks the second factor once the
+// configured number of attempts is reached
+func (s *MFAService) recordFailure(userID string, now time.Time) {
+	if err := s.db.Model(&domain.UserMFA{}).Where("user_id = ?", userID).
+		Update("failed_attempts", gorm.Expr("failed_attempts + 1")).Error; err != nil {
+		logger.Error("failed to record mfa attempt", "error", err)
+		return
+	}
+
+	var mfa domain.UserMFA
+	if err := s.db.Select("failed_attempts").Where("user_id = ?", userID).First(&mfa).Error; err != nil {
+		logger.Error("failed to find mfa", "error", err)
+		return
+	}
+	if mfa.FailedAttempts < s.config.MaxAttempts {
+		return
+	}
+	if err := s.db.Model(&domain.UserMFA{}).Where("user_id = ?", userID).
+		Updates(map[string]interface{}{"failed_attempts": 0, "locked_until": now.Add(s.config.LockoutDuration)}).Error; err != nil {
+		logger.Error("failed to lock mfa", "error", err)
+	}
+}
+
+// isRequired reports whether a role of the user requires a second factor
+func (s *MFAService) isRequired(user *domain.User) (bool, error) {
+	roles := []string{user.Role}
+	var extra []string
+	if err := s.db.Model(&domain.UserRole{}).Where("user_id = ?", user.ID).Pluck("role", &extra).Error; err != nil {
+		logger.Error("failed to find user roles", "error", err)
+		return false, err
+	}
+	roles = append(roles, extra...)
+
+	var count int64
+	if err := s.db.Model(&domain.MFARolePolicy{}).Where("role IN ? AND required = ?", roles, true).Count(&count).Error; err != nil {
+		logger.Error("failed to find mfa role policies", "error", err)
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// findUser finds a user by ID
+func (s *MFAService) findUser(userID string) (*domain.User, error) {
+	var user domain.User
+	if err := s.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("user not found")
+		}
+		logger.Error("failed to find user", "error", err)
+		return nil, errors.New("failed to find user")
+	}
+	return &user, nil
+}
+
+// findMFA returns the second factor of a user, or nil when there is none
+func (s *MFAService) findMFA(userID string) (*domain.UserMFA, error) {
+	var mfa domain.UserMFA
+	if err := s.db.Where("user_id = ?", userID).First(&mfa).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		logger.Error("failed to find mfa", "error", err)
+		return nil, err
+	}
+	return &mfa, nil
+}
+
+// findEnabledMFA returns the confirmed second factor of a user
+func (s *MFAService) findEnabledMFA(userID string) (*domain.UserMFA, error) {
+	mfa, err := s.findMFA(userID)
+	if err != nil {
+		return nil, errors.New("failed to find mfa")
+	}
+	if mfa == nil || !mfa.Enabled {
+		return nil, errors.New("mfa is not enabled")
+	}
+	return mfa, nil
+}
+
+// replaceRecoveryCodes issues a new set of recovery codes for a user, revoking the previous ones
+func replaceRecoveryCodes(tx *gorm.DB, userID string) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&domain.MFARecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	records := make([]domain.MFARecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		records[i] = domain.MFARecoveryCode{
+			ID:       utils.GenerateMFARecoveryCodeID(),
+			UserID:   userID,
+			CodeHash: hashRecoveryCode(code),
+		}
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// generateRecoveryCode returns a random code formatted as two groups of five characters
+func generateRecoveryCode() (string, error) {
+	code := make([]byte, 0, 11)
+	buf := make([]byte, 1)
+	// Bytes past the last multiple of the alphabet size are skipped to keep the draw uniform
+	limit := byte(256 / len(recoveryCodeAlphabet) * len(recoveryCodeAlphabet))
+	for len(code) < 11 {
+		if len(code) == 5 {
+			code = append(code, '-')
+			continue
+		}
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		if buf[0] >= limit {
+			continue
+		}
+		code = append(code, recoveryCodeAlphabet[int(buf[0])%len(recoveryCodeAlphabet)])
+	}
+	return string(code), nil
+}
+
+// hashRecoveryCode hashes a recovery code ignoring case, spaces and dashes
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
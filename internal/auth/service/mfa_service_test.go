@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"cdk-office/internal/auth/domain"
+	"cdk-office/internal/shared/testutils"
+	"cdk-office/pkg/config"
+	"cdk-office/pkg/jwt"
+	"cdk-office/pkg/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+func newTestJWTManager() *jwt.JWTManager {
+	return jwt.NewJWTManager(&jwt.JWTConfig{
+		SecretKey:       "test-secret-key",
+		AccessTokenExp:  time.Hour,
+		RefreshTokenExp: time.Hour * 24,
+	})
+}
+
+func createTestUser(t *testing.T, db *gorm.DB, id, role string) *domain.User {
+	hashed, err := bcrypt.GenerateFromPassword([]byte("secret123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := &domain.User{
+		ID:       id,
+		Username: id,
+		Email:    id + "@example.com",
+		Password: string(hashed),
+		Role:     role,
+		Status:   "active",
+	}
+	require.NoError(t, db.Create(user).Error)
+	return user
+}
+
+// codeAt returns the TOTP code of secret for a time step
+func codeAt(t *testing.T, secret string, step int64) string {
+	code, err := totp.Code(secret, step)
+	require.NoError(t, err)
+	return code
+}
+
+func TestMFALogin(t *testing.T) {
+	db := testutils.SetupTestDB()
+	jwtManager := newTestJWTManager()
+	authService := NewAuthServiceWithDB(db, jwtManager, config.Default())
+	mfaService := NewMFAServiceWithDB(db, jwtManager, &config.Default().MFA)
+	ctx := context.Background()
+	createTestUser(t, db, "alice", "user")
+	login := &LoginRequest{Username: "alice", Password: "secret123"}
+
+	resp, err := authService.Login(ctx, login)
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.AccessToken)
+	assert.False(t, resp.MFARequired)
+
+	enrollment, err := mfaService.Enroll(ctx, "alice")
+	require.NoError(t, err)
+	step := totp.Step(time.Now())
+	assert.True(t, strings.HasPrefix(enrollment.URI, "otpauth://totp/"))
+	assert.Contains(t, enrollment.URI, "secret="+enrollment.Secret)
+	assert.True(t, strings.HasPrefix(enrollment.QRCode, "data:image/png;base64,"))
+
+	// An unconfirmed enrolment does not affect logins
+	resp, err = authService.Login(ctx, login)
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.AccessToken)
+
+	_, err = mfaService.Enable(ctx, "alice", "000000")
+	assert.EqualError(t, err, "invalid mfa code")
+	recoveryCodes, err := mfaService.Enable(ctx, "alice", codeAt(t, enrollment.Secret, step))
+	require.NoError(t, err)
+	assert.Len(t, recoveryCodes, recoveryCodeCount)
+	_, err = mfaService.Enroll(ctx, "alice")
+	assert.EqualError(t, err, "mfa is already enabled")
+
+	// The password alone now only yields a challenge token
+	resp, err = authService.Login(ctx, login)
+	require.NoError(t, err)
+	assert.True(t, resp.MFARequired)
+	assert.False(t, resp.MFASetupRequired)
+	assert.Empty(t, resp.AccessToken)
+	assert.Nil(t, resp.User)
+	_, err = jwtManager.VerifyToken(resp.MFAToken)
+	assert.Error(t, err, "a challenge token must not pass as an access token")
+
+	_, err = mfaService.CompleteLogin(ctx, "not-a-token", codeAt(t, enrollment.Secret, step+1), nil)
+	assert.EqualError(t, err, "invalid mfa token")
+	// The code that enabled the factor cannot be replayed
+	_, err = mfaService.CompleteLogin(ctx, resp.MFAToken, codeAt(t, enrollment.Secret, step), nil)
+	assert.EqualError(t, err, "invalid mfa code")
+
+	completed, err := mfaService.CompleteLogin(ctx, resp.MFAToken, codeAt(t, enrollment.Secret, step+1), nil)
+	require.NoError(t, err)
+	assert.NotEmpty(t, completed.AccessToken)
+	assert.Equal(t, "alice", completed.User.ID)
+
+	// Recovery codes work once, whatever their case
+	completed, err = mfaService.CompleteLogin(ctx, resp.MFAToken, strings.ToUpper(recoveryCodes[0]), nil)
+	require.NoError(t, err)
+	assert.NotEmpty(t, completed.AccessToken)
+	_, err = mfaService.CompleteLogin(ctx, resp.MFAToken, recoveryCodes[0], nil)
+	assert.EqualError(t, err, "invalid mfa code")
+
+	status, err := mfaService.GetStatus(ctx, "alice")
+	require.NoError(t, err)
+	assert.True(t, status.Enabled)
+	assert.EqualValues(t, recoveryCodeCount-1, status.RecoveryCodesLeft)
+
+	// A recovery code also confirms disabling the factor
+	require.NoError(t, mfaService.Disable(ctx, "alice", recoveryCodes[1]))
+	resp, err = authService.Login(ctx, login)
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.AccessToken)
+}
+
+func TestMFARolePolicy(t *testing.T) {
+	db := testutils.SetupTestDB()
+	jwtManager := newTestJWTManager()
+	authService := NewAuthServiceWithDB(db, jwtManager, config.Default())
+	mfaService := NewMFAServiceWithDB(db, jwtManager, &config.Default().MFA)
+	ctx := context.Background()
+	createTestUser(t, db, "admin1", "admin")
+	createTestUser(t, db, "hr1", "user")
+	require.NoError(t, db.Create(&domain.UserRole{ID: "ur1", UserID: "hr1", Role: "hr"}).Error)
+
+	_, err := mfaService.SetRolePolicy(ctx, " ", true, "admin1")
+	assert.EqualError(t, err, "invalid role")
+	_, err = mfaService.SetRolePolicy(ctx, "admin", true, "admin1")
+	require.NoError(t, err)
+	_, err = mfaService.SetRolePolicy(ctx, "hr", false, "admin1")
+	require.NoError(t, err)
+
+	resp, err := authService.Login(ctx, &LoginRequest{Username: "hr1", Password: "secret123"})
+	require.NoError(t, err)
+	assert.False(t, resp.MFARequired)
+
+	// Roles granted through user roles count too
+	policy, err := mfaService.SetRolePolicy(ctx, "hr", true, "admin1")
+	require.NoError(t, err)
+	assert.True(t, policy.Required)
+	resp, err = authService.Login(ctx, &LoginRequest{Username: "hr1", Password: "secret123"})
+	require.NoError(t, err)
+	assert.True(t, resp.MFASetupRequired)
+
+	// An enforced role enrols during login
+	resp, err = authService.Login(ctx, &LoginRequest{Username: "admin1", Password: "secret123"})
+	require.NoError(t, err)
+	assert.True(t, resp.MFARequired)
+	assert.True(t, resp.MFASetupRequired)
+	assert.Empty(t, resp.AccessToken)
+
+	_, err = mfaService.CompleteLogin(ctx, resp.MFAToken, "123456", nil)
+	assert.EqualError(t, err, "mfa enrollment not started")
+	enrollment, err := mfaService.SetupLogin(ctx, resp.MFAToken)
+	require.NoError(t, err)
+	step := totp.Step(time.Now())
+	completed, err := mfaService.CompleteLogin(ctx, resp.MFAToken, codeAt(t, enrollment.Secret, step), nil)
+	require.NoError(t, err)
+	assert.NotEmpty(t, completed.AccessToken)
+	assert.Len(t, completed.RecoveryCodes, recoveryCodeCount)
+
+	_, err = mfaService.SetupLogin(ctx, resp.MFAToken)
+	assert.EqualError(t, err, "mfa is already enabled")
+	err = mfaService.Disable(ctx, "admin1", codeAt(t, enrollment.Secret, step+1))
+	assert.EqualError(t, err, "mfa is required for your role")
+
+	policies, err := mfaService.ListRolePolicies(ctx)
+	require.NoError(t, err)
+	require.Len(t, policies, 2)
+	assert.Equal(t, "admin", policies[0].Role)
+}
+
+func TestMFALockout(t *testing.T) {
+	db := testutils.SetupTestDB()
+	mfaService := NewMFAServiceWithDB(db, newTestJWTManager(), &config.Default().MFA)
+	mfaService.config.MaxAttempts = 2
+	ctx := context.Background()
+	createTestUser(t, db, "bob", "user")
+
+	enrollment, err := mfaService.Enroll(ctx, "bob")
+	require.NoError(t, err)
+	step := totp.Step(time.Now())
+	_, err = mfaService.Enable(ctx, "bob", codeAt(t, enrollment.Secret, step))
+	require.NoError(t, err)
+
+	_, err = mfaService.RegenerateRecoveryCodes(ctx, "bob", "000000")
+	assert.EqualError(t, err, "invalid mfa code")
+	_, err = mfaService.RegenerateRecoveryCodes(ctx, "bob", "000000")
+	assert.EqualError(t, err, "invalid mfa code")
+
+	// Even a valid code is refused while the factor is locked
+	_, err = mfaService.RegenerateRecoveryCodes(ctx, "bob", codeAt(t, enrollment.Secret, step+1))
+	assert.EqualError(t, err, "too many failed mfa attempts, try again later")
+
+	require.NoError(t, db.Model(&domain.UserMFA{}).Where("user_id = ?", "bob").Update("locked_until", time.Now().Add(-time.Minute)).Error)
+	recoveryCodes, err := mfaService.RegenerateRecoveryCodes(ctx, "bob", codeAt(t, enrollment.Secret, step+1))
+	require.NoError(t, err)
+	assert.Len(t, recoveryCodes, recoveryCodeCount)
+}
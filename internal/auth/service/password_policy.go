@@ -0,0 +1,205 @@
+package service
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"cdk-office/internal/auth/domain"
+	"cdk-office/internal/shared/utils"
+	"cdk-office/pkg/config"
+	"cdk-office/pkg/logger"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// PasswordPolicy checks new passwords against the configured rules
+type PasswordPolicy struct {
+	config *config.PasswordPolicyConfig
+}
+
+// NewPasswordPolicy creates a new instance of PasswordPolicy
+func NewPasswordPolicy(cfg *config.PasswordPolicyConfig) *PasswordPolicy {
+	return &PasswordPolicy{
+		config: cfg,
+	}
+}
+
+// Validate checks the length, character classes and breach list rules for a
+// password of user
+func (p *PasswordPolicy) Validate(password string, user *domain.User) error {
+	if utf8.RuneCountInString(password) < p.config.MinLength {
+		return fmt.Errorf("invalid password: must be at least %d characters", p.config.MinLength)
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			classes++
+		}
+	}
+	if classes < p.config.MinCharClasses {
+		return fmt.Errorf("invalid password: must contain %d of lower case letters, upper case letters, digits and symbols", p.config.MinCharClasses)
+	}
+
+	if user != nil {
+		for _, identity := range []string{user.Username, user.Email, user.Phone} {
+			if identity != "" && strings.EqualFold(password, identity) {
+				return errors.New("invalid password: must not match the username, email or phone")
+			}
+		}
+	}
+
+	if p.config.BreachListPath != "" {
+		list, err := loadBreachList(p.config.BreachListPath)
+		if err != nil {
+			logger.Error("failed to load password breach list", "error", err, "path", p.config.BreachListPath)
+			return errors.New("failed to check password breach list")
+		}
+		if _, breached := list[strings.ToLower(password)]; breached {
+			return errors.New("invalid password: appears in a list of breached passwords")
+		}
+	}
+	return nil
+}
+
+// SetPassword validates a new password of a user and stores it, keeping the
+// previous one in the password history
+func (p *PasswordPolicy) SetPassword(tx *gorm.DB, user *domain.User, password string) error {
+	if err := p.Validate(password, user); err != nil {
+		return err
+	}
+	if err := p.checkReuse(tx, user, password); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		logger.Error("failed to hash password", "error", err)
+		return errors.New("failed to set password")
+	}
+
+	if err := p.storePassword(tx, user, string(hashedPassword)); err != nil {
+		logger.Error("failed to store password", "error", err)
+		return errors.New("failed to set password")
+	}
+	return nil
+}
+
+// checkReuse refuses a password matching one of the recent passwords of a user
+func (p *PasswordPolicy) checkReuse(tx *gorm.DB, user *domain.User, password string) error {
+	if p.config.HistorySize <= 0 {
+		return nil
+	}
+
+	hashes := []string{user.Password}
+	if p.config.HistorySize > 1 {
+		var previous []string
+		if err := tx.Model(&domain.PasswordHistory{}).Where("user_id = ?", user.ID).
+			Order("created_at DESC").Limit(p.config.HistorySize-1).Pluck("password_hash", &previous).Error; err != nil {
+			logger.Error("failed to find password history", "error", err)
+			return errors.New("failed to set password")
+		}
+		hashes = append(hashes, previous...)
+	}
+
+	for _, hash := range hashes {
+		if hash != "" && bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return fmt.Errorf("invalid password: must not match one of the last %d passwords", p.config.HistorySize)
+		}
+	}
+	return nil
+}
+
+// storePassword saves the hashed password of a user and moves the previous one
+// to the history, pruning entries past the history size
+func (p *PasswordPolicy) storePassword(tx *gorm.DB, user *domain.User, hashedPassword string) error {
+	now := time.Now()
+	if user.Password != "" && p.config.HistorySize > 1 {
+		if err := tx.Create(&domain.PasswordHistory{
+			ID:           utils.GeneratePasswordHistoryID(),
+			UserID:       user.ID,
+			PasswordHash: user.Password,
+			CreatedAt:    now,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Model(&domain.User{}).Where("id = ?", user.ID).
+		Updates(map[string]interface{}{"password": hashedPassword, "updated_at": now}).Error; err != nil {
+		return err
+	}
+	user.Password = hashedPassword
+	user.UpdatedAt = now
+
+	keep := p.config.HistorySize - 1
+	if keep < 0 {
+		keep = 0
+	}
+	var stale []string
+	if err := tx.Model(&domain.PasswordHistory{}).Where("user_id = ?", user.ID).
+		Order("created_at DESC").Offset(keep).Limit(-1).Pluck("id", &stale).Error; err != nil {
+		return err
+	}
+	if len(stale) > 0 {
+		return tx.Where("id IN ?", stale).Delete(&domain.PasswordHistory{}).Error
+	}
+	return nil
+}
+
+// breachLists caches the breach lists read, by path
+var breachLists = struct {
+	sync.Mutex
+	lists map[string]map[string]struct{}
+}{lists: make(map[string]map[string]struct{})}
+
+// loadBreachList returns the lower cased passwords of a breach list file, read
+// once. A file that cannot be read is not cached, so that passwords are refused
+// until it can.
+func loadBreachList(path string) (map[string]struct{}, error) {
+	breachLists.Lock()
+	defer breachLists.Unlock()
+
+	if list, ok := breachLists.lists[path]; ok {
+		return list, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	list := make(map[string]struct{})
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			list[strings.ToLower(line)] = struct{}{}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	breachLists.lists[path] = list
+	return list, nil
+}
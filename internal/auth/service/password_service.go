@@ -2,11 +2,19 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"net/url"
 	"time"
 
 	"cdk-office/internal/auth/domain"
+	"cdk-office/internal/shared/cache"
 	"cdk-office/internal/shared/database"
+	"cdk-office/internal/shared/notify"
+	"cdk-office/internal/shared/utils"
+	"cdk-office/pkg/config"
 	"cdk-office/pkg/jwt"
 	"cdk-office/pkg/logger"
 	"golang.org/x/crypto/bcrypt"
@@ -17,17 +25,38 @@ import (
 type PasswordServiceInterface interface {
 	PasswordLogin(ctx context.Context, req *PasswordLoginRequest) (*LoginResponse, error)
 	ChangePassword(ctx context.Context, userID, oldPassword, newPassword string) error
+	RequestPasswordReset(ctx context.Context, req *PasswordResetRequest) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
 }
 
 // PasswordService implements the PasswordServiceInterface
 type PasswordService struct {
-	db *gorm.DB
+	db          *gorm.DB
+	mfa         MFAServiceInterface
+	sessions    SessionServiceInterface
+	throttle    *LoginThrottle
+	policy      *PasswordPolicy
+	store       cache.StoreInterface
+	sender      notify.Sender
+	resetConfig *config.PasswordResetConfig
 }
 
 // NewPasswordService creates a new instance of PasswordService
-func NewPasswordService() *PasswordService {
+func NewPasswordService(jwtManager *jwt.JWTManager, cfg *config.Config) *PasswordService {
+	return NewPasswordServiceWithDB(database.GetDB(), jwtManager, cfg)
+}
+
+// NewPasswordServiceWithDB creates a new instance of PasswordService with a custom database connection
+func NewPasswordServiceWithDB(db *gorm.DB, jwtManager *jwt.JWTManager, cfg *config.Config) *PasswordService {
 	return &PasswordService{
-		db: database.GetDB(),
+		db:          db,
+		mfa:         NewMFAServiceWithDB(db, jwtManager, &cfg.MFA),
+		sessions:    NewSessionServiceWithDB(db, jwtManager),
+		throttle:    NewLoginThrottle(&cfg.LoginThrottle),
+		policy:      NewPasswordPolicy(&cfg.PasswordPolicy),
+		store:       cache.NewStore(),
+		sender:      notify.NewDefaultSender(&cfg.Notify),
+		resetConfig: &cfg.PasswordReset,
 	}
 }
 
@@ -35,50 +64,17 @@ func NewPasswordService() *PasswordService {
 type PasswordLoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	Client   *ClientInfo `json:"-"` // device the session is opened from
 }
 
 // PasswordLogin authenticates a user via username and password
 func (s *PasswordService) PasswordLogin(ctx context.Context, req *PasswordLoginRequest) (*LoginResponse, error) {
-	// Find user by username
-	var user domain.User
-	if err := s.db.Where("username = ?", req.Username).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("invalid username or password")
-		}
-		logger.Error("failed to find user", "error", err)
-		return nil, errors.New("failed to login")
-	}
-
-	// Check password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return nil, errors.New("invalid username or password")
-	}
-
-	// Generate access token
-	jwtManager := jwt.NewJWTManager(&jwt.JWTConfig{
-		SecretKey:       "cdk-office-secret-key",
-		AccessTokenExp:  time.Hour * 2,
-		RefreshTokenExp: time.Hour * 24 * 7,
-	})
-	
-	accessToken, err := jwtManager.GenerateAccessToken(user.ID, user.Username, user.Role)
+	user, err := authenticate(s.db, s.throttle, req.Username, req.Password, req.Client)
 	if err != nil {
-		logger.Error("failed to generate access token", "error", err)
-		return nil, errors.New("failed to login")
+		return nil, err
 	}
 
-	// Generate refresh token
-	refreshToken, err := jwtManager.GenerateRefreshToken(user.ID)
-	if err != nil {
-		logger.Error("failed to generate refresh token", "error", err)
-		return nil, errors.New("failed to login")
-	}
-
-	return &LoginResponse{
-		User:         &user,
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-	}, nil
+	return s.mfa.BeginLogin(ctx, user, req.Client)
 }
 
 // ChangePassword changes a user's password
@@ -98,21 +94,180 @@ func (s *PasswordService) ChangePassword(ctx context.Context, userID, oldPasswor
 		return errors.New("invalid old password")
 	}
 
-	// Hash new password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	// Store the new password if the policy allows it
+	if err := s.policy.SetPassword(s.db, &user, newPassword); err != nil {
+		if err.Error() == "failed to set password" {
+			return errors.New("failed to change password")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// PasswordResetRequest represents the request for a password reset
+type PasswordResetRequest struct {
+	Login     string `json:"login" binding:"required"` // username, email or phone
+	Channel   string `json:"channel"`                  // email (default) or sms
+	IPAddress string `json:"-"`
+}
+
+// RequestPasswordReset sends a user a single-use link to choose a new password.
+// Unknown logins succeed silently so that accounts cannot be probed.
+func (s *PasswordService) RequestPasswordReset(ctx context.Context, req *PasswordResetRequest) error {
+	channel := req.Channel
+	if channel == "" {
+		channel = notify.ChannelEmail
+	}
+	if channel != notify.ChannelEmail && channel != notify.ChannelSMS {
+		return errors.New("invalid channel")
+	}
+
+	var user domain.User
+	if err := s.db.Where("username = ? OR email = ? OR phone = ?", req.Login, req.Login, req.Login).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		logger.Error("failed to find user", "error", err)
+		return errors.New("failed to request password reset")
+	}
+
+	recipient := user.Email
+	if channel == notify.ChannelSMS {
+		recipient = user.Phone
+	}
+	if recipient == "" || user.Status != "active" {
+		return nil
+	}
+
+	requests, err := s.store.Increment("password_reset:"+user.ID, time.Hour)
 	if err != nil {
-		logger.Error("failed to hash new password", "error", err)
-		return errors.New("failed to change password")
+		logger.Error("failed to count password reset requests", "error", err)
+		return errors.New("failed to request password reset")
+	}
+	if s.resetConfig.MaxRequests > 0 && requests > int64(s.resetConfig.MaxRequests) {
+		return errors.New("too many password reset requests, try again later")
 	}
 
-	// Update password
-	user.Password = string(hashedPassword)
-	user.UpdatedAt = time.Now()
+	token := utils.GenerateShortCode(40)
+	resetToken := &domain.PasswordResetToken{
+		ID:          utils.GeneratePasswordResetTokenID(),
+		UserID:      user.ID,
+		TokenHash:   hashResetToken(token),
+		Channel:     channel,
+		RequestedIP: req.IPAddress,
+		ExpiresAt:   time.Now().Add(s.resetConfig.TokenTTL),
+		CreatedAt:   time.Now(),
+	}
+	if err := s.db.Create(resetToken).Error; err != nil {
+		logger.Error("failed to create password reset token", "error", err)
+		return errors.New("failed to request password reset")
+	}
 
-	if err := s.db.Save(&user).Error; err != nil {
-		logger.Error("failed to update user password", "error", err)
-		return errors.New("failed to change password")
+	link, err := s.resetLink(token)
+	if err != nil {
+		logger.Error("failed to build password reset link", "error", err)
+		return errors.New("failed to request password reset")
+	}
+	minutes := int(s.resetConfig.TokenTTL.Minutes())
+	msg := &notify.Message{
+		Channel: channel,
+		To:      recipient,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("We received a request to reset your password.\n\nOpen this link to choose a new one: %s\n\n"+
+			"The link works once and expires in %d minutes. If you did not ask for a reset, ignore this message.", link, minutes),
+	}
+	if channel == notify.ChannelSMS {
+		msg.Body = fmt.Sprintf("Reset your password: %s (expires in %d minutes)", link, minutes)
+	}
+	if err := s.sender.Send(ctx, msg); err != nil {
+		logger.Error("failed to send password reset message", "error", err, "channel", channel)
+		return errors.New("failed to send password reset message")
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password with a reset token, then ends every session
+// of the user and lifts any login lockout
+func (s *PasswordService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	var resetToken domain.PasswordResetToken
+	if err := s.db.Where("token_hash = ?", hashResetToken(token)).First(&resetToken).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("invalid or expired reset token")
+		}
+		logger.Error("failed to find password reset token", "error", err)
+		return errors.New("failed to reset password")
+	}
+	if resetToken.UsedAt != nil || time.Now().After(resetToken.ExpiresAt) {
+		return errors.New("invalid or expired reset token")
+	}
+
+	var user domain.User
+	if err := s.db.Where("id = ?", resetToken.UserID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("invalid or expired reset token")
+		}
+		logger.Error("failed to find user", "error", err)
+		return errors.New("failed to reset password")
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Claim the token so that concurrent resets cannot both use it
+		now := time.Now()
+		result := tx.Model(&domain.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", resetToken.ID).Update("used_at", now)
+		if result.Error != nil {
+			logger.Error("failed to use password reset token", "error", result.Error)
+			return errors.New("failed to reset password")
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("invalid or expired reset token")
+		}
+
+		if err := s.policy.SetPassword(tx, &user, newPassword); err != nil {
+			if err.Error() == "failed to set password" {
+				return errors.New("failed to reset password")
+			}
+			return err
+		}
+
+		// Other outstanding tokens of the user stop working with the new password
+		if err := tx.Model(&domain.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).Update("used_at", now).Error; err != nil {
+			logger.Error("failed to invalidate password reset tokens", "error", err)
+			return errors.New("failed to reset password")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.sessions.RevokeUserSessions(ctx, user.ID, domain.SessionRevokedPasswordReset); err != nil {
+		logger.Error("failed to revoke sessions after password reset", "error", err)
+	}
+	if err := s.throttle.Unlock(user.Username, ""); err != nil {
+		logger.Error("failed to unlock user after password reset", "error", err)
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// resetLink is the web app link carrying a password reset token
+func (s *PasswordService) resetLink(token string) (string, error) {
+	link, err := url.Parse(s.resetConfig.ResetURL)
+	if err != nil {
+		return "", err
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
+
+// hashResetToken returns the hex SHA-256 of a password reset token
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
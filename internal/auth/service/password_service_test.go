@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"cdk-office/internal/auth/domain"
+	"cdk-office/internal/shared/cache"
+	"cdk-office/internal/shared/notify"
+	"cdk-office/internal/shared/testutils"
+	"cdk-office/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestThrottle(store cache.StoreInterface) *LoginThrottle {
+	return &LoginThrottle{
+		store: store,
+		config: &config.LoginThrottleConfig{
+			Window:             time.Minute,
+			MaxAccountFailures: 3,
+			MaxIPFailures:      10,
+			LockoutBase:        time.Minute,
+			LockoutMax:         3 * time.Minute,
+			LockoutMemory:      time.Hour,
+		},
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	store := cache.NewMemoryCache()
+	throttle := newTestThrottle(store)
+
+	for i := 0; i < 2; i++ {
+		throttle.RecordFailure("Alice", "10.0.0.1")
+	}
+	assert.NoError(t, throttle.Check("alice", "10.0.0.1"))
+	throttle.RecordFailure("alice", "10.0.0.1")
+	assert.EqualError(t, throttle.Check("ALICE", ""), "account is temporarily locked, try again later")
+
+	// Each further lockout doubles, up to the maximum
+	var until time.Time
+	require.NoError(t, store.Get("login:lock:account:alice", &until))
+	assert.WithinDuration(t, time.Now().Add(time.Minute), until, 5*time.Second)
+	for i := 0; i < 3; i++ {
+		throttle.RecordFailure("alice", "")
+	}
+	require.NoError(t, store.Get("login:lock:account:alice", &until))
+	assert.WithinDuration(t, time.Now().Add(2*time.Minute), until, 5*time.Second)
+	for round := 0; round < 2; round++ {
+		for i := 0; i < 3; i++ {
+			throttle.RecordFailure("alice", "")
+		}
+	}
+	require.NoError(t, store.Get("login:lock:account:alice", &until))
+	assert.WithinDuration(t, time.Now().Add(3*time.Minute), until, 5*time.Second)
+
+	require.NoError(t, throttle.Unlock("alice", ""))
+	assert.NoError(t, throttle.Check("alice", ""))
+
+	// Failures spread over accounts still lock the address
+	for i := 0; i < 10; i++ {
+		throttle.RecordFailure("user"+string(rune('a'+i)), "10.0.0.2")
+	}
+	assert.EqualError(t, throttle.Check("bob", "10.0.0.2"), "too many failed login attempts, try again later")
+	assert.NoError(t, throttle.Check("bob", "10.0.0.3"))
+}
+
+func TestLoginLockout(t *testing.T) {
+	db := testutils.SetupTestDB()
+	authService := NewAuthServiceWithDB(db, newTestJWTManager(), config.Default())
+	authService.throttle = newTestThrottle(cache.NewMemoryCache())
+	ctx := context.Background()
+	createTestUser(t, db, "alice", "user")
+
+	for i := 0; i < 3; i++ {
+		_, err := authService.Login(ctx, &LoginRequest{Username: "alice", Password: "wrong"})
+		assert.EqualError(t, err, "invalid username or password")
+	}
+	_, err := authService.Login(ctx, &LoginRequest{Username: "alice", Password: "secret123"})
+	assert.EqualError(t, err, "account is temporarily locked, try again later")
+
+	require.NoError(t, authService.UnlockUser(ctx, "alice", ""))
+	resp, err := authService.Login(ctx, &LoginRequest{Username: "alice", Password: "secret123"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.AccessToken)
+	assert.EqualError(t, authService.UnlockUser(ctx, "nobody", ""), "user not found")
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	breachList := filepath.Join(t.TempDir(), "breached.txt")
+	require.NoError(t, os.WriteFile(breachList, []byte("Password123!\nletmein\n"), 0o600))
+	policy := NewPasswordPolicy(&config.PasswordPolicyConfig{
+		MinLength:      10,
+		MinCharClasses: 3,
+		BreachListPath: breachList,
+		HistorySize:    3,
+	})
+	user := &domain.User{Username: "alice.smith", Email: "Alice.Smith@example.com"}
+
+	tests := []struct {
+		password string
+		err      string
+	}{
+		{"Sh0rt!", "invalid password: must be at least 10 characters"},
+		{"alllowercaseletters", "invalid password: must contain 3 of lower case letters, upper case letters, digits and symbols"},
+		{"alice.smith@EXAMPLE.com", "invalid password: must not match the username, email or phone"},
+		{"password123!", "invalid password: appears in a list of breached passwords"},
+		{"Correct-Horse-7", ""},
+	}
+	for _, tt := range tests {
+		err := policy.Validate(tt.password, user)
+		if tt.err == "" {
+			assert.NoError(t, err, tt.password)
+		} else {
+			assert.EqualError(t, err, tt.err, tt.password)
+		}
+	}
+
+	// The current password and the previous ones up to the history size cannot be reused
+	db := testutils.SetupTestDB()
+	stored := createTestUser(t, db, "alice", "user")
+	for _, password := range []string{"First-Pass-1", "Second-Pass-2", "Third-Pass-3"} {
+		require.NoError(t, policy.SetPassword(db, stored, password))
+	}
+	assert.EqualError(t, policy.SetPassword(db, stored, "Third-Pass-3"), "invalid password: must not match one of the last 3 passwords")
+	assert.EqualError(t, policy.SetPassword(db, stored, "First-Pass-1"), "invalid password: must not match one of the last 3 passwords")
+	require.NoError(t, policy.SetPassword(db, stored, "Fourth-Pass-4"))
+	assert.NoError(t, policy.SetPassword(db, stored, "First-Pass-1"))
+
+	var count int64
+	require.NoError(t, db.Model(&domain.PasswordHistory{}).Where("user_id = ?", "alice").Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+
+	// Passwords are refused while the breach list cannot be read
+	missing := NewPasswordPolicy(&config.PasswordPolicyConfig{
+		MinLength:      10,
+		BreachListPath: filepath.Join(t.TempDir(), "missing.txt"),
+	})
+	assert.EqualError(t, missing.Validate("Correct-Horse-7", user), "failed to check password breach list")
+}
+
+func TestPasswordReset(t *testing.T) {
+	db := testutils.SetupTestDB()
+	jwtManager := newTestJWTManager()
+	authService := NewAuthServiceWithDB(db, jwtManager, config.Default())
+	passwordService := NewPasswordServiceWithDB(db, jwtManager, config.Default())
+	passwordService.store = cache.NewMemoryCache()
+	passwordService.throttle = newTestThrottle(cache.NewMemoryCache())
+	passwordService.resetConfig = &config.PasswordResetConfig{
+		TokenTTL:    30 * time.Minute,
+		ResetURL:    "https://office.example.com/reset?lang=en",
+		MaxRequests: 3,
+	}
+	var sent []*notify.Message
+	passwordService.sender = notify.SenderFunc(func(ctx context.Context, msg *notify.Message) error {
+		sent = append(sent, msg)
+		return nil
+	})
+	ctx := context.Background()
+	createTestUser(t, db, "alice", "user")
+
+	login, err := authService.Login(ctx, &LoginRequest{Username: "alice", Password: "secret123"})
+	require.NoError(t, err)
+
+	// Unknown accounts get the same answer and no message
+	require.NoError(t, passwordService.RequestPasswordReset(ctx, &PasswordResetRequest{Login: "nobody@example.com"}))
+	assert.Empty(t, sent)
+	assert.EqualError(t, passwordService.RequestPasswordReset(ctx, &PasswordResetRequest{Login: "alice", Channel: "fax"}), "invalid channel")
+
+	require.NoError(t, passwordService.RequestPasswordReset(ctx, &PasswordResetRequest{Login: "alice@example.com", IPAddress: "10.0.0.1"}))
+	require.NoError(t, passwordService.RequestPasswordReset(ctx, &PasswordResetRequest{Login: "alice"}))
+	require.Len(t, sent, 2)
+	assert.Equal(t, notify.ChannelEmail, sent[0].Channel)
+	assert.Equal(t, "alice@example.com", sent[0].To)
+
+	first := resetTokenFrom(t, sent[0].Body)
+	second := resetTokenFrom(t, sent[1].Body)
+
+	err = passwordService.ResetPassword(ctx, first, "short")
+	assert.EqualError(t, err, "invalid password: must be at least 10 characters")
+	require.NoError(t, passwordService.ResetPassword(ctx, first, "Brand-New-Pass-1"))
+
+	// The token is single use and the other outstanding token stops working
+	assert.EqualError(t, passwordService.ResetPassword(ctx, first, "Another-Pass-2"), "invalid or expired reset token")
+	assert.EqualError(t, passwordService.ResetPassword(ctx, second, "Another-Pass-2"), "invalid or expired reset token")
+	assert.EqualError(t, passwordService.ResetPassword(ctx, "unknown", "Another-Pass-2"), "invalid or expired reset token")
+
+	// Existing sessions end and the new password works
+	var session domain.Session
+	require.NoError(t, db.First(&session, "id = ?", login.SessionID).Error)
+	assert.Equal(t, domain.SessionRevokedPasswordReset, session.RevokedReason)
+	_, err = passwordService.PasswordLogin(ctx, &PasswordLoginRequest{Username: "alice", Password: "Brand-New-Pass-1"})
+	require.NoError(t, err)
+
+	// Expired tokens are refused
+	require.NoError(t, passwordService.RequestPasswordReset(ctx, &PasswordResetRequest{Login: "alice"}))
+	require.NoError(t, db.Model(&domain.PasswordResetToken{}).Where("used_at IS NULL").
+		Update("expires_at", time.Now().Add(-time.Minute)).Error)
+	assert.EqualError(t, passwordService.ResetPassword(ctx, resetTokenFrom(t, sent[2].Body), "Another-Pass-2"), "invalid or expired reset token")
+
+	// Requests are limited per hour
+	assert.EqualError(t, passwordService.RequestPasswordReset(ctx, &PasswordResetRequest{Login: "alice"}),
+		"too many password reset requests, try again later")
+}
+
+// resetTokenFrom extracts the reset token from the link in a message
+func resetTokenFrom(t *testing.T, body string) string {
+	link := regexp.MustCompile(`https://\S+`).FindString(body)
+	require.NotEmpty(t, link)
+	parsed, err := url.Parse(link)
+	require.NoError(t, err)
+	assert.Equal(t, "en", parsed.Query().Get("lang"))
+	return parsed.Query().Get("token")
+}
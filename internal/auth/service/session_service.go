@@ -0,0 +1,269 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+	"unicode/utf8"
+
+	"cdk-office/internal/auth/domain"
+	"cdk-office/internal/shared/database"
+	"cdk-office/internal/shared/utils"
+	"cdk-office/pkg/jwt"
+	"cdk-office/pkg/logger"
+	"gorm.io/gorm"
+)
+
+const (
+	// refreshTokenIDLength is the length of the random IDs of refresh tokens
+	refreshTokenIDLength = 32
+	// sessionTouchInterval is how often authenticated requests update the last
+	// seen time of their session
+	sessionTouchInterval = time.Minute
+)
+
+// SessionServiceInterface defines the interface for login session service
+type SessionServiceInterface interface {
+	CreateSession(ctx context.Context, user *domain.User, client *ClientInfo) (*LoginResponse, error)
+	RefreshSession(ctx context.Context, refreshToken string, client *ClientInfo) (*LoginResponse, error)
+	CheckSession(ctx context.Context, sessionID string) (bool, error)
+	ListSessions(ctx context.Context, userID string) ([]*domain.Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID, reason string) error
+	RevokeUserSessions(ctx context.Context, userID, reason string) (int64, error)
+}
+
+// SessionService implements the SessionServiceInterface
+type SessionService struct {
+	db         *gorm.DB
+	jwtManager *jwt.JWTManager
+}
+
+// NewSessionService creates a new instance of SessionService
+func NewSessionService(jwtManager *jwt.JWTManager) *SessionService {
+	return &SessionService{
+		db:         database.GetDB(),
+		jwtManager: jwtManager,
+	}
+}
+
+// NewSessionServiceWithDB creates a new instance of SessionService with a custom database connection
+func NewSessionServiceWithDB(db *gorm.DB, jwtManager *jwt.JWTManager) *SessionService {
+	return &SessionService{
+		db:         db,
+		jwtManager: jwtManager,
+	}
+}
+
+// ClientInfo describes the device a session is used from
+type ClientInfo struct {
+	DeviceName string
+	UserAgent  string
+	IPAddress  string
+}
+
+// CreateSession opens a session for a user who logged in and issues its tokens
+func (s *SessionService) CreateSession(ctx context.Context, user *domain.User, client *ClientInfo) (*LoginResponse, error) {
+	now := time.Now()
+	session := &domain.Session{
+		ID:             utils.GenerateSessionID(),
+		UserID:         user.ID,
+		CurrentTokenID: utils.GenerateShortCode(refreshTokenIDLength),
+		LastSeenAt:     now,
+		ExpiresAt:      now.Add(s.jwtManager.RefreshTokenExp()),
+	}
+	if client != nil {
+		session.DeviceName = truncate(client.DeviceName, 100)
+		session.UserAgent = truncate(client.UserAgent, 255)
+		session.IPAddress = truncate(client.IPAddress, 45)
+	}
+
+	if err := s.db.Create(session).Error; err != nil {
+		logger.Error("failed to create session", "error", err)
+		return nil, errors.New("failed to login")
+	}
+
+	return s.issueTokens(user, session.ID, session.CurrentTokenID)
+}
+
+// RefreshSession rotates the refresh token of a session and issues new tokens.
+// A refresh token that was already rotated means a copy of it leaked, so the
+// session is revoked.
+func (s *SessionService) RefreshSession(ctx context.Context, refreshToken string, client *ClientInfo) (*LoginResponse, error) {
+	claims, err := s.jwtManager.ParseRefreshToken(refreshToken)
+	if err != nil {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	var session domain.Session
+	if err := s.db.Where("id = ? AND user_id = ?", claims.SessionID, claims.Subject).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invalid refresh token")
+		}
+		logger.Error("failed to find session", "error", err)
+		return nil, errors.New("failed to refresh token")
+	}
+
+	now := time.Now()
+	if session.RevokedAt != nil {
+		return nil, errors.New("session has been revoked")
+	}
+	if !now.Before(session.ExpiresAt) {
+		return nil, errors.New("session has expired")
+	}
+
+	var user domain.User
+	if err := s.db.Where("id = ?", session.UserID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invalid refresh token")
+		}
+		logger.Error("failed to find user", "error", err)
+		return nil, errors.New("failed to refresh token")
+	}
+
+	tokenID := utils.GenerateShortCode(refreshTokenIDLength)
+	updates := map[string]interface{}{
+		"current_token_id": tokenID,
+		"last_seen_at":     now,
+		"expires_at":       now.Add(s.jwtManager.RefreshTokenExp()),
+		"updated_at":       now,
+	}
+	if client != nil && client.IPAddress != "" {
+		updates["ip_address"] = truncate(client.IPAddress, 45)
+	}
+	if client != nil && client.UserAgent != "" {
+		updates["user_agent"] = truncate(client.UserAgent, 255)
+	}
+
+	// Only the current token of the session can claim the rotation
+	result := s.db.Model(&domain.Session{}).
+		Where("id = ? AND current_token_id = ? AND revoked_at IS NULL", session.ID, claims.ID).
+		Updates(updates)
+	if result.Error != nil {
+		logger.Error("failed to rotate refresh token", "error", result.Error)
+		return nil, errors.New("failed to refresh token")
+	}
+	if result.RowsAffected == 0 {
+		logger.Warn("refresh token reuse detected, revoking session", "session_id", session.ID, "user_id", session.UserID)
+		if _, err := revokeSessions(s.db.Where("id = ?", session.ID), domain.SessionRevokedReuse); err != nil {
+			logger.Error("failed to revoke session", "error", err, "session_id", session.ID)
+		}
+		return nil, errors.New("refresh token reuse detected")
+	}
+
+	return s.issueTokens(&user, session.ID, tokenID)
+}
+
+// CheckSession reports whether a session is still active, recording that it was seen
+func (s *SessionService) CheckSession(ctx context.Context, sessionID string) (bool, error) {
+	var session domain.Session
+	if err := s.db.Where("id = ?", sessionID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		logger.Error("failed to find session", "error", err)
+		return false, errors.New("failed to check session")
+	}
+
+	now := time.Now()
+	if session.RevokedAt != nil || !now.Before(session.ExpiresAt) {
+		return false, nil
+	}
+
+	if now.Sub(session.LastSeenAt) >= sessionTouchInterval {
+		if err := s.db.Model(&domain.Session{}).Where("id = ?", sessionID).Update("last_seen_at", now).Error; err != nil {
+			logger.Error("failed to update session last seen time", "error", err, "session_id", sessionID)
+		}
+	}
+	return true, nil
+}
+
+// ListSessions lists the active sessions of a user, most recently seen first
+func (s *SessionService) ListSessions(ctx context.Context, userID string) ([]*domain.Session, error) {
+	var sessions []*domain.Session
+	if err := s.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").Find(&sessions).Error; err != nil {
+		logger.Error("failed to list sessions", "error", err)
+		return nil, errors.New("failed to list sessions")
+	}
+	return sessions, nil
+}
+
+// RevokeSession revokes a session of a user
+func (s *SessionService) RevokeSession(ctx context.Context, userID, sessionID, reason string) error {
+	var session domain.Session
+	if err := s.db.Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("session not found")
+		}
+		logger.Error("failed to find session", "error", err)
+		return errors.New("failed to revoke session")
+	}
+
+	if _, err := revokeSessions(s.db.Where("id = ?", sessionID), reason); err != nil {
+		logger.Error("failed to revoke session", "error", err)
+		return errors.New("failed to revoke session")
+	}
+	return nil
+}
+
+// RevokeUserSessions revokes every session of a user and returns how many were active
+func (s *SessionService) RevokeUserSessions(ctx context.Context, userID, reason string) (int64, error) {
+	var count int64
+	if err := s.db.Model(&domain.User{}).Where("id = ?", userID).Count(&count).Error; err != nil {
+		logger.Error("failed to find user", "error", err)
+		return 0, errors.New("failed to revoke sessions")
+	}
+	if count == 0 {
+		return 0, errors.New("user not found")
+	}
+
+	revoked, err := revokeSessions(s.db.Where("user_id = ?", userID), reason)
+	if err != nil {
+		logger.Error("failed to revoke sessions", "error", err)
+		return 0, errors.New("failed to revoke sessions")
+	}
+	return revoked, nil
+}
+
+// issueTokens issues the access token and refresh token tokenID of a session
+func (s *SessionService) issueTokens(user *domain.User, sessionID, tokenID string) (*LoginResponse, error) {
+	// Generate access token
+	accessToken, err := s.jwtManager.GenerateSessionAccessToken(user.ID, user.Username, user.Role, sessionID)
+	if err != nil {
+		logger.Error("failed to generate access token", "error", err)
+		return nil, errors.New("failed to login")
+	}
+
+	// Generate refresh token
+	refreshToken, err := s.jwtManager.GenerateRefreshToken(user.ID, sessionID, tokenID)
+	if err != nil {
+		logger.Error("failed to generate refresh token", "error", err)
+		return nil, errors.New("failed to login")
+	}
+
+	return &LoginResponse{
+		User:         user,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		SessionID:    sessionID,
+	}, nil
+}
+
+// revokeSessions revokes the active sessions matched by query
+func revokeSessions(query *gorm.DB, reason string) (int64, error) {
+	now := time.Now()
+	result := query.Model(&domain.Session{}).Where("revoked_at IS NULL").
+		Updates(map[string]interface{}{"revoked_at": now, "revoked_reason": reason, "updated_at": now})
+	return result.RowsAffected, result.Error
+}
+
+// truncate shortens a string to at most max bytes, keeping whole UTF-8 characters
+func truncate(value string, max int) string {
+	if len(value) <= max {
+		return value
+	}
+	for max > 0 && !utf8.RuneStart(value[max]) {
+		max--
+	}
+	return value[:max]
+}
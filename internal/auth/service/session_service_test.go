@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"cdk-office/internal/auth/domain"
+	"cdk-office/internal/shared/testutils"
+	"cdk-office/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionRefreshRotation(t *testing.T) {
+	db := testutils.SetupTestDB()
+	jwtManager := newTestJWTManager()
+	authService := NewAuthServiceWithDB(db, jwtManager, config.Default())
+	sessionService := NewSessionServiceWithDB(db, jwtManager)
+	ctx := context.Background()
+	createTestUser(t, db, "alice", "hr")
+
+	resp, err := authService.Login(ctx, &LoginRequest{
+		Username: "alice",
+		Password: "secret123",
+		Client:   &ClientInfo{DeviceName: "laptop", UserAgent: "test-agent", IPAddress: "10.0.0.1"},
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, resp.SessionID)
+	_, err = jwtManager.VerifyToken(resp.RefreshToken)
+	assert.Error(t, err, "a refresh token must not pass as an access token")
+	_, err = authService.RefreshToken(ctx, resp.AccessToken, nil)
+	assert.EqualError(t, err, "invalid refresh token")
+
+	// Refreshing rotates the token and keeps the user details in the access token
+	refreshed, err := authService.RefreshToken(ctx, resp.RefreshToken, &ClientInfo{IPAddress: "10.0.0.2"})
+	require.NoError(t, err)
+	assert.Equal(t, resp.SessionID, refreshed.SessionID)
+	assert.NotEqual(t, resp.RefreshToken, refreshed.RefreshToken)
+	claims, err := jwtManager.VerifyToken(refreshed.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", claims.Username)
+	assert.Equal(t, "hr", claims.Role)
+	assert.Equal(t, resp.SessionID, claims.SessionID)
+
+	sessions, err := sessionService.ListSessions(ctx, "alice")
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "laptop", sessions[0].DeviceName)
+	assert.Equal(t, "10.0.0.2", sessions[0].IPAddress)
+
+	// Presenting the rotated token again revokes the whole family
+	_, err = authService.RefreshToken(ctx, resp.RefreshToken, nil)
+	assert.EqualError(t, err, "refresh token reuse detected")
+	_, err = authService.RefreshToken(ctx, refreshed.RefreshToken, nil)
+	assert.EqualError(t, err, "session has been revoked")
+
+	active, err := sessionService.CheckSession(ctx, resp.SessionID)
+	require.NoError(t, err)
+	assert.False(t, active)
+
+	var session domain.Session
+	require.NoError(t, db.First(&session, "id = ?", resp.SessionID).Error)
+	assert.Equal(t, domain.SessionRevokedReuse, session.RevokedReason)
+}
+
+func TestSessionRevocation(t *testing.T) {
+	db := testutils.SetupTestDB()
+	jwtManager := newTestJWTManager()
+	authService := NewAuthServiceWithDB(db, jwtManager, config.Default())
+	sessionService := NewSessionServiceWithDB(db, jwtManager)
+	ctx := context.Background()
+	createTestUser(t, db, "alice", "user")
+	createTestUser(t, db, "bob", "user")
+
+	laptop, err := authService.Login(ctx, &LoginRequest{Username: "alice", Password: "secret123", Client: &ClientInfo{DeviceName: "laptop"}})
+	require.NoError(t, err)
+	phone, err := authService.Login(ctx, &LoginRequest{Username: "alice", Password: "secret123", Client: &ClientInfo{DeviceName: "phone"}})
+	require.NoError(t, err)
+	bob, err := authService.Login(ctx, &LoginRequest{Username: "bob", Password: "secret123"})
+	require.NoError(t, err)
+
+	sessions, err := sessionService.ListSessions(ctx, "alice")
+	require.NoError(t, err)
+	assert.Len(t, sessions, 2)
+
+	err = sessionService.RevokeSession(ctx, "bob", laptop.SessionID, domain.SessionRevokedByUser)
+	assert.EqualError(t, err, "session not found")
+	require.NoError(t, sessionService.RevokeSession(ctx, "alice", laptop.SessionID, domain.SessionRevokedByUser))
+
+	_, err = authService.RefreshToken(ctx, laptop.RefreshToken, nil)
+	assert.EqualError(t, err, "session has been revoked")
+	sessions, err = sessionService.ListSessions(ctx, "alice")
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "phone", sessions[0].DeviceName)
+
+	// A forced logout ends every session of the user only
+	revoked, err := sessionService.RevokeUserSessions(ctx, "alice", domain.SessionRevokedForced)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, revoked)
+	active, err := sessionService.CheckSession(ctx, phone.SessionID)
+	require.NoError(t, err)
+	assert.False(t, active)
+	active, err = sessionService.CheckSession(ctx, bob.SessionID)
+	require.NoError(t, err)
+	assert.True(t, active)
+
+	_, err = sessionService.RevokeUserSessions(ctx, "nobody", domain.SessionRevokedForced)
+	assert.EqualError(t, err, "user not found")
+}
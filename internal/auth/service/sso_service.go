@@ -0,0 +1,436 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"cdk-office/internal/auth/domain"
+	"cdk-office/internal/auth/provider"
+	"cdk-office/internal/shared/cache"
+	"cdk-office/internal/shared/database"
+	"cdk-office/internal/shared/utils"
+	"cdk-office/pkg/config"
+	"cdk-office/pkg/jwt"
+	"cdk-office/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// SSOServiceInterface defines the interface for single sign-on service
+type SSOServiceInterface interface {
+	ListProviders(ctx context.Context) []*SSOProviderInfo
+	BeginLogin(ctx context.Context, providerName string) (*SSOAuthorization, error)
+	CompleteLogin(ctx context.Context, providerName, state, code string, client *ClientInfo) (*LoginResponse, error)
+	PasswordLogin(ctx context.Context, providerName string, req *LoginRequest) (*LoginResponse, error)
+}
+
+// SSOService implements the SSOServiceInterface
+type SSOService struct {
+	db        *gorm.DB
+	providers *provider.Registry
+	sessions  SessionServiceInterface
+	mfa       MFAServiceInterface
+	throttle  *LoginThrottle
+	store     cache.StoreInterface
+	config    *config.SSOConfig
+}
+
+// NewSSOService creates a new instance of SSOService
+func NewSSOService(jwtManager *jwt.JWTManager, cfg *config.Config) *SSOService {
+	return NewSSOServiceWithDB(database.GetDB(), jwtManager, cfg)
+}
+
+// NewSSOServiceWithDB creates a new instance of SSOService with a custom database connection
+func NewSSOServiceWithDB(db *gorm.DB, jwtManager *jwt.JWTManager, cfg *config.Config) *SSOService {
+	return &SSOService{
+		db:        db,
+		providers: provider.NewRegistry(&cfg.SSO),
+		sessions:  NewSessionServiceWithDB(db, jwtManager),
+		mfa:       NewMFAServiceWithDB(db, jwtManager, &cfg.MFA),
+		throttle:  NewLoginThrottle(&cfg.LoginThrottle),
+		store:     cache.NewStore(),
+		config:    &cfg.SSO,
+	}
+}
+
+// SSOProviderInfo describes a provider on the login page
+type SSOProviderInfo struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	DisplayName string `json:"display_name"`
+}
+
+// SSOAuthorization is a login started at a redirect provider
+type SSOAuthorization struct {
+	AuthorizationURL string `json:"authorization_url"`
+	State            string `json:"state"`
+}
+
+// pendingSSOLogin is the login a state stands for
+type pendingSSOLogin struct {
+	Provider     string `json:"provider"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// ListProviders lists the configured providers
+func (s *SSOService) ListProviders(ctx context.Context) []*SSOProviderInfo {
+	var infos []*SSOProviderInfo
+	for _, p := range s.providers.List() {
+		infos = append(infos, &SSOProviderInfo{Name: p.Name(), Type: p.Type(), DisplayName: p.DisplayName()})
+	}
+	return infos
+}
+
+// BeginLogin starts a login at a redirect provider
+func (s *SSOService) BeginLogin(ctx context.Context, providerName string) (*SSOAuthorization, error) {
+	p, ok := s.providers.Get(providerName)
+	if !ok {
+		return nil, errors.New("identity provider not found")
+	}
+	redirect, ok := p.(provider.RedirectProvider)
+	if !ok {
+		return nil, errors.New("identity provider does not support redirect login")
+	}
+
+	verifier, err := provider.NewCodeVerifier()
+	if err != nil {
+		logger.Error("failed to generate code verifier", "error", err)
+		return nil, errors.New("failed to start single sign-on")
+	}
+	state := utils.GenerateShortCode(32)
+	pending := &pendingSSOLogin{Provider: providerName, Nonce: utils.GenerateShortCode(32), CodeVerifier: verifier}
+
+	authURL, err := redirect.AuthCodeURL(ctx, state, pending.Nonce, provider.CodeChallenge(verifier))
+	if err != nil {
+		logger.Error("failed to build authorization url", "provider", providerName, "error", err)
+		return nil, errors.New("failed to start single sign-on")
+	}
+	if err := s.store.Set("sso_state:"+state, pending, s.config.StateTTL); err != nil {
+		logger.Error("failed to store sso state", "error", err)
+		return nil, errors.New("failed to start single sign-on")
+	}
+
+	return &SSOAuthorization{AuthorizationURL: authURL, State: state}, nil
+}
+
+// CompleteLogin finishes a login at a redirect provider with the code and
+// state the provider redirected back with, then continues the login like a
+// local password login unless the provider is trusted to ask for a second
+// factor itself.
+func (s *SSOService) CompleteLogin(ctx context.Context, providerName, state, code string, client *ClientInfo) (*LoginResponse, error) {
+	key := "sso_state:" + state
+	var pending pendingSSOLogin
+	if err := s.store.Get(key, &pending); err != nil || pending.Provider != providerName {
+		return nil, errors.New("invalid or expired sso state")
+	}
+	// The state is single-use
+	if err := s.store.Delete(key); err != nil {
+		logger.Error("failed to delete sso state", "error", err)
+		return nil, errors.New("failed to complete single sign-on")
+	}
+
+	p, ok := s.providers.Get(providerName)
+	if !ok {
+		return nil, errors.New("identity provider not found")
+	}
+	redirect, ok := p.(provider.RedirectProvider)
+	if !ok {
+		return nil, errors.New("identity provider does not support redirect login")
+	}
+
+	identity, err := redirect.Exchange(ctx, code, pending.CodeVerifier, pending.Nonce)
+	if err != nil {
+		if errors.Is(err, provider.ErrInvalidCode) {
+			return nil, errors.New("invalid authorization code")
+		}
+		logger.Error("failed to exchange authorization code", "provider", providerName, "error", err)
+		return nil, errors.New("failed to complete single sign-on")
+	}
+
+	user, err := s.resolveUser(identity)
+	if err != nil {
+		return nil, err
+	}
+	if cfg := s.config.Provider(providerName); cfg != nil && cfg.SkipMFA {
+		return s.sessions.CreateSession(ctx, user, client)
+	}
+	return s.mfa.BeginLogin(ctx, user, client)
+}
+
+// PasswordLogin logs a user in with the username and password of a password
+// provider, then continues the login like a local password login
+func (s *SSOService) PasswordLogin(ctx context.Context, providerName string, req *LoginRequest) (*LoginResponse, error) {
+	p, ok := s.providers.Get(providerName)
+	if !ok {
+		return nil, errors.New("identity provider not found")
+	}
+	passwordProvider, ok := p.(provider.PasswordProvider)
+	if !ok {
+		return nil, errors.New("identity provider does not support password login")
+	}
+
+	ip := ""
+	if req.Client != nil {
+		ip = req.Client.IPAddress
+	}
+	if err := s.throttle.Check(req.Username, ip); err != nil {
+		return nil, err
+	}
+
+	identity, err := passwordProvider.Authenticate(ctx, req.Username, req.Password)
+	if err != nil {
+		if errors.Is(err, provider.ErrInvalidCredentials) {
+			s.throttle.RecordFailure(req.Username, ip)
+			return nil, errors.New("invalid username or password")
+		}
+		logger.Error("failed to authenticate with identity provider", "provider", providerName, "error", err)
+		return nil, errors.New("failed to login")
+	}
+	s.throttle.Reset(req.Username)
+
+	user, err := s.resolveUser(identity)
+	if err != nil {
+		return nil, err
+	}
+	return s.mfa.BeginLogin(ctx, user, req.Client)
+}
+
+// resolveUser finds the user of an identity, linking it to the user with its
+// verified email or provisioning a new user when unknown, and syncs the roles
+// its groups map to. Users whose account is not active are refused.
+func (s *SSOService) resolveUser(identity *provider.Identity) (*domain.User, error) {
+	cfg := s.config.Provider(identity.Provider)
+	if cfg == nil {
+		return nil, errors.New("identity provider not found")
+	}
+
+	var user *domain.User
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		user, err = s.findOrCreateUser(tx, cfg, identity)
+		if err != nil {
+			return err
+		}
+		if user.Status != "active" {
+			return errors.New("user account is not active")
+		}
+		return syncRoles(tx, cfg, user, identity.Groups)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// findOrCreateUser finds the user linked to an identity, links one or creates one
+func (s *SSOService) findOrCreateUser(tx *gorm.DB, cfg *config.SSOProviderConfig, identity *provider.Identity) (*domain.User, error) {
+	now := time.Now()
+
+	var linked domain.UserIdentity
+	err := tx.Where("provider = ? AND open_id = ?", identity.Provider, identity.Subject).First(&linked).Error
+	if err == nil {
+		var user domain.User
+		if err := tx.Where("id = ?", linked.UserID).First(&user).Error; err != nil {
+			logger.Error("failed to find user of identity", "error", err)
+			return nil, errors.New("failed to complete single sign-on")
+		}
+		if err := tx.Model(&linked).Updates(map[string]interface{}{"last_login_at": now, "updated_at": now}).Error; err != nil {
+			logger.Error("failed to update identity", "error", err)
+			return nil, errors.New("failed to complete single sign-on")
+		}
+		return &user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Error("failed to find identity", "error", err)
+		return nil, errors.New("failed to complete single sign-on")
+	}
+
+	if identity.Email == "" {
+		return nil, errors.New("identity provider did not return an email address")
+	}
+
+	var user domain.User
+	err = tx.Where("email = ?", identity.Email).First(&user).Error
+	switch {
+	case err == nil:
+		if !cfg.LinkByEmail || !identity.EmailVerified {
+			return nil, errors.New("an account with this email already exists")
+		}
+		var count int64
+		if err := tx.Model(&domain.UserIdentity{}).Where("provider = ? AND user_id = ?", identity.Provider, user.ID).Count(&count).Error; err != nil {
+			logger.Error("failed to find identity", "error", err)
+			return nil, errors.New("failed to complete single sign-on")
+		}
+		if count > 0 {
+			return nil, errors.New("user is already linked to another account of this identity provider")
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if !cfg.AutoProvision {
+			return nil, errors.New("no account is linked to this identity")
+		}
+		username, err := availableUsername(tx, identity)
+		if err != nil {
+			return nil, err
+		}
+		user = domain.User{
+			ID:        utils.GenerateUserID(),
+			Username:  username,
+			Email:     identity.Email,
+			Phone:     identity.Phone,
+			RealName:  identity.RealName,
+			Role:      cfg.DefaultRole,
+			Status:    "active",
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			logger.Error("failed to create user", "error", err)
+			return nil, errors.New("failed to complete single sign-on")
+		}
+	default:
+		logger.Error("failed to find user", "error", err)
+		return nil, errors.New("failed to complete single sign-on")
+	}
+
+	if err := tx.Create(&domain.UserIdentity{
+		ID:          utils.GenerateUserIdentityID(),
+		UserID:      user.ID,
+		Provider:    identity.Provider,
+		OpenID:      identity.Subject,
+		LastLoginAt: &now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}).Error; err != nil {
+		logger.Error("failed to create identity", "error", err)
+		return nil, errors.New("failed to complete single sign-on")
+	}
+	return &user, nil
+}
+
+// availableUsername returns the username of an identity, or its email local
+// part, suffixed with a number when taken
+func availableUsername(tx *gorm.DB, identity *provider.Identity) (string, error) {
+	base := identity.Username
+	if base == "" {
+		base, _, _ = strings.Cut(identity.Email, "@")
+	}
+	if len(base) > 45 {
+		base = base[:45]
+	}
+
+	username := base
+	for i := 2; i < 100; i++ {
+		var count int64
+		if err := tx.Model(&domain.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+			logger.Error("failed to find user", "error", err)
+			return "", errors.New("failed to complete single sign-on")
+		}
+		if count == 0 {
+			return username, nil
+		}
+		username = fmt.Sprintf("%s%d", base, i)
+	}
+	return "", errors.New("failed to complete single sign-on")
+}
+
+// syncRoles maps the groups of an identity to roles. The first mapped role
+// becomes the primary role and all mapped roles are assigned; roles the
+// provider maps but the groups no longer grant are revoked. Roles assigned
+// otherwise are left alone.
+func syncRoles(tx *gorm.DB, cfg *config.SSOProviderConfig, user *domain.User, groups []string) error {
+	if len(cfg.GroupRoles) == 0 {
+		return nil
+	}
+
+	var managed, granted []string
+	for _, mapping := range cfg.GroupRoles {
+		managed = appendUnique(managed, mapping.Role)
+		for _, group := range groups {
+			if groupMatches(group, mapping.Group) {
+				granted = appendUnique(granted, mapping.Role)
+				break
+			}
+		}
+	}
+
+	role := user.Role
+	if len(granted) > 0 {
+		role = granted[0]
+	} else if contains(managed, role) {
+		role = cfg.DefaultRole
+	}
+	if role != user.Role {
+		if err := tx.Model(user).Updates(map[string]interface{}{"role": role, "updated_at": time.Now()}).Error; err != nil {
+			logger.Error("failed to update user role", "error", err)
+			return errors.New("failed to complete single sign-on")
+		}
+		user.Role = role
+	}
+
+	var assigned []string
+	if err := tx.Model(&domain.UserRole{}).Where("user_id = ?", user.ID).Pluck("role", &assigned).Error; err != nil {
+		logger.Error("failed to find user roles", "error", err)
+		return errors.New("failed to complete single sign-on")
+	}
+	var revoked []string
+	for _, name := range assigned {
+		if contains(managed, name) && !contains(granted, name) {
+			revoked = append(revoked, name)
+		}
+	}
+	if len(revoked) > 0 {
+		if err := tx.Where("user_id = ? AND role IN ?", user.ID, revoked).Delete(&domain.UserRole{}).Error; err != nil {
+			logger.Error("failed to revoke user roles", "error", err)
+			return errors.New("failed to complete single sign-on")
+		}
+	}
+	for _, name := range granted {
+		if contains(assigned, name) {
+			continue
+		}
+		if err := tx.Create(&domain.UserRole{
+			ID:        utils.GenerateUserRoleID(),
+			UserID:    user.ID,
+			Role:      name,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}).Error; err != nil {
+			logger.Error("failed to assign user role", "error", err)
+			return errors.New("failed to complete single sign-on")
+		}
+	}
+	return nil
+}
+
+// groupMatches reports whether a group of an identity is the group of a
+// mapping. Directory groups are DNs, which also match by their first RDN
+// value, so "cn=hr,ou=groups,dc=example,dc=com" matches "hr".
+func groupMatches(group, pattern string) bool {
+	if strings.EqualFold(group, pattern) {
+		return true
+	}
+	rdn, _, _ := strings.Cut(group, ",")
+	_, value, ok := strings.Cut(rdn, "=")
+	return ok && strings.EqualFold(strings.TrimSpace(value), pattern)
+}
+
+// appendUnique appends a value not in a slice yet
+func appendUnique(values []string, value string) []string {
+	if contains(values, value) {
+		return values
+	}
+	return append(values, value)
+}
+
+// contains reports whether a slice holds a value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"cdk-office/internal/auth/domain"
+	"cdk-office/internal/auth/provider"
+	"cdk-office/internal/shared/cache"
+	"cdk-office/internal/shared/testutils"
+	"cdk-office/pkg/config"
+	"cdk-office/pkg/ldap/ldaptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// stubRedirectProvider answers code "ok" with its identity when the verifier
+// matches the challenge of the authorization
+type stubRedirectProvider struct {
+	name      string
+	identity  *provider.Identity
+	challenge string
+	nonce     string
+}
+
+func (p *stubRedirectProvider) Name() string        { return p.name }
+func (p *stubRedirectProvider) Type() string        { return config.SSOProviderOIDC }
+func (p *stubRedirectProvider) DisplayName() string { return "Corp" }
+
+func (p *stubRedirectProvider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	p.challenge, p.nonce = codeChallenge, nonce
+	return "https://idp.example.com/authorize?" + url.Values{"state": {state}}.Encode(), nil
+}
+
+func (p *stubRedirectProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*provider.Identity, error) {
+	if code != "ok" || provider.CodeChallenge(codeVerifier) != p.challenge || nonce != p.nonce {
+		return nil, provider.ErrInvalidCode
+	}
+	identity := *p.identity
+	identity.Provider = p.name
+	return &identity, nil
+}
+
+func newTestSSOService(db *gorm.DB, providers ...*config.SSOProviderConfig) *SSOService {
+	s := NewSSOServiceWithDB(db, newTestJWTManager(), config.Default())
+	s.config = &config.SSOConfig{StateTTL: s.config.StateTTL, Providers: providers}
+	s.providers = provider.NewRegistry(s.config)
+	s.store = cache.NewMemoryCache()
+	s.throttle = newTestThrottle(cache.NewMemoryCache())
+	return s
+}
+
+// login runs a redirect login through the stub provider
+func login(t *testing.T, s *SSOService, stub *stubRedirectProvider) (*LoginResponse, error) {
+	authorization, err := s.BeginLogin(context.Background(), stub.name)
+	require.NoError(t, err)
+	return s.CompleteLogin(context.Background(), stub.name, authorization.State, "ok", nil)
+}
+
+func userRoles(t *testing.T, db *gorm.DB, userID string) []string {
+	var roles []string
+	require.NoError(t, db.Model(&domain.UserRole{}).Where("user_id = ?", userID).Order("role").Pluck("role", &roles).Error)
+	return roles
+}
+
+func TestSSOProvisionsAndMapsGroups(t *testing.T) {
+	db := testutils.SetupTestDB()
+	s := newTestSSOService(db, &config.SSOProviderConfig{
+		Name:          "corp",
+		Type:          config.SSOProviderOIDC,
+		AutoProvision: true,
+		LinkByEmail:   true,
+		DefaultRole:   "user",
+		GroupRoles:    []config.SSOGroupRole{{Group: "admins", Role: "admin"}, {Group: "finance", Role: "finance"}},
+	})
+	stub := &stubRedirectProvider{name: "corp", identity: &provider.Identity{
+		Subject: "subject-1", Username: "alice", Email: "alice@corp.example.com", EmailVerified: true,
+		RealName: "Alice", Groups: []string{"finance", "admins"},
+	}}
+	s.providers.Register(stub)
+	ctx := context.Background()
+	createTestUser(t, db, "alice", "user")
+
+	authorization, err := s.BeginLogin(ctx, "corp")
+	require.NoError(t, err)
+	_, err = s.CompleteLogin(ctx, "corp", authorization.State, "bad", nil)
+	assert.EqualError(t, err, "invalid authorization code")
+	_, err = s.CompleteLogin(ctx, "corp", authorization.State, "ok", nil)
+	assert.EqualError(t, err, "invalid or expired sso state", "states are single-use")
+	_, err = s.BeginLogin(ctx, "unknown")
+	assert.EqualError(t, err, "identity provider not found")
+
+	// The first login provisions a user, renamed as the username is taken
+	resp, err := login(t, s, stub)
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.AccessToken)
+	assert.Equal(t, "alice2", resp.User.Username)
+	assert.Equal(t, "admin", resp.User.Role)
+	assert.Empty(t, resp.User.Password)
+	userID := resp.User.ID
+	assert.Equal(t, []string{"admin", "finance"}, userRoles(t, db, userID))
+
+	// Later logins find the user by subject and follow group changes, keeping
+	// roles assigned otherwise
+	require.NoError(t, db.Create(&domain.UserRole{ID: "manual", UserID: userID, Role: "auditor"}).Error)
+	stub.identity.Groups = []string{"finance"}
+	resp, err = login(t, s, stub)
+	require.NoError(t, err)
+	assert.Equal(t, userID, resp.User.ID)
+	assert.Equal(t, "finance", resp.User.Role)
+	assert.Equal(t, []string{"auditor", "finance"}, userRoles(t, db, userID))
+
+	stub.identity.Groups = nil
+	resp, err = login(t, s, stub)
+	require.NoError(t, err)
+	assert.Equal(t, "user", resp.User.Role)
+	assert.Equal(t, []string{"auditor"}, userRoles(t, db, userID))
+}
+
+func TestSSOLinksByVerifiedEmail(t *testing.T) {
+	db := testutils.SetupTestDB()
+	cfg := &config.SSOProviderConfig{Name: "corp", Type: config.SSOProviderOIDC, LinkByEmail: true, DefaultRole: "user"}
+	s := newTestSSOService(db, cfg)
+	stub := &stubRedirectProvider{name: "corp", identity: &provider.Identity{Subject: "subject-1", Email: "alice@example.com"}}
+	s.providers.Register(stub)
+	createTestUser(t, db, "alice", "manager")
+
+	_, err := login(t, s, stub)
+	assert.EqualError(t, err, "an account with this email already exists")
+
+	stub.identity.EmailVerified = true
+	resp, err := login(t, s, stub)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", resp.User.ID)
+	assert.Equal(t, "manager", resp.User.Role, "roles are left alone without group mappings")
+
+	// Another account of the provider cannot take over the user
+	stub.identity.Subject = "subject-2"
+	_, err = login(t, s, stub)
+	assert.EqualError(t, err, "user is already linked to another account of this identity provider")
+
+	// Unknown users are not provisioned unless enabled
+	stub.identity.Email = "bob@example.com"
+	_, err = login(t, s, stub)
+	assert.EqualError(t, err, "no account is linked to this identity")
+}
+
+func TestSSOPasswordLoginWithLDAP(t *testing.T) {
+	server := ldaptest.NewServer(&ldaptest.Entry{
+		DN:       "uid=carol,ou=people,dc=example,dc=com",
+		Password: "carol-secret",
+		Attributes: map[string][]string{
+			"uid":      {"carol"},
+			"mail":     {"carol@example.com"},
+			"memberOf": {"cn=hr,ou=groups,dc=example,dc=com"},
+		},
+	})
+	defer server.Close()
+
+	db := testutils.SetupTestDB()
+	s := newTestSSOService(db, &config.SSOProviderConfig{
+		Name:          "directory",
+		Type:          config.SSOProviderLDAP,
+		URL:           server.URL,
+		BaseDN:        "ou=people,dc=example,dc=com",
+		UserFilter:    "(uid=%s)",
+		UsernameAttr:  "uid",
+		EmailAttr:     "mail",
+		GroupAttr:     "memberOf",
+		SubjectAttr:   "entryUUID",
+		AutoProvision: true,
+		DefaultRole:   "user",
+		GroupRoles:    []config.SSOGroupRole{{Group: "hr", Role: "hr"}},
+	})
+	ctx := context.Background()
+
+	_, err := s.PasswordLogin(ctx, "directory", &LoginRequest{Username: "carol", Password: "wrong"})
+	assert.EqualError(t, err, "invalid username or password")
+
+	resp, err := s.PasswordLogin(ctx, "directory", &LoginRequest{Username: "carol", Password: "carol-secret"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, resp.AccessToken)
+	assert.Equal(t, "carol", resp.User.Username)
+	assert.Equal(t, "hr", resp.User.Role)
+
+	var identity domain.UserIdentity
+	require.NoError(t, db.First(&identity, "provider = ?", "directory").Error)
+	assert.Equal(t, resp.User.ID, identity.UserID)
+	assert.Equal(t, "uid=carol,ou=people,dc=example,dc=com", identity.OpenID)
+
+	_, err = s.BeginLogin(ctx, "directory")
+	assert.EqualError(t, err, "identity provider does not support redirect login")
+}
+
+func TestSSOLoginEnforcesMFAAndAccountStatus(t *testing.T) {
+	db := testutils.SetupTestDB()
+	cfg := &config.SSOProviderConfig{Name: "corp", Type: config.SSOProviderOIDC, LinkByEmail: true}
+	s := newTestSSOService(db, cfg)
+	stub := &stubRedirectProvider{name: "corp", identity: &provider.Identity{Subject: "subject-1", Email: "alice@example.com", EmailVerified: true}}
+	s.providers.Register(stub)
+	createTestUser(t, db, "alice", "admin")
+	require.NoError(t, db.Create(&domain.MFARolePolicy{Role: "admin", Required: true}).Error)
+
+	// A role requiring a second factor is asked for it after single sign-on
+	resp, err := login(t, s, stub)
+	require.NoError(t, err)
+	assert.True(t, resp.MFARequired)
+	assert.NotEmpty(t, resp.MFAToken)
+	assert.Empty(t, resp.AccessToken)
+
+	// unless the provider is trusted to have asked for it
+	cfg.SkipMFA = true
+	resp, err = login(t, s, stub)
+	require.NoError(t, err)
+	assert.False(t, resp.MFARequired)
+	assert.NotEmpty(t, resp.AccessToken)
+
+	// Accounts that are not active are refused
+	require.NoError(t, db.Model(&domain.User{}).Where("id = ?", "alice").Update("status", "disabled").Error)
+	_, err = login(t, s, stub)
+	assert.EqualError(t, err, "user account is not active")
+}
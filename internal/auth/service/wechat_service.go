@@ -6,111 +6,291 @@ import (
 	"time"
 
 	"cdk-office/internal/auth/domain"
+	"cdk-office/internal/shared/cache"
 	"cdk-office/internal/shared/database"
+	"cdk-office/internal/shared/utils"
+	"cdk-office/pkg/config"
 	"cdk-office/pkg/jwt"
 	"cdk-office/pkg/logger"
+	"cdk-office/pkg/wechat"
 	"gorm.io/gorm"
 )
 
 // WeChatServiceInterface defines the interface for WeChat authentication service
 type WeChatServiceInterface interface {
-	WeChatLogin(ctx context.Context, code string) (*LoginResponse, error)
+	WeChatLogin(ctx context.Context, code string, client *ClientInfo) (*LoginResponse, error)
+	BindLogin(ctx context.Context, req *WeChatBindLoginRequest) (*LoginResponse, error)
+	BindWeChat(ctx context.Context, userID, code string) error
+	UnbindWeChat(ctx context.Context, userID string) error
+	BindPhoneNumber(ctx context.Context, userID string, req *WeChatPhoneRequest) (string, error)
 }
 
 // WeChatService implements the WeChatServiceInterface
 type WeChatService struct {
-	db *gorm.DB
+	db       *gorm.DB
+	client   wechat.ClientInterface
+	mfa      MFAServiceInterface
+	throttle *LoginThrottle
+	store    cache.StoreInterface
+	config   *config.WeChatConfig
 }
 
 // NewWeChatService creates a new instance of WeChatService
-func NewWeChatService() *WeChatService {
+func NewWeChatService(jwtManager *jwt.JWTManager, cfg *config.Config) *WeChatService {
+	return NewWeChatServiceWithDB(database.GetDB(), jwtManager, cfg)
+}
+
+// NewWeChatServiceWithDB creates a new instance of WeChatService with a custom database connection
+func NewWeChatServiceWithDB(db *gorm.DB, jwtManager *jwt.JWTManager, cfg *config.Config) *WeChatService {
 	return &WeChatService{
-		db: database.GetDB(),
+		db:       db,
+		client:   wechat.NewClient(cfg.WeChat.BaseURL, cfg.WeChat.AppID, cfg.WeChat.AppSecret),
+		mfa:      NewMFAServiceWithDB(db, jwtManager, &cfg.MFA),
+		throttle: NewLoginThrottle(&cfg.LoginThrottle),
+		store:    cache.NewStore(),
+		config:   &cfg.WeChat,
 	}
 }
 
-// WeChatLogin authenticates a user via WeChat
-func (s *WeChatService) WeChatLogin(ctx context.Context, code string) (*LoginResponse, error) {
-	// Exchange code for access token and openid (simplified implementation)
-	// In a real application, you would call WeChat's API to exchange the code
-	// For now, we'll simulate this process
-	openid, err := s.exchangeCodeForOpenID(code)
+// WeChatBindLoginRequest represents the request for binding a WeChat account to
+// a password account while logging in
+type WeChatBindLoginRequest struct {
+	BindToken string
+	Username  string
+	Password  string
+	Client    *ClientInfo // device the session is opened from
+}
+
+// WeChatPhoneRequest represents the data of a getPhoneNumber button event
+type WeChatPhoneRequest struct {
+	Code          string // fresh wx.login code, for the session key the data is encrypted with
+	EncryptedData string
+	IV            string
+}
+
+// pendingWeChatBinding is the WeChat account a bind token stands for
+type pendingWeChatBinding struct {
+	OpenID  string `json:"openid"`
+	UnionID string `json:"unionid"`
+}
+
+// WeChatLogin authenticates a user via WeChat, then continues the login like a
+// password login. A WeChat account not bound to a user yet gets a bind token to
+// present with the password of the account to bind.
+func (s *WeChatService) WeChatLogin(ctx context.Context, code string, client *ClientInfo) (*LoginResponse, error) {
+	session, err := s.code2Session(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := s.findIdentity(session)
 	if err != nil {
-		logger.Error("failed to exchange code for openid", "error", err)
+		return nil, err
+	}
+	if identity == nil {
+		token := utils.GenerateShortCode(32)
+		if err := s.store.Set("wechat_bind:"+token, &pendingWeChatBinding{OpenID: session.OpenID, UnionID: session.UnionID}, s.config.BindTokenTTL); err != nil {
+			logger.Error("failed to store WeChat bind token", "error", err)
+			return nil, errors.New("failed to login with WeChat")
+		}
+		return &LoginResponse{WeChatBindRequired: true, WeChatBindToken: token}, nil
+	}
+
+	var user domain.User
+	if err := s.db.Where("id = ?", identity.UserID).First(&user).Error; err != nil {
+		logger.Error("failed to find user of WeChat identity", "error", err)
 		return nil, errors.New("failed to login with WeChat")
 	}
+	if user.Status != "active" {
+		return nil, errors.New("user account is not active")
+	}
+
+	now := time.Now()
+	if err := s.db.Model(identity).Updates(map[string]interface{}{"last_login_at": now, "updated_at": now}).Error; err != nil {
+		logger.Error("failed to update WeChat identity", "error", err)
+	}
 
-	// Find user by WeChat openid
+	return s.mfa.BeginLogin(ctx, &user, client)
+}
+
+// BindLogin binds the WeChat account of a bind token to the password account
+// logged in with, then continues the login like a password login
+func (s *WeChatService) BindLogin(ctx context.Context, req *WeChatBindLoginRequest) (*LoginResponse, error) {
+	key := "wechat_bind:" + req.BindToken
+	var pending pendingWeChatBinding
+	if err := s.store.Get(key, &pending); err != nil || pending.OpenID == "" {
+		return nil, errors.New("invalid or expired bind token")
+	}
+
+	user, err := authenticate(s.db, s.throttle, req.Username, req.Password, req.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.link(user.ID, pending.OpenID, pending.UnionID); err != nil {
+		return nil, err
+	}
+	if err := s.store.Delete(key); err != nil {
+		logger.Error("failed to delete WeChat bind token", "error", err)
+	}
+
+	return s.mfa.BeginLogin(ctx, user, req.Client)
+}
+
+// BindWeChat binds the WeChat account of a login code to a logged in user
+func (s *WeChatService) BindWeChat(ctx context.Context, userID, code string) error {
+	session, err := s.code2Session(ctx, code)
+	if err != nil {
+		return err
+	}
+
+	return s.link(userID, session.OpenID, session.UnionID)
+}
+
+// UnbindWeChat removes the WeChat binding of a user
+func (s *WeChatService) UnbindWeChat(ctx context.Context, userID string) error {
 	var user domain.User
-	if err := s.db.Where("wechat_openid = ?", openid).First(&user).Error; err != nil {
+	if err := s.db.Where("id = ?", userID).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			// If user doesn't exist, create a new user
-			user, err = s.createWeChatUser(ctx, openid)
-			if err != nil {
-				logger.Error("failed to create WeChat user", "error", err)
-				return nil, errors.New("failed to login with WeChat")
-			}
-		} else {
-			logger.Error("failed to find user by WeChat openid", "error", err)
-			return nil, errors.New("failed to login with WeChat")
+			return errors.New("user not found")
 		}
+		logger.Error("failed to find user", "error", err)
+		return errors.New("failed to unbind WeChat account")
+	}
+	if user.Password == "" {
+		return errors.New("cannot unbind the only login method of the user")
+	}
+
+	result := s.db.Where("user_id = ? AND provider = ?", userID, domain.IdentityProviderWeChat).Delete(&domain.UserIdentity{})
+	if result.Error != nil {
+		logger.Error("failed to delete WeChat identity", "error", result.Error)
+		return errors.New("failed to unbind WeChat account")
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("WeChat account not bound")
 	}
 
-	// Generate access token
-	jwtManager := jwt.NewJWTManager(&jwt.JWTConfig{
-		SecretKey:       "cdk-office-secret-key",
-		AccessTokenExp:  time.Hour * 2,
-		RefreshTokenExp: time.Hour * 24 * 7,
-	})
-	
-	accessToken, err := jwtManager.GenerateAccessToken(user.ID, user.Username, user.Role)
+	return nil
+}
+
+// BindPhoneNumber decrypts the phone number a user shared from the mini-program
+// and stores it on the user
+func (s *WeChatService) BindPhoneNumber(ctx context.Context, userID string, req *WeChatPhoneRequest) (string, error) {
+	session, err := s.code2Session(ctx, req.Code)
 	if err != nil {
-		logger.Error("failed to generate access token", "error", err)
-		return nil, errors.New("failed to login with WeChat")
+		return "", err
 	}
 
-	// Generate refresh token
-	refreshToken, err := jwtManager.GenerateRefreshToken(user.ID)
+	identity, err := s.findIdentity(session)
 	if err != nil {
-		logger.Error("failed to generate refresh token", "error", err)
-		return nil, errors.New("failed to login with WeChat")
+		return "", err
+	}
+	if identity == nil || identity.UserID != userID {
+		return "", errors.New("WeChat account not bound")
+	}
+
+	phone, err := s.client.DecryptPhoneNumber(session.SessionKey, req.EncryptedData, req.IV)
+	if err != nil {
+		logger.Error("failed to decrypt WeChat phone number", "error", err)
+		return "", errors.New("invalid phone number data")
 	}
 
-	return &LoginResponse{
-		User:         &user,
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
-	}, nil
+	if err := s.db.Model(&domain.User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"phone": phone.PhoneNumber, "updated_at": time.Now()}).Error; err != nil {
+		logger.Error("failed to update user phone", "error", err)
+		return "", errors.New("failed to bind phone number")
+	}
+
+	return phone.PhoneNumber, nil
+}
+
+// code2Session exchanges a login code with WeChat
+func (s *WeChatService) code2Session(ctx context.Context, code string) (*wechat.Session, error) {
+	session, err := s.client.Code2Session(ctx, code)
+	if err != nil {
+		if errors.Is(err, wechat.ErrInvalidCode) {
+			return nil, errors.New("invalid WeChat code")
+		}
+		logger.Error("failed to exchange WeChat code", "error", err)
+		return nil, errors.New("failed to login with WeChat")
+	}
+	return session, nil
 }
 
-// exchangeCodeForOpenID exchanges WeChat code for openid (simplified implementation)
-func (s *WeChatService) exchangeCodeForOpenID(code string) (string, error) {
-	// In a real application, you would call WeChat's API to exchange the code
-	// For now, we'll just return a simulated openid
-	// This is where you would make an HTTP request to WeChat's API
-	return "wechat_openid_" + code, nil
+// findIdentity finds the identity of a WeChat account, by openid or else by
+// unionid, recording the openid when only the unionid is known. It returns nil
+// for unbound accounts.
+func (s *WeChatService) findIdentity(session *wechat.Session) (*domain.UserIdentity, error) {
+	var identity domain.UserIdentity
+	err := s.db.Where("provider = ? AND open_id = ?", domain.IdentityProviderWeChat, session.OpenID).First(&identity).Error
+	if err == nil {
+		return &identity, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Error("failed to find WeChat identity", "error", err)
+		return nil, errors.New("failed to login with WeChat")
+	}
+	if session.UnionID == "" {
+		return nil, nil
+	}
+
+	if err := s.db.Where("provider = ? AND union_id = ?", domain.IdentityProviderWeChat, session.UnionID).First(&identity).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		logger.Error("failed to find WeChat identity", "error", err)
+		return nil, errors.New("failed to login with WeChat")
+	}
+
+	linked := &domain.UserIdentity{
+		ID:        utils.GenerateUserIdentityID(),
+		UserID:    identity.UserID,
+		Provider:  domain.IdentityProviderWeChat,
+		OpenID:    session.OpenID,
+		UnionID:   session.UnionID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := s.db.Create(linked).Error; err != nil {
+		logger.Error("failed to create WeChat identity", "error", err)
+		return nil, errors.New("failed to login with WeChat")
+	}
+	return linked, nil
 }
 
-// createWeChatUser creates a new user from WeChat information (simplified implementation)
-func (s *WeChatService) createWeChatUser(ctx context.Context, openid string) (domain.User, error) {
-	user := domain.User{
-		ID:          generateID(),
-		Username:    "wechat_user_" + openid,
-		Email:       "", // WeChat login doesn't provide email by default
-		Phone:       "", // WeChat login doesn't provide phone by default
-		Password:    "", // WeChat login doesn't use password
-		RealName:    "", // WeChat login doesn't provide real name by default
-		IDCard:      "", // WeChat login doesn't provide ID card by default
-		Role:        "user",
-		Status:      "active",
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-	}
-
-	// Save user to database
-	if err := s.db.Create(&user).Error; err != nil {
-		return domain.User{}, err
-	}
-
-	return user, nil
-}
\ No newline at end of file
+// link binds a WeChat account to a user
+func (s *WeChatService) link(userID, openID, unionID string) error {
+	var count int64
+	if err := s.db.Model(&domain.UserIdentity{}).
+		Where("provider = ? AND open_id = ?", domain.IdentityProviderWeChat, openID).Count(&count).Error; err != nil {
+		logger.Error("failed to find WeChat identity", "error", err)
+		return errors.New("failed to bind WeChat account")
+	}
+	if count > 0 {
+		return errors.New("WeChat account is already bound to a user")
+	}
+	if err := s.db.Model(&domain.UserIdentity{}).
+		Where("provider = ? AND user_id = ?", domain.IdentityProviderWeChat, userID).Count(&count).Error; err != nil {
+		logger.Error("failed to find WeChat identity", "error", err)
+		return errors.New("failed to bind WeChat account")
+	}
+	if count > 0 {
+		return errors.New("user is already bound to a WeChat account")
+	}
+
+	identity := &domain.UserIdentity{
+		ID:        utils.GenerateUserIdentityID(),
+		UserID:    userID,
+		Provider:  domain.IdentityProviderWeChat,
+		OpenID:    openID,
+		UnionID:   unionID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := s.db.Create(identity).Error; err != nil {
+		logger.Error("failed to create WeChat identity", "error", err)
+		return errors.New("failed to bind WeChat account")
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cdk-office/internal/auth/domain"
+	"cdk-office/internal/shared/cache"
+	"cdk-office/internal/shared/testutils"
+	"cdk-office/pkg/config"
+	"cdk-office/pkg/wechat"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+var testSessionKey = bytes.Repeat([]byte{0x42}, 16)
+
+// newFakeWeChat serves jscode2session, answering code "<openid>" or
+// "<openid>:<unionid>" with that account
+func newFakeWeChat(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code := r.URL.Query().Get("js_code")
+		if code == "expired" {
+			w.Write([]byte(`{"errcode":40029,"errmsg":"invalid code"}`))
+			return
+		}
+		openID, unionID, _ := strings.Cut(code, ":")
+		json.NewEncoder(w).Encode(map[string]string{
+			"openid":      openID,
+			"unionid":     unionID,
+			"session_key": base64.StdEncoding.EncodeToString(testSessionKey),
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestWeChatService(t *testing.T, db *gorm.DB) *WeChatService {
+	s := NewWeChatServiceWithDB(db, newTestJWTManager(), config.Default())
+	s.client = wechat.NewClient(newFakeWeChat(t).URL, "wx-test", "secret")
+	s.store = cache.NewMemoryCache()
+	s.throttle = newTestThrottle(cache.NewMemoryCache())
+	return s
+}
+
+func TestWeChatBindLogin(t *testing.T) {
+	db := testutils.SetupTestDB()
+	s := newTestWeChatService(t, db)
+	ctx := context.Background()
+	createTestUser(t, db, "alice", "user")
+
+	_, err := s.WeChatLogin(ctx, "expired", nil)
+	assert.EqualError(t, err, "invalid WeChat code")
+
+	// An unknown account has to be bound first
+	resp, err := s.WeChatLogin(ctx, "o-alice:u-alice", nil)
+	require.NoError(t, err)
+	assert.True(t, resp.WeChatBindRequired)
+	assert.Empty(t, resp.AccessToken)
+
+	_, err = s.BindLogin(ctx, &WeChatBindLoginRequest{BindToken: resp.WeChatBindToken, Username: "alice", Password: "wrong"})
+	assert.EqualError(t, err, "invalid username or password")
+	_, err = s.BindLogin(ctx, &WeChatBindLoginRequest{BindToken: "unknown", Username: "alice", Password: "secret123"})
+	assert.EqualError(t, err, "invalid or expired bind token")
+
+	bound, err := s.BindLogin(ctx, &WeChatBindLoginRequest{BindToken: resp.WeChatBindToken, Username: "alice", Password: "secret123"})
+	require.NoError(t, err)
+	assert.Equal(t, "alice", bound.User.ID)
+	assert.NotEmpty(t, bound.AccessToken)
+	_, err = s.BindLogin(ctx, &WeChatBindLoginRequest{BindToken: resp.WeChatBindToken, Username: "alice", Password: "secret123"})
+	assert.EqualError(t, err, "invalid or expired bind token")
+
+	// Later logins go straight through, also from another app of the same open platform account
+	login, err := s.WeChatLogin(ctx, "o-alice", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", login.User.ID)
+	other, err := s.WeChatLogin(ctx, "o-alice-web:u-alice", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", other.User.ID)
+
+	var count int64
+	require.NoError(t, db.Model(&domain.UserIdentity{}).Where("user_id = ?", "alice").Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestWeChatLoginChecks(t *testing.T) {
+	db := testutils.SetupTestDB()
+	s := newTestWeChatService(t, db)
+	ctx := context.Background()
+	createTestUser(t, db, "alice", "admin")
+	createTestUser(t, db, "bob", "user")
+	require.NoError(t, s.BindWeChat(ctx, "alice", "o-alice"))
+	require.NoError(t, s.BindWeChat(ctx, "bob", "o-bob"))
+
+	// WeChat logins are challenged for a second factor like password logins
+	_, err := s.mfa.SetRolePolicy(ctx, "admin", true, "root")
+	require.NoError(t, err)
+	resp, err := s.WeChatLogin(ctx, "o-alice", nil)
+	require.NoError(t, err)
+	assert.True(t, resp.MFARequired)
+	assert.Empty(t, resp.AccessToken)
+
+	require.NoError(t, db.Model(&domain.User{}).Where("id = ?", "bob").Update("status", "disabled").Error)
+	_, err = s.WeChatLogin(ctx, "o-bob", nil)
+	assert.EqualError(t, err, "user account is not active")
+}
+
+func TestWeChatBindAndUnbind(t *testing.T) {
+	db := testutils.SetupTestDB()
+	s := newTestWeChatService(t, db)
+	ctx := context.Background()
+	createTestUser(t, db, "alice", "user")
+	createTestUser(t, db, "bob", "user")
+
+	require.NoError(t, s.BindWeChat(ctx, "alice", "o-alice"))
+	assert.EqualError(t, s.BindWeChat(ctx, "bob", "o-alice"), "WeChat account is already bound to a user")
+	assert.EqualError(t, s.BindWeChat(ctx, "alice", "o-other"), "user is already bound to a WeChat account")
+
+	assert.EqualError(t, s.UnbindWeChat(ctx, "bob"), "WeChat account not bound")
+	require.NoError(t, s.UnbindWeChat(ctx, "alice"))
+
+	resp, err := s.WeChatLogin(ctx, "o-alice", nil)
+	require.NoError(t, err)
+	assert.True(t, resp.WeChatBindRequired)
+	require.NoError(t, s.BindWeChat(ctx, "bob", "o-alice"))
+}
+
+func TestWeChatBindPhoneNumber(t *testing.T) {
+	db := testutils.SetupTestDB()
+	s := newTestWeChatService(t, db)
+	ctx := context.Background()
+	createTestUser(t, db, "alice", "user")
+	createTestUser(t, db, "bob", "user")
+	require.NoError(t, s.BindWeChat(ctx, "alice", "o-alice"))
+
+	plaintext, err := json.Marshal(map[string]interface{}{
+		"phoneNumber":     "13800138000",
+		"purePhoneNumber": "13800138000",
+		"countryCode":     "86",
+		"watermark":       map[string]interface{}{"appid": "wx-test"},
+	})
+	require.NoError(t, err)
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	plaintext = append(plaintext, bytes.Repeat([]byte{byte(padding)}, padding)...)
+	iv := bytes.Repeat([]byte{0x01}, aes.BlockSize)
+	block, err := aes.NewCipher(testSessionKey)
+	require.NoError(t, err)
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, plaintext)
+	req := &WeChatPhoneRequest{
+		Code:          "o-alice",
+		EncryptedData: base64.StdEncoding.EncodeToString(ciphertext),
+		IV:            base64.StdEncoding.EncodeToString(iv),
+	}
+
+	_, err = s.BindPhoneNumber(ctx, "bob", req)
+	assert.EqualError(t, err, "WeChat account not bound")
+
+	phone, err := s.BindPhoneNumber(ctx, "alice", req)
+	require.NoError(t, err)
+	assert.Equal(t, "13800138000", phone)
+	var user domain.User
+	require.NoError(t, db.First(&user, "id = ?", "alice").Error)
+	assert.Equal(t, "13800138000", user.Phone)
+
+	req.IV = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0x02}, aes.BlockSize))
+	_, err = s.BindPhoneNumber(ctx, "alice", req)
+	assert.EqualError(t, err, "invalid phone number data")
+}
@@ -0,0 +1,194 @@
+package contractcert
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+	"time"
+
+	"cdk-office/internal/business/domain"
+	"cdk-office/internal/shared/pdf"
+)
+
+// Page layout in points
+const (
+	pageMargin   = 56
+	footerOffset = 28
+	bodySize     = 10.5
+	smallSize    = 8
+)
+
+var (
+	textColor  = color.RGBA{A: 255}
+	mutedColor = color.RGBA{R: 96, G: 96, B: 96, A: 255}
+)
+
+// Certificate is a completed contract with its signatures and audit trail
+type Certificate struct {
+	Contract  *domain.Contract
+	Signers   []*domain.ContractSigner
+	Events    []*domain.ContractEvent
+	KeyID     string
+	PublicKey string
+}
+
+// Render writes the contract text followed by a certificate of completion
+// listing the signatures and every event of the audit trail. Characters outside
+// Latin-1, such as Chinese, are drawn with the font set by pdf.SetFont.
+func Render(w io.Writer, cert *Certificate) error {
+	contract := cert.Contract
+	out := &pageWriter{}
+
+	out.line(contract.Title, pdf.Bold, 16, 0)
+	out.gap(6)
+	out.muted(fmt.Sprintf("Contract %s", contract.ID), 0)
+	out.gap(12)
+	for _, line := range pdf.Wrap(contract.Content, pdf.Regular, bodySize, out.width(0)) {
+		out.line(line, pdf.Regular, bodySize, 0)
+	}
+
+	out.newPage()
+	out.line("Certificate of completion", pdf.Bold, 16, 0)
+	out.gap(10)
+	for _, field := range [][2]string{
+		{"Contract", contract.ID},
+		{"Title", contract.Title},
+		{"Status", contract.Status},
+		{"Signing order", contract.SigningOrder},
+		{"Sent", formatTime(contract.SentAt)},
+		{"Completed", formatTime(contract.CompletedAt)},
+		{"Content SHA-256", contract.ContentHash},
+		{"Signing key ID", cert.KeyID},
+		{"Public key (Ed25519)", cert.PublicKey},
+	} {
+		out.field(field[0], field[1])
+	}
+
+	out.gap(14)
+	out.line("Signatures", pdf.Bold, 12, 0)
+	out.gap(4)
+	for _, signer := range cert.Signers {
+		out.line(fmt.Sprintf("%d. %s, %s", signer.Position, signer.SignerID, signer.Status), pdf.Regular, bodySize, 0)
+		if signer.Signature != "" {
+			out.muted("Signed at "+signedAt(*signer.SignedAt)+" with key "+signer.SigningKeyID, 12)
+			out.muted("Signature "+signer.Signature, 12)
+		}
+		out.gap(4)
+	}
+	out.gap(4)
+	out.muted("Each signature is the base64 Ed25519 signature of four lines: \""+payloadPrefix+
+		"\", the content SHA-256, the signer and the time of signing as printed above.", 0)
+
+	out.gap(14)
+	out.line("Audit trail", pdf.Bold, 12, 0)
+	out.gap(4)
+	for _, event := range cert.Events {
+		out.line(fmt.Sprintf("%s  %s by %s", formatTime(&event.CreatedAt), event.Type, event.Actor), pdf.Regular, bodySize, 0)
+		details := []string{}
+		if event.IPAddress != "" {
+			details = append(details, "IP "+event.IPAddress)
+		}
+		if event.UserAgent != "" {
+			details = append(details, event.UserAgent)
+		}
+		if len(details) > 0 {
+			out.muted(strings.Join(details, ", "), 12)
+		}
+		out.muted("Content SHA-256 "+event.ContentHash, 12)
+		if event.Detail != "" {
+			out.muted(event.Detail, 12)
+		}
+		out.gap(4)
+	}
+
+	doc := pdf.NewDocument()
+	doc.SetInfo("Title", contract.Title)
+	doc.SetInfo("Subject", "Contract "+contract.ID)
+	for i, canvas := range out.pages {
+		canvas.SetFill(mutedColor)
+		footer := fmt.Sprintf("Contract %s - content SHA-256 %s - page %d of %d", contract.ID, contract.ContentHash, i+1, len(out.pages))
+		canvas.CenteredText(footer, pdf.Regular, 7, pdf.A4Width/2, pdf.A4Height-footerOffset, pdf.A4Width-2*pageMargin)
+		doc.AddPage(pdf.A4Width, pdf.A4Height, canvas)
+	}
+	return doc.Write(w)
+}
+
+// pageWriter lays out lines of text top to bottom on A4 pages
+type pageWriter struct {
+	pages  []*pdf.Canvas
+	canvas *pdf.Canvas
+	y      float64
+}
+
+// newPage starts a page
+func (p *pageWriter) newPage() {
+	p.canvas = pdf.NewCanvas(pdf.A4Height)
+	p.pages = append(p.pages, p.canvas)
+	p.y = pageMargin
+}
+
+// width returns the width available to lines with the given indent
+func (p *pageWriter) width(indent float64) float64 {
+	return pdf.A4Width - 2*pageMargin - indent
+}
+
+// line writes a line of text, starting a page when the current one is full
+func (p *pageWriter) line(text, font string, size, indent float64) {
+	p.write(text, font, size, indent, textColor)
+}
+
+// muted writes small grey text, wrapped so that hashes and signatures are
+// printed in full
+func (p *pageWriter) muted(text string, indent float64) {
+	for _, line := range pdf.Wrap(text, pdf.Regular, smallSize, p.width(indent)) {
+		p.write(line, pdf.Regular, smallSize, indent, mutedColor)
+	}
+}
+
+// field writes a label and its value, wrapping long values such as keys
+func (p *pageWriter) field(label, value string) {
+	const labelWidth = 120
+	lines := pdf.Wrap(value, pdf.Regular, bodySize, p.width(labelWidth))
+	for i, line := range lines {
+		p.advance(bodySize * 1.4)
+		if i == 0 {
+			p.canvas.SetFill(mutedColor)
+			p.canvas.Text(label, pdf.Regular, bodySize, pageMargin, p.y)
+		}
+		p.canvas.SetFill(textColor)
+		p.canvas.Text(line, pdf.Regular, bodySize, pageMargin+labelWidth, p.y)
+	}
+}
+
+// gap adds vertical space
+func (p *pageWriter) gap(space float64) {
+	p.y += space
+}
+
+func (p *pageWriter) write(text, font string, size, indent float64, c color.RGBA) {
+	p.advance(size * 1.4)
+	p.canvas.SetFill(c)
+	p.canvas.Text(pdf.FitText(text, font, size, p.width(indent)), font, size, pageMargin+indent, p.y)
+}
+
+// advance moves to the baseline of the next line, starting a page when needed
+func (p *pageWriter) advance(height float64) {
+	if p.canvas == nil || p.y+height > pdf.A4Height-pageMargin {
+		p.newPage()
+	}
+	p.y += height
+}
+
+// formatTime formats a time in UTC, or a dash when it is not set
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format("2006-01-02 15:04:05 UTC")
+}
+
+// signedAt formats a time of signing as it appears in signed payloads
+func signedAt(t time.Time) string {
+	return t.UTC().Format(payloadTime)
+}
@@ -0,0 +1,113 @@
+package contractcert
+
+import (
+	"bytes"
+	"encoding/base64"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"cdk-office/internal/business/domain"
+	"cdk-office/internal/shared/pdf"
+	"cdk-office/internal/shared/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSigner tests signing and verifying signatures
+func TestSigner(t *testing.T) {
+	seed := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+	signer, err := NewSigner(seed)
+	assert.NoError(t, err)
+	again, err := NewSigner(seed)
+	assert.NoError(t, err)
+	assert.Equal(t, signer.PublicKey(), again.PublicKey())
+	assert.Len(t, signer.KeyID(), 16)
+
+	at := time.Date(2024, 5, 1, 9, 30, 0, 123456789, time.FixedZone("CEST", 2*3600))
+	signature := signer.Sign("abc", "alice", at)
+	assert.True(t, again.Verify("abc", "alice", at.UTC(), signature))
+	assert.False(t, signer.Verify("abd", "alice", at, signature))
+	assert.False(t, signer.Verify("abc", "bob", at, signature))
+	assert.False(t, signer.Verify("abc", "alice", at.Add(time.Millisecond), signature))
+	assert.Equal(t, "cdk-office contract signature v1\nabc\nalice\n2024-05-01T07:30:00.123456Z", string(Payload("abc", "alice", at)))
+
+	other, err := GenerateSigner()
+	assert.NoError(t, err)
+	assert.False(t, other.Verify("abc", "alice", at, signature))
+
+	_, err = NewSigner("c2hvcnQ=")
+	assert.EqualError(t, err, "invalid signing key: must be a base64 32 byte Ed25519 seed")
+}
+
+// TestRender tests rendering a signed contract with its certificate
+func TestRender(t *testing.T) {
+	signer, err := GenerateSigner()
+	assert.NoError(t, err)
+	now := time.Now()
+	contract := &domain.Contract{
+		ID:           "contract_1",
+		Title:        "Supply agreement",
+		Content:      strings.Repeat("The supplier supplies goods to the buyer. ", 400),
+		Status:       domain.ContractCompleted,
+		SigningOrder: domain.SigningOrderParallel,
+		ContentHash:  strings.Repeat("ab", 32),
+		SentAt:       &now,
+		CompletedAt:  &now,
+	}
+	cert := &Certificate{
+		Contract: contract,
+		Signers: []*domain.ContractSigner{{
+			SignerID: "alice", Position: 1, Status: domain.SignerSigned, SignedAt: &now,
+			Signature: signer.Sign(contract.ContentHash, "alice", now), SigningKeyID: signer.KeyID(),
+		}},
+		Events: []*domain.ContractEvent{
+			{Type: domain.ContractEventSigned, Actor: "alice", IPAddress: "10.0.0.1", UserAgent: "browser", ContentHash: contract.ContentHash, CreatedAt: now},
+		},
+		KeyID:     signer.KeyID(),
+		PublicKey: signer.PublicKey(),
+	}
+
+	var out bytes.Buffer
+	assert.NoError(t, Render(&out, cert))
+	file := out.String()
+	assert.True(t, strings.HasPrefix(file, "%PDF-1.4"))
+	assert.Contains(t, file, "/Title (Supply agreement)")
+	// The content takes several pages before the certificate
+	assert.Regexp(t, `/Count ([4-9]|\d\d) `, file)
+}
+
+// TestRenderChinese tests rendering a contract in Chinese with an embedded font
+func TestRenderChinese(t *testing.T) {
+	now := time.Now()
+	cert := &Certificate{
+		Contract: &domain.Contract{
+			ID: "contract_2", Title: "劳动合同", Content: "甲方王芳乙方张伟签署劳动合同书",
+			Status: domain.ContractCompleted, ContentHash: strings.Repeat("ab", 32), CompletedAt: &now,
+		},
+		Signers: []*domain.ContractSigner{{SignerID: "张伟", Position: 1, Status: domain.SignerPending}},
+	}
+
+	var out bytes.Buffer
+	assert.NoError(t, Render(&out, cert))
+	assert.NotContains(t, out.String(), "/Type0")
+
+	font, err := pdf.LoadFont(testutils.CJKFontPath())
+	if !assert.NoError(t, err) {
+		return
+	}
+	pdf.SetFont(font)
+	defer pdf.SetFont(nil)
+
+	out.Reset()
+	assert.NoError(t, Render(&out, cert))
+	file := out.String()
+	assert.Contains(t, file, "/Subtype /Type0")
+	assert.Contains(t, file, "/FontFile2")
+	assert.Contains(t, file, "/Title <FEFF52B352A85408540C>")
+	// Each of the 14 characters is drawn with its glyph in the font
+	widths := regexp.MustCompile(`/W \[(.*?) \]`).FindStringSubmatch(file)
+	if assert.NotNil(t, widths) {
+		assert.Equal(t, 14, strings.Count(widths[1], "["))
+	}
+}
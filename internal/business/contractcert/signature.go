@@ -0,0 +1,85 @@
+// Package contractcert signs contract signatures with an Ed25519 key and renders
+// signed contracts as PDF files with a certificate of completion.
+package contractcert
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// Format of signed payloads
+const (
+	payloadPrefix = "cdk-office contract signature v1" // versions the format
+	payloadTime   = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// Signer signs the signatures of contracts
+type Signer struct {
+	key   ed25519.PrivateKey
+	keyID string
+}
+
+// NewSigner creates a signer from a base64 Ed25519 seed
+func NewSigner(seed string) (*Signer, error) {
+	raw, err := base64.StdEncoding.DecodeString(seed)
+	if err != nil || len(raw) != ed25519.SeedSize {
+		return nil, errors.New("invalid signing key: must be a base64 32 byte Ed25519 seed")
+	}
+	return newSigner(ed25519.NewKeyFromSeed(raw)), nil
+}
+
+// GenerateSigner creates a signer with a random key
+func GenerateSigner() (*Signer, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return newSigner(key), nil
+}
+
+func newSigner(key ed25519.PrivateKey) *Signer {
+	return &Signer{key: key, keyID: KeyID(key.Public().(ed25519.PublicKey))}
+}
+
+// KeyID returns the ID of the signing key
+func (s *Signer) KeyID() string {
+	return s.keyID
+}
+
+// PublicKey returns the base64 public key that verifies the signatures
+func (s *Signer) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(s.key.Public().(ed25519.PublicKey))
+}
+
+// Sign returns the base64 signature of a signer signing content with the given
+// hash at signedAt
+func (s *Signer) Sign(contentHash, signerID string, signedAt time.Time) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, Payload(contentHash, signerID, signedAt)))
+}
+
+// Verify reports whether signature is a valid signature of the key
+func (s *Signer) Verify(contentHash, signerID string, signedAt time.Time, signature string) bool {
+	raw, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(s.key.Public().(ed25519.PublicKey), Payload(contentHash, signerID, signedAt), raw)
+}
+
+// Payload returns the bytes signed for a signature: the content hash, the
+// signer and the time of signing in UTC with microseconds, one per line
+func Payload(contentHash, signerID string, signedAt time.Time) []byte {
+	return []byte(payloadPrefix + "\n" + contentHash + "\n" + signerID + "\n" +
+		signedAt.UTC().Format(payloadTime))
+}
+
+// KeyID returns the ID of a public key: the first 16 hex digits of its SHA-256 hash
+func KeyID(publicKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKey)
+	return hex.EncodeToString(sum[:8])
+}
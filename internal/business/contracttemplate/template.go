@@ -0,0 +1,265 @@
+// Package contracttemplate renders contract templates whose {{placeholders}}
+// are typed fields filled from employees, departments and JSON data.
+package contracttemplate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	employeedomain "cdk-office/internal/employee/domain"
+)
+
+// Field types
+const (
+	TypeText    = "text"
+	TypeNumber  = "number"
+	TypeDate    = "date"
+	TypeBoolean = "boolean"
+)
+
+// placeholder matches a {{field}} in a template. Field names are identifiers
+// joined by dots, such as employee.name.
+var placeholder = regexp.MustCompile(`\{\{\s*([\pL\pN_]+(?:\.[\pL\pN_]+)*)\s*\}\}`)
+
+// fieldName matches a valid field name
+var fieldName = regexp.MustCompile(`^[\pL\pN_]+(?:\.[\pL\pN_]+)*$`)
+
+// Field is a typed placeholder of a template
+type Field struct {
+	Name     string `json:"name"`
+	Label    string `json:"label,omitempty"`
+	Type     string `json:"type"` // text (the default), number, date or boolean
+	Required bool   `json:"required"`
+	Default  string `json:"default,omitempty"`
+}
+
+// Placeholders returns the names of the fields used in a template, in order of
+// first use
+func Placeholders(content string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range placeholder.FindAllStringSubmatch(content, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// Check validates the fields of a template and that every placeholder of the
+// content is declared. Errors start with "invalid template".
+func Check(content string, fields []*Field) error {
+	declared := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		if !fieldName.MatchString(field.Name) {
+			return fmt.Errorf("invalid template: field name %q must be letters, digits and underscores joined by dots", field.Name)
+		}
+		if declared[field.Name] {
+			return fmt.Errorf("invalid template: field %s is declared more than once", field.Name)
+		}
+		declared[field.Name] = true
+		if field.Type == "" {
+			field.Type = TypeText
+		}
+		if field.Type != TypeText && field.Type != TypeNumber && field.Type != TypeDate && field.Type != TypeBoolean {
+			return fmt.Errorf("invalid template: field %s has unknown type %q", field.Name, field.Type)
+		}
+		if field.Default != "" {
+			if _, err := format(field, field.Default); err != nil {
+				return fmt.Errorf("invalid template: default of field %s %s", field.Name, err)
+			}
+		}
+	}
+	for _, name := range Placeholders(content) {
+		if !declared[name] {
+			return fmt.Errorf("invalid template: placeholder {{%s}} is not declared as a field", name)
+		}
+	}
+	return nil
+}
+
+// Render fills the placeholders of a template with values. See Resolve.
+func Render(content string, fields []*Field, values Values) (string, error) {
+	texts, err := Resolve(fields, values)
+	if err != nil {
+		return "", err
+	}
+	return texts.Expand(content), nil
+}
+
+// Texts are the formatted texts of template fields keyed by field name
+type Texts map[string]string
+
+// Resolve formats the values of fields by their type. Missing values take the
+// field default, and every missing required field is reported at once. Errors
+// start with "invalid data".
+func Resolve(fields []*Field, values Values) (Texts, error) {
+	texts := make(Texts, len(fields))
+	var missing []string
+	for _, field := range fields {
+		value, ok := values[field.Name]
+		if !ok || value == nil || value == "" {
+			if field.Default == "" {
+				if field.Required {
+					missing = append(missing, field.Name)
+				}
+				continue
+			}
+			value = field.Default
+		}
+		text, err := format(field, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data: %s %s", field.Name, err)
+		}
+		texts[field.Name] = text
+	}
+	if len(missing) > 0 {
+		return nil, errors.New("invalid data: missing required fields: " + strings.Join(missing, ", "))
+	}
+	return texts, nil
+}
+
+// Expand replaces the placeholders of a template with their texts. Placeholders
+// without a text are removed.
+func (t Texts) Expand(content string) string {
+	return placeholder.ReplaceAllStringFunc(content, func(match string) string {
+		return t[placeholder.FindStringSubmatch(match)[1]]
+	})
+}
+
+// format formats a value as the text of a field of its type
+func format(field *Field, value interface{}) (string, error) {
+	switch field.Type {
+	case TypeNumber:
+		switch v := value.(type) {
+		case json.Number:
+			value = v.String()
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case int:
+			return strconv.Itoa(v), nil
+		}
+		if s, ok := value.(string); ok {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+				return strconv.FormatFloat(f, 'f', -1, 64), nil
+			}
+		}
+		return "", errors.New("must be a number")
+	case TypeDate:
+		switch v := value.(type) {
+		case time.Time:
+			return v.Format("2006-01-02"), nil
+		case string:
+			if t, err := time.Parse("2006-01-02", v); err == nil {
+				return t.Format("2006-01-02"), nil
+			}
+			if t, err := time.Parse(time.RFC3339, v); err == nil {
+				return t.Format("2006-01-02"), nil
+			}
+		}
+		return "", errors.New("must be a date")
+	case TypeBoolean:
+		switch v := value.(type) {
+		case bool:
+			return formatBool(v), nil
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return formatBool(b), nil
+			}
+		}
+		return "", errors.New("must be true or false")
+	default:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case json.Number:
+			return v.String(), nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		case time.Time:
+			return v.Format("2006-01-02"), nil
+		}
+		return "", errors.New("must be text")
+	}
+}
+
+// formatBool formats a boolean field as yes or no
+func formatBool(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// Values are the values of template fields keyed by field name
+type Values map[string]interface{}
+
+// ParseValues reads values from a JSON object. Nested objects are flattened
+// into names joined by dots, so {"company": {"name": "X"}} fills {{company.name}}.
+func ParseValues(data string) (Values, error) {
+	values := Values{}
+	if strings.TrimSpace(data) == "" {
+		return values, nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil {
+		return nil, errors.New("invalid data: must be a JSON object")
+	}
+	values.flatten("", object)
+	return values, nil
+}
+
+func (v Values) flatten(prefix string, object map[string]interface{}) {
+	for key, value := range object {
+		if nested, ok := value.(map[string]interface{}); ok {
+			v.flatten(prefix+key+".", nested)
+			continue
+		}
+		v[prefix+key] = value
+	}
+}
+
+// Merge copies the values of other that are not already set
+func (v Values) Merge(other Values) {
+	for name, value := range other {
+		if _, ok := v[name]; !ok {
+			v[name] = value
+		}
+	}
+}
+
+// EmployeeValues returns the employee.* values of an employee
+func EmployeeValues(employee *employeedomain.Employee) Values {
+	values := Values{
+		"employee.id":       employee.ID,
+		"employee.number":   employee.EmployeeID,
+		"employee.name":     employee.RealName,
+		"employee.gender":   employee.Gender,
+		"employee.position": employee.Position,
+		"employee.status":   employee.Status,
+	}
+	if !employee.BirthDate.IsZero() {
+		values["employee.birth_date"] = employee.BirthDate
+	}
+	if !employee.HireDate.IsZero() {
+		values["employee.hire_date"] = employee.HireDate
+	}
+	return values
+}
+
+// DepartmentValues returns the department.* values of a department
+func DepartmentValues(department *employeedomain.Department) Values {
+	return Values{
+		"department.id":          department.ID,
+		"department.name":        department.Name,
+		"department.description": department.Description,
+	}
+}
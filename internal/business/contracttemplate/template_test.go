@@ -0,0 +1,67 @@
+package contracttemplate
+
+import (
+	"testing"
+	"time"
+
+	employeedomain "cdk-office/internal/employee/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCheck tests validating the fields of a template
+func TestCheck(t *testing.T) {
+	content := "{{ employee.name }} earns {{salary}} from {{employee.hire_date}}."
+	assert.Equal(t, []string{"employee.name", "salary", "employee.hire_date"}, Placeholders(content+" {{salary}}"))
+
+	fields := []*Field{{Name: "employee.name"}, {Name: "salary", Type: TypeNumber}, {Name: "employee.hire_date", Type: TypeDate}}
+	assert.NoError(t, Check(content, fields))
+	assert.Equal(t, TypeText, fields[0].Type)
+
+	assert.EqualError(t, Check(content, fields[:2]), "invalid template: placeholder {{employee.hire_date}} is not declared as a field")
+	assert.EqualError(t, Check("", []*Field{{Name: "a"}, {Name: "a"}}), "invalid template: field a is declared more than once")
+	assert.EqualError(t, Check("", []*Field{{Name: "a", Type: "money"}}), `invalid template: field a has unknown type "money"`)
+	assert.EqualError(t, Check("", []*Field{{Name: "a b"}}), `invalid template: field name "a b" must be letters, digits and underscores joined by dots`)
+	assert.EqualError(t, Check("", []*Field{{Name: "a", Type: TypeNumber, Default: "many"}}), "invalid template: default of field a must be a number")
+}
+
+// TestRender tests filling a template from an employee, a department and JSON data
+func TestRender(t *testing.T) {
+	content := "{{employee.name}} joins {{department.name}} as {{employee.position}} on {{employee.hire_date}} " +
+		"for {{salary}} {{currency}}. Remote: {{remote}}. Signed at {{company.city}}.{{note}}"
+	fields := []*Field{
+		{Name: "employee.name", Required: true},
+		{Name: "employee.position", Required: true},
+		{Name: "employee.hire_date", Type: TypeDate, Required: true},
+		{Name: "department.name", Required: true},
+		{Name: "salary", Type: TypeNumber, Required: true},
+		{Name: "currency", Default: "EUR"},
+		{Name: "remote", Type: TypeBoolean},
+		{Name: "company.city", Required: true},
+		{Name: "note"},
+	}
+	assert.NoError(t, Check(content, fields))
+
+	values, err := ParseValues(`{"salary": 4200.50, "remote": true, "company": {"city": "Berlin"}, "employee": {"position": "Lead"}}`)
+	assert.NoError(t, err)
+	values.Merge(EmployeeValues(&employeedomain.Employee{
+		RealName: "Ada Lovelace",
+		Position: "Engineer",
+		HireDate: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	}))
+	values.Merge(DepartmentValues(&employeedomain.Department{Name: "Research"}))
+
+	text, err := Render(content, fields, values)
+	assert.NoError(t, err)
+	// Data takes precedence over the employee
+	assert.Equal(t, "Ada Lovelace joins Research as Lead on 2024-03-01 for 4200.5 EUR. Remote: yes. Signed at Berlin.", text)
+
+	_, err = Render(content, fields, Values{"employee.name": "Ada", "salary": ""})
+	assert.EqualError(t, err, "invalid data: missing required fields: employee.position, employee.hire_date, department.name, salary, company.city")
+
+	values["salary"] = "a lot"
+	_, err = Render(content, fields, values)
+	assert.EqualError(t, err, "invalid data: salary must be a number")
+
+	_, err = ParseValues(`[1, 2]`)
+	assert.EqualError(t, err, "invalid data: must be a JSON object")
+}
@@ -4,17 +4,113 @@ import (
 	"time"
 )
 
+// Contract statuses. A draft is sent for signing, becomes partially signed with
+// the first signature and completed with the last one. Declining, a passed
+// signer deadline or voiding ends the signing early.
+const (
+	ContractDraft           = "draft"
+	ContractSent            = "sent"
+	ContractPartiallySigned = "partially_signed"
+	ContractCompleted       = "completed"
+	ContractDeclined        = "declined"
+	ContractExpired         = "expired"
+	ContractVoided          = "voided"
+)
+
+// Contract signing orders
+const (
+	SigningOrderParallel   = "parallel"   // signers sign in any order
+	SigningOrderSequential = "sequential" // signers sign in the order they are listed
+)
+
+// Contract signer statuses
+const (
+	SignerPending  = "pending"
+	SignerSigned   = "signed"
+	SignerDeclined = "declined"
+	SignerExpired  = "expired"
+)
+
+// Contract event types
+const (
+	ContractEventCreated   = "created"
+	ContractEventSent      = "sent"
+	ContractEventSigned    = "signed"
+	ContractEventDeclined  = "declined"
+	ContractEventExpired   = "expired"
+	ContractEventVoided    = "voided"
+	ContractEventCompleted = "completed"
+)
+
+// Approval entity type and action of contracts, for approval definitions
+const (
+	ApprovalEntityContract = "contract"
+	ApprovalActionSend     = "send"
+)
+
 // Contract represents a contract in the system
 type Contract struct {
+	ID           string     `json:"id" gorm:"primaryKey"`
+	TeamID       string     `json:"team_id" gorm:"index"`
+	Title        string     `json:"title" gorm:"size:200"`
+	Description  string     `json:"description" gorm:"type:text"`
+	Content      string     `json:"content" gorm:"type:text"`
+	Status       string     `json:"status" gorm:"size:20"`
+	CreatedBy    string     `json:"created_by" gorm:"size:50"`
+	Signers      string     `json:"signers" gorm:"type:jsonb"`
+	SignedBy     string     `json:"signed_by" gorm:"type:jsonb"`
+	SigningOrder string     `json:"signing_order" gorm:"size:20"`
+	ContentHash  string     `json:"content_hash" gorm:"size:64"` // SHA-256 of the content when sent
+	SentAt       *time.Time `json:"sent_at"`
+	CompletedAt  *time.Time `json:"completed_at"`
+	// CertificateDocumentID is the document holding the signed PDF once completed
+	CertificateDocumentID string    `json:"certificate_document_id" gorm:"size:50"`
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+// ContractSigner is a signer of a contract and the state of their signature
+type ContractSigner struct {
+	ID            string     `json:"id" gorm:"primaryKey"`
+	ContractID    string     `json:"contract_id" gorm:"index"`
+	SignerID      string     `json:"signer_id" gorm:"size:50"`
+	Position      int        `json:"position"` // signing order, from 1
+	Status        string     `json:"status" gorm:"size:20"`
+	Deadline      *time.Time `json:"deadline"`
+	SignedAt      *time.Time `json:"signed_at"`
+	Signature     string     `json:"signature" gorm:"size:100"` // base64 Ed25519 signature of the content hash, signer and SignedAt
+	SigningKeyID  string     `json:"signing_key_id" gorm:"size:16"`
+	DeclinedAt    *time.Time `json:"declined_at"`
+	DeclineReason string     `json:"decline_reason" gorm:"type:text"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// ContractEvent is an entry of the audit trail of a contract. Events are only
+// ever inserted, and are kept when a draft contract is deleted.
+type ContractEvent struct {
+	ID          string    `json:"id" gorm:"primaryKey"`
+	ContractID  string    `json:"contract_id" gorm:"index"`
+	Type        string    `json:"type" gorm:"size:20"`
+	Actor       string    `json:"actor" gorm:"size:50"`
+	IPAddress   string    `json:"ip_address" gorm:"size:45"`
+	UserAgent   string    `json:"user_agent" gorm:"size:500"`
+	ContentHash string    `json:"content_hash" gorm:"size:64"` // SHA-256 of the content at the time of the event
+	Detail      string    `json:"detail" gorm:"type:text"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ContractTemplate is reusable contract content whose {{placeholders}} are
+// filled from business data when a contract is generated from it
+type ContractTemplate struct {
 	ID          string    `json:"id" gorm:"primaryKey"`
 	TeamID      string    `json:"team_id" gorm:"index"`
-	Title       string    `json:"title" gorm:"size:200"`
+	Name        string    `json:"name" gorm:"size:100"`
 	Description string    `json:"description" gorm:"type:text"`
+	Title       string    `json:"title" gorm:"size:200"` // title of generated contracts, may hold placeholders
 	Content     string    `json:"content" gorm:"type:text"`
-	Status      string    `json:"status" gorm:"size:20"`
+	Fields      string    `json:"fields" gorm:"type:jsonb"` // typed field definitions of the placeholders
 	CreatedBy   string    `json:"created_by" gorm:"size:50"`
-	Signers     string    `json:"signers" gorm:"type:jsonb"`
-	SignedBy    string    `json:"signed_by" gorm:"type:jsonb"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
-}
\ No newline at end of file
+}
@@ -1,11 +1,17 @@
 package handler
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	approvalservice "cdk-office/internal/approval/service"
 	"cdk-office/internal/business/domain"
 	"cdk-office/internal/business/service"
+	"cdk-office/pkg/config"
 	"github.com/gin-gonic/gin"
 )
 
@@ -16,7 +22,12 @@ type ContractHandlerInterface interface {
 	DeleteContract(c *gin.Context)
 	ListContracts(c *gin.Context)
 	GetContract(c *gin.Context)
+	SendContract(c *gin.Context)
 	SignContract(c *gin.Context)
+	DeclineContract(c *gin.Context)
+	VoidContract(c *gin.Context)
+	GetContractAudit(c *gin.Context)
+	GetContractCertificate(c *gin.Context)
 }
 
 // ContractHandler implements the ContractHandlerInterface
@@ -25,9 +36,18 @@ type ContractHandler struct {
 }
 
 // NewContractHandler creates a new instance of ContractHandler
-func NewContractHandler() *ContractHandler {
+func NewContractHandler(cfg *config.Config) (*ContractHandler, error) {
+	contractService, err := service.NewContractService(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewContractHandlerWithService(contractService), nil
+}
+
+// NewContractHandlerWithService creates a new instance of ContractHandler with a specific contract service
+func NewContractHandlerWithService(contractService service.ContractServiceInterface) *ContractHandler {
 	return &ContractHandler{
-		contractService: service.NewContractService(),
+		contractService: contractService,
 	}
 }
 
@@ -37,8 +57,11 @@ type CreateContractRequest struct {
 	Title       string   `json:"title" binding:"required"`
 	Description string   `json:"description"`
 	Content     string   `json:"content" binding:"required"`
-	CreatedBy   string   `json:"created_by" binding:"required"`
 	Signers     []string `json:"signers" binding:"required"`
+	// SigningOrder is sequential or parallel (the default)
+	SigningOrder string `json:"signing_order"`
+	// Deadlines are the times by which signers must sign, keyed by signer ID
+	Deadlines map[string]time.Time `json:"deadlines"`
 }
 
 // UpdateContractRequest represents the request for updating a contract
@@ -48,9 +71,14 @@ type UpdateContractRequest struct {
 	Content     string `json:"content"`
 }
 
-// SignContractRequest represents the request for signing a contract
-type SignContractRequest struct {
-	SignerID string `json:"signer_id" binding:"required"`
+// DeclineContractRequest represents the request for declining to sign a contract
+type DeclineContractRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// VoidContractRequest represents the request for voiding a contract
+type VoidContractRequest struct {
+	Reason string `json:"reason"`
 }
 
 // ListContractsRequest represents the request for listing contracts
@@ -70,15 +98,17 @@ func (h *ContractHandler) CreateContract(c *gin.Context) {
 
 	// Call service to create contract
 	contract, err := h.contractService.CreateContract(c.Request.Context(), &service.CreateContractRequest{
-		TeamID:      req.TeamID,
-		Title:       req.Title,
-		Description: req.Description,
-		Content:     req.Content,
-		CreatedBy:   req.CreatedBy,
-		Signers:     req.Signers,
+		TeamID:       req.TeamID,
+		Title:        req.Title,
+		Description:  req.Description,
+		Content:      req.Content,
+		CreatedBy:    c.GetString("user_id"),
+		Signers:      req.Signers,
+		SigningOrder: req.SigningOrder,
+		Deadlines:    req.Deadlines,
 	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(contractErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -100,7 +130,7 @@ func (h *ContractHandler) UpdateContract(c *gin.Context) {
 	}
 
 	// Call service to update contract
-	if err := h.contractService.UpdateContract(c.Request.Context(), contractID, &service.UpdateContractRequest{
+	if err := h.contractService.UpdateContract(c.Request.Context(), contractID, signingContext(c), &service.UpdateContractRequest{
 		Title:       req.Title,
 		Description: req.Description,
 		Content:     req.Content,
@@ -113,6 +143,14 @@ func (h *ContractHandler) UpdateContract(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "only draft contracts can be updated"})
 			return
 		}
+		if err.Error() == "contract is awaiting approval" {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err.Error() == "only the creator can update this contract" {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -129,7 +167,7 @@ func (h *ContractHandler) DeleteContract(c *gin.Context) {
 	}
 
 	// Call service to delete contract
-	if err := h.contractService.DeleteContract(c.Request.Context(), contractID); err != nil {
+	if err := h.contractService.DeleteContract(c.Request.Context(), contractID, signingContext(c)); err != nil {
 		if err.Error() == "contract not found" {
 			c.JSON(http.StatusNotFound, gin.H{"error": "contract not found"})
 			return
@@ -138,6 +176,10 @@ func (h *ContractHandler) DeleteContract(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "only draft contracts can be deleted"})
 			return
 		}
+		if err.Error() == "only the creator can delete this contract" {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -215,6 +257,28 @@ func (h *ContractHandler) GetContract(c *gin.Context) {
 	c.JSON(http.StatusOK, contract)
 }
 
+// SendContract handles sending a contract out for signing
+func (h *ContractHandler) SendContract(c *gin.Context) {
+	contractID := c.Param("id")
+	if contractID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "contract id is required"})
+		return
+	}
+
+	// Call service to send contract
+	if err := h.contractService.SendContract(c.Request.Context(), contractID, signingContext(c)); err != nil {
+		var required *approvalservice.ApprovalRequiredError
+		if errors.As(err, &required) {
+			c.JSON(http.StatusAccepted, gin.H{"message": "contract submitted for approval", "approval_request": required.Request})
+			return
+		}
+		c.JSON(contractErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "contract sent successfully"})
+}
+
 // SignContract handles signing a contract
 func (h *ContractHandler) SignContract(c *gin.Context) {
 	contractID := c.Param("id")
@@ -223,35 +287,129 @@ func (h *ContractHandler) SignContract(c *gin.Context) {
 		return
 	}
 
-	var req SignContractRequest
+	// Call service to sign contract
+	if err := h.contractService.SignContract(c.Request.Context(), contractID, signingContext(c)); err != nil {
+		c.JSON(contractErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "contract signed successfully"})
+}
+
+// DeclineContract handles a signer declining to sign a contract
+func (h *ContractHandler) DeclineContract(c *gin.Context) {
+	contractID := c.Param("id")
+	if contractID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "contract id is required"})
+		return
+	}
+
+	var req DeclineContractRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Call service to sign contract
-	if err := h.contractService.SignContract(c.Request.Context(), contractID, req.SignerID); err != nil {
-		if err.Error() == "contract not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "contract not found"})
-			return
-		}
-		if err.Error() == "contract is not in pending status" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "contract is not in pending status"})
-			return
-		}
-		if err.Error() == "user is not authorized to sign this contract" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "user is not authorized to sign this contract"})
-			return
-		}
-		if err.Error() == "user has already signed this contract" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "user has already signed this contract"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// Call service to decline contract
+	if err := h.contractService.DeclineContract(c.Request.Context(), contractID, signingContext(c), req.Reason); err != nil {
+		c.JSON(contractErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "contract signed successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "contract declined successfully"})
+}
+
+// VoidContract handles voiding a contract out for signing
+func (h *ContractHandler) VoidContract(c *gin.Context) {
+	contractID := c.Param("id")
+	if contractID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "contract id is required"})
+		return
+	}
+
+	var req VoidContractRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Call service to void contract
+	if err := h.contractService.VoidContract(c.Request.Context(), contractID, signingContext(c), req.Reason); err != nil {
+		c.JSON(contractErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "contract voided successfully"})
+}
+
+// GetContractAudit handles retrieving the signers and audit trail of a contract
+func (h *ContractHandler) GetContractAudit(c *gin.Context) {
+	contractID := c.Param("id")
+	if contractID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "contract id is required"})
+		return
+	}
+
+	// Call service to get contract audit
+	audit, err := h.contractService.GetContractAudit(c.Request.Context(), contractID)
+	if err != nil {
+		c.JSON(contractErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, audit)
+}
+
+// GetContractCertificate handles downloading the signed PDF of a completed contract
+func (h *ContractHandler) GetContractCertificate(c *gin.Context) {
+	contractID := c.Param("id")
+	if contractID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "contract id is required"})
+		return
+	}
+
+	// Call service to open contract certificate
+	document, file, err := h.contractService.OpenContractCertificate(c.Request.Context(), contractID)
+	if err != nil {
+		c.JSON(contractErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	c.Header("Content-Disposition", `attachment; filename="`+contractID+`-signed.pdf"`)
+	c.Header("X-Content-SHA256", document.ContentHash)
+	c.DataFromReader(http.StatusOK, document.FileSize, "application/pdf", file, nil)
+}
+
+// signingContext identifies the authenticated user of a request as its actor
+// for the audit trail
+func signingContext(c *gin.Context) *service.SigningContext {
+	return &service.SigningContext{
+		Actor:     c.GetString("user_id"),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
+
+// contractErrorStatus maps contract signing errors to HTTP status codes
+func contractErrorStatus(err error) int {
+	switch msg := err.Error(); {
+	case msg == "contract not found":
+		return http.StatusNotFound
+	case msg == "user is not authorized to sign this contract" || msg == "only the creator can send this contract" ||
+		msg == "only the creator can void this contract":
+		return http.StatusForbidden
+	case msg == "only draft contracts can be sent" || msg == "contract is not open for signing" ||
+		msg == "contract has expired" || msg == "user has already signed this contract" ||
+		msg == "waiting for earlier signers to sign" || msg == "contract is not completed" ||
+		msg == "contract content has changed since it was sent" || msg == "approval is already pending" ||
+		strings.HasPrefix(msg, "no approvers found"):
+		return http.StatusConflict
+	case strings.HasPrefix(msg, "invalid signers") || msg == "decline reason is required":
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
 }
 
 // ListContractsResponse represents the response for listing contracts
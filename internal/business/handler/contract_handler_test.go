@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cdk-office/internal/business/domain"
+	"cdk-office/internal/business/service"
+	"cdk-office/internal/shared/testutils"
+	"cdk-office/pkg/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestContractHandlerActor tests that contracts are created, sent, signed and
+// voided as the authenticated user, whatever the request says
+func TestContractHandlerActor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewContractHandlerWithService(service.NewContractServiceWithDB(testutils.SetupTestDB(), &config.Default().Contract))
+	serveAs := func(userID, method, path, body string) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", userID)
+		})
+		router.POST("/contracts", handler.CreateContract)
+		router.POST("/contracts/:id/send", handler.SendContract)
+		router.POST("/contracts/:id/sign", handler.SignContract)
+		router.POST("/contracts/:id/void", handler.VoidContract)
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serveAs("owner", http.MethodPost, "/contracts",
+		`{"team_id":"team_1","title":"Supply agreement","content":"The supplier supplies.","created_by":"mallory","signers":["alice"]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var contract domain.Contract
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &contract))
+	assert.Equal(t, "owner", contract.CreatedBy)
+
+	path := "/contracts/" + contract.ID
+	assert.Equal(t, http.StatusForbidden, serveAs("mallory", http.MethodPost, path+"/send", `{"sender_id":"owner"}`).Code)
+	assert.Equal(t, http.StatusOK, serveAs("owner", http.MethodPost, path+"/send", "").Code)
+
+	assert.Equal(t, http.StatusForbidden, serveAs("mallory", http.MethodPost, path+"/sign", `{"signer_id":"alice"}`).Code)
+	assert.Equal(t, http.StatusForbidden, serveAs("mallory", http.MethodPost, path+"/void", `{"voided_by":"owner"}`).Code)
+	assert.Equal(t, http.StatusOK, serveAs("alice", http.MethodPost, path+"/sign", "").Code)
+}
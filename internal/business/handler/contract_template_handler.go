@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"cdk-office/internal/business/contracttemplate"
+	"cdk-office/internal/business/service"
+	"cdk-office/pkg/config"
+	"github.com/gin-gonic/gin"
+)
+
+// ContractTemplateHandlerInterface defines the interface for contract template handler
+type ContractTemplateHandlerInterface interface {
+	CreateTemplate(c *gin.Context)
+	UpdateTemplate(c *gin.Context)
+	DeleteTemplate(c *gin.Context)
+	ListTemplates(c *gin.Context)
+	GetTemplate(c *gin.Context)
+	GenerateContract(c *gin.Context)
+}
+
+// ContractTemplateHandler implements the ContractTemplateHandlerInterface
+type ContractTemplateHandler struct {
+	templateService service.ContractTemplateServiceInterface
+}
+
+// NewContractTemplateHandler creates a new instance of ContractTemplateHandler
+func NewContractTemplateHandler(cfg *config.Config) (*ContractTemplateHandler, error) {
+	templateService, err := service.NewContractTemplateService(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewContractTemplateHandlerWithService(templateService), nil
+}
+
+// NewContractTemplateHandlerWithService creates a new instance of ContractTemplateHandler with a specific template service
+func NewContractTemplateHandlerWithService(templateService service.ContractTemplateServiceInterface) *ContractTemplateHandler {
+	return &ContractTemplateHandler{
+		templateService: templateService,
+	}
+}
+
+// CreateContractTemplateRequest represents the request for creating a contract template
+type CreateContractTemplateRequest struct {
+	TeamID      string                    `json:"team_id" binding:"required"`
+	Name        string                    `json:"name" binding:"required"`
+	Description string                    `json:"description"`
+	Title       string                    `json:"title" binding:"required"`
+	Content     string                    `json:"content" binding:"required"`
+	Fields      []*contracttemplate.Field `json:"fields"`
+}
+
+// UpdateContractTemplateRequest represents the request for updating a contract template
+type UpdateContractTemplateRequest struct {
+	Name        string                    `json:"name"`
+	Description string                    `json:"description"`
+	Title       string                    `json:"title"`
+	Content     string                    `json:"content"`
+	Fields      []*contracttemplate.Field `json:"fields"`
+}
+
+// GenerateContractRequest represents the request for generating a contract from a template
+type GenerateContractRequest struct {
+	Signers      []string             `json:"signers" binding:"required"`
+	SigningOrder string               `json:"signing_order"`
+	Deadlines    map[string]time.Time `json:"deadlines"`
+	EmployeeID   string               `json:"employee_id"`
+	DepartmentID string               `json:"department_id"`
+	// Data holds further field values, such as {"salary": 4200}
+	Data json.RawMessage `json:"data"`
+}
+
+// CreateTemplate handles creating a new contract template
+func (h *ContractTemplateHandler) CreateTemplate(c *gin.Context) {
+	var req CreateContractTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Call service to create template
+	template, err := h.templateService.CreateTemplate(c.Request.Context(), &service.CreateContractTemplateRequest{
+		TeamID:      req.TeamID,
+		Name:        req.Name,
+		Description: req.Description,
+		Title:       req.Title,
+		Content:     req.Content,
+		Fields:      req.Fields,
+		CreatedBy:   c.GetString("user_id"),
+	})
+	if err != nil {
+		c.JSON(contractTemplateErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// UpdateTemplate handles updating an existing contract template
+func (h *ContractTemplateHandler) UpdateTemplate(c *gin.Context) {
+	templateID := c.Param("id")
+	if templateID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "template id is required"})
+		return
+	}
+
+	var req UpdateContractTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Call service to update template
+	if err := h.templateService.UpdateTemplate(c.Request.Context(), templateID, c.GetString("user_id"), &service.UpdateContractTemplateRequest{
+		Name:        req.Name,
+		Description: req.Description,
+		Title:       req.Title,
+		Content:     req.Content,
+		Fields:      req.Fields,
+	}); err != nil {
+		c.JSON(contractTemplateErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "contract template updated successfully"})
+}
+
+// DeleteTemplate handles deleting a contract template
+func (h *ContractTemplateHandler) DeleteTemplate(c *gin.Context) {
+	templateID := c.Param("id")
+	if templateID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "template id is required"})
+		return
+	}
+
+	// Call service to delete template
+	if err := h.templateService.DeleteTemplate(c.Request.Context(), templateID, c.GetString("user_id")); err != nil {
+		c.JSON(contractTemplateErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "contract template deleted successfully"})
+}
+
+// ListTemplates handles listing the contract templates of a team
+func (h *ContractTemplateHandler) ListTemplates(c *gin.Context) {
+	teamID := c.Query("team_id")
+	if teamID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id is required"})
+		return
+	}
+
+	// Call service to list templates
+	templates, err := h.templateService.ListTemplates(c.Request.Context(), teamID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+// GetTemplate handles retrieving a contract template by ID
+func (h *ContractTemplateHandler) GetTemplate(c *gin.Context) {
+	templateID := c.Param("id")
+	if templateID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "template id is required"})
+		return
+	}
+
+	// Call service to get template
+	template, err := h.templateService.GetTemplate(c.Request.Context(), templateID)
+	if err != nil {
+		c.JSON(contractTemplateErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// GenerateContract handles generating a draft contract from a template
+func (h *ContractTemplateHandler) GenerateContract(c *gin.Context) {
+	templateID := c.Param("id")
+	if templateID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "template id is required"})
+		return
+	}
+
+	var req GenerateContractRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Call service to generate contract
+	contract, err := h.templateService.GenerateContract(c.Request.Context(), templateID, &service.GenerateContractRequest{
+		CreatedBy:    c.GetString("user_id"),
+		Signers:      req.Signers,
+		SigningOrder: req.SigningOrder,
+		Deadlines:    req.Deadlines,
+		EmployeeID:   req.EmployeeID,
+		DepartmentID: req.DepartmentID,
+		Data:         string(req.Data),
+	})
+	if err != nil {
+		c.JSON(contractTemplateErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, contract)
+}
+
+// contractTemplateErrorStatus maps contract template errors to HTTP status codes
+func contractTemplateErrorStatus(err error) int {
+	switch msg := err.Error(); {
+	case msg == "contract template not found" || msg == "employee not found" || msg == "department not found":
+		return http.StatusNotFound
+	case msg == "user is not a member of the team":
+		return http.StatusForbidden
+	case strings.HasPrefix(msg, "invalid template") || strings.HasPrefix(msg, "invalid data") ||
+		strings.HasPrefix(msg, "invalid signers"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
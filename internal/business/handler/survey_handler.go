@@ -3,8 +3,11 @@ package handler
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
+	"cdk-office/internal/app/dataexport"
 	"cdk-office/internal/business/service"
+	"cdk-office/pkg/logger"
 	"github.com/gin-gonic/gin"
 )
 
@@ -19,6 +22,7 @@ type SurveyHandlerInterface interface {
 	CloseSurvey(c *gin.Context)
 	SubmitResponse(c *gin.Context)
 	GetSurveyResponses(c *gin.Context)
+	ExportSurveyResponses(c *gin.Context)
 }
 
 // SurveyHandler implements the SurveyHandlerInterface
@@ -322,6 +326,45 @@ func (h *SurveyHandler) GetSurveyResponses(c *gin.Context) {
 	c.JSON(http.StatusOK, responses)
 }
 
+// ExportSurveyResponses handles exporting the responses of a survey as a CSV,
+// XLSX or NDJSON file, see dataexport.OptionsFromQuery for the filters
+func (h *SurveyHandler) ExportSurveyResponses(c *gin.Context) {
+	surveyID := c.Param("id")
+	if surveyID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "survey id is required"})
+		return
+	}
+
+	opts, err := dataexport.OptionsFromQuery(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Call service to prepare the export
+	export, err := h.surveyService.OpenResponsesExport(c.Request.Context(), surveyID, opts)
+	if err != nil {
+		if err.Error() == "survey not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "survey not found"})
+			return
+		}
+		if strings.HasPrefix(err.Error(), "invalid export") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+export.FileName(surveyID)+`"`)
+	c.Header("Content-Type", export.ContentType())
+	c.Status(http.StatusOK)
+	if err := export.Stream(c.Writer); err != nil {
+		logger.Error("failed to stream survey responses", "error", err, "survey_id", surveyID)
+		c.Abort()
+	}
+}
+
 // ListSurveysResponse represents the response for listing surveys
 type ListSurveysResponse struct {
 	Items []*service.Survey `json:"items"`
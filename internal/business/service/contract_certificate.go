@@ -0,0 +1,107 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+
+	"cdk-office/internal/business/contractcert"
+	"cdk-office/internal/business/domain"
+	docdomain "cdk-office/internal/document/domain"
+	docservice "cdk-office/internal/document/service"
+	"cdk-office/pkg/logger"
+)
+
+// OpenContractCertificate opens the signed PDF of a completed contract, issuing
+// it first if it has not been stored yet
+func (s *ContractService) OpenContractCertificate(ctx context.Context, contractID string) (*docdomain.Document, io.ReadCloser, error) {
+	contract, err := s.findContract(contractID, "failed to get contract certificate")
+	if err != nil {
+		return nil, nil, err
+	}
+	if contract.Status != domain.ContractCompleted {
+		return nil, nil, errors.New("contract is not completed")
+	}
+	if s.documentService == nil {
+		return nil, nil, errors.New("failed to get contract certificate")
+	}
+
+	documentID := contract.CertificateDocumentID
+	if documentID == "" {
+		if documentID, err = s.issueCertificate(ctx, contractID); err != nil {
+			logger.Error("failed to issue contract certificate", "contract_id", contractID, "error", err)
+			return nil, nil, errors.New("failed to get contract certificate")
+		}
+	}
+
+	document, err := s.documentService.GetDocument(ctx, documentID)
+	if err != nil {
+		logger.Error("failed to find contract certificate", "contract_id", contractID, "error", err)
+		return nil, nil, errors.New("failed to get contract certificate")
+	}
+	file, err := s.storageService.GetFile(ctx, document.FilePath)
+	if err != nil {
+		logger.Error("failed to open contract certificate", "contract_id", contractID, "error", err)
+		return nil, nil, errors.New("failed to get contract certificate")
+	}
+	return document, file, nil
+}
+
+// issueCertificate renders the signed PDF of a completed contract, stores it as
+// a document of the contract's team and returns the document ID. A certificate
+// issued concurrently by another request wins.
+func (s *ContractService) issueCertificate(ctx context.Context, contractID string) (string, error) {
+	contract, err := s.findContract(contractID, "failed to issue contract certificate")
+	if err != nil {
+		return "", err
+	}
+	if contract.CertificateDocumentID != "" {
+		return contract.CertificateDocumentID, nil
+	}
+
+	cert := &contractcert.Certificate{Contract: contract, KeyID: s.signer.KeyID(), PublicKey: s.signer.PublicKey()}
+	if err := s.db.Where("contract_id = ?", contractID).Order("position asc").Find(&cert.Signers).Error; err != nil {
+		return "", err
+	}
+	if err := s.db.Where("contract_id = ?", contractID).Order("created_at asc").Find(&cert.Events).Error; err != nil {
+		return "", err
+	}
+	var file bytes.Buffer
+	if err := contractcert.Render(&file, cert); err != nil {
+		return "", err
+	}
+
+	stored, err := s.storageService.SaveFile(ctx, &file)
+	if err != nil {
+		return "", err
+	}
+	document, err := s.documentService.Upload(ctx, &docservice.UploadRequest{
+		Title:       contract.Title + " (signed)",
+		Description: "Signed contract " + contract.ID + " with its certificate of completion",
+		FilePath:    stored.Key,
+		FileSize:    stored.Size,
+		MimeType:    "application/pdf",
+		OwnerID:     contract.CreatedBy,
+		TeamID:      contract.TeamID,
+		Tags:        `["contract"]`,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	result := s.db.Model(&domain.Contract{}).Where("id = ? AND certificate_document_id = ?", contractID, "").
+		Update("certificate_document_id", document.ID)
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		s.documentService.DeleteDocument(ctx, document.ID)
+		contract, err = s.findContract(contractID, "failed to issue contract certificate")
+		if err != nil {
+			return "", err
+		}
+		return contract.CertificateDocumentID, nil
+	}
+	return document.ID, nil
+}
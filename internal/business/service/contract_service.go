@@ -4,11 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"time"
 
+	approvalservice "cdk-office/internal/approval/service"
+	"cdk-office/internal/business/contractcert"
 	"cdk-office/internal/business/domain"
+	docdomain "cdk-office/internal/document/domain"
+	docservice "cdk-office/internal/document/service"
 	"cdk-office/internal/shared/database"
 	"cdk-office/internal/shared/utils"
+	"cdk-office/pkg/config"
 	"cdk-office/pkg/logger"
 	"gorm.io/gorm"
 )
@@ -16,25 +22,86 @@ import (
 // ContractServiceInterface defines the interface for contract service
 type ContractServiceInterface interface {
 	CreateContract(ctx context.Context, req *CreateContractRequest) (*domain.Contract, error)
-	UpdateContract(ctx context.Context, contractID string, req *UpdateContractRequest) error
-	DeleteContract(ctx context.Context, contractID string) error
+	UpdateContract(ctx context.Context, contractID string, sc *SigningContext, req *UpdateContractRequest) error
+	DeleteContract(ctx context.Context, contractID string, sc *SigningContext) error
 	ListContracts(ctx context.Context, teamID string, page, size int) ([]*domain.Contract, int64, error)
 	GetContract(ctx context.Context, contractID string) (*domain.Contract, error)
-	SignContract(ctx context.Context, contractID string, signerID string) error
+	SendContract(ctx context.Context, contractID string, sc *SigningContext) error
+	SignContract(ctx context.Context, contractID string, sc *SigningContext) error
+	DeclineContract(ctx context.Context, contractID string, sc *SigningContext, reason string) error
+	VoidContract(ctx context.Context, contractID string, sc *SigningContext, reason string) error
+	GetContractAudit(ctx context.Context, contractID string) (*ContractAudit, error)
+	OpenContractCertificate(ctx context.Context, contractID string) (*docdomain.Document, io.ReadCloser, error)
 }
 
 // ContractService implements the ContractServiceInterface
 type ContractService struct {
-	db *gorm.DB
+	db              *gorm.DB
+	signer          *contractcert.Signer
+	storageService  docservice.StorageServiceInterface
+	documentService docservice.DocumentServiceInterface
+	approvals       approvalservice.ApprovalServiceInterface
 }
 
-// NewContractService creates a new instance of ContractService
-func NewContractService() *ContractService {
+// NewContractService creates a new instance of ContractService that stores
+// certificates of completion as documents
+func NewContractService(cfg *config.Config) (*ContractService, error) {
+	storageService, err := docservice.NewStorageService(&cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	documentService, err := docservice.NewDocumentService(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewContractServiceWithStorage(database.GetDB(), &cfg.Contract, storageService, documentService), nil
+}
+
+// NewContractServiceWithDB creates a new instance of ContractService with a specific database connection.
+// It does not issue certificates of completion.
+func NewContractServiceWithDB(db *gorm.DB, cfg *config.ContractConfig) *ContractService {
 	return &ContractService{
-		db: database.GetDB(),
+		db:     db,
+		signer: newContractSigner(cfg),
 	}
 }
 
+// NewContractServiceWithStorage creates a new instance of ContractService with a specific database connection
+// and the services storing certificates of completion
+func NewContractServiceWithStorage(db *gorm.DB, cfg *config.ContractConfig, storageService docservice.StorageServiceInterface, documentService docservice.DocumentServiceInterface) *ContractService {
+	s := NewContractServiceWithDB(db, cfg)
+	s.storageService = storageService
+	s.documentService = documentService
+	return s
+}
+
+// UseApprovals makes sending contracts require approval in teams with an
+// approval definition for it, and sends contracts once approved
+func (s *ContractService) UseApprovals(approvals approvalservice.ApprovalServiceInterface) {
+	s.approvals = approvals
+	approvals.RegisterHook(domain.ApprovalEntityContract, approvalservice.HookFuncs{OnApproved: s.sendApprovedContract})
+}
+
+// newContractSigner loads the configured signing key. Without one, signatures
+// are signed with a random key and can only be verified until the next restart.
+func newContractSigner(cfg *config.ContractConfig) *contractcert.Signer {
+	if seed := cfg.SigningKey; seed != "" {
+		signer, err := contractcert.NewSigner(seed)
+		if err == nil {
+			return signer
+		}
+		logger.Error("failed to load contract signing key", "error", err)
+	} else {
+		logger.Warn("contract signing key is not configured, using a temporary key")
+	}
+
+	signer, err := contractcert.GenerateSigner()
+	if err != nil {
+		panic(err)
+	}
+	return signer
+}
+
 // CreateContractRequest represents the request for creating a contract
 type CreateContractRequest struct {
 	TeamID      string   `json:"team_id" binding:"required"`
@@ -43,6 +110,10 @@ type CreateContractRequest struct {
 	Content     string   `json:"content" binding:"required"`
 	CreatedBy   string   `json:"created_by" binding:"required"`
 	Signers     []string `json:"signers" binding:"required"`
+	// SigningOrder is sequential or parallel (the default)
+	SigningOrder string `json:"signing_order"`
+	// Deadlines are the times by which signers must sign, keyed by signer ID
+	Deadlines map[string]time.Time `json:"deadlines"`
 }
 
 // UpdateContractRequest represents the request for updating a contract
@@ -54,21 +125,51 @@ type UpdateContractRequest struct {
 
 // CreateContract creates a new contract
 func (s *ContractService) CreateContract(ctx context.Context, req *CreateContractRequest) (*domain.Contract, error) {
+	signingOrder := req.SigningOrder
+	if signingOrder == "" {
+		signingOrder = domain.SigningOrderParallel
+	}
+	if signingOrder != domain.SigningOrderParallel && signingOrder != domain.SigningOrderSequential {
+		return nil, errors.New("invalid signers: signing order must be sequential or parallel")
+	}
+	signers, err := contractSigners(req.Signers, req.Deadlines)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create new contract
+	now := time.Now()
 	contract := &domain.Contract{
-		ID:          utils.GenerateContractID(),
-		Title:       req.Title,
-		Description: req.Description,
-		Content:     req.Content,
-		Status:      "draft",
-		CreatedBy:   req.CreatedBy,
-		TeamID:      req.TeamID,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		ID:           utils.GenerateContractID(),
+		Title:        req.Title,
+		Description:  req.Description,
+		Content:      req.Content,
+		Status:       domain.ContractDraft,
+		CreatedBy:    req.CreatedBy,
+		TeamID:       req.TeamID,
+		Signers:      convertStringSliceToJSON(req.Signers),
+		SignedBy:     convertStringSliceToJSON([]string{}),
+		SigningOrder: signingOrder,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	for _, signer := range signers {
+		signer.ContractID = contract.ID
+		signer.CreatedAt = now
+		signer.UpdatedAt = now
 	}
 
-	// Save contract to database
-	if err := s.db.Create(contract).Error; err != nil {
+	// Save contract, signers and creation event to database
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(contract).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&signers).Error; err != nil {
+			return err
+		}
+		return recordContractEvent(tx, contract, domain.ContractEventCreated, &SigningContext{Actor: req.CreatedBy}, "")
+	})
+	if err != nil {
 		logger.Error("failed to create contract", "error", err)
 		return nil, errors.New("failed to create contract")
 	}
@@ -76,8 +177,8 @@ func (s *ContractService) CreateContract(ctx context.Context, req *CreateContrac
 	return contract, nil
 }
 
-// UpdateContract updates an existing contract
-func (s *ContractService) UpdateContract(ctx context.Context, contractID string, req *UpdateContractRequest) error {
+// UpdateContract updates the given fields of a draft contract on behalf of its creator
+func (s *ContractService) UpdateContract(ctx context.Context, contractID string, sc *SigningContext, req *UpdateContractRequest) error {
 	// Find contract by ID
 	var contract domain.Contract
 	if err := s.db.Where("id = ?", contractID).First(&contract).Error; err != nil {
@@ -89,36 +190,49 @@ func (s *ContractService) UpdateContract(ctx context.Context, contractID string,
 	}
 
 	// Check if contract is in draft status
-	if contract.Status != "draft" {
+	if contract.Status != domain.ContractDraft {
 		return errors.New("only draft contracts can be updated")
 	}
+	if sc.Actor != contract.CreatedBy {
+		return errors.New("only the creator can update this contract")
+	}
 
-	// Update contract fields
+	// The approved content is what gets sent, so it cannot change while awaiting approval
+	pending, err := s.awaitingApproval(contract.ID)
+	if err != nil {
+		logger.Error("failed to find contract approvals", "error", err)
+		return errors.New("failed to update contract")
+	}
+	if pending {
+		return errors.New("contract is awaiting approval")
+	}
+
+	// Update only the given fields, and only while the contract is still a draft,
+	// so that a concurrent send is not reverted
+	updates := map[string]interface{}{"updated_at": time.Now()}
 	if req.Title != "" {
-		contract.Title = req.Title
+		updates["title"] = req.Title
 	}
-	
 	if req.Description != "" {
-		contract.Description = req.Description
+		updates["description"] = req.Description
 	}
-	
 	if req.Content != "" {
-		contract.Content = req.Content
+		updates["content"] = req.Content
 	}
-	
-	contract.UpdatedAt = time.Now()
-
-	// Save updated contract to database
-	if err := s.db.Save(&contract).Error; err != nil {
-		logger.Error("failed to update contract", "error", err)
+	result := s.db.Model(&domain.Contract{}).Where("id = ? AND status = ?", contract.ID, domain.ContractDraft).Updates(updates)
+	if result.Error != nil {
+		logger.Error("failed to update contract", "error", result.Error)
 		return errors.New("failed to update contract")
 	}
+	if result.RowsAffected == 0 {
+		return errors.New("only draft contracts can be updated")
+	}
 
 	return nil
 }
 
-// DeleteContract deletes a contract
-func (s *ContractService) DeleteContract(ctx context.Context, contractID string) error {
+// DeleteContract deletes a draft contract on behalf of its creator
+func (s *ContractService) DeleteContract(ctx context.Context, contractID string, sc *SigningContext) error {
 	// Find contract by ID
 	var contract domain.Contract
 	if err := s.db.Where("id = ?", contractID).First(&contract).Error; err != nil {
@@ -130,17 +244,30 @@ func (s *ContractService) DeleteContract(ctx context.Context, contractID string)
 	}
 
 	// Check if contract is in draft status
-	if contract.Status != "draft" {
+	if contract.Status != domain.ContractDraft {
 		return errors.New("only draft contracts can be deleted")
 	}
-
-	// Delete contract from database
-	if err := s.db.Delete(&contract).Error; err != nil {
-		logger.Error("failed to delete contract", "error", err)
-		return errors.New("failed to delete contract")
+	if sc.Actor != contract.CreatedBy {
+		return errors.New("only the creator can delete this contract")
 	}
 
-	return nil
+	// Delete contract and its signers from database, keeping the audit trail.
+	// The contract is only deleted while it is still a draft.
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND status = ?", contract.ID, domain.ContractDraft).Delete(&domain.Contract{})
+		if result.Error != nil {
+			logger.Error("failed to delete contract", "error", result.Error)
+			return errors.New("failed to delete contract")
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("only draft contracts can be deleted")
+		}
+		if err := tx.Where("contract_id = ?", contract.ID).Delete(&domain.ContractSigner{}).Error; err != nil {
+			logger.Error("failed to delete contract signers", "error", err)
+			return errors.New("failed to delete contract")
+		}
+		return nil
+	})
 }
 
 // ListContracts lists contracts with pagination
@@ -191,75 +318,6 @@ func (s *ContractService) GetContract(ctx context.Context, contractID string) (*
 	return &contract, nil
 }
 
-// SignContract signs a contract
-func (s *ContractService) SignContract(ctx context.Context, contractID string, signerID string) error {
-	// Find contract by ID
-	var contract domain.Contract
-	if err := s.db.Where("id = ?", contractID).First(&contract).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New("contract not found")
-		}
-		logger.Error("failed to find contract", "error", err)
-		return errors.New("failed to sign contract")
-	}
-
-	// Check if contract is in pending status
-	if contract.Status != "pending" {
-		return errors.New("contract is not in pending status")
-	}
-
-	// Check if signer is in the signers list
-	signers, err := convertJSONToStringSlice(contract.Signers)
-	if err != nil {
-		logger.Error("failed to parse signers", "error", err)
-		return errors.New("failed to sign contract")
-	}
-
-	isSigner := false
-	for _, s := range signers {
-		if s == signerID {
-			isSigner = true
-			break
-		}
-	}
-
-	if !isSigner {
-		return errors.New("user is not authorized to sign this contract")
-	}
-
-	// Check if signer has already signed
-	signedBy, err := convertJSONToStringSlice(contract.SignedBy)
-	if err != nil {
-		logger.Error("failed to parse signed by", "error", err)
-		return errors.New("failed to sign contract")
-	}
-
-	for _, s := range signedBy {
-		if s == signerID {
-			return errors.New("user has already signed this contract")
-		}
-	}
-
-	// Add signer to signed by list
-	signedBy = append(signedBy, signerID)
-	contract.SignedBy = convertStringSliceToJSON(signedBy)
-
-	// Check if all signers have signed
-	if len(signedBy) == len(signers) {
-		contract.Status = "completed"
-	}
-
-	contract.UpdatedAt = time.Now()
-
-	// Save updated contract to database
-	if err := s.db.Save(&contract).Error; err != nil {
-		logger.Error("failed to update contract", "error", err)
-		return errors.New("failed to sign contract")
-	}
-
-	return nil
-}
-
 // convertStringSliceToJSON converts a string slice to JSON string
 func convertStringSliceToJSON(slice []string) string {
 	// Convert string slice to JSON
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	approvaldomain "cdk-office/internal/approval/domain"
+	approvalservice "cdk-office/internal/approval/service"
+	"cdk-office/internal/business/domain"
+	docservice "cdk-office/internal/document/service"
+	"cdk-office/internal/document/storage"
+	"cdk-office/internal/shared/testutils"
+	"cdk-office/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// createTestContract creates a contract signed by the given signers
+func createTestContract(t *testing.T, s *ContractService, order string, deadlines map[string]time.Time, signers ...string) *domain.Contract {
+	contract, err := s.CreateContract(context.Background(), &CreateContractRequest{
+		TeamID:       "team_1",
+		Title:        "Supply agreement",
+		Content:      "The supplier supplies.",
+		CreatedBy:    "owner",
+		Signers:      signers,
+		SigningOrder: order,
+		Deadlines:    deadlines,
+	})
+	assert.NoError(t, err)
+	return contract
+}
+
+// TestContractSigning tests the signing workflow of a contract
+func TestContractSigning(t *testing.T) {
+	testDB := testutils.SetupTestDB()
+	s := NewContractServiceWithDB(testDB, &config.Default().Contract)
+	ctx := context.Background()
+	owner := &SigningContext{Actor: "owner", IPAddress: "10.0.0.1", UserAgent: "test"}
+
+	t.Run("Sequential", func(t *testing.T) {
+		contract := createTestContract(t, s, domain.SigningOrderSequential, nil, "alice", "bob")
+		assert.Equal(t, domain.ContractDraft, contract.Status)
+
+		assert.EqualError(t, s.SignContract(ctx, contract.ID, &SigningContext{Actor: "alice"}), "contract is not open for signing")
+		assert.EqualError(t, s.SendContract(ctx, contract.ID, &SigningContext{Actor: "alice"}), "only the creator can send this contract")
+		assert.NoError(t, s.SendContract(ctx, contract.ID, owner))
+		assert.EqualError(t, s.UpdateContract(ctx, contract.ID, owner, &UpdateContractRequest{Content: "Changed"}), "only draft contracts can be updated")
+
+		assert.EqualError(t, s.SignContract(ctx, contract.ID, &SigningContext{Actor: "bob"}), "waiting for earlier signers to sign")
+		assert.EqualError(t, s.SignContract(ctx, contract.ID, &SigningContext{Actor: "mallory"}), "user is not authorized to sign this contract")
+		assert.NoError(t, s.SignContract(ctx, contract.ID, &SigningContext{Actor: "alice", IPAddress: "10.0.0.2", UserAgent: "browser"}))
+		assert.EqualError(t, s.SignContract(ctx, contract.ID, &SigningContext{Actor: "alice"}), "user has already signed this contract")
+
+		signed, err := s.GetContract(ctx, contract.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.ContractPartiallySigned, signed.Status)
+
+		assert.NoError(t, s.SignContract(ctx, contract.ID, &SigningContext{Actor: "bob"}))
+		audit, err := s.GetContractAudit(ctx, contract.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.ContractCompleted, audit.Contract.Status)
+		assert.Equal(t, `["alice","bob"]`, audit.Contract.SignedBy)
+		assert.NotNil(t, audit.Contract.CompletedAt)
+		assert.True(t, audit.ContentIntact)
+		assert.True(t, audit.SignaturesValid)
+
+		var types []string
+		for _, event := range audit.Events {
+			types = append(types, event.Type)
+		}
+		assert.Equal(t, []string{"created", "sent", "signed", "signed", "completed"}, types)
+		assert.Equal(t, "alice", audit.Events[2].Actor)
+		assert.Equal(t, "10.0.0.2", audit.Events[2].IPAddress)
+		assert.Equal(t, "browser", audit.Events[2].UserAgent)
+		assert.Equal(t, audit.Contract.ContentHash, audit.Events[2].ContentHash)
+		assert.Len(t, audit.Contract.ContentHash, 64)
+		for _, signer := range audit.Signers {
+			assert.Equal(t, domain.SignerSigned, signer.Status)
+			assert.NotEmpty(t, signer.Signature)
+			assert.Equal(t, audit.SigningKeyID, signer.SigningKeyID)
+		}
+
+		// Test a forged signature fails verification
+		assert.NoError(t, testDB.Model(&domain.ContractSigner{}).Where("contract_id = ? AND signer_id = ?", contract.ID, "bob").
+			Update("signer_id", "eve").Error)
+		audit, err = s.GetContractAudit(ctx, contract.ID)
+		assert.NoError(t, err)
+		assert.False(t, audit.SignaturesValid)
+	})
+
+	t.Run("Tampered", func(t *testing.T) {
+		contract := createTestContract(t, s, "", nil, "alice")
+		assert.NoError(t, s.SendContract(ctx, contract.ID, owner))
+		assert.NoError(t, testDB.Model(&domain.Contract{}).Where("id = ?", contract.ID).Update("content", "Changed").Error)
+
+		assert.EqualError(t, s.SignContract(ctx, contract.ID, &SigningContext{Actor: "alice"}), "contract content has changed since it was sent")
+		audit, err := s.GetContractAudit(ctx, contract.ID)
+		assert.NoError(t, err)
+		assert.False(t, audit.ContentIntact)
+		assert.Equal(t, domain.ContractSent, audit.Contract.Status)
+	})
+
+	t.Run("Decline", func(t *testing.T) {
+		contract := createTestContract(t, s, "", nil, "alice", "bob")
+		assert.NoError(t, s.SendContract(ctx, contract.ID, owner))
+
+		// Parallel signers sign in any order
+		assert.NoError(t, s.SignContract(ctx, contract.ID, &SigningContext{Actor: "bob"}))
+		assert.EqualError(t, s.DeclineContract(ctx, contract.ID, &SigningContext{Actor: "alice"}, " "), "decline reason is required")
+		assert.NoError(t, s.DeclineContract(ctx, contract.ID, &SigningContext{Actor: "alice"}, "Wrong amount"))
+		assert.EqualError(t, s.SignContract(ctx, contract.ID, &SigningContext{Actor: "alice"}), "contract is not open for signing")
+
+		audit, err := s.GetContractAudit(ctx, contract.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.ContractDeclined, audit.Contract.Status)
+		assert.Equal(t, domain.SignerDeclined, audit.Signers[0].Status)
+		assert.Equal(t, "Wrong amount", audit.Signers[0].DeclineReason)
+		assert.Equal(t, "Wrong amount", audit.Events[len(audit.Events)-1].Detail)
+	})
+
+	t.Run("Expire", func(t *testing.T) {
+		contract := createTestContract(t, s, "", map[string]time.Time{"bob": time.Now().Add(time.Hour)}, "alice", "bob")
+		assert.NoError(t, s.SendContract(ctx, contract.ID, owner))
+		assert.NoError(t, testDB.Model(&domain.ContractSigner{}).Where("contract_id = ? AND signer_id = ?", contract.ID, "bob").
+			Update("deadline", time.Now().Add(-time.Minute)).Error)
+
+		expired, err := s.ExpireOverdueContracts(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, expired)
+		assert.EqualError(t, s.SignContract(ctx, contract.ID, &SigningContext{Actor: "alice"}), "contract is not open for signing")
+
+		audit, err := s.GetContractAudit(ctx, contract.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.ContractExpired, audit.Contract.Status)
+		assert.Equal(t, domain.SignerExpired, audit.Signers[1].Status)
+		assert.Equal(t, "expired", audit.Events[len(audit.Events)-1].Type)
+	})
+
+	t.Run("Void", func(t *testing.T) {
+		contract := createTestContract(t, s, "", nil, "alice")
+		assert.NoError(t, s.SendContract(ctx, contract.ID, owner))
+		assert.EqualError(t, s.VoidContract(ctx, contract.ID, &SigningContext{Actor: "alice"}, ""), "only the creator can void this contract")
+		assert.NoError(t, s.VoidContract(ctx, contract.ID, owner, "Superseded"))
+
+		voided, err := s.GetContract(ctx, contract.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.ContractVoided, voided.Status)
+	})
+
+	t.Run("Draft", func(t *testing.T) {
+		contract := createTestContract(t, s, "", nil, "alice")
+		alice := &SigningContext{Actor: "alice"}
+		assert.EqualError(t, s.UpdateContract(ctx, contract.ID, alice, &UpdateContractRequest{Content: "Changed"}),
+			"only the creator can update this contract")
+		assert.EqualError(t, s.DeleteContract(ctx, contract.ID, alice), "only the creator can delete this contract")
+
+		assert.NoError(t, s.UpdateContract(ctx, contract.ID, owner, &UpdateContractRequest{Title: "Renamed"}))
+		updated, err := s.GetContract(ctx, contract.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Renamed", updated.Title)
+		assert.Equal(t, contract.Content, updated.Content)
+
+		assert.NoError(t, s.DeleteContract(ctx, contract.ID, owner))
+		_, err = s.GetContract(ctx, contract.ID)
+		assert.EqualError(t, err, "contract not found")
+	})
+
+	t.Run("InvalidSigners", func(t *testing.T) {
+		for _, req := range []*CreateContractRequest{
+			{Signers: []string{}},
+			{Signers: []string{"alice", "alice"}},
+			{Signers: []string{"alice"}, SigningOrder: "random"},
+			{Signers: []string{"alice"}, Deadlines: map[string]time.Time{"bob": time.Now()}},
+		} {
+			req.TeamID, req.Title, req.Content, req.CreatedBy = "team_1", "Contract", "Content", "owner"
+			_, err := s.CreateContract(ctx, req)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), "invalid signers")
+			}
+		}
+
+		// Deadlines must still be ahead when the contract is sent
+		contract := createTestContract(t, s, "", map[string]time.Time{"alice": time.Now().Add(-time.Hour)}, "alice")
+		assert.EqualError(t, s.SendContract(ctx, contract.ID, owner), "invalid signers: the deadline of alice has passed")
+	})
+}
+
+// TestContractSendApproval tests sending a contract in a team requiring approval
+func TestContractSendApproval(t *testing.T) {
+	testDB := testutils.SetupTestDB()
+	s := NewContractServiceWithDB(testDB, &config.Default().Contract)
+	approvals := approvalservice.NewApprovalServiceWithDB(testDB)
+	s.UseApprovals(approvals)
+	ctx := context.Background()
+
+	_, err := approvals.CreateDefinition(ctx, &approvalservice.CreateDefinitionRequest{
+		TeamID: "team_1", EntityType: domain.ApprovalEntityContract, Action: domain.ApprovalActionSend, IsActive: true,
+		Steps: []approvaldomain.ApprovalStep{{Approvers: []approvaldomain.ApproverRule{{Type: approvaldomain.ApproverUser, UserID: "legal"}}}},
+	})
+	assert.NoError(t, err)
+
+	contract := createTestContract(t, s, "", nil, "alice")
+	err = s.SendContract(ctx, contract.ID, &SigningContext{Actor: "owner"})
+	var required *approvalservice.ApprovalRequiredError
+	if !assert.ErrorAs(t, err, &required) {
+		return
+	}
+	assert.EqualError(t, s.SendContract(ctx, contract.ID, &SigningContext{Actor: "owner"}), "approval is already pending")
+	assert.EqualError(t, s.UpdateContract(ctx, contract.ID, &SigningContext{Actor: "owner"}, &UpdateContractRequest{Content: "Changed"}),
+		"contract is awaiting approval")
+	pending, err := s.GetContract(ctx, contract.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.ContractDraft, pending.Status)
+
+	assert.NoError(t, approvals.Approve(ctx, required.Request.ID, "legal", ""))
+	audit, err := s.GetContractAudit(ctx, contract.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.ContractSent, audit.Contract.Status)
+	sent := audit.Events[len(audit.Events)-1]
+	assert.Equal(t, domain.ContractEventSent, sent.Type)
+	assert.Equal(t, "owner", sent.Actor)
+	assert.Equal(t, "approved in request "+required.Request.ID, sent.Detail)
+}
+
+// TestContractCertificate tests the signed PDF issued when a contract completes
+func TestContractCertificate(t *testing.T) {
+	testDB := testutils.SetupTestDB()
+	driver, err := storage.NewLocalDriver(t.TempDir())
+	assert.NoError(t, err)
+	storageService := docservice.NewStorageServiceWithDriver(driver)
+	s := NewContractServiceWithStorage(testDB, &config.Default().Contract, storageService, docservice.NewDocumentServiceWithStorage(testDB, storageService))
+	ctx := context.Background()
+
+	contract := createTestContract(t, s, "", nil, "alice")
+	_, _, err = s.OpenContractCertificate(ctx, contract.ID)
+	assert.EqualError(t, err, "contract is not completed")
+
+	assert.NoError(t, s.SendContract(ctx, contract.ID, &SigningContext{Actor: "owner"}))
+	assert.NoError(t, s.SignContract(ctx, contract.ID, &SigningContext{Actor: "alice"}))
+
+	completed, err := s.GetContract(ctx, contract.ID)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, completed.CertificateDocumentID)
+
+	document, file, err := s.OpenContractCertificate(ctx, contract.ID)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer file.Close()
+	assert.Equal(t, completed.CertificateDocumentID, document.ID)
+	assert.Equal(t, "application/pdf", document.MimeType)
+	assert.Equal(t, "team_1", document.TeamID)
+	content, err := io.ReadAll(file)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(content), "%PDF-1.4"))
+	assert.Equal(t, document.FileSize, int64(len(content)))
+}
@@ -0,0 +1,528 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	approvaldomain "cdk-office/internal/approval/domain"
+	approvalservice "cdk-office/internal/approval/service"
+	"cdk-office/internal/business/domain"
+	"cdk-office/internal/shared/utils"
+	"cdk-office/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// openContractStatuses are the statuses of contracts out for signing
+var openContractStatuses = []string{domain.ContractSent, domain.ContractPartiallySigned}
+
+// SigningContext identifies who acts on a contract and from where, for the audit trail
+type SigningContext struct {
+	Actor     string
+	IPAddress string
+	UserAgent string
+}
+
+// ContractAudit is the signing state and audit trail of a contract
+type ContractAudit struct {
+	Contract *domain.Contract `json:"contract"`
+	// ContentIntact reports whether the content still matches the hash taken when the contract was sent
+	ContentIntact bool `json:"content_intact"`
+	// SignaturesValid reports whether every signature verifies with the signing key
+	SignaturesValid bool                     `json:"signatures_valid"`
+	SigningKeyID    string                   `json:"signing_key_id"`
+	PublicKey       string                   `json:"public_key"` // base64 Ed25519 public key
+	Signers         []*domain.ContractSigner `json:"signers"`
+	Events          []*domain.ContractEvent  `json:"events"`
+}
+
+// SendContract sends a draft contract out for signing, fixing the hash of its
+// content. When the team requires approval to send contracts, the contract is
+// submitted for approval instead and sent once approved.
+func (s *ContractService) SendContract(ctx context.Context, contractID string, sc *SigningContext) error {
+	contract, err := s.checkSendable(contractID, sc)
+	if err != nil {
+		return err
+	}
+	if err := approvalservice.RequireApproval(ctx, s.approvals, &approvalservice.SubmitRequest{
+		TeamID:      contract.TeamID,
+		EntityType:  domain.ApprovalEntityContract,
+		EntityID:    contract.ID,
+		Action:      domain.ApprovalActionSend,
+		RequestedBy: sc.Actor,
+	}); err != nil {
+		return err
+	}
+	return s.sendContract(contract, sc, "")
+}
+
+// sendApprovedContract sends a contract whose sending was approved, on behalf of its requester
+func (s *ContractService) sendApprovedContract(ctx context.Context, request *approvaldomain.ApprovalRequest) error {
+	sc := &SigningContext{Actor: request.RequestedBy}
+	contract, err := s.checkSendable(request.EntityID, sc)
+	if err != nil {
+		return err
+	}
+	return s.sendContract(contract, sc, "approved in request "+request.ID)
+}
+
+// awaitingApproval reports whether sending a contract is pending approval
+func (s *ContractService) awaitingApproval(contractID string) (bool, error) {
+	var pending int64
+	err := s.db.Model(&approvaldomain.ApprovalRequest{}).Where("entity_type = ? AND entity_id = ? AND action = ? AND status = ?",
+		domain.ApprovalEntityContract, contractID, domain.ApprovalActionSend, approvaldomain.ApprovalPending).Count(&pending).Error
+	return pending > 0, err
+}
+
+// checkSendable loads a draft contract the actor may send
+func (s *ContractService) checkSendable(contractID string, sc *SigningContext) (*domain.Contract, error) {
+	contract, err := s.findContract(contractID, "failed to send contract")
+	if err != nil {
+		return nil, err
+	}
+	if contract.Status != domain.ContractDraft {
+		return nil, errors.New("only draft contracts can be sent")
+	}
+	if sc.Actor != contract.CreatedBy {
+		return nil, errors.New("only the creator can send this contract")
+	}
+
+	var signers []*domain.ContractSigner
+	if err := s.db.Where("contract_id = ?", contract.ID).Find(&signers).Error; err != nil {
+		logger.Error("failed to find contract signers", "error", err)
+		return nil, errors.New("failed to send contract")
+	}
+	if len(signers) == 0 {
+		return nil, errors.New("invalid signers: a contract needs at least one signer")
+	}
+	if overdue := overdueSigners(signers, time.Now()); len(overdue) > 0 {
+		return nil, fmt.Errorf("invalid signers: the deadline of %s has passed", overdue[0].SignerID)
+	}
+	return contract, nil
+}
+
+// sendContract sends a checked draft contract out for signing
+func (s *ContractService) sendContract(contract *domain.Contract, sc *SigningContext, detail string) error {
+	now := time.Now()
+	contract.Status = domain.ContractSent
+	contract.ContentHash = hashContent(contract.Content)
+	contract.SentAt = &now
+	contract.UpdatedAt = now
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&domain.Contract{}).Where("id = ? AND status = ?", contract.ID, domain.ContractDraft).Updates(map[string]interface{}{
+			"status":       contract.Status,
+			"content_hash": contract.ContentHash,
+			"sent_at":      now,
+			"updated_at":   now,
+		})
+		if result.Error != nil {
+			logger.Error("failed to send contract", "error", result.Error)
+			return errors.New("failed to send contract")
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("only draft contracts can be sent")
+		}
+		if err := recordContractEvent(tx, contract, domain.ContractEventSent, sc, detail); err != nil {
+			logger.Error("failed to record contract event", "error", err)
+			return errors.New("failed to send contract")
+		}
+		return nil
+	})
+}
+
+// SignContract records the signature of a signer, signing the content hash,
+// the signer and the time with the signing key. Sequential contracts are signed
+// in the order of their signers. The last signature completes the contract and
+// issues its certificate of completion.
+func (s *ContractService) SignContract(ctx context.Context, contractID string, sc *SigningContext) error {
+	completed := false
+	err := s.withOpenContract(contractID, "failed to sign contract", func(tx *gorm.DB, contract *domain.Contract, signers []*domain.ContractSigner) error {
+		signer := findSigner(signers, sc.Actor)
+		if signer == nil {
+			return errors.New("user is not authorized to sign this contract")
+		}
+		if signer.Status == domain.SignerSigned {
+			return errors.New("user has already signed this contract")
+		}
+		if contract.SigningOrder == domain.SigningOrderSequential {
+			for _, other := range signers {
+				if other.Position < signer.Position && other.Status == domain.SignerPending {
+					return errors.New("waiting for earlier signers to sign")
+				}
+			}
+		}
+
+		if hashContent(contract.Content) != contract.ContentHash {
+			logger.Error("contract content does not match its hash", "contract_id", contract.ID)
+			return errors.New("contract content has changed since it was sent")
+		}
+
+		// Signed times keep microseconds, the precision of database timestamps
+		now := time.Now().UTC().Truncate(time.Microsecond)
+		signer.Status = domain.SignerSigned
+		signer.SignedAt = &now
+		signer.Signature = s.signer.Sign(contract.ContentHash, signer.SignerID, now)
+		signer.SigningKeyID = s.signer.KeyID()
+		signer.UpdatedAt = now
+
+		signedBy, err := convertJSONToStringSlice(contract.SignedBy)
+		if err != nil {
+			signedBy = []string{}
+		}
+		contract.SignedBy = convertStringSliceToJSON(append(signedBy, signer.SignerID))
+		contract.Status = domain.ContractCompleted
+		for _, other := range signers {
+			if other.Status != domain.SignerSigned {
+				contract.Status = domain.ContractPartiallySigned
+				break
+			}
+		}
+		if contract.Status == domain.ContractCompleted {
+			contract.CompletedAt = &now
+		}
+		contract.UpdatedAt = now
+
+		err = tx.Save(signer).Error
+		if err == nil {
+			err = tx.Save(contract).Error
+		}
+		if err == nil {
+			err = recordContractEvent(tx, contract, domain.ContractEventSigned, sc, "")
+		}
+		if err == nil && contract.Status == domain.ContractCompleted {
+			err = recordContractEvent(tx, contract, domain.ContractEventCompleted, sc, "")
+		}
+		if err != nil {
+			logger.Error("failed to sign contract", "error", err)
+			return errors.New("failed to sign contract")
+		}
+		completed = contract.Status == domain.ContractCompleted
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if completed && s.documentService != nil {
+		// The certificate is issued again on request if this fails
+		if _, err := s.issueCertificate(ctx, contractID); err != nil {
+			logger.Error("failed to issue contract certificate", "contract_id", contractID, "error", err)
+		}
+	}
+	return nil
+}
+
+// DeclineContract records a signer declining to sign, which ends the signing
+func (s *ContractService) DeclineContract(ctx context.Context, contractID string, sc *SigningContext, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return errors.New("decline reason is required")
+	}
+
+	return s.withOpenContract(contractID, "failed to decline contract", func(tx *gorm.DB, contract *domain.Contract, signers []*domain.ContractSigner) error {
+		signer := findSigner(signers, sc.Actor)
+		if signer == nil {
+			return errors.New("user is not authorized to sign this contract")
+		}
+		if signer.Status == domain.SignerSigned {
+			return errors.New("user has already signed this contract")
+		}
+
+		now := time.Now()
+		signer.Status = domain.SignerDeclined
+		signer.DeclinedAt = &now
+		signer.DeclineReason = reason
+		signer.UpdatedAt = now
+		contract.Status = domain.ContractDeclined
+		contract.UpdatedAt = now
+
+		err := tx.Save(signer).Error
+		if err == nil {
+			err = tx.Save(contract).Error
+		}
+		if err == nil {
+			err = recordContractEvent(tx, contract, domain.ContractEventDeclined, sc, reason)
+		}
+		if err != nil {
+			logger.Error("failed to decline contract", "error", err)
+			return errors.New("failed to decline contract")
+		}
+		return nil
+	})
+}
+
+// VoidContract withdraws a contract out for signing
+func (s *ContractService) VoidContract(ctx context.Context, contractID string, sc *SigningContext, reason string) error {
+	return s.withOpenContract(contractID, "failed to void contract", func(tx *gorm.DB, contract *domain.Contract, signers []*domain.ContractSigner) error {
+		if sc.Actor != contract.CreatedBy {
+			return errors.New("only the creator can void this contract")
+		}
+
+		contract.Status = domain.ContractVoided
+		contract.UpdatedAt = time.Now()
+		err := tx.Save(contract).Error
+		if err == nil {
+			err = recordContractEvent(tx, contract, domain.ContractEventVoided, sc, strings.TrimSpace(reason))
+		}
+		if err != nil {
+			logger.Error("failed to void contract", "error", err)
+			return errors.New("failed to void contract")
+		}
+		return nil
+	})
+}
+
+// GetContractAudit retrieves a contract with its signers and audit trail
+func (s *ContractService) GetContractAudit(ctx context.Context, contractID string) (*ContractAudit, error) {
+	contract, err := s.findContract(contractID, "failed to get contract audit")
+	if err != nil {
+		return nil, err
+	}
+
+	audit := &ContractAudit{
+		Contract:        contract,
+		ContentIntact:   contract.ContentHash == "" || contract.ContentHash == hashContent(contract.Content),
+		SignaturesValid: true,
+		SigningKeyID:    s.signer.KeyID(),
+		PublicKey:       s.signer.PublicKey(),
+	}
+	if err := s.db.Where("contract_id = ?", contractID).Order("position asc").Find(&audit.Signers).Error; err != nil {
+		logger.Error("failed to find contract signers", "error", err)
+		return nil, errors.New("failed to get contract audit")
+	}
+	for _, signer := range audit.Signers {
+		if signer.Status == domain.SignerSigned && !s.verifySignature(contract, signer) {
+			audit.SignaturesValid = false
+		}
+	}
+	if err := s.db.Where("contract_id = ?", contractID).Order("created_at asc").Find(&audit.Events).Error; err != nil {
+		logger.Error("failed to find contract events", "error", err)
+		return nil, errors.New("failed to get contract audit")
+	}
+	return audit, nil
+}
+
+// ExpireOverdueContracts expires the contracts out for signing with a signer
+// who has not signed by their deadline. It returns the number of contracts expired.
+func (s *ContractService) ExpireOverdueContracts(ctx context.Context) (int, error) {
+	var contractIDs []string
+	err := s.db.Model(&domain.ContractSigner{}).
+		Joins("JOIN contracts ON contracts.id = contract_signers.contract_id").
+		Where("contract_signers.status = ? AND contract_signers.deadline <= ? AND contracts.status IN ?",
+			domain.SignerPending, time.Now(), openContractStatuses).
+		Distinct().Pluck("contract_signers.contract_id", &contractIDs).Error
+	if err != nil {
+		logger.Error("failed to find overdue contracts", "error", err)
+		return 0, errors.New("failed to expire contracts")
+	}
+
+	expired := 0
+	for _, contractID := range contractIDs {
+		err := s.withOpenContract(contractID, "failed to expire contracts", func(*gorm.DB, *domain.Contract, []*domain.ContractSigner) error {
+			return nil
+		})
+		switch {
+		case err == nil:
+		case err.Error() == "contract has expired":
+			expired++
+		case err.Error() == "contract not found" || err.Error() == "contract is not open for signing":
+			// Signed, declined or voided in the meantime
+		default:
+			return expired, err
+		}
+	}
+	return expired, nil
+}
+
+// StartExpiry periodically expires overdue contracts until ctx is done
+func (s *ContractService) StartExpiry(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if expired, err := s.ExpireOverdueContracts(ctx); err == nil && expired > 0 {
+					logger.Info("expired overdue contracts", "count", expired)
+				}
+			}
+		}
+	}()
+}
+
+// withOpenContract runs fn in a transaction on a contract out for signing and
+// its signers. The contract row is written first, so concurrent actions on the
+// same contract run one after the other. A contract with an overdue signer is
+// expired instead of running fn.
+func (s *ContractService) withOpenContract(contractID, failure string, fn func(tx *gorm.DB, contract *domain.Contract, signers []*domain.ContractSigner) error) error {
+	expired := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&domain.Contract{}).Where("id = ? AND status IN ?", contractID, openContractStatuses).Update("updated_at", now)
+		if result.Error != nil {
+			logger.Error("failed to lock contract", "error", result.Error)
+			return errors.New(failure)
+		}
+		if result.RowsAffected == 0 {
+			var count int64
+			if err := tx.Model(&domain.Contract{}).Where("id = ?", contractID).Count(&count).Error; err != nil {
+				logger.Error("failed to find contract", "error", err)
+				return errors.New(failure)
+			}
+			if count == 0 {
+				return errors.New("contract not found")
+			}
+			return errors.New("contract is not open for signing")
+		}
+
+		var contract domain.Contract
+		if err := tx.Where("id = ?", contractID).First(&contract).Error; err != nil {
+			logger.Error("failed to find contract", "error", err)
+			return errors.New(failure)
+		}
+		var signers []*domain.ContractSigner
+		if err := tx.Where("contract_id = ?", contractID).Order("position asc").Find(&signers).Error; err != nil {
+			logger.Error("failed to find contract signers", "error", err)
+			return errors.New(failure)
+		}
+
+		if overdue := overdueSigners(signers, now); len(overdue) > 0 {
+			if err := expireContract(tx, &contract, overdue, now); err != nil {
+				logger.Error("failed to expire contract", "error", err)
+				return errors.New(failure)
+			}
+			expired = true
+			return nil
+		}
+		return fn(tx, &contract, signers)
+	})
+	if err == nil && expired {
+		return errors.New("contract has expired")
+	}
+	return err
+}
+
+// expireContract marks the overdue signers and their contract as expired
+func expireContract(tx *gorm.DB, contract *domain.Contract, overdue []*domain.ContractSigner, now time.Time) error {
+	ids := make([]string, len(overdue))
+	for i, signer := range overdue {
+		signer.Status = domain.SignerExpired
+		signer.UpdatedAt = now
+		if err := tx.Save(signer).Error; err != nil {
+			return err
+		}
+		ids[i] = signer.SignerID
+	}
+	contract.Status = domain.ContractExpired
+	contract.UpdatedAt = now
+	if err := tx.Save(contract).Error; err != nil {
+		return err
+	}
+	detail := "deadline passed for " + strings.Join(ids, ", ")
+	return recordContractEvent(tx, contract, domain.ContractEventExpired, &SigningContext{Actor: "system"}, detail)
+}
+
+// verifySignature reports whether the signature of a signer verifies with the signing key
+func (s *ContractService) verifySignature(contract *domain.Contract, signer *domain.ContractSigner) bool {
+	return signer.SignedAt != nil && signer.SigningKeyID == s.signer.KeyID() &&
+		s.signer.Verify(contract.ContentHash, signer.SignerID, *signer.SignedAt, signer.Signature)
+}
+
+// findContract retrieves a contract, failing with the given message on database errors
+func (s *ContractService) findContract(contractID, failure string) (*domain.Contract, error) {
+	var contract domain.Contract
+	if err := s.db.Where("id = ?", contractID).First(&contract).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("contract not found")
+		}
+		logger.Error("failed to find contract", "error", err)
+		return nil, errors.New(failure)
+	}
+	return &contract, nil
+}
+
+// contractSigners builds the pending signers of a new contract in signing order
+func contractSigners(signerIDs []string, deadlines map[string]time.Time) ([]*domain.ContractSigner, error) {
+	if len(signerIDs) == 0 {
+		return nil, errors.New("invalid signers: a contract needs at least one signer")
+	}
+
+	listed := make(map[string]bool, len(signerIDs))
+	signers := make([]*domain.ContractSigner, 0, len(signerIDs))
+	for i, signerID := range signerIDs {
+		if strings.TrimSpace(signerID) == "" {
+			return nil, errors.New("invalid signers: signer ID is empty")
+		}
+		if listed[signerID] {
+			return nil, fmt.Errorf("invalid signers: %s is listed more than once", signerID)
+		}
+		listed[signerID] = true
+
+		signer := &domain.ContractSigner{
+			ID:       utils.GenerateContractSignerID(),
+			SignerID: signerID,
+			Position: i + 1,
+			Status:   domain.SignerPending,
+		}
+		if deadline, ok := deadlines[signerID]; ok {
+			signer.Deadline = &deadline
+		}
+		signers = append(signers, signer)
+	}
+	for signerID := range deadlines {
+		if !listed[signerID] {
+			return nil, fmt.Errorf("invalid signers: deadline given for %s, who is not a signer", signerID)
+		}
+	}
+	return signers, nil
+}
+
+// overdueSigners returns the pending signers whose deadline has passed
+func overdueSigners(signers []*domain.ContractSigner, now time.Time) []*domain.ContractSigner {
+	var overdue []*domain.ContractSigner
+	for _, signer := range signers {
+		if signer.Status == domain.SignerPending && signer.Deadline != nil && !signer.Deadline.After(now) {
+			overdue = append(overdue, signer)
+		}
+	}
+	return overdue
+}
+
+// findSigner returns the signer with the given user ID, or nil
+func findSigner(signers []*domain.ContractSigner, signerID string) *domain.ContractSigner {
+	for _, signer := range signers {
+		if signer.SignerID == signerID {
+			return signer
+		}
+	}
+	return nil
+}
+
+// recordContractEvent appends an event to the audit trail of a contract, with
+// the hash of the contract content at that moment
+func recordContractEvent(tx *gorm.DB, contract *domain.Contract, eventType string, sc *SigningContext, detail string) error {
+	return tx.Create(&domain.ContractEvent{
+		ID:          utils.GenerateContractEventID(),
+		ContractID:  contract.ID,
+		Type:        eventType,
+		Actor:       sc.Actor,
+		IPAddress:   sc.IPAddress,
+		UserAgent:   sc.UserAgent,
+		ContentHash: hashContent(contract.Content),
+		Detail:      detail,
+		CreatedAt:   time.Now(),
+	}).Error
+}
+
+// hashContent returns the hex SHA-256 hash of contract content
+func hashContent(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,330 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"cdk-office/internal/business/contracttemplate"
+	"cdk-office/internal/business/domain"
+	employeedomain "cdk-office/internal/employee/domain"
+	"cdk-office/internal/shared/database"
+	"cdk-office/internal/shared/utils"
+	"cdk-office/pkg/config"
+	"cdk-office/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// ContractTemplateServiceInterface defines the interface for contract template service
+type ContractTemplateServiceInterface interface {
+	CreateTemplate(ctx context.Context, req *CreateContractTemplateRequest) (*domain.ContractTemplate, error)
+	UpdateTemplate(ctx context.Context, templateID, userID string, req *UpdateContractTemplateRequest) error
+	DeleteTemplate(ctx context.Context, templateID, userID string) error
+	ListTemplates(ctx context.Context, teamID string) ([]*domain.ContractTemplate, error)
+	GetTemplate(ctx context.Context, templateID string) (*domain.ContractTemplate, error)
+	GenerateContract(ctx context.Context, templateID string, req *GenerateContractRequest) (*domain.Contract, error)
+}
+
+// ContractTemplateService implements the ContractTemplateServiceInterface
+type ContractTemplateService struct {
+	db              *gorm.DB
+	contractService ContractServiceInterface
+}
+
+// NewContractTemplateService creates a new instance of ContractTemplateService
+func NewContractTemplateService(cfg *config.Config) (*ContractTemplateService, error) {
+	contractService, err := NewContractService(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewContractTemplateServiceWithService(database.GetDB(), contractService), nil
+}
+
+// NewContractTemplateServiceWithService creates a new instance of ContractTemplateService with a specific
+// database connection and the contract service creating generated contracts
+func NewContractTemplateServiceWithService(db *gorm.DB, contractService ContractServiceInterface) *ContractTemplateService {
+	return &ContractTemplateService{
+		db:              db,
+		contractService: contractService,
+	}
+}
+
+// CreateContractTemplateRequest represents the request for creating a contract template
+type CreateContractTemplateRequest struct {
+	TeamID      string                    `json:"team_id"`
+	Name        string                    `json:"name"`
+	Description string                    `json:"description"`
+	Title       string                    `json:"title"`
+	Content     string                    `json:"content"`
+	Fields      []*contracttemplate.Field `json:"fields"`
+	CreatedBy   string                    `json:"created_by"`
+}
+
+// UpdateContractTemplateRequest represents the request for updating a contract template.
+// Fields replace the field definitions when set.
+type UpdateContractTemplateRequest struct {
+	Name        string                    `json:"name"`
+	Description string                    `json:"description"`
+	Title       string                    `json:"title"`
+	Content     string                    `json:"content"`
+	Fields      []*contracttemplate.Field `json:"fields"`
+}
+
+// GenerateContractRequest represents the request for generating a contract from a template.
+// Placeholders are filled from Data first, then from the employee and the department,
+// which defaults to the employee's department.
+type GenerateContractRequest struct {
+	CreatedBy    string               `json:"created_by"`
+	Signers      []string             `json:"signers"`
+	SigningOrder string               `json:"signing_order"`
+	Deadlines    map[string]time.Time `json:"deadlines"`
+	EmployeeID   string               `json:"employee_id"`
+	DepartmentID string               `json:"department_id"`
+	Data         string               `json:"data"` // JSON object of field values
+}
+
+// CreateTemplate creates a new contract template. The creator must be a member of the team.
+func (s *ContractTemplateService) CreateTemplate(ctx context.Context, req *CreateContractTemplateRequest) (*domain.ContractTemplate, error) {
+	if err := s.checkTeamMember(req.CreatedBy, req.TeamID, "failed to create contract template"); err != nil {
+		return nil, err
+	}
+	if err := checkTemplate(req.Title, req.Content, req.Fields); err != nil {
+		return nil, err
+	}
+
+	// Create new template
+	now := time.Now()
+	template := &domain.ContractTemplate{
+		ID:          utils.GenerateContractTemplateID(),
+		TeamID:      req.TeamID,
+		Name:        req.Name,
+		Description: req.Description,
+		Title:       req.Title,
+		Content:     req.Content,
+		Fields:      convertFieldsToJSON(req.Fields),
+		CreatedBy:   req.CreatedBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	// Save template to database
+	if err := s.db.Create(template).Error; err != nil {
+		logger.Error("failed to create contract template", "error", err)
+		return nil, errors.New("failed to create contract template")
+	}
+
+	return template, nil
+}
+
+// UpdateTemplate updates an existing contract template on behalf of a member of its team
+func (s *ContractTemplateService) UpdateTemplate(ctx context.Context, templateID, userID string, req *UpdateContractTemplateRequest) error {
+	template, err := s.findTemplate(templateID, "failed to update contract template")
+	if err != nil {
+		return err
+	}
+	if err := s.checkTeamMember(userID, template.TeamID, "failed to update contract template"); err != nil {
+		return err
+	}
+	fields, err := templateFields(template)
+	if err != nil {
+		return errors.New("failed to update contract template")
+	}
+
+	// Update template fields
+	if req.Name != "" {
+		template.Name = req.Name
+	}
+	if req.Description != "" {
+		template.Description = req.Description
+	}
+	if req.Title != "" {
+		template.Title = req.Title
+	}
+	if req.Content != "" {
+		template.Content = req.Content
+	}
+	if req.Fields != nil {
+		fields = req.Fields
+	}
+	if err := checkTemplate(template.Title, template.Content, fields); err != nil {
+		return err
+	}
+	template.Fields = convertFieldsToJSON(fields)
+	template.UpdatedAt = time.Now()
+
+	// Save updated template to database
+	if err := s.db.Save(template).Error; err != nil {
+		logger.Error("failed to update contract template", "error", err)
+		return errors.New("failed to update contract template")
+	}
+
+	return nil
+}
+
+// DeleteTemplate deletes a contract template on behalf of a member of its team.
+// Contracts generated from it are kept.
+func (s *ContractTemplateService) DeleteTemplate(ctx context.Context, templateID, userID string) error {
+	template, err := s.findTemplate(templateID, "failed to delete contract template")
+	if err != nil {
+		return err
+	}
+	if err := s.checkTeamMember(userID, template.TeamID, "failed to delete contract template"); err != nil {
+		return err
+	}
+
+	if err := s.db.Delete(template).Error; err != nil {
+		logger.Error("failed to delete contract template", "error", err)
+		return errors.New("failed to delete contract template")
+	}
+
+	return nil
+}
+
+// ListTemplates lists the contract templates of a team
+func (s *ContractTemplateService) ListTemplates(ctx context.Context, teamID string) ([]*domain.ContractTemplate, error) {
+	var templates []*domain.ContractTemplate
+	if err := s.db.Where("team_id = ?", teamID).Order("name asc").Find(&templates).Error; err != nil {
+		logger.Error("failed to list contract templates", "error", err)
+		return nil, errors.New("failed to list contract templates")
+	}
+
+	return templates, nil
+}
+
+// GetTemplate retrieves a contract template by ID
+func (s *ContractTemplateService) GetTemplate(ctx context.Context, templateID string) (*domain.ContractTemplate, error) {
+	return s.findTemplate(templateID, "failed to get contract template")
+}
+
+// GenerateContract fills the placeholders of a template and creates a draft
+// contract of the template's team from the result. The creator must be a member
+// of the team, as the placeholders expose employee records.
+func (s *ContractTemplateService) GenerateContract(ctx context.Context, templateID string, req *GenerateContractRequest) (*domain.Contract, error) {
+	template, err := s.findTemplate(templateID, "failed to generate contract")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkTeamMember(req.CreatedBy, template.TeamID, "failed to generate contract"); err != nil {
+		return nil, err
+	}
+	fields, err := templateFields(template)
+	if err != nil {
+		return nil, errors.New("failed to generate contract")
+	}
+
+	values, err := contracttemplate.ParseValues(req.Data)
+	if err != nil {
+		return nil, err
+	}
+	departmentID := req.DepartmentID
+	if req.EmployeeID != "" {
+		var employee employeedomain.Employee
+		if err := s.db.Where("id = ? AND team_id = ?", req.EmployeeID, template.TeamID).First(&employee).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("employee not found")
+			}
+			logger.Error("failed to find employee", "error", err)
+			return nil, errors.New("failed to generate contract")
+		}
+		values.Merge(contracttemplate.EmployeeValues(&employee))
+		if departmentID == "" {
+			departmentID = employee.DeptID
+		}
+	}
+	if departmentID != "" {
+		var department employeedomain.Department
+		if err := s.db.Where("id = ? AND team_id = ?", departmentID, template.TeamID).First(&department).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("department not found")
+			}
+			logger.Error("failed to find department", "error", err)
+			return nil, errors.New("failed to generate contract")
+		}
+		values.Merge(contracttemplate.DepartmentValues(&department))
+	}
+
+	texts, err := contracttemplate.Resolve(fields, values)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.contractService.CreateContract(ctx, &CreateContractRequest{
+		TeamID:       template.TeamID,
+		Title:        texts.Expand(template.Title),
+		Description:  "Generated from template " + template.Name,
+		Content:      texts.Expand(template.Content),
+		CreatedBy:    req.CreatedBy,
+		Signers:      req.Signers,
+		SigningOrder: req.SigningOrder,
+		Deadlines:    req.Deadlines,
+	})
+}
+
+// findTemplate loads a contract template, mapping lookup failures to the given error
+func (s *ContractTemplateService) findTemplate(templateID, failure string) (*domain.ContractTemplate, error) {
+	var template domain.ContractTemplate
+	if err := s.db.Where("id = ?", templateID).First(&template).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("contract template not found")
+		}
+		logger.Error("failed to find contract template", "error", err)
+		return nil, errors.New(failure)
+	}
+	return &template, nil
+}
+
+// checkTeamMember ensures the user is an active employee of the team
+func (s *ContractTemplateService) checkTeamMember(userID, teamID, failure string) error {
+	if userID == "" {
+		return errors.New("user is not a member of the team")
+	}
+	var count int64
+	if err := s.db.Model(&employeedomain.Employee{}).
+		Where("user_id = ? AND team_id = ? AND status = ?", userID, teamID, "active").
+		Count(&count).Error; err != nil {
+		logger.Error("failed to check team membership", "error", err)
+		return errors.New(failure)
+	}
+	if count == 0 {
+		return errors.New("user is not a member of the team")
+	}
+	return nil
+}
+
+// checkTemplate validates the title, content and fields of a template
+func checkTemplate(title, content string, fields []*contracttemplate.Field) error {
+	if title == "" || content == "" {
+		return errors.New("invalid template: title and content are required")
+	}
+	if err := contracttemplate.Check(title, fields); err != nil {
+		return err
+	}
+	return contracttemplate.Check(content, fields)
+}
+
+// templateFields decodes the field definitions of a template
+func templateFields(template *domain.ContractTemplate) ([]*contracttemplate.Field, error) {
+	var fields []*contracttemplate.Field
+	if template.Fields == "" {
+		return fields, nil
+	}
+	if err := json.Unmarshal([]byte(template.Fields), &fields); err != nil {
+		logger.Error("failed to unmarshal contract template fields", "template_id", template.ID, "error", err)
+		return nil, err
+	}
+	return fields, nil
+}
+
+// convertFieldsToJSON converts field definitions to a JSON string
+func convertFieldsToJSON(fields []*contracttemplate.Field) string {
+	if fields == nil {
+		fields = []*contracttemplate.Field{}
+	}
+	jsonData, err := json.Marshal(fields)
+	if err != nil {
+		logger.Error("failed to marshal contract template fields to JSON", "error", err)
+		return "[]"
+	}
+	return string(jsonData)
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cdk-office/internal/business/contracttemplate"
+	"cdk-office/internal/business/domain"
+	employeedomain "cdk-office/internal/employee/domain"
+	"cdk-office/internal/shared/testutils"
+	"cdk-office/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestContractTemplateGenerate tests generating contracts from a template
+func TestContractTemplateGenerate(t *testing.T) {
+	testDB := testutils.SetupTestDB()
+	s := NewContractTemplateServiceWithService(testDB, NewContractServiceWithDB(testDB, &config.Default().Contract))
+	ctx := context.Background()
+
+	assert.NoError(t, testDB.Create(&employeedomain.Department{ID: "dept_1", TeamID: "team_1", Name: "Research"}).Error)
+	assert.NoError(t, testDB.Create(&employeedomain.Employee{
+		ID: "emp_1", TeamID: "team_1", DeptID: "dept_1", EmployeeID: "E001", RealName: "Ada Lovelace", Position: "Engineer",
+		HireDate: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	}).Error)
+	assert.NoError(t, testDB.Create(&employeedomain.Employee{ID: "emp_2", TeamID: "team_2", EmployeeID: "E002", RealName: "Eve"}).Error)
+	assert.NoError(t, testDB.Create(&employeedomain.Employee{
+		ID: "emp_hr", UserID: "hr", TeamID: "team_1", EmployeeID: "E003", RealName: "Hannah", Status: "active",
+	}).Error)
+	assert.NoError(t, testDB.Create(&employeedomain.Employee{
+		ID: "emp_eve", UserID: "eve", TeamID: "team_2", EmployeeID: "E004", RealName: "Eve", Status: "active",
+	}).Error)
+
+	_, err := s.CreateTemplate(ctx, &CreateContractTemplateRequest{
+		TeamID: "team_1", Name: "Employment", Title: "Employment contract", Content: "Terms", CreatedBy: "eve",
+	})
+	assert.EqualError(t, err, "user is not a member of the team")
+
+	_, err = s.CreateTemplate(ctx, &CreateContractTemplateRequest{
+		TeamID: "team_1", Name: "Employment", Title: "Contract {{employee.name}}", Content: "{{salary}}", CreatedBy: "hr",
+	})
+	assert.EqualError(t, err, "invalid template: placeholder {{employee.name}} is not declared as a field")
+
+	template, err := s.CreateTemplate(ctx, &CreateContractTemplateRequest{
+		TeamID:  "team_1",
+		Name:    "Employment",
+		Title:   "Employment contract {{employee.name}}",
+		Content: "{{employee.name}} joins {{department.name}} as {{employee.position}} on {{employee.hire_date}} for {{salary}}.",
+		Fields: []*contracttemplate.Field{
+			{Name: "employee.name", Required: true},
+			{Name: "employee.position", Required: true},
+			{Name: "employee.hire_date", Type: contracttemplate.TypeDate, Required: true},
+			{Name: "department.name", Required: true},
+			{Name: "salary", Type: contracttemplate.TypeNumber, Required: true},
+		},
+		CreatedBy: "hr",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	generate := &GenerateContractRequest{CreatedBy: "hr", Signers: []string{"emp_1", "hr"}, EmployeeID: "emp_1"}
+	_, err = s.GenerateContract(ctx, template.ID, generate)
+	assert.EqualError(t, err, "invalid data: missing required fields: salary")
+
+	generate.Data = `{"salary": 4200}`
+	contract, err := s.GenerateContract(ctx, template.ID, generate)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "team_1", contract.TeamID)
+	assert.Equal(t, "Employment contract Ada Lovelace", contract.Title)
+	assert.Equal(t, "Ada Lovelace joins Research as Engineer on 2024-03-01 for 4200.", contract.Content)
+	assert.Equal(t, domain.ContractDraft, contract.Status)
+
+	// Members of other teams cannot read employee records through the template
+	_, err = s.GenerateContract(ctx, template.ID, &GenerateContractRequest{
+		CreatedBy: "eve", Signers: []string{"eve"}, EmployeeID: "emp_1", Data: `{"salary": 4200}`,
+	})
+	assert.EqualError(t, err, "user is not a member of the team")
+	assert.EqualError(t, s.UpdateTemplate(ctx, template.ID, "eve", &UpdateContractTemplateRequest{Name: "Mine"}),
+		"user is not a member of the team")
+	assert.EqualError(t, s.DeleteTemplate(ctx, template.ID, "eve"), "user is not a member of the team")
+
+	// The generated contract goes through the normal signing flow
+	assert.NoError(t, s.contractService.SendContract(ctx, contract.ID, &SigningContext{Actor: "hr"}))
+
+	// Employees and departments of other teams are not found
+	generate.EmployeeID = "emp_2"
+	_, err = s.GenerateContract(ctx, template.ID, generate)
+	assert.EqualError(t, err, "employee not found")
+
+	// Without an employee, the department and data fill the fields
+	contract, err = s.GenerateContract(ctx, template.ID, &GenerateContractRequest{
+		CreatedBy: "hr", Signers: []string{"hr"}, DepartmentID: "dept_1",
+		Data: `{"employee": {"name": "Grace", "position": "Analyst", "hire_date": "2024-06-01"}, "salary": "3900"}`,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Grace joins Research as Analyst on 2024-06-01 for 3900.", contract.Content)
+
+	assert.EqualError(t, s.UpdateTemplate(ctx, template.ID, "hr", &UpdateContractTemplateRequest{Content: "{{bonus}}"}),
+		"invalid template: placeholder {{bonus}} is not declared as a field")
+	templates, err := s.ListTemplates(ctx, "team_1")
+	assert.NoError(t, err)
+	assert.Len(t, templates, 1)
+	assert.NoError(t, s.DeleteTemplate(ctx, template.ID, "hr"))
+	_, err = s.GenerateContract(ctx, template.ID, generate)
+	assert.EqualError(t, err, "contract template not found")
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"time"
 
+	"cdk-office/internal/app/dataexport"
 	"cdk-office/internal/business/domain"
 	"cdk-office/internal/shared/database"
 	"cdk-office/internal/shared/utils"
@@ -23,6 +24,7 @@ type SurveyServiceInterface interface {
 	CloseSurvey(ctx context.Context, surveyID string) error
 	SubmitResponse(ctx context.Context, req *SubmitResponseRequest) error
 	GetSurveyResponses(ctx context.Context, surveyID string) ([]*SurveyResponse, error)
+	OpenResponsesExport(ctx context.Context, surveyID string, opts *dataexport.Options) (*dataexport.Export, error)
 }
 
 // SurveyService implements the SurveyServiceInterface
@@ -347,4 +349,21 @@ func (s *SurveyService) GetSurveyResponses(ctx context.Context, surveyID string)
 	}
 
 	return responses, nil
-}
\ No newline at end of file
+}
+
+// OpenResponsesExport prepares an export of the responses of a survey with a
+// column per question, when the questions are a form schema
+func (s *SurveyService) OpenResponsesExport(ctx context.Context, surveyID string, opts *dataexport.Options) (*dataexport.Export, error) {
+	// Check if survey exists
+	var survey domain.Survey
+	if err := s.db.Where("id = ?", surveyID).First(&survey).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("survey not found")
+		}
+		logger.Error("failed to find survey", "error", err)
+		return nil, errors.New("failed to export survey responses")
+	}
+
+	query := s.db.WithContext(ctx).Model(&domain.SurveyResponse{}).Where("survey_id = ?", surveyID)
+	return dataexport.New(query, dataexport.Table{CreatedBy: "user_id", Data: "answers"}, survey.Questions, opts)
+}
@@ -2,7 +2,9 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"strings"
 	"time"
 
 	"cdk-office/internal/dify/client"
@@ -18,13 +20,17 @@ type AgentServiceInterface interface {
 
 // AgentService implements the AgentServiceInterface
 type AgentService struct {
-	difyClient client.DifyClientInterface
+	difyClient      client.DifyClientInterface
+	streamingClient client.StreamingClientInterface
 }
 
-// NewAgentService creates a new instance of AgentService
+// NewAgentService creates a new instance of AgentService. Agents answer with
+// their thoughts and tool calls when difyClient also streams answers.
 func NewAgentService(difyClient client.DifyClientInterface) *AgentService {
+	streamingClient, _ := difyClient.(client.StreamingClientInterface)
 	return &AgentService{
-		difyClient: difyClient,
+		difyClient:      difyClient,
+		streamingClient: streamingClient,
 	}
 }
 
@@ -42,7 +48,7 @@ type Agent struct {
 type AgentConfig struct {
 	Name        string            `json:"name"`
 	Description string            `json:"description"`
-	Tools       []string          `json:"tools"`
+	Tools       []string          `json:"tools"` // names of registered tools, run by AgentRunner
 	Parameters  map[string]string `json:"parameters"`
 }
 
@@ -64,7 +70,9 @@ type ToolCall struct {
 	Outputs  map[string]interface{} `json:"outputs"`
 }
 
-// InvokeAgent invokes an AI agent with a message
+// InvokeAgent invokes an AI agent with a message. Dify agents only answer in
+// streaming mode, so the answer, thoughts and tool calls are collected from the
+// stream when the client supports it.
 func (s *AgentService) InvokeAgent(ctx context.Context, agentID string, message string) (*AgentResponse, error) {
 	// Prepare the request for Dify Agent API
 	req := &client.ChatRequest{
@@ -75,44 +83,100 @@ func (s *AgentService) InvokeAgent(ctx context.Context, agentID string, message
 		ResponseMode: "blocking",
 		User: "cdk-office",
 	}
-	
-	// Call the Dify API
-	resp, err := s.difyClient.CreateChatMessage(ctx, req)
+
+	if s.streamingClient == nil {
+		resp, err := s.difyClient.CreateChatMessage(ctx, req)
+		if err != nil {
+			logger.Error("failed to invoke agent", "error", err)
+			return nil, errors.New("failed to invoke agent")
+		}
+		return &AgentResponse{
+			AgentID:   agentID,
+			MessageID: resp.MessageID,
+			Answer:    resp.Answer,
+			Thoughts:  []string{},
+			ToolCalls: []ToolCall{},
+			CreatedAt: resp.CreatedAt.Format(time.RFC3339),
+		}, nil
+	}
+
+	events, err := s.streamingClient.StreamChatMessage(ctx, req)
 	if err != nil {
 		logger.Error("failed to invoke agent", "error", err)
 		return nil, errors.New("failed to invoke agent")
 	}
-	
-	// Parse the response and convert to AgentResponse
-	// This implementation assumes the Dify API returns results in a format we can convert to AgentResponse
+
 	response := &AgentResponse{
 		AgentID:   agentID,
-		MessageID: resp.MessageID,
-		Answer:    resp.Answer,
-		Thoughts: []string{
-			"Thought 1: Understanding the user's request",
-			"Thought 2: Formulating a response",
-		},
-		ToolCalls: []ToolCall{
-			{
-				ID:   "tool_1",
-				Name: "document_search",
-				Inputs: map[string]interface{}{
-					"query": message,
-				},
-				Outputs: map[string]interface{}{
-					"results": []string{"Document 1", "Document 2"},
-				},
-			},
-		},
-		CreatedAt: resp.CreatedAt.Format(time.RFC3339),
+		Thoughts:  []string{},
+		ToolCalls: []ToolCall{},
+		CreatedAt: time.Now().Format(time.RFC3339),
 	}
-	
-	logger.Info("invoked AI agent", "agent_id", agentID, "message", message)
-	
+	var answer strings.Builder
+	// An agent thought is sent again each time it is updated, as its tool runs
+	thoughts := make(map[string]*client.StreamEvent)
+	var thoughtIDs []string
+	for event := range events {
+		if event.MessageID != "" {
+			response.MessageID = event.MessageID
+		}
+		if event.CreatedAt > 0 {
+			response.CreatedAt = time.Unix(event.CreatedAt, 0).Format(time.RFC3339)
+		}
+		switch event.Event {
+		case client.StreamEventMessage, client.StreamEventAgentMessage:
+			answer.WriteString(event.Answer)
+		case client.StreamEventMessageReplace:
+			answer.Reset()
+			answer.WriteString(event.Answer)
+		case client.StreamEventAgentThought:
+			if _, ok := thoughts[event.ID]; !ok {
+				thoughtIDs = append(thoughtIDs, event.ID)
+			}
+			thoughts[event.ID] = event
+		case client.StreamEventError:
+			logger.Error("agent answer failed", "code", event.Code, "message", event.Message)
+			return nil, errors.New("failed to invoke agent")
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, errors.New("failed to invoke agent")
+	}
+	response.Answer = answer.String()
+
+	for _, id := range thoughtIDs {
+		thought := thoughts[id]
+		if thought.Thought != "" {
+			response.Thoughts = append(response.Thoughts, thought.Thought)
+		}
+		if thought.Tool != "" {
+			response.ToolCalls = append(response.ToolCalls, ToolCall{
+				ID:      thought.ID,
+				Name:    thought.Tool,
+				Inputs:  toolInputs(thought.ToolInput),
+				Outputs: map[string]interface{}{"observation": thought.Observation},
+			})
+		}
+	}
+
+	logger.Info("invoked AI agent", "agent_id", agentID, "thoughts", len(response.Thoughts), "tool_calls", len(response.ToolCalls))
+
 	return response, nil
 }
 
+// toolInputs decodes the JSON tool input of an agent thought, keeping input that
+// is not a JSON object as is
+func toolInputs(input string) map[string]interface{} {
+	inputs := make(map[string]interface{})
+	if input == "" {
+		return inputs
+	}
+	if err := json.Unmarshal([]byte(input), &inputs); err != nil {
+		return map[string]interface{}{"input": input}
+	}
+	return inputs
+}
+
 // CreateAgent creates a new AI agent
 func (s *AgentService) CreateAgent(ctx context.Context, config *AgentConfig) (*Agent, error) {
 	// Prepare the request for Dify Agent API to create an agent
@@ -0,0 +1,53 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cdk-office/internal/dify/client"
+	"cdk-office/internal/shared/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestInvokeAgent tests that the answer, thoughts and tool calls of an agent are
+// collected from its streamed answer
+func TestInvokeAgent(t *testing.T) {
+	testutils.SetupTestDB()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: {\"event\": \"agent_thought\", \"id\": \"thought_1\", \"message_id\": \"msg_1\", \"thought\": \"I should search the handbook\", \"tool\": \"dataset\", \"tool_input\": \"{\\\"query\\\": \\\"leave\\\"}\"}\n\n")
+		fmt.Fprint(w, "data: {\"event\": \"agent_thought\", \"id\": \"thought_1\", \"message_id\": \"msg_1\", \"thought\": \"I should search the handbook\", \"tool\": \"dataset\", \"tool_input\": \"{\\\"query\\\": \\\"leave\\\"}\", \"observation\": \"25 days\"}\n\n")
+		fmt.Fprint(w, "data: {\"event\": \"agent_message\", \"message_id\": \"msg_1\", \"answer\": \"You have \"}\n\n")
+		fmt.Fprint(w, "data: {\"event\": \"agent_message\", \"message_id\": \"msg_1\", \"answer\": \"25 days.\"}\n\n")
+		fmt.Fprint(w, "data: {\"event\": \"message_end\", \"message_id\": \"msg_1\"}\n\n")
+	}))
+	defer server.Close()
+
+	agentService := NewAgentService(client.NewDifyClient(server.URL, "test-key"))
+	response, err := agentService.InvokeAgent(context.Background(), "agent_1", "How much leave do I have?")
+	require.NoError(t, err)
+
+	assert.Equal(t, "msg_1", response.MessageID)
+	assert.Equal(t, "You have 25 days.", response.Answer)
+	assert.Equal(t, []string{"I should search the handbook"}, response.Thoughts)
+	require.Len(t, response.ToolCalls, 1)
+	assert.Equal(t, "dataset", response.ToolCalls[0].Name)
+	assert.Equal(t, map[string]interface{}{"query": "leave"}, response.ToolCalls[0].Inputs)
+	assert.Equal(t, "25 days", response.ToolCalls[0].Outputs["observation"])
+}
+
+// TestInvokeAgentError tests that an error event fails the invocation
+func TestInvokeAgentError(t *testing.T) {
+	testutils.SetupTestDB()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: {\"event\": \"error\", \"code\": \"completion_request_error\", \"message\": \"model unavailable\"}\n\n")
+	}))
+	defer server.Close()
+
+	agentService := NewAgentService(client.NewDifyClient(server.URL, "test-key"))
+	_, err := agentService.InvokeAgent(context.Background(), "agent_1", "Hi")
+	assert.EqualError(t, err, "failed to invoke agent")
+}
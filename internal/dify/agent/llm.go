@@ -0,0 +1,174 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"cdk-office/internal/dify/client"
+)
+
+// LLM message roles
+const (
+	LLMRoleSystem    = "system"
+	LLMRoleUser      = "user"
+	LLMRoleAssistant = "assistant"
+	LLMRoleTool      = "tool"
+)
+
+// LLM decides the next step of an agent run from the conversation so far:
+// either tools to call or the final answer
+type LLM interface {
+	Next(ctx context.Context, req *LLMRequest) (*LLMStep, error)
+}
+
+// LLMRequest is the conversation of an agent run and the tools it may call
+type LLMRequest struct {
+	Messages []LLMMessage
+	Tools    []ToolDefinition
+}
+
+// LLMMessage is a message of the conversation. Tool messages carry the result
+// of the call with the same ToolCallID.
+type LLMMessage struct {
+	Role       string        `json:"role"`
+	Content    string        `json:"content,omitempty"`
+	ToolCalls  []LLMToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string        `json:"tool_call_id,omitempty"`
+}
+
+// LLMToolCall is a tool call requested by the model
+type LLMToolCall struct {
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// LLMStep is the decision of the model: tool calls, or the answer when there are none
+type LLMStep struct {
+	Thought   string        `json:"thought,omitempty"`
+	ToolCalls []LLMToolCall `json:"tool_calls,omitempty"`
+	Answer    string        `json:"answer,omitempty"`
+}
+
+// difyToolProtocol tells a completion model how to call tools
+const difyToolProtocol = `You can call the tools below to help the user. Reply with a single JSON object and nothing else:
+- to call tools: {"thought": "why", "tool_calls": [{"name": "tool_name", "arguments": {...}}]}
+- to answer the user: {"answer": "your answer"}
+Only use data returned by the tools; tool errors are returned as {"error": "..."}.
+
+Tools:
+`
+
+// DifyLLM implements LLM with a Dify completion app, describing the tools and
+// the expected JSON reply in the prompt. Replies that are not JSON are taken as
+// the answer.
+type DifyLLM struct {
+	difyClient client.DifyClientInterface
+}
+
+// NewDifyLLM creates a new instance of DifyLLM
+func NewDifyLLM(difyClient client.DifyClientInterface) *DifyLLM {
+	return &DifyLLM{difyClient: difyClient}
+}
+
+// Next asks the completion app for the next step
+func (l *DifyLLM) Next(ctx context.Context, req *LLMRequest) (*LLMStep, error) {
+	resp, err := l.difyClient.CreateCompletionMessage(ctx, &client.CompletionRequest{
+		Query:        difyPrompt(req),
+		Inputs:       map[string]interface{}{},
+		ResponseMode: "blocking",
+		User:         "cdk-office-agent",
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseLLMReply(resp.Answer), nil
+}
+
+// difyPrompt renders the conversation and tools as a completion prompt
+func difyPrompt(req *LLMRequest) string {
+	var b strings.Builder
+	for _, message := range req.Messages {
+		if message.Role == LLMRoleSystem {
+			b.WriteString(message.Content)
+			b.WriteString("\n\n")
+		}
+	}
+	b.WriteString(difyToolProtocol)
+	for _, tool := range req.Tools {
+		fmt.Fprintf(&b, "- %s: %s Parameters: %s\n", tool.Name, tool.Description, tool.Parameters)
+	}
+	b.WriteString("\nConversation:\n")
+	for _, message := range req.Messages {
+		switch message.Role {
+		case LLMRoleUser:
+			fmt.Fprintf(&b, "User: %s\n", message.Content)
+		case LLMRoleAssistant:
+			calls, _ := json.Marshal(message.ToolCalls)
+			fmt.Fprintf(&b, "Assistant: %s\n", calls)
+		case LLMRoleTool:
+			fmt.Fprintf(&b, "Tool result %s: %s\n", message.ToolCallID, message.Content)
+		}
+	}
+	b.WriteString("Assistant:")
+	return b.String()
+}
+
+// parseLLMReply parses the JSON reply of a completion model, taking text that
+// holds no JSON object as the answer
+func parseLLMReply(reply string) *LLMStep {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start >= 0 && end > start {
+		var step LLMStep
+		if err := json.Unmarshal([]byte(reply[start:end+1]), &step); err == nil && (step.Answer != "" || len(step.ToolCalls) > 0) {
+			return &step
+		}
+	}
+	return &LLMStep{Answer: strings.TrimSpace(reply)}
+}
+
+// ScriptedLLM replays scripted steps in order, recording the requests it
+// receives. It stands in for a model in tests and offline demos.
+type ScriptedLLM struct {
+	mu       sync.Mutex
+	steps    []*LLMStep
+	requests []*LLMRequest
+}
+
+// NewScriptedLLM creates a new instance of ScriptedLLM
+func NewScriptedLLM(steps ...*LLMStep) *ScriptedLLM {
+	return &ScriptedLLM{steps: steps}
+}
+
+// Next returns the next scripted step
+func (l *ScriptedLLM) Next(ctx context.Context, req *LLMRequest) (*LLMStep, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.requests = append(l.requests, req)
+	if len(l.requests) > len(l.steps) {
+		return nil, errors.New("script has no more steps")
+	}
+	return l.steps[len(l.requests)-1], nil
+}
+
+// Requests returns the requests received so far
+func (l *ScriptedLLM) Requests() []*LLMRequest {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]*LLMRequest(nil), l.requests...)
+}
+
+// CallTool returns a scripted step calling a tool
+func CallTool(thought, name string, args map[string]interface{}) *LLMStep {
+	data, _ := json.Marshal(args)
+	return &LLMStep{Thought: thought, ToolCalls: []LLMToolCall{{Name: name, Arguments: data}}}
+}
+
+// Answer returns a scripted step answering the user
+func Answer(answer string) *LLMStep {
+	return &LLMStep{Answer: answer}
+}
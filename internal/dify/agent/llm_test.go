@@ -0,0 +1,68 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cdk-office/internal/dify/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestParseLLMReply tests reading the steps of a completion model from its replies
+func TestParseLLMReply(t *testing.T) {
+	step := parseLLMReply("Sure.\n```json\n{\"thought\": \"search\", \"tool_calls\": [{\"name\": \"search_documents\", \"arguments\": {\"query\": \"leave\"}}]}\n```")
+	require.Len(t, step.ToolCalls, 1)
+	assert.Equal(t, "search", step.Thought)
+	assert.Equal(t, "search_documents", step.ToolCalls[0].Name)
+	assert.JSONEq(t, `{"query":"leave"}`, string(step.ToolCalls[0].Arguments))
+
+	assert.Equal(t, "25 days.", parseLLMReply(`{"answer": "25 days."}`).Answer)
+	assert.Equal(t, "You have 25 days.", parseLLMReply(" You have 25 days. ").Answer)
+	assert.Equal(t, `Use {braces}`, parseLLMReply(`Use {braces}`).Answer)
+}
+
+// stubCompletionClient answers completions with scripted replies
+type stubCompletionClient struct {
+	replies []string
+	prompts []string
+}
+
+func (s *stubCompletionClient) CreateCompletionMessage(ctx context.Context, req *client.CompletionRequest) (*client.CompletionResponse, error) {
+	s.prompts = append(s.prompts, req.Query)
+	if len(s.replies) == 0 {
+		return nil, errors.New("no reply")
+	}
+	reply := s.replies[0]
+	s.replies = s.replies[1:]
+	return &client.CompletionResponse{Answer: reply}, nil
+}
+
+func (s *stubCompletionClient) CreateChatMessage(ctx context.Context, req *client.ChatRequest) (*client.ChatResponse, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s *stubCompletionClient) UploadFile(ctx context.Context, req *client.FileUploadRequest) (*client.FileUploadResponse, error) {
+	return nil, errors.New("not supported")
+}
+
+// TestDifyLLM tests running an agent with a Dify completion app
+func TestDifyLLM(t *testing.T) {
+	difyClient := &stubCompletionClient{replies: []string{
+		`{"thought": "search", "tool_calls": [{"name": "search_documents", "arguments": {"query": "leave"}}]}`,
+		`{"answer": "You have 25 days."}`,
+	}}
+	runner, _, calls := setupRunner(t, NewDifyLLM(difyClient))
+
+	run, err := runner.Run(context.Background(), &RunRequest{UserID: "user_1", Role: "user", Message: "How much leave do I have?"})
+	require.NoError(t, err)
+	assert.Equal(t, "You have 25 days.", run.Answer)
+	assert.Equal(t, 1, calls["search_documents"])
+
+	require.Len(t, difyClient.prompts, 2)
+	assert.Contains(t, difyClient.prompts[0], "- search_documents: Search documents Parameters:")
+	assert.Contains(t, difyClient.prompts[0], "User: How much leave do I have?")
+	assert.Contains(t, difyClient.prompts[1], "Tool result call_1_1:")
+	assert.Contains(t, difyClient.prompts[1], "Leave policy")
+}
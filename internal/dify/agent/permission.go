@@ -0,0 +1,46 @@
+package agent
+
+import (
+	"context"
+	"errors"
+
+	"cdk-office/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// PermissionChecker decides whether a caller holds a role permission
+type PermissionChecker interface {
+	HasPermission(ctx context.Context, caller *Caller, resource, action string) (bool, error)
+}
+
+// RolePermissionChecker checks the permissions granted to the primary role of a
+// caller and to the roles assigned to them. Administrators hold every permission.
+type RolePermissionChecker struct {
+	db *gorm.DB
+}
+
+// NewRolePermissionChecker creates a new instance of RolePermissionChecker
+func NewRolePermissionChecker(db *gorm.DB) *RolePermissionChecker {
+	return &RolePermissionChecker{db: db}
+}
+
+// HasPermission reports whether the caller may perform the action on the resource
+func (c *RolePermissionChecker) HasPermission(ctx context.Context, caller *Caller, resource, action string) (bool, error) {
+	if caller.IsAdmin() {
+		return true, nil
+	}
+
+	var count int64
+	err := c.db.WithContext(ctx).Table("role_permissions").
+		Joins("JOIN roles ON roles.id = role_permissions.role_id").
+		Joins("JOIN permissions ON permissions.id = role_permissions.permission_id").
+		Where("permissions.resource = ? AND permissions.action = ?", resource, action).
+		Where("roles.name = ? OR roles.name IN (?)", caller.Role,
+			c.db.Table("user_roles").Select("role").Where("user_id = ?", caller.UserID)).
+		Count(&count).Error
+	if err != nil {
+		logger.Error("failed to check tool permission", "error", err)
+		return false, errors.New("failed to check permission")
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,342 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"cdk-office/internal/dify/domain"
+	"cdk-office/internal/shared/database"
+	"cdk-office/internal/shared/utils"
+	"cdk-office/pkg/logger"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultMaxSteps is the number of model turns a run may take to answer
+	defaultMaxSteps = 8
+	// maxMaxSteps bounds the step limit a run may ask for
+	maxMaxSteps = 20
+	// toolTimeout bounds the time a tool call may take
+	toolTimeout = 30 * time.Second
+	// maxObservationLength is the length in characters of a tool result sent to the model
+	maxObservationLength = 8000
+	// maxListedRuns is the number of runs returned by ListRuns
+	maxListedRuns = 100
+)
+
+// agentInstructions opens the conversation of every run
+const agentInstructions = `You are the CDK-Office assistant. Use the tools to look up documents, employees, ` +
+	`departments, forms and contracts for the user. Tools run with the user's permissions, so a denied ` +
+	`call means the user may not see that data. Answer from the tool results only.`
+
+// AgentRunnerInterface defines the interface for running agents with tools
+type AgentRunnerInterface interface {
+	Run(ctx context.Context, req *RunRequest) (*RunDetail, error)
+	GetRun(ctx context.Context, caller *Caller, runID string) (*RunDetail, error)
+	ListRuns(ctx context.Context, caller *Caller, userID string) ([]*domain.AgentRun, error)
+	Tools() []ToolDefinition
+}
+
+// AgentRunner implements the AgentRunnerInterface. It lets a model call the
+// registered tools on behalf of a user until it answers or runs out of steps,
+// and records every tool call and the answer of a run for audit.
+type AgentRunner struct {
+	db          *gorm.DB
+	registry    *ToolRegistry
+	llm         LLM
+	permissions PermissionChecker
+}
+
+// NewAgentRunner creates a new instance of AgentRunner
+func NewAgentRunner(registry *ToolRegistry, llm LLM) *AgentRunner {
+	db := database.GetDB()
+	return NewAgentRunnerWithDeps(db, registry, llm, NewRolePermissionChecker(db))
+}
+
+// NewAgentRunnerWithDeps creates a new instance of AgentRunner with specific dependencies
+func NewAgentRunnerWithDeps(db *gorm.DB, registry *ToolRegistry, llm LLM, permissions PermissionChecker) *AgentRunner {
+	return &AgentRunner{
+		db:          db,
+		registry:    registry,
+		llm:         llm,
+		permissions: permissions,
+	}
+}
+
+// RunRequest represents a message for an agent to answer for a user
+type RunRequest struct {
+	AgentID  string
+	UserID   string
+	Role     string
+	Message  string
+	Tools    []string // tools the agent may call, as in AgentConfig.Tools; every tool when empty
+	MaxSteps int      // model turns allowed; defaultMaxSteps when zero
+}
+
+// RunDetail represents an agent run with its trace of tool calls and answer
+type RunDetail struct {
+	*domain.AgentRun
+	Trace []*domain.AgentRunStep `json:"trace"`
+}
+
+// Run lets the model answer a message, calling tools with the permissions of
+// the user. A run that reaches its step limit is returned with status
+// step_limit and no answer.
+func (r *AgentRunner) Run(ctx context.Context, req *RunRequest) (*RunDetail, error) {
+	message := strings.TrimSpace(req.Message)
+	if message == "" {
+		return nil, errors.New("message is required")
+	}
+	tools, err := r.registry.Resolve(req.Tools)
+	if err != nil {
+		return nil, err
+	}
+	maxSteps := req.MaxSteps
+	if maxSteps <= 0 {
+		maxSteps = defaultMaxSteps
+	}
+	if maxSteps > maxMaxSteps {
+		maxSteps = maxMaxSteps
+	}
+
+	allowed := make(map[string]*Tool, len(tools))
+	definitions := make([]ToolDefinition, len(tools))
+	for i, tool := range tools {
+		allowed[tool.Name] = tool
+		definitions[i] = tool.Definition()
+	}
+	caller := &Caller{UserID: req.UserID, Role: req.Role}
+
+	run := &domain.AgentRun{
+		ID:        utils.GenerateAgentRunID(),
+		AgentID:   req.AgentID,
+		UserID:    req.UserID,
+		Role:      req.Role,
+		Message:   message,
+		Status:    domain.AgentRunRunning,
+		StartedAt: time.Now(),
+	}
+	if err := r.db.WithContext(ctx).Create(run).Error; err != nil {
+		logger.Error("failed to create agent run", "error", err)
+		return nil, errors.New("failed to run agent")
+	}
+	detail := &RunDetail{AgentRun: run, Trace: []*domain.AgentRunStep{}}
+
+	messages := []LLMMessage{
+		{Role: LLMRoleSystem, Content: agentInstructions},
+		{Role: LLMRoleUser, Content: message},
+	}
+	for run.Steps < maxSteps {
+		run.Steps++
+		step, err := r.llm.Next(ctx, &LLMRequest{Messages: messages, Tools: definitions})
+		if err != nil {
+			logger.Error("failed to get agent step", "run_id", run.ID, "error", err)
+			r.finish(ctx, run, domain.AgentRunFailed, "failed to get the next step from the model")
+			return nil, errors.New("failed to run agent")
+		}
+
+		if len(step.ToolCalls) == 0 {
+			run.Answer = step.Answer
+			if err := r.record(ctx, detail, &domain.AgentRunStep{
+				Step:    run.Steps,
+				Kind:    domain.AgentStepAnswer,
+				Thought: step.Thought,
+				Result:  step.Answer,
+			}); err != nil {
+				r.finish(ctx, run, domain.AgentRunFailed, "failed to record the answer")
+				return nil, err
+			}
+			r.finish(ctx, run, domain.AgentRunCompleted, "")
+			return detail, nil
+		}
+
+		calls := make([]LLMToolCall, len(step.ToolCalls))
+		for i, call := range step.ToolCalls {
+			if call.ID == "" {
+				call.ID = fmt.Sprintf("call_%d_%d", run.Steps, i+1)
+			}
+			calls[i] = call
+		}
+		messages = append(messages, LLMMessage{Role: LLMRoleAssistant, Content: step.Thought, ToolCalls: calls})
+		for i, call := range calls {
+			entry := r.callTool(ctx, caller, allowed, call)
+			entry.Step = run.Steps
+			if i == 0 {
+				entry.Thought = step.Thought
+			}
+			if err := r.record(ctx, detail, entry); err != nil {
+				r.finish(ctx, run, domain.AgentRunFailed, "failed to record a tool call")
+				return nil, err
+			}
+			messages = append(messages, LLMMessage{Role: LLMRoleTool, ToolCallID: call.ID, Content: observation(entry)})
+		}
+	}
+
+	logger.Warn("agent run reached its step limit", "run_id", run.ID, "max_steps", maxSteps)
+	r.finish(ctx, run, domain.AgentRunStepLimit, fmt.Sprintf("no answer after %d steps", maxSteps))
+	return detail, nil
+}
+
+// callTool runs a tool call requested by the model, returning its trace entry
+func (r *AgentRunner) callTool(ctx context.Context, caller *Caller, allowed map[string]*Tool, call LLMToolCall) *domain.AgentRunStep {
+	entry := &domain.AgentRunStep{
+		Kind:       domain.AgentStepToolCall,
+		ToolCallID: call.ID,
+		ToolName:   call.Name,
+		Arguments:  string(call.Arguments),
+	}
+
+	tool, ok := allowed[call.Name]
+	if !ok {
+		entry.Status = domain.ToolCallRejected
+		entry.Error = "unknown tool: " + call.Name
+		return entry
+	}
+	args, err := tool.ParseArguments(call.Arguments)
+	if err != nil {
+		entry.Status = domain.ToolCallRejected
+		entry.Error = "invalid arguments: " + err.Error()
+		return entry
+	}
+	if tool.Permission != nil {
+		granted, err := r.permissions.HasPermission(ctx, caller, tool.Permission.Resource, tool.Permission.Action)
+		if err != nil {
+			entry.Status = domain.ToolCallFailed
+			entry.Error = err.Error()
+			return entry
+		}
+		if !granted {
+			entry.Status = domain.ToolCallDenied
+			entry.Error = fmt.Sprintf("permission denied: %s %s", tool.Permission.Action, tool.Permission.Resource)
+			return entry
+		}
+	}
+
+	started := time.Now()
+	result, err := runTool(ctx, tool, caller, args)
+	entry.DurationMS = time.Since(started).Milliseconds()
+	if err != nil {
+		entry.Status = domain.ToolCallFailed
+		if errors.Is(err, ErrPermissionDenied) {
+			entry.Status = domain.ToolCallDenied
+		}
+		entry.Error = err.Error()
+		return entry
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		logger.Error("failed to encode tool result", "tool", tool.Name, "error", err)
+		entry.Status = domain.ToolCallFailed
+		entry.Error = "failed to encode tool result"
+		return entry
+	}
+	entry.Status = domain.ToolCallSucceeded
+	entry.Result = string(data)
+	return entry
+}
+
+// runTool runs the handler of a tool with a timeout, turning panics into errors
+func runTool(ctx context.Context, tool *Tool, caller *Caller, args map[string]interface{}) (result interface{}, err error) {
+	ctx, cancel := context.WithTimeout(ctx, toolTimeout)
+	defer cancel()
+	defer func() {
+		if p := recover(); p != nil {
+			logger.Error("agent tool panicked", "tool", tool.Name, "panic", p)
+			result, err = nil, errors.New("tool failed")
+		}
+	}()
+	return tool.Handler(ctx, caller, args)
+}
+
+// observation returns what the model is told of a tool call: its result, or its error
+func observation(entry *domain.AgentRunStep) string {
+	if entry.Status != domain.ToolCallSucceeded {
+		data, _ := json.Marshal(map[string]string{"error": entry.Error})
+		return string(data)
+	}
+	if utf8.RuneCountInString(entry.Result) <= maxObservationLength {
+		return entry.Result
+	}
+	runes := []rune(entry.Result)
+	return string(runes[:maxObservationLength]) + "...(truncated)"
+}
+
+// record saves a trace entry of a run
+func (r *AgentRunner) record(ctx context.Context, detail *RunDetail, entry *domain.AgentRunStep) error {
+	entry.ID = fmt.Sprintf("%s_step_%03d", detail.ID, len(detail.Trace)+1)
+	entry.RunID = detail.ID
+	entry.CreatedAt = time.Now()
+	if err := r.db.WithContext(ctx).Create(entry).Error; err != nil {
+		logger.Error("failed to record agent run step", "run_id", detail.ID, "error", err)
+		return errors.New("failed to run agent")
+	}
+	detail.Trace = append(detail.Trace, entry)
+	return nil
+}
+
+// finish saves the outcome of a run, even when the request was cancelled
+func (r *AgentRunner) finish(ctx context.Context, run *domain.AgentRun, status, message string) {
+	now := time.Now()
+	run.Status = status
+	run.Error = message
+	run.FinishedAt = &now
+	if err := r.db.WithContext(context.WithoutCancel(ctx)).Save(run).Error; err != nil {
+		logger.Error("failed to save agent run", "run_id", run.ID, "error", err)
+		return
+	}
+
+	logger.Info("agent run finished", "run_id", run.ID, "status", status, "steps", run.Steps)
+}
+
+// GetRun returns a run with its trace. Users see their own runs; administrators see every run.
+func (r *AgentRunner) GetRun(ctx context.Context, caller *Caller, runID string) (*RunDetail, error) {
+	var run domain.AgentRun
+	if err := r.db.WithContext(ctx).Where("id = ?", runID).First(&run).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("agent run not found")
+		}
+		logger.Error("failed to find agent run", "error", err)
+		return nil, errors.New("failed to get agent run")
+	}
+	if run.UserID != caller.UserID && !caller.IsAdmin() {
+		return nil, errors.New("agent run not found")
+	}
+
+	var trace []*domain.AgentRunStep
+	if err := r.db.WithContext(ctx).Where("run_id = ?", run.ID).Order("id").Find(&trace).Error; err != nil {
+		logger.Error("failed to get agent run steps", "error", err)
+		return nil, errors.New("failed to get agent run")
+	}
+	return &RunDetail{AgentRun: &run, Trace: trace}, nil
+}
+
+// ListRuns returns the latest runs of a user, newest first. Users list their own
+// runs; administrators may list the runs of any user, or of every user when
+// userID is empty.
+func (r *AgentRunner) ListRuns(ctx context.Context, caller *Caller, userID string) ([]*domain.AgentRun, error) {
+	if !caller.IsAdmin() {
+		userID = caller.UserID
+	}
+	query := r.db.WithContext(ctx)
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var runs []*domain.AgentRun
+	if err := query.Order("started_at desc").Limit(maxListedRuns).Find(&runs).Error; err != nil {
+		logger.Error("failed to list agent runs", "error", err)
+		return nil, errors.New("failed to list agent runs")
+	}
+	return runs, nil
+}
+
+// Tools returns the definitions of every registered tool
+func (r *AgentRunner) Tools() []ToolDefinition {
+	definitions, _ := r.registry.Definitions(nil)
+	return definitions
+}
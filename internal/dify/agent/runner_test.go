@@ -0,0 +1,237 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	authdomain "cdk-office/internal/auth/domain"
+	"cdk-office/internal/dify/domain"
+	"cdk-office/internal/shared/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// setupRunner creates a runner over a test database where the user role may
+// read documents but not employees, with tools counting their calls
+func setupRunner(t *testing.T, llm LLM) (*AgentRunner, *gorm.DB, map[string]int) {
+	db := testutils.SetupTestDB()
+	now := time.Now()
+	require.NoError(t, db.Create(&authdomain.Role{ID: "role_user", Name: "user", CreatedAt: now, UpdatedAt: now}).Error)
+	require.NoError(t, db.Create(&authdomain.Role{ID: "role_auditor", Name: "auditor", CreatedAt: now, UpdatedAt: now}).Error)
+	require.NoError(t, db.Create(&authdomain.Permission{ID: "perm_read_document", Name: "read_document", Resource: "document", Action: "read"}).Error)
+	require.NoError(t, db.Create(&authdomain.Permission{ID: "perm_read_employee", Name: "read_employee", Resource: "employee", Action: "read"}).Error)
+	require.NoError(t, db.Create(&authdomain.RolePermission{ID: "rp_1", RoleID: "role_user", PermissionID: "perm_read_document"}).Error)
+	require.NoError(t, db.Create(&authdomain.RolePermission{ID: "rp_2", RoleID: "role_auditor", PermissionID: "perm_read_employee"}).Error)
+
+	calls := make(map[string]int)
+	registry := NewToolRegistry()
+	require.NoError(t, registry.Register(&Tool{
+		Name:        "search_documents",
+		Description: "Search documents",
+		Parameters:  json.RawMessage(`{"type":"object","properties":{"query":{"type":"string"}},"required":["query"]}`),
+		Permission:  &Permission{Resource: "document", Action: "read"},
+		Handler: func(ctx context.Context, caller *Caller, args map[string]interface{}) (interface{}, error) {
+			calls["search_documents"]++
+			return map[string]interface{}{"documents": []string{"Leave policy"}, "user": caller.UserID}, nil
+		},
+	}))
+	require.NoError(t, registry.Register(&Tool{
+		Name:       "lookup_employee",
+		Parameters: json.RawMessage(`{"type":"object","properties":{"query":{"type":"string"}}}`),
+		Permission: &Permission{Resource: "employee", Action: "read"},
+		Handler: func(ctx context.Context, caller *Caller, args map[string]interface{}) (interface{}, error) {
+			calls["lookup_employee"]++
+			return map[string]string{"real_name": "Li Lei"}, nil
+		},
+	}))
+	require.NoError(t, registry.Register(&Tool{
+		Name:       "check_contract_status",
+		Parameters: json.RawMessage(`{"type":"object"}`),
+		Handler: func(ctx context.Context, caller *Caller, args map[string]interface{}) (interface{}, error) {
+			calls["check_contract_status"]++
+			return nil, fmt.Errorf("%w: contract is not shared with the user", ErrPermissionDenied)
+		},
+	}))
+	require.NoError(t, registry.Register(&Tool{
+		Name:       "create_form_entry",
+		Parameters: json.RawMessage(`{"type":"object"}`),
+		Handler: func(ctx context.Context, caller *Caller, args map[string]interface{}) (interface{}, error) {
+			panic("form service is not set")
+		},
+	}))
+
+	return NewAgentRunnerWithDeps(db, registry, llm, NewRolePermissionChecker(db)), db, calls
+}
+
+// TestAgentRun tests a run calling a tool and answering from its result
+func TestAgentRun(t *testing.T) {
+	llm := NewScriptedLLM(
+		CallTool("I should search the handbook", "search_documents", map[string]interface{}{"query": "leave"}),
+		Answer("You have 25 days of leave."),
+	)
+	runner, db, calls := setupRunner(t, llm)
+
+	run, err := runner.Run(context.Background(), &RunRequest{AgentID: "agent_1", UserID: "user_1", Role: "user", Message: " How much leave do I have? "})
+	require.NoError(t, err)
+	assert.Equal(t, domain.AgentRunCompleted, run.Status)
+	assert.Equal(t, "You have 25 days of leave.", run.Answer)
+	assert.Equal(t, 2, run.Steps)
+	assert.NotNil(t, run.FinishedAt)
+	assert.Equal(t, 1, calls["search_documents"])
+
+	require.Len(t, run.Trace, 2)
+	assert.Equal(t, run.ID+"_step_001", run.Trace[0].ID)
+	assert.Equal(t, domain.AgentStepToolCall, run.Trace[0].Kind)
+	assert.Equal(t, "search_documents", run.Trace[0].ToolName)
+	assert.Equal(t, "I should search the handbook", run.Trace[0].Thought)
+	assert.Equal(t, domain.ToolCallSucceeded, run.Trace[0].Status)
+	assert.JSONEq(t, `{"query":"leave"}`, run.Trace[0].Arguments)
+	assert.JSONEq(t, `{"documents":["Leave policy"],"user":"user_1"}`, run.Trace[0].Result)
+	assert.Equal(t, domain.AgentStepAnswer, run.Trace[1].Kind)
+	assert.Equal(t, 2, run.Trace[1].Step)
+
+	// The model is given the tools and the result of the call
+	requests := llm.Requests()
+	require.Len(t, requests, 2)
+	assert.Len(t, requests[0].Tools, 4)
+	messages := requests[1].Messages
+	require.Len(t, messages, 4)
+	assert.Equal(t, LLMRoleSystem, messages[0].Role)
+	assert.Equal(t, "How much leave do I have?", messages[1].Content)
+	assert.Equal(t, "call_1_1", messages[2].ToolCalls[0].ID)
+	assert.Equal(t, LLMRoleTool, messages[3].Role)
+	assert.Equal(t, "call_1_1", messages[3].ToolCallID)
+	assert.Contains(t, messages[3].Content, "Leave policy")
+
+	// The run and its trace are kept for audit
+	var saved domain.AgentRun
+	require.NoError(t, db.Where("id = ?", run.ID).First(&saved).Error)
+	assert.Equal(t, domain.AgentRunCompleted, saved.Status)
+	assert.Equal(t, "user", saved.Role)
+	var count int64
+	db.Model(&domain.AgentRunStep{}).Where("run_id = ?", run.ID).Count(&count)
+	assert.Equal(t, int64(2), count)
+}
+
+// TestAgentRunToolOutcomes tests that tool calls the user may not make, or with
+// invalid arguments, are refused and reported to the model
+func TestAgentRunToolOutcomes(t *testing.T) {
+	llm := NewScriptedLLM(
+		&LLMStep{ToolCalls: []LLMToolCall{
+			{ID: "a", Name: "lookup_employee", Arguments: json.RawMessage(`{"query":"Li"}`)},
+			{ID: "b", Name: "search_documents", Arguments: json.RawMessage(`{"limit":5}`)},
+			{ID: "c", Name: "send_email", Arguments: json.RawMessage(`{}`)},
+			{ID: "d", Name: "check_contract_status"},
+			{ID: "e", Name: "create_form_entry", Arguments: json.RawMessage(`{}`)},
+		}},
+		Answer("I cannot help with that."),
+	)
+	runner, _, calls := setupRunner(t, llm)
+
+	run, err := runner.Run(context.Background(), &RunRequest{UserID: "user_1", Role: "user", Message: "Who is Li?"})
+	require.NoError(t, err)
+	require.Len(t, run.Trace, 6)
+
+	assert.Equal(t, domain.ToolCallDenied, run.Trace[0].Status)
+	assert.Equal(t, "permission denied: read employee", run.Trace[0].Error)
+	assert.Equal(t, domain.ToolCallRejected, run.Trace[1].Status)
+	assert.Equal(t, "invalid arguments: query is required", run.Trace[1].Error)
+	assert.Equal(t, domain.ToolCallRejected, run.Trace[2].Status)
+	assert.Equal(t, "unknown tool: send_email", run.Trace[2].Error)
+	assert.Equal(t, domain.ToolCallDenied, run.Trace[3].Status)
+	assert.Equal(t, domain.ToolCallFailed, run.Trace[4].Status)
+	assert.Equal(t, "tool failed", run.Trace[4].Error)
+	assert.Zero(t, calls["lookup_employee"])
+	assert.Zero(t, calls["search_documents"])
+	assert.Equal(t, 1, calls["check_contract_status"])
+
+	messages := llm.Requests()[1].Messages
+	assert.JSONEq(t, `{"error":"permission denied: read employee"}`, messages[3].Content)
+
+	// A role assigned to the user grants its permissions too
+	runner2, db, calls2 := setupRunner(t, NewScriptedLLM(
+		CallTool("", "lookup_employee", map[string]interface{}{"query": "Li"}),
+		Answer("Li Lei"),
+	))
+	require.NoError(t, db.Create(&authdomain.UserRole{ID: "ur_1", UserID: "user_2", Role: "auditor"}).Error)
+	run, err = runner2.Run(context.Background(), &RunRequest{UserID: "user_2", Role: "user", Message: "Who is Li?"})
+	require.NoError(t, err)
+	assert.Equal(t, domain.ToolCallSucceeded, run.Trace[0].Status)
+	assert.Equal(t, 1, calls2["lookup_employee"])
+}
+
+// TestAgentRunLimits tests the step limit, the tools an agent may call and model failures
+func TestAgentRunLimits(t *testing.T) {
+	t.Run("StepLimit", func(t *testing.T) {
+		step := CallTool("", "search_documents", map[string]interface{}{"query": "leave"})
+		runner, _, calls := setupRunner(t, NewScriptedLLM(step, step, step, step))
+		run, err := runner.Run(context.Background(), &RunRequest{UserID: "user_1", Role: "user", Message: "Loop", MaxSteps: 3})
+		require.NoError(t, err)
+		assert.Equal(t, domain.AgentRunStepLimit, run.Status)
+		assert.Equal(t, 3, run.Steps)
+		assert.Empty(t, run.Answer)
+		assert.Equal(t, 3, calls["search_documents"])
+	})
+
+	t.Run("AllowedTools", func(t *testing.T) {
+		llm := NewScriptedLLM(CallTool("", "search_documents", map[string]interface{}{"query": "leave"}), Answer("No."))
+		runner, _, _ := setupRunner(t, llm)
+		run, err := runner.Run(context.Background(), &RunRequest{UserID: "user_1", Role: "admin", Message: "Hi", Tools: []string{"lookup_employee"}})
+		require.NoError(t, err)
+		assert.Equal(t, "unknown tool: search_documents", run.Trace[0].Error)
+		require.Len(t, llm.Requests()[0].Tools, 1)
+		assert.Equal(t, "lookup_employee", llm.Requests()[0].Tools[0].Name)
+
+		_, err = runner.Run(context.Background(), &RunRequest{UserID: "user_1", Message: "Hi", Tools: []string{"send_email"}})
+		assert.EqualError(t, err, "unknown tool: send_email")
+		_, err = runner.Run(context.Background(), &RunRequest{UserID: "user_1", Message: " "})
+		assert.EqualError(t, err, "message is required")
+	})
+
+	t.Run("ModelFailure", func(t *testing.T) {
+		runner, db, _ := setupRunner(t, NewScriptedLLM())
+		_, err := runner.Run(context.Background(), &RunRequest{UserID: "user_1", Role: "user", Message: "Hi"})
+		assert.EqualError(t, err, "failed to run agent")
+
+		var run domain.AgentRun
+		require.NoError(t, db.Where("user_id = ?", "user_1").First(&run).Error)
+		assert.Equal(t, domain.AgentRunFailed, run.Status)
+		assert.NotEmpty(t, run.Error)
+	})
+}
+
+// TestAgentRunHistory tests that users only see their own runs and administrators see every run
+func TestAgentRunHistory(t *testing.T) {
+	runner, _, _ := setupRunner(t, NewScriptedLLM(Answer("Hello"), Answer("Hi")))
+	run1, err := runner.Run(context.Background(), &RunRequest{UserID: "user_1", Role: "user", Message: "Hello"})
+	require.NoError(t, err)
+	_, err = runner.Run(context.Background(), &RunRequest{UserID: "user_2", Role: "user", Message: "Hi"})
+	require.NoError(t, err)
+
+	user1 := &Caller{UserID: "user_1", Role: "user"}
+	detail, err := runner.GetRun(context.Background(), user1, run1.ID)
+	require.NoError(t, err)
+	require.Len(t, detail.Trace, 1)
+	assert.Equal(t, "Hello", detail.Trace[0].Result)
+
+	_, err = runner.GetRun(context.Background(), &Caller{UserID: "user_2", Role: "user"}, run1.ID)
+	assert.EqualError(t, err, "agent run not found")
+	_, err = runner.GetRun(context.Background(), &Caller{UserID: "admin_1", Role: "admin"}, run1.ID)
+	assert.NoError(t, err)
+	_, err = runner.GetRun(context.Background(), user1, "agent_run_missing")
+	assert.EqualError(t, err, "agent run not found")
+
+	runs, err := runner.ListRuns(context.Background(), user1, "user_2")
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, run1.ID, runs[0].ID)
+	runs, err = runner.ListRuns(context.Background(), &Caller{UserID: "admin_1", Role: "admin"}, "")
+	require.NoError(t, err)
+	assert.Len(t, runs, 2)
+
+	assert.Len(t, runner.Tools(), 4)
+}
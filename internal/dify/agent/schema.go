@@ -0,0 +1,169 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// jsonSchema is the subset of JSON Schema used to declare tool parameters:
+// types, object properties and required names, array items, enums and bounds
+type jsonSchema struct {
+	Type                 string                 `json:"type"`
+	Description          string                 `json:"description"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []interface{}          `json:"enum"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+}
+
+// parseSchema parses the parameters schema of a tool, which must describe an object
+func parseSchema(data json.RawMessage) (*jsonSchema, error) {
+	var schema jsonSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("invalid parameters schema: %w", err)
+	}
+	if schema.Type != "object" {
+		return nil, fmt.Errorf("parameters schema must be of type object, got %q", schema.Type)
+	}
+	if err := schema.check("parameters"); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// check reports types the validator does not support
+func (s *jsonSchema) check(path string) error {
+	switch s.Type {
+	case "object":
+		for _, name := range s.Required {
+			if _, ok := s.Properties[name]; !ok {
+				return fmt.Errorf("%s: required property %q is not declared", path, name)
+			}
+		}
+		for name, property := range s.Properties {
+			if err := property.check(path + "." + name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		if s.Items != nil {
+			return s.Items.check(path + "[]")
+		}
+	case "string", "integer", "number", "boolean":
+	default:
+		return fmt.Errorf("%s: unsupported type %q", path, s.Type)
+	}
+	return nil
+}
+
+// validate checks a value decoded from JSON against the schema
+func (s *jsonSchema) validate(value interface{}, path string) error {
+	switch s.Type {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an object", path)
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				return fmt.Errorf("%s is required", joinPath(path, name))
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			property, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("%s is not allowed", joinPath(path, name))
+				}
+				continue
+			}
+			if err := property.validate(object[name], joinPath(path, name)); err != nil {
+				return err
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an array", path)
+		}
+		if s.Items != nil {
+			for i, item := range items {
+				if err := s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", path)
+		}
+		length := utf8.RuneCountInString(text)
+		if s.MinLength != nil && length < *s.MinLength {
+			return fmt.Errorf("%s must be at least %d characters", path, *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			return fmt.Errorf("%s must be at most %d characters", path, *s.MaxLength)
+		}
+	case "integer", "number":
+		number, ok := value.(float64)
+		if !ok || (s.Type == "integer" && number != math.Trunc(number)) {
+			return fmt.Errorf("%s must be %s", path, numberNoun(s.Type))
+		}
+		if s.Minimum != nil && number < *s.Minimum {
+			return fmt.Errorf("%s must be at least %g", path, *s.Minimum)
+		}
+		if s.Maximum != nil && number > *s.Maximum {
+			return fmt.Errorf("%s must be at most %g", path, *s.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", path)
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		for _, allowed := range s.Enum {
+			if reflect.DeepEqual(value, allowed) {
+				return nil
+			}
+		}
+		values := make([]string, len(s.Enum))
+		for i, allowed := range s.Enum {
+			values[i] = fmt.Sprint(allowed)
+		}
+		return fmt.Errorf("%s must be one of %s", path, strings.Join(values, ", "))
+	}
+	return nil
+}
+
+// joinPath returns the path of a property of an object
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// numberNoun names a numeric type in error messages
+func numberNoun(typeName string) string {
+	if typeName == "integer" {
+		return "an integer"
+	}
+	return "a number"
+}
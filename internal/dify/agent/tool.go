@@ -0,0 +1,163 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// adminRole is the role allowed to run every tool
+const adminRole = "admin"
+
+// toolNamePattern restricts tool names to what function-calling models accept
+var toolNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// ErrPermissionDenied is wrapped by the errors of tools refusing data the caller may not see
+var ErrPermissionDenied = errors.New("permission denied")
+
+// Caller is the user an agent runs for. Tools run with the caller's permissions
+// and only see the data the caller may see.
+type Caller struct {
+	UserID string
+	Role   string
+}
+
+// IsAdmin reports whether the caller is an administrator
+func (c *Caller) IsAdmin() bool {
+	return c.Role == adminRole
+}
+
+// ToolHandler runs a tool with arguments validated against its parameters schema.
+// The result is returned to the model as JSON; errors are returned to the model
+// as the outcome of the call, so their messages must not leak internals.
+type ToolHandler func(ctx context.Context, caller *Caller, args map[string]interface{}) (interface{}, error)
+
+// Permission is a role permission a caller needs to run a tool
+type Permission struct {
+	Resource string
+	Action   string
+}
+
+// Tool is an operation agents can call
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage // JSON Schema of the arguments object
+	Permission  *Permission     // nil when the tool scopes its data to the caller by itself
+	Handler     ToolHandler
+
+	schema *jsonSchema
+}
+
+// ToolDefinition describes a tool to the model
+type ToolDefinition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+// ToolRegistry holds the tools agents can call
+type ToolRegistry struct {
+	mu    sync.RWMutex
+	tools map[string]*Tool
+}
+
+// NewToolRegistry creates a new instance of ToolRegistry
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{tools: make(map[string]*Tool)}
+}
+
+// Register adds a tool, checking its name and parameters schema
+func (r *ToolRegistry) Register(tool *Tool) error {
+	if !toolNamePattern.MatchString(tool.Name) {
+		return fmt.Errorf("invalid tool name %q", tool.Name)
+	}
+	if tool.Handler == nil {
+		return fmt.Errorf("tool %s has no handler", tool.Name)
+	}
+	schema, err := parseSchema(tool.Parameters)
+	if err != nil {
+		return fmt.Errorf("tool %s: %w", tool.Name, err)
+	}
+	tool.schema = schema
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tools[tool.Name]; ok {
+		return fmt.Errorf("tool %s is already registered", tool.Name)
+	}
+	r.tools[tool.Name] = tool
+	return nil
+}
+
+// Get returns a registered tool
+func (r *ToolRegistry) Get(name string) (*Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// Resolve returns the tools of the given names, or every tool when no name is given
+func (r *ToolRegistry) Resolve(names []string) ([]*Tool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var tools []*Tool
+	if len(names) == 0 {
+		for _, tool := range r.tools {
+			tools = append(tools, tool)
+		}
+	} else {
+		seen := make(map[string]bool)
+		for _, name := range names {
+			tool, ok := r.tools[name]
+			if !ok {
+				return nil, errors.New("unknown tool: " + name)
+			}
+			if !seen[name] {
+				seen[name] = true
+				tools = append(tools, tool)
+			}
+		}
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	return tools, nil
+}
+
+// Definitions returns the definitions of the given tools, or of every tool
+func (r *ToolRegistry) Definitions(names []string) ([]ToolDefinition, error) {
+	tools, err := r.Resolve(names)
+	if err != nil {
+		return nil, err
+	}
+	definitions := make([]ToolDefinition, len(tools))
+	for i, tool := range tools {
+		definitions[i] = tool.Definition()
+	}
+	return definitions, nil
+}
+
+// Definition returns the definition of the tool given to the model
+func (t *Tool) Definition() ToolDefinition {
+	return ToolDefinition{Name: t.Name, Description: t.Description, Parameters: t.Parameters}
+}
+
+// ParseArguments decodes the JSON arguments of a call and validates them against
+// the parameters schema of the tool
+func (t *Tool) ParseArguments(data json.RawMessage) (map[string]interface{}, error) {
+	args := make(map[string]interface{})
+	if len(data) > 0 && string(data) != "null" {
+		if err := json.Unmarshal(data, &args); err != nil {
+			return nil, errors.New("arguments must be a JSON object")
+		}
+	}
+	if err := t.schema.validate(args, ""); err != nil {
+		return nil, err
+	}
+	return args, nil
+}
@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// noopHandler is a tool handler returning its arguments
+func noopHandler(ctx context.Context, caller *Caller, args map[string]interface{}) (interface{}, error) {
+	return args, nil
+}
+
+// TestToolRegistry tests registering and resolving tools
+func TestToolRegistry(t *testing.T) {
+	registry := NewToolRegistry()
+	require.NoError(t, registry.Register(&Tool{Name: "search_documents", Parameters: json.RawMessage(`{"type":"object"}`), Handler: noopHandler}))
+	require.NoError(t, registry.Register(&Tool{Name: "lookup_employee", Parameters: json.RawMessage(`{"type":"object"}`), Handler: noopHandler}))
+
+	for name, tool := range map[string]*Tool{
+		"invalid name":          {Name: "Search Documents", Parameters: json.RawMessage(`{"type":"object"}`), Handler: noopHandler},
+		"no handler":            {Name: "no_handler", Parameters: json.RawMessage(`{"type":"object"}`)},
+		"not an object":         {Name: "not_object", Parameters: json.RawMessage(`{"type":"string"}`), Handler: noopHandler},
+		"undeclared required":   {Name: "undeclared", Parameters: json.RawMessage(`{"type":"object","required":["query"]}`), Handler: noopHandler},
+		"unsupported type":      {Name: "unsupported", Parameters: json.RawMessage(`{"type":"object","properties":{"at":{"type":"date"}}}`), Handler: noopHandler},
+		"already registered":    {Name: "search_documents", Parameters: json.RawMessage(`{"type":"object"}`), Handler: noopHandler},
+		"invalid schema syntax": {Name: "bad_json", Parameters: json.RawMessage(`{`), Handler: noopHandler},
+	} {
+		assert.Error(t, registry.Register(tool), name)
+	}
+
+	tools, err := registry.Resolve(nil)
+	require.NoError(t, err)
+	require.Len(t, tools, 2)
+	assert.Equal(t, "lookup_employee", tools[0].Name)
+	assert.Equal(t, "search_documents", tools[1].Name)
+
+	tools, err = registry.Resolve([]string{"search_documents", "search_documents"})
+	require.NoError(t, err)
+	assert.Len(t, tools, 1)
+
+	_, err = registry.Resolve([]string{"send_email"})
+	assert.EqualError(t, err, "unknown tool: send_email")
+
+	definitions, err := registry.Definitions([]string{"lookup_employee"})
+	require.NoError(t, err)
+	require.Len(t, definitions, 1)
+	assert.JSONEq(t, `{"name":"lookup_employee","description":"","parameters":{"type":"object"}}`, mustMarshal(t, definitions[0]))
+}
+
+// TestParseArguments tests validating tool arguments against the parameters schema
+func TestParseArguments(t *testing.T) {
+	registry := NewToolRegistry()
+	tool := &Tool{
+		Name: "search_documents",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"query": {"type": "string", "minLength": 1},
+				"limit": {"type": "integer", "minimum": 1, "maximum": 20},
+				"score": {"type": "number"},
+				"exact": {"type": "boolean"},
+				"sort": {"type": "string", "enum": ["score", "date"]},
+				"tags": {"type": "array", "items": {"type": "string"}},
+				"filter": {"type": "object", "properties": {"team_id": {"type": "string"}}, "required": ["team_id"]}
+			},
+			"required": ["query"],
+			"additionalProperties": false
+		}`),
+		Handler: noopHandler,
+	}
+	require.NoError(t, registry.Register(tool))
+
+	args, err := tool.ParseArguments(json.RawMessage(`{"query":"leave","limit":5,"tags":["hr"],"filter":{"team_id":"team_1"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "leave", args["query"])
+	assert.Equal(t, float64(5), args["limit"])
+
+	for arguments, message := range map[string]string{
+		`[]`:                            "arguments must be a JSON object",
+		`{}`:                            "query is required",
+		`null`:                          "query is required",
+		`{"query":""}`:                  "query must be at least 1 characters",
+		`{"query":1}`:                   "query must be a string",
+		`{"query":"a","limit":2.5}`:     "limit must be an integer",
+		`{"query":"a","limit":21}`:      "limit must be at most 20",
+		`{"query":"a","score":"high"}`:  "score must be a number",
+		`{"query":"a","exact":"yes"}`:   "exact must be a boolean",
+		`{"query":"a","sort":"title"}`:  "sort must be one of score, date",
+		`{"query":"a","tags":"hr"}`:     "tags must be an array",
+		`{"query":"a","tags":["hr",1]}`: "tags[1] must be a string",
+		`{"query":"a","filter":{}}`:     "filter.team_id is required",
+		`{"query":"a","team_id":"t"}`:   "team_id is not allowed",
+	} {
+		_, err := tool.ParseArguments(json.RawMessage(arguments))
+		assert.EqualError(t, err, message, arguments)
+	}
+}
+
+// mustMarshal encodes a value as JSON
+func mustMarshal(t *testing.T, value interface{}) string {
+	data, err := json.Marshal(value)
+	require.NoError(t, err)
+	return string(data)
+}
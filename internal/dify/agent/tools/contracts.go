@@ -0,0 +1,102 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	businessdomain "cdk-office/internal/business/domain"
+	"cdk-office/internal/dify/agent"
+	"cdk-office/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// checkContractStatusParameters is the parameters schema of check_contract_status
+const checkContractStatusParameters = `{
+	"type": "object",
+	"properties": {
+		"contract_id": {"type": "string", "minLength": 1, "description": "ID of the contract"}
+	},
+	"required": ["contract_id"],
+	"additionalProperties": false
+}`
+
+// signerResult is the signature state of a signer returned by check_contract_status
+type signerResult struct {
+	SignerID   string     `json:"signer_id"`
+	Position   int        `json:"position"`
+	Status     string     `json:"status"`
+	Deadline   *time.Time `json:"deadline,omitempty"`
+	SignedAt   *time.Time `json:"signed_at,omitempty"`
+	DeclinedAt *time.Time `json:"declined_at,omitempty"`
+}
+
+// checkContractStatusTool reports the signing state of a contract
+func (t *toolset) checkContractStatusTool() *agent.Tool {
+	return &agent.Tool{
+		Name:        "check_contract_status",
+		Description: "Get the signing status of a contract and of each of its signers.",
+		Parameters:  json.RawMessage(checkContractStatusParameters),
+		Permission:  &agent.Permission{Resource: "contract", Action: "read"},
+		Handler:     t.checkContractStatus,
+	}
+}
+
+// checkContractStatus returns the status of a contract the caller created, signs
+// or belongs to the team of
+func (t *toolset) checkContractStatus(ctx context.Context, caller *agent.Caller, args map[string]interface{}) (interface{}, error) {
+	var contract businessdomain.Contract
+	if err := t.db.WithContext(ctx).Where("id = ?", stringArg(args, "contract_id")).First(&contract).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("contract not found")
+		}
+		logger.Error("failed to find contract", "error", err)
+		return nil, errors.New("failed to check contract status")
+	}
+
+	var signers []*businessdomain.ContractSigner
+	if err := t.db.WithContext(ctx).Where("contract_id = ?", contract.ID).Order("position").Find(&signers).Error; err != nil {
+		logger.Error("failed to find contract signers", "error", err)
+		return nil, errors.New("failed to check contract status")
+	}
+
+	allowed := contract.CreatedBy == caller.UserID
+	for _, signer := range signers {
+		if signer.SignerID == caller.UserID {
+			allowed = true
+		}
+	}
+	if !allowed {
+		member, err := t.isTeamMember(ctx, caller, contract.TeamID)
+		if err != nil {
+			return nil, err
+		}
+		if !member {
+			return nil, fmt.Errorf("%w: contract %s is not shared with the user", agent.ErrPermissionDenied, contract.ID)
+		}
+	}
+
+	results := make([]*signerResult, len(signers))
+	for i, signer := range signers {
+		results[i] = &signerResult{
+			SignerID:   signer.SignerID,
+			Position:   signer.Position,
+			Status:     signer.Status,
+			Deadline:   signer.Deadline,
+			SignedAt:   signer.SignedAt,
+			DeclinedAt: signer.DeclinedAt,
+		}
+	}
+	return map[string]interface{}{
+		"id":            contract.ID,
+		"title":         contract.Title,
+		"team_id":       contract.TeamID,
+		"status":        contract.Status,
+		"signing_order": contract.SigningOrder,
+		"sent_at":       contract.SentAt,
+		"completed_at":  contract.CompletedAt,
+		"signers":       results,
+	}, nil
+}
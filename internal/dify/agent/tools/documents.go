@@ -0,0 +1,102 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cdk-office/internal/dify/agent"
+	"cdk-office/internal/document/search"
+)
+
+// searchDocumentsParameters is the parameters schema of search_documents
+const searchDocumentsParameters = `{
+	"type": "object",
+	"properties": {
+		"query": {"type": "string", "minLength": 1, "maxLength": 200, "description": "Words to search for"},
+		"team_id": {"type": "string", "description": "Only search the documents of this team"},
+		"limit": {"type": "integer", "minimum": 1, "maximum": 20, "description": "Number of documents to return, 5 by default"}
+	},
+	"required": ["query"],
+	"additionalProperties": false
+}`
+
+// documentResult is a document found by search_documents
+type documentResult struct {
+	ID       string  `json:"id"`
+	Title    string  `json:"title"`
+	TeamID   string  `json:"team_id"`
+	MimeType string  `json:"mime_type"`
+	Snippet  string  `json:"snippet,omitempty"`
+	Score    float64 `json:"score"`
+}
+
+// searchDocumentsTool searches the documents the caller may read
+func (t *toolset) searchDocumentsTool() *agent.Tool {
+	return &agent.Tool{
+		Name:        "search_documents",
+		Description: "Search the documents the user may read by keywords and return their titles and matching snippets.",
+		Parameters:  json.RawMessage(searchDocumentsParameters),
+		Permission:  &agent.Permission{Resource: "document", Action: "read"},
+		Handler:     t.searchDocuments,
+	}
+}
+
+// searchDocuments searches the documents of a team, or of every team, keeping
+// those the caller may read
+func (t *toolset) searchDocuments(ctx context.Context, caller *agent.Caller, args map[string]interface{}) (interface{}, error) {
+	teamID := stringArg(args, "team_id")
+	limit := intArg(args, "limit", 5)
+	if teamID != "" {
+		allowed, err := t.access.CanReadTeam(ctx, caller.UserID, caller.Role, teamID)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, fmt.Errorf("%w: cannot read the documents of team %s", agent.ErrPermissionDenied, teamID)
+		}
+	}
+
+	// Ask for more hits than needed, as some may not be readable by the caller
+	result, err := t.searchService.Search(ctx, &search.Query{
+		Text:    stringArg(args, "query"),
+		Filters: search.Filters{TeamID: teamID},
+		Size:    limit * 4,
+	})
+	if err != nil {
+		return nil, err
+	}
+	documentIDs := make([]string, len(result.Hits))
+	for i, hit := range result.Hits {
+		documentIDs[i] = hit.Document.ID
+	}
+	readable, err := t.access.ReadableDocuments(ctx, caller.UserID, caller.Role, documentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	documents := []*documentResult{}
+	for _, hit := range result.Hits {
+		if readable[hit.Document.ID] == nil {
+			continue
+		}
+		documents = append(documents, &documentResult{
+			ID:       hit.Document.ID,
+			Title:    hit.Document.Title,
+			TeamID:   hit.Document.TeamID,
+			MimeType: hit.Document.MimeType,
+			Snippet:  stripMarks(hit.Highlights["body"]),
+			Score:    hit.Score,
+		})
+		if len(documents) == limit {
+			break
+		}
+	}
+	return map[string]interface{}{"documents": documents}, nil
+}
+
+// stripMarks removes the <mark> tags of a search highlight
+func stripMarks(highlight string) string {
+	return strings.NewReplacer("<mark>", "", "</mark>", "").Replace(highlight)
+}
@@ -0,0 +1,184 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"cdk-office/internal/dify/agent"
+	employeedomain "cdk-office/internal/employee/domain"
+	"cdk-office/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// maxEmployees is the number of employees lookup_employee returns
+const maxEmployees = 10
+
+// lookupEmployeeParameters is the parameters schema of lookup_employee
+const lookupEmployeeParameters = `{
+	"type": "object",
+	"properties": {
+		"query": {"type": "string", "minLength": 1, "maxLength": 100, "description": "Employee number, user ID or part of the name"},
+		"team_id": {"type": "string", "description": "Only look in this team"}
+	},
+	"required": ["query"],
+	"additionalProperties": false
+}`
+
+// listDepartmentMembersParameters is the parameters schema of list_department_members
+const listDepartmentMembersParameters = `{
+	"type": "object",
+	"properties": {
+		"department_id": {"type": "string", "minLength": 1, "description": "ID of the department"},
+		"include_inactive": {"type": "boolean", "description": "Also list employees who have left"}
+	},
+	"required": ["department_id"],
+	"additionalProperties": false
+}`
+
+// employeeResult is an employee returned by the employee tools. Personal
+// details such as the birth date are left out.
+type employeeResult struct {
+	ID         string    `json:"id"`
+	EmployeeID string    `json:"employee_id"`
+	UserID     string    `json:"user_id"`
+	RealName   string    `json:"real_name"`
+	TeamID     string    `json:"team_id"`
+	DeptID     string    `json:"dept_id"`
+	Department string    `json:"department,omitempty"`
+	Position   string    `json:"position"`
+	Status     string    `json:"status"`
+	HireDate   time.Time `json:"hire_date"`
+}
+
+// lookupEmployeeTool finds employees of the caller's teams
+func (t *toolset) lookupEmployeeTool() *agent.Tool {
+	return &agent.Tool{
+		Name:        "lookup_employee",
+		Description: "Find employees of the user's teams by employee number, user ID or name, with their department and position.",
+		Parameters:  json.RawMessage(lookupEmployeeParameters),
+		Permission:  &agent.Permission{Resource: "employee", Action: "read"},
+		Handler:     t.lookupEmployee,
+	}
+}
+
+// lookupEmployee finds the employees matching a query in the teams the caller belongs to
+func (t *toolset) lookupEmployee(ctx context.Context, caller *agent.Caller, args map[string]interface{}) (interface{}, error) {
+	text := stringArg(args, "query")
+	query := t.db.WithContext(ctx).
+		Where("employee_id = ? OR user_id = ? OR real_name LIKE ?", text, text, "%"+text+"%")
+	if teamID := stringArg(args, "team_id"); teamID != "" {
+		query = query.Where("team_id = ?", teamID)
+	}
+	if !caller.IsAdmin() {
+		teamIDs, err := t.teamsOf(ctx, caller.UserID)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("team_id IN ?", teamIDs)
+	}
+
+	var employees []*employeedomain.Employee
+	if err := query.Order("real_name, employee_id").Limit(maxEmployees).Find(&employees).Error; err != nil {
+		logger.Error("failed to look up employees", "error", err)
+		return nil, errors.New("failed to look up employees")
+	}
+	results, err := t.employeeResults(ctx, employees)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"employees": results}, nil
+}
+
+// listDepartmentMembersTool lists the employees of a department of the caller's teams
+func (t *toolset) listDepartmentMembersTool() *agent.Tool {
+	return &agent.Tool{
+		Name:        "list_department_members",
+		Description: "List the employees of a department of the user's teams, with the department manager.",
+		Parameters:  json.RawMessage(listDepartmentMembersParameters),
+		Permission:  &agent.Permission{Resource: "employee", Action: "read"},
+		Handler:     t.listDepartmentMembers,
+	}
+}
+
+// listDepartmentMembers lists the employees of a department, active ones only unless asked otherwise
+func (t *toolset) listDepartmentMembers(ctx context.Context, caller *agent.Caller, args map[string]interface{}) (interface{}, error) {
+	var department employeedomain.Department
+	if err := t.db.WithContext(ctx).Where("id = ?", stringArg(args, "department_id")).First(&department).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("department not found")
+		}
+		logger.Error("failed to find department", "error", err)
+		return nil, errors.New("failed to list department members")
+	}
+	member, err := t.isTeamMember(ctx, caller, department.TeamID)
+	if err != nil {
+		return nil, err
+	}
+	if !member {
+		return nil, fmt.Errorf("%w: department %s is not in the user's teams", agent.ErrPermissionDenied, department.ID)
+	}
+
+	query := t.db.WithContext(ctx).Where("dept_id = ?", department.ID)
+	if !boolArg(args, "include_inactive") {
+		query = query.Where("status = ?", activeStatus)
+	}
+	var employees []*employeedomain.Employee
+	if err := query.Order("real_name, employee_id").Find(&employees).Error; err != nil {
+		logger.Error("failed to list department members", "error", err)
+		return nil, errors.New("failed to list department members")
+	}
+	results, err := t.employeeResults(ctx, employees)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"department": map[string]interface{}{
+			"id":         department.ID,
+			"name":       department.Name,
+			"team_id":    department.TeamID,
+			"manager_id": department.ManagerID,
+		},
+		"members": results,
+	}, nil
+}
+
+// employeeResults converts employees to tool results with their department names
+func (t *toolset) employeeResults(ctx context.Context, employees []*employeedomain.Employee) ([]*employeeResult, error) {
+	deptIDs := make([]string, 0, len(employees))
+	for _, employee := range employees {
+		if employee.DeptID != "" {
+			deptIDs = append(deptIDs, employee.DeptID)
+		}
+	}
+	names := make(map[string]string)
+	if len(deptIDs) > 0 {
+		var departments []*employeedomain.Department
+		if err := t.db.WithContext(ctx).Where("id IN ?", deptIDs).Find(&departments).Error; err != nil {
+			logger.Error("failed to find departments", "error", err)
+			return nil, errors.New("failed to look up employees")
+		}
+		for _, department := range departments {
+			names[department.ID] = department.Name
+		}
+	}
+
+	results := make([]*employeeResult, len(employees))
+	for i, employee := range employees {
+		results[i] = &employeeResult{
+			ID:         employee.ID,
+			EmployeeID: employee.EmployeeID,
+			UserID:     employee.UserID,
+			RealName:   employee.RealName,
+			TeamID:     employee.TeamID,
+			DeptID:     employee.DeptID,
+			Department: names[employee.DeptID],
+			Position:   employee.Position,
+			Status:     employee.Status,
+			HireDate:   employee.HireDate,
+		}
+	}
+	return results, nil
+}
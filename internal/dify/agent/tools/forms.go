@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	appservice "cdk-office/internal/app/service"
+	"cdk-office/internal/dify/agent"
+)
+
+// createFormEntryParameters is the parameters schema of create_form_entry
+const createFormEntryParameters = `{
+	"type": "object",
+	"properties": {
+		"form_id": {"type": "string", "minLength": 1, "description": "ID of the form"},
+		"data": {"type": "object", "description": "Field values of the entry, by field name"}
+	},
+	"required": ["form_id", "data"],
+	"additionalProperties": false
+}`
+
+// createFormEntryTool submits a form entry as the caller
+func (t *toolset) createFormEntryTool() *agent.Tool {
+	return &agent.Tool{
+		Name:        "create_form_entry",
+		Description: "Submit an entry to a form of an application the user may write to. The data is checked against the form fields.",
+		Parameters:  json.RawMessage(createFormEntryParameters),
+		// Writing is granted per application, so the handler checks it
+		Handler: t.createFormEntry,
+	}
+}
+
+// createFormEntry submits an entry to a form, as the caller, when they may
+// write to the application of the form
+func (t *toolset) createFormEntry(ctx context.Context, caller *agent.Caller, args map[string]interface{}) (interface{}, error) {
+	form, err := t.formService.GetForm(ctx, stringArg(args, "form_id"))
+	if err != nil {
+		return nil, err
+	}
+	if !caller.IsAdmin() {
+		allowed, err := t.appPermissions.CheckUserPermission(ctx, form.AppID, caller.UserID, "write")
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, fmt.Errorf("%w: cannot write to the application of form %s", agent.ErrPermissionDenied, form.ID)
+		}
+	}
+
+	data, err := json.Marshal(args["data"])
+	if err != nil {
+		return nil, errors.New("failed to encode form data")
+	}
+	entry, err := t.formService.SubmitFormData(ctx, &appservice.SubmitFormDataRequest{
+		FormID:    form.ID,
+		Data:      string(data),
+		CreatedBy: caller.UserID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"entry_id":   entry.ID,
+		"form_id":    entry.FormID,
+		"form_name":  form.Name,
+		"created_at": entry.CreatedAt,
+	}, nil
+}
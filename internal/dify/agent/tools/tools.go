@@ -0,0 +1,116 @@
+// Package tools declares the CDK-Office operations agents can call. Every tool
+// runs for the calling user and only returns data that user may see.
+package tools
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	appservice "cdk-office/internal/app/service"
+	"cdk-office/internal/dify/agent"
+	docservice "cdk-office/internal/document/service"
+	employeedomain "cdk-office/internal/employee/domain"
+	"cdk-office/internal/shared/database"
+	"cdk-office/pkg/config"
+	"cdk-office/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// activeStatus is the status of employees currently employed
+const activeStatus = "active"
+
+// toolset holds the services the built-in tools use
+type toolset struct {
+	db             *gorm.DB
+	searchService  docservice.SearchServiceInterface
+	access         docservice.DocumentAccessInterface
+	formService    appservice.FormServiceInterface
+	appPermissions appservice.AppPermissionServiceInterface
+}
+
+// Register registers the built-in tools
+func Register(registry *agent.ToolRegistry, cfg *config.StorageConfig) error {
+	searchService, err := docservice.NewSearchService(cfg)
+	if err != nil {
+		return err
+	}
+	db := database.GetDB()
+	return RegisterWithDeps(registry, db, searchService, docservice.NewDocumentAccessWithDB(db),
+		appservice.NewFormService(), appservice.NewAppPermissionService())
+}
+
+// RegisterWithDeps registers the built-in tools with specific dependencies
+func RegisterWithDeps(registry *agent.ToolRegistry, db *gorm.DB, searchService docservice.SearchServiceInterface,
+	access docservice.DocumentAccessInterface, formService appservice.FormServiceInterface,
+	appPermissions appservice.AppPermissionServiceInterface) error {
+	t := &toolset{
+		db:             db,
+		searchService:  searchService,
+		access:         access,
+		formService:    formService,
+		appPermissions: appPermissions,
+	}
+	for _, tool := range []*agent.Tool{
+		t.searchDocumentsTool(),
+		t.lookupEmployeeTool(),
+		t.listDepartmentMembersTool(),
+		t.createFormEntryTool(),
+		t.checkContractStatusTool(),
+	} {
+		if err := registry.Register(tool); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// teamsOf returns the teams a user is an active employee of
+func (t *toolset) teamsOf(ctx context.Context, userID string) ([]string, error) {
+	var teamIDs []string
+	if err := t.db.WithContext(ctx).Model(&employeedomain.Employee{}).
+		Where("user_id = ? AND status = ?", userID, activeStatus).
+		Distinct().Pluck("team_id", &teamIDs).Error; err != nil {
+		logger.Error("failed to find the teams of a user", "error", err)
+		return nil, errors.New("failed to check access")
+	}
+	return teamIDs, nil
+}
+
+// isTeamMember reports whether the caller is an administrator or an active employee of a team
+func (t *toolset) isTeamMember(ctx context.Context, caller *agent.Caller, teamID string) (bool, error) {
+	if caller.IsAdmin() {
+		return true, nil
+	}
+	teamIDs, err := t.teamsOf(ctx, caller.UserID)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range teamIDs {
+		if id == teamID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// stringArg returns a string argument, or "" when it is not given
+func stringArg(args map[string]interface{}, name string) string {
+	value, _ := args[name].(string)
+	return value
+}
+
+// intArg returns an integer argument, or fallback when it is not given
+func intArg(args map[string]interface{}, name string, fallback int) int {
+	value, ok := args[name].(float64)
+	if !ok {
+		return fallback
+	}
+	return int(math.Round(value))
+}
+
+// boolArg returns a boolean argument, or false when it is not given
+func boolArg(args map[string]interface{}, name string) bool {
+	value, _ := args[name].(bool)
+	return value
+}
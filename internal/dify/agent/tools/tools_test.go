@@ -0,0 +1,218 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	appdomain "cdk-office/internal/app/domain"
+	appservice "cdk-office/internal/app/service"
+	businessdomain "cdk-office/internal/business/domain"
+	"cdk-office/internal/dify/agent"
+	documentdomain "cdk-office/internal/document/domain"
+	"cdk-office/internal/document/search"
+	docservice "cdk-office/internal/document/service"
+	employeedomain "cdk-office/internal/employee/domain"
+	"cdk-office/internal/shared/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+var (
+	admin  = &agent.Caller{UserID: "admin_1", Role: "admin"}
+	alice  = &agent.Caller{UserID: "user_alice", Role: "user"}
+	bob    = &agent.Caller{UserID: "user_bob", Role: "user"}
+	nobody = &agent.Caller{UserID: "user_nobody", Role: "user"}
+)
+
+// setupTools registers the built-in tools over a test database where alice and
+// bob work in team_1 and carol in team_2
+func setupTools(t *testing.T) (*agent.ToolRegistry, *gorm.DB) {
+	db := testutils.SetupTestDB()
+	hired := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	for _, department := range []*employeedomain.Department{
+		{ID: "dept_hr", Name: "Human Resources", TeamID: "team_1", ManagerID: "user_alice"},
+		{ID: "dept_sales", Name: "Sales", TeamID: "team_2"},
+	} {
+		require.NoError(t, db.Create(department).Error)
+	}
+	for _, employee := range []*employeedomain.Employee{
+		{ID: "emp_1", UserID: "user_alice", TeamID: "team_1", DeptID: "dept_hr", EmployeeID: "E001", RealName: "Alice Wang", Position: "HR manager", Status: "active", HireDate: hired, BirthDate: hired.AddDate(-30, 0, 0)},
+		{ID: "emp_2", UserID: "user_bob", TeamID: "team_1", DeptID: "dept_hr", EmployeeID: "E002", RealName: "Bob Li", Position: "Recruiter", Status: "active", HireDate: hired},
+		{ID: "emp_3", UserID: "user_dave", TeamID: "team_1", DeptID: "dept_hr", EmployeeID: "E003", RealName: "Dave Li", Position: "Recruiter", Status: "terminated", HireDate: hired},
+		{ID: "emp_4", UserID: "user_carol", TeamID: "team_2", DeptID: "dept_sales", EmployeeID: "E004", RealName: "Carol Li", Position: "Sales", Status: "active", HireDate: hired},
+	} {
+		require.NoError(t, db.Create(employee).Error)
+	}
+
+	registry := agent.NewToolRegistry()
+	require.NoError(t, RegisterWithDeps(registry, db,
+		docservice.NewSearchServiceWithEngine(db, search.NewEngine(db), nil, nil),
+		docservice.NewDocumentAccessWithDB(db), appservice.NewFormService(), appservice.NewAppPermissionService()))
+	return registry, db
+}
+
+// call runs a tool with JSON arguments and returns its result as JSON
+func call(t *testing.T, registry *agent.ToolRegistry, caller *agent.Caller, name, arguments string) (string, error) {
+	t.Helper()
+	tool, ok := registry.Get(name)
+	require.True(t, ok, name)
+	args, err := tool.ParseArguments(json.RawMessage(arguments))
+	require.NoError(t, err)
+	result, err := tool.Handler(context.Background(), caller, args)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(result)
+	require.NoError(t, err)
+	return string(data), nil
+}
+
+// TestRegister tests that the built-in tools are declared with their permissions
+func TestRegister(t *testing.T) {
+	registry, _ := setupTools(t)
+	definitions, err := registry.Definitions(nil)
+	require.NoError(t, err)
+	names := make([]string, len(definitions))
+	for i, definition := range definitions {
+		names[i] = definition.Name
+		assert.NotEmpty(t, definition.Description)
+	}
+	assert.Equal(t, []string{"check_contract_status", "create_form_entry", "list_department_members", "lookup_employee", "search_documents"}, names)
+
+	tool, _ := registry.Get("lookup_employee")
+	assert.Equal(t, &agent.Permission{Resource: "employee", Action: "read"}, tool.Permission)
+	tool, _ = registry.Get("create_form_entry")
+	assert.Nil(t, tool.Permission)
+
+	assert.Error(t, RegisterWithDeps(registry, nil, nil, nil, nil, nil))
+}
+
+// TestSearchDocuments tests that only documents the caller may read are found
+func TestSearchDocuments(t *testing.T) {
+	registry, db := setupTools(t)
+	engine := search.NewEngine(db)
+	for _, document := range []*documentdomain.Document{
+		{ID: "doc_1", Title: "Leave policy", TeamID: "team_1", OwnerID: "user_alice", MimeType: "application/pdf"},
+		{ID: "doc_2", Title: "Sales leave calendar", TeamID: "team_2", OwnerID: "user_carol", MimeType: "text/plain"},
+	} {
+		require.NoError(t, db.Create(document).Error)
+		require.NoError(t, engine.IndexDocument(context.Background(), &documentdomain.SearchIndexEntry{
+			DocumentID: document.ID, Title: document.Title, Body: "Employees get 25 days of annual leave.",
+		}))
+	}
+
+	result, err := call(t, registry, bob, "search_documents", `{"query":"leave"}`)
+	require.NoError(t, err)
+	assert.Contains(t, result, `"id":"doc_1"`)
+	assert.Contains(t, result, `"snippet":"Employees get 25 days of annual leave."`)
+	assert.NotContains(t, result, "doc_2")
+
+	result, err = call(t, registry, admin, "search_documents", `{"query":"leave","limit":1}`)
+	require.NoError(t, err)
+	var found struct{ Documents []documentResult }
+	require.NoError(t, json.Unmarshal([]byte(result), &found))
+	assert.Len(t, found.Documents, 1)
+
+	_, err = call(t, registry, bob, "search_documents", `{"query":"leave","team_id":"team_2"}`)
+	assert.True(t, errors.Is(err, agent.ErrPermissionDenied))
+}
+
+// TestEmployeeTools tests looking up employees and department members within the caller's teams
+func TestEmployeeTools(t *testing.T) {
+	registry, _ := setupTools(t)
+
+	result, err := call(t, registry, bob, "lookup_employee", `{"query":"Li"}`)
+	require.NoError(t, err)
+	assert.Contains(t, result, `"real_name":"Bob Li"`)
+	assert.Contains(t, result, `"department":"Human Resources"`)
+	assert.NotContains(t, result, "Carol")
+	assert.NotContains(t, result, "birth")
+
+	result, err = call(t, registry, bob, "lookup_employee", `{"query":"E001"}`)
+	require.NoError(t, err)
+	assert.Contains(t, result, "Alice Wang")
+
+	result, err = call(t, registry, nobody, "lookup_employee", `{"query":"Li"}`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"employees":[]}`, result)
+
+	result, err = call(t, registry, admin, "lookup_employee", `{"query":"Li","team_id":"team_2"}`)
+	require.NoError(t, err)
+	assert.Contains(t, result, "Carol Li")
+	assert.NotContains(t, result, "Bob Li")
+
+	result, err = call(t, registry, alice, "list_department_members", `{"department_id":"dept_hr"}`)
+	require.NoError(t, err)
+	var members struct {
+		Department map[string]string
+		Members    []employeeResult
+	}
+	require.NoError(t, json.Unmarshal([]byte(result), &members))
+	assert.Equal(t, "user_alice", members.Department["manager_id"])
+	assert.Len(t, members.Members, 2)
+
+	result, err = call(t, registry, alice, "list_department_members", `{"department_id":"dept_hr","include_inactive":true}`)
+	require.NoError(t, err)
+	assert.Contains(t, result, "Dave Li")
+
+	_, err = call(t, registry, alice, "list_department_members", `{"department_id":"dept_sales"}`)
+	assert.True(t, errors.Is(err, agent.ErrPermissionDenied))
+	_, err = call(t, registry, alice, "list_department_members", `{"department_id":"dept_missing"}`)
+	assert.EqualError(t, err, "department not found")
+}
+
+// TestCreateFormEntry tests that entries are submitted as the caller when they may write to the application
+func TestCreateFormEntry(t *testing.T) {
+	registry, db := setupTools(t)
+	require.NoError(t, db.Create(&appdomain.FormData{ID: "form_1", AppID: "app_1", Name: "Leave request", IsActive: true,
+		Schema: `{"fields":[{"name":"days","type":"number"}]}`}).Error)
+	require.NoError(t, db.Create(&appdomain.AppPermission{ID: "app_perm_1", AppID: "app_1", Name: "Write", Permission: "write"}).Error)
+	require.NoError(t, db.Create(&appdomain.AppUserPermission{ID: "app_user_perm_1", AppID: "app_1", UserID: "user_alice", PermissionID: "app_perm_1"}).Error)
+
+	result, err := call(t, registry, alice, "create_form_entry", `{"form_id":"form_1","data":{"days":3}}`)
+	require.NoError(t, err)
+	assert.Contains(t, result, `"form_name":"Leave request"`)
+	var entry appdomain.FormDataEntry
+	require.NoError(t, db.Where("form_id = ?", "form_1").First(&entry).Error)
+	assert.Equal(t, "user_alice", entry.CreatedBy)
+	assert.JSONEq(t, `{"days":3}`, entry.Data)
+
+	_, err = call(t, registry, bob, "create_form_entry", `{"form_id":"form_1","data":{"days":3}}`)
+	assert.True(t, errors.Is(err, agent.ErrPermissionDenied))
+	_, err = call(t, registry, admin, "create_form_entry", `{"form_id":"form_missing","data":{}}`)
+	assert.EqualError(t, err, "form not found")
+}
+
+// TestCheckContractStatus tests that contract status is returned to its creator, signers and team
+func TestCheckContractStatus(t *testing.T) {
+	registry, db := setupTools(t)
+	sentAt := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	require.NoError(t, db.Create(&businessdomain.Contract{ID: "contract_1", TeamID: "team_2", Title: "Supply agreement",
+		Status: businessdomain.ContractPartiallySigned, CreatedBy: "user_carol", SigningOrder: businessdomain.SigningOrderSequential, SentAt: &sentAt}).Error)
+	require.NoError(t, db.Create(&businessdomain.ContractSigner{ID: "signer_1", ContractID: "contract_1", SignerID: "user_bob", Position: 1, Status: businessdomain.SignerSigned, SignedAt: &sentAt}).Error)
+	require.NoError(t, db.Create(&businessdomain.ContractSigner{ID: "signer_2", ContractID: "contract_1", SignerID: "user_carol", Position: 2, Status: businessdomain.SignerPending}).Error)
+
+	result, err := call(t, registry, bob, "check_contract_status", `{"contract_id":"contract_1"}`)
+	require.NoError(t, err)
+	var status struct {
+		Status  string
+		Signers []signerResult
+	}
+	require.NoError(t, json.Unmarshal([]byte(result), &status))
+	assert.Equal(t, businessdomain.ContractPartiallySigned, status.Status)
+	require.Len(t, status.Signers, 2)
+	assert.Equal(t, "user_bob", status.Signers[0].SignerID)
+	assert.Equal(t, businessdomain.SignerPending, status.Signers[1].Status)
+	assert.NotContains(t, result, "content")
+
+	_, err = call(t, registry, alice, "check_contract_status", `{"contract_id":"contract_1"}`)
+	assert.True(t, errors.Is(err, agent.ErrPermissionDenied))
+	_, err = call(t, registry, admin, "check_contract_status", `{"contract_id":"contract_1"}`)
+	assert.NoError(t, err)
+	_, err = call(t, registry, admin, "check_contract_status", `{"contract_id":"contract_missing"}`)
+	assert.EqualError(t, err, "contract not found")
+}
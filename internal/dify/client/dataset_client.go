@@ -0,0 +1,395 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"time"
+
+	"cdk-office/pkg/logger"
+)
+
+// Dataset errors
+var (
+	ErrNotFound = errors.New("dify resource not found")
+	ErrConflict = errors.New("dify resource already exists")
+)
+
+// Indexing statuses of a dataset document
+const (
+	IndexingStatusWaiting   = "waiting"
+	IndexingStatusParsing   = "parsing"
+	IndexingStatusCleaning  = "cleaning"
+	IndexingStatusSplitting = "splitting"
+	IndexingStatusIndexing  = "indexing"
+	IndexingStatusCompleted = "completed"
+	IndexingStatusError     = "error"
+	IndexingStatusPaused    = "paused"
+)
+
+// DatasetClientInterface defines the interface for the Dify knowledge base (datasets) API
+type DatasetClientInterface interface {
+	CreateDataset(ctx context.Context, req *CreateDatasetRequest) (*Dataset, error)
+	ListDatasets(ctx context.Context, page, limit int) (*DatasetList, error)
+	DeleteDataset(ctx context.Context, datasetID string) error
+	CreateDocumentByText(ctx context.Context, datasetID string, req *DocumentTextRequest) (*DocumentResponse, error)
+	CreateDocumentByFile(ctx context.Context, datasetID string, req *DocumentFileRequest) (*DocumentResponse, error)
+	UpdateDocumentByText(ctx context.Context, datasetID, documentID string, req *DocumentTextRequest) (*DocumentResponse, error)
+	UpdateDocumentByFile(ctx context.Context, datasetID, documentID string, req *DocumentFileRequest) (*DocumentResponse, error)
+	DeleteDocument(ctx context.Context, datasetID, documentID string) error
+	GetIndexingStatus(ctx context.Context, datasetID, batch string) ([]*IndexingStatus, error)
+	Retrieve(ctx context.Context, datasetID string, req *RetrieveRequest) (*RetrieveResponse, error)
+}
+
+// DatasetClient implements the DatasetClientInterface. Dify issues separate API
+// keys for the knowledge base API and for applications.
+type DatasetClient struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// NewDatasetClient creates a new instance of DatasetClient
+func NewDatasetClient(baseURL, apiKey string) *DatasetClient {
+	return &DatasetClient{
+		baseURL: baseURL,
+		apiKey:  apiKey,
+		httpClient: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+	}
+}
+
+// Dataset represents a Dify knowledge base
+type Dataset struct {
+	ID                string `json:"id"`
+	Name              string `json:"name"`
+	Description       string `json:"description"`
+	Permission        string `json:"permission"`
+	IndexingTechnique string `json:"indexing_technique"`
+	DocumentCount     int    `json:"document_count"`
+	WordCount         int    `json:"word_count"`
+	CreatedAt         int64  `json:"created_at"` // unix seconds
+}
+
+// CreateDatasetRequest represents the request for creating a dataset
+type CreateDatasetRequest struct {
+	Name              string `json:"name"`
+	Description       string `json:"description,omitempty"`
+	IndexingTechnique string `json:"indexing_technique,omitempty"` // high_quality or economy
+	Permission        string `json:"permission,omitempty"`         // only_me or all_team_members
+}
+
+// DatasetList represents a page of datasets
+type DatasetList struct {
+	Data    []*Dataset `json:"data"`
+	HasMore bool       `json:"has_more"`
+	Limit   int        `json:"limit"`
+	Total   int        `json:"total"`
+	Page    int        `json:"page"`
+}
+
+// DatasetDocument represents a document of a dataset
+type DatasetDocument struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	Position       int    `json:"position"`
+	DataSourceType string `json:"data_source_type"`
+	IndexingStatus string `json:"indexing_status"`
+	Error          string `json:"error"`
+	Enabled        bool   `json:"enabled"`
+	WordCount      int    `json:"word_count"`
+	CreatedAt      int64  `json:"created_at"` // unix seconds
+}
+
+// DocumentResponse represents the response for creating or updating a document.
+// Batch identifies the indexing run to poll with GetIndexingStatus.
+type DocumentResponse struct {
+	Document *DatasetDocument `json:"document"`
+	Batch    string           `json:"batch"`
+}
+
+// ProcessRule selects how a document is split into segments
+type ProcessRule struct {
+	Mode string `json:"mode"` // automatic or custom
+}
+
+// DocumentTextRequest represents the request for creating or updating a document from text
+type DocumentTextRequest struct {
+	Name              string       `json:"name,omitempty"`
+	Text              string       `json:"text"`
+	IndexingTechnique string       `json:"indexing_technique,omitempty"`
+	ProcessRule       *ProcessRule `json:"process_rule,omitempty"`
+}
+
+// DocumentFileRequest represents the request for creating or updating a document from a file,
+// which Dify extracts the text of
+type DocumentFileRequest struct {
+	File              io.Reader    `json:"-"`
+	FileName          string       `json:"-"`
+	IndexingTechnique string       `json:"indexing_technique,omitempty"`
+	ProcessRule       *ProcessRule `json:"process_rule,omitempty"`
+}
+
+// IndexingStatus represents the indexing progress of a document
+type IndexingStatus struct {
+	ID                string `json:"id"`
+	IndexingStatus    string `json:"indexing_status"`
+	Error             string `json:"error"`
+	CompletedSegments int    `json:"completed_segments"`
+	TotalSegments     int    `json:"total_segments"`
+}
+
+// RetrieveRequest represents the request for retrieving segments (hit testing)
+type RetrieveRequest struct {
+	Query          string          `json:"query"`
+	RetrievalModel *RetrievalModel `json:"retrieval_model,omitempty"`
+}
+
+// RetrievalModel overrides the retrieval settings of the dataset
+type RetrievalModel struct {
+	SearchMethod          string  `json:"search_method"` // semantic_search, full_text_search or hybrid_search
+	RerankingEnable       bool    `json:"reranking_enable"`
+	TopK                  int     `json:"top_k"`
+	ScoreThresholdEnabled bool    `json:"score_threshold_enabled"`
+	ScoreThreshold        float64 `json:"score_threshold"`
+}
+
+// RetrieveResponse represents the segments matching a query
+type RetrieveResponse struct {
+	Query   RetrieveQuery     `json:"query"`
+	Records []*RetrieveRecord `json:"records"`
+}
+
+// RetrieveQuery represents the query of a retrieval
+type RetrieveQuery struct {
+	Content string `json:"content"`
+}
+
+// RetrieveRecord represents a matching segment and its score
+type RetrieveRecord struct {
+	Segment *Segment `json:"segment"`
+	Score   float64  `json:"score"`
+}
+
+// Segment represents a chunk of a dataset document
+type Segment struct {
+	ID         string           `json:"id"`
+	Position   int              `json:"position"`
+	DocumentID string           `json:"document_id"`
+	Content    string           `json:"content"`
+	WordCount  int              `json:"word_count"`
+	Tokens     int              `json:"tokens"`
+	Keywords   []string         `json:"keywords"`
+	Document   *SegmentDocument `json:"document"`
+}
+
+// SegmentDocument identifies the document of a segment
+type SegmentDocument struct {
+	ID             string `json:"id"`
+	DataSourceType string `json:"data_source_type"`
+	Name           string `json:"name"`
+}
+
+// apiError represents an error response of the Dify API
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// CreateDataset creates an empty dataset
+func (c *DatasetClient) CreateDataset(ctx context.Context, req *CreateDatasetRequest) (*Dataset, error) {
+	var dataset Dataset
+	if err := c.doJSON(ctx, http.MethodPost, "/datasets", req, &dataset); err != nil {
+		return nil, datasetError(err, "failed to create dataset")
+	}
+	return &dataset, nil
+}
+
+// ListDatasets lists the datasets, page starting at 1
+func (c *DatasetClient) ListDatasets(ctx context.Context, page, limit int) (*DatasetList, error) {
+	query := url.Values{}
+	query.Set("page", fmt.Sprint(page))
+	query.Set("limit", fmt.Sprint(limit))
+
+	var list DatasetList
+	if err := c.do(ctx, http.MethodGet, "/datasets?"+query.Encode(), nil, "", &list); err != nil {
+		return nil, datasetError(err, "failed to list datasets")
+	}
+	return &list, nil
+}
+
+// DeleteDataset deletes a dataset and its documents
+func (c *DatasetClient) DeleteDataset(ctx context.Context, datasetID string) error {
+	if err := c.do(ctx, http.MethodDelete, "/datasets/"+url.PathEscape(datasetID), nil, "", nil); err != nil {
+		return datasetError(err, "failed to delete dataset")
+	}
+	return nil
+}
+
+// CreateDocumentByText adds a document to a dataset from text
+func (c *DatasetClient) CreateDocumentByText(ctx context.Context, datasetID string, req *DocumentTextRequest) (*DocumentResponse, error) {
+	var resp DocumentResponse
+	path := "/datasets/" + url.PathEscape(datasetID) + "/document/create-by-text"
+	if err := c.doJSON(ctx, http.MethodPost, path, req, &resp); err != nil {
+		return nil, datasetError(err, "failed to create dataset document")
+	}
+	return &resp, nil
+}
+
+// CreateDocumentByFile adds a document to a dataset from a file
+func (c *DatasetClient) CreateDocumentByFile(ctx context.Context, datasetID string, req *DocumentFileRequest) (*DocumentResponse, error) {
+	var resp DocumentResponse
+	path := "/datasets/" + url.PathEscape(datasetID) + "/document/create-by-file"
+	if err := c.doFile(ctx, path, req, &resp); err != nil {
+		return nil, datasetError(err, "failed to create dataset document")
+	}
+	return &resp, nil
+}
+
+// UpdateDocumentByText replaces the content of a document with text, re-indexing it
+func (c *DatasetClient) UpdateDocumentByText(ctx context.Context, datasetID, documentID string, req *DocumentTextRequest) (*DocumentResponse, error) {
+	var resp DocumentResponse
+	path := "/datasets/" + url.PathEscape(datasetID) + "/documents/" + url.PathEscape(documentID) + "/update-by-text"
+	if err := c.doJSON(ctx, http.MethodPost, path, req, &resp); err != nil {
+		return nil, datasetError(err, "failed to update dataset document")
+	}
+	return &resp, nil
+}
+
+// UpdateDocumentByFile replaces the content of a document with a file, re-indexing it
+func (c *DatasetClient) UpdateDocumentByFile(ctx context.Context, datasetID, documentID string, req *DocumentFileRequest) (*DocumentResponse, error) {
+	var resp DocumentResponse
+	path := "/datasets/" + url.PathEscape(datasetID) + "/documents/" + url.PathEscape(documentID) + "/update-by-file"
+	if err := c.doFile(ctx, path, req, &resp); err != nil {
+		return nil, datasetError(err, "failed to update dataset document")
+	}
+	return &resp, nil
+}
+
+// DeleteDocument deletes a document from a dataset
+func (c *DatasetClient) DeleteDocument(ctx context.Context, datasetID, documentID string) error {
+	path := "/datasets/" + url.PathEscape(datasetID) + "/documents/" + url.PathEscape(documentID)
+	if err := c.do(ctx, http.MethodDelete, path, nil, "", nil); err != nil {
+		return datasetError(err, "failed to delete dataset document")
+	}
+	return nil
+}
+
+// GetIndexingStatus returns the indexing progress of the documents of a batch
+func (c *DatasetClient) GetIndexingStatus(ctx context.Context, datasetID, batch string) ([]*IndexingStatus, error) {
+	var resp struct {
+		Data []*IndexingStatus `json:"data"`
+	}
+	path := "/datasets/" + url.PathEscape(datasetID) + "/documents/" + url.PathEscape(batch) + "/indexing-status"
+	if err := c.do(ctx, http.MethodGet, path, nil, "", &resp); err != nil {
+		return nil, datasetError(err, "failed to get indexing status")
+	}
+	return resp.Data, nil
+}
+
+// Retrieve returns the segments of a dataset matching a query, with their scores
+func (c *DatasetClient) Retrieve(ctx context.Context, datasetID string, req *RetrieveRequest) (*RetrieveResponse, error) {
+	var resp RetrieveResponse
+	path := "/datasets/" + url.PathEscape(datasetID) + "/retrieve"
+	if err := c.doJSON(ctx, http.MethodPost, path, req, &resp); err != nil {
+		return nil, datasetError(err, "failed to retrieve from dataset")
+	}
+	return &resp, nil
+}
+
+// doJSON sends a request with a JSON body
+func (c *DatasetClient) doJSON(ctx context.Context, method, path string, body, out interface{}) error {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		logger.Error("failed to marshal dataset request", "error", err)
+		return err
+	}
+	return c.do(ctx, method, path, bytes.NewReader(jsonData), "application/json", out)
+}
+
+// doFile sends a multipart request with the file and its processing settings
+func (c *DatasetClient) doFile(ctx context.Context, path string, req *DocumentFileRequest, out interface{}) error {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		logger.Error("failed to marshal dataset file request", "error", err)
+		return err
+	}
+	if err := writer.WriteField("data", string(data)); err != nil {
+		return err
+	}
+	fileWriter, err := writer.CreateFormFile("file", req.FileName)
+	if err != nil {
+		logger.Error("failed to create form file", "error", err)
+		return err
+	}
+	if _, err := io.Copy(fileWriter, req.File); err != nil {
+		logger.Error("failed to copy file data", "error", err)
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return c.do(ctx, http.MethodPost, path, &body, writer.FormDataContentType(), out)
+}
+
+// do sends a request and decodes the response into out, if not nil
+func (c *DatasetClient) do(ctx context.Context, method, path string, body io.Reader, contentType string, out interface{}) error {
+	httpReq, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		logger.Error("failed to create dataset HTTP request", "error", err)
+		return err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	if contentType != "" {
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		logger.Error("failed to send dataset request", "error", err, "path", path)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(resp.Body)
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return ErrNotFound
+		case http.StatusConflict:
+			return ErrConflict
+		}
+		logger.Error("dataset request failed", "method", method, "path", path, "status", resp.StatusCode, "body", string(data))
+		var apiErr apiError
+		json.Unmarshal(data, &apiErr)
+		return fmt.Errorf("dify returned %d %s: %s", resp.StatusCode, apiErr.Code, apiErr.Message)
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		logger.Error("failed to decode dataset response", "error", err, "path", path)
+		return err
+	}
+	return nil
+}
+
+// datasetError keeps the errors callers act on and hides the others behind message
+func datasetError(err error, message string) error {
+	if errors.Is(err, ErrNotFound) || errors.Is(err, ErrConflict) {
+		return err
+	}
+	return errors.New(message)
+}
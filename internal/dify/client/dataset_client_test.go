@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cdk-office/internal/dify/client/difytest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatasetClient(t *testing.T) {
+	server := difytest.NewServer("dataset-key")
+	defer server.Close()
+	datasetClient := NewDatasetClient(server.URL, "dataset-key")
+	ctx := context.Background()
+
+	dataset, err := datasetClient.CreateDataset(ctx, &CreateDatasetRequest{Name: "team-1", Description: "Team documents"})
+	require.NoError(t, err)
+	assert.Equal(t, "team-1", dataset.Name)
+
+	_, err = datasetClient.CreateDataset(ctx, &CreateDatasetRequest{Name: "team-1"})
+	assert.ErrorIs(t, err, ErrConflict)
+
+	t.Run("ListDatasets", func(t *testing.T) {
+		_, err := datasetClient.CreateDataset(ctx, &CreateDatasetRequest{Name: "team-2"})
+		require.NoError(t, err)
+
+		list, err := datasetClient.ListDatasets(ctx, 1, 1)
+		require.NoError(t, err)
+		assert.Equal(t, 2, list.Total)
+		assert.True(t, list.HasMore)
+		require.Len(t, list.Data, 1)
+		assert.Equal(t, dataset.ID, list.Data[0].ID)
+	})
+
+	t.Run("DocumentLifecycle", func(t *testing.T) {
+		created, err := datasetClient.CreateDocumentByText(ctx, dataset.ID, &DocumentTextRequest{
+			Name:        "Handbook",
+			Text:        "Holiday policy\n\nEmployees have 25 days of annual leave.",
+			ProcessRule: &ProcessRule{Mode: "automatic"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "Handbook", created.Document.Name)
+		assert.NotEmpty(t, created.Batch)
+
+		statuses, err := datasetClient.GetIndexingStatus(ctx, dataset.ID, created.Batch)
+		require.NoError(t, err)
+		require.Len(t, statuses, 1)
+		assert.Equal(t, IndexingStatusCompleted, statuses[0].IndexingStatus)
+		assert.Equal(t, 2, statuses[0].TotalSegments)
+
+		result, err := datasetClient.Retrieve(ctx, dataset.ID, &RetrieveRequest{
+			Query:          "annual leave",
+			RetrievalModel: &RetrievalModel{SearchMethod: "semantic_search", TopK: 3},
+		})
+		require.NoError(t, err)
+		require.Len(t, result.Records, 1)
+		assert.Equal(t, created.Document.ID, result.Records[0].Segment.DocumentID)
+		assert.Equal(t, "Handbook", result.Records[0].Segment.Document.Name)
+		assert.Equal(t, 1.0, result.Records[0].Score)
+
+		updated, err := datasetClient.UpdateDocumentByFile(ctx, dataset.ID, created.Document.ID, &DocumentFileRequest{
+			File:     strings.NewReader("Employees have 30 days of annual leave."),
+			FileName: "handbook.txt",
+		})
+		require.NoError(t, err)
+		assert.NotEqual(t, created.Batch, updated.Batch)
+		stored := server.Dataset(dataset.ID).Documents[created.Document.ID]
+		assert.Equal(t, "upload_file", stored.DataSourceType)
+		assert.Equal(t, "Employees have 30 days of annual leave.", stored.Text)
+
+		require.NoError(t, datasetClient.DeleteDocument(ctx, dataset.ID, created.Document.ID))
+		assert.ErrorIs(t, datasetClient.DeleteDocument(ctx, dataset.ID, created.Document.ID), ErrNotFound)
+	})
+
+	t.Run("CreateDocumentByFile", func(t *testing.T) {
+		created, err := datasetClient.CreateDocumentByFile(ctx, dataset.ID, &DocumentFileRequest{
+			File:        strings.NewReader("Expense claims are paid monthly."),
+			FileName:    "expenses.txt",
+			ProcessRule: &ProcessRule{Mode: "automatic"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "expenses.txt", created.Document.Name)
+	})
+
+	t.Run("DeleteDataset", func(t *testing.T) {
+		require.NoError(t, datasetClient.DeleteDataset(ctx, dataset.ID))
+		_, err := datasetClient.Retrieve(ctx, dataset.ID, &RetrieveRequest{Query: "leave"})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestDatasetClientErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"code":"provider_not_initialize","message":"no embedding model"}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+	datasetClient := NewDatasetClient(server.URL, "dataset-key")
+
+	_, err := datasetClient.CreateDocumentByText(context.Background(), "dataset-1", &DocumentTextRequest{Name: "a", Text: "b"})
+	assert.EqualError(t, err, "failed to create dataset document")
+	assert.NotErrorIs(t, err, ErrNotFound)
+
+	unauthorized := difytest.NewServer("dataset-key")
+	defer unauthorized.Close()
+	_, err = NewDatasetClient(unauthorized.URL, "app-key").ListDatasets(context.Background(), 1, 20)
+	assert.EqualError(t, err, "failed to list datasets")
+}
@@ -21,21 +21,36 @@ type DifyClientInterface interface {
 	UploadFile(ctx context.Context, req *FileUploadRequest) (*FileUploadResponse, error)
 }
 
-// DifyClient implements the DifyClientInterface
+// DifyClient implements the DifyClientInterface and the StreamingClientInterface
 type DifyClient struct {
-	baseURL    string
-	apiKey     string
-	httpClient *http.Client
+	baseURL           string
+	apiKey            string
+	httpClient        *http.Client
+	streamClient      *http.Client
+	streamIdleTimeout time.Duration
 }
 
 // NewDifyClient creates a new instance of DifyClient
 func NewDifyClient(baseURL, apiKey string) *DifyClient {
+	return NewDifyClientWithTimeouts(baseURL, apiKey, 30*time.Second, defaultStreamIdleTimeout)
+}
+
+// NewDifyClientWithTimeouts creates a new instance of DifyClient. timeout bounds
+// blocking requests and the wait for a stream to start; a started stream is only
+// cut when no event arrives for streamIdleTimeout.
+func NewDifyClientWithTimeouts(baseURL, apiKey string, timeout, streamIdleTimeout time.Duration) *DifyClient {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = timeout
 	return &DifyClient{
 		baseURL: baseURL,
 		apiKey:  apiKey,
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout: timeout,
+		},
+		streamClient: &http.Client{
+			Transport: transport,
 		},
+		streamIdleTimeout: streamIdleTimeout,
 	}
 }
 
@@ -0,0 +1,407 @@
+// Package difytest provides an in-memory Dify knowledge base API for tests.
+package difytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Document is a document stored by the fake server
+type Document struct {
+	ID             string
+	Name           string
+	Text           string
+	DataSourceType string // upload_file or text
+	FileName       string
+	Batch          string
+}
+
+// Dataset is a dataset stored by the fake server
+type Dataset struct {
+	ID          string
+	Name        string
+	Description string
+	Documents   map[string]*Document
+}
+
+// Server is a fake Dify knowledge base API. Documents are indexed at once and
+// retrieval scores segments by the share of query terms they contain.
+type Server struct {
+	URL    string // base URL of the API, including /v1
+	APIKey string
+
+	server   *httptest.Server
+	mu       sync.Mutex
+	datasets map[string]*Dataset
+	order    []string
+	nextID   int
+	requests []string
+}
+
+// NewServer starts a fake server accepting apiKey
+func NewServer(apiKey string) *Server {
+	s := &Server{APIKey: apiKey, datasets: make(map[string]*Dataset)}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.server.URL + "/v1"
+	return s
+}
+
+// Close stops the server
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Dataset returns a copy of a dataset, or nil
+func (s *Server) Dataset(id string) *Dataset {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dataset, ok := s.datasets[id]
+	if !ok {
+		return nil
+	}
+	copied := *dataset
+	copied.Documents = make(map[string]*Document, len(dataset.Documents))
+	for id, document := range dataset.Documents {
+		doc := *document
+		copied.Documents[id] = &doc
+	}
+	return &copied
+}
+
+// DatasetCount returns the number of datasets
+func (s *Server) DatasetCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.datasets)
+}
+
+// Requests returns the method and path of the requests received, such as
+// "POST /datasets"
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) newID(prefix string) string {
+	s.nextID++
+	return fmt.Sprintf("%s-%d", prefix, s.nextID)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+s.APIKey {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "invalid api key")
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/v1")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) == 0 || parts[0] != "datasets" {
+		writeError(w, http.StatusNotFound, "not_found", "not found")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.Method+" "+path)
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodPost:
+		s.createDataset(w, r)
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		s.listDatasets(w, r)
+	case len(parts) == 2 && r.Method == http.MethodDelete:
+		if _, ok := s.datasets[parts[1]]; !ok {
+			writeError(w, http.StatusNotFound, "dataset_not_found", "dataset not found")
+			return
+		}
+		delete(s.datasets, parts[1])
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		dataset, ok := s.datasets[parts[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "dataset_not_found", "dataset not found")
+			return
+		}
+		s.serveDataset(w, r, dataset, parts[2:])
+	}
+}
+
+func (s *Server) serveDataset(w http.ResponseWriter, r *http.Request, dataset *Dataset, parts []string) {
+	switch {
+	case len(parts) == 2 && parts[0] == "document" && r.Method == http.MethodPost:
+		document := &Document{ID: s.newID("doc")}
+		if !s.writeDocument(w, r, document, parts[1], "create") {
+			return
+		}
+		dataset.Documents[document.ID] = document
+		writeDocument(w, document)
+	case len(parts) == 1 && parts[0] == "retrieve" && r.Method == http.MethodPost:
+		s.retrieve(w, r, dataset)
+	case len(parts) >= 2 && parts[0] == "documents":
+		if len(parts) == 3 && parts[2] == "indexing-status" && r.Method == http.MethodGet {
+			s.indexingStatus(w, dataset, parts[1])
+			return
+		}
+		document, ok := dataset.Documents[parts[1]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "document_not_found", "document not found")
+			return
+		}
+		switch {
+		case len(parts) == 2 && r.Method == http.MethodDelete:
+			delete(dataset.Documents, document.ID)
+			writeJSON(w, http.StatusOK, map[string]string{"result": "success"})
+		case len(parts) == 3 && r.Method == http.MethodPost:
+			if s.writeDocument(w, r, document, parts[2], "update") {
+				writeDocument(w, document)
+			}
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method_not_allowed", "method not allowed")
+		}
+	default:
+		writeError(w, http.StatusNotFound, "not_found", "not found")
+	}
+}
+
+func (s *Server) createDataset(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+		writeError(w, http.StatusBadRequest, "invalid_param", "name is required")
+		return
+	}
+	for _, dataset := range s.datasets {
+		if dataset.Name == req.Name {
+			writeError(w, http.StatusConflict, "dataset_name_duplicate", "dataset name already exists")
+			return
+		}
+	}
+
+	dataset := &Dataset{ID: s.newID("dataset"), Name: req.Name, Description: req.Description, Documents: make(map[string]*Document)}
+	s.datasets[dataset.ID] = dataset
+	s.order = append(s.order, dataset.ID)
+	writeJSON(w, http.StatusOK, datasetJSON(dataset))
+}
+
+func (s *Server) listDatasets(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 20
+	}
+
+	var all []*Dataset
+	for _, id := range s.order {
+		if dataset, ok := s.datasets[id]; ok {
+			all = append(all, dataset)
+		}
+	}
+	data := []map[string]interface{}{}
+	for i := (page - 1) * limit; i < len(all) && i < page*limit; i++ {
+		data = append(data, datasetJSON(all[i]))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"data":     data,
+		"has_more": page*limit < len(all),
+		"limit":    limit,
+		"total":    len(all),
+		"page":     page,
+	})
+}
+
+// writeDocument applies a create-by-text, create-by-file, update-by-text or
+// update-by-file request to document
+func (s *Server) writeDocument(w http.ResponseWriter, r *http.Request, document *Document, action, verb string) bool {
+	switch action {
+	case verb + "-by-text":
+		var req struct {
+			Name string  `json:"name"`
+			Text *string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Text == nil || (verb == "create" && req.Name == "") {
+			writeError(w, http.StatusBadRequest, "invalid_param", "name and text are required")
+			return false
+		}
+		if req.Name != "" {
+			document.Name = req.Name
+		}
+		document.Text = *req.Text
+		document.DataSourceType = "text"
+		document.FileName = ""
+	case verb + "-by-file":
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "no_file_uploaded", "file is required")
+			return false
+		}
+		defer file.Close()
+		data, _ := io.ReadAll(file)
+		if data := r.FormValue("data"); data != "" && !json.Valid([]byte(data)) {
+			writeError(w, http.StatusBadRequest, "invalid_param", "data must be JSON")
+			return false
+		}
+		document.Name = header.Filename
+		document.FileName = header.Filename
+		document.Text = string(data)
+		document.DataSourceType = "upload_file"
+	default:
+		writeError(w, http.StatusNotFound, "not_found", "not found")
+		return false
+	}
+	document.Batch = s.newID("batch")
+	return true
+}
+
+func (s *Server) indexingStatus(w http.ResponseWriter, dataset *Dataset, batch string) {
+	data := []map[string]interface{}{}
+	for _, document := range dataset.Documents {
+		if document.Batch == batch {
+			segments := len(segmentsOf(document.Text))
+			data = append(data, map[string]interface{}{
+				"id":                 document.ID,
+				"indexing_status":    "completed",
+				"error":              nil,
+				"completed_segments": segments,
+				"total_segments":     segments,
+			})
+		}
+	}
+	if len(data) == 0 {
+		writeError(w, http.StatusNotFound, "document_not_found", "batch not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": data})
+}
+
+func (s *Server) retrieve(w http.ResponseWriter, r *http.Request, dataset *Dataset) {
+	var req struct {
+		Query          string `json:"query"`
+		RetrievalModel *struct {
+			TopK int `json:"top_k"`
+		} `json:"retrieval_model"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Query == "" {
+		writeError(w, http.StatusBadRequest, "invalid_param", "query is required")
+		return
+	}
+	topK := 4
+	if req.RetrievalModel != nil && req.RetrievalModel.TopK > 0 {
+		topK = req.RetrievalModel.TopK
+	}
+
+	terms := strings.Fields(strings.ToLower(req.Query))
+	type record struct {
+		document *Document
+		position int
+		content  string
+		score    float64
+	}
+	var records []record
+	for _, document := range dataset.Documents {
+		for i, segment := range segmentsOf(document.Text) {
+			lower := strings.ToLower(segment)
+			matched := 0
+			for _, term := range terms {
+				if strings.Contains(lower, term) {
+					matched++
+				}
+			}
+			if matched > 0 {
+				records = append(records, record{document, i + 1, segment, float64(matched) / float64(len(terms))})
+			}
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		if records[i].score != records[j].score {
+			return records[i].score > records[j].score
+		}
+		return records[i].document.ID < records[j].document.ID
+	})
+	if len(records) > topK {
+		records = records[:topK]
+	}
+
+	out := []map[string]interface{}{}
+	for _, rec := range records {
+		out = append(out, map[string]interface{}{
+			"segment": map[string]interface{}{
+				"id":          fmt.Sprintf("%s-segment-%d", rec.document.ID, rec.position),
+				"position":    rec.position,
+				"document_id": rec.document.ID,
+				"content":     rec.content,
+				"word_count":  len(rec.content),
+				"document": map[string]interface{}{
+					"id":               rec.document.ID,
+					"data_source_type": rec.document.DataSourceType,
+					"name":             rec.document.Name,
+				},
+			},
+			"score": rec.score,
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"query":   map[string]string{"content": req.Query},
+		"records": out,
+	})
+}
+
+// segmentsOf splits text into paragraphs
+func segmentsOf(text string) []string {
+	var segments []string
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			segments = append(segments, paragraph)
+		}
+	}
+	return segments
+}
+
+func datasetJSON(dataset *Dataset) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                 dataset.ID,
+		"name":               dataset.Name,
+		"description":        dataset.Description,
+		"permission":         "only_me",
+		"indexing_technique": "high_quality",
+		"document_count":     len(dataset.Documents),
+		"created_at":         time.Now().Unix(),
+	}
+}
+
+func writeDocument(w http.ResponseWriter, document *Document) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"document": map[string]interface{}{
+			"id":               document.ID,
+			"name":             document.Name,
+			"data_source_type": document.DataSourceType,
+			"indexing_status":  "waiting",
+			"enabled":          true,
+			"word_count":       len(document.Text),
+			"created_at":       time.Now().Unix(),
+		},
+		"batch": document.Batch,
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]interface{}{"status": status, "code": code, "message": message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,263 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"cdk-office/pkg/logger"
+)
+
+const (
+	// defaultStreamIdleTimeout cuts streams silent for longer; Dify pings every 10 seconds
+	defaultStreamIdleTimeout = time.Minute
+	// maxStreamEventSize is the largest event line accepted, agent thoughts can be long
+	maxStreamEventSize = 1 << 20
+)
+
+// Stream event types
+const (
+	StreamEventMessage        = "message"       // next chunk of the answer
+	StreamEventAgentMessage   = "agent_message" // next chunk of the answer of an agent
+	StreamEventAgentThought   = "agent_thought"
+	StreamEventMessageReplace = "message_replace" // replaces the answer so far, after content moderation
+	StreamEventMessageEnd     = "message_end"
+	StreamEventError          = "error"
+	StreamEventPing           = "ping"
+)
+
+// StreamingClientInterface defines the interface for streamed (SSE) Dify answers
+type StreamingClientInterface interface {
+	StreamCompletionMessage(ctx context.Context, req *CompletionRequest) (<-chan *StreamEvent, error)
+	StreamChatMessage(ctx context.Context, req *ChatRequest) (<-chan *StreamEvent, error)
+	StopCompletionMessage(ctx context.Context, taskID, user string) error
+	StopChatMessage(ctx context.Context, taskID, user string) error
+}
+
+// StreamEvent represents an event of a streamed answer. The channel of events is
+// closed after message_end or error, or when the context is cancelled.
+type StreamEvent struct {
+	Event          string `json:"event"`
+	TaskID         string `json:"task_id,omitempty"`
+	MessageID      string `json:"message_id,omitempty"`
+	ConversationID string `json:"conversation_id,omitempty"`
+	Answer         string `json:"answer,omitempty"`
+	CreatedAt      int64  `json:"created_at,omitempty"`
+
+	// agent_thought
+	ID           string   `json:"id,omitempty"`
+	Position     int      `json:"position,omitempty"`
+	Thought      string   `json:"thought,omitempty"`
+	Observation  string   `json:"observation,omitempty"`
+	Tool         string   `json:"tool,omitempty"`
+	ToolInput    string   `json:"tool_input,omitempty"`
+	MessageFiles []string `json:"message_files,omitempty"`
+
+	// message_end
+	Metadata *Metadata `json:"metadata,omitempty"`
+
+	// error
+	Status  int    `json:"status,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// StreamCompletionMessage sends a completion message to Dify and streams the answer
+func (c *DifyClient) StreamCompletionMessage(ctx context.Context, req *CompletionRequest) (<-chan *StreamEvent, error) {
+	streamReq := *req
+	streamReq.ResponseMode = "streaming"
+	return c.stream(ctx, "/completion-messages", &streamReq, "failed to create completion message")
+}
+
+// StreamChatMessage sends a chat message to Dify and streams the answer
+func (c *DifyClient) StreamChatMessage(ctx context.Context, req *ChatRequest) (<-chan *StreamEvent, error) {
+	streamReq := *req
+	streamReq.ResponseMode = "streaming"
+	return c.stream(ctx, "/chat-messages", &streamReq, "failed to create chat message")
+}
+
+// StopCompletionMessage stops the generation of a streamed completion
+func (c *DifyClient) StopCompletionMessage(ctx context.Context, taskID, user string) error {
+	return c.stop(ctx, "/completion-messages/"+url.PathEscape(taskID)+"/stop", user)
+}
+
+// StopChatMessage stops the generation of a streamed chat answer
+func (c *DifyClient) StopChatMessage(ctx context.Context, taskID, user string) error {
+	return c.stop(ctx, "/chat-messages/"+url.PathEscape(taskID)+"/stop", user)
+}
+
+// stop asks Dify to stop generating the answer of a task
+func (c *DifyClient) stop(ctx context.Context, path, user string) error {
+	jsonData, err := json.Marshal(map[string]string{"user": user})
+	if err != nil {
+		return errors.New("failed to stop message")
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+path, bytes.NewReader(jsonData))
+	if err != nil {
+		logger.Error("failed to create stop HTTP request", "error", err)
+		return errors.New("failed to stop message")
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		logger.Error("failed to send stop request", "error", err)
+		return errors.New("failed to stop message")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		logger.Error("stop request failed", "status", resp.StatusCode, "body", string(body))
+		return errors.New("failed to stop message")
+	}
+	return nil
+}
+
+// stream sends a streaming request and returns its events once Dify accepted it
+func (c *DifyClient) stream(ctx context.Context, path string, body interface{}, failure string) (<-chan *StreamEvent, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		logger.Error("failed to marshal streaming request", "error", err)
+		return nil, errors.New(failure)
+	}
+
+	// The request has its own context so an idle stream can be cut without
+	// cancelling the caller
+	reqCtx, cancel := context.WithCancel(ctx)
+	httpReq, err := http.NewRequestWithContext(reqCtx, "POST", c.baseURL+path, bytes.NewReader(jsonData))
+	if err != nil {
+		cancel()
+		logger.Error("failed to create streaming HTTP request", "error", err)
+		return nil, errors.New(failure)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.streamClient.Do(httpReq)
+	if err != nil {
+		cancel()
+		logger.Error("failed to send streaming request", "error", err)
+		return nil, errors.New(failure)
+	}
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		cancel()
+		logger.Error("streaming request failed", "status", resp.StatusCode, "body", string(data))
+		return nil, errors.New(failure)
+	}
+
+	events := make(chan *StreamEvent)
+	go c.readStream(ctx, cancel, resp.Body, events)
+	return events, nil
+}
+
+// readStream parses the server-sent events of body into events until the answer
+// ends, the stream breaks or ctx is cancelled
+func (c *DifyClient) readStream(ctx context.Context, cancel context.CancelFunc, body io.ReadCloser, events chan<- *StreamEvent) {
+	defer close(events)
+	defer cancel()
+	defer body.Close()
+
+	var timedOut atomic.Bool
+	idle := time.AfterFunc(c.streamIdleTimeout, func() {
+		timedOut.Store(true)
+		cancel()
+	})
+	defer idle.Stop()
+
+	send := func(event *StreamEvent) bool {
+		select {
+		case events <- event:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamEventSize)
+	var name string
+	var data strings.Builder
+	for scanner.Scan() {
+		idle.Reset(c.streamIdleTimeout)
+		line := scanner.Text()
+
+		if line == "" {
+			if data.Len() == 0 && name == "" {
+				continue
+			}
+			event, err := parseStreamEvent(name, data.String())
+			name = ""
+			data.Reset()
+			if err != nil {
+				logger.Warn("failed to decode stream event", "error", err)
+				continue
+			}
+			if !send(event) {
+				return
+			}
+			if event.Event == StreamEventMessageEnd || event.Event == StreamEventError {
+				return
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			name = value
+		case "data":
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+		}
+	}
+
+	if ctx.Err() != nil {
+		return
+	}
+	event := &StreamEvent{Event: StreamEventError, Code: "stream_interrupted", Message: "the answer stream ended unexpectedly"}
+	if timedOut.Load() {
+		event.Code = "stream_timeout"
+		event.Message = fmt.Sprintf("no answer received for %s", c.streamIdleTimeout)
+	} else if err := scanner.Err(); err != nil {
+		logger.Error("failed to read answer stream", "error", err)
+	}
+	send(event)
+}
+
+// parseStreamEvent decodes the data of an event, named by its event field when
+// the data does not name it. Pings have no data.
+func parseStreamEvent(name, data string) (*StreamEvent, error) {
+	var event StreamEvent
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return nil, err
+		}
+	}
+	if event.Event == "" {
+		event.Event = name
+	}
+	if event.Event == "" {
+		event.Event = StreamEventMessage
+	}
+	return &event, nil
+}
@@ -0,0 +1,173 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collect reads events until the channel is closed
+func collect(t *testing.T, events <-chan *StreamEvent) []*StreamEvent {
+	var collected []*StreamEvent
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return collected
+			}
+			collected = append(collected, event)
+		case <-timeout:
+			t.Fatal("stream was not closed")
+		}
+	}
+}
+
+func TestStreamChatMessage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chat-messages", r.URL.Path)
+		assert.Equal(t, "text/event-stream", r.Header.Get("Accept"))
+		var req ChatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "streaming", req.ResponseMode)
+		assert.Equal(t, "user_1", req.User)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"event\": \"message\", \"task_id\": \"task_1\", \"answer\": \"Hello\"}\n\n")
+		fmt.Fprint(w, "event: ping\n\n")
+		fmt.Fprint(w, ": comment\n")
+		fmt.Fprint(w, "data: {\"event\": \"agent_thought\", \"task_id\": \"task_1\", \"thought\": \"searching\", \"tool\": \"dataset\"}\n\n")
+		fmt.Fprint(w, "data: {\"event\": \"message\", \"task_id\": \"task_1\",\ndata: \"answer\": \" world\"}\n\n")
+		fmt.Fprint(w, "data: {\"event\": \"message_end\", \"task_id\": \"task_1\", \"conversation_id\": \"conv_1\", \"metadata\": {\"usage\": {\"total_tokens\": 12}}}\n\n")
+		fmt.Fprint(w, "data: {\"event\": \"message\", \"answer\": \"ignored\"}\n\n")
+	}))
+	defer server.Close()
+
+	difyClient := NewDifyClient(server.URL, "test-key")
+	req := &ChatRequest{Query: "Hi", User: "user_1"}
+	events, err := difyClient.StreamChatMessage(context.Background(), req)
+	require.NoError(t, err)
+	assert.Empty(t, req.ResponseMode)
+
+	collected := collect(t, events)
+	require.Len(t, collected, 5)
+	assert.Equal(t, StreamEventMessage, collected[0].Event)
+	assert.Equal(t, "Hello", collected[0].Answer)
+	assert.Equal(t, StreamEventPing, collected[1].Event)
+	assert.Equal(t, StreamEventAgentThought, collected[2].Event)
+	assert.Equal(t, "searching", collected[2].Thought)
+	assert.Equal(t, " world", collected[3].Answer)
+	assert.Equal(t, StreamEventMessageEnd, collected[4].Event)
+	assert.Equal(t, "conv_1", collected[4].ConversationID)
+	assert.Equal(t, 12, collected[4].Metadata.Usage.TotalTokens)
+}
+
+func TestStreamCompletionMessageErrors(t *testing.T) {
+	t.Run("Rejected", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"code":"app_unavailable"}`, http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		_, err := NewDifyClient(server.URL, "test-key").StreamCompletionMessage(context.Background(), &CompletionRequest{Query: "Hi"})
+		assert.EqualError(t, err, "failed to create completion message")
+	})
+
+	t.Run("ErrorEvent", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "data: {\"event\": \"error\", \"task_id\": \"task_1\", \"status\": 400, \"code\": \"provider_quota_exceeded\", \"message\": \"quota exceeded\"}\n\n")
+		}))
+		defer server.Close()
+
+		events, err := NewDifyClient(server.URL, "test-key").StreamCompletionMessage(context.Background(), &CompletionRequest{Query: "Hi"})
+		require.NoError(t, err)
+		collected := collect(t, events)
+		require.Len(t, collected, 1)
+		assert.Equal(t, StreamEventError, collected[0].Event)
+		assert.Equal(t, "provider_quota_exceeded", collected[0].Code)
+	})
+
+	t.Run("Interrupted", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "data: {\"event\": \"message\", \"answer\": \"Hel\"}\n\n")
+		}))
+		defer server.Close()
+
+		events, err := NewDifyClient(server.URL, "test-key").StreamCompletionMessage(context.Background(), &CompletionRequest{Query: "Hi"})
+		require.NoError(t, err)
+		collected := collect(t, events)
+		require.Len(t, collected, 2)
+		assert.Equal(t, StreamEventError, collected[1].Event)
+		assert.Equal(t, "stream_interrupted", collected[1].Code)
+	})
+
+	t.Run("IdleTimeout", func(t *testing.T) {
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "data: {\"event\": \"message\", \"answer\": \"Hel\"}\n\n")
+			w.(http.Flusher).Flush()
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}))
+		defer server.Close()
+		defer close(release)
+
+		difyClient := NewDifyClientWithTimeouts(server.URL, "test-key", time.Second, 50*time.Millisecond)
+		events, err := difyClient.StreamCompletionMessage(context.Background(), &CompletionRequest{Query: "Hi"})
+		require.NoError(t, err)
+		collected := collect(t, events)
+		require.Len(t, collected, 2)
+		assert.Equal(t, "stream_timeout", collected[1].Code)
+	})
+}
+
+func TestStreamCancel(t *testing.T) {
+	disconnected := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: {\"event\": \"message\", \"answer\": \"Hel\"}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		close(disconnected)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := NewDifyClient(server.URL, "test-key").StreamChatMessage(ctx, &ChatRequest{Query: "Hi"})
+	require.NoError(t, err)
+	<-events
+	cancel()
+
+	assert.Empty(t, collect(t, events))
+	select {
+	case <-disconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("upstream request was not cancelled")
+	}
+}
+
+func TestStopChatMessage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.JSONEq(t, `{"user":"user_1"}`, string(body))
+		if !strings.HasPrefix(r.URL.Path, "/chat-messages/task_1/") {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"result":"success"}`)
+	}))
+	defer server.Close()
+
+	require.NoError(t, NewDifyClient(server.URL, "test-key").StopChatMessage(context.Background(), "task_1", "user_1"))
+	assert.EqualError(t, NewDifyClient(server.URL, "test-key").StopCompletionMessage(context.Background(), "task_1", "user_1"), "failed to stop message")
+}
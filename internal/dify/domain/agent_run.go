@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"time"
+)
+
+// Agent run statuses
+const (
+	AgentRunRunning   = "running"
+	AgentRunCompleted = "completed"
+	AgentRunFailed    = "failed"
+	AgentRunStepLimit = "step_limit" // stopped before the model answered
+)
+
+// Agent run step kinds
+const (
+	AgentStepToolCall = "tool_call"
+	AgentStepAnswer   = "answer"
+)
+
+// Tool call statuses
+const (
+	ToolCallSucceeded = "succeeded"
+	ToolCallFailed    = "failed"   // the tool returned an error
+	ToolCallRejected  = "rejected" // unknown tool or invalid arguments
+	ToolCallDenied    = "denied"   // the user lacks the permission of the tool
+)
+
+// AgentRun records an agent answering a message for a user, kept for audit
+type AgentRun struct {
+	ID         string     `json:"id" gorm:"primaryKey"`
+	AgentID    string     `json:"agent_id" gorm:"size:50"`
+	UserID     string     `json:"user_id" gorm:"index"`
+	Role       string     `json:"role" gorm:"size:20"` // role of the user when the run started
+	Message    string     `json:"message" gorm:"type:text"`
+	Answer     string     `json:"answer" gorm:"type:text"`
+	Status     string     `json:"status" gorm:"size:20"`
+	Steps      int        `json:"steps"` // model turns taken
+	Error      string     `json:"error,omitempty" gorm:"type:text"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// AgentRunStep records a tool call or the final answer of an agent run
+type AgentRunStep struct {
+	ID         string    `json:"id" gorm:"primaryKey"`
+	RunID      string    `json:"run_id" gorm:"index"`
+	Step       int       `json:"step"` // model turn the entry belongs to, from 1
+	Kind       string    `json:"kind" gorm:"size:20"`
+	Thought    string    `json:"thought,omitempty" gorm:"type:text"`
+	ToolCallID string    `json:"tool_call_id,omitempty" gorm:"size:100"`
+	ToolName   string    `json:"tool_name,omitempty" gorm:"size:100"`
+	Arguments  string    `json:"arguments,omitempty" gorm:"type:text"` // JSON arguments given by the model
+	Result     string    `json:"result,omitempty" gorm:"type:text"`    // JSON result of the tool, or the answer
+	Status     string    `json:"status,omitempty" gorm:"size:20"`
+	Error      string    `json:"error,omitempty" gorm:"type:text"`
+	DurationMS int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
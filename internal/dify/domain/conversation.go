@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"time"
+)
+
+// Conversation message roles
+const (
+	MessageRoleUser      = "user"
+	MessageRoleAssistant = "assistant"
+)
+
+// Conversation represents the questions of a user about the documents of a team
+type Conversation struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	UserID    string    `json:"user_id" gorm:"index"`
+	TeamID    string    `json:"team_id" gorm:"index"`
+	Title     string    `json:"title" gorm:"size:200"` // first question, shortened
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ConversationMessage represents a question or an answer in a conversation
+type ConversationMessage struct {
+	ID             string    `json:"id" gorm:"primaryKey"`
+	ConversationID string    `json:"conversation_id" gorm:"index"`
+	Role           string    `json:"role" gorm:"size:20"`
+	Content        string    `json:"content" gorm:"type:text"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// ConversationCitation represents a document passage an answer is based on
+type ConversationCitation struct {
+	ID         string  `json:"-" gorm:"primaryKey"`
+	MessageID  string  `json:"-" gorm:"index"`
+	Number     int     `json:"number"` // [n] marker of the passage in the answer
+	DocumentID string  `json:"document_id" gorm:"index"`
+	Title      string  `json:"title" gorm:"size:200"`
+	VersionID  string  `json:"version_id"`
+	Version    int     `json:"version"`
+	Snippet    string  `json:"snippet" gorm:"type:text"`
+	Page       int     `json:"page,omitempty"` // zero when the document text has no pages
+	Score      float64 `json:"score"`
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"cdk-office/internal/dify/agent"
+	"github.com/gin-gonic/gin"
+)
+
+// AgentHandlerInterface defines the interface for the agent handler
+type AgentHandlerInterface interface {
+	Run(c *gin.Context)
+	ListRuns(c *gin.Context)
+	GetRun(c *gin.Context)
+	ListTools(c *gin.Context)
+}
+
+// AgentHandler implements the AgentHandlerInterface
+type AgentHandler struct {
+	runner agent.AgentRunnerInterface
+}
+
+// NewAgentHandler creates a new instance of AgentHandler
+func NewAgentHandler(runner agent.AgentRunnerInterface) *AgentHandler {
+	return &AgentHandler{
+		runner: runner,
+	}
+}
+
+// RunAgentRequest represents the request for running an agent
+type RunAgentRequest struct {
+	Message  string   `json:"message" binding:"required"`
+	AgentID  string   `json:"agent_id"`
+	Tools    []string `json:"tools"`
+	MaxSteps int      `json:"max_steps"`
+}
+
+// Run handles answering a message with an agent calling tools for the current user
+func (h *AgentHandler) Run(c *gin.Context) {
+	var req RunAgentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	run, err := h.runner.Run(c.Request.Context(), &agent.RunRequest{
+		AgentID:  req.AgentID,
+		UserID:   c.GetString("user_id"),
+		Role:     c.GetString("role"),
+		Message:  req.Message,
+		Tools:    req.Tools,
+		MaxSteps: req.MaxSteps,
+	})
+	if err != nil {
+		c.JSON(agentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+// ListRuns handles listing the agent runs of the current user, or of any user for admins
+func (h *AgentHandler) ListRuns(c *gin.Context) {
+	runs, err := h.runner.ListRuns(c.Request.Context(), callerOf(c), c.Query("user_id"))
+	if err != nil {
+		c.JSON(agentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": runs})
+}
+
+// GetRun handles retrieving an agent run with its trace of tool calls
+func (h *AgentHandler) GetRun(c *gin.Context) {
+	run, err := h.runner.GetRun(c.Request.Context(), callerOf(c), c.Param("id"))
+	if err != nil {
+		c.JSON(agentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+// ListTools handles listing the tools agents can call
+func (h *AgentHandler) ListTools(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"items": h.runner.Tools()})
+}
+
+// callerOf returns the current user as an agent caller
+func callerOf(c *gin.Context) *agent.Caller {
+	return &agent.Caller{UserID: c.GetString("user_id"), Role: c.GetString("role")}
+}
+
+// agentErrorStatus maps agent runner errors to HTTP status codes
+func agentErrorStatus(err error) int {
+	switch msg := err.Error(); {
+	case msg == "agent run not found":
+		return http.StatusNotFound
+	case msg == "message is required" || strings.HasPrefix(msg, "unknown tool: "):
+		return http.StatusBadRequest
+	case msg == "failed to run agent":
+		return http.StatusBadGateway
+	case strings.HasPrefix(msg, "failed to"):
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cdk-office/internal/dify/agent"
+	"cdk-office/internal/dify/domain"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockAgentRunner is a mock implementation of AgentRunnerInterface
+type MockAgentRunner struct {
+	mock.Mock
+}
+
+func (m *MockAgentRunner) Run(ctx context.Context, req *agent.RunRequest) (*agent.RunDetail, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*agent.RunDetail), args.Error(1)
+}
+
+func (m *MockAgentRunner) GetRun(ctx context.Context, caller *agent.Caller, runID string) (*agent.RunDetail, error) {
+	args := m.Called(ctx, caller, runID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*agent.RunDetail), args.Error(1)
+}
+
+func (m *MockAgentRunner) ListRuns(ctx context.Context, caller *agent.Caller, userID string) ([]*domain.AgentRun, error) {
+	args := m.Called(ctx, caller, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.AgentRun), args.Error(1)
+}
+
+func (m *MockAgentRunner) Tools() []agent.ToolDefinition {
+	args := m.Called()
+	return args.Get(0).([]agent.ToolDefinition)
+}
+
+// TestAgentHandler tests the AgentHandler
+func TestAgentHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockRunner := new(MockAgentRunner)
+	handler := NewAgentHandler(mockRunner)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", "user_1")
+		c.Set("role", "user")
+	})
+	router.POST("/ai/agent/runs", handler.Run)
+	router.GET("/ai/agent/runs", handler.ListRuns)
+	router.GET("/ai/agent/runs/:id", handler.GetRun)
+	router.GET("/ai/agent/tools", handler.ListTools)
+	caller := &agent.Caller{UserID: "user_1", Role: "user"}
+
+	t.Run("Run", func(t *testing.T) {
+		mockRunner.On("Run", mock.Anything, &agent.RunRequest{AgentID: "agent_1", UserID: "user_1", Role: "user", Message: "Who is Li?", Tools: []string{"lookup_employee"}}).
+			Return(&agent.RunDetail{
+				AgentRun: &domain.AgentRun{ID: "agent_run_1", Status: domain.AgentRunCompleted, Answer: "Li Lei works in HR.", Steps: 2},
+				Trace:    []*domain.AgentRunStep{{ID: "agent_run_1_step_001", Kind: domain.AgentStepToolCall, ToolName: "lookup_employee", Status: domain.ToolCallSucceeded}},
+			}, nil).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/ai/agent/runs", strings.NewReader(`{"message":"Who is Li?","agent_id":"agent_1","tools":["lookup_employee"]}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, "Li Lei works in HR.", body["answer"])
+		assert.Equal(t, float64(2), body["steps"])
+		assert.Len(t, body["trace"], 1)
+	})
+
+	t.Run("RunErrors", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/ai/agent/runs", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		for message, status := range map[string]int{
+			"unknown tool: send_email": http.StatusBadRequest,
+			"failed to run agent":      http.StatusBadGateway,
+		} {
+			mockRunner.On("Run", mock.Anything, mock.Anything).Return(nil, errors.New(message)).Once()
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/ai/agent/runs", strings.NewReader(`{"message":"Hi"}`))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			assert.Equal(t, status, w.Code, message)
+		}
+	})
+
+	t.Run("Runs", func(t *testing.T) {
+		mockRunner.On("ListRuns", mock.Anything, caller, "").
+			Return([]*domain.AgentRun{{ID: "agent_run_1", Message: "Who is Li?"}}, nil).Once()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/ai/agent/runs", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"message":"Who is Li?"`)
+
+		mockRunner.On("GetRun", mock.Anything, caller, "agent_run_2").Return(nil, errors.New("agent run not found")).Once()
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, "/ai/agent/runs/agent_run_2", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Tools", func(t *testing.T) {
+		mockRunner.On("Tools").Return([]agent.ToolDefinition{{Name: "lookup_employee", Parameters: json.RawMessage(`{"type":"object"}`)}}).Once()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/ai/agent/tools", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"name":"lookup_employee"`)
+	})
+
+	mockRunner.AssertExpectations(t)
+}
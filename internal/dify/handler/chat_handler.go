@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"cdk-office/internal/dify/client"
+	"cdk-office/pkg/logger"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// keepAliveInterval is how often a comment is sent while no event is relayed,
+	// so proxies do not close the connection
+	keepAliveInterval = 15 * time.Second
+	// stopTimeout bounds stopping the Dify task of a client that went away
+	stopTimeout = 5 * time.Second
+)
+
+// ChatHandlerInterface defines the interface for the streamed AI chat handler
+type ChatHandlerInterface interface {
+	StreamChat(c *gin.Context)
+	StreamCompletion(c *gin.Context)
+}
+
+// ChatHandler implements the ChatHandlerInterface by relaying Dify answers as server-sent events
+type ChatHandler struct {
+	streamingClient   client.StreamingClientInterface
+	keepAliveInterval time.Duration
+}
+
+// NewChatHandler creates a new instance of ChatHandler
+func NewChatHandler(streamingClient client.StreamingClientInterface) *ChatHandler {
+	return &ChatHandler{
+		streamingClient:   streamingClient,
+		keepAliveInterval: keepAliveInterval,
+	}
+}
+
+// StreamChatRequest represents the request for a streamed chat answer
+type StreamChatRequest struct {
+	Query          string                 `json:"query" binding:"required"`
+	ConversationID string                 `json:"conversation_id"`
+	Inputs         map[string]interface{} `json:"inputs"`
+}
+
+// StreamCompletionRequest represents the request for a streamed completion
+type StreamCompletionRequest struct {
+	Query  string                 `json:"query" binding:"required"`
+	Inputs map[string]interface{} `json:"inputs"`
+}
+
+// StreamChat handles streaming the answer to a chat message
+func (h *ChatHandler) StreamChat(c *gin.Context) {
+	var req StreamChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := c.GetString("user_id")
+	events, err := h.streamingClient.StreamChatMessage(c.Request.Context(), &client.ChatRequest{
+		Query:          req.Query,
+		Inputs:         req.Inputs,
+		ConversationID: req.ConversationID,
+		User:           user,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.relay(c, events, func(ctx context.Context, taskID string) error {
+		return h.streamingClient.StopChatMessage(ctx, taskID, user)
+	})
+}
+
+// StreamCompletion handles streaming a completion
+func (h *ChatHandler) StreamCompletion(c *gin.Context) {
+	var req StreamCompletionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := c.GetString("user_id")
+	events, err := h.streamingClient.StreamCompletionMessage(c.Request.Context(), &client.CompletionRequest{
+		Query:  req.Query,
+		Inputs: req.Inputs,
+		User:   user,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.relay(c, events, func(ctx context.Context, taskID string) error {
+		return h.streamingClient.StopCompletionMessage(ctx, taskID, user)
+	})
+}
+
+// relay writes events to the client as they arrive, and stops the Dify task when
+// the client goes away before the answer ends
+func (h *ChatHandler) relay(c *gin.Context, events <-chan *client.StreamEvent, stop func(ctx context.Context, taskID string) error) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	keepAlive := time.NewTicker(h.keepAliveInterval)
+	defer keepAlive.Stop()
+
+	var taskID string
+	finished := false
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			if event.TaskID != "" {
+				taskID = event.TaskID
+			}
+			if event.Event == client.StreamEventPing {
+				_, err := io.WriteString(w, ": ping\n\n")
+				return err == nil
+			}
+			c.SSEvent(event.Event, event)
+			if event.Event == client.StreamEventMessageEnd || event.Event == client.StreamEventError {
+				finished = true
+				return false
+			}
+			return true
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": ping\n\n")
+			return err == nil
+		}
+	})
+
+	if finished || taskID == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+	defer cancel()
+	if err := stop(ctx, taskID); err != nil {
+		logger.Warn("failed to stop abandoned answer", "task_id", taskID, "error", err)
+	}
+}
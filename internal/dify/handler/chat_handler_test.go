@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"cdk-office/internal/dify/client"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockStreamingClient is a mock implementation of StreamingClientInterface
+type MockStreamingClient struct {
+	mock.Mock
+}
+
+func (m *MockStreamingClient) StreamCompletionMessage(ctx context.Context, req *client.CompletionRequest) (<-chan *client.StreamEvent, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(chan *client.StreamEvent), args.Error(1)
+}
+
+func (m *MockStreamingClient) StreamChatMessage(ctx context.Context, req *client.ChatRequest) (<-chan *client.StreamEvent, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(chan *client.StreamEvent), args.Error(1)
+}
+
+func (m *MockStreamingClient) StopCompletionMessage(ctx context.Context, taskID, user string) error {
+	args := m.Called(ctx, taskID, user)
+	return args.Error(0)
+}
+
+func (m *MockStreamingClient) StopChatMessage(ctx context.Context, taskID, user string) error {
+	args := m.Called(ctx, taskID, user)
+	return args.Error(0)
+}
+
+// newChatServer serves the handler behind a real listener, as streaming needs a
+// connection that can be closed by the client
+func newChatServer(handler *ChatHandler) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", "user_1")
+	})
+	router.POST("/ai/chat/stream", handler.StreamChat)
+	router.POST("/ai/completion/stream", handler.StreamCompletion)
+	return httptest.NewServer(router)
+}
+
+// TestChatHandlerStreamChat tests that chat answers are relayed as server-sent events
+func TestChatHandlerStreamChat(t *testing.T) {
+	mockClient := new(MockStreamingClient)
+	server := newChatServer(NewChatHandler(mockClient))
+	defer server.Close()
+
+	events := make(chan *client.StreamEvent, 4)
+	events <- &client.StreamEvent{Event: client.StreamEventMessage, TaskID: "task_1", Answer: "Hello"}
+	events <- &client.StreamEvent{Event: client.StreamEventPing}
+	events <- &client.StreamEvent{Event: client.StreamEventMessage, TaskID: "task_1", Answer: " world"}
+	events <- &client.StreamEvent{Event: client.StreamEventMessageEnd, TaskID: "task_1", ConversationID: "conv_1"}
+	close(events)
+	mockClient.On("StreamChatMessage", mock.Anything, mock.MatchedBy(func(req *client.ChatRequest) bool {
+		return req.Query == "Hi" && req.User == "user_1" && req.ConversationID == "conv_1"
+	})).Return(events, nil).Once()
+
+	resp, err := http.Post(server.URL+"/ai/chat/stream", "application/json", strings.NewReader(`{"query":"Hi","conversation_id":"conv_1"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "no", resp.Header.Get("X-Accel-Buffering"))
+	assert.Contains(t, string(body), "event:message\ndata:{\"event\":\"message\",\"task_id\":\"task_1\",\"answer\":\"Hello\"}\n\n")
+	assert.Contains(t, string(body), ": ping\n\n")
+	assert.Contains(t, string(body), "event:message_end\n")
+	assert.Contains(t, string(body), "\"conversation_id\":\"conv_1\"")
+	mockClient.AssertNotCalled(t, "StopChatMessage", mock.Anything, mock.Anything, mock.Anything)
+	mockClient.AssertExpectations(t)
+}
+
+// TestChatHandlerStreamCompletionErrors tests the responses before streaming starts
+func TestChatHandlerStreamCompletionErrors(t *testing.T) {
+	mockClient := new(MockStreamingClient)
+	server := newChatServer(NewChatHandler(mockClient))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/ai/completion/stream", "application/json", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	mockClient.On("StreamCompletionMessage", mock.Anything, mock.Anything).Return(nil, errors.New("failed to create completion message")).Once()
+	resp, err = http.Post(server.URL+"/ai/completion/stream", "application/json", strings.NewReader(`{"query":"Hi"}`))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.JSONEq(t, `{"error":"failed to create completion message"}`, string(body))
+}
+
+// TestChatHandlerStopsAbandonedAnswers tests that the Dify task is stopped when the
+// client disconnects before the answer ends
+func TestChatHandlerStopsAbandonedAnswers(t *testing.T) {
+	mockClient := new(MockStreamingClient)
+	handler := NewChatHandler(mockClient)
+	handler.keepAliveInterval = 10 * time.Millisecond
+	server := newChatServer(handler)
+	defer server.Close()
+
+	events := make(chan *client.StreamEvent, 1)
+	events <- &client.StreamEvent{Event: client.StreamEventMessage, TaskID: "task_1", Answer: "Hel"}
+	mockClient.On("StreamCompletionMessage", mock.Anything, mock.Anything).Return(events, nil).Once()
+	stopped := make(chan struct{})
+	mockClient.On("StopCompletionMessage", mock.Anything, "task_1", "user_1").Return(nil).Run(func(mock.Arguments) {
+		close(stopped)
+	}).Once()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/ai/completion/stream", bytes.NewBufferString(`{"query":"Hi"}`))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "event:message\n", line)
+	resp.Body.Close()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("answer was not stopped")
+	}
+	mockClient.AssertExpectations(t)
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"cdk-office/internal/dify/rag"
+	"github.com/gin-gonic/gin"
+)
+
+// QAHandlerInterface defines the interface for the document Q&A handler
+type QAHandlerInterface interface {
+	Ask(c *gin.Context)
+	ListConversations(c *gin.Context)
+	GetConversation(c *gin.Context)
+	DeleteConversation(c *gin.Context)
+}
+
+// QAHandler implements the QAHandlerInterface
+type QAHandler struct {
+	qaService rag.QAServiceInterface
+}
+
+// NewQAHandler creates a new instance of QAHandler
+func NewQAHandler(qaService rag.QAServiceInterface) *QAHandler {
+	return &QAHandler{
+		qaService: qaService,
+	}
+}
+
+// AskRequest represents the request for asking a question about a team's documents
+type AskRequest struct {
+	Question       string `json:"question" binding:"required"`
+	TeamID         string `json:"team_id"`
+	ConversationID string `json:"conversation_id"`
+}
+
+// Ask handles answering a question with citations of the team's documents
+func (h *QAHandler) Ask(c *gin.Context) {
+	var req AskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.qaService.Ask(c.Request.Context(), &rag.AskRequest{
+		UserID:         c.GetString("user_id"),
+		Role:           c.GetString("role"),
+		TeamID:         req.TeamID,
+		ConversationID: req.ConversationID,
+		Question:       req.Question,
+	})
+	if err != nil {
+		c.JSON(qaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ListConversations handles listing the conversations of the current user
+func (h *QAHandler) ListConversations(c *gin.Context) {
+	conversations, err := h.qaService.ListConversations(c.Request.Context(), c.GetString("user_id"), c.Query("team_id"))
+	if err != nil {
+		c.JSON(qaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": conversations})
+}
+
+// GetConversation handles retrieving a conversation of the current user with its messages
+func (h *QAHandler) GetConversation(c *gin.Context) {
+	conversation, err := h.qaService.GetConversation(c.Request.Context(), c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		c.JSON(qaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, conversation)
+}
+
+// DeleteConversation handles deleting a conversation of the current user
+func (h *QAHandler) DeleteConversation(c *gin.Context) {
+	if err := h.qaService.DeleteConversation(c.Request.Context(), c.GetString("user_id"), c.Param("id")); err != nil {
+		c.JSON(qaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "conversation deleted successfully"})
+}
+
+// qaErrorStatus maps Q&A service errors to HTTP status codes
+func qaErrorStatus(err error) int {
+	switch msg := err.Error(); {
+	case msg == "conversation not found":
+		return http.StatusNotFound
+	case msg == "access to team denied":
+		return http.StatusForbidden
+	case msg == "question is required" || msg == "team_id is required" || msg == "conversation belongs to another team":
+		return http.StatusBadRequest
+	case msg == "knowledge base is not configured":
+		return http.StatusServiceUnavailable
+	case msg == "failed to answer question":
+		return http.StatusBadGateway
+	case strings.HasPrefix(msg, "failed to"):
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
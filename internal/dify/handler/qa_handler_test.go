@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cdk-office/internal/dify/domain"
+	"cdk-office/internal/dify/rag"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockQAService is a mock implementation of QAServiceInterface
+type MockQAService struct {
+	mock.Mock
+}
+
+func (m *MockQAService) Ask(ctx context.Context, req *rag.AskRequest) (*rag.AskResult, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*rag.AskResult), args.Error(1)
+}
+
+func (m *MockQAService) ListConversations(ctx context.Context, userID, teamID string) ([]*domain.Conversation, error) {
+	args := m.Called(ctx, userID, teamID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*domain.Conversation), args.Error(1)
+}
+
+func (m *MockQAService) GetConversation(ctx context.Context, userID, conversationID string) (*rag.ConversationDetail, error) {
+	args := m.Called(ctx, userID, conversationID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*rag.ConversationDetail), args.Error(1)
+}
+
+func (m *MockQAService) DeleteConversation(ctx context.Context, userID, conversationID string) error {
+	args := m.Called(ctx, userID, conversationID)
+	return args.Error(0)
+}
+
+// TestQAHandler tests the QAHandler
+func TestQAHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockQAService)
+	handler := NewQAHandler(mockService)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", "user_1")
+		c.Set("role", "user")
+	})
+	router.POST("/ai/ask", handler.Ask)
+	router.GET("/ai/conversations", handler.ListConversations)
+	router.GET("/ai/conversations/:id", handler.GetConversation)
+	router.DELETE("/ai/conversations/:id", handler.DeleteConversation)
+
+	t.Run("Ask", func(t *testing.T) {
+		mockService.On("Ask", mock.Anything, &rag.AskRequest{UserID: "user_1", Role: "user", TeamID: "team_1", Question: "What is the leave policy?"}).
+			Return(&rag.AskResult{
+				ConversationID: "conv_1",
+				Answer:         "25 days [1].",
+				Citations:      []*domain.ConversationCitation{{Number: 1, DocumentID: "doc_1", Version: 2, Snippet: "25 days", Page: 3}},
+			}, nil).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/ai/ask", strings.NewReader(`{"question":"What is the leave policy?","team_id":"team_1"}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var result rag.AskResult
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		assert.Equal(t, "conv_1", result.ConversationID)
+		require.Len(t, result.Citations, 1)
+		assert.Equal(t, 3, result.Citations[0].Page)
+	})
+
+	t.Run("AskErrors", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/ai/ask", strings.NewReader(`{"team_id":"team_1"}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		for message, status := range map[string]int{
+			"access to team denied":            http.StatusForbidden,
+			"conversation not found":           http.StatusNotFound,
+			"knowledge base is not configured": http.StatusServiceUnavailable,
+			"failed to answer question":        http.StatusBadGateway,
+			"failed to save conversation":      http.StatusInternalServerError,
+		} {
+			mockService.On("Ask", mock.Anything, mock.Anything).Return(nil, errors.New(message)).Once()
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/ai/ask", strings.NewReader(`{"question":"Hi","team_id":"team_2"}`))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+			assert.Equal(t, status, w.Code, message)
+		}
+	})
+
+	t.Run("Conversations", func(t *testing.T) {
+		mockService.On("ListConversations", mock.Anything, "user_1", "team_1").
+			Return([]*domain.Conversation{{ID: "conv_1", Title: "Leave"}}, nil).Once()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/ai/conversations?team_id=team_1", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"title":"Leave"`)
+
+		mockService.On("GetConversation", mock.Anything, "user_1", "conv_2").Return(nil, errors.New("conversation not found")).Once()
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, "/ai/conversations/conv_2", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		mockService.On("DeleteConversation", mock.Anything, "user_1", "conv_1").Return(nil).Once()
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodDelete, "/ai/conversations/conv_1", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,554 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"cdk-office/internal/dify/client"
+	"cdk-office/internal/dify/domain"
+	documentdomain "cdk-office/internal/document/domain"
+	"cdk-office/internal/document/service"
+	"cdk-office/internal/shared/database"
+	"cdk-office/internal/shared/utils"
+	"cdk-office/pkg/logger"
+	"gorm.io/gorm"
+)
+
+const (
+	// retrievalTopK is the number of passages retrieved, before access filtering
+	retrievalTopK = 8
+	// maxPassages is the number of passages sent with a question
+	maxPassages = 5
+	// maxHistoryMessages is the number of earlier messages sent with a question
+	maxHistoryMessages = 6
+	// maxSnippetLength is the length in characters of the snippet kept in a citation
+	maxSnippetLength = 300
+	// maxTitleLength is the length in characters of a conversation title
+	maxTitleLength = 100
+)
+
+// noPassagesAnswer is the answer given, without asking the LLM, when no passage matches
+const noPassagesAnswer = "I could not find anything about this in the team's documents."
+
+// answerInstructions opens the prompt sent to the LLM
+const answerInstructions = `Answer the question using only the numbered passages from the team's documents below. ` +
+	`Cite every passage you use with its number in square brackets, such as [1]. ` +
+	`If the passages do not contain the answer, say that the documents do not cover it.`
+
+// citationMarker matches the [n] passage markers of an answer
+var citationMarker = regexp.MustCompile(`\[(\d+)\]`)
+
+// KnowledgeRetriever retrieves the passages of a team's documents matching a query
+type KnowledgeRetriever interface {
+	Retrieve(ctx context.Context, teamID, query string, topK int) ([]*service.KnowledgeHit, error)
+}
+
+// QAServiceInterface defines the interface for the document Q&A service
+type QAServiceInterface interface {
+	Ask(ctx context.Context, req *AskRequest) (*AskResult, error)
+	ListConversations(ctx context.Context, userID, teamID string) ([]*domain.Conversation, error)
+	GetConversation(ctx context.Context, userID, conversationID string) (*ConversationDetail, error)
+	DeleteConversation(ctx context.Context, userID, conversationID string) error
+}
+
+// QAService implements the QAServiceInterface. It answers questions from the
+// passages of a team's documents the user may read, and keeps the questions and
+// cited answers of each user as conversations.
+type QAService struct {
+	db         *gorm.DB
+	retriever  KnowledgeRetriever
+	access     service.DocumentAccessInterface
+	difyClient client.DifyClientInterface
+}
+
+// NewQAService creates a new instance of QAService
+func NewQAService(retriever KnowledgeRetriever, difyClient client.DifyClientInterface) *QAService {
+	db := database.GetDB()
+	return NewQAServiceWithDeps(db, retriever, service.NewDocumentAccessWithDB(db), difyClient)
+}
+
+// NewQAServiceWithDeps creates a new instance of QAService with specific dependencies
+func NewQAServiceWithDeps(db *gorm.DB, retriever KnowledgeRetriever, access service.DocumentAccessInterface, difyClient client.DifyClientInterface) *QAService {
+	return &QAService{
+		db:         db,
+		retriever:  retriever,
+		access:     access,
+		difyClient: difyClient,
+	}
+}
+
+// AskRequest represents a question about the documents of a team. A question
+// continuing a conversation may leave TeamID empty.
+type AskRequest struct {
+	UserID         string
+	Role           string
+	TeamID         string
+	ConversationID string
+	Question       string
+}
+
+// AskResult represents the answer to a question
+type AskResult struct {
+	ConversationID string                         `json:"conversation_id"`
+	MessageID      string                         `json:"message_id"`
+	Answer         string                         `json:"answer"`
+	Citations      []*domain.ConversationCitation `json:"citations"`
+	CreatedAt      time.Time                      `json:"created_at"`
+}
+
+// Message represents a message of a conversation with the passages it cites
+type Message struct {
+	*domain.ConversationMessage
+	Citations []*domain.ConversationCitation `json:"citations,omitempty"`
+}
+
+// ConversationDetail represents a conversation with its messages, oldest first
+type ConversationDetail struct {
+	*domain.Conversation
+	Messages []*Message `json:"messages"`
+}
+
+// passage is a retrieved passage numbered as sent to the LLM
+type passage struct {
+	hit      *service.KnowledgeHit
+	citation *domain.ConversationCitation
+}
+
+// Ask answers a question from the passages of the team's documents the user may read
+func (s *QAService) Ask(ctx context.Context, req *AskRequest) (*AskResult, error) {
+	question := strings.TrimSpace(req.Question)
+	if question == "" {
+		return nil, errors.New("question is required")
+	}
+
+	conversation, history, err := s.openConversation(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	allowed, err := s.access.CanReadTeam(ctx, req.UserID, req.Role, conversation.TeamID)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, errors.New("access to team denied")
+	}
+
+	askedAt := time.Now()
+	passages, err := s.retrieve(ctx, req, conversation.TeamID, retrievalQuery(question, history))
+	if err != nil {
+		return nil, err
+	}
+
+	answer := noPassagesAnswer
+	var citations []*domain.ConversationCitation
+	if len(passages) > 0 {
+		resp, err := s.difyClient.CreateCompletionMessage(ctx, &client.CompletionRequest{
+			Query:        buildPrompt(question, history, passages),
+			ResponseMode: "blocking",
+			User:         req.UserID,
+		})
+		if err != nil {
+			logger.Error("failed to answer question", "error", err)
+			return nil, errors.New("failed to answer question")
+		}
+		answer = strings.TrimSpace(resp.Answer)
+		citations = citedPassages(answer, passages)
+	}
+
+	assistantMessage, err := s.saveExchange(ctx, conversation, question, answer, citations, askedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("answered question", "conversation_id", conversation.ID, "team_id", conversation.TeamID, "passages", len(passages), "citations", len(citations))
+
+	if citations == nil {
+		citations = []*domain.ConversationCitation{}
+	}
+	return &AskResult{
+		ConversationID: conversation.ID,
+		MessageID:      assistantMessage.ID,
+		Answer:         answer,
+		Citations:      citations,
+		CreatedAt:      assistantMessage.CreatedAt,
+	}, nil
+}
+
+// ListConversations returns the conversations of a user, optionally of one team, latest first
+func (s *QAService) ListConversations(ctx context.Context, userID, teamID string) ([]*domain.Conversation, error) {
+	query := s.db.WithContext(ctx).Where("user_id = ?", userID)
+	if teamID != "" {
+		query = query.Where("team_id = ?", teamID)
+	}
+
+	var conversations []*domain.Conversation
+	if err := query.Order("updated_at desc").Find(&conversations).Error; err != nil {
+		logger.Error("failed to list conversations", "error", err)
+		return nil, errors.New("failed to list conversations")
+	}
+	return conversations, nil
+}
+
+// GetConversation returns a conversation of a user with its messages and citations
+func (s *QAService) GetConversation(ctx context.Context, userID, conversationID string) (*ConversationDetail, error) {
+	conversation, err := s.findConversation(ctx, userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []*domain.ConversationMessage
+	if err := s.db.WithContext(ctx).Where("conversation_id = ?", conversation.ID).
+		Order("created_at, role desc").Find(&messages).Error; err != nil {
+		logger.Error("failed to get conversation messages", "error", err)
+		return nil, errors.New("failed to get conversation")
+	}
+	messageIDs := make([]string, 0, len(messages))
+	for _, message := range messages {
+		messageIDs = append(messageIDs, message.ID)
+	}
+	var citations []*domain.ConversationCitation
+	if len(messageIDs) > 0 {
+		if err := s.db.WithContext(ctx).Where("message_id IN ?", messageIDs).Order("number").Find(&citations).Error; err != nil {
+			logger.Error("failed to get conversation citations", "error", err)
+			return nil, errors.New("failed to get conversation")
+		}
+	}
+	byMessage := make(map[string][]*domain.ConversationCitation)
+	for _, citation := range citations {
+		byMessage[citation.MessageID] = append(byMessage[citation.MessageID], citation)
+	}
+
+	detail := &ConversationDetail{Conversation: conversation, Messages: make([]*Message, 0, len(messages))}
+	for _, message := range messages {
+		detail.Messages = append(detail.Messages, &Message{ConversationMessage: message, Citations: byMessage[message.ID]})
+	}
+	return detail, nil
+}
+
+// DeleteConversation deletes a conversation of a user with its messages
+func (s *QAService) DeleteConversation(ctx context.Context, userID, conversationID string) error {
+	conversation, err := s.findConversation(ctx, userID, conversationID)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		messages := tx.Model(&domain.ConversationMessage{}).Select("id").Where("conversation_id = ?", conversation.ID)
+		if err := tx.Where("message_id IN (?)", messages).Delete(&domain.ConversationCitation{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("conversation_id = ?", conversation.ID).Delete(&domain.ConversationMessage{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(conversation).Error
+	})
+	if err != nil {
+		logger.Error("failed to delete conversation", "error", err)
+		return errors.New("failed to delete conversation")
+	}
+
+	logger.Info("deleted conversation", "conversation_id", conversation.ID)
+
+	return nil
+}
+
+// openConversation returns the conversation a question continues with its latest
+// messages, oldest first, or a new unsaved conversation
+func (s *QAService) openConversation(ctx context.Context, req *AskRequest) (*domain.Conversation, []*domain.ConversationMessage, error) {
+	if req.ConversationID == "" {
+		if req.TeamID == "" {
+			return nil, nil, errors.New("team_id is required")
+		}
+		return &domain.Conversation{UserID: req.UserID, TeamID: req.TeamID}, nil, nil
+	}
+
+	conversation, err := s.findConversation(ctx, req.UserID, req.ConversationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if req.TeamID != "" && req.TeamID != conversation.TeamID {
+		return nil, nil, errors.New("conversation belongs to another team")
+	}
+
+	var history []*domain.ConversationMessage
+	if err := s.db.WithContext(ctx).Where("conversation_id = ?", conversation.ID).
+		Order("created_at desc, role").Limit(maxHistoryMessages).Find(&history).Error; err != nil {
+		logger.Error("failed to get conversation history", "error", err)
+		return nil, nil, errors.New("failed to get conversation")
+	}
+	for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+		history[i], history[j] = history[j], history[i]
+	}
+	return conversation, history, nil
+}
+
+// findConversation returns a conversation of a user
+func (s *QAService) findConversation(ctx context.Context, userID, conversationID string) (*domain.Conversation, error) {
+	var conversation domain.Conversation
+	if err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", conversationID, userID).First(&conversation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("conversation not found")
+		}
+		logger.Error("failed to get conversation", "error", err)
+		return nil, errors.New("failed to get conversation")
+	}
+	return &conversation, nil
+}
+
+// retrieve returns the best passages of the team's documents the user may read,
+// with their document version and page
+func (s *QAService) retrieve(ctx context.Context, req *AskRequest, teamID, query string) ([]*passage, error) {
+	hits, err := s.retriever.Retrieve(ctx, teamID, query, retrievalTopK)
+	if err != nil {
+		return nil, err
+	}
+
+	documentIDs := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		documentIDs = append(documentIDs, hit.DocumentID)
+	}
+	readable, err := s.access.ReadableDocuments(ctx, req.UserID, req.Role, documentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var passages []*passage
+	for _, hit := range hits {
+		document, ok := readable[hit.DocumentID]
+		if !ok || document.TeamID != teamID {
+			continue
+		}
+		title := hit.Title
+		if title == "" {
+			title = document.Title
+		}
+		passages = append(passages, &passage{
+			hit: hit,
+			citation: &domain.ConversationCitation{
+				Number:     len(passages) + 1,
+				DocumentID: hit.DocumentID,
+				Title:      title,
+				Snippet:    truncate(strings.TrimSpace(hit.Content), maxSnippetLength),
+				Score:      hit.Score,
+			},
+		})
+		if len(passages) == maxPassages {
+			break
+		}
+	}
+	if len(passages) > 0 {
+		s.locatePassages(ctx, passages)
+	}
+	return passages, nil
+}
+
+// locatePassages sets the version of the document each passage was indexed from
+// and, for paginated documents, the page it is on
+func (s *QAService) locatePassages(ctx context.Context, passages []*passage) {
+	documentIDs := make([]string, 0, len(passages))
+	for _, p := range passages {
+		documentIDs = append(documentIDs, p.citation.DocumentID)
+	}
+
+	var records []*documentdomain.KnowledgeDocument
+	if err := s.db.WithContext(ctx).Where("document_id IN ?", documentIDs).Find(&records).Error; err != nil {
+		logger.Warn("failed to find cited document versions", "error", err)
+	}
+	versionIDs := make(map[string]string, len(records))
+	var ids []string
+	for _, record := range records {
+		if record.VersionID != "" {
+			versionIDs[record.DocumentID] = record.VersionID
+			ids = append(ids, record.VersionID)
+		}
+	}
+	var versions []*documentdomain.DocumentVersion
+	if len(ids) > 0 {
+		if err := s.db.WithContext(ctx).Where("id IN ?", ids).Find(&versions).Error; err != nil {
+			logger.Warn("failed to find cited document versions", "error", err)
+		}
+	}
+	versionNumbers := make(map[string]int, len(versions))
+	for _, version := range versions {
+		versionNumbers[version.ID] = version.Version
+	}
+
+	var entries []*documentdomain.SearchIndexEntry
+	if err := s.db.WithContext(ctx).Select("document_id", "body").
+		Where("document_id IN ? AND body LIKE ?", documentIDs, "%"+service.PageBreak+"%").
+		Find(&entries).Error; err != nil {
+		logger.Warn("failed to find cited document text", "error", err)
+	}
+	bodies := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		bodies[entry.DocumentID] = entry.Body
+	}
+
+	for _, p := range passages {
+		p.citation.VersionID = versionIDs[p.citation.DocumentID]
+		p.citation.Version = versionNumbers[p.citation.VersionID]
+		if body, ok := bodies[p.citation.DocumentID]; ok {
+			p.citation.Page = pageOf(body, p.hit.Content)
+		}
+	}
+}
+
+// saveExchange stores a question and its answer, and the conversation when new
+func (s *QAService) saveExchange(ctx context.Context, conversation *domain.Conversation, question, answer string, citations []*domain.ConversationCitation, askedAt time.Time) (*domain.ConversationMessage, error) {
+	now := time.Now()
+	if !now.After(askedAt) {
+		now = askedAt.Add(time.Microsecond)
+	}
+	questionMessage := &domain.ConversationMessage{
+		ID:        utils.GenerateConversationMessageID(),
+		Role:      domain.MessageRoleUser,
+		Content:   question,
+		CreatedAt: askedAt,
+	}
+	answerMessage := &domain.ConversationMessage{
+		ID:        utils.GenerateConversationMessageID(),
+		Role:      domain.MessageRoleAssistant,
+		Content:   answer,
+		CreatedAt: now,
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if conversation.ID == "" {
+			conversation.ID = utils.GenerateConversationID()
+			conversation.Title = truncate(question, maxTitleLength)
+			conversation.CreatedAt = askedAt
+			conversation.UpdatedAt = now
+			if err := tx.Create(conversation).Error; err != nil {
+				return err
+			}
+		} else {
+			conversation.UpdatedAt = now
+			if err := tx.Model(conversation).Update("updated_at", now).Error; err != nil {
+				return err
+			}
+		}
+
+		questionMessage.ConversationID = conversation.ID
+		answerMessage.ConversationID = conversation.ID
+		if err := tx.Create(questionMessage).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(answerMessage).Error; err != nil {
+			return err
+		}
+		for _, citation := range citations {
+			citation.ID = utils.GenerateConversationCitationID()
+			citation.MessageID = answerMessage.ID
+			if err := tx.Create(citation).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("failed to save conversation", "error", err)
+		return nil, errors.New("failed to save conversation")
+	}
+	return answerMessage, nil
+}
+
+// retrievalQuery returns the query passages are retrieved with; a follow-up
+// question is joined to the previous question, which it often refers to
+func retrievalQuery(question string, history []*domain.ConversationMessage) string {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == domain.MessageRoleUser {
+			return history[i].Content + "\n" + question
+		}
+	}
+	return question
+}
+
+// buildPrompt returns the prompt asking the LLM to answer a question from passages
+func buildPrompt(question string, history []*domain.ConversationMessage, passages []*passage) string {
+	var prompt strings.Builder
+	prompt.WriteString(answerInstructions)
+
+	if len(history) > 0 {
+		prompt.WriteString("\n\nConversation so far:")
+		for _, message := range history {
+			speaker := "User"
+			if message.Role == domain.MessageRoleAssistant {
+				speaker = "Assistant"
+			}
+			fmt.Fprintf(&prompt, "\n%s: %s", speaker, message.Content)
+		}
+	}
+
+	prompt.WriteString("\n\nPassages:")
+	for _, p := range passages {
+		fmt.Fprintf(&prompt, "\n\n[%d] %s", p.citation.Number, p.citation.Title)
+		if p.citation.Page > 0 {
+			fmt.Fprintf(&prompt, " (page %d)", p.citation.Page)
+		}
+		prompt.WriteString("\n" + strings.TrimSpace(p.hit.Content))
+	}
+
+	prompt.WriteString("\n\nQuestion: " + question)
+	return prompt.String()
+}
+
+// citedPassages returns the citations of the passages an answer refers to with
+// [n] markers, or of every passage when it has none
+func citedPassages(answer string, passages []*passage) []*domain.ConversationCitation {
+	cited := make(map[int]bool)
+	for _, match := range citationMarker.FindAllStringSubmatch(answer, -1) {
+		if number, err := strconv.Atoi(match[1]); err == nil {
+			cited[number] = true
+		}
+	}
+
+	citations := make([]*domain.ConversationCitation, 0, len(passages))
+	for _, p := range passages {
+		if len(cited) == 0 || cited[p.citation.Number] {
+			citations = append(citations, p.citation)
+		}
+	}
+	if len(citations) == 0 {
+		for _, p := range passages {
+			citations = append(citations, p.citation)
+		}
+	}
+	return citations
+}
+
+// pageOf returns the page of a paginated text a passage ends on, or zero when it
+// cannot be found. The passage may start with the document title or description,
+// so ever shorter runs of its last words are looked for; whitespace is ignored as
+// it is normalised when indexing.
+func pageOf(body, content string) int {
+	words := strings.Fields(content)
+	pages := strings.Split(body, service.PageBreak)
+	for i, page := range pages {
+		pages[i] = strings.Join(strings.Fields(page), " ")
+	}
+
+	for size := min(len(words), 8); size >= min(len(words), 3) && size > 0; size-- {
+		needle := strings.Join(words[len(words)-size:], " ")
+		for i, page := range pages {
+			if strings.Contains(page, needle) {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
+
+// truncate shortens text to at most maxLength characters
+func truncate(text string, maxLength int) string {
+	if utf8.RuneCountInString(text) <= maxLength {
+		return text
+	}
+	runes := []rune(text)
+	return strings.TrimSpace(string(runes[:maxLength-1])) + "…"
+}
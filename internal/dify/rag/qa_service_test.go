@@ -0,0 +1,163 @@
+package rag
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"cdk-office/internal/dify/client"
+	"cdk-office/internal/dify/client/difytest"
+	"cdk-office/internal/dify/domain"
+	documentdomain "cdk-office/internal/document/domain"
+	"cdk-office/internal/document/service"
+	"cdk-office/internal/document/storage"
+	employeedomain "cdk-office/internal/employee/domain"
+	"cdk-office/internal/shared/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// stubDifyClient answers every completion with the same answer
+type stubDifyClient struct {
+	answer  string
+	prompts []string
+}
+
+func (s *stubDifyClient) CreateCompletionMessage(ctx context.Context, req *client.CompletionRequest) (*client.CompletionResponse, error) {
+	s.prompts = append(s.prompts, req.Query)
+	return &client.CompletionResponse{MessageID: "msg_1", Answer: s.answer}, nil
+}
+
+func (s *stubDifyClient) CreateChatMessage(ctx context.Context, req *client.ChatRequest) (*client.ChatResponse, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s *stubDifyClient) UploadFile(ctx context.Context, req *client.FileUploadRequest) (*client.FileUploadResponse, error) {
+	return nil, errors.New("not supported")
+}
+
+// setupQAService returns a Q&A service over a team_1 travel policy, which user_1
+// may read as an employee of team_1, and a team_2 document user_1 may not read
+func setupQAService(t *testing.T) (*QAService, *stubDifyClient, *gorm.DB, *documentdomain.Document) {
+	ctx := context.Background()
+	db := testutils.SetupTestDB()
+	dify := difytest.NewServer("dataset-key")
+	t.Cleanup(dify.Close)
+
+	driver, err := storage.NewLocalDriver(t.TempDir())
+	require.NoError(t, err)
+	storageService := service.NewStorageServiceWithDriver(driver)
+	knowledgeBase := service.NewKnowledgeBaseWithClient(db, client.NewDatasetClient(dify.URL, "dataset-key"), storageService)
+	indexer := service.DocumentIndexers{service.NewSearchServiceWithStorage(db, storageService), knowledgeBase}
+	documentService := service.NewDocumentServiceWithIndexer(db, storageService, indexer)
+
+	upload := func(title, text, ownerID, teamID string) *documentdomain.Document {
+		stored, err := storageService.SaveFile(ctx, strings.NewReader(text))
+		require.NoError(t, err)
+		document, err := documentService.Upload(ctx, &service.UploadRequest{
+			Title: title, FilePath: stored.Key, FileSize: stored.Size, MimeType: "text/plain", OwnerID: ownerID, TeamID: teamID,
+		})
+		require.NoError(t, err)
+		return document
+	}
+	travel := upload("Travel policy", "Flights are booked by the office."+service.PageBreak+"Hotels up to 150 euros per night are reimbursed.", "user_2", "team_1")
+	upload("Board minutes", "Hotels for the board retreat are not reimbursed.", "user_3", "team_2")
+	require.NoError(t, db.Create(&employeedomain.Employee{ID: "emp_1", UserID: "user_1", TeamID: "team_1", EmployeeID: "E001", Status: "active"}).Error)
+
+	difyClient := &stubDifyClient{answer: "Hotels are reimbursed up to 150 euros per night [1]."}
+	return NewQAServiceWithDeps(db, knowledgeBase, service.NewDocumentAccessWithDB(db), difyClient), difyClient, db, travel
+}
+
+// TestQAServiceAsk tests that answers cite the passages they are based on and
+// that follow-up questions are asked with the conversation so far
+func TestQAServiceAsk(t *testing.T) {
+	ctx := context.Background()
+	qaService, difyClient, db, travel := setupQAService(t)
+
+	result, err := qaService.Ask(ctx, &AskRequest{UserID: "user_1", Role: "user", TeamID: "team_1", Question: "hotels reimbursed"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hotels are reimbursed up to 150 euros per night [1].", result.Answer)
+	assert.NotEmpty(t, result.ConversationID)
+	require.Len(t, result.Citations, 1)
+	citation := result.Citations[0]
+	assert.Equal(t, 1, citation.Number)
+	assert.Equal(t, travel.ID, citation.DocumentID)
+	assert.Equal(t, "Travel policy", citation.Title)
+	assert.NotEmpty(t, citation.VersionID)
+	assert.Equal(t, 1, citation.Version)
+	assert.Equal(t, 2, citation.Page)
+	assert.Contains(t, citation.Snippet, "Hotels up to 150 euros")
+
+	require.Len(t, difyClient.prompts, 1)
+	assert.Contains(t, difyClient.prompts[0], "[1] Travel policy (page 2)\n")
+	assert.Contains(t, difyClient.prompts[0], "Question: hotels reimbursed")
+	assert.NotContains(t, difyClient.prompts[0], "board retreat")
+
+	// Follow-up questions continue the conversation
+	difyClient.answer = "Flights are booked by the office [2]."
+	followUp, err := qaService.Ask(ctx, &AskRequest{UserID: "user_1", Role: "user", ConversationID: result.ConversationID, Question: "and flights?"})
+	require.NoError(t, err)
+	assert.Equal(t, result.ConversationID, followUp.ConversationID)
+	assert.Contains(t, difyClient.prompts[1], "Conversation so far:\nUser: hotels reimbursed\nAssistant: Hotels are reimbursed up to 150 euros per night [1].")
+
+	detail, err := qaService.GetConversation(ctx, "user_1", result.ConversationID)
+	require.NoError(t, err)
+	assert.Equal(t, "hotels reimbursed", detail.Title)
+	require.Len(t, detail.Messages, 4)
+	assert.Equal(t, domain.MessageRoleUser, detail.Messages[0].Role)
+	assert.Equal(t, domain.MessageRoleAssistant, detail.Messages[1].Role)
+	assert.Len(t, detail.Messages[1].Citations, 1)
+	assert.Equal(t, "and flights?", detail.Messages[2].Content)
+	assert.Equal(t, followUp.MessageID, detail.Messages[3].ID)
+
+	// Conversations are private to their user
+	_, err = qaService.GetConversation(ctx, "user_2", result.ConversationID)
+	assert.EqualError(t, err, "conversation not found")
+	_, err = qaService.Ask(ctx, &AskRequest{UserID: "user_2", ConversationID: result.ConversationID, Question: "and trains?"})
+	assert.EqualError(t, err, "conversation not found")
+
+	conversations, err := qaService.ListConversations(ctx, "user_1", "")
+	require.NoError(t, err)
+	assert.Len(t, conversations, 1)
+
+	require.NoError(t, qaService.DeleteConversation(ctx, "user_1", result.ConversationID))
+	var count int64
+	db.Model(&domain.ConversationCitation{}).Count(&count)
+	assert.Zero(t, count)
+	db.Model(&domain.ConversationMessage{}).Count(&count)
+	assert.Zero(t, count)
+}
+
+// TestQAServiceAccess tests that questions are only answered from documents the user may read
+func TestQAServiceAccess(t *testing.T) {
+	ctx := context.Background()
+	qaService, difyClient, _, _ := setupQAService(t)
+
+	_, err := qaService.Ask(ctx, &AskRequest{UserID: "user_1", Role: "user", TeamID: "team_2", Question: "hotels reimbursed"})
+	assert.EqualError(t, err, "access to team denied")
+
+	result, err := qaService.Ask(ctx, &AskRequest{UserID: "admin_1", Role: "admin", TeamID: "team_2", Question: "hotels reimbursed"})
+	require.NoError(t, err)
+	require.Len(t, result.Citations, 1)
+	assert.Equal(t, "Board minutes", result.Citations[0].Title)
+
+	// No passage matches: answered without asking the LLM
+	asked := len(difyClient.prompts)
+	result, err = qaService.Ask(ctx, &AskRequest{UserID: "user_1", Role: "user", TeamID: "team_1", Question: "parking"})
+	require.NoError(t, err)
+	assert.Equal(t, noPassagesAnswer, result.Answer)
+	assert.Empty(t, result.Citations)
+	assert.Len(t, difyClient.prompts, asked)
+
+	_, err = qaService.Ask(ctx, &AskRequest{UserID: "user_1", Question: "parking"})
+	assert.EqualError(t, err, "team_id is required")
+}
+
+func TestPageOf(t *testing.T) {
+	body := "Title\n\nFirst   page text." + service.PageBreak + "Second page\ntext about hotels." + service.PageBreak + "Third page."
+	assert.Equal(t, 2, pageOf(body, "Second page text about hotels."))
+	assert.Equal(t, 1, pageOf(body, "Travel policy\n\nFirst page text."))
+	assert.Equal(t, 0, pageOf(body, "Not in the document"))
+}
@@ -9,6 +9,9 @@ import (
 	"cdk-office/pkg/logger"
 )
 
+// defaultTopK is the number of segments returned by Search
+const defaultTopK = 5
+
 // RAGServiceInterface defines the interface for RAG service
 type RAGServiceInterface interface {
 	Search(ctx context.Context, query string, kb string) ([]*Document, error)
@@ -16,138 +19,136 @@ type RAGServiceInterface interface {
 	UpdateDocument(ctx context.Context, kbID string, doc *Document) error
 }
 
-// RAGService implements the RAGServiceInterface
+// RAGService implements the RAGServiceInterface on the Dify knowledge base API
 type RAGService struct {
-	difyClient client.DifyClientInterface
+	datasetClient client.DatasetClientInterface
 }
 
 // NewRAGService creates a new instance of RAGService
-func NewRAGService(difyClient client.DifyClientInterface) *RAGService {
+func NewRAGService(datasetClient client.DatasetClientInterface) *RAGService {
 	return &RAGService{
-		difyClient: difyClient,
+		datasetClient: datasetClient,
 	}
 }
 
 // Document represents a document in the knowledge base
 type Document struct {
-	ID       string `json:"id"`
-	Title    string `json:"title"`
-	Content  string `json:"content"`
+	ID       string                 `json:"id"`
+	Title    string                 `json:"title"`
+	Content  string                 `json:"content"`
 	Metadata map[string]interface{} `json:"metadata"`
 }
 
 // KnowledgeBase represents a knowledge base
 type KnowledgeBase struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	CreatedAt   string    `json:"created_at"`
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	CreatedAt   string `json:"created_at"`
 }
 
 // KBConfig represents knowledge base configuration
 type KBConfig struct {
 	Description string            `json:"description"`
-	Parameters  map[string]string `json:"parameters"`
+	Parameters  map[string]string `json:"parameters"` // indexing_technique and permission of the dataset
 }
 
-// Search performs a search in the knowledge base
+// Search returns the segments of a knowledge base matching a query, best first
 func (s *RAGService) Search(ctx context.Context, query string, kb string) ([]*Document, error) {
-	// Prepare the request for Dify RAG API
-	req := &client.CompletionRequest{
-		Query: query,
-		Inputs: map[string]interface{}{
-			"kb_id": kb,
-		},
-		ResponseMode: "blocking",
-		User: "cdk-office",
-	}
-	
-	// Call the Dify API
-	resp, err := s.difyClient.CreateCompletionMessage(ctx, req)
+	resp, err := s.datasetClient.Retrieve(ctx, kb, &client.RetrieveRequest{
+		Query:          query,
+		RetrievalModel: &client.RetrievalModel{SearchMethod: "semantic_search", TopK: defaultTopK},
+	})
 	if err != nil {
 		logger.Error("failed to search knowledge base", "error", err)
+		if errors.Is(err, client.ErrNotFound) {
+			return nil, errors.New("knowledge base not found")
+		}
 		return nil, errors.New("failed to search knowledge base")
 	}
-	
-	// Parse the response and convert to Document objects
-	// This implementation assumes the Dify API returns results in a format we can convert to Document objects
-	documents := []*Document{
-		{
-			ID:      "doc_" + resp.MessageID,
-			Title:   "Search Result for: " + query,
-			Content: resp.Answer,
+
+	documents := make([]*Document, 0, len(resp.Records))
+	for _, record := range resp.Records {
+		if record.Segment == nil {
+			continue
+		}
+		document := &Document{
+			ID:      record.Segment.DocumentID,
+			Content: record.Segment.Content,
 			Metadata: map[string]interface{}{
-				"source": "knowledge_base",
-				"score":  0.95,
+				"source":     "knowledge_base",
+				"score":      record.Score,
+				"segment_id": record.Segment.ID,
+				"position":   record.Segment.Position,
 			},
-		},
+		}
+		if record.Segment.Document != nil {
+			document.Title = record.Segment.Document.Name
+		}
+		documents = append(documents, document)
 	}
-	
+
 	logger.Info("performed knowledge base search", "query", query, "kb", kb, "results", len(documents))
-	
+
 	return documents, nil
 }
 
 // CreateKnowledgeBase creates a new knowledge base
 func (s *RAGService) CreateKnowledgeBase(ctx context.Context, name string, config *KBConfig) (*KnowledgeBase, error) {
-	// Prepare the request for Dify RAG API to create a knowledge base
-	// This is a simplified implementation - the actual Dify API may require different parameters
-	req := &client.CompletionRequest{
-		Query: "create_knowledge_base",
-		Inputs: map[string]interface{}{
-			"name": name,
-			"description": config.Description,
-			"parameters": config.Parameters,
-		},
-		ResponseMode: "blocking",
-		User: "cdk-office",
+	req := &client.CreateDatasetRequest{Name: name}
+	if config != nil {
+		req.Description = config.Description
+		req.IndexingTechnique = config.Parameters["indexing_technique"]
+		req.Permission = config.Parameters["permission"]
 	}
-	
-	// Call the Dify API
-	resp, err := s.difyClient.CreateCompletionMessage(ctx, req)
+
+	dataset, err := s.datasetClient.CreateDataset(ctx, req)
 	if err != nil {
 		logger.Error("failed to create knowledge base", "error", err)
+		if errors.Is(err, client.ErrConflict) {
+			return nil, errors.New("knowledge base name already exists")
+		}
 		return nil, errors.New("failed to create knowledge base")
 	}
-	
-	// Parse the response and create a KnowledgeBase object
+
 	kb := &KnowledgeBase{
-		ID:          "kb_" + resp.MessageID,
-		Name:        name,
-		Description: config.Description,
-		CreatedAt:   time.Now().Format(time.RFC3339),
+		ID:          dataset.ID,
+		Name:        dataset.Name,
+		Description: dataset.Description,
+		CreatedAt:   time.Unix(dataset.CreatedAt, 0).Format(time.RFC3339),
 	}
-	
+
 	logger.Info("created knowledge base", "name", name, "id", kb.ID)
-	
+
 	return kb, nil
 }
 
-// UpdateDocument updates a document in the knowledge base
+// UpdateDocument replaces the content of a document in the knowledge base,
+// creating the document when doc.ID is empty
 func (s *RAGService) UpdateDocument(ctx context.Context, kbID string, doc *Document) error {
-	// Prepare the request for Dify RAG API to update a document
-	// This is a simplified implementation - the actual Dify API may require different parameters
-	req := &client.CompletionRequest{
-		Query: "update_document",
-		Inputs: map[string]interface{}{
-			"kb_id": kbID,
-			"document_id": doc.ID,
-			"content": doc.Content,
-			"title": doc.Title,
-			"metadata": doc.Metadata,
-		},
-		ResponseMode: "blocking",
-		User: "cdk-office",
+	req := &client.DocumentTextRequest{
+		Name:        doc.Title,
+		Text:        doc.Content,
+		ProcessRule: &client.ProcessRule{Mode: "automatic"},
+	}
+
+	var resp *client.DocumentResponse
+	var err error
+	if doc.ID == "" {
+		resp, err = s.datasetClient.CreateDocumentByText(ctx, kbID, req)
+	} else {
+		resp, err = s.datasetClient.UpdateDocumentByText(ctx, kbID, doc.ID, req)
 	}
-	
-	// Call the Dify API
-	_, err := s.difyClient.CreateCompletionMessage(ctx, req)
 	if err != nil {
 		logger.Error("failed to update document in knowledge base", "error", err)
+		if errors.Is(err, client.ErrNotFound) {
+			return errors.New("knowledge base document not found")
+		}
 		return errors.New("failed to update document in knowledge base")
 	}
-	
+	doc.ID = resp.Document.ID
+
 	logger.Info("updated document in knowledge base", "kb_id", kbID, "doc_id", doc.ID)
-	
+
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,41 @@
+package rag
+
+import (
+	"context"
+	"testing"
+
+	"cdk-office/internal/dify/client"
+	"cdk-office/internal/dify/client/difytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRAGService(t *testing.T) {
+	ctx := context.Background()
+	dify := difytest.NewServer("dataset-key")
+	defer dify.Close()
+	ragService := NewRAGService(client.NewDatasetClient(dify.URL, "dataset-key"))
+
+	kb, err := ragService.CreateKnowledgeBase(ctx, "policies", &KBConfig{Description: "Company policies"})
+	require.NoError(t, err)
+	assert.Equal(t, "policies", kb.Name)
+	_, err = ragService.CreateKnowledgeBase(ctx, "policies", nil)
+	assert.EqualError(t, err, "knowledge base name already exists")
+
+	doc := &Document{Title: "Travel", Content: "Book economy class for flights under six hours."}
+	require.NoError(t, ragService.UpdateDocument(ctx, kb.ID, doc))
+	require.NotEmpty(t, doc.ID)
+	doc.Content = "Book economy class for flights under eight hours."
+	require.NoError(t, ragService.UpdateDocument(ctx, kb.ID, doc))
+
+	results, err := ragService.Search(ctx, "economy flights", kb.ID)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, doc.ID, results[0].ID)
+	assert.Equal(t, "Travel", results[0].Title)
+	assert.Contains(t, results[0].Content, "eight hours")
+	assert.Equal(t, 1.0, results[0].Metadata["score"])
+
+	_, err = ragService.Search(ctx, "flights", "missing")
+	assert.EqualError(t, err, "knowledge base not found")
+}
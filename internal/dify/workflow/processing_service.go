@@ -0,0 +1,494 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"cdk-office/internal/document/domain"
+	"cdk-office/internal/shared/database"
+	"cdk-office/internal/shared/utils"
+	"cdk-office/pkg/config"
+	"cdk-office/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// ProcessingServiceInterface defines the interface for the document processing job service
+type ProcessingServiceInterface interface {
+	EnqueueDocument(ctx context.Context, documentID string) (*domain.ProcessingJob, error)
+	GetProcessingStatus(ctx context.Context, documentID string) (*ProcessingStatus, error)
+	RetryFailedStages(ctx context.Context, documentID string, stages []string) (*domain.ProcessingJob, error)
+}
+
+// ProcessingStatus represents the processing state of a document
+type ProcessingStatus struct {
+	DocumentID   string                   `json:"document_id"`
+	Job          *domain.ProcessingJob    `json:"job"`
+	CurrentStage string                   `json:"current_stage,omitempty"`
+	Steps        []*domain.ProcessingStep `json:"steps"`
+}
+
+// ProcessingService runs the document pipeline asynchronously through a job queue
+// and a pool of workers, recording the status of every stage
+type ProcessingService struct {
+	db     *gorm.DB
+	queue  JobQueue
+	runner StageRunner
+	config *config.ProcessingConfig
+	wake   chan struct{}
+}
+
+// NewProcessingService creates a new instance of ProcessingService
+func NewProcessingService(runner StageRunner, cfg *config.ProcessingConfig) *ProcessingService {
+	db := database.GetDB()
+	return NewProcessingServiceWithDeps(db, NewJobQueue(db, cfg), runner, cfg)
+}
+
+// NewProcessingServiceWithDeps creates a new instance of ProcessingService with specific dependencies
+func NewProcessingServiceWithDeps(db *gorm.DB, queue JobQueue, runner StageRunner, cfg *config.ProcessingConfig) *ProcessingService {
+	return &ProcessingService{
+		db:     db,
+		queue:  queue,
+		runner: runner,
+		config: cfg,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// EnqueueDocument queues a document for processing. If the document already has
+// an active job, that job is returned instead.
+func (s *ProcessingService) EnqueueDocument(ctx context.Context, documentID string) (*domain.ProcessingJob, error) {
+	var document domain.Document
+	if err := s.db.Where("id = ?", documentID).First(&document).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("document not found")
+		}
+		logger.Error("failed to find document", "error", err)
+		return nil, errors.New("failed to enqueue document")
+	}
+
+	var active domain.ProcessingJob
+	err := s.db.Where("document_id = ? AND status IN ?", documentID,
+		[]string{domain.ProcessingJobPending, domain.ProcessingJobRunning}).First(&active).Error
+	if err == nil {
+		return &active, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Error("failed to find processing job", "error", err)
+		return nil, errors.New("failed to enqueue document")
+	}
+
+	now := time.Now()
+	job := &domain.ProcessingJob{
+		ID:          utils.GenerateProcessingJobID(),
+		DocumentID:  documentID,
+		Status:      domain.ProcessingJobPending,
+		MaxAttempts: s.config.MaxAttempts,
+		NextRunAt:   now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(job).Error; err != nil {
+			return err
+		}
+		steps := make([]*domain.ProcessingStep, len(Stages))
+		for i, stage := range Stages {
+			steps[i] = &domain.ProcessingStep{
+				ID:         utils.GenerateProcessingStepID(),
+				JobID:      job.ID,
+				DocumentID: documentID,
+				Stage:      stage,
+				Position:   i,
+				Status:     domain.ProcessingStepPending,
+				CreatedAt:  now,
+				UpdatedAt:  now,
+			}
+		}
+		return tx.Create(&steps).Error
+	})
+	if err != nil {
+		logger.Error("failed to create processing job", "error", err)
+		return nil, errors.New("failed to enqueue document")
+	}
+
+	s.push(ctx, job)
+
+	return job, nil
+}
+
+// GetProcessingStatus returns the latest processing job of a document and the status of each stage
+func (s *ProcessingService) GetProcessingStatus(ctx context.Context, documentID string) (*ProcessingStatus, error) {
+	job, err := s.latestJob(documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []*domain.ProcessingStep
+	if err := s.db.Where("job_id = ?", job.ID).Order("position").Find(&steps).Error; err != nil {
+		logger.Error("failed to find processing steps", "error", err)
+		return nil, errors.New("failed to get processing status")
+	}
+
+	status := &ProcessingStatus{
+		DocumentID: documentID,
+		Job:        job,
+		Steps:      steps,
+	}
+	for _, step := range steps {
+		if step.Status != domain.ProcessingStepCompleted {
+			status.CurrentStage = step.Stage
+			break
+		}
+	}
+
+	return status, nil
+}
+
+// RetryFailedStages re-runs the failed stages of a document's latest job. When
+// stages are given, those stages and every stage after them are run again.
+func (s *ProcessingService) RetryFailedStages(ctx context.Context, documentID string, stages []string) (*domain.ProcessingJob, error) {
+	from := -1
+	for _, stage := range stages {
+		position := stagePosition(stage)
+		if position < 0 {
+			return nil, errors.New("invalid stage")
+		}
+		if from < 0 || position < from {
+			from = position
+		}
+	}
+
+	job, err := s.latestJob(documentID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == domain.ProcessingJobRunning {
+		return nil, errors.New("processing job is running")
+	}
+
+	reset := s.db.Model(&domain.ProcessingStep{}).Where("job_id = ?", job.ID)
+	if from >= 0 {
+		reset = reset.Where("position >= ?", from)
+	} else {
+		reset = reset.Where("status = ?", domain.ProcessingStepFailed)
+	}
+	result := reset.Updates(map[string]interface{}{
+		"status":      domain.ProcessingStepPending,
+		"error":       "",
+		"finished_at": nil,
+		"updated_at":  time.Now(),
+	})
+	if result.Error != nil {
+		logger.Error("failed to reset processing steps", "error", result.Error)
+		return nil, errors.New("failed to retry processing")
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("no failed stages to retry")
+	}
+
+	now := time.Now()
+	if err := s.db.Model(job).Updates(map[string]interface{}{
+		"status":       domain.ProcessingJobPending,
+		"attempts":     0,
+		"max_attempts": s.config.MaxAttempts,
+		"next_run_at":  now,
+		"last_error":   "",
+		"locked_by":    "",
+		"locked_until": nil,
+		"completed_at": nil,
+		"updated_at":   now,
+	}).Error; err != nil {
+		logger.Error("failed to reset processing job", "error", err)
+		return nil, errors.New("failed to retry processing")
+	}
+	if err := s.db.Where("id = ?", job.ID).First(job).Error; err != nil {
+		logger.Error("failed to reload processing job", "error", err)
+		return nil, errors.New("failed to retry processing")
+	}
+
+	s.push(ctx, job)
+
+	return job, nil
+}
+
+// Start launches the worker pool and the recovery loop. Workers stop when ctx is cancelled.
+func (s *ProcessingService) Start(ctx context.Context) {
+	hostname, _ := os.Hostname()
+	for i := 0; i < s.config.Workers; i++ {
+		workerID := fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), i)
+		go s.work(ctx, workerID)
+	}
+	go s.recover(ctx)
+}
+
+// ProcessNext claims and runs the next due job. It reports whether a job was run.
+func (s *ProcessingService) ProcessNext(ctx context.Context, workerID string) (bool, error) {
+	job, err := s.queue.Pop(ctx, workerID, s.config.LockTimeout)
+	if err != nil {
+		logger.Error("failed to claim processing job", "error", err)
+		return false, errors.New("failed to claim processing job")
+	}
+	if job == nil {
+		return false, nil
+	}
+
+	s.runJob(ctx, job)
+	return true, nil
+}
+
+// RequeueStaleJobs returns jobs whose worker lock expired to the queue and
+// re-pushes pending jobs, which restores a Redis queue that lost its entries
+func (s *ProcessingService) RequeueStaleJobs(ctx context.Context) error {
+	now := time.Now()
+	if err := s.db.Model(&domain.ProcessingJob{}).
+		Where("status = ? AND locked_until < ?", domain.ProcessingJobRunning, now).
+		Updates(map[string]interface{}{
+			"status":       domain.ProcessingJobPending,
+			"locked_by":    "",
+			"locked_until": nil,
+			"next_run_at":  now,
+			"updated_at":   now,
+		}).Error; err != nil {
+		return err
+	}
+
+	var pending []*domain.ProcessingJob
+	if err := s.db.Where("status = ?", domain.ProcessingJobPending).Find(&pending).Error; err != nil {
+		return err
+	}
+	for _, job := range pending {
+		if err := s.queue.Push(ctx, job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// work runs jobs until ctx is cancelled, sleeping while the queue is empty
+func (s *ProcessingService) work(ctx context.Context, workerID string) {
+	for {
+		processed, _ := s.ProcessNext(ctx, workerID)
+		if processed {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-time.After(s.config.PollInterval):
+		}
+	}
+}
+
+// recover periodically requeues jobs abandoned by crashed workers
+func (s *ProcessingService) recover(ctx context.Context) {
+	ticker := time.NewTicker(s.config.LockTimeout / 2)
+	defer ticker.Stop()
+	for {
+		if err := s.RequeueStaleJobs(ctx); err != nil {
+			logger.Error("failed to requeue stale processing jobs", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runJob runs the pending stages of a claimed job in order, resuming from the
+// outputs of stages completed by earlier attempts
+func (s *ProcessingService) runJob(ctx context.Context, job *domain.ProcessingJob) {
+	var document domain.Document
+	if err := s.db.Where("id = ?", job.DocumentID).First(&document).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Retrying cannot help when the document is gone
+			s.deadLetter(job, "document not found")
+			return
+		}
+		s.failJob(ctx, job, fmt.Errorf("failed to load document: %w", err))
+		return
+	}
+
+	var steps []*domain.ProcessingStep
+	if err := s.db.Where("job_id = ?", job.ID).Order("position").Find(&steps).Error; err != nil {
+		s.failJob(ctx, job, fmt.Errorf("failed to load steps: %w", err))
+		return
+	}
+
+	state := &PipelineState{}
+	for _, step := range steps {
+		if step.Status == domain.ProcessingStepCompleted {
+			if err := restoreStageOutput(step.Stage, step.Output, state); err != nil {
+				s.failJob(ctx, job, fmt.Errorf("%s: failed to restore output: %w", step.Stage, err))
+				return
+			}
+			continue
+		}
+
+		startedAt := time.Now()
+		step.Status = domain.ProcessingStepRunning
+		step.Attempts++
+		step.StartedAt = &startedAt
+		step.FinishedAt = nil
+		step.Error = ""
+		s.saveStep(step)
+
+		// Keep the job locked while the stage runs, since stages calling Dify can
+		// outlast the lock timeout
+		stopHeartbeat := s.heartbeat(job)
+		err := s.runner.RunStage(ctx, step.Stage, &document, state)
+		stopHeartbeat()
+		finishedAt := time.Now()
+		step.FinishedAt = &finishedAt
+		if err == nil {
+			step.Output, err = encodeStageOutput(step.Stage, state)
+		}
+		if err != nil {
+			step.Status = domain.ProcessingStepFailed
+			step.Error = err.Error()
+			s.saveStep(step)
+			s.failJob(ctx, job, fmt.Errorf("%s: %w", step.Stage, err))
+			return
+		}
+		step.Status = domain.ProcessingStepCompleted
+		s.saveStep(step)
+	}
+
+	now := time.Now()
+	if err := s.db.Model(job).Updates(map[string]interface{}{
+		"status":       domain.ProcessingJobCompleted,
+		"last_error":   "",
+		"locked_by":    "",
+		"locked_until": nil,
+		"completed_at": now,
+		"updated_at":   now,
+	}).Error; err != nil {
+		logger.Error("failed to complete processing job", "error", err, "job_id", job.ID)
+	}
+}
+
+// heartbeat renews the lock of a job every half lock timeout until the returned
+// function is called, which waits for the last renewal to finish
+func (s *ProcessingService) heartbeat(job *domain.ProcessingJob) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(s.config.LockTimeout / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				s.renewLock(job)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// renewLock extends the lock a worker holds on a job
+func (s *ProcessingService) renewLock(job *domain.ProcessingJob) {
+	if err := s.db.Model(&domain.ProcessingJob{}).Where("id = ? AND locked_by = ?", job.ID, job.LockedBy).
+		Update("locked_until", time.Now().Add(s.config.LockTimeout)).Error; err != nil {
+		logger.Error("failed to renew processing job lock", "error", err, "job_id", job.ID)
+	}
+}
+
+// failJob schedules a retry with exponential backoff, or dead-letters the job
+// once it has used all of its attempts
+func (s *ProcessingService) failJob(ctx context.Context, job *domain.ProcessingJob, cause error) {
+	logger.Error("document processing failed", "error", cause, "job_id", job.ID, "attempt", job.Attempts)
+
+	if job.Attempts >= job.MaxAttempts {
+		s.deadLetter(job, cause.Error())
+		return
+	}
+
+	job.NextRunAt = time.Now().Add(s.backoff(job.Attempts))
+	if err := s.db.Model(job).Updates(map[string]interface{}{
+		"status":       domain.ProcessingJobPending,
+		"next_run_at":  job.NextRunAt,
+		"last_error":   cause.Error(),
+		"locked_by":    "",
+		"locked_until": nil,
+		"updated_at":   time.Now(),
+	}).Error; err != nil {
+		logger.Error("failed to reschedule processing job", "error", err, "job_id", job.ID)
+		return
+	}
+	if err := s.queue.Push(ctx, job); err != nil {
+		logger.Error("failed to push processing job", "error", err, "job_id", job.ID)
+	}
+}
+
+// deadLetter marks a job as dead so it is no longer retried automatically
+func (s *ProcessingService) deadLetter(job *domain.ProcessingJob, reason string) {
+	logger.Warn("document processing job dead-lettered", "job_id", job.ID, "document_id", job.DocumentID, "reason", reason)
+	if err := s.db.Model(job).Updates(map[string]interface{}{
+		"status":       domain.ProcessingJobDead,
+		"last_error":   reason,
+		"locked_by":    "",
+		"locked_until": nil,
+		"updated_at":   time.Now(),
+	}).Error; err != nil {
+		logger.Error("failed to dead-letter processing job", "error", err, "job_id", job.ID)
+	}
+}
+
+// backoff returns the delay before the next attempt: BaseBackoff doubled for each
+// failed attempt, capped at MaxBackoff
+func (s *ProcessingService) backoff(attempt int) time.Duration {
+	delay := s.config.BaseBackoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= s.config.MaxBackoff {
+			return s.config.MaxBackoff
+		}
+	}
+	if delay > s.config.MaxBackoff {
+		return s.config.MaxBackoff
+	}
+	return delay
+}
+
+// saveStep persists a step's status
+func (s *ProcessingService) saveStep(step *domain.ProcessingStep) {
+	step.UpdatedAt = time.Now()
+	if err := s.db.Save(step).Error; err != nil {
+		logger.Error("failed to save processing step", "error", err, "step_id", step.ID)
+	}
+}
+
+// latestJob returns the most recent processing job of a document
+func (s *ProcessingService) latestJob(documentID string) (*domain.ProcessingJob, error) {
+	var job domain.ProcessingJob
+	if err := s.db.Where("document_id = ?", documentID).Order("created_at desc").First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("processing job not found")
+		}
+		logger.Error("failed to find processing job", "error", err)
+		return nil, errors.New("failed to get processing job")
+	}
+	return &job, nil
+}
+
+// push hands a job to the queue and wakes an idle worker
+func (s *ProcessingService) push(ctx context.Context, job *domain.ProcessingJob) {
+	if err := s.queue.Push(ctx, job); err != nil {
+		// The recovery loop pushes pending jobs again
+		logger.Error("failed to push processing job", "error", err, "job_id", job.ID)
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
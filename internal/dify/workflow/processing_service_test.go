@@ -0,0 +1,331 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"cdk-office/internal/document/domain"
+	"cdk-office/internal/shared/testutils"
+	"cdk-office/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// scriptedRunner is a StageRunner that fails stages a set number of times
+type scriptedRunner struct {
+	failures map[string]int
+	calls    map[string]int
+	inputs   map[string]string
+}
+
+func newScriptedRunner(failures map[string]int) *scriptedRunner {
+	return &scriptedRunner{failures: failures, calls: map[string]int{}, inputs: map[string]string{}}
+}
+
+func (r *scriptedRunner) RunStage(ctx context.Context, stage string, document *domain.Document, state *PipelineState) error {
+	r.calls[stage]++
+	r.inputs[stage] = state.Content
+	if r.failures[stage] > 0 {
+		r.failures[stage]--
+		return errors.New(stage + " unavailable")
+	}
+	switch stage {
+	case StageExtract:
+		state.Content = "extracted text"
+	case StageClassify:
+		state.Classification = "report"
+	}
+	return nil
+}
+
+// runnerFunc adapts a function to the StageRunner interface
+type runnerFunc func(ctx context.Context, stage string, document *domain.Document, state *PipelineState) error
+
+func (f runnerFunc) RunStage(ctx context.Context, stage string, document *domain.Document, state *PipelineState) error {
+	return f(ctx, stage, document, state)
+}
+
+// testProcessingConfig returns a processing configuration suitable for tests
+func testProcessingConfig() *config.ProcessingConfig {
+	return &config.ProcessingConfig{
+		Workers:      1,
+		MaxAttempts:  3,
+		BaseBackoff:  time.Minute,
+		MaxBackoff:   10 * time.Minute,
+		PollInterval: 10 * time.Millisecond,
+		LockTimeout:  time.Minute,
+	}
+}
+
+// setupProcessing creates a test database with a document and a processing service
+func setupProcessing(t *testing.T, runner StageRunner) (*gorm.DB, *ProcessingService) {
+	db := testutils.SetupTestDB()
+	require.NoError(t, db.Create(&domain.Document{ID: "doc_proc", Title: "Report", MimeType: "text/plain"}).Error)
+	return db, NewProcessingServiceWithDeps(db, NewDBQueue(db), runner, testProcessingConfig())
+}
+
+// makeDue moves a job's next run time into the past
+func makeDue(db *gorm.DB, jobID string) {
+	db.Model(&domain.ProcessingJob{}).Where("id = ?", jobID).Update("next_run_at", time.Now().Add(-time.Second))
+}
+
+// TestProcessingService_CompletesAllStages tests a successful pipeline run
+func TestProcessingService_CompletesAllStages(t *testing.T) {
+	ctx := context.Background()
+	runner := newScriptedRunner(nil)
+	_, svc := setupProcessing(t, runner)
+
+	job, err := svc.EnqueueDocument(ctx, "doc_proc")
+	require.NoError(t, err)
+
+	// Enqueueing again returns the active job
+	again, err := svc.EnqueueDocument(ctx, "doc_proc")
+	require.NoError(t, err)
+	assert.Equal(t, job.ID, again.ID)
+
+	processed, err := svc.ProcessNext(ctx, "worker-1")
+	require.NoError(t, err)
+	assert.True(t, processed)
+
+	status, err := svc.GetProcessingStatus(ctx, "doc_proc")
+	require.NoError(t, err)
+	assert.Equal(t, domain.ProcessingJobCompleted, status.Job.Status)
+	assert.Equal(t, 1, status.Job.Attempts)
+	assert.NotNil(t, status.Job.CompletedAt)
+	assert.Empty(t, status.CurrentStage)
+	require.Len(t, status.Steps, len(Stages))
+	for i, step := range status.Steps {
+		assert.Equal(t, Stages[i], step.Stage)
+		assert.Equal(t, domain.ProcessingStepCompleted, step.Status)
+	}
+
+	processed, err = svc.ProcessNext(ctx, "worker-1")
+	require.NoError(t, err)
+	assert.False(t, processed)
+
+	_, err = svc.EnqueueDocument(ctx, "missing")
+	assert.EqualError(t, err, "document not found")
+}
+
+// TestProcessingService_RetriesWithBackoffAndResumes tests that a failed stage is
+// retried later without re-running the stages before it
+func TestProcessingService_RetriesWithBackoffAndResumes(t *testing.T) {
+	ctx := context.Background()
+	runner := newScriptedRunner(map[string]int{StageClassify: 1})
+	db, svc := setupProcessing(t, runner)
+
+	job, err := svc.EnqueueDocument(ctx, "doc_proc")
+	require.NoError(t, err)
+	_, err = svc.ProcessNext(ctx, "worker-1")
+	require.NoError(t, err)
+
+	status, err := svc.GetProcessingStatus(ctx, "doc_proc")
+	require.NoError(t, err)
+	assert.Equal(t, domain.ProcessingJobPending, status.Job.Status)
+	assert.Equal(t, StageClassify, status.CurrentStage)
+	assert.Contains(t, status.Job.LastError, "classify unavailable")
+	assert.WithinDuration(t, time.Now().Add(time.Minute), status.Job.NextRunAt, 5*time.Second)
+	assert.Equal(t, domain.ProcessingStepCompleted, status.Steps[0].Status)
+	assert.Equal(t, domain.ProcessingStepFailed, status.Steps[1].Status)
+	assert.Equal(t, "classify unavailable", status.Steps[1].Error)
+
+	// The retry is not due yet
+	processed, err := svc.ProcessNext(ctx, "worker-1")
+	require.NoError(t, err)
+	assert.False(t, processed)
+
+	makeDue(db, job.ID)
+	processed, err = svc.ProcessNext(ctx, "worker-1")
+	require.NoError(t, err)
+	assert.True(t, processed)
+
+	status, err = svc.GetProcessingStatus(ctx, "doc_proc")
+	require.NoError(t, err)
+	assert.Equal(t, domain.ProcessingJobCompleted, status.Job.Status)
+	assert.Equal(t, 2, status.Job.Attempts)
+	assert.Equal(t, 1, runner.calls[StageExtract])
+	assert.Equal(t, 2, runner.calls[StageClassify])
+	assert.Equal(t, "extracted text", runner.inputs[StageClassify], "content is restored from the extract stage output")
+	assert.Equal(t, 2, status.Steps[1].Attempts)
+}
+
+// TestProcessingService_DeadLettersAndRetriesFailedStages tests dead-lettering
+// after the last attempt and manually re-running failed stages
+func TestProcessingService_DeadLettersAndRetriesFailedStages(t *testing.T) {
+	ctx := context.Background()
+	runner := newScriptedRunner(map[string]int{StageKnowledgeBase: 3})
+	db, svc := setupProcessing(t, runner)
+
+	job, err := svc.EnqueueDocument(ctx, "doc_proc")
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		makeDue(db, job.ID)
+		processed, err := svc.ProcessNext(ctx, "worker-1")
+		require.NoError(t, err)
+		assert.True(t, processed)
+	}
+
+	status, err := svc.GetProcessingStatus(ctx, "doc_proc")
+	require.NoError(t, err)
+	assert.Equal(t, domain.ProcessingJobDead, status.Job.Status)
+	assert.Equal(t, StageKnowledgeBase, status.CurrentStage)
+
+	// Dead jobs are not picked up again
+	makeDue(db, job.ID)
+	processed, err := svc.ProcessNext(ctx, "worker-1")
+	require.NoError(t, err)
+	assert.False(t, processed)
+
+	_, err = svc.RetryFailedStages(ctx, "doc_proc", []string{"unknown"})
+	assert.EqualError(t, err, "invalid stage")
+
+	retried, err := svc.RetryFailedStages(ctx, "doc_proc", nil)
+	require.NoError(t, err)
+	assert.Equal(t, domain.ProcessingJobPending, retried.Status)
+	assert.Equal(t, 0, retried.Attempts)
+
+	processed, err = svc.ProcessNext(ctx, "worker-1")
+	require.NoError(t, err)
+	assert.True(t, processed)
+	status, err = svc.GetProcessingStatus(ctx, "doc_proc")
+	require.NoError(t, err)
+	assert.Equal(t, domain.ProcessingJobCompleted, status.Job.Status)
+	assert.Equal(t, 1, runner.calls[StageSummarize])
+
+	_, err = svc.RetryFailedStages(ctx, "doc_proc", nil)
+	assert.EqualError(t, err, "no failed stages to retry")
+
+	// Re-running a stage also re-runs the stages after it
+	_, err = svc.RetryFailedStages(ctx, "doc_proc", []string{StageSummarize})
+	require.NoError(t, err)
+	_, err = svc.ProcessNext(ctx, "worker-1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, runner.calls[StageClassify])
+	assert.Equal(t, 2, runner.calls[StageSummarize])
+	assert.Equal(t, 2, runner.calls[StageUpdate])
+	assert.Equal(t, 2, runner.calls[StageNotify])
+
+	_, err = svc.GetProcessingStatus(ctx, "missing")
+	assert.EqualError(t, err, "processing job not found")
+}
+
+// TestProcessingService_RequeueStaleJobs tests recovery of jobs held by a crashed worker
+func TestProcessingService_RequeueStaleJobs(t *testing.T) {
+	ctx := context.Background()
+	db, svc := setupProcessing(t, newScriptedRunner(nil))
+
+	job, err := svc.EnqueueDocument(ctx, "doc_proc")
+	require.NoError(t, err)
+	claimed, err := NewDBQueue(db).Pop(ctx, "crashed-worker", -time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+
+	// Another worker cannot claim a running job
+	other, err := NewDBQueue(db).Pop(ctx, "worker-2", time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, other)
+
+	require.NoError(t, svc.RequeueStaleJobs(ctx))
+	processed, err := svc.ProcessNext(ctx, "worker-2")
+	require.NoError(t, err)
+	assert.True(t, processed)
+
+	var stored domain.ProcessingJob
+	require.NoError(t, db.Where("id = ?", job.ID).First(&stored).Error)
+	assert.Equal(t, domain.ProcessingJobCompleted, stored.Status)
+}
+
+// TestProcessingService_HeartbeatKeepsLongStagesLocked tests that a job whose
+// stage outlasts the lock timeout is not requeued as stale while it runs
+func TestProcessingService_HeartbeatKeepsLongStagesLocked(t *testing.T) {
+	ctx := context.Background()
+	var db *gorm.DB
+	var svc *ProcessingService
+	statusDuringStage := ""
+	runner := runnerFunc(func(ctx context.Context, stage string, document *domain.Document, state *PipelineState) error {
+		if stage != StageExtract {
+			return nil
+		}
+		time.Sleep(3 * svc.config.LockTimeout)
+		if err := svc.RequeueStaleJobs(ctx); err != nil {
+			return err
+		}
+		var job domain.ProcessingJob
+		if err := db.Where("document_id = ?", document.ID).First(&job).Error; err != nil {
+			return err
+		}
+		statusDuringStage = job.Status
+		return nil
+	})
+	db, svc = setupProcessing(t, runner)
+	svc.config.LockTimeout = 100 * time.Millisecond
+	// The heartbeat renews the lock concurrently, and every connection to an
+	// in-memory database opens a new, empty database
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
+	_, err = svc.EnqueueDocument(ctx, "doc_proc")
+	require.NoError(t, err)
+	processed, err := svc.ProcessNext(ctx, "worker-1")
+	require.NoError(t, err)
+	assert.True(t, processed)
+	assert.Equal(t, domain.ProcessingJobRunning, statusDuringStage)
+
+	status, err := svc.GetProcessingStatus(ctx, "doc_proc")
+	require.NoError(t, err)
+	assert.Equal(t, domain.ProcessingJobCompleted, status.Job.Status)
+	assert.Equal(t, 1, status.Job.Attempts)
+}
+
+// TestProcessingService_Backoff tests the exponential backoff schedule
+func TestProcessingService_Backoff(t *testing.T) {
+	svc := NewProcessingServiceWithDeps(nil, nil, nil, testProcessingConfig())
+	assert.Equal(t, time.Minute, svc.backoff(1))
+	assert.Equal(t, 2*time.Minute, svc.backoff(2))
+	assert.Equal(t, 8*time.Minute, svc.backoff(4))
+	assert.Equal(t, 10*time.Minute, svc.backoff(5))
+	assert.Equal(t, 10*time.Minute, svc.backoff(50))
+}
+
+// TestProcessingService_WithDocumentWorkflow tests the worker pool running the real workflow stages
+func TestProcessingService_WithDocumentWorkflow(t *testing.T) {
+	db := testutils.SetupTestDB()
+	require.NoError(t, db.Create(&domain.Document{ID: "doc_flow", Title: "Plan", MimeType: "text/plain"}).Error)
+
+	mockContentExtractor := new(MockContentExtractor)
+	mockClassifier := new(MockClassifier)
+	mockTagExtractor := new(MockTagExtractor)
+	mockSummarizer := new(MockSummarizer)
+	mockKnowledgeBase := new(MockKnowledgeBase)
+	docWorkflow := NewDocumentWorkflow(new(MockDifyClient), new(MockDocumentService), mockContentExtractor,
+		new(MockOCRExtractor), mockClassifier, mockTagExtractor, mockSummarizer, mockKnowledgeBase)
+
+	mockContentExtractor.On("ExtractContent", mock.Anything).Return("plan content", nil)
+	mockClassifier.On("ClassifyDocument", mock.Anything, "plan content", mock.Anything).Return("planning", nil)
+	mockTagExtractor.On("ExtractTags", mock.Anything, "plan content", mock.Anything).Return([]string{"plan"}, nil)
+	mockSummarizer.On("SummarizeDocument", mock.Anything, "plan content", mock.Anything).Return("A plan", nil)
+	mockKnowledgeBase.On("AddToKnowledgeBase", mock.Anything, mock.Anything, "plan content").Return(nil)
+
+	svc := NewProcessingServiceWithDeps(db, NewDBQueue(db), docWorkflow, testProcessingConfig())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	svc.Start(ctx)
+
+	_, err := svc.EnqueueDocument(ctx, "doc_flow")
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		status, err := svc.GetProcessingStatus(ctx, "doc_flow")
+		return err == nil && status.Job.Status == domain.ProcessingJobCompleted
+	}, 5*time.Second, 20*time.Millisecond)
+
+	var document domain.Document
+	require.NoError(t, db.Where("id = ?", "doc_flow").First(&document).Error)
+	assert.Equal(t, "A plan [Category: planning]", document.Description)
+	assert.Equal(t, `["plan"]`, document.Tags)
+}
@@ -0,0 +1,151 @@
+package workflow
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"cdk-office/internal/document/domain"
+	"cdk-office/internal/shared/cache"
+	"cdk-office/pkg/config"
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// redisQueueKey is the sorted set holding processing job IDs scored by their run time
+const redisQueueKey = "document_processing:queue"
+
+// popBatchSize is the number of due jobs considered per claim attempt
+const popBatchSize = 10
+
+// JobQueue dispatches processing jobs to workers. Job state is always stored in
+// the database; a queue only decides which due job a worker claims next.
+type JobQueue interface {
+	Push(ctx context.Context, job *domain.ProcessingJob) error
+	Pop(ctx context.Context, workerID string, lockTimeout time.Duration) (*domain.ProcessingJob, error)
+}
+
+// NewJobQueue returns the queue selected by the configuration. The Redis queue
+// is used only when requested and a Redis client is available.
+func NewJobQueue(db *gorm.DB, cfg *config.ProcessingConfig) JobQueue {
+	if cfg.QueueDriver == "redis" {
+		if client := cache.GetRedisClient(); client != nil {
+			return NewRedisQueue(db, client)
+		}
+	}
+	return NewDBQueue(db)
+}
+
+// DBQueue implements the JobQueue by polling the processing_jobs table
+type DBQueue struct {
+	db *gorm.DB
+}
+
+// NewDBQueue creates a new instance of DBQueue
+func NewDBQueue(db *gorm.DB) *DBQueue {
+	return &DBQueue{db: db}
+}
+
+// Push is a no-op since pending jobs are read directly from the database
+func (q *DBQueue) Push(ctx context.Context, job *domain.ProcessingJob) error {
+	return nil
+}
+
+// Pop claims the next due job, or returns nil if none is due
+func (q *DBQueue) Pop(ctx context.Context, workerID string, lockTimeout time.Duration) (*domain.ProcessingJob, error) {
+	var candidates []*domain.ProcessingJob
+	if err := q.db.WithContext(ctx).Select("id").
+		Where("status = ? AND next_run_at <= ?", domain.ProcessingJobPending, time.Now()).
+		Order("next_run_at").Limit(popBatchSize).Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range candidates {
+		job, err := claimJob(ctx, q.db, candidate.ID, workerID, lockTimeout)
+		if err != nil {
+			return nil, err
+		}
+		if job != nil {
+			return job, nil
+		}
+	}
+	return nil, nil
+}
+
+// RedisQueue implements the JobQueue with a Redis sorted set scored by run time
+type RedisQueue struct {
+	db     *gorm.DB
+	client *redis.Client
+}
+
+// NewRedisQueue creates a new instance of RedisQueue
+func NewRedisQueue(db *gorm.DB, client *redis.Client) *RedisQueue {
+	return &RedisQueue{db: db, client: client}
+}
+
+// Push schedules a job to run at its next run time
+func (q *RedisQueue) Push(ctx context.Context, job *domain.ProcessingJob) error {
+	return q.client.ZAdd(ctx, redisQueueKey, &redis.Z{
+		Score:  float64(job.NextRunAt.UnixMilli()),
+		Member: job.ID,
+	}).Err()
+}
+
+// Pop claims the next due job, or returns nil if none is due
+func (q *RedisQueue) Pop(ctx context.Context, workerID string, lockTimeout time.Duration) (*domain.ProcessingJob, error) {
+	ids, err := q.client.ZRangeByScore(ctx, redisQueueKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(time.Now().UnixMilli(), 10),
+		Count: popBatchSize,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		// Only the worker that removes the entry may claim the job
+		removed, err := q.client.ZRem(ctx, redisQueueKey, id).Result()
+		if err != nil {
+			return nil, err
+		}
+		if removed == 0 {
+			continue
+		}
+		job, err := claimJob(ctx, q.db, id, workerID, lockTimeout)
+		if err != nil {
+			return nil, err
+		}
+		if job != nil {
+			return job, nil
+		}
+	}
+	return nil, nil
+}
+
+// claimJob marks a pending job as running for a worker. It returns nil if another
+// worker claimed the job first.
+func claimJob(ctx context.Context, db *gorm.DB, jobID, workerID string, lockTimeout time.Duration) (*domain.ProcessingJob, error) {
+	now := time.Now()
+	lockedUntil := now.Add(lockTimeout)
+	result := db.WithContext(ctx).Model(&domain.ProcessingJob{}).
+		Where("id = ? AND status = ?", jobID, domain.ProcessingJobPending).
+		Updates(map[string]interface{}{
+			"status":       domain.ProcessingJobRunning,
+			"attempts":     gorm.Expr("attempts + 1"),
+			"locked_by":    workerID,
+			"locked_until": lockedUntil,
+			"updated_at":   now,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	var job domain.ProcessingJob
+	if err := db.WithContext(ctx).Where("id = ?", jobID).First(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
@@ -0,0 +1,129 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"cdk-office/internal/document/domain"
+)
+
+// Pipeline stages in execution order
+const (
+	StageExtract       = "extract"
+	StageClassify      = "classify"
+	StageTag           = "tag"
+	StageSummarize     = "summarize"
+	StageUpdate        = "update"
+	StageKnowledgeBase = "knowledge_base"
+	StageNotify        = "notify"
+)
+
+// Stages lists the pipeline stages in the order they run
+var Stages = []string{
+	StageExtract,
+	StageClassify,
+	StageTag,
+	StageSummarize,
+	StageUpdate,
+	StageKnowledgeBase,
+	StageNotify,
+}
+
+// StageRunner runs a single stage of the document processing pipeline
+type StageRunner interface {
+	RunStage(ctx context.Context, stage string, document *domain.Document, state *PipelineState) error
+}
+
+// PipelineState carries the results of completed stages to the stages after them
+type PipelineState struct {
+	Content        string   `json:"content,omitempty"`
+	Classification string   `json:"classification,omitempty"`
+	Tags           []string `json:"tags,omitempty"`
+	Summary        string   `json:"summary,omitempty"`
+}
+
+// RunStage runs a single stage of the pipeline, reading its inputs from and
+// writing its result to the pipeline state
+func (w *DocumentWorkflow) RunStage(ctx context.Context, stage string, document *domain.Document, state *PipelineState) error {
+	var err error
+	switch stage {
+	case StageExtract:
+		state.Content, err = w.extractContent(document)
+	case StageClassify:
+		state.Classification, err = w.classifier.ClassifyDocument(ctx, state.Content, document)
+	case StageTag:
+		state.Tags, err = w.tagExtractor.ExtractTags(ctx, state.Content, document)
+	case StageSummarize:
+		state.Summary, err = w.summarizer.SummarizeDocument(ctx, state.Content, document)
+	case StageUpdate:
+		err = w.updateDocumentWithAIResults(ctx, document, &AIResult{
+			Classification: state.Classification,
+			Tags:           state.Tags,
+			Summary:        state.Summary,
+		})
+	case StageKnowledgeBase:
+		err = w.addToKnowledgeBase(ctx, document, state.Content)
+	case StageNotify:
+		err = w.notifyUsers(ctx, document)
+	default:
+		err = errors.New("invalid stage")
+	}
+	return err
+}
+
+// stagePosition returns the position of a stage in the pipeline, or -1 if unknown
+func stagePosition(stage string) int {
+	for i, s := range Stages {
+		if s == stage {
+			return i
+		}
+	}
+	return -1
+}
+
+// encodeStageOutput returns the part of the pipeline state produced by a stage
+func encodeStageOutput(stage string, state *PipelineState) (string, error) {
+	var output PipelineState
+	switch stage {
+	case StageExtract:
+		output.Content = state.Content
+	case StageClassify:
+		output.Classification = state.Classification
+	case StageTag:
+		output.Tags = state.Tags
+	case StageSummarize:
+		output.Summary = state.Summary
+	default:
+		return "", nil
+	}
+
+	data, err := json.Marshal(&output)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// restoreStageOutput merges the stored output of a completed stage into the pipeline state
+func restoreStageOutput(stage, output string, state *PipelineState) error {
+	if output == "" {
+		return nil
+	}
+
+	var stored PipelineState
+	if err := json.Unmarshal([]byte(output), &stored); err != nil {
+		return err
+	}
+	switch stage {
+	case StageExtract:
+		state.Content = stored.Content
+	case StageClassify:
+		state.Classification = stored.Classification
+	case StageTag:
+		state.Tags = stored.Tags
+	case StageSummarize:
+		state.Summary = stored.Summary
+	}
+	return nil
+}
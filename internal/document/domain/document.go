@@ -9,8 +9,9 @@ type Document struct {
 	ID          string    `json:"id" gorm:"primaryKey"`
 	Title       string    `json:"title" gorm:"size:200"`
 	Description string    `json:"description" gorm:"type:text"`
-	FilePath    string    `json:"file_path" gorm:"size:500"`
+	FilePath    string    `json:"file_path" gorm:"size:500"` // storage key of the current content
 	FileSize    int64     `json:"file_size"`
+	ContentHash string    `json:"content_hash" gorm:"size:64;index"`
 	MimeType    string    `json:"mime_type" gorm:"size:100"`
 	OwnerID     string    `json:"owner_id" gorm:"index"`
 	TeamID      string    `json:"team_id" gorm:"index"`
@@ -22,10 +23,11 @@ type Document struct {
 
 // DocumentVersion represents a version of a document
 type DocumentVersion struct {
-	ID         string    `json:"id" gorm:"primaryKey"`
-	DocumentID string    `json:"document_id" gorm:"index"`
-	Version    int       `json:"version"`
-	FilePath   string    `json:"file_path" gorm:"size:500"`
-	FileSize   int64     `json:"file_size"`
-	CreatedAt  time.Time `json:"created_at"`
+	ID          string    `json:"id" gorm:"primaryKey"`
+	DocumentID  string    `json:"document_id" gorm:"index"`
+	Version     int       `json:"version"`
+	FilePath    string    `json:"file_path" gorm:"size:500"` // storage key of the version content
+	FileSize    int64     `json:"file_size"`
+	ContentHash string    `json:"content_hash" gorm:"size:64;index"`
+	CreatedAt   time.Time `json:"created_at"`
 }
\ No newline at end of file
@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"time"
+)
+
+// Knowledge document sources
+const (
+	KnowledgeSourceText = "text" // extracted text sent to Dify
+	KnowledgeSourceFile = "file" // file sent to Dify to extract
+)
+
+// KnowledgeDataset maps a team to the Dify dataset its documents are mirrored into
+type KnowledgeDataset struct {
+	TeamID    string    `json:"team_id" gorm:"primaryKey"`
+	DatasetID string    `json:"dataset_id" gorm:"size:100"`
+	Name      string    `json:"name" gorm:"size:100"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// KnowledgeDocument records the Dify document mirroring a document
+type KnowledgeDocument struct {
+	DocumentID     string    `json:"document_id" gorm:"primaryKey"`
+	TeamID         string    `json:"team_id" gorm:"index"`
+	DatasetID      string    `json:"dataset_id" gorm:"size:100"`
+	DifyDocumentID string    `json:"dify_document_id" gorm:"size:100;index"`
+	VersionID      string    `json:"version_id"`                  // latest version when synced
+	ContentHash    string    `json:"content_hash" gorm:"size:64"` // content of the document when synced
+	Source         string    `json:"source" gorm:"size:20"`
+	Fingerprint    string    `json:"-" gorm:"size:64"` // hash of what was sent, to skip unchanged syncs
+	Batch          string    `json:"batch" gorm:"size:100"`
+	IndexingStatus string    `json:"indexing_status" gorm:"size:20"`
+	Error          string    `json:"error" gorm:"type:text"`
+	SyncedAt       time.Time `json:"synced_at"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"time"
+)
+
+// Processing job statuses
+const (
+	ProcessingJobPending   = "pending"
+	ProcessingJobRunning   = "running"
+	ProcessingJobCompleted = "completed"
+	ProcessingJobDead      = "dead" // exhausted its retries and was dead-lettered
+)
+
+// Processing step statuses
+const (
+	ProcessingStepPending   = "pending"
+	ProcessingStepRunning   = "running"
+	ProcessingStepCompleted = "completed"
+	ProcessingStepFailed    = "failed"
+)
+
+// ProcessingJob represents a queued run of the AI processing pipeline for a document
+type ProcessingJob struct {
+	ID          string     `json:"id" gorm:"primaryKey"`
+	DocumentID  string     `json:"document_id" gorm:"index"`
+	Status      string     `json:"status" gorm:"size:20;index"`
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"max_attempts"`
+	NextRunAt   time.Time  `json:"next_run_at" gorm:"index"`
+	LockedBy    string     `json:"locked_by" gorm:"size:100"`
+	LockedUntil *time.Time `json:"locked_until"`
+	LastError   string     `json:"last_error" gorm:"type:text"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// ProcessingStep represents the status of one pipeline stage within a processing job
+type ProcessingStep struct {
+	ID         string     `json:"id" gorm:"primaryKey"`
+	JobID      string     `json:"job_id" gorm:"uniqueIndex:idx_processing_step"`
+	DocumentID string     `json:"document_id" gorm:"index"`
+	Stage      string     `json:"stage" gorm:"size:50;uniqueIndex:idx_processing_step"`
+	Position   int        `json:"position"`
+	Status     string     `json:"status" gorm:"size:20"`
+	Attempts   int        `json:"attempts"`
+	Output     string     `json:"-" gorm:"type:text"` // stage result reused when later stages are retried
+	Error      string     `json:"error" gorm:"type:text"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"time"
+)
+
+// SearchIndexEntry stores the text indexed for a document
+type SearchIndexEntry struct {
+	DocumentID  string    `json:"document_id" gorm:"primaryKey"`
+	Title       string    `json:"title" gorm:"size:200"`
+	Description string    `json:"description" gorm:"type:text"`
+	Tags        string    `json:"tags" gorm:"type:text"`
+	Body        string    `json:"body" gorm:"type:text"`       // text extracted from the document content
+	ContentHash string    `json:"content_hash" gorm:"size:64"` // content the body was extracted from
+	Length      int       `json:"length"`                      // weighted token count used for BM25 length normalisation
+	IndexedAt   time.Time `json:"indexed_at"`
+}
+
+// SearchPosting represents a term occurrence in the embedded inverted index
+type SearchPosting struct {
+	Term       string `json:"term" gorm:"primaryKey;size:100"`
+	DocumentID string `json:"document_id" gorm:"primaryKey;index"`
+	Frequency  int    `json:"frequency"` // field-weighted term frequency
+}
@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"time"
+)
+
+// UploadSession represents a resumable chunked upload of a document file
+type UploadSession struct {
+	ID          string    `json:"id" gorm:"primaryKey"`
+	OwnerID     string    `json:"owner_id" gorm:"index"`
+	TeamID      string    `json:"team_id" gorm:"index"`
+	Title       string    `json:"title" gorm:"size:200"`
+	Description string    `json:"description" gorm:"type:text"`
+	Tags        string    `json:"tags" gorm:"type:jsonb"`
+	FileName    string    `json:"file_name" gorm:"size:255"`
+	MimeType    string    `json:"mime_type" gorm:"size:100"`
+	TotalSize   int64     `json:"total_size"`
+	ChunkSize   int64     `json:"chunk_size"`
+	TotalChunks int       `json:"total_chunks"`
+	FileHash    string    `json:"file_hash" gorm:"size:64"` // optional SHA-256 of the whole file
+	Status      string    `json:"status" gorm:"size:20"`    // uploading, completed
+	DocumentID  string    `json:"document_id" gorm:"size:50"`
+	ExpiresAt   time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// UploadChunk represents a received chunk of an upload session
+type UploadChunk struct {
+	ID         string    `json:"id" gorm:"primaryKey"`
+	SessionID  string    `json:"session_id" gorm:"uniqueIndex:idx_upload_chunk"`
+	ChunkIndex int       `json:"chunk_index" gorm:"uniqueIndex:idx_upload_chunk"`
+	Size       int64     `json:"size"`
+	Checksum   string    `json:"checksum" gorm:"size:64"` // SHA-256 of the chunk content
+	StorageKey string    `json:"storage_key" gorm:"size:500"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"time"
+)
+
+// DocumentChunk stores a passage of a document and its embedding for semantic search
+type DocumentChunk struct {
+	ID         string    `json:"id" gorm:"primaryKey"`
+	DocumentID string    `json:"document_id" gorm:"index"`
+	TeamID     string    `json:"team_id" gorm:"index"`
+	Position   int       `json:"position"` // order of the chunk in the document
+	Content    string    `json:"content" gorm:"type:text"`
+	Embedding  []byte    `json:"-"` // little-endian float32 vector
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// DocumentEmbedding records how the chunks of a document were embedded
+type DocumentEmbedding struct {
+	DocumentID  string    `json:"document_id" gorm:"primaryKey"`
+	TeamID      string    `json:"team_id" gorm:"index"`
+	Model       string    `json:"model" gorm:"size:100"`
+	Fingerprint string    `json:"-" gorm:"size:64"` // hash of the embedded text and model, to skip unchanged documents
+	Chunks      int       `json:"chunks"`
+	Centroid    []byte    `json:"-"` // normalised mean of the chunk vectors, compared to find near-duplicates
+	EmbeddedAt  time.Time `json:"embedded_at"`
+}
+
+// DocumentDuplicate records that a document is a near-duplicate of another
+// document of its team. Pairs are stored in both directions.
+type DocumentDuplicate struct {
+	DocumentID  string    `json:"document_id" gorm:"primaryKey"`
+	DuplicateID string    `json:"duplicate_id" gorm:"primaryKey;index"`
+	Similarity  float64   `json:"similarity"`
+	DetectedAt  time.Time `json:"detected_at"`
+}
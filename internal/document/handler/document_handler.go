@@ -1,9 +1,14 @@
 package handler
 
 import (
+	"mime"
 	"net/http"
+	"path/filepath"
 
+	"cdk-office/internal/dify/workflow"
 	"cdk-office/internal/document/service"
+	"cdk-office/pkg/config"
+	"cdk-office/pkg/logger"
 	"github.com/gin-gonic/gin"
 )
 
@@ -18,23 +23,53 @@ type DocumentHandlerInterface interface {
 
 // DocumentHandler implements the DocumentHandlerInterface
 type DocumentHandler struct {
-	documentService service.DocumentServiceInterface
+	documentService   service.DocumentServiceInterface
+	storageService    service.StorageServiceInterface
+	processingService workflow.ProcessingServiceInterface
+	access            service.DocumentAccessInterface
 }
 
 // NewDocumentHandler creates a new instance of DocumentHandler
-func NewDocumentHandler() *DocumentHandler {
+func NewDocumentHandler(cfg *config.Config) (*DocumentHandler, error) {
+	documentService, err := service.NewDocumentService(cfg)
+	if err != nil {
+		return nil, err
+	}
+	storageService, err := service.NewStorageService(&cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	return NewDocumentHandlerWithServices(documentService, storageService, service.NewDocumentAccess()), nil
+}
+
+// NewDocumentHandlerWithService creates a new instance of DocumentHandler with a specific service and access checks
+func NewDocumentHandlerWithService(documentService service.DocumentServiceInterface, access service.DocumentAccessInterface) *DocumentHandler {
 	return &DocumentHandler{
-		documentService: service.NewDocumentService(),
+		documentService: documentService,
+		access:          access,
 	}
 }
 
-// NewDocumentHandlerWithService creates a new instance of DocumentHandler with a specific service
-func NewDocumentHandlerWithService(documentService service.DocumentServiceInterface) *DocumentHandler {
+// NewDocumentHandlerWithServices creates a new instance of DocumentHandler with specific document and storage services and access checks
+func NewDocumentHandlerWithServices(documentService service.DocumentServiceInterface, storageService service.StorageServiceInterface, access service.DocumentAccessInterface) *DocumentHandler {
 	return &DocumentHandler{
 		documentService: documentService,
+		storageService:  storageService,
+		access:          access,
 	}
 }
 
+// NewDocumentHandlerWithProcessing creates a new instance of DocumentHandler that
+// queues uploaded documents for AI processing
+func NewDocumentHandlerWithProcessing(processingService workflow.ProcessingServiceInterface, cfg *config.Config) (*DocumentHandler, error) {
+	h, err := NewDocumentHandler(cfg)
+	if err != nil {
+		return nil, err
+	}
+	h.processingService = processingService
+	return h, nil
+}
+
 // UploadRequest represents the request for uploading a document
 type UploadRequest struct {
 	Title       string `json:"title" binding:"required"`
@@ -42,7 +77,6 @@ type UploadRequest struct {
 	FilePath    string `json:"file_path" binding:"required"`
 	FileSize    int64  `json:"file_size" binding:"required"`
 	MimeType    string `json:"mime_type" binding:"required"`
-	OwnerID     string `json:"owner_id" binding:"required"`
 	TeamID      string `json:"team_id" binding:"required"`
 	Tags        string `json:"tags"`
 }
@@ -55,13 +89,32 @@ type UpdateRequest struct {
 	Tags        string `json:"tags"`
 }
 
-// Upload handles uploading a new document
+// UploadFileRequest represents the multipart form for uploading a document file
+type UploadFileRequest struct {
+	Title       string `form:"title" binding:"required"`
+	Description string `form:"description"`
+	TeamID      string `form:"team_id" binding:"required"`
+	Tags        string `form:"tags"`
+}
+
+// Upload handles uploading a new document owned by the authenticated user, who
+// must be a member of its team. A multipart request carries the file itself,
+// which is saved through the storage service; a JSON request refers to an
+// already stored file by its storage key.
 func (h *DocumentHandler) Upload(c *gin.Context) {
+	if c.ContentType() == "multipart/form-data" {
+		h.uploadFile(c)
+		return
+	}
+
 	var req UploadRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !checkTeamMember(c, h.access, req.TeamID) {
+		return
+	}
 
 	// Call service to upload document
 	document, err := h.documentService.Upload(c.Request.Context(), &service.UploadRequest{
@@ -70,7 +123,7 @@ func (h *DocumentHandler) Upload(c *gin.Context) {
 		FilePath:    req.FilePath,
 		FileSize:    req.FileSize,
 		MimeType:    req.MimeType,
-		OwnerID:     req.OwnerID,
+		OwnerID:     c.GetString("user_id"),
 		TeamID:      req.TeamID,
 		Tags:        req.Tags,
 	})
@@ -79,6 +132,8 @@ func (h *DocumentHandler) Upload(c *gin.Context) {
 		return
 	}
 
+	h.enqueueProcessing(c, document.ID)
+
 	c.JSON(http.StatusOK, document)
 }
 
@@ -177,4 +232,100 @@ func (h *DocumentHandler) GetDocumentVersions(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, versions)
-}
\ No newline at end of file
+}
+
+// uploadFile handles a multipart document upload
+func (h *DocumentHandler) uploadFile(c *gin.Context) {
+	if h.storageService == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "file upload is not available"})
+		return
+	}
+
+	var req UploadFileRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !checkTeamMember(c, h.access, req.TeamID) {
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	// Save file content to storage
+	stored, err := h.storageService.SaveFile(c.Request.Context(), file)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Call service to upload document
+	document, err := h.documentService.Upload(c.Request.Context(), &service.UploadRequest{
+		Title:       req.Title,
+		Description: req.Description,
+		FilePath:    stored.Key,
+		FileSize:    stored.Size,
+		MimeType:    detectMimeType(header.Header.Get("Content-Type"), header.Filename),
+		OwnerID:     c.GetString("user_id"),
+		TeamID:      req.TeamID,
+		Tags:        req.Tags,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.enqueueProcessing(c, document.ID)
+
+	c.JSON(http.StatusOK, document)
+}
+
+// checkTeamMember responds with an error unless the authenticated user is a
+// member of the team, or an admin, and reports whether they are. Documents are
+// readable by the whole team, so only its members may add them.
+func checkTeamMember(c *gin.Context, access service.DocumentAccessInterface, teamID string) bool {
+	allowed, err := access.CanReadTeam(c.Request.Context(), c.GetString("user_id"), c.GetString("role"), teamID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "user is not a member of the team"})
+		return false
+	}
+	return true
+}
+
+// enqueueProcessing queues an uploaded document for AI processing. A failure to
+// enqueue does not fail the upload; processing can be started again later.
+func (h *DocumentHandler) enqueueProcessing(c *gin.Context, documentID string) {
+	if h.processingService == nil {
+		return
+	}
+	if _, err := h.processingService.EnqueueDocument(c.Request.Context(), documentID); err != nil {
+		logger.Error("failed to enqueue document processing", "error", err, "document_id", documentID)
+	}
+}
+
+// detectMimeType returns the MIME type of an uploaded file, falling back to its extension
+func detectMimeType(contentType, fileName string) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType != "application/octet-stream" {
+		return mediaType
+	}
+	if mimeType := mime.TypeByExtension(filepath.Ext(fileName)); mimeType != "" {
+		mediaType, _, _ := mime.ParseMediaType(mimeType)
+		return mediaType
+	}
+	return "application/octet-stream"
+}
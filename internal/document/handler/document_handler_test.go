@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -12,9 +13,13 @@ import (
 
 	"cdk-office/internal/document/domain"
 	"cdk-office/internal/document/service"
+	"cdk-office/internal/document/storage"
+	employeedomain "cdk-office/internal/employee/domain"
+	"cdk-office/internal/shared/testutils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // mockDocumentService is a mock implementation of the DocumentServiceInterface
@@ -109,6 +114,13 @@ func (m *mockDocumentService) GetDocumentVersions(ctx context.Context, docID str
 	return versions, nil
 }
 
+// newTeamAccess returns access checks under which user_123 is a member of team_123
+func newTeamAccess(t *testing.T) service.DocumentAccessInterface {
+	db := testutils.SetupTestDB()
+	require.NoError(t, db.Create(&employeedomain.Employee{ID: "emp_1", UserID: "user_123", TeamID: "team_123", EmployeeID: "E1", Status: "active"}).Error)
+	return service.NewDocumentAccessWithDB(db)
+}
+
 // TestDocumentHandler tests the DocumentHandler
 func TestDocumentHandler(t *testing.T) {
 	// Set up test environment
@@ -118,7 +130,7 @@ func TestDocumentHandler(t *testing.T) {
 	mockService := newMockDocumentService()
 
 	// Create document handler with mock service
-	docHandler := NewDocumentHandlerWithService(mockService)
+	docHandler := NewDocumentHandlerWithService(mockService, newTeamAccess(t))
 
 	// Test Upload
 	t.Run("Upload", func(t *testing.T) {
@@ -128,7 +140,6 @@ func TestDocumentHandler(t *testing.T) {
 			FilePath: "/path/to/test.pdf",
 			FileSize: 1024,
 			MimeType: "application/pdf",
-			OwnerID:  "user_123",
 			TeamID:   "team_123",
 		}
 		jsonReq, _ := json.Marshal(reqBody)
@@ -141,10 +152,14 @@ func TestDocumentHandler(t *testing.T) {
 		// Create gin context and call handler
 		c, _ := gin.CreateTestContext(w)
 		c.Request = req
+		c.Set("user_id", "user_123")
 		docHandler.Upload(c)
 
-		// Assert response
+		// Assert response, the document is owned by the authenticated user
 		assert.Equal(t, http.StatusOK, w.Code)
+		var doc domain.Document
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+		assert.Equal(t, "user_123", doc.OwnerID)
 	})
 
 	// Test Upload with invalid JSON
@@ -365,4 +380,72 @@ func TestDocumentHandler(t *testing.T) {
 		// Assert response
 		assert.Equal(t, http.StatusNotFound, w.Code)
 	})
-}
\ No newline at end of file
+}
+// TestDocumentHandler_UploadFile tests uploading a document file as multipart form data
+func TestDocumentHandler_UploadFile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	driver, err := storage.NewLocalDriver(t.TempDir())
+	assert.NoError(t, err)
+	mockService := newMockDocumentService()
+	docHandler := NewDocumentHandlerWithServices(mockService, service.NewStorageServiceWithDriver(driver), newTeamAccess(t))
+
+	newUploadRequest := func(fields map[string]string, content string) *http.Request {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		for name, value := range fields {
+			writer.WriteField(name, value)
+		}
+		if content != "" {
+			part, _ := writer.CreateFormFile("file", "report.txt")
+			part.Write([]byte(content))
+		}
+		writer.Close()
+
+		req, _ := http.NewRequest("POST", "/documents", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		return req
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = newUploadRequest(map[string]string{"title": "Report", "team_id": "team_123"}, "quarterly report")
+		c.Set("user_id", "user_123")
+		docHandler.Upload(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var doc domain.Document
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+		assert.Equal(t, "user_123", doc.OwnerID)
+		assert.Equal(t, int64(16), doc.FileSize)
+		assert.Equal(t, "text/plain", doc.MimeType)
+		assert.NotEmpty(t, storage.HashFromKey(doc.FilePath))
+
+		exists, _ := driver.Exists(context.Background(), doc.FilePath)
+		assert.True(t, exists)
+	})
+
+	t.Run("MissingFile", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = newUploadRequest(map[string]string{"title": "Report", "team_id": "team_123"}, "")
+		c.Set("user_id", "user_123")
+		docHandler.Upload(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("NotMember", func(t *testing.T) {
+		uploaded := len(mockService.documents)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = newUploadRequest(map[string]string{"title": "Report", "team_id": "team_456"}, "quarterly report")
+		c.Set("user_id", "user_123")
+		docHandler.Upload(c)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Len(t, mockService.documents, uploaded)
+	})
+}
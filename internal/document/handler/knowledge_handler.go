@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"cdk-office/internal/document/service"
+	"github.com/gin-gonic/gin"
+)
+
+// KnowledgeHandlerInterface defines the interface for knowledge base sync handler
+type KnowledgeHandlerInterface interface {
+	GetSyncStatus(c *gin.Context)
+	SyncKnowledgeBase(c *gin.Context)
+}
+
+// KnowledgeHandler implements the KnowledgeHandlerInterface
+type KnowledgeHandler struct {
+	knowledgeBase service.KnowledgeSyncInterface
+	access        service.DocumentAccessInterface
+}
+
+// NewKnowledgeHandler creates a new instance of KnowledgeHandler
+func NewKnowledgeHandler(knowledgeBase service.KnowledgeSyncInterface, access service.DocumentAccessInterface) *KnowledgeHandler {
+	return &KnowledgeHandler{
+		knowledgeBase: knowledgeBase,
+		access:        access,
+	}
+}
+
+// GetSyncStatus handles retrieving the knowledge base sync status of a document
+func (h *KnowledgeHandler) GetSyncStatus(c *gin.Context) {
+	if !checkReadable(c, h.access) {
+		return
+	}
+
+	status, err := h.knowledgeBase.GetSyncStatus(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(knowledgeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// SyncKnowledgeBase handles syncing every changed or deleted document to the knowledge base
+func (h *KnowledgeHandler) SyncKnowledgeBase(c *gin.Context) {
+	if c.GetString("role") != adminRole {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only administrators can sync the knowledge base"})
+		return
+	}
+
+	count, err := h.knowledgeBase.SyncAll(c.Request.Context())
+	if err != nil {
+		c.JSON(knowledgeErrorStatus(err), gin.H{"error": err.Error(), "count": count})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "knowledge base synced successfully", "count": count})
+}
+
+// knowledgeErrorStatus maps knowledge base errors to HTTP status codes
+func knowledgeErrorStatus(err error) int {
+	switch msg := err.Error(); {
+	case msg == "document is not in the knowledge base":
+		return http.StatusNotFound
+	case msg == "knowledge base is not configured":
+		return http.StatusServiceUnavailable
+	case strings.HasPrefix(msg, "failed to"):
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
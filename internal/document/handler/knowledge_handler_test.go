@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cdk-office/internal/document/domain"
+	"cdk-office/internal/document/service"
+	employeedomain "cdk-office/internal/employee/domain"
+	"cdk-office/internal/shared/testutils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockKnowledgeSync is a mock implementation of KnowledgeSyncInterface
+type MockKnowledgeSync struct {
+	mock.Mock
+}
+
+func (m *MockKnowledgeSync) GetSyncStatus(ctx context.Context, docID string) (*domain.KnowledgeDocument, error) {
+	args := m.Called(ctx, docID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.KnowledgeDocument), args.Error(1)
+}
+
+func (m *MockKnowledgeSync) SyncAll(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+// TestKnowledgeHandler tests the KnowledgeHandler
+func TestKnowledgeHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := testutils.SetupTestDB()
+	require.NoError(t, db.Create(&employeedomain.Employee{ID: "emp_1", UserID: "user_1", TeamID: "team_1", EmployeeID: "E1", Status: "active"}).Error)
+	for _, document := range []*domain.Document{
+		{ID: "doc_1", Title: "Travel policy", TeamID: "team_1", OwnerID: "user_2"},
+		{ID: "doc_2", Title: "Sales travel policy", TeamID: "team_2", OwnerID: "user_3"},
+	} {
+		require.NoError(t, db.Create(document).Error)
+	}
+
+	mockSync := new(MockKnowledgeSync)
+	handler := NewKnowledgeHandler(mockSync, service.NewDocumentAccessWithDB(db))
+	serveAs := func(role, method, url string) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.Use(asUser("user_1", role))
+		router.GET("/documents/:id/knowledge", handler.GetSyncStatus)
+		router.POST("/documents/knowledge/sync", handler.SyncKnowledgeBase)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, url, nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("GetSyncStatus", func(t *testing.T) {
+		mockSync.On("GetSyncStatus", mock.Anything, "doc_1").
+			Return(&domain.KnowledgeDocument{DocumentID: "doc_1", IndexingStatus: "completed"}, nil).Once()
+		w := serveAs("user", http.MethodGet, "/documents/doc_1/knowledge")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"indexing_status":"completed"`)
+
+		assert.Equal(t, http.StatusNotFound, serveAs("user", http.MethodGet, "/documents/doc_2/knowledge").Code)
+
+		for message, status := range map[string]int{
+			"document is not in the knowledge base": http.StatusNotFound,
+			"knowledge base is not configured":      http.StatusServiceUnavailable,
+			"failed to get knowledge base status":   http.StatusInternalServerError,
+		} {
+			mockSync.On("GetSyncStatus", mock.Anything, "doc_1").Return(nil, errors.New(message)).Once()
+			assert.Equal(t, status, serveAs("user", http.MethodGet, "/documents/doc_1/knowledge").Code, message)
+		}
+	})
+
+	t.Run("SyncKnowledgeBase", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, serveAs("user", http.MethodPost, "/documents/knowledge/sync").Code)
+
+		mockSync.On("SyncAll", mock.Anything).Return(2, nil).Once()
+		w := serveAs("admin", http.MethodPost, "/documents/knowledge/sync")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"count":2`)
+
+		mockSync.On("SyncAll", mock.Anything).Return(2, errors.New("failed to sync some documents to knowledge base")).Once()
+		assert.Equal(t, http.StatusInternalServerError, serveAs("admin", http.MethodPost, "/documents/knowledge/sync").Code)
+	})
+
+	mockSync.AssertExpectations(t)
+}
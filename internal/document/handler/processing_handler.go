@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"cdk-office/internal/dify/workflow"
+	"github.com/gin-gonic/gin"
+)
+
+// ProcessingHandlerInterface defines the interface for document processing handler
+type ProcessingHandlerInterface interface {
+	GetProcessingStatus(c *gin.Context)
+	StartProcessing(c *gin.Context)
+	RetryProcessing(c *gin.Context)
+}
+
+// ProcessingHandler implements the ProcessingHandlerInterface
+type ProcessingHandler struct {
+	processingService workflow.ProcessingServiceInterface
+}
+
+// NewProcessingHandler creates a new instance of ProcessingHandler
+func NewProcessingHandler(processingService workflow.ProcessingServiceInterface) *ProcessingHandler {
+	return &ProcessingHandler{
+		processingService: processingService,
+	}
+}
+
+// RetryProcessingRequest represents the request for re-running processing stages
+type RetryProcessingRequest struct {
+	Stages []string `json:"stages"`
+}
+
+// GetProcessingStatus handles retrieving the processing stage of a document
+func (h *ProcessingHandler) GetProcessingStatus(c *gin.Context) {
+	status, err := h.processingService.GetProcessingStatus(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(processingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// StartProcessing handles queueing a document for processing
+func (h *ProcessingHandler) StartProcessing(c *gin.Context) {
+	job, err := h.processingService.EnqueueDocument(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(processingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// RetryProcessing handles re-running the failed stages of a document, or the given stages
+func (h *ProcessingHandler) RetryProcessing(c *gin.Context) {
+	var req RetryProcessingRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	job, err := h.processingService.RetryFailedStages(c.Request.Context(), c.Param("id"), req.Stages)
+	if err != nil {
+		c.JSON(processingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// processingErrorStatus maps processing service errors to HTTP status codes
+func processingErrorStatus(err error) int {
+	switch msg := err.Error(); {
+	case msg == "document not found" || msg == "processing job not found":
+		return http.StatusNotFound
+	case msg == "processing job is running" || msg == "no failed stages to retry":
+		return http.StatusConflict
+	case strings.HasPrefix(msg, "failed to"):
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cdk-office/internal/dify/workflow"
+	"cdk-office/internal/document/domain"
+	"cdk-office/internal/shared/testutils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockProcessingService is a mock implementation of ProcessingServiceInterface
+type MockProcessingService struct {
+	mock.Mock
+}
+
+func (m *MockProcessingService) EnqueueDocument(ctx context.Context, documentID string) (*domain.ProcessingJob, error) {
+	args := m.Called(ctx, documentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.ProcessingJob), args.Error(1)
+}
+
+func (m *MockProcessingService) GetProcessingStatus(ctx context.Context, documentID string) (*workflow.ProcessingStatus, error) {
+	args := m.Called(ctx, documentID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*workflow.ProcessingStatus), args.Error(1)
+}
+
+func (m *MockProcessingService) RetryFailedStages(ctx context.Context, documentID string, stages []string) (*domain.ProcessingJob, error) {
+	args := m.Called(ctx, documentID, stages)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.ProcessingJob), args.Error(1)
+}
+
+// TestProcessingHandler tests the ProcessingHandler
+func TestProcessingHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockProcessingService)
+	handler := NewProcessingHandler(mockService)
+	router := gin.New()
+	router.GET("/documents/:id/processing", handler.GetProcessingStatus)
+	router.POST("/documents/:id/processing", handler.StartProcessing)
+	router.POST("/documents/:id/processing/retry", handler.RetryProcessing)
+
+	t.Run("GetProcessingStatus", func(t *testing.T) {
+		status := &workflow.ProcessingStatus{
+			DocumentID:   "doc_123",
+			Job:          &domain.ProcessingJob{ID: "job_1", Status: domain.ProcessingJobPending},
+			CurrentStage: workflow.StageClassify,
+			Steps: []*domain.ProcessingStep{
+				{Stage: workflow.StageExtract, Status: domain.ProcessingStepCompleted},
+				{Stage: workflow.StageClassify, Status: domain.ProcessingStepFailed, Error: "timeout"},
+			},
+		}
+		mockService.On("GetProcessingStatus", mock.Anything, "doc_123").Return(status, nil).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/documents/doc_123/processing", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response workflow.ProcessingStatus
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, workflow.StageClassify, response.CurrentStage)
+		assert.Equal(t, "timeout", response.Steps[1].Error)
+	})
+
+	t.Run("GetProcessingStatusNotFound", func(t *testing.T) {
+		mockService.On("GetProcessingStatus", mock.Anything, "doc_404").Return(nil, testutils.NewError("processing job not found")).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/documents/doc_404/processing", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("StartProcessing", func(t *testing.T) {
+		mockService.On("EnqueueDocument", mock.Anything, "doc_123").Return(&domain.ProcessingJob{ID: "job_2"}, nil).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/documents/doc_123/processing", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Contains(t, w.Body.String(), "job_2")
+	})
+
+	t.Run("RetryProcessing", func(t *testing.T) {
+		mockService.On("RetryFailedStages", mock.Anything, "doc_123", []string{"summarize"}).Return(&domain.ProcessingJob{ID: "job_1"}, nil).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/documents/doc_123/processing/retry", bytes.NewBufferString(`{"stages":["summarize"]}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+	})
+
+	t.Run("RetryProcessingWithoutFailures", func(t *testing.T) {
+		mockService.On("RetryFailedStages", mock.Anything, "doc_123", []string(nil)).Return(nil, testutils.NewError("no failed stages to retry")).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/documents/doc_123/processing/retry", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
@@ -3,26 +3,45 @@ package handler
 import (
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"cdk-office/internal/document/domain"
+	"cdk-office/internal/document/search"
 	"cdk-office/internal/document/service"
+	"cdk-office/pkg/config"
 	"github.com/gin-gonic/gin"
 )
 
+// adminRole is the role allowed to search every team and rebuild the search index
+const adminRole = "admin"
+
 // SearchHandlerInterface defines the interface for document search handler
 type SearchHandlerInterface interface {
 	SearchDocuments(c *gin.Context)
+	ReindexDocuments(c *gin.Context)
 }
 
 // SearchHandler implements the SearchHandlerInterface
 type SearchHandler struct {
 	searchService service.SearchServiceInterface
+	access        service.DocumentAccessInterface
 }
 
 // NewSearchHandler creates a new instance of SearchHandler
-func NewSearchHandler() *SearchHandler {
+func NewSearchHandler(cfg *config.StorageConfig) (*SearchHandler, error) {
+	searchService, err := service.NewSearchService(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewSearchHandlerWithService(searchService, service.NewDocumentAccess()), nil
+}
+
+// NewSearchHandlerWithService creates a new instance of SearchHandler with a specific search service and access checks
+func NewSearchHandlerWithService(searchService service.SearchServiceInterface, access service.DocumentAccessInterface) *SearchHandler {
 	return &SearchHandler{
-		searchService: service.NewSearchService(),
+		searchService: searchService,
+		access:        access,
 	}
 }
 
@@ -36,10 +55,12 @@ type SearchDocumentsRequest struct {
 
 // SearchDocumentsResponse represents the response for searching documents
 type SearchDocumentsResponse struct {
-	Items []*domain.Document `json:"items"`
-	Total int64              `json:"total"`
-	Page  int                `json:"page"`
-	Size  int                `json:"size"`
+	Items  []*domain.Document             `json:"items"`
+	Hits   []*search.Hit                  `json:"hits"`
+	Facets map[string][]search.FacetValue `json:"facets"`
+	Total  int64                          `json:"total"`
+	Page   int                            `json:"page"`
+	Size   int                            `json:"size"`
 }
 
 // SearchDocuments handles searching for documents
@@ -72,20 +93,136 @@ func (h *SearchHandler) SearchDocuments(c *gin.Context) {
 		req.Size = 10
 	}
 
+	// Only administrators search across teams
+	if req.TeamID == "" && c.GetString("role") != adminRole {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team_id is required"})
+		return
+	}
+	if req.TeamID != "" {
+		allowed, err := h.access.CanReadTeam(c.Request.Context(), c.GetString("user_id"), c.GetString("role"), req.TeamID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "user cannot read the documents of this team"})
+			return
+		}
+	}
+
+	// Parse filters
+	query := &search.Query{
+		Text: req.Query,
+		Filters: search.Filters{
+			TeamID:      req.TeamID,
+			MimeTypes:   queryList(c, "mime_type"),
+			CategoryIDs: queryList(c, "category_id"),
+			OwnerIDs:    queryList(c, "owner_id"),
+			Tags:        queryList(c, "tag"),
+		},
+		Page: req.Page,
+		Size: req.Size,
+	}
+	if from := c.Query("from"); from != "" {
+		t, err := parseSearchDate(from, false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date"})
+			return
+		}
+		query.CreatedFrom = &t
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := parseSearchDate(to, true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date"})
+			return
+		}
+		query.CreatedTo = &t
+	}
+
 	// Call service to search documents
-	documents, total, err := h.searchService.SearchDocuments(c.Request.Context(), req.Query, req.TeamID, req.Page, req.Size)
+	result, err := h.searchService.Search(c.Request.Context(), query)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Keep the hits on documents the user may read
+	documentIDs := make([]string, len(result.Hits))
+	for i, hit := range result.Hits {
+		documentIDs[i] = hit.Document.ID
+	}
+	readable, err := h.access.ReadableDocuments(c.Request.Context(), c.GetString("user_id"), c.GetString("role"), documentIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	hits := make([]*search.Hit, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		if readable[hit.Document.ID] != nil {
+			hits = append(hits, hit)
+		}
+	}
+	result.Total -= int64(len(result.Hits) - len(hits))
+
 	// Build response
+	documents := make([]*domain.Document, 0, len(hits))
+	for _, hit := range hits {
+		documents = append(documents, hit.Document)
+	}
 	response := SearchDocumentsResponse{
-		Items: documents,
-		Total: total,
-		Page:  req.Page,
-		Size:  req.Size,
+		Items:  documents,
+		Hits:   hits,
+		Facets: result.Facets,
+		Total:  result.Total,
+		Page:   req.Page,
+		Size:   req.Size,
 	}
 
 	c.JSON(http.StatusOK, response)
-}
\ No newline at end of file
+}
+
+// ReindexDocuments handles rebuilding the search index for all documents
+func (h *SearchHandler) ReindexDocuments(c *gin.Context) {
+	if c.GetString("role") != adminRole {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only administrators can reindex documents"})
+		return
+	}
+
+	count, err := h.searchService.ReindexAll(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "documents reindexed successfully", "count": count})
+}
+
+// queryList returns the values of a query parameter given repeatedly or as a comma-separated list
+func queryList(c *gin.Context, key string) []string {
+	var values []string
+	for _, param := range c.QueryArray(key) {
+		for _, value := range strings.Split(param, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// parseSearchDate parses an RFC3339 timestamp or a date. A date used as the end of
+// a range covers the whole day.
+func parseSearchDate(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
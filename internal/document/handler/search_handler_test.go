@@ -7,9 +7,14 @@ import (
 	"net/http/httptest"
 	"strconv"
 	"testing"
+	"time"
 
 	"cdk-office/internal/document/domain"
+	"cdk-office/internal/document/search"
+	"cdk-office/internal/document/service"
+	employeedomain "cdk-office/internal/employee/domain"
 	"cdk-office/internal/shared/testutils"
+	"cdk-office/pkg/config"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -29,9 +34,49 @@ func (m *MockSearchService) SearchDocuments(ctx context.Context, query, teamID s
 	return args.Get(0).([]*domain.Document), args.Get(1).(int64), args.Error(2)
 }
 
+func (m *MockSearchService) Search(ctx context.Context, query *search.Query) (*search.Result, error) {
+	args := m.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*search.Result), args.Error(1)
+}
+
+func (m *MockSearchService) IndexDocument(ctx context.Context, docID string) error {
+	args := m.Called(ctx, docID)
+	return args.Error(0)
+}
+
+func (m *MockSearchService) RemoveDocument(ctx context.Context, docID string) error {
+	args := m.Called(ctx, docID)
+	return args.Error(0)
+}
+
+func (m *MockSearchService) ReindexAll(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+// searchQuery builds the query the handler is expected to pass to the service
+func searchQuery(text, teamID string, page, size int) *search.Query {
+	return &search.Query{Text: text, Filters: search.Filters{TeamID: teamID}, Page: page, Size: size}
+}
+
+// searchResult builds a search result containing the given documents
+func searchResult(documents []*domain.Document, total int64) *search.Result {
+	result := &search.Result{Hits: []*search.Hit{}, Total: total}
+	for _, doc := range documents {
+		result.Hits = append(result.Hits, &search.Hit{Document: doc})
+	}
+	return result
+}
+
 // TestNewSearchHandler tests the NewSearchHandler function
 func TestNewSearchHandler(t *testing.T) {
-	handler := NewSearchHandler()
+	cfg := config.Default()
+	cfg.Storage.LocalPath = t.TempDir()
+	handler, err := NewSearchHandler(&cfg.Storage)
+	assert.NoError(t, err)
 	assert.NotNil(t, handler)
 	assert.NotNil(t, handler.searchService)
 }
@@ -44,13 +89,17 @@ func TestSearchDocuments(t *testing.T) {
 	// Create mock service
 	mockService := new(MockSearchService)
 
-	// Create handler with mock service
+	// Create handler with mock service over stored documents
+	db := testutils.SetupTestDB()
+	assert.NoError(t, db.Create([]*domain.Document{{ID: "doc_123", TeamID: "team_123"}, {ID: "doc_456", TeamID: "team_123"}}).Error)
 	handler := &SearchHandler{
 		searchService: mockService,
+		access:        service.NewDocumentAccessWithDB(db),
 	}
 
 	// Create test router
 	router := gin.New()
+	router.Use(asUser("admin_1", "admin"))
 	router.GET("/search", handler.SearchDocuments)
 
 	// Test successful search
@@ -77,7 +126,7 @@ func TestSearchDocuments(t *testing.T) {
 		total := int64(2)
 
 		// Mock service response
-		mockService.On("SearchDocuments", mock.Anything, query, teamID, page, size).Return(expectedDocuments, total, nil).Once()
+		mockService.On("Search", mock.Anything, searchQuery(query, teamID, page, size)).Return(searchResult(expectedDocuments, total), nil).Once()
 
 		// Create request
 		req, _ := http.NewRequest(http.MethodGet, "/search?q="+query+"&team_id="+teamID+"&page="+strconv.Itoa(page)+"&size="+strconv.Itoa(size), nil)
@@ -119,7 +168,7 @@ func TestSearchDocuments(t *testing.T) {
 		total := int64(1)
 
 		// Mock service response with default page=1 and size=10
-		mockService.On("SearchDocuments", mock.Anything, query, "", 1, 10).Return(expectedDocuments, total, nil).Once()
+		mockService.On("Search", mock.Anything, searchQuery(query, "", 1, 10)).Return(searchResult(expectedDocuments, total), nil).Once()
 
 		// Create request
 		req, _ := http.NewRequest(http.MethodGet, "/search?q="+query, nil)
@@ -158,7 +207,7 @@ func TestSearchDocuments(t *testing.T) {
 		total := int64(0)
 
 		// Mock service response
-		mockService.On("SearchDocuments", mock.Anything, query, "", page, size).Return(expectedDocuments, total, nil).Once()
+		mockService.On("Search", mock.Anything, searchQuery(query, "", page, size)).Return(searchResult(expectedDocuments, total), nil).Once()
 
 		// Create request
 		req, _ := http.NewRequest(http.MethodGet, "/search?q="+query+"&page="+strconv.Itoa(page)+"&size="+strconv.Itoa(size), nil)
@@ -195,7 +244,7 @@ func TestSearchDocuments(t *testing.T) {
 		total := int64(0)
 
 		// Mock service response with default page=1
-		mockService.On("SearchDocuments", mock.Anything, query, "", 1, 10).Return(expectedDocuments, total, nil).Once()
+		mockService.On("Search", mock.Anything, searchQuery(query, "", 1, 10)).Return(searchResult(expectedDocuments, total), nil).Once()
 
 		// Create request with invalid page
 		req, _ := http.NewRequest(http.MethodGet, "/search?q="+query+"&page=invalid", nil)
@@ -232,7 +281,7 @@ func TestSearchDocuments(t *testing.T) {
 		total := int64(0)
 
 		// Mock service response with default size=10
-		mockService.On("SearchDocuments", mock.Anything, query, "", 1, 10).Return(expectedDocuments, total, nil).Once()
+		mockService.On("Search", mock.Anything, searchQuery(query, "", 1, 10)).Return(searchResult(expectedDocuments, total), nil).Once()
 
 		// Create request with invalid size
 		req, _ := http.NewRequest(http.MethodGet, "/search?q="+query+"&size=invalid", nil)
@@ -269,7 +318,7 @@ func TestSearchDocuments(t *testing.T) {
 		total := int64(0)
 
 		// Mock service response with default size=10
-		mockService.On("SearchDocuments", mock.Anything, query, "", 1, 10).Return(expectedDocuments, total, nil).Once()
+		mockService.On("Search", mock.Anything, searchQuery(query, "", 1, 10)).Return(searchResult(expectedDocuments, total), nil).Once()
 
 		// Create request with size exceeding maximum
 		req, _ := http.NewRequest(http.MethodGet, "/search?q="+query+"&size=150", nil)
@@ -302,7 +351,7 @@ func TestSearchDocuments(t *testing.T) {
 		query := "test"
 
 		// Mock service response
-		mockService.On("SearchDocuments", mock.Anything, query, "", 1, 10).Return([]*domain.Document(nil), int64(0), testutils.NewError("internal error")).Once()
+		mockService.On("Search", mock.Anything, searchQuery(query, "", 1, 10)).Return(nil, testutils.NewError("internal error")).Once()
 
 		// Create request
 		req, _ := http.NewRequest(http.MethodGet, "/search?q="+query, nil)
@@ -320,4 +369,137 @@ func TestSearchDocuments(t *testing.T) {
 		// Assert mock expectations
 		mockService.AssertExpectations(t)
 	})
-}
\ No newline at end of file
+}
+// TestSearchDocumentsWithFilters tests parsing of search filters
+func TestSearchDocumentsWithFilters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := testutils.SetupTestDB()
+	assert.NoError(t, db.Create(&domain.Document{ID: "doc_123", TeamID: "team_123"}).Error)
+	mockService := new(MockSearchService)
+	handler := NewSearchHandlerWithService(mockService, service.NewDocumentAccessWithDB(db))
+	router := gin.New()
+	router.Use(asUser("admin_1", "admin"))
+	router.GET("/search", handler.SearchDocuments)
+
+	t.Run("FiltersAndFacets", func(t *testing.T) {
+		from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		to := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC).Add(24*time.Hour - time.Nanosecond)
+		expectedQuery := &search.Query{
+			Text: "report",
+			Filters: search.Filters{
+				TeamID:      "team_123",
+				MimeTypes:   []string{"application/pdf", "text/plain"},
+				CategoryIDs: []string{"cat_1"},
+				OwnerIDs:    []string{"user_1"},
+				Tags:        []string{"finance", "q1"},
+				CreatedFrom: &from,
+				CreatedTo:   &to,
+			},
+			Page: 1,
+			Size: 10,
+		}
+		result := &search.Result{
+			Hits: []*search.Hit{{
+				Document:   &domain.Document{ID: "doc_123", Title: "Quarterly report"},
+				Score:      1.5,
+				Highlights: map[string]string{"title": "Quarterly <mark>report</mark>"},
+			}},
+			Total:  1,
+			Facets: map[string][]search.FacetValue{search.FacetMimeType: {{Value: "application/pdf", Count: 1}}},
+		}
+		mockService.On("Search", mock.Anything, expectedQuery).Return(result, nil).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/search?q=report&team_id=team_123&mime_type=application/pdf,text/plain"+
+			"&category_id=cat_1&owner_id=user_1&tag=finance&tag=q1&from=2024-01-01&to=2024-01-31", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response SearchDocumentsResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, int64(1), response.Total)
+		assert.Len(t, response.Items, 1)
+		assert.Equal(t, "Quarterly <mark>report</mark>", response.Hits[0].Highlights["title"])
+		assert.Equal(t, int64(1), response.Facets[search.FacetMimeType][0].Count)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("InvalidDate", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/search?q=report&from=yesterday", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid from date")
+	})
+}
+
+// TestReindexDocuments tests the ReindexDocuments handler
+func TestReindexDocuments(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockSearchService)
+	handler := NewSearchHandlerWithService(mockService, service.NewDocumentAccessWithDB(testutils.SetupTestDB()))
+	reindexAs := func(role string) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.Use(asUser("user_1", role))
+		router.POST("/search/reindex", handler.ReindexDocuments)
+		req, _ := http.NewRequest(http.MethodPost, "/search/reindex", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusForbidden, reindexAs("user").Code)
+
+	mockService.On("ReindexAll", mock.Anything).Return(3, nil).Once()
+	w := reindexAs("admin")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"count":3`)
+	mockService.AssertExpectations(t)
+}
+
+// TestSearchDocumentsAccess tests that users only search the teams they may read
+// and only see hits on documents they may read
+func TestSearchDocumentsAccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := testutils.SetupTestDB()
+	assert.NoError(t, db.Create(&employeedomain.Employee{ID: "emp_1", UserID: "user_1", TeamID: "team_1", EmployeeID: "E1", Status: "active"}).Error)
+	mockService := new(MockSearchService)
+	handler := NewSearchHandlerWithService(mockService, service.NewDocumentAccessWithDB(db))
+	router := gin.New()
+	router.Use(asUser("user_1", "user"))
+	router.GET("/search", handler.SearchDocuments)
+
+	assert.NoError(t, db.Create([]*domain.Document{{ID: "doc_1", TeamID: "team_1"}, {ID: "doc_2", TeamID: "team_2"}}).Error)
+	mockService.On("Search", mock.Anything, searchQuery("report", "team_1", 1, 10)).Return(searchResult([]*domain.Document{{ID: "doc_1"}, {ID: "doc_2"}}, 2), nil).Once()
+	for url, status := range map[string]int{
+		"/search?q=report&team_id=team_1": http.StatusOK,
+		"/search?q=report&team_id=team_2": http.StatusForbidden,
+		"/search?q=report":                http.StatusBadRequest,
+	} {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, status, w.Code, url)
+		if status == http.StatusOK {
+			var response SearchDocumentsResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			if assert.Len(t, response.Hits, 1) {
+				assert.Equal(t, "doc_1", response.Hits[0].Document.ID)
+			}
+			assert.Equal(t, int64(1), response.Total)
+		}
+	}
+	mockService.AssertExpectations(t)
+}
+
+// asUser authenticates every request as a user with a role
+func asUser(userID, role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Set("role", role)
+	}
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cdk-office/internal/document/service"
+	"cdk-office/pkg/config"
+	"github.com/gin-gonic/gin"
+)
+
+// SemanticHandlerInterface defines the interface for semantic search handler
+type SemanticHandlerInterface interface {
+	SemanticSearch(c *gin.Context)
+	SimilarDocuments(c *gin.Context)
+	NearDuplicates(c *gin.Context)
+}
+
+// SemanticHandler implements the SemanticHandlerInterface
+type SemanticHandler struct {
+	semanticService service.SemanticServiceInterface
+	access          service.DocumentAccessInterface
+}
+
+// NewSemanticHandler creates a new instance of SemanticHandler
+func NewSemanticHandler(cfg *config.VectorConfig) *SemanticHandler {
+	return &SemanticHandler{
+		semanticService: service.NewSemanticService(cfg),
+		access:          service.NewDocumentAccess(),
+	}
+}
+
+// NewSemanticHandlerWithService creates a new instance of SemanticHandler with a specific semantic service and access checks
+func NewSemanticHandlerWithService(semanticService service.SemanticServiceInterface, access service.DocumentAccessInterface) *SemanticHandler {
+	return &SemanticHandler{
+		semanticService: semanticService,
+		access:          access,
+	}
+}
+
+// SemanticSearch handles searching the documents of a team by meaning
+func (h *SemanticHandler) SemanticSearch(c *gin.Context) {
+	teamID := c.Query("team_id")
+	if teamID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team_id is required"})
+		return
+	}
+	allowed, err := h.access.CanReadTeam(c.Request.Context(), c.GetString("user_id"), c.GetString("role"), teamID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "user cannot read the documents of this team"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	hits, err := h.semanticService.SemanticSearch(c.Request.Context(), c.Query("q"), teamID, limit)
+	if err != nil {
+		c.JSON(semanticErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	visible, ok := h.readableHits(c, hits)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"hits": visible})
+}
+
+// SimilarDocuments handles finding the documents most like a document
+func (h *SemanticHandler) SimilarDocuments(c *gin.Context) {
+	if !checkReadable(c, h.access) {
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	hits, err := h.semanticService.MoreLikeThis(c.Request.Context(), c.Param("id"), limit)
+	if err != nil {
+		c.JSON(semanticErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	visible, ok := h.readableHits(c, hits)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"hits": visible})
+}
+
+// readableHits keeps the hits on documents the authenticated user may read. It
+// responds with an error and reports false if access can not be checked.
+func (h *SemanticHandler) readableHits(c *gin.Context, hits []*service.SemanticHit) ([]*service.SemanticHit, bool) {
+	documentIDs := make([]string, len(hits))
+	for i, hit := range hits {
+		documentIDs[i] = hit.Document.ID
+	}
+	readable, err := h.access.ReadableDocuments(c.Request.Context(), c.GetString("user_id"), c.GetString("role"), documentIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	visible := make([]*service.SemanticHit, 0, len(hits))
+	for _, hit := range hits {
+		if readable[hit.Document.ID] != nil {
+			visible = append(visible, hit)
+		}
+	}
+	return visible, true
+}
+
+// NearDuplicates handles listing the near-duplicates of a document
+func (h *SemanticHandler) NearDuplicates(c *gin.Context) {
+	if !checkReadable(c, h.access) {
+		return
+	}
+
+	duplicates, err := h.semanticService.GetNearDuplicates(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(semanticErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	documentIDs := make([]string, len(duplicates))
+	for i, duplicate := range duplicates {
+		documentIDs[i] = duplicate.Document.ID
+	}
+	readable, err := h.access.ReadableDocuments(c.Request.Context(), c.GetString("user_id"), c.GetString("role"), documentIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	visible := make([]*service.NearDuplicate, 0, len(duplicates))
+	for _, duplicate := range duplicates {
+		if readable[duplicate.Document.ID] != nil {
+			visible = append(visible, duplicate)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": visible})
+}
+
+// checkReadable responds with not found unless the user may read the document
+// in the path, so that documents of other teams are not disclosed
+func checkReadable(c *gin.Context, access service.DocumentAccessInterface) bool {
+	readable, err := access.ReadableDocuments(c.Request.Context(), c.GetString("user_id"), c.GetString("role"), []string{c.Param("id")})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if readable[c.Param("id")] == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return false
+	}
+	return true
+}
+
+// semanticErrorStatus maps semantic search service errors to HTTP status codes
+func semanticErrorStatus(err error) int {
+	switch msg := err.Error(); {
+	case msg == "document not found":
+		return http.StatusNotFound
+	case msg == "semantic search is not configured":
+		return http.StatusServiceUnavailable
+	case strings.HasPrefix(msg, "failed to"):
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cdk-office/internal/document/domain"
+	"cdk-office/internal/document/service"
+	employeedomain "cdk-office/internal/employee/domain"
+	"cdk-office/internal/shared/testutils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockSemanticService is a mock implementation of SemanticServiceInterface
+type MockSemanticService struct {
+	mock.Mock
+}
+
+func (m *MockSemanticService) SemanticSearch(ctx context.Context, query, teamID string, limit int) ([]*service.SemanticHit, error) {
+	args := m.Called(ctx, query, teamID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*service.SemanticHit), args.Error(1)
+}
+
+func (m *MockSemanticService) MoreLikeThis(ctx context.Context, docID string, limit int) ([]*service.SemanticHit, error) {
+	args := m.Called(ctx, docID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*service.SemanticHit), args.Error(1)
+}
+
+func (m *MockSemanticService) GetNearDuplicates(ctx context.Context, docID string) ([]*service.NearDuplicate, error) {
+	args := m.Called(ctx, docID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*service.NearDuplicate), args.Error(1)
+}
+
+// TestSemanticHandler tests the SemanticHandler
+func TestSemanticHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db := testutils.SetupTestDB()
+	require.NoError(t, db.Create(&employeedomain.Employee{ID: "emp_1", UserID: "user_1", TeamID: "team_1", EmployeeID: "E1", Status: "active"}).Error)
+	for _, document := range []*domain.Document{
+		{ID: "doc_1", Title: "Travel policy", TeamID: "team_1", OwnerID: "user_2"},
+		{ID: "doc_2", Title: "Travel policy 2023", TeamID: "team_1", OwnerID: "user_2"},
+		{ID: "doc_3", Title: "Travel policy copy", TeamID: "team_1", OwnerID: "user_2"},
+		{ID: "doc_4", Title: "Sales travel policy", TeamID: "team_2", OwnerID: "user_3"},
+	} {
+		require.NoError(t, db.Create(document).Error)
+	}
+
+	mockService := new(MockSemanticService)
+	handler := NewSemanticHandlerWithService(mockService, service.NewDocumentAccessWithDB(db))
+	router := gin.New()
+	router.Use(asUser("user_1", "user"))
+	router.GET("/search/semantic", handler.SemanticSearch)
+	router.GET("/documents/:id/similar", handler.SimilarDocuments)
+	router.GET("/documents/:id/duplicates", handler.NearDuplicates)
+
+	t.Run("SemanticSearch", func(t *testing.T) {
+		mockService.On("SemanticSearch", mock.Anything, "hotel costs", "team_1", 5).
+			Return([]*service.SemanticHit{
+				{Document: &domain.Document{ID: "doc_1"}, Score: 0.8, Passage: "Hotels are reimbursed"},
+				{Document: &domain.Document{ID: "doc_4"}, Score: 0.7, Passage: "Sales hotels are reimbursed"},
+			}, nil).Once()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/search/semantic?q=hotel+costs&team_id=team_1&limit=5", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var response struct {
+			Hits []*service.SemanticHit `json:"hits"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Hits, 1)
+		assert.Equal(t, "doc_1", response.Hits[0].Document.ID)
+		assert.Equal(t, "Hotels are reimbursed", response.Hits[0].Passage)
+	})
+
+	t.Run("SimilarAndDuplicates", func(t *testing.T) {
+		mockService.On("MoreLikeThis", mock.Anything, "doc_1", 0).
+			Return([]*service.SemanticHit{
+				{Document: &domain.Document{ID: "doc_2"}, Score: 0.7},
+				{Document: &domain.Document{ID: "doc_4"}, Score: 0.6},
+			}, nil).Once()
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/documents/doc_1/similar", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"doc_2"`)
+		assert.NotContains(t, w.Body.String(), `"doc_4"`)
+
+		mockService.On("GetNearDuplicates", mock.Anything, "doc_1").
+			Return([]*service.NearDuplicate{
+				{Document: &domain.Document{ID: "doc_3"}, Similarity: 0.98},
+				{Document: &domain.Document{ID: "doc_4"}, Similarity: 0.97},
+			}, nil).Once()
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest(http.MethodGet, "/documents/doc_1/duplicates", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"similarity":0.98`)
+		assert.NotContains(t, w.Body.String(), `"doc_4"`)
+	})
+
+	t.Run("Access", func(t *testing.T) {
+		for url, status := range map[string]int{
+			"/search/semantic?q=travel":                http.StatusBadRequest,
+			"/search/semantic?q=travel&team_id=team_2": http.StatusForbidden,
+			"/documents/doc_4/similar":                 http.StatusNotFound,
+			"/documents/doc_4/duplicates":              http.StatusNotFound,
+		} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, url, nil)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, status, w.Code, url)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for message, status := range map[string]int{
+			"query is required":                 http.StatusBadRequest,
+			"semantic search is not configured": http.StatusServiceUnavailable,
+			"failed to search documents":        http.StatusInternalServerError,
+		} {
+			mockService.On("SemanticSearch", mock.Anything, "", "team_1", 0).Return(nil, errors.New(message)).Once()
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/search/semantic?team_id=team_1", nil)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, status, w.Code, message)
+		}
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/documents/doc_missing/duplicates", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"cdk-office/internal/document/service"
+	"cdk-office/pkg/config"
+	"github.com/gin-gonic/gin"
+)
+
+// UploadHandlerInterface defines the interface for resumable upload handler
+type UploadHandlerInterface interface {
+	InitUpload(c *gin.Context)
+	UploadChunk(c *gin.Context)
+	GetUploadStatus(c *gin.Context)
+	CompleteUpload(c *gin.Context)
+	AbortUpload(c *gin.Context)
+}
+
+// UploadHandler implements the UploadHandlerInterface
+type UploadHandler struct {
+	uploadService service.UploadServiceInterface
+}
+
+// NewUploadHandler creates a new instance of UploadHandler
+func NewUploadHandler(cfg *config.Config) (*UploadHandler, error) {
+	uploadService, err := service.NewUploadService(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewUploadHandlerWithService(uploadService), nil
+}
+
+// NewUploadHandlerWithService creates a new instance of UploadHandler with a specific service
+func NewUploadHandlerWithService(uploadService service.UploadServiceInterface) *UploadHandler {
+	return &UploadHandler{
+		uploadService: uploadService,
+	}
+}
+
+// InitUploadRequest represents the request for starting a resumable upload
+type InitUploadRequest struct {
+	TeamID      string `json:"team_id" binding:"required"`
+	Title       string `json:"title" binding:"required"`
+	Description string `json:"description"`
+	Tags        string `json:"tags"`
+	FileName    string `json:"file_name" binding:"required"`
+	MimeType    string `json:"mime_type" binding:"required"`
+	TotalSize   int64  `json:"total_size" binding:"required"`
+	ChunkSize   int64  `json:"chunk_size" binding:"required"`
+	FileHash    string `json:"file_hash"`
+}
+
+// InitUpload handles starting a resumable upload session
+func (h *UploadHandler) InitUpload(c *gin.Context) {
+	var req InitUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Call service to init upload
+	session, err := h.uploadService.InitUpload(c.Request.Context(), &service.InitUploadRequest{
+		OwnerID:     c.GetString("user_id"),
+		TeamID:      req.TeamID,
+		Title:       req.Title,
+		Description: req.Description,
+		Tags:        req.Tags,
+		FileName:    req.FileName,
+		MimeType:    req.MimeType,
+		TotalSize:   req.TotalSize,
+		ChunkSize:   req.ChunkSize,
+		FileHash:    req.FileHash,
+	})
+	if err != nil {
+		c.JSON(uploadErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+// UploadChunk handles uploading one chunk of a session. The raw chunk bytes are
+// sent as the request body and their SHA-256 in the X-Chunk-Checksum header.
+func (h *UploadHandler) UploadChunk(c *gin.Context) {
+	sessionID := c.Param("id")
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "upload session id is required"})
+		return
+	}
+
+	chunkIndex, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chunk index"})
+		return
+	}
+
+	checksum := c.GetHeader("X-Chunk-Checksum")
+	if checksum == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "X-Chunk-Checksum header is required"})
+		return
+	}
+
+	// Call service to upload chunk
+	chunk, err := h.uploadService.UploadChunk(c.Request.Context(), sessionID, c.GetString("user_id"), chunkIndex, checksum, c.Request.Body)
+	if err != nil {
+		c.JSON(uploadErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, chunk)
+}
+
+// GetUploadStatus handles retrieving the received chunks and byte ranges of a session
+func (h *UploadHandler) GetUploadStatus(c *gin.Context) {
+	sessionID := c.Param("id")
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "upload session id is required"})
+		return
+	}
+
+	// Call service to get upload status
+	status, err := h.uploadService.GetUploadStatus(c.Request.Context(), sessionID, c.GetString("user_id"))
+	if err != nil {
+		c.JSON(uploadErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// CompleteUpload handles assembling the chunks of a session into a document
+func (h *UploadHandler) CompleteUpload(c *gin.Context) {
+	sessionID := c.Param("id")
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "upload session id is required"})
+		return
+	}
+
+	// Call service to complete upload
+	document, err := h.uploadService.CompleteUpload(c.Request.Context(), sessionID, c.GetString("user_id"))
+	if err != nil {
+		c.JSON(uploadErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, document)
+}
+
+// AbortUpload handles cancelling an upload session
+func (h *UploadHandler) AbortUpload(c *gin.Context) {
+	sessionID := c.Param("id")
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "upload session id is required"})
+		return
+	}
+
+	// Call service to abort upload
+	if err := h.uploadService.AbortUpload(c.Request.Context(), sessionID, c.GetString("user_id")); err != nil {
+		c.JSON(uploadErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "upload aborted successfully"})
+}
+
+// uploadErrorStatus maps upload service errors to HTTP status codes
+func uploadErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case msg == "upload session not found":
+		return http.StatusNotFound
+	case msg == "user is not a member of the team":
+		return http.StatusForbidden
+	case msg == "upload session expired":
+		return http.StatusGone
+	case msg == "upload session already completed":
+		return http.StatusConflict
+	case strings.HasPrefix(msg, "failed to"):
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
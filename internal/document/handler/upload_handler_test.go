@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"cdk-office/internal/document/domain"
+	"cdk-office/internal/document/service"
+	"cdk-office/internal/document/storage"
+	employeedomain "cdk-office/internal/employee/domain"
+	"cdk-office/internal/shared/testutils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestUploadHandler tests the UploadHandler
+func TestUploadHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	testDB := testutils.SetupTestDB()
+	assert.NoError(t, testDB.Create(&employeedomain.Employee{ID: "emp_1", UserID: "user_123", TeamID: "team_123", EmployeeID: "E1", Status: "active"}).Error)
+	driver, err := storage.NewLocalDriver(t.TempDir())
+	assert.NoError(t, err)
+	storageService := service.NewStorageServiceWithDriver(driver)
+	uploadHandler := NewUploadHandlerWithService(service.NewUploadServiceWithDeps(
+		testDB, storageService, service.NewDocumentServiceWithStorage(testDB, storageService)))
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", "user_123")
+		c.Next()
+	})
+	router.POST("/uploads", uploadHandler.InitUpload)
+	router.GET("/uploads/:id", uploadHandler.GetUploadStatus)
+	router.PUT("/uploads/:id/chunks/:index", uploadHandler.UploadChunk)
+	router.POST("/uploads/:id/complete", uploadHandler.CompleteUpload)
+	router.DELETE("/uploads/:id", uploadHandler.AbortUpload)
+
+	content := bytes.Repeat([]byte("x"), service.MinChunkSize+100)
+
+	// Init upload
+	initBody, _ := json.Marshal(InitUploadRequest{
+		TeamID:    "team_123",
+		Title:     "Scan",
+		FileName:  "scan.pdf",
+		MimeType:  "application/pdf",
+		TotalSize: int64(len(content)),
+		ChunkSize: service.MinChunkSize,
+	})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/uploads", bytes.NewReader(initBody)))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Uploads to a team the user is not a member of are rejected
+	otherTeamBody, _ := json.Marshal(InitUploadRequest{
+		TeamID:    "team_456",
+		Title:     "Scan",
+		FileName:  "scan.pdf",
+		MimeType:  "application/pdf",
+		TotalSize: int64(len(content)),
+		ChunkSize: service.MinChunkSize,
+	})
+	forbidden := httptest.NewRecorder()
+	router.ServeHTTP(forbidden, httptest.NewRequest("POST", "/uploads", bytes.NewReader(otherTeamBody)))
+	assert.Equal(t, http.StatusForbidden, forbidden.Code)
+
+	var session domain.UploadSession
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &session))
+	assert.Equal(t, 2, session.TotalChunks)
+
+	putChunk := func(index int, data []byte, checksum string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/uploads/"+session.ID+"/chunks/"+strconv.Itoa(index), bytes.NewReader(data))
+		if checksum != "" {
+			req.Header.Set("X-Chunk-Checksum", checksum)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	sum := func(data []byte) string {
+		s := sha256.Sum256(data)
+		return hex.EncodeToString(s[:])
+	}
+
+	t.Run("UploadChunkMissingChecksum", func(t *testing.T) {
+		w := putChunk(0, content[:service.MinChunkSize], "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("UploadChunkBadChecksum", func(t *testing.T) {
+		w := putChunk(0, content[:service.MinChunkSize], sum([]byte("other")))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("UploadAndComplete", func(t *testing.T) {
+		w := putChunk(0, content[:service.MinChunkSize], sum(content[:service.MinChunkSize]))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/uploads/"+session.ID, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		var status service.UploadStatus
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		assert.Equal(t, []int{1}, status.MissingChunks)
+
+		w = putChunk(1, content[service.MinChunkSize:], sum(content[service.MinChunkSize:]))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/uploads/"+session.ID+"/complete", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		var document domain.Document
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &document))
+		assert.Equal(t, int64(len(content)), document.FileSize)
+		assert.Equal(t, "user_123", document.OwnerID)
+	})
+
+	t.Run("SessionNotFound", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("DELETE", "/uploads/missing", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"cdk-office/internal/document/service"
+	"cdk-office/pkg/config"
 	"github.com/gin-gonic/gin"
 )
 
@@ -22,10 +23,14 @@ type VersionHandler struct {
 }
 
 // NewVersionHandler creates a new instance of VersionHandler
-func NewVersionHandler() *VersionHandler {
-	return &VersionHandler{
-		versionService: service.NewVersionService(),
+func NewVersionHandler(cfg *config.Config) (*VersionHandler, error) {
+	versionService, err := service.NewVersionService(cfg)
+	if err != nil {
+		return nil, err
 	}
+	return &VersionHandler{
+		versionService: versionService,
+	}, nil
 }
 
 // CreateVersionRequest represents the request for creating a document version
@@ -10,6 +10,7 @@ import (
 
 	"cdk-office/internal/document/domain"
 	"cdk-office/internal/shared/testutils"
+	"cdk-office/pkg/config"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -60,7 +61,10 @@ func (m *MockVersionService) RestoreVersion(ctx context.Context, versionID strin
 
 // TestNewVersionHandler tests the NewVersionHandler function
 func TestNewVersionHandler(t *testing.T) {
-	handler := NewVersionHandler()
+	cfg := config.Default()
+	cfg.Storage.LocalPath = t.TempDir()
+	handler, err := NewVersionHandler(cfg)
+	assert.NoError(t, err)
 	assert.NotNil(t, handler)
 	assert.NotNil(t, handler.versionService)
 }
@@ -0,0 +1,91 @@
+package search
+
+import (
+	"html"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// highlightStart and highlightEnd wrap matched terms in snippets
+	highlightStart = "<mark>"
+	highlightEnd   = "</mark>"
+	// snippetLength is the approximate length of a snippet in bytes
+	snippetLength = 240
+	// snippetLead is how much context is kept before the first match
+	snippetLead = 60
+)
+
+// Highlight returns an HTML-escaped excerpt of text around the first matching term,
+// with every matching term wrapped in <mark> tags. If maxLength is zero the whole
+// text is returned. An empty string is returned when no term matches.
+func Highlight(text string, terms map[string]bool, maxLength int) string {
+	type span struct{ start, end int }
+
+	var matches []span
+	for _, token := range Tokenize(text) {
+		if terms[token.Term] {
+			matches = append(matches, span{token.Start, token.End})
+		}
+	}
+	if len(matches) == 0 {
+		return ""
+	}
+
+	// Choose the excerpt window
+	start, end := 0, len(text)
+	if maxLength > 0 && len(text) > maxLength {
+		start = alignRune(text, matches[0].start-snippetLead)
+		end = alignRune(text, start+maxLength)
+	}
+
+	// Merge overlapping matches (CJK bigrams overlap)
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+	var merged []span
+	for _, m := range matches {
+		if m.start < start || m.end > end {
+			continue
+		}
+		if n := len(merged); n > 0 && m.start <= merged[n-1].end {
+			if m.end > merged[n-1].end {
+				merged[n-1].end = m.end
+			}
+			continue
+		}
+		merged = append(merged, m)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, m := range merged {
+		b.WriteString(html.EscapeString(text[pos:m.start]))
+		b.WriteString(highlightStart)
+		b.WriteString(html.EscapeString(text[m.start:m.end]))
+		b.WriteString(highlightEnd)
+		pos = m.end
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// alignRune clamps a byte offset to the text and moves it back to a rune boundary
+func alignRune(text string, offset int) int {
+	if offset <= 0 {
+		return 0
+	}
+	if offset >= len(text) {
+		return len(text)
+	}
+	for offset > 0 && !utf8.RuneStart(text[offset]) {
+		offset--
+	}
+	return offset
+}
@@ -0,0 +1,347 @@
+package search
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"cdk-office/internal/document/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Field weights applied to term frequencies when indexing
+const (
+	titleWeight       = 3
+	descriptionWeight = 2
+	tagsWeight        = 2
+	bodyWeight        = 1
+)
+
+// BM25 ranking parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// InvertedIndexEngine is a portable search engine that keeps an inverted index
+// in the search_postings table and ranks matches with BM25
+type InvertedIndexEngine struct {
+	db *gorm.DB
+}
+
+// NewInvertedIndexEngine creates a new instance of InvertedIndexEngine
+func NewInvertedIndexEngine(db *gorm.DB) *InvertedIndexEngine {
+	return &InvertedIndexEngine{db: db}
+}
+
+// candidate holds the document attributes needed for filtering, ranking and facets
+type candidate struct {
+	ID        string
+	MimeType  string
+	OwnerID   string
+	Tags      string
+	CreatedAt time.Time
+	score     float64
+}
+
+// IndexDocument adds or replaces a document in the index
+func (e *InvertedIndexEngine) IndexDocument(ctx context.Context, entry *domain.SearchIndexEntry) error {
+	frequencies := make(map[string]int)
+	addTerms := func(text string, weight int) {
+		for _, token := range Tokenize(text) {
+			frequencies[token.Term] += weight
+		}
+	}
+	addTerms(entry.Title, titleWeight)
+	addTerms(entry.Description, descriptionWeight)
+	addTerms(strings.Join(ParseTags(entry.Tags), " "), tagsWeight)
+	addTerms(entry.Body, bodyWeight)
+
+	entry.Length = 0
+	postings := make([]*domain.SearchPosting, 0, len(frequencies))
+	for term, frequency := range frequencies {
+		entry.Length += frequency
+		postings = append(postings, &domain.SearchPosting{
+			Term:       term,
+			DocumentID: entry.DocumentID,
+			Frequency:  frequency,
+		})
+	}
+	entry.IndexedAt = time.Now()
+
+	return e.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(entry).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("document_id = ?", entry.DocumentID).Delete(&domain.SearchPosting{}).Error; err != nil {
+			return err
+		}
+		if len(postings) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(postings, 200).Error
+	})
+}
+
+// RemoveDocument removes a document from the index
+func (e *InvertedIndexEngine) RemoveDocument(ctx context.Context, documentID string) error {
+	return e.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ?", documentID).Delete(&domain.SearchPosting{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("document_id = ?", documentID).Delete(&domain.SearchIndexEntry{}).Error
+	})
+}
+
+// Search returns documents containing every query term, ranked by BM25
+func (e *InvertedIndexEngine) Search(ctx context.Context, query *Query) (*Result, error) {
+	query.normalize()
+	terms := Terms(query.Text)
+
+	var candidates []*candidate
+	var err error
+	if len(terms) == 0 {
+		candidates, err = e.loadCandidates(ctx, &query.Filters, nil)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		candidates, err = e.rank(ctx, terms, &query.Filters)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	facets, err := e.facets(ctx, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		Hits:   []*Hit{},
+		Total:  int64(len(candidates)),
+		Facets: facets,
+		Page:   query.Page,
+		Size:   query.Size,
+	}
+
+	// Paginate
+	offset := (query.Page - 1) * query.Size
+	if offset >= len(candidates) {
+		return result, nil
+	}
+	end := offset + query.Size
+	if end > len(candidates) {
+		end = len(candidates)
+	}
+	page := candidates[offset:end]
+
+	ids := make([]string, len(page))
+	for i, c := range page {
+		ids[i] = c.ID
+	}
+
+	var documents []*domain.Document
+	if err := e.db.WithContext(ctx).Where("id IN ?", ids).Find(&documents).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*domain.Document, len(documents))
+	for _, doc := range documents {
+		byID[doc.ID] = doc
+	}
+
+	var entries map[string]*domain.SearchIndexEntry
+	if len(terms) > 0 {
+		if entries, err = loadEntries(ctx, e.db, ids); err != nil {
+			return nil, err
+		}
+	}
+
+	set := termSet(terms)
+	for _, c := range page {
+		doc, ok := byID[c.ID]
+		if !ok {
+			continue
+		}
+		result.Hits = append(result.Hits, &Hit{
+			Document:   doc,
+			Score:      c.score,
+			Highlights: buildHighlights(doc, entries[c.ID], set),
+		})
+	}
+
+	return result, nil
+}
+
+// rank scores the documents containing every term and returns those matching the filters
+func (e *InvertedIndexEngine) rank(ctx context.Context, terms []string, filters *Filters) ([]*candidate, error) {
+	var postings []*domain.SearchPosting
+	if err := e.db.WithContext(ctx).Where("term IN ?", terms).Find(&postings).Error; err != nil {
+		return nil, err
+	}
+
+	// Group postings by document and keep documents matching every term
+	matches := make(map[string]map[string]int)
+	documentFrequency := make(map[string]int)
+	for _, p := range postings {
+		if matches[p.DocumentID] == nil {
+			matches[p.DocumentID] = make(map[string]int)
+		}
+		matches[p.DocumentID][p.Term] = p.Frequency
+		documentFrequency[p.Term]++
+	}
+	var ids []string
+	for id, found := range matches {
+		if len(found) == len(terms) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	candidates, err := e.loadCandidates(ctx, filters, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	// Collection statistics for BM25
+	var stats struct {
+		Count     int64
+		AvgLength float64
+	}
+	if err := e.db.WithContext(ctx).Model(&domain.SearchIndexEntry{}).
+		Select("COUNT(*) AS count, AVG(length) AS avg_length").Scan(&stats).Error; err != nil {
+		return nil, err
+	}
+	if stats.AvgLength <= 0 {
+		stats.AvgLength = 1
+	}
+
+	candidateIDs := make([]string, len(candidates))
+	for i, c := range candidates {
+		candidateIDs[i] = c.ID
+	}
+	lengths := make(map[string]int, len(candidates))
+	for _, chunk := range chunkStrings(candidateIDs, inClauseLimit) {
+		var rows []*domain.SearchIndexEntry
+		if err := e.db.WithContext(ctx).Select("document_id, length").Where("document_id IN ?", chunk).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			lengths[row.DocumentID] = row.Length
+		}
+	}
+
+	n := float64(stats.Count)
+	for _, c := range candidates {
+		norm := bm25K1 * (1 - bm25B + bm25B*float64(lengths[c.ID])/stats.AvgLength)
+		for term, frequency := range matches[c.ID] {
+			df := float64(documentFrequency[term])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			f := float64(frequency)
+			c.score += idf * f * (bm25K1 + 1) / (f + norm)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].CreatedAt.After(candidates[j].CreatedAt)
+	})
+
+	return candidates, nil
+}
+
+// loadCandidates returns the documents matching the filters, newest first.
+// When ids is not nil only those documents are considered.
+func (e *InvertedIndexEngine) loadCandidates(ctx context.Context, filters *Filters, ids []string) ([]*candidate, error) {
+	selectCandidates := func(chunk []string) ([]*candidate, error) {
+		tx := e.db.WithContext(ctx).Table("documents AS d").
+			Select("d.id, d.mime_type, d.owner_id, d.tags, d.created_at")
+		if chunk != nil {
+			tx = tx.Where("d.id IN ?", chunk)
+		}
+		var rows []*candidate
+		err := applyFilters(tx, filters).Order("d.created_at DESC").Scan(&rows).Error
+		return rows, err
+	}
+
+	var rows []*candidate
+	if ids == nil {
+		var err error
+		if rows, err = selectCandidates(nil); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, chunk := range chunkStrings(ids, inClauseLimit) {
+			chunkRows, err := selectCandidates(chunk)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, chunkRows...)
+		}
+	}
+
+	if len(filters.Tags) == 0 {
+		return rows, nil
+	}
+	filtered := rows[:0]
+	for _, row := range rows {
+		if hasAllTags(row.Tags, filters.Tags) {
+			filtered = append(filtered, row)
+		}
+	}
+	return filtered, nil
+}
+
+// facets counts the facet values over all matching documents
+func (e *InvertedIndexEngine) facets(ctx context.Context, candidates []*candidate) (map[string][]FacetValue, error) {
+	mimeTypes := make(map[string]int64)
+	owners := make(map[string]int64)
+	tags := make(map[string]int64)
+	ids := make([]string, len(candidates))
+	for i, c := range candidates {
+		ids[i] = c.ID
+		mimeTypes[c.MimeType]++
+		owners[c.OwnerID]++
+		seen := make(map[string]bool)
+		for _, tag := range ParseTags(c.Tags) {
+			if !seen[tag] {
+				seen[tag] = true
+				tags[tag]++
+			}
+		}
+	}
+
+	categories := make(map[string]int64)
+	for _, chunk := range chunkStrings(ids, inClauseLimit) {
+		var relations []*domain.DocumentCategoryRelation
+		if err := e.db.WithContext(ctx).Select("document_id, category_id").
+			Where("document_id IN ?", chunk).Find(&relations).Error; err != nil {
+			return nil, err
+		}
+		seen := make(map[string]bool)
+		for _, r := range relations {
+			key := r.DocumentID + "\x00" + r.CategoryID
+			if !seen[key] {
+				seen[key] = true
+				categories[r.CategoryID]++
+			}
+		}
+	}
+
+	return map[string][]FacetValue{
+		FacetMimeType: topFacetValues(mimeTypes),
+		FacetOwner:    topFacetValues(owners),
+		FacetTags:     topFacetValues(tags),
+		FacetCategory: topFacetValues(categories),
+	}, nil
+}
@@ -0,0 +1,136 @@
+package search
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cdk-office/internal/document/domain"
+	"cdk-office/internal/shared/testutils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// indexTestDocument creates a document and indexes it with the given body
+func indexTestDocument(t *testing.T, engine *InvertedIndexEngine, doc *domain.Document, body string) {
+	t.Helper()
+	require.NoError(t, engine.db.Create(doc).Error)
+	require.NoError(t, engine.IndexDocument(context.Background(), &domain.SearchIndexEntry{
+		DocumentID:  doc.ID,
+		Title:       doc.Title,
+		Description: doc.Description,
+		Tags:        doc.Tags,
+		Body:        body,
+	}))
+}
+
+// TestInvertedIndexEngine tests indexing, ranking, highlighting, filters and facets
+func TestInvertedIndexEngine(t *testing.T) {
+	db := testutils.SetupTestDB()
+	engine := NewInvertedIndexEngine(db)
+	ctx := context.Background()
+
+	base := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	indexTestDocument(t, engine, &domain.Document{
+		ID: "doc_1", Title: "Employment contract", MimeType: "application/pdf", OwnerID: "user_1",
+		TeamID: "team_1", Tags: `["hr","legal"]`, CreatedAt: base,
+	}, "This employment contract covers salary and annual leave.")
+	indexTestDocument(t, engine, &domain.Document{
+		ID: "doc_2", Title: "Meeting notes", MimeType: "text/plain", OwnerID: "user_2",
+		TeamID: "team_1", Tags: `["notes"]`, CreatedAt: base.AddDate(0, 0, 10),
+	}, "We discussed the supplier contract renewal and the salary review.")
+	indexTestDocument(t, engine, &domain.Document{
+		ID: "doc_3", Title: "劳动合同模板", MimeType: "application/pdf", OwnerID: "user_1",
+		TeamID: "team_2", Tags: "hr", CreatedAt: base.AddDate(0, 0, 20),
+	}, "本合同由甲乙双方签署。")
+	require.NoError(t, db.Create(&domain.DocumentCategoryRelation{ID: "rel_1", DocumentID: "doc_1", CategoryID: "cat_legal"}).Error)
+
+	t.Run("RanksTitleMatchesHigher", func(t *testing.T) {
+		result, err := engine.Search(ctx, &Query{Text: "contract"})
+		require.NoError(t, err)
+		require.Equal(t, int64(2), result.Total)
+		assert.Equal(t, "doc_1", result.Hits[0].Document.ID)
+		assert.Equal(t, "doc_2", result.Hits[1].Document.ID)
+		assert.Greater(t, result.Hits[0].Score, result.Hits[1].Score)
+		assert.Equal(t, "Employment <mark>contract</mark>", result.Hits[0].Highlights["title"])
+		assert.Contains(t, result.Hits[1].Highlights["body"], "supplier <mark>contract</mark> renewal")
+	})
+
+	t.Run("MatchesAllTerms", func(t *testing.T) {
+		result, err := engine.Search(ctx, &Query{Text: "salary leave"})
+		require.NoError(t, err)
+		require.Equal(t, int64(1), result.Total)
+		assert.Equal(t, "doc_1", result.Hits[0].Document.ID)
+	})
+
+	t.Run("CJK", func(t *testing.T) {
+		result, err := engine.Search(ctx, &Query{Text: "劳动合同"})
+		require.NoError(t, err)
+		require.Equal(t, int64(1), result.Total)
+		assert.Equal(t, "doc_3", result.Hits[0].Document.ID)
+		assert.Equal(t, "<mark>劳动合同</mark>模板", result.Hits[0].Highlights["title"])
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		result, err := engine.Search(ctx, &Query{Text: "contract", Filters: Filters{MimeTypes: []string{"text/plain"}}})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), result.Total)
+
+		result, err = engine.Search(ctx, &Query{Filters: Filters{Tags: []string{"hr"}}})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), result.Total)
+
+		result, err = engine.Search(ctx, &Query{Filters: Filters{CategoryIDs: []string{"cat_legal"}}})
+		require.NoError(t, err)
+		require.Equal(t, int64(1), result.Total)
+		assert.Equal(t, "doc_1", result.Hits[0].Document.ID)
+
+		from := base.AddDate(0, 0, 5)
+		to := base.AddDate(0, 0, 15)
+		result, err = engine.Search(ctx, &Query{Filters: Filters{CreatedFrom: &from, CreatedTo: &to}})
+		require.NoError(t, err)
+		require.Equal(t, int64(1), result.Total)
+		assert.Equal(t, "doc_2", result.Hits[0].Document.ID)
+	})
+
+	t.Run("Facets", func(t *testing.T) {
+		result, err := engine.Search(ctx, &Query{Filters: Filters{TeamID: "team_1"}})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), result.Total)
+		assert.ElementsMatch(t, []FacetValue{{Value: "application/pdf", Count: 1}, {Value: "text/plain", Count: 1}}, result.Facets[FacetMimeType])
+		assert.ElementsMatch(t, []FacetValue{{Value: "hr", Count: 1}, {Value: "legal", Count: 1}, {Value: "notes", Count: 1}}, result.Facets[FacetTags])
+		assert.Equal(t, []FacetValue{{Value: "cat_legal", Count: 1}}, result.Facets[FacetCategory])
+		assert.Len(t, result.Facets[FacetOwner], 2)
+	})
+
+	t.Run("EmptyQueryListsNewestFirst", func(t *testing.T) {
+		result, err := engine.Search(ctx, &Query{Page: 1, Size: 2})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), result.Total)
+		require.Len(t, result.Hits, 2)
+		assert.Equal(t, "doc_3", result.Hits[0].Document.ID)
+
+		result, err = engine.Search(ctx, &Query{Page: 2, Size: 2})
+		require.NoError(t, err)
+		require.Len(t, result.Hits, 1)
+		assert.Equal(t, "doc_1", result.Hits[0].Document.ID)
+	})
+
+	t.Run("ReindexAndRemove", func(t *testing.T) {
+		require.NoError(t, engine.IndexDocument(ctx, &domain.SearchIndexEntry{DocumentID: "doc_2", Title: "Meeting notes", Body: "Budget planning"}))
+		result, err := engine.Search(ctx, &Query{Text: "contract"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), result.Total)
+
+		require.NoError(t, engine.RemoveDocument(ctx, "doc_1"))
+		result, err = engine.Search(ctx, &Query{Text: "contract"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), result.Total)
+		assert.NotNil(t, result.Hits)
+
+		var postings int64
+		db.Model(&domain.SearchPosting{}).Where("document_id = ?", "doc_1").Count(&postings)
+		assert.Zero(t, postings)
+	})
+}
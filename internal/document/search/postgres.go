@@ -0,0 +1,192 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"cdk-office/internal/document/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostgresEngine implements full-text search with Postgres tsvector columns.
+// Text is tokenized in Go before it reaches Postgres so CJK content is indexed
+// as bigrams, matching the embedded engine.
+type PostgresEngine struct {
+	db         *gorm.DB
+	schemaOnce sync.Once
+	schemaErr  error
+}
+
+// NewPostgresEngine creates a new instance of PostgresEngine
+func NewPostgresEngine(db *gorm.DB) *PostgresEngine {
+	return &PostgresEngine{db: db}
+}
+
+// ensureSchema adds the tsvector column and its GIN index to the index table
+func (e *PostgresEngine) ensureSchema(ctx context.Context) error {
+	e.schemaOnce.Do(func() {
+		db := e.db.WithContext(ctx)
+		if e.schemaErr = db.Exec("ALTER TABLE search_index_entries ADD COLUMN IF NOT EXISTS search_vector tsvector").Error; e.schemaErr != nil {
+			return
+		}
+		e.schemaErr = db.Exec("CREATE INDEX IF NOT EXISTS idx_search_index_entries_vector ON search_index_entries USING GIN (search_vector)").Error
+	})
+	return e.schemaErr
+}
+
+// IndexDocument adds or replaces a document in the index
+func (e *PostgresEngine) IndexDocument(ctx context.Context, entry *domain.SearchIndexEntry) error {
+	if err := e.ensureSchema(ctx); err != nil {
+		return err
+	}
+
+	entry.Length = len(Tokenize(entry.Title)) + len(Tokenize(entry.Description)) + len(Tokenize(entry.Body))
+	entry.IndexedAt = time.Now()
+
+	return e.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(entry).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`UPDATE search_index_entries SET search_vector =
+			setweight(to_tsvector('simple', ?), 'A') ||
+			setweight(to_tsvector('simple', ?), 'B') ||
+			setweight(to_tsvector('simple', ?), 'D')
+			WHERE document_id = ?`,
+			termText(entry.Title),
+			termText(entry.Description+" "+strings.Join(ParseTags(entry.Tags), " ")),
+			termText(entry.Body),
+			entry.DocumentID,
+		).Error
+	})
+}
+
+// RemoveDocument removes a document from the index
+func (e *PostgresEngine) RemoveDocument(ctx context.Context, documentID string) error {
+	return e.db.WithContext(ctx).Where("document_id = ?", documentID).Delete(&domain.SearchIndexEntry{}).Error
+}
+
+// Search returns documents matching every query term, ranked by ts_rank_cd
+func (e *PostgresEngine) Search(ctx context.Context, query *Query) (*Result, error) {
+	if err := e.ensureSchema(ctx); err != nil {
+		return nil, err
+	}
+	query.normalize()
+	terms := Terms(query.Text)
+	tsQuery := strings.Join(terms, " ")
+
+	var tagsJSON string
+	if len(query.Tags) > 0 {
+		data, err := json.Marshal(query.Tags)
+		if err != nil {
+			return nil, err
+		}
+		tagsJSON = string(data)
+	}
+
+	base := func() *gorm.DB {
+		tx := e.db.WithContext(ctx).Table("documents AS d")
+		if len(terms) > 0 {
+			tx = tx.Joins("JOIN search_index_entries AS e ON e.document_id = d.id").
+				Where("e.search_vector @@ plainto_tsquery('simple', ?)", tsQuery)
+		}
+		if tagsJSON != "" {
+			tx = tx.Where("d.tags @> CAST(? AS jsonb)", tagsJSON)
+		}
+		return applyFilters(tx, &query.Filters)
+	}
+
+	var total int64
+	if err := base().Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	type rankedDocument struct {
+		domain.Document
+		Score float64
+	}
+	var rows []*rankedDocument
+	tx := base()
+	if len(terms) > 0 {
+		tx = tx.Select("d.*, ts_rank_cd(e.search_vector, plainto_tsquery('simple', ?)) AS score", tsQuery).
+			Order("score DESC")
+	} else {
+		tx = tx.Select("d.*, 0 AS score")
+	}
+	if err := tx.Order("d.created_at DESC").
+		Offset((query.Page - 1) * query.Size).Limit(query.Size).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	facets := make(map[string][]FacetValue)
+	facetQueries := map[string]*gorm.DB{
+		FacetMimeType: base().Select("d.mime_type AS value, COUNT(*) AS count").Group("d.mime_type"),
+		FacetOwner:    base().Select("d.owner_id AS value, COUNT(*) AS count").Group("d.owner_id"),
+		FacetCategory: base().Joins("JOIN document_category_relations AS r ON r.document_id = d.id").
+			Select("r.category_id AS value, COUNT(DISTINCT d.id) AS count").Group("r.category_id"),
+		FacetTags: base().Joins("CROSS JOIN LATERAL jsonb_array_elements_text(CASE WHEN jsonb_typeof(d.tags) = 'array' THEN d.tags ELSE '[]'::jsonb END) AS t(value)").
+			Select("t.value AS value, COUNT(*) AS count").Group("t.value"),
+	}
+	for name, facetQuery := range facetQueries {
+		var values []FacetValue
+		if err := facetQuery.Order("count DESC, value").Limit(maxFacetValues).Scan(&values).Error; err != nil {
+			return nil, err
+		}
+		facets[name] = topFacetValues(facetValueCounts(values))
+	}
+
+	result := &Result{
+		Hits:   []*Hit{},
+		Total:  total,
+		Facets: facets,
+		Page:   query.Page,
+		Size:   query.Size,
+	}
+
+	var entries map[string]*domain.SearchIndexEntry
+	if len(terms) > 0 && len(rows) > 0 {
+		ids := make([]string, len(rows))
+		for i, row := range rows {
+			ids[i] = row.ID
+		}
+		var err error
+		if entries, err = loadEntries(ctx, e.db, ids); err != nil {
+			return nil, err
+		}
+	}
+
+	set := termSet(terms)
+	for _, row := range rows {
+		doc := row.Document
+		result.Hits = append(result.Hits, &Hit{
+			Document:   &doc,
+			Score:      row.Score,
+			Highlights: buildHighlights(&doc, entries[doc.ID], set),
+		})
+	}
+
+	return result, nil
+}
+
+// termText returns the normalised terms of a text separated by spaces
+func termText(text string) string {
+	tokens := Tokenize(text)
+	terms := make([]string, len(tokens))
+	for i, token := range tokens {
+		terms[i] = token.Term
+	}
+	return strings.Join(terms, " ")
+}
+
+// facetValueCounts converts facet values back to counts so they can be sorted consistently
+func facetValueCounts(values []FacetValue) map[string]int64 {
+	counts := make(map[string]int64, len(values))
+	for _, v := range values {
+		counts[v.Value] = v.Count
+	}
+	return counts
+}
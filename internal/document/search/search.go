@@ -0,0 +1,240 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"cdk-office/internal/document/domain"
+	"gorm.io/gorm"
+)
+
+// Facet names returned in search results
+const (
+	FacetMimeType = "mime_type"
+	FacetCategory = "category"
+	FacetOwner    = "owner"
+	FacetTags     = "tags"
+)
+
+const (
+	// maxFacetValues is the number of values returned per facet
+	maxFacetValues = 20
+	// inClauseLimit bounds the number of parameters in a single IN clause
+	inClauseLimit = 500
+)
+
+// Engine defines the interface for a full-text search backend
+type Engine interface {
+	IndexDocument(ctx context.Context, entry *domain.SearchIndexEntry) error
+	RemoveDocument(ctx context.Context, documentID string) error
+	Search(ctx context.Context, query *Query) (*Result, error)
+}
+
+// NewEngine returns the search engine suited to the database: Postgres uses its
+// built-in tsvector full-text search, other databases (SQLite in development
+// and tests) use the embedded inverted index.
+func NewEngine(db *gorm.DB) Engine {
+	if db != nil && db.Dialector != nil && db.Dialector.Name() == "postgres" {
+		return NewPostgresEngine(db)
+	}
+	return NewInvertedIndexEngine(db)
+}
+
+// Filters narrows a search to documents with matching attributes
+type Filters struct {
+	TeamID      string     `json:"team_id"`
+	MimeTypes   []string   `json:"mime_types"`
+	CategoryIDs []string   `json:"category_ids"`
+	OwnerIDs    []string   `json:"owner_ids"`
+	Tags        []string   `json:"tags"`
+	CreatedFrom *time.Time `json:"created_from"`
+	CreatedTo   *time.Time `json:"created_to"`
+}
+
+// Query represents a search request
+type Query struct {
+	Text string `json:"text"`
+	Filters
+	Page int `json:"page"`
+	Size int `json:"size"`
+}
+
+// Hit represents a matching document
+type Hit struct {
+	Document   *domain.Document  `json:"document"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+// FacetValue represents the number of matching documents with a facet value
+type FacetValue struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// Result represents a page of search results
+type Result struct {
+	Hits   []*Hit                  `json:"hits"`
+	Total  int64                   `json:"total"`
+	Facets map[string][]FacetValue `json:"facets"`
+	Page   int                     `json:"page"`
+	Size   int                     `json:"size"`
+}
+
+// normalize validates the pagination parameters of a query
+func (q *Query) normalize() {
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.Size < 1 || q.Size > 100 {
+		q.Size = 10
+	}
+}
+
+// applyFilters adds the query filters to a query over "documents AS d".
+// Tag filters are applied by each engine since tag storage differs per database.
+func applyFilters(tx *gorm.DB, f *Filters) *gorm.DB {
+	if f.TeamID != "" {
+		tx = tx.Where("d.team_id = ?", f.TeamID)
+	}
+	if len(f.MimeTypes) > 0 {
+		tx = tx.Where("d.mime_type IN ?", f.MimeTypes)
+	}
+	if len(f.OwnerIDs) > 0 {
+		tx = tx.Where("d.owner_id IN ?", f.OwnerIDs)
+	}
+	if len(f.CategoryIDs) > 0 {
+		tx = tx.Where("d.id IN (SELECT document_id FROM document_category_relations WHERE category_id IN ?)", f.CategoryIDs)
+	}
+	if f.CreatedFrom != nil {
+		tx = tx.Where("d.created_at >= ?", *f.CreatedFrom)
+	}
+	if f.CreatedTo != nil {
+		tx = tx.Where("d.created_at <= ?", *f.CreatedTo)
+	}
+	return tx
+}
+
+// ParseTags parses a document's tags, stored either as a JSON array or as a comma-separated list
+func ParseTags(tags string) []string {
+	tags = strings.TrimSpace(tags)
+	if tags == "" {
+		return nil
+	}
+
+	var list []string
+	if strings.HasPrefix(tags, "[") && json.Unmarshal([]byte(tags), &list) == nil {
+		return list
+	}
+
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			list = append(list, tag)
+		}
+	}
+	return list
+}
+
+// hasAllTags reports whether a document's tags contain every wanted tag
+func hasAllTags(tags string, wanted []string) bool {
+	if len(wanted) == 0 {
+		return true
+	}
+	have := make(map[string]bool)
+	for _, tag := range ParseTags(tags) {
+		have[tag] = true
+	}
+	for _, tag := range wanted {
+		if !have[tag] {
+			return false
+		}
+	}
+	return true
+}
+
+// topFacetValues converts counts into facet values sorted by count and value
+func topFacetValues(counts map[string]int64) []FacetValue {
+	values := make([]FacetValue, 0, len(counts))
+	for value, count := range counts {
+		if value == "" {
+			continue
+		}
+		values = append(values, FacetValue{Value: value, Count: count})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+	if len(values) > maxFacetValues {
+		values = values[:maxFacetValues]
+	}
+	return values
+}
+
+// termSet builds a lookup set from query terms
+func termSet(terms []string) map[string]bool {
+	set := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		set[term] = true
+	}
+	return set
+}
+
+// chunkStrings splits a slice into chunks to keep IN clauses within database limits
+func chunkStrings(values []string, size int) [][]string {
+	var chunks [][]string
+	for len(values) > size {
+		chunks = append(chunks, values[:size])
+		values = values[size:]
+	}
+	if len(values) > 0 {
+		chunks = append(chunks, values)
+	}
+	return chunks
+}
+
+// loadEntries loads the index entries of the given documents keyed by document ID
+func loadEntries(ctx context.Context, db *gorm.DB, ids []string) (map[string]*domain.SearchIndexEntry, error) {
+	entries := make(map[string]*domain.SearchIndexEntry, len(ids))
+	for _, chunk := range chunkStrings(ids, inClauseLimit) {
+		var rows []*domain.SearchIndexEntry
+		if err := db.WithContext(ctx).Where("document_id IN ?", chunk).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			entries[row.DocumentID] = row
+		}
+	}
+	return entries, nil
+}
+
+// buildHighlights returns the highlighted title and body snippet of a hit
+func buildHighlights(doc *domain.Document, entry *domain.SearchIndexEntry, terms map[string]bool) map[string]string {
+	if len(terms) == 0 {
+		return nil
+	}
+
+	highlights := make(map[string]string)
+	if title := Highlight(doc.Title, terms, 0); title != "" {
+		highlights["title"] = title
+	}
+	body := ""
+	if entry != nil {
+		body = Highlight(entry.Body, terms, snippetLength)
+	}
+	if body == "" {
+		body = Highlight(doc.Description, terms, snippetLength)
+	}
+	if body != "" {
+		highlights["body"] = body
+	}
+	if len(highlights) == 0 {
+		return nil
+	}
+	return highlights
+}
@@ -0,0 +1,89 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxTermLength is the longest term (in bytes) that is indexed
+const maxTermLength = 100
+
+// Token represents a normalised term and its byte offsets in the source text
+type Token struct {
+	Term  string
+	Start int
+	End   int
+}
+
+// Tokenize splits text into normalised index terms. Words made of letters and
+// digits are lowercased; runs of CJK characters, which are not separated by
+// spaces, are split into overlapping bigrams.
+func Tokenize(text string) []Token {
+	var tokens []Token
+
+	i := 0
+	for i < len(text) {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case isCJK(r):
+			// Collect the whole CJK run with rune offsets
+			var offsets []int
+			j := i
+			for j < len(text) {
+				r2, size2 := utf8.DecodeRuneInString(text[j:])
+				if !isCJK(r2) {
+					break
+				}
+				offsets = append(offsets, j)
+				j += size2
+			}
+			offsets = append(offsets, j)
+
+			if len(offsets) == 2 {
+				tokens = append(tokens, Token{Term: text[i:j], Start: i, End: j})
+			}
+			for k := 0; k+2 < len(offsets); k++ {
+				tokens = append(tokens, Token{Term: text[offsets[k]:offsets[k+2]], Start: offsets[k], End: offsets[k+2]})
+			}
+			i = j
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			j := i
+			for j < len(text) {
+				r2, size2 := utf8.DecodeRuneInString(text[j:])
+				if isCJK(r2) || !(unicode.IsLetter(r2) || unicode.IsDigit(r2)) {
+					break
+				}
+				j += size2
+			}
+			term := strings.ToLower(text[i:j])
+			if len(term) <= maxTermLength {
+				tokens = append(tokens, Token{Term: term, Start: i, End: j})
+			}
+			i = j
+		default:
+			i += size
+		}
+	}
+
+	return tokens
+}
+
+// Terms returns the distinct terms of a text in order of first occurrence
+func Terms(text string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, token := range Tokenize(text) {
+		if !seen[token.Term] {
+			seen[token.Term] = true
+			terms = append(terms, token.Term)
+		}
+	}
+	return terms
+}
+
+// isCJK reports whether r is a Han, Hiragana, Katakana or Hangul character
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
@@ -0,0 +1,50 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTokenize tests splitting text into terms
+func TestTokenize(t *testing.T) {
+	tokens := Tokenize("Hello, World! v2.0")
+	terms := make([]string, len(tokens))
+	for i, token := range tokens {
+		terms[i] = token.Term
+	}
+	assert.Equal(t, []string{"hello", "world", "v2", "0"}, terms)
+	assert.Equal(t, "World", "Hello, World! v2.0"[tokens[1].Start:tokens[1].End])
+
+	// CJK runs are split into bigrams
+	assert.Equal(t, []string{"合同", "同管", "管理", "pdf"}, Terms("合同管理 PDF"))
+	assert.Equal(t, []string{"表"}, Terms("表"))
+	assert.Empty(t, Terms("  ,.;  "))
+}
+
+// TestHighlight tests snippet generation
+func TestHighlight(t *testing.T) {
+	terms := termSet([]string{"contract"})
+
+	assert.Equal(t, "Sales <mark>Contract</mark> &amp; terms", Highlight("Sales Contract & terms", terms, 0))
+	assert.Equal(t, "", Highlight("No match here", terms, 0))
+
+	long := "Introduction. " +
+		"This paragraph is padding that goes on for a while before the interesting part arrives. " +
+		"The contract is signed by both parties. " +
+		"More padding follows after the match so the snippet has to be cut at the end as well as the start of the text."
+	snippet := Highlight(long, terms, 80)
+	assert.Contains(t, snippet, "<mark>contract</mark>")
+	assert.True(t, len(snippet) < len(long))
+	assert.Contains(t, snippet, "…")
+
+	// Overlapping CJK bigrams are merged into one highlight
+	assert.Equal(t, "签署<mark>劳动合同</mark>", Highlight("签署劳动合同", termSet(Terms("劳动合同")), 0))
+}
+
+// TestParseTags tests parsing JSON and comma-separated tags
+func TestParseTags(t *testing.T) {
+	assert.Equal(t, []string{"a", "b c"}, ParseTags(`["a","b c"]`))
+	assert.Equal(t, []string{"a", "b"}, ParseTags("a, b,"))
+	assert.Nil(t, ParseTags(""))
+}
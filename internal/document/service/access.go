@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"cdk-office/internal/document/domain"
+	employeedomain "cdk-office/internal/employee/domain"
+	"cdk-office/internal/shared/database"
+	"cdk-office/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// adminRole is the role allowed to read the documents of every team
+const adminRole = "admin"
+
+// DocumentAccessInterface defines the interface for document read access checks
+type DocumentAccessInterface interface {
+	CanReadTeam(ctx context.Context, userID, role, teamID string) (bool, error)
+	ReadableDocuments(ctx context.Context, userID, role string, documentIDs []string) (map[string]*domain.Document, error)
+}
+
+// DocumentAccess implements the DocumentAccessInterface. A user may read the
+// documents they own, the documents of the teams they are an active employee
+// of, and, as an admin, every document.
+type DocumentAccess struct {
+	db *gorm.DB
+}
+
+// NewDocumentAccess creates a new instance of DocumentAccess
+func NewDocumentAccess() *DocumentAccess {
+	return NewDocumentAccessWithDB(database.GetDB())
+}
+
+// NewDocumentAccessWithDB creates a new instance of DocumentAccess with a specific database connection
+func NewDocumentAccessWithDB(db *gorm.DB) *DocumentAccess {
+	return &DocumentAccess{
+		db: db,
+	}
+}
+
+// CanReadTeam reports whether a user may read every document of a team, which
+// only its active employees and admins may. Owning some of its documents is not
+// enough.
+func (a *DocumentAccess) CanReadTeam(ctx context.Context, userID, role, teamID string) (bool, error) {
+	if role == adminRole {
+		return true, nil
+	}
+
+	member, err := isTeamMember(ctx, a.db, userID, teamID)
+	if err != nil {
+		return false, errors.New("failed to check document access")
+	}
+	return member, nil
+}
+
+// ReadableDocuments returns, by ID, the documents among documentIDs a user may read
+func (a *DocumentAccess) ReadableDocuments(ctx context.Context, userID, role string, documentIDs []string) (map[string]*domain.Document, error) {
+	readable := make(map[string]*domain.Document, len(documentIDs))
+	if len(documentIDs) == 0 {
+		return readable, nil
+	}
+
+	query := a.db.WithContext(ctx).Where("id IN ?", documentIDs)
+	if role != adminRole {
+		teams := a.db.Model(&employeedomain.Employee{}).Select("team_id").Where("user_id = ? AND status = ?", userID, "active")
+		query = query.Where("owner_id = ? OR team_id IN (?)", userID, teams)
+	}
+	var documents []*domain.Document
+	if err := query.Find(&documents).Error; err != nil {
+		logger.Error("failed to check document access", "error", err)
+		return nil, errors.New("failed to check document access")
+	}
+	for _, document := range documents {
+		readable[document.ID] = document
+	}
+	return readable, nil
+}
+
+// isTeamMember reports whether a user is an active employee of a team
+func isTeamMember(ctx context.Context, db *gorm.DB, userID, teamID string) (bool, error) {
+	var count int64
+	if err := db.WithContext(ctx).Model(&employeedomain.Employee{}).
+		Where("user_id = ? AND team_id = ? AND status = ?", userID, teamID, "active").
+		Count(&count).Error; err != nil {
+		logger.Error("failed to check team membership", "error", err)
+		return false, err
+	}
+	return count > 0, nil
+}
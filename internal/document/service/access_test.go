@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"cdk-office/internal/document/domain"
+	employeedomain "cdk-office/internal/employee/domain"
+	"cdk-office/internal/shared/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDocumentAccess tests that users read their own documents and those of the
+// teams they are an active employee of, and that owning a document of a team
+// does not open the rest of the team
+func TestDocumentAccess(t *testing.T) {
+	ctx := context.Background()
+	db := testutils.SetupTestDB()
+	access := NewDocumentAccessWithDB(db)
+
+	require.NoError(t, db.Create([]*domain.Document{
+		{ID: "doc_1", Title: "Handbook", OwnerID: "user_2", TeamID: "team_1"},
+		{ID: "doc_2", Title: "Minutes", OwnerID: "user_2", TeamID: "team_2"},
+		{ID: "doc_3", Title: "Notes", OwnerID: "user_1", TeamID: "team_3"},
+	}).Error)
+	require.NoError(t, db.Create([]*employeedomain.Employee{
+		{ID: "emp_1", UserID: "user_1", TeamID: "team_1", EmployeeID: "E001", Status: "active"},
+		{ID: "emp_2", UserID: "user_1", TeamID: "team_2", EmployeeID: "E002", Status: "terminated"},
+	}).Error)
+
+	readable, err := access.ReadableDocuments(ctx, "user_1", "user", []string{"doc_1", "doc_2", "doc_3"})
+	require.NoError(t, err)
+	assert.Contains(t, readable, "doc_1")
+	assert.NotContains(t, readable, "doc_2")
+	assert.Contains(t, readable, "doc_3")
+
+	readable, err = access.ReadableDocuments(ctx, "admin_1", "admin", []string{"doc_1", "doc_2", "doc_3"})
+	require.NoError(t, err)
+	assert.Len(t, readable, 3)
+
+	for teamID, expected := range map[string]bool{"team_1": true, "team_2": false, "team_3": false, "team_4": false} {
+		allowed, err := access.CanReadTeam(ctx, "user_1", "user", teamID)
+		require.NoError(t, err)
+		assert.Equal(t, expected, allowed, teamID)
+	}
+	allowed, err := access.CanReadTeam(ctx, "admin_1", "admin", "team_4")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+}
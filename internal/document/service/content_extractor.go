@@ -3,7 +3,7 @@ package service
 import (
 	"cdk-office/internal/document/domain"
 	"cdk-office/pkg/logger"
-	"bytes"
+	"context"
 	"fmt"
 	"github.com/EndFirstCorp/doc2txt"
 	"github.com/dslipak/pdf"
@@ -15,6 +15,9 @@ import (
 	"strings"
 )
 
+// PageBreak separates the pages of the text extracted from paginated documents
+const PageBreak = "\f"
+
 // ContentExtractorInterface defines the interface for content extraction service
 type ContentExtractorInterface interface {
 	ExtractContent(document *domain.Document) (string, error)
@@ -22,7 +25,8 @@ type ContentExtractorInterface interface {
 
 // ContentExtractor implements the ContentExtractorInterface
 type ContentExtractor struct {
-	storagePath string
+	storagePath    string
+	storageService StorageServiceInterface
 }
 
 // NewContentExtractor creates a new instance of ContentExtractor
@@ -32,19 +36,22 @@ func NewContentExtractor(storagePath string) *ContentExtractor {
 	}
 }
 
+// NewContentExtractorWithStorage creates a new instance of ContentExtractor that
+// reads document files through the storage service
+func NewContentExtractorWithStorage(storageService StorageServiceInterface) *ContentExtractor {
+	return &ContentExtractor{
+		storageService: storageService,
+	}
+}
+
 // ExtractContent extracts content from a document
 func (ce *ContentExtractor) ExtractContent(document *domain.Document) (string, error) {
 	// Determine file path
-	filePath := document.FilePath
-	if !filepath.IsAbs(filePath) {
-		filePath = filepath.Join(ce.storagePath, filePath)
-	}
-
-	// Check if file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		logger.Error("file not found", "file_path", filePath)
-		return "", fmt.Errorf("file not found: %s", filePath)
+	filePath, cleanup, err := resolveDocumentFile(ce.storageService, ce.storagePath, document)
+	if err != nil {
+		return "", err
 	}
+	defer cleanup()
 
 	// Extract content based on file type
 	switch strings.ToLower(document.MimeType) {
@@ -125,18 +132,27 @@ func (ce *ContentExtractor) extractPDFContent(filePath string) (string, error) {
 	}
 	// Note: pdf.Open doesn't return a file handle that needs to be closed in this library
 
-	// Extract plain text content from the PDF
-	b, err := r.GetPlainText()
-	if err != nil {
-		logger.Error("failed to extract text from PDF file", "error", err)
-		return "", fmt.Errorf("failed to extract text from PDF file: %v", err)
+	// Extract plain text content page by page, keeping the page breaks so
+	// passages can be traced back to their page
+	pages := make([]string, 0, r.NumPage())
+	fonts := make(map[string]*pdf.Font)
+	for i := 1; i <= r.NumPage(); i++ {
+		page := r.Page(i)
+		for _, name := range page.Fonts() {
+			if _, ok := fonts[name]; !ok {
+				font := page.Font(name)
+				fonts[name] = &font
+			}
+		}
+		text, err := page.GetPlainText(fonts)
+		if err != nil {
+			logger.Error("failed to extract text from PDF file", "error", err)
+			return "", fmt.Errorf("failed to extract text from PDF file: %v", err)
+		}
+		pages = append(pages, text)
 	}
 
-	// Read the extracted text into a string
-	var buf bytes.Buffer
-	buf.ReadFrom(b)
-	
-	return buf.String(), nil
+	return strings.Join(pages, PageBreak), nil
 }
 
 // extractDOCContent extracts content from a DOC file
@@ -190,4 +206,31 @@ func (ce *ContentExtractor) extractGenericContent(document *domain.Document) (st
 		document.Title, document.FileSize, document.MimeType)
 	
 	return content, nil
-}
\ No newline at end of file
+}
+
+// resolveDocumentFile returns a local path for a document's file. When a storage
+// service is available the file path is treated as a storage key, otherwise as a
+// path relative to storagePath. The returned cleanup function must always be called.
+func resolveDocumentFile(storageService StorageServiceInterface, storagePath string, document *domain.Document) (string, func(), error) {
+	if storageService != nil {
+		filePath, cleanup, err := storageService.LocalCopy(context.Background(), document.FilePath)
+		if err != nil {
+			logger.Error("failed to get file from storage", "key", document.FilePath, "error", err)
+			return "", nil, fmt.Errorf("file not found: %s", document.FilePath)
+		}
+		return filePath, cleanup, nil
+	}
+
+	filePath := document.FilePath
+	if !filepath.IsAbs(filePath) {
+		filePath = filepath.Join(storagePath, filePath)
+	}
+
+	// Check if file exists
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		logger.Error("file not found", "file_path", filePath)
+		return "", nil, fmt.Errorf("file not found: %s", filePath)
+	}
+
+	return filePath, func() {}, nil
+}
@@ -6,9 +6,11 @@ import (
 	"time"
 
 	"cdk-office/internal/document/domain"
+	"cdk-office/internal/document/storage"
 	"cdk-office/internal/shared/cache"
 	"cdk-office/internal/shared/database"
 	"cdk-office/internal/shared/utils"
+	"cdk-office/pkg/config"
 	"cdk-office/pkg/logger"
 	"gorm.io/gorm"
 )
@@ -24,14 +26,19 @@ type DocumentServiceInterface interface {
 
 // DocumentService implements the DocumentServiceInterface
 type DocumentService struct {
-	db *gorm.DB
+	db             *gorm.DB
+	storageService StorageServiceInterface
+	indexer        DocumentIndexer
 }
 
 // NewDocumentService creates a new instance of DocumentService
-func NewDocumentService() *DocumentService {
-	return &DocumentService{
-		db: database.GetDB(),
+func NewDocumentService(cfg *config.Config) (*DocumentService, error) {
+	db := database.GetDB()
+	storageService, err := NewStorageService(&cfg.Storage)
+	if err != nil {
+		return nil, err
 	}
+	return NewDocumentServiceWithIndexer(db, storageService, newDocumentIndexer(db, storageService, cfg)), nil
 }
 
 // NewDocumentServiceWithDB creates a new instance of DocumentService with a specific database connection
@@ -41,11 +48,28 @@ func NewDocumentServiceWithDB(db *gorm.DB) *DocumentService {
 	}
 }
 
+// NewDocumentServiceWithStorage creates a new instance of DocumentService with a specific database connection and storage service
+func NewDocumentServiceWithStorage(db *gorm.DB, storageService StorageServiceInterface) *DocumentService {
+	return &DocumentService{
+		db:             db,
+		storageService: storageService,
+	}
+}
+
+// NewDocumentServiceWithIndexer creates a new instance of DocumentService that keeps the search index up to date
+func NewDocumentServiceWithIndexer(db *gorm.DB, storageService StorageServiceInterface, indexer DocumentIndexer) *DocumentService {
+	return &DocumentService{
+		db:             db,
+		storageService: storageService,
+		indexer:        indexer,
+	}
+}
+
 // UploadRequest represents the request for uploading a document
 type UploadRequest struct {
 	Title       string `json:"title" binding:"required"`
 	Description string `json:"description"`
-	FilePath    string `json:"file_path" binding:"required"`
+	FilePath    string `json:"file_path" binding:"required"` // storage key returned by the storage service
 	FileSize    int64  `json:"file_size" binding:"required"`
 	MimeType    string `json:"mime_type" binding:"required"`
 	OwnerID     string `json:"owner_id" binding:"required"`
@@ -70,6 +94,7 @@ func (s *DocumentService) Upload(ctx context.Context, req *UploadRequest) (*doma
 		Description: req.Description,
 		FilePath:    req.FilePath,
 		FileSize:    req.FileSize,
+		ContentHash: storage.HashFromKey(req.FilePath),
 		MimeType:    req.MimeType,
 		OwnerID:     req.OwnerID,
 		TeamID:      req.TeamID,
@@ -87,12 +112,13 @@ func (s *DocumentService) Upload(ctx context.Context, req *UploadRequest) (*doma
 
 	// Create first version of the document
 	version := &domain.DocumentVersion{
-		ID:         utils.GenerateDocumentVersionID(),
-		DocumentID: document.ID,
-		Version:    1,
-		FilePath:   req.FilePath,
-		FileSize:   req.FileSize,
-		CreatedAt:  time.Now(),
+		ID:          utils.GenerateDocumentVersionID(),
+		DocumentID:  document.ID,
+		Version:     1,
+		FilePath:    req.FilePath,
+		FileSize:    req.FileSize,
+		ContentHash: document.ContentHash,
+		CreatedAt:   time.Now(),
 	}
 
 	if err := s.db.Create(version).Error; err != nil {
@@ -102,6 +128,8 @@ func (s *DocumentService) Upload(ctx context.Context, req *UploadRequest) (*doma
 		return nil, errors.New("failed to upload document")
 	}
 
+	s.indexDocument(ctx, document.ID)
+
 	return document, nil
 }
 
@@ -172,6 +200,8 @@ func (s *DocumentService) UpdateDocument(ctx context.Context, docID string, req
 	cacheKey := "document:" + docID
 	cache.Delete(cacheKey)
 
+	s.indexDocument(ctx, docID)
+
 	return nil
 }
 
@@ -187,6 +217,13 @@ func (s *DocumentService) DeleteDocument(ctx context.Context, docID string) erro
 		return errors.New("failed to delete document")
 	}
 
+	// Collect the storage keys used by the document before deleting its versions
+	var keys []string
+	if err := s.db.Model(&domain.DocumentVersion{}).Where("document_id = ?", docID).Distinct().Pluck("file_path", &keys).Error; err != nil {
+		logger.Error("failed to find document version files", "error", err)
+	}
+	keys = append(keys, document.FilePath)
+
 	// Delete document from database
 	if err := s.db.Delete(&document).Error; err != nil {
 		logger.Error("failed to delete document", "error", err)
@@ -200,6 +237,16 @@ func (s *DocumentService) DeleteDocument(ctx context.Context, docID string) erro
 		// For now, we'll just log it and continue
 	}
 
+	// Release stored files that are no longer referenced
+	s.releaseFiles(ctx, keys)
+
+	// Remove the document from the search index
+	if s.indexer != nil {
+		if err := s.indexer.RemoveDocument(ctx, docID); err != nil {
+			logger.Error("failed to remove document from search index", "error", err, "document_id", docID)
+		}
+	}
+
 	// Invalidate cache
 	cacheKey := "document:" + docID
 	cache.Delete(cacheKey)
@@ -242,4 +289,53 @@ func (s *DocumentService) GetDocumentVersions(ctx context.Context, docID string)
 	cache.Set(cacheKey, &versions, 10*time.Minute)
 
 	return versions, nil
-}
\ No newline at end of file
+}
+
+// indexDocument updates the search index for a document. Indexing failures are
+// logged and do not fail the document operation.
+func (s *DocumentService) indexDocument(ctx context.Context, docID string) {
+	if s.indexer == nil {
+		return
+	}
+	if err := s.indexer.IndexDocument(ctx, docID); err != nil {
+		logger.Error("failed to index document", "error", err, "document_id", docID)
+	}
+}
+
+// releaseFiles deletes stored files that are no longer referenced by any document or version
+func (s *DocumentService) releaseFiles(ctx context.Context, keys []string) {
+	releaseStoredFiles(ctx, s.db, s.storageService, keys)
+}
+
+// releaseStoredFiles deletes content-addressed files that are no longer referenced by any
+// document or version. Files may be shared, so each key is checked before deletion.
+func releaseStoredFiles(ctx context.Context, db *gorm.DB, storageService StorageServiceInterface, keys []string) {
+	if storageService == nil {
+		return
+	}
+
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		if key == "" || seen[key] || storage.HashFromKey(key) == "" {
+			continue
+		}
+		seen[key] = true
+
+		var documentRefs, versionRefs int64
+		if err := db.Model(&domain.Document{}).Where("file_path = ?", key).Count(&documentRefs).Error; err != nil {
+			logger.Error("failed to count document file references", "error", err)
+			continue
+		}
+		if err := db.Model(&domain.DocumentVersion{}).Where("file_path = ?", key).Count(&versionRefs).Error; err != nil {
+			logger.Error("failed to count version file references", "error", err)
+			continue
+		}
+		if documentRefs > 0 || versionRefs > 0 {
+			continue
+		}
+
+		if err := storageService.DeleteFile(ctx, key); err != nil {
+			logger.Error("failed to delete stored file", "key", key, "error", err)
+		}
+	}
+}
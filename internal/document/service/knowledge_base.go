@@ -1,11 +1,29 @@
 package service
 
 import (
-	"cdk-office/internal/document/domain"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+	"time"
+
 	"cdk-office/internal/dify/client"
+	"cdk-office/internal/document/domain"
+	"cdk-office/internal/shared/database"
+	"cdk-office/pkg/config"
 	"cdk-office/pkg/logger"
-	"context"
-	"fmt"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultKnowledgeTopK is the number of segments retrieved when no limit is given
+	defaultKnowledgeTopK = 5
+	// knowledgeDatasetPageSize is the page size used to look datasets up by name
+	knowledgeDatasetPageSize = 100
 )
 
 // KnowledgeBaseInterface defines the interface for knowledge base service
@@ -13,42 +31,577 @@ type KnowledgeBaseInterface interface {
 	AddToKnowledgeBase(ctx context.Context, document *domain.Document, content string) error
 }
 
-// KnowledgeBase implements the KnowledgeBaseInterface
+// KnowledgeSyncInterface defines the interface for checking and repairing the knowledge base sync
+type KnowledgeSyncInterface interface {
+	GetSyncStatus(ctx context.Context, docID string) (*domain.KnowledgeDocument, error)
+	SyncAll(ctx context.Context) (int, error)
+}
+
+// KnowledgeHit represents a segment of a document matching a knowledge base query
+type KnowledgeHit struct {
+	DocumentID string  `json:"document_id"`
+	Title      string  `json:"title"`
+	SegmentID  string  `json:"segment_id"`
+	Position   int     `json:"position"`
+	Content    string  `json:"content"`
+	Score      float64 `json:"score"`
+}
+
+// KnowledgeBase implements the KnowledgeBaseInterface by mirroring documents
+// into a Dify dataset per team. It is also a DocumentIndexer, so documents are
+// synced when their content changes and removed when they are deleted.
 type KnowledgeBase struct {
-	difyClient client.DifyClientInterface
+	db                *gorm.DB
+	datasetClient     client.DatasetClientInterface
+	storageService    StorageServiceInterface
+	contentExtractor  ContentExtractorInterface
+	indexingTechnique string
 }
 
 // NewKnowledgeBase creates a new instance of KnowledgeBase
-func NewKnowledgeBase(difyClient client.DifyClientInterface) *KnowledgeBase {
+func NewKnowledgeBase(cfg *config.Config) (*KnowledgeBase, error) {
+	storageService, err := NewStorageService(&cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	return NewKnowledgeBaseWithStorage(database.GetDB(), storageService, &cfg.Dify), nil
+}
+
+// NewKnowledgeBaseWithStorage creates a new instance of KnowledgeBase using the configured
+// Dify dataset API. Syncing is disabled when no dataset API key is configured.
+func NewKnowledgeBaseWithStorage(db *gorm.DB, storageService StorageServiceInterface, cfg *config.DifyConfig) *KnowledgeBase {
+	var datasetClient client.DatasetClientInterface
+	if cfg.DatasetAPIKey != "" {
+		datasetClient = client.NewDatasetClient(cfg.BaseURL, cfg.DatasetAPIKey)
+	}
+	kb := NewKnowledgeBaseWithClient(db, datasetClient, storageService)
+	kb.indexingTechnique = cfg.IndexingTechnique
+	return kb
+}
+
+// NewKnowledgeBaseWithClient creates a new instance of KnowledgeBase with a specific dataset client.
+// Documents are indexed with the high quality technique.
+func NewKnowledgeBaseWithClient(db *gorm.DB, datasetClient client.DatasetClientInterface, storageService StorageServiceInterface) *KnowledgeBase {
 	return &KnowledgeBase{
-		difyClient: difyClient,
+		db:                db,
+		datasetClient:     datasetClient,
+		storageService:    storageService,
+		contentExtractor:  NewContentExtractorWithStorage(storageService),
+		indexingTechnique: "high_quality",
 	}
 }
 
-// AddToKnowledgeBase adds a document to the Dify knowledge base
+// Enabled reports whether documents are synced to Dify
+func (kb *KnowledgeBase) Enabled() bool {
+	return kb.datasetClient != nil
+}
+
+// AddToKnowledgeBase syncs a document with the content extracted by the processing
+// pipeline. Without content the document file is sent for Dify to extract.
 func (kb *KnowledgeBase) AddToKnowledgeBase(ctx context.Context, document *domain.Document, content string) error {
-	// Prepare the knowledge base entry
-	_ = fmt.Sprintf("Title: %s\nContent: %s\nTags: %s\nSummary: %s", 
-		document.Title, content, document.Tags, document.Description)
-
-	// In a real implementation, you would use the Dify API to add the document to the knowledge base
-	// For now, we'll just log that this step would happen
-	logger.Error("would add document to knowledge base", "document_id", document.ID, "title", document.Title)
-	
-	// If there was a Dify API for adding to knowledge base, it might look something like this:
-	/*
-	req := &client.KnowledgeBaseRequest{
-		DocumentID: document.ID,
-		Title:      document.Title,
-		Content:    entry,
-	}
-	
-	_, err := kb.difyClient.AddToKnowledgeBase(ctx, req)
-	if err != nil {
-		logger.Error("failed to add document to knowledge base", "error", err)
-		return fmt.Errorf("failed to add document to knowledge base: %v", err)
-	}
-	*/
-	
+	if !kb.Enabled() {
+		return nil
+	}
+	return kb.sync(ctx, document, content)
+}
+
+// IndexDocument syncs a document after it was created or its content changed.
+// Metadata-only changes reach the knowledge base with the next processing run,
+// and images are left to the OCR text of the processing pipeline.
+func (kb *KnowledgeBase) IndexDocument(ctx context.Context, docID string) error {
+	if !kb.Enabled() {
+		return nil
+	}
+
+	var document domain.Document
+	if err := kb.db.Where("id = ?", docID).First(&document).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("document not found")
+		}
+		logger.Error("failed to find document", "error", err)
+		return errors.New("failed to sync document to knowledge base")
+	}
+
+	record, err := kb.findRecord(docID)
+	if err != nil {
+		return errors.New("failed to sync document to knowledge base")
+	}
+	if record != nil && record.ContentHash == document.ContentHash {
+		return nil
+	}
+
+	mimeType := strings.ToLower(document.MimeType)
+	if strings.HasPrefix(mimeType, "image/") {
+		return nil
+	}
+	var content string
+	if isExtractedText(mimeType) {
+		content, err = kb.contentExtractor.ExtractContent(&document)
+		if err != nil {
+			logger.Warn("failed to extract document content for knowledge base", "error", err, "document_id", docID)
+			content = ""
+		}
+	}
+
+	return kb.sync(ctx, &document, content)
+}
+
+// RemoveDocument deletes the Dify document mirroring a document
+func (kb *KnowledgeBase) RemoveDocument(ctx context.Context, docID string) error {
+	if !kb.Enabled() {
+		return nil
+	}
+
+	record, err := kb.findRecord(docID)
+	if err != nil {
+		return errors.New("failed to remove document from knowledge base")
+	}
+	if record == nil {
+		return nil
+	}
+
+	if err := kb.datasetClient.DeleteDocument(ctx, record.DatasetID, record.DifyDocumentID); err != nil && !errors.Is(err, client.ErrNotFound) {
+		logger.Error("failed to delete knowledge base document", "error", err, "document_id", docID)
+		return errors.New("failed to remove document from knowledge base")
+	}
+	if err := kb.db.Delete(record).Error; err != nil {
+		logger.Error("failed to delete knowledge document record", "error", err, "document_id", docID)
+		return errors.New("failed to remove document from knowledge base")
+	}
+
+	return nil
+}
+
+// GetSyncStatus returns the sync record of a document, refreshing its indexing
+// status from Dify while indexing is in progress
+func (kb *KnowledgeBase) GetSyncStatus(ctx context.Context, docID string) (*domain.KnowledgeDocument, error) {
+	if !kb.Enabled() {
+		return nil, errors.New("knowledge base is not configured")
+	}
+
+	record, err := kb.findRecord(docID)
+	if err != nil {
+		return nil, errors.New("failed to get knowledge base status")
+	}
+	if record == nil {
+		return nil, errors.New("document is not in the knowledge base")
+	}
+	if record.Batch == "" || record.IndexingStatus == client.IndexingStatusCompleted || record.IndexingStatus == client.IndexingStatusError {
+		return record, nil
+	}
+
+	statuses, err := kb.datasetClient.GetIndexingStatus(ctx, record.DatasetID, record.Batch)
+	if err != nil {
+		logger.Error("failed to get indexing status", "error", err, "document_id", docID)
+		return nil, errors.New("failed to get knowledge base status")
+	}
+	for _, status := range statuses {
+		if status.ID != record.DifyDocumentID {
+			continue
+		}
+		record.IndexingStatus = status.IndexingStatus
+		record.Error = status.Error
+		record.UpdatedAt = time.Now()
+		if err := kb.db.Save(record).Error; err != nil {
+			logger.Error("failed to update knowledge document record", "error", err, "document_id", docID)
+		}
+	}
+
+	return record, nil
+}
+
+// Retrieve returns the segments of the documents of a team best matching a query
+func (kb *KnowledgeBase) Retrieve(ctx context.Context, teamID, query string, topK int) ([]*KnowledgeHit, error) {
+	if !kb.Enabled() {
+		return nil, errors.New("knowledge base is not configured")
+	}
+	if topK <= 0 {
+		topK = defaultKnowledgeTopK
+	}
+
+	var dataset domain.KnowledgeDataset
+	if err := kb.db.Where("team_id = ?", teamID).First(&dataset).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return []*KnowledgeHit{}, nil
+		}
+		logger.Error("failed to find knowledge dataset", "error", err)
+		return nil, errors.New("failed to search knowledge base")
+	}
+
+	searchMethod := "semantic_search"
+	if kb.indexingTechnique == "economy" {
+		searchMethod = "keyword_search"
+	}
+	resp, err := kb.datasetClient.Retrieve(ctx, dataset.DatasetID, &client.RetrieveRequest{
+		Query:          query,
+		RetrievalModel: &client.RetrievalModel{SearchMethod: searchMethod, TopK: topK},
+	})
+	if errors.Is(err, client.ErrNotFound) {
+		kb.forgetDataset(&dataset)
+		return []*KnowledgeHit{}, nil
+	}
+	if err != nil {
+		logger.Error("failed to retrieve from knowledge base", "error", err, "team_id", teamID)
+		return nil, errors.New("failed to search knowledge base")
+	}
+
+	// Map the Dify documents back to documents, dropping those no longer mirrored
+	var difyIDs []string
+	for _, record := range resp.Records {
+		if record.Segment != nil {
+			difyIDs = append(difyIDs, record.Segment.DocumentID)
+		}
+	}
+	var records []*domain.KnowledgeDocument
+	if len(difyIDs) > 0 {
+		if err := kb.db.Where("dataset_id = ? AND dify_document_id IN ?", dataset.DatasetID, difyIDs).Find(&records).Error; err != nil {
+			logger.Error("failed to find knowledge documents", "error", err)
+			return nil, errors.New("failed to search knowledge base")
+		}
+	}
+	documentIDs := make(map[string]string, len(records))
+	for _, record := range records {
+		documentIDs[record.DifyDocumentID] = record.DocumentID
+	}
+
+	hits := make([]*KnowledgeHit, 0, len(resp.Records))
+	for _, record := range resp.Records {
+		if record.Segment == nil {
+			continue
+		}
+		documentID, ok := documentIDs[record.Segment.DocumentID]
+		if !ok {
+			continue
+		}
+		hit := &KnowledgeHit{
+			DocumentID: documentID,
+			SegmentID:  record.Segment.ID,
+			Position:   record.Segment.Position,
+			Content:    record.Segment.Content,
+			Score:      record.Score,
+		}
+		if record.Segment.Document != nil {
+			hit.Title = record.Segment.Document.Name
+		}
+		hits = append(hits, hit)
+	}
+
+	return hits, nil
+}
+
+// SyncAll syncs every document whose content changed since it was last synced and
+// removes the Dify documents of deleted documents. It returns the number of
+// documents checked; documents failing to sync are logged and skipped.
+func (kb *KnowledgeBase) SyncAll(ctx context.Context) (int, error) {
+	if !kb.Enabled() {
+		return 0, errors.New("knowledge base is not configured")
+	}
+
+	checked, failed := 0, 0
+	var documents []*domain.Document
+	err := kb.db.Select("id").Order("id").FindInBatches(&documents, reindexBatchSize, func(tx *gorm.DB, batch int) error {
+		for _, document := range documents {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := kb.IndexDocument(ctx, document.ID); err != nil {
+				failed++
+			}
+			checked++
+		}
+		return nil
+	}).Error
+	if err != nil {
+		logger.Error("failed to sync documents to knowledge base", "error", err)
+		return checked, errors.New("failed to sync documents to knowledge base")
+	}
+
+	var orphans []string
+	if err := kb.db.Model(&domain.KnowledgeDocument{}).
+		Where("document_id NOT IN (?)", kb.db.Model(&domain.Document{}).Select("id")).
+		Pluck("document_id", &orphans).Error; err != nil {
+		logger.Error("failed to find deleted knowledge documents", "error", err)
+		return checked, errors.New("failed to sync documents to knowledge base")
+	}
+	for _, docID := range orphans {
+		if err := kb.RemoveDocument(ctx, docID); err != nil {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return checked, errors.New("failed to sync some documents to knowledge base")
+	}
+	return checked, nil
+}
+
+// sync creates or updates the Dify document mirroring a document, from text when
+// content is given and from the document file otherwise
+func (kb *KnowledgeBase) sync(ctx context.Context, document *domain.Document, content string) error {
+	source := domain.KnowledgeSourceFile
+	var text string
+	if strings.TrimSpace(content) != "" {
+		source = domain.KnowledgeSourceText
+		text = knowledgeEntry(document, content)
+	} else if document.FilePath == "" {
+		return nil
+	}
+	fingerprint := hashString(document.Title + "\x00" + source + "\x00" + text + "\x00" + document.ContentHash)
+
+	record, err := kb.findRecord(document.ID)
+	if err != nil {
+		return errors.New("failed to sync document to knowledge base")
+	}
+	if record != nil && record.Fingerprint == fingerprint {
+		return nil
+	}
+	// Text extracted from the same content is better than Dify's own extraction
+	if record != nil && source == domain.KnowledgeSourceFile && record.Source == domain.KnowledgeSourceText && record.ContentHash == document.ContentHash {
+		return nil
+	}
+
+	dataset, err := kb.teamDataset(ctx, document.TeamID)
+	if err != nil {
+		return errors.New("failed to sync document to knowledge base")
+	}
+
+	// A document moved to another team is deleted from the dataset of its old team
+	difyDocumentID := ""
+	if record != nil {
+		if record.DatasetID == dataset.DatasetID {
+			difyDocumentID = record.DifyDocumentID
+		} else if err := kb.datasetClient.DeleteDocument(ctx, record.DatasetID, record.DifyDocumentID); err != nil && !errors.Is(err, client.ErrNotFound) {
+			logger.Warn("failed to delete knowledge base document of previous team", "error", err, "document_id", document.ID)
+		}
+	}
+
+	resp, err := kb.write(ctx, dataset.DatasetID, difyDocumentID, document, source, text)
+	if errors.Is(err, client.ErrNotFound) {
+		// The dataset was deleted in Dify; create a new one for the team
+		kb.forgetDataset(dataset)
+		if dataset, err = kb.teamDataset(ctx, document.TeamID); err != nil {
+			return errors.New("failed to sync document to knowledge base")
+		}
+		resp, err = kb.write(ctx, dataset.DatasetID, "", document, source, text)
+	}
+	if err != nil {
+		logger.Error("failed to write knowledge base document", "error", err, "document_id", document.ID)
+		return errors.New("failed to sync document to knowledge base")
+	}
+
+	now := time.Now()
+	if record == nil {
+		record = &domain.KnowledgeDocument{DocumentID: document.ID, CreatedAt: now}
+	}
+	record.TeamID = document.TeamID
+	record.DatasetID = dataset.DatasetID
+	record.DifyDocumentID = resp.Document.ID
+	record.VersionID = kb.latestVersionID(document.ID)
+	record.ContentHash = document.ContentHash
+	record.Source = source
+	record.Fingerprint = fingerprint
+	record.Batch = resp.Batch
+	record.IndexingStatus = resp.Document.IndexingStatus
+	record.Error = resp.Document.Error
+	record.SyncedAt = now
+	record.UpdatedAt = now
+	if err := kb.db.Save(record).Error; err != nil {
+		logger.Error("failed to save knowledge document record", "error", err, "document_id", document.ID)
+		return errors.New("failed to sync document to knowledge base")
+	}
+
 	return nil
-}
\ No newline at end of file
+}
+
+// write updates a Dify document, or creates it when difyDocumentID is empty or
+// the document no longer exists. ErrNotFound means the dataset does not exist.
+func (kb *KnowledgeBase) write(ctx context.Context, datasetID, difyDocumentID string, document *domain.Document, source, text string) (*client.DocumentResponse, error) {
+	processRule := &client.ProcessRule{Mode: "automatic"}
+
+	if source == domain.KnowledgeSourceText {
+		req := &client.DocumentTextRequest{
+			Name:              document.Title,
+			Text:              text,
+			IndexingTechnique: kb.indexingTechnique,
+			ProcessRule:       processRule,
+		}
+		if difyDocumentID != "" {
+			resp, err := kb.datasetClient.UpdateDocumentByText(ctx, datasetID, difyDocumentID, req)
+			if !errors.Is(err, client.ErrNotFound) {
+				return resp, err
+			}
+		}
+		return kb.datasetClient.CreateDocumentByText(ctx, datasetID, req)
+	}
+
+	if kb.storageService == nil {
+		return nil, errors.New("storage service is not available")
+	}
+	open := func() (*client.DocumentFileRequest, io.Closer, error) {
+		file, err := kb.storageService.GetFile(ctx, document.FilePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &client.DocumentFileRequest{
+			File:              file,
+			FileName:          knowledgeFileName(document),
+			IndexingTechnique: kb.indexingTechnique,
+			ProcessRule:       processRule,
+		}, file, nil
+	}
+
+	if difyDocumentID != "" {
+		req, file, err := open()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := kb.datasetClient.UpdateDocumentByFile(ctx, datasetID, difyDocumentID, req)
+		file.Close()
+		if !errors.Is(err, client.ErrNotFound) {
+			return resp, err
+		}
+	}
+	req, file, err := open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return kb.datasetClient.CreateDocumentByFile(ctx, datasetID, req)
+}
+
+// teamDataset returns the dataset of a team, creating it on first use
+func (kb *KnowledgeBase) teamDataset(ctx context.Context, teamID string) (*domain.KnowledgeDataset, error) {
+	var dataset domain.KnowledgeDataset
+	err := kb.db.Where("team_id = ?", teamID).First(&dataset).Error
+	if err == nil {
+		return &dataset, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Error("failed to find knowledge dataset", "error", err)
+		return nil, err
+	}
+
+	name := knowledgeDatasetName(teamID)
+	created, err := kb.datasetClient.CreateDataset(ctx, &client.CreateDatasetRequest{
+		Name:              name,
+		Description:       "CDK-Office documents of team " + teamID,
+		IndexingTechnique: kb.indexingTechnique,
+		Permission:        "only_me",
+	})
+	if errors.Is(err, client.ErrConflict) {
+		// Adopt the dataset of a lost mapping or of a concurrent sync
+		created, err = kb.findDataset(ctx, name)
+	}
+	if err != nil {
+		logger.Error("failed to create knowledge dataset", "error", err, "team_id", teamID)
+		return nil, err
+	}
+
+	dataset = domain.KnowledgeDataset{
+		TeamID:    teamID,
+		DatasetID: created.ID,
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+	if err := kb.db.Create(&dataset).Error; err != nil {
+		// Another sync stored the dataset of the team first
+		var stored domain.KnowledgeDataset
+		if kb.db.Where("team_id = ?", teamID).First(&stored).Error == nil {
+			return &stored, nil
+		}
+		logger.Error("failed to save knowledge dataset", "error", err, "team_id", teamID)
+		return nil, err
+	}
+
+	return &dataset, nil
+}
+
+// findDataset looks a dataset up by name
+func (kb *KnowledgeBase) findDataset(ctx context.Context, name string) (*client.Dataset, error) {
+	for page := 1; ; page++ {
+		list, err := kb.datasetClient.ListDatasets(ctx, page, knowledgeDatasetPageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, dataset := range list.Data {
+			if dataset.Name == name {
+				return dataset, nil
+			}
+		}
+		if !list.HasMore {
+			return nil, client.ErrNotFound
+		}
+	}
+}
+
+// forgetDataset drops the mapping to a dataset deleted in Dify, with the records
+// of the documents it held
+func (kb *KnowledgeBase) forgetDataset(dataset *domain.KnowledgeDataset) {
+	logger.Warn("knowledge dataset no longer exists", "team_id", dataset.TeamID, "dataset_id", dataset.DatasetID)
+	if err := kb.db.Where("dataset_id = ?", dataset.DatasetID).Delete(&domain.KnowledgeDocument{}).Error; err != nil {
+		logger.Error("failed to delete knowledge document records", "error", err)
+	}
+	if err := kb.db.Delete(dataset).Error; err != nil {
+		logger.Error("failed to delete knowledge dataset", "error", err)
+	}
+}
+
+// findRecord returns the sync record of a document, or nil
+func (kb *KnowledgeBase) findRecord(docID string) (*domain.KnowledgeDocument, error) {
+	var record domain.KnowledgeDocument
+	if err := kb.db.Where("document_id = ?", docID).First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		logger.Error("failed to find knowledge document record", "error", err)
+		return nil, err
+	}
+	return &record, nil
+}
+
+// latestVersionID returns the ID of the latest version of a document, or empty
+func (kb *KnowledgeBase) latestVersionID(docID string) string {
+	var version domain.DocumentVersion
+	if err := kb.db.Where("document_id = ?", docID).Order("version desc").First(&version).Error; err != nil {
+		return ""
+	}
+	return version.ID
+}
+
+// knowledgeEntry returns the text of a document sent to the knowledge base
+func knowledgeEntry(document *domain.Document, content string) string {
+	var entry strings.Builder
+	entry.WriteString(document.Title)
+	if document.Description != "" {
+		entry.WriteString("\n\n" + document.Description)
+	}
+	if document.Tags != "" && document.Tags != "[]" {
+		entry.WriteString("\n\nTags: " + document.Tags)
+	}
+	entry.WriteString("\n\n" + content)
+	return entry.String()
+}
+
+// knowledgeDatasetName returns the name of the dataset of a team, short enough for
+// Dify's 40 character limit whatever the team ID
+func knowledgeDatasetName(teamID string) string {
+	return "cdk-office-" + hashString(teamID)[:16]
+}
+
+// knowledgeFileName returns the file name a document is uploaded under; Dify picks
+// the extractor by its extension
+func knowledgeFileName(document *domain.Document) string {
+	if filepath.Ext(document.Title) != "" {
+		return document.Title
+	}
+	if extensions, err := mime.ExtensionsByType(document.MimeType); err == nil && len(extensions) > 0 {
+		return document.Title + extensions[0]
+	}
+	return document.Title
+}
+
+// hashString returns the hex SHA-256 hash of a string
+func hashString(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
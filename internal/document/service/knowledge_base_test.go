@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"cdk-office/internal/dify/client"
+	"cdk-office/internal/dify/client/difytest"
+	"cdk-office/internal/document/domain"
+	"cdk-office/internal/document/storage"
+	"cdk-office/internal/shared/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestKnowledgeBaseMirrorsDocuments tests that uploads, new versions, processing
+// results and deletions are mirrored into the Dify dataset of the team
+func TestKnowledgeBaseMirrorsDocuments(t *testing.T) {
+	ctx := context.Background()
+	db := testutils.SetupTestDB()
+	dify := difytest.NewServer("dataset-key")
+	defer dify.Close()
+
+	driver, err := storage.NewLocalDriver(t.TempDir())
+	require.NoError(t, err)
+	storageService := NewStorageServiceWithDriver(driver)
+	knowledgeBase := NewKnowledgeBaseWithClient(db, client.NewDatasetClient(dify.URL, "dataset-key"), storageService)
+	indexer := DocumentIndexers{NewSearchServiceWithStorage(db, storageService), knowledgeBase}
+	documentService := NewDocumentServiceWithIndexer(db, storageService, indexer)
+	versionService := NewVersionServiceWithDeps(db, indexer)
+
+	stored, err := storageService.SaveFile(ctx, strings.NewReader("Employees have 25 days of annual leave."))
+	require.NoError(t, err)
+	document, err := documentService.Upload(ctx, &UploadRequest{
+		Title:    "Handbook",
+		FilePath: stored.Key,
+		FileSize: stored.Size,
+		MimeType: "text/plain",
+		OwnerID:  "user_1",
+		TeamID:   "team_1",
+	})
+	require.NoError(t, err)
+
+	// Uploads are mirrored into a dataset created for the team
+	var record domain.KnowledgeDocument
+	require.NoError(t, db.Where("document_id = ?", document.ID).First(&record).Error)
+	assert.Equal(t, domain.KnowledgeSourceText, record.Source)
+	assert.NotEmpty(t, record.VersionID)
+	dataset := dify.Dataset(record.DatasetID)
+	require.NotNil(t, dataset)
+	require.Contains(t, dataset.Documents, record.DifyDocumentID)
+	assert.Equal(t, "Handbook", dataset.Documents[record.DifyDocumentID].Name)
+	assert.Contains(t, dataset.Documents[record.DifyDocumentID].Text, "25 days of annual leave")
+
+	hits, err := knowledgeBase.Retrieve(ctx, "team_1", "annual leave", 3)
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	assert.Equal(t, document.ID, hits[0].DocumentID)
+	assert.Equal(t, "Handbook", hits[0].Title)
+	assert.Greater(t, hits[0].Score, 0.0)
+
+	hits, err = knowledgeBase.Retrieve(ctx, "team_2", "annual leave", 3)
+	require.NoError(t, err)
+	assert.Empty(t, hits)
+
+	status, err := knowledgeBase.GetSyncStatus(ctx, document.ID)
+	require.NoError(t, err)
+	assert.Equal(t, client.IndexingStatusCompleted, status.IndexingStatus)
+
+	// Metadata-only changes do not resend unchanged content
+	requests := len(dify.Requests())
+	require.NoError(t, documentService.UpdateDocument(ctx, document.ID, &UpdateRequest{Description: "Leave policy"}))
+	assert.Len(t, dify.Requests(), requests)
+
+	// New versions update the same Dify document
+	next, err := storageService.SaveFile(ctx, strings.NewReader("Employees have 30 days of annual leave."))
+	require.NoError(t, err)
+	version, err := versionService.CreateVersion(ctx, document.ID, next.Key, next.Size)
+	require.NoError(t, err)
+	var updated domain.KnowledgeDocument
+	require.NoError(t, db.Where("document_id = ?", document.ID).First(&updated).Error)
+	assert.Equal(t, record.DifyDocumentID, updated.DifyDocumentID)
+	assert.Equal(t, version.ID, updated.VersionID)
+	assert.Contains(t, dify.Dataset(record.DatasetID).Documents[record.DifyDocumentID].Text, "30 days")
+
+	// The processing pipeline resends the document with its summary
+	require.NoError(t, db.First(document, "id = ?", document.ID).Error)
+	require.NoError(t, knowledgeBase.AddToKnowledgeBase(ctx, document, "Employees have 30 days of annual leave."))
+	assert.Contains(t, dify.Dataset(record.DatasetID).Documents[record.DifyDocumentID].Text, "Leave policy")
+	requests = len(dify.Requests())
+	require.NoError(t, knowledgeBase.AddToKnowledgeBase(ctx, document, "Employees have 30 days of annual leave."))
+	assert.Len(t, dify.Requests(), requests)
+
+	// Deleting the document deletes it from the dataset
+	require.NoError(t, documentService.DeleteDocument(ctx, document.ID))
+	assert.Empty(t, dify.Dataset(record.DatasetID).Documents)
+	var count int64
+	db.Model(&domain.KnowledgeDocument{}).Count(&count)
+	assert.Zero(t, count)
+}
+
+// TestKnowledgeBaseSendsUnextractedFiles tests that files the content extractor
+// does not read are sent for Dify to extract
+func TestKnowledgeBaseSendsUnextractedFiles(t *testing.T) {
+	ctx := context.Background()
+	db := testutils.SetupTestDB()
+	dify := difytest.NewServer("dataset-key")
+	defer dify.Close()
+
+	driver, err := storage.NewLocalDriver(t.TempDir())
+	require.NoError(t, err)
+	storageService := NewStorageServiceWithDriver(driver)
+	knowledgeBase := NewKnowledgeBaseWithClient(db, client.NewDatasetClient(dify.URL, "dataset-key"), storageService)
+	documentService := NewDocumentServiceWithIndexer(db, storageService, knowledgeBase)
+
+	stored, err := storageService.SaveFile(ctx, strings.NewReader("name,amount\nrent,1200\n"))
+	require.NoError(t, err)
+	document, err := documentService.Upload(ctx, &UploadRequest{
+		Title:    "Budget",
+		FilePath: stored.Key,
+		FileSize: stored.Size,
+		MimeType: "text/csv",
+		OwnerID:  "user_1",
+		TeamID:   "team_1",
+	})
+	require.NoError(t, err)
+
+	var record domain.KnowledgeDocument
+	require.NoError(t, db.Where("document_id = ?", document.ID).First(&record).Error)
+	assert.Equal(t, domain.KnowledgeSourceFile, record.Source)
+	mirrored := dify.Dataset(record.DatasetID).Documents[record.DifyDocumentID]
+	assert.Equal(t, "Budget.csv", mirrored.FileName)
+	assert.Equal(t, "name,amount\nrent,1200\n", mirrored.Text)
+
+	// Text extracted by the processing pipeline replaces the file
+	require.NoError(t, knowledgeBase.AddToKnowledgeBase(ctx, document, "Rent 1200"))
+	mirrored = dify.Dataset(record.DatasetID).Documents[record.DifyDocumentID]
+	assert.Equal(t, "text", mirrored.DataSourceType)
+
+	// and is kept when the unchanged file is synced again
+	require.NoError(t, knowledgeBase.AddToKnowledgeBase(ctx, document, ""))
+	assert.Equal(t, "text", dify.Dataset(record.DatasetID).Documents[record.DifyDocumentID].DataSourceType)
+}
+
+// TestKnowledgeBaseRecoversDeletedDatasets tests that a dataset deleted in Dify is
+// recreated, and that a lost dataset mapping adopts the existing dataset
+func TestKnowledgeBaseRecoversDeletedDatasets(t *testing.T) {
+	ctx := context.Background()
+	db := testutils.SetupTestDB()
+	dify := difytest.NewServer("dataset-key")
+	defer dify.Close()
+	datasetClient := client.NewDatasetClient(dify.URL, "dataset-key")
+	knowledgeBase := NewKnowledgeBaseWithClient(db, datasetClient, nil)
+
+	document := &domain.Document{ID: "doc_1", Title: "Policy", TeamID: "team_1", ContentHash: "hash_1"}
+	require.NoError(t, db.Create(document).Error)
+	require.NoError(t, knowledgeBase.AddToKnowledgeBase(ctx, document, "Remote work is allowed."))
+
+	var dataset domain.KnowledgeDataset
+	require.NoError(t, db.First(&dataset, "team_id = ?", "team_1").Error)
+
+	// Dataset deleted in Dify
+	require.NoError(t, datasetClient.DeleteDataset(ctx, dataset.DatasetID))
+	document.Title = "Remote work policy"
+	require.NoError(t, knowledgeBase.AddToKnowledgeBase(ctx, document, "Remote work is allowed."))
+	var recreated domain.KnowledgeDataset
+	require.NoError(t, db.First(&recreated, "team_id = ?", "team_1").Error)
+	assert.NotEqual(t, dataset.DatasetID, recreated.DatasetID)
+	assert.Len(t, dify.Dataset(recreated.DatasetID).Documents, 1)
+
+	// Mapping lost, dataset still in Dify
+	require.NoError(t, db.Delete(&recreated).Error)
+	require.NoError(t, db.Where("document_id = ?", "doc_1").Delete(&domain.KnowledgeDocument{}).Error)
+	_, err := knowledgeBase.teamDataset(ctx, "team_1")
+	require.NoError(t, err)
+	var adopted domain.KnowledgeDataset
+	require.NoError(t, db.First(&adopted, "team_id = ?", "team_1").Error)
+	assert.Equal(t, recreated.DatasetID, adopted.DatasetID)
+	assert.Equal(t, 1, dify.DatasetCount())
+}
+
+// TestKnowledgeBaseSyncAll tests that SyncAll mirrors unsynced documents and removes
+// the Dify documents of documents deleted without the indexer
+func TestKnowledgeBaseSyncAll(t *testing.T) {
+	ctx := context.Background()
+	db := testutils.SetupTestDB()
+	dify := difytest.NewServer("dataset-key")
+	defer dify.Close()
+
+	driver, err := storage.NewLocalDriver(t.TempDir())
+	require.NoError(t, err)
+	storageService := NewStorageServiceWithDriver(driver)
+	knowledgeBase := NewKnowledgeBaseWithClient(db, client.NewDatasetClient(dify.URL, "dataset-key"), storageService)
+	documentService := NewDocumentServiceWithStorage(db, storageService)
+
+	var documents []*domain.Document
+	for _, text := range []string{"First document", "Second document"} {
+		stored, err := storageService.SaveFile(ctx, strings.NewReader(text))
+		require.NoError(t, err)
+		document, err := documentService.Upload(ctx, &UploadRequest{
+			Title: text, FilePath: stored.Key, FileSize: stored.Size, MimeType: "text/plain", OwnerID: "user_1", TeamID: "team_1",
+		})
+		require.NoError(t, err)
+		documents = append(documents, document)
+	}
+
+	checked, err := knowledgeBase.SyncAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, checked)
+	var dataset domain.KnowledgeDataset
+	require.NoError(t, db.First(&dataset, "team_id = ?", "team_1").Error)
+	assert.Len(t, dify.Dataset(dataset.DatasetID).Documents, 2)
+
+	require.NoError(t, documentService.DeleteDocument(ctx, documents[0].ID))
+	checked, err = knowledgeBase.SyncAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, checked)
+	assert.Len(t, dify.Dataset(dataset.DatasetID).Documents, 1)
+}
+
+// TestKnowledgeBaseDisabled tests that a knowledge base without a dataset client does nothing
+func TestKnowledgeBaseDisabled(t *testing.T) {
+	knowledgeBase := NewKnowledgeBaseWithClient(testutils.SetupTestDB(), nil, nil)
+
+	assert.False(t, knowledgeBase.Enabled())
+	assert.NoError(t, knowledgeBase.AddToKnowledgeBase(context.Background(), &domain.Document{ID: "doc_1"}, "content"))
+	assert.NoError(t, knowledgeBase.RemoveDocument(context.Background(), "doc_1"))
+	_, err := knowledgeBase.Retrieve(context.Background(), "team_1", "query", 0)
+	assert.EqualError(t, err, "knowledge base is not configured")
+}
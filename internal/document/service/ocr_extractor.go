@@ -18,7 +18,8 @@ type OCRExtractorInterface interface {
 
 // OCRExtractor implements the OCRExtractorInterface
 type OCRExtractor struct {
-	storagePath string
+	storagePath    string
+	storageService StorageServiceInterface
 }
 
 // NewOCRExtractor creates a new instance of OCRExtractor
@@ -28,19 +29,22 @@ func NewOCRExtractor(storagePath string) *OCRExtractor {
 	}
 }
 
+// NewOCRExtractorWithStorage creates a new instance of OCRExtractor that
+// reads document files through the storage service
+func NewOCRExtractorWithStorage(storageService StorageServiceInterface) *OCRExtractor {
+	return &OCRExtractor{
+		storageService: storageService,
+	}
+}
+
 // ExtractOCRContent extracts content from a document using OCR
 func (oe *OCRExtractor) ExtractOCRContent(document *domain.Document) (string, error) {
 	// Determine file path
-	filePath := document.FilePath
-	if !filepath.IsAbs(filePath) {
-		filePath = filepath.Join(oe.storagePath, filePath)
-	}
-
-	// Check if file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		logger.Error("file not found", "file_path", filePath)
-		return "", fmt.Errorf("file not found: %s", filePath)
+	filePath, cleanup, err := resolveDocumentFile(oe.storageService, oe.storagePath, document)
+	if err != nil {
+		return "", err
 	}
+	defer cleanup()
 
 	// Extract OCR content based on file type
 	switch strings.ToLower(document.MimeType) {
@@ -130,17 +134,17 @@ func (oe *OCRExtractor) extractPDFWithImageOCRContent(filePath string) (string,
 	}
 	
 	// Extract the text from the result
-	// For PDFs, we concatenate text from all pages
+	// For PDFs, we concatenate text from all pages, separated by page breaks
 	if pages, ok := result["pages"].([]interface{}); ok {
-		var fullText string
+		texts := make([]string, 0, len(pages))
 		for _, page := range pages {
+			text := ""
 			if pageMap, ok := page.(map[string]interface{}); ok {
-				if text, ok := pageMap["text"].(string); ok {
-					fullText += text + "\n"
-				}
+				text, _ = pageMap["text"].(string)
 			}
+			texts = append(texts, text)
 		}
-		return fullText, nil
+		return strings.Join(texts, PageBreak), nil
 	}
 	
 	logger.Error("PDF OCR result does not contain pages", "result", result)
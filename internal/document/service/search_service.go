@@ -6,84 +6,250 @@ import (
 	"strings"
 
 	"cdk-office/internal/document/domain"
+	"cdk-office/internal/document/search"
 	"cdk-office/internal/shared/database"
+	"cdk-office/pkg/config"
 	"cdk-office/pkg/logger"
 	"gorm.io/gorm"
 )
 
+// reindexBatchSize is the number of documents loaded per batch when rebuilding the index
+const reindexBatchSize = 100
+
 // SearchServiceInterface defines the interface for document search service
 type SearchServiceInterface interface {
 	SearchDocuments(ctx context.Context, query string, teamID string, page, size int) ([]*domain.Document, int64, error)
+	Search(ctx context.Context, query *search.Query) (*search.Result, error)
+	IndexDocument(ctx context.Context, docID string) error
+	RemoveDocument(ctx context.Context, docID string) error
+	ReindexAll(ctx context.Context) (int, error)
+}
+
+// DocumentIndexer keeps the search index in sync with document changes
+type DocumentIndexer interface {
+	IndexDocument(ctx context.Context, docID string) error
+	RemoveDocument(ctx context.Context, docID string) error
+}
+
+// DocumentIndexers runs several indexers, each whatever the others return
+type DocumentIndexers []DocumentIndexer
+
+// IndexDocument runs IndexDocument of every indexer
+func (indexers DocumentIndexers) IndexDocument(ctx context.Context, docID string) error {
+	var errs []error
+	for _, indexer := range indexers {
+		if err := indexer.IndexDocument(ctx, docID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// RemoveDocument runs RemoveDocument of every indexer
+func (indexers DocumentIndexers) RemoveDocument(ctx context.Context, docID string) error {
+	var errs []error
+	for _, indexer := range indexers {
+		if err := indexer.RemoveDocument(ctx, docID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// newDocumentIndexer returns the indexers kept in sync with document changes: the
+// search index, then the semantic search and the Dify knowledge base when
+// configured. Semantic search embeds the text extracted for the search index.
+func newDocumentIndexer(db *gorm.DB, storageService StorageServiceInterface, cfg *config.Config) DocumentIndexer {
+	indexers := DocumentIndexers{NewSearchServiceWithStorage(db, storageService)}
+	if semanticService := NewSemanticServiceWithDB(db, &cfg.Vector); semanticService.Enabled() {
+		indexers = append(indexers, semanticService)
+	}
+	if knowledgeBase := NewKnowledgeBaseWithStorage(db, storageService, &cfg.Dify); knowledgeBase.Enabled() {
+		indexers = append(indexers, knowledgeBase)
+	}
+	return indexers
 }
 
 // SearchService implements the SearchServiceInterface
 type SearchService struct {
-	db *gorm.DB
+	db               *gorm.DB
+	engine           search.Engine
+	contentExtractor ContentExtractorInterface
+	ocrExtractor     OCRExtractorInterface
 }
 
 // NewSearchService creates a new instance of SearchService
-func NewSearchService() *SearchService {
+func NewSearchService(cfg *config.StorageConfig) (*SearchService, error) {
+	storageService, err := NewStorageService(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewSearchServiceWithStorage(database.GetDB(), storageService), nil
+}
+
+// NewSearchServiceWithStorage creates a new instance of SearchService that extracts
+// document content through the given storage service
+func NewSearchServiceWithStorage(db *gorm.DB, storageService StorageServiceInterface) *SearchService {
+	return &SearchService{
+		db:               db,
+		engine:           search.NewEngine(db),
+		contentExtractor: NewContentExtractorWithStorage(storageService),
+		ocrExtractor:     NewOCRExtractorWithStorage(storageService),
+	}
+}
+
+// NewSearchServiceWithEngine creates a new instance of SearchService with specific dependencies
+func NewSearchServiceWithEngine(db *gorm.DB, engine search.Engine, contentExtractor ContentExtractorInterface, ocrExtractor OCRExtractorInterface) *SearchService {
 	return &SearchService{
-		db: database.GetDB(),
+		db:               db,
+		engine:           engine,
+		contentExtractor: contentExtractor,
+		ocrExtractor:     ocrExtractor,
 	}
 }
 
 // SearchDocuments searches for documents based on a query
 func (s *SearchService) SearchDocuments(ctx context.Context, query string, teamID string, page, size int) ([]*domain.Document, int64, error) {
-	// Validate pagination parameters
-	if page < 1 {
-		page = 1
-	}
-	if size < 1 || size > 100 {
-		size = 10
-	}
-
-	// Build the query
-	dbQuery := s.db.Model(&domain.Document{})
-
-	// Add team filter if provided
-	if teamID != "" {
-		dbQuery = dbQuery.Where("team_id = ?", teamID)
-	}
-
-	// Add search conditions if query is provided
-	if query != "" {
-		// Split query into terms
-		terms := strings.Fields(query)
-		
-		// Build search conditions
-		for _, term := range terms {
-			// Escape special characters in term
-			escapedTerm := strings.ReplaceAll(term, "%", "\\%")
-			escapedTerm = strings.ReplaceAll(escapedTerm, "_", "\\_")
-			
-			// Add search condition for title, description, and tags
-			dbQuery = dbQuery.Where(
-				"title ILIKE ? OR description ILIKE ? OR tags ILIKE ?",
-				"%"+escapedTerm+"%",
-				"%"+escapedTerm+"%",
-				"%"+escapedTerm+"%",
-			)
+	result, err := s.Search(ctx, &search.Query{
+		Text:    query,
+		Filters: search.Filters{TeamID: teamID},
+		Page:    page,
+		Size:    size,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	documents := make([]*domain.Document, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		documents = append(documents, hit.Document)
+	}
+
+	return documents, result.Total, nil
+}
+
+// Search searches for documents with ranking, highlights and facets
+func (s *SearchService) Search(ctx context.Context, query *search.Query) (*search.Result, error) {
+	result, err := s.engine.Search(ctx, query)
+	if err != nil {
+		logger.Error("failed to search documents", "error", err)
+		return nil, errors.New("failed to search documents")
+	}
+
+	return result, nil
+}
+
+// IndexDocument extracts the content of a document and adds it to the search index
+func (s *SearchService) IndexDocument(ctx context.Context, docID string) error {
+	// Find document by ID
+	var document domain.Document
+	if err := s.db.Where("id = ?", docID).First(&document).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("document not found")
 		}
+		logger.Error("failed to find document", "error", err)
+		return errors.New("failed to index document")
 	}
 
-	// Count total results
-	var total int64
-	if err := dbQuery.Count(&total).Error; err != nil {
-		logger.Error("failed to count search results", "error", err)
-		return nil, 0, errors.New("failed to search documents")
+	entry := &domain.SearchIndexEntry{
+		DocumentID:  document.ID,
+		Title:       document.Title,
+		Description: document.Description,
+		Tags:        document.Tags,
+		ContentHash: document.ContentHash,
 	}
 
-	// Apply pagination
-	offset := (page - 1) * size
-	dbQuery = dbQuery.Offset(offset).Limit(size)
+	// Metadata-only changes reuse the body extracted from unchanged content
+	var existing domain.SearchIndexEntry
+	if document.ContentHash != "" &&
+		s.db.Where("document_id = ?", docID).First(&existing).Error == nil &&
+		existing.ContentHash == document.ContentHash {
+		entry.Body = existing.Body
+	} else {
+		entry.Body = s.extractBody(&document)
+	}
+
+	if err := s.engine.IndexDocument(ctx, entry); err != nil {
+		logger.Error("failed to index document", "error", err, "document_id", docID)
+		return errors.New("failed to index document")
+	}
 
-	// Execute query
+	return nil
+}
+
+// RemoveDocument removes a document from the search index
+func (s *SearchService) RemoveDocument(ctx context.Context, docID string) error {
+	if err := s.engine.RemoveDocument(ctx, docID); err != nil {
+		logger.Error("failed to remove document from index", "error", err, "document_id", docID)
+		return errors.New("failed to remove document from index")
+	}
+
+	return nil
+}
+
+// ReindexAll rebuilds the search index for every document and returns the number indexed
+func (s *SearchService) ReindexAll(ctx context.Context) (int, error) {
+	indexed := 0
 	var documents []*domain.Document
-	if err := dbQuery.Find(&documents).Error; err != nil {
-		logger.Error("failed to search documents", "error", err)
-		return nil, 0, errors.New("failed to search documents")
+	err := s.db.Select("id").Order("id").FindInBatches(&documents, reindexBatchSize, func(tx *gorm.DB, batch int) error {
+		for _, document := range documents {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := s.IndexDocument(ctx, document.ID); err != nil {
+				return err
+			}
+			indexed++
+		}
+		return nil
+	}).Error
+	if err != nil {
+		logger.Error("failed to reindex documents", "error", err)
+		return indexed, errors.New("failed to reindex documents")
 	}
 
-	return documents, total, nil
-}
\ No newline at end of file
+	return indexed, nil
+}
+
+// extractBody extracts the searchable text of a document, falling back to OCR for
+// images and scanned PDFs. Extraction failures leave the body empty so the document
+// remains searchable by its metadata.
+func (s *SearchService) extractBody(document *domain.Document) string {
+	if document.FilePath == "" {
+		return ""
+	}
+
+	mimeType := strings.ToLower(document.MimeType)
+	var body string
+	if !strings.HasPrefix(mimeType, "image/") && s.contentExtractor != nil {
+		content, err := s.contentExtractor.ExtractContent(document)
+		if err != nil {
+			logger.Warn("failed to extract document content", "error", err, "document_id", document.ID)
+		} else if isExtractedText(mimeType) {
+			body = content
+		}
+	}
+
+	if strings.TrimSpace(body) == "" && (strings.HasPrefix(mimeType, "image/") || mimeType == "application/pdf") && s.ocrExtractor != nil {
+		content, err := s.ocrExtractor.ExtractOCRContent(document)
+		if err != nil {
+			logger.Warn("failed to extract document content with OCR", "error", err, "document_id", document.ID)
+		} else {
+			body = content
+		}
+	}
+
+	return body
+}
+
+// isExtractedText reports whether the content extractor returns the document text
+// for a MIME type rather than a generic file description
+func isExtractedText(mimeType string) bool {
+	switch mimeType {
+	case "text/plain", "text/html", "application/pdf", "application/msword",
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"cdk-office/internal/document/domain"
+	"cdk-office/internal/document/search"
+	"cdk-office/internal/document/storage"
+	"cdk-office/internal/shared/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSearchServiceIndexesDocumentContent tests that uploads, updates, new versions
+// and deletions keep the search index in sync with the extracted document text
+func TestSearchServiceIndexesDocumentContent(t *testing.T) {
+	ctx := context.Background()
+	db := testutils.SetupTestDB()
+	driver, err := storage.NewLocalDriver(t.TempDir())
+	require.NoError(t, err)
+	storageService := NewStorageServiceWithDriver(driver)
+	searchService := NewSearchServiceWithStorage(db, storageService)
+	documentService := NewDocumentServiceWithIndexer(db, storageService, searchService)
+	versionService := NewVersionServiceWithDeps(db, searchService)
+
+	stored, err := storageService.SaveFile(ctx, strings.NewReader("The quarterly budget was approved by the board."))
+	require.NoError(t, err)
+	document, err := documentService.Upload(ctx, &UploadRequest{
+		Title:    "Board minutes",
+		FilePath: stored.Key,
+		FileSize: stored.Size,
+		MimeType: "text/plain",
+		OwnerID:  "user_1",
+		TeamID:   "team_1",
+		Tags:     `["finance"]`,
+	})
+	require.NoError(t, err)
+
+	// Extracted body text is searchable
+	result, err := searchService.Search(ctx, &search.Query{Text: "budget approved"})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), result.Total)
+	assert.Equal(t, document.ID, result.Hits[0].Document.ID)
+	assert.Contains(t, result.Hits[0].Highlights["body"], "<mark>budget</mark>")
+
+	// Metadata updates are reindexed
+	require.NoError(t, documentService.UpdateDocument(ctx, document.ID, &UpdateRequest{Title: "Annual board minutes"}))
+	documents, total, err := searchService.SearchDocuments(ctx, "annual budget", "team_1", 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Len(t, documents, 1)
+
+	// New versions replace the indexed content
+	next, err := storageService.SaveFile(ctx, strings.NewReader("The hiring plan was postponed."))
+	require.NoError(t, err)
+	_, err = versionService.CreateVersion(ctx, document.ID, next.Key, next.Size)
+	require.NoError(t, err)
+	_, total, err = searchService.SearchDocuments(ctx, "budget", "", 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), total)
+	_, total, err = searchService.SearchDocuments(ctx, "hiring", "", 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+
+	var entry domain.SearchIndexEntry
+	require.NoError(t, db.Where("document_id = ?", document.ID).First(&entry).Error)
+	assert.Equal(t, next.ContentHash, entry.ContentHash)
+
+	// Rebuilding the index covers every document
+	count, err := searchService.ReindexAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	// Deleted documents are removed from the index
+	require.NoError(t, documentService.DeleteDocument(ctx, document.ID))
+	_, total, err = searchService.SearchDocuments(ctx, "hiring", "", 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), total)
+}
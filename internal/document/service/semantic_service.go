@@ -0,0 +1,457 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"cdk-office/internal/document/domain"
+	"cdk-office/internal/document/vector"
+	"cdk-office/internal/shared/database"
+	"cdk-office/pkg/config"
+	"cdk-office/pkg/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// defaultSemanticLimit is the number of documents returned when no limit is given
+	defaultSemanticLimit = 10
+	// maxSemanticLimit bounds the number of documents returned
+	maxSemanticLimit = 50
+	// chunksPerDocument is the number of chunks searched per wanted document, as
+	// the best chunks of a query often belong to the same documents
+	chunksPerDocument = 4
+	// maxPassageLength bounds the passage returned with a hit, in bytes
+	maxPassageLength = 300
+)
+
+// SemanticServiceInterface defines the interface for semantic search service
+type SemanticServiceInterface interface {
+	SemanticSearch(ctx context.Context, query, teamID string, limit int) ([]*SemanticHit, error)
+	MoreLikeThis(ctx context.Context, docID string, limit int) ([]*SemanticHit, error)
+	GetNearDuplicates(ctx context.Context, docID string) ([]*NearDuplicate, error)
+}
+
+// SemanticHit represents a document close in meaning to a query or document
+type SemanticHit struct {
+	Document *domain.Document `json:"document"`
+	Score    float64          `json:"score"`             // cosine similarity of the best matching chunk
+	Passage  string           `json:"passage,omitempty"` // best matching chunk
+}
+
+// NearDuplicate represents a document found to be almost the same as another
+type NearDuplicate struct {
+	Document   *domain.Document `json:"document"`
+	Similarity float64          `json:"similarity"`
+	DetectedAt time.Time        `json:"detected_at"`
+}
+
+// SemanticService implements the SemanticServiceInterface with embeddings
+// computed without Dify. It is also a DocumentIndexer: the text extracted for
+// the search index is chunked and embedded, and near-duplicates of the
+// document in its team are recorded.
+type SemanticService struct {
+	db                 *gorm.DB
+	embedder           vector.Embedder
+	index              vector.Index
+	chunkSize          int
+	chunkOverlap       int
+	duplicateThreshold float64
+}
+
+// NewSemanticService creates a new instance of SemanticService
+func NewSemanticService(cfg *config.VectorConfig) *SemanticService {
+	return NewSemanticServiceWithDB(database.GetDB(), cfg)
+}
+
+// NewSemanticServiceWithDB creates a new instance of SemanticService using the
+// configured embedder and index. It is disabled unless vector.enabled is set.
+func NewSemanticServiceWithDB(db *gorm.DB, cfg *config.VectorConfig) *SemanticService {
+	if !cfg.Enabled {
+		return NewSemanticServiceWithDeps(db, nil, nil, cfg)
+	}
+
+	var embedder vector.Embedder
+	if cfg.Embedder == "http" {
+		embedder = vector.NewHTTPEmbedder(cfg.BaseURL, cfg.APIKey, cfg.Model, cfg.Dimensions, cfg.BatchSize)
+	} else {
+		embedder = vector.NewHashEmbedder(cfg.Dimensions)
+	}
+	return NewSemanticServiceWithDeps(db, embedder, vector.NewIndex(db, cfg.Dimensions, cfg.Index), cfg)
+}
+
+// NewSemanticServiceWithDeps creates a new instance of SemanticService with a
+// specific embedder and index. A nil embedder disables the service.
+func NewSemanticServiceWithDeps(db *gorm.DB, embedder vector.Embedder, index vector.Index, cfg *config.VectorConfig) *SemanticService {
+	return &SemanticService{
+		db:                 db,
+		embedder:           embedder,
+		index:              index,
+		chunkSize:          cfg.ChunkSize,
+		chunkOverlap:       cfg.ChunkOverlap,
+		duplicateThreshold: cfg.DuplicateThreshold,
+	}
+}
+
+// Enabled reports whether documents are embedded
+func (s *SemanticService) Enabled() bool {
+	return s.embedder != nil && s.index != nil
+}
+
+// IndexDocument embeds the chunks of a document and records its near-duplicates.
+// It runs after the search index, whose extracted text it reuses; documents
+// whose text, team and model did not change are skipped.
+func (s *SemanticService) IndexDocument(ctx context.Context, docID string) error {
+	if !s.Enabled() {
+		return nil
+	}
+
+	var document domain.Document
+	if err := s.db.Where("id = ?", docID).First(&document).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("document not found")
+		}
+		logger.Error("failed to find document", "error", err)
+		return errors.New("failed to embed document")
+	}
+
+	var entry domain.SearchIndexEntry
+	if err := s.db.Where("document_id = ?", docID).Limit(1).Find(&entry).Error; err != nil {
+		logger.Error("failed to find search index entry", "error", err, "document_id", docID)
+		return errors.New("failed to embed document")
+	}
+	text := strings.Join(nonEmpty(document.Title, document.Description, entry.Body), "\n\n")
+
+	fingerprint := s.fingerprint(&document, text)
+	var existing domain.DocumentEmbedding
+	if err := s.db.Where("document_id = ?", docID).Limit(1).Find(&existing).Error; err != nil {
+		logger.Error("failed to find document embedding", "error", err, "document_id", docID)
+		return errors.New("failed to embed document")
+	}
+	if existing.Fingerprint == fingerprint {
+		return nil
+	}
+
+	passages := vector.Chunk(text, s.chunkSize, s.chunkOverlap)
+	vectors, err := s.embedder.Embed(ctx, passages)
+	if err != nil {
+		logger.Error("failed to embed document", "error", err, "document_id", docID)
+		return errors.New("failed to embed document")
+	}
+
+	chunks := make([]*domain.DocumentChunk, len(passages))
+	for i, passage := range passages {
+		chunks[i] = &domain.DocumentChunk{
+			ID:         fmt.Sprintf("%s_chunk_%d", docID, i),
+			DocumentID: docID,
+			TeamID:     document.TeamID,
+			Position:   i,
+			Content:    passage,
+			Embedding:  vector.Encode(vectors[i]),
+			CreatedAt:  time.Now(),
+		}
+	}
+	if err := s.index.Upsert(ctx, docID, chunks); err != nil {
+		logger.Error("failed to store document chunks", "error", err, "document_id", docID)
+		return errors.New("failed to embed document")
+	}
+
+	centroid := vector.Mean(vectors)
+	embedding := &domain.DocumentEmbedding{
+		DocumentID:  docID,
+		TeamID:      document.TeamID,
+		Model:       s.embedder.Model(),
+		Fingerprint: fingerprint,
+		Chunks:      len(chunks),
+		Centroid:    vector.Encode(centroid),
+		EmbeddedAt:  time.Now(),
+	}
+	if err := s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(embedding).Error; err != nil {
+		logger.Error("failed to save document embedding", "error", err, "document_id", docID)
+		return errors.New("failed to embed document")
+	}
+
+	return s.detectDuplicates(&document, centroid)
+}
+
+// RemoveDocument removes the chunks and near-duplicate records of a document
+func (s *SemanticService) RemoveDocument(ctx context.Context, docID string) error {
+	if !s.Enabled() {
+		return nil
+	}
+
+	if err := s.index.Remove(ctx, docID); err != nil {
+		logger.Error("failed to remove document chunks", "error", err, "document_id", docID)
+		return errors.New("failed to remove document embeddings")
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ?", docID).Delete(&domain.DocumentEmbedding{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("document_id = ? OR duplicate_id = ?", docID, docID).Delete(&domain.DocumentDuplicate{}).Error
+	})
+	if err != nil {
+		logger.Error("failed to remove document embedding", "error", err, "document_id", docID)
+		return errors.New("failed to remove document embeddings")
+	}
+
+	return nil
+}
+
+// SemanticSearch returns the documents of a team closest in meaning to a query
+func (s *SemanticService) SemanticSearch(ctx context.Context, query, teamID string, limit int) ([]*SemanticHit, error) {
+	if !s.Enabled() {
+		return nil, errors.New("semantic search is not configured")
+	}
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, errors.New("query is required")
+	}
+	limit = semanticLimit(limit)
+
+	vectors, err := s.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		logger.Error("failed to embed query", "error", err)
+		return nil, errors.New("failed to search documents")
+	}
+
+	return s.search(ctx, vectors[0], limit, vector.Filter{TeamID: teamID})
+}
+
+// MoreLikeThis returns the documents of the team of a document closest in
+// meaning to it. Documents embedded before semantic search was enabled are
+// embedded first.
+func (s *SemanticService) MoreLikeThis(ctx context.Context, docID string, limit int) ([]*SemanticHit, error) {
+	if !s.Enabled() {
+		return nil, errors.New("semantic search is not configured")
+	}
+	limit = semanticLimit(limit)
+
+	var document domain.Document
+	if err := s.db.Where("id = ?", docID).First(&document).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("document not found")
+		}
+		logger.Error("failed to find document", "error", err)
+		return nil, errors.New("failed to find similar documents")
+	}
+
+	embedding, err := s.findEmbedding(docID)
+	if err == nil && embedding == nil {
+		if err = s.IndexDocument(ctx, docID); err == nil {
+			embedding, err = s.findEmbedding(docID)
+		}
+	}
+	if err != nil || embedding == nil {
+		return nil, errors.New("failed to find similar documents")
+	}
+
+	return s.search(ctx, vector.Decode(embedding.Centroid), limit, vector.Filter{TeamID: document.TeamID, ExcludeDocumentID: docID})
+}
+
+// GetNearDuplicates returns the documents recorded as near-duplicates of a document
+func (s *SemanticService) GetNearDuplicates(ctx context.Context, docID string) ([]*NearDuplicate, error) {
+	var count int64
+	if err := s.db.Model(&domain.Document{}).Where("id = ?", docID).Count(&count).Error; err != nil {
+		logger.Error("failed to find document", "error", err)
+		return nil, errors.New("failed to get near-duplicates")
+	}
+	if count == 0 {
+		return nil, errors.New("document not found")
+	}
+
+	var records []*domain.DocumentDuplicate
+	if err := s.db.Where("document_id = ?", docID).Order("similarity DESC").Find(&records).Error; err != nil {
+		logger.Error("failed to find near-duplicates", "error", err, "document_id", docID)
+		return nil, errors.New("failed to get near-duplicates")
+	}
+	ids := make([]string, len(records))
+	for i, record := range records {
+		ids[i] = record.DuplicateID
+	}
+	documents, err := s.findDocuments(ids)
+	if err != nil {
+		return nil, errors.New("failed to get near-duplicates")
+	}
+
+	duplicates := make([]*NearDuplicate, 0, len(records))
+	for _, record := range records {
+		if document, ok := documents[record.DuplicateID]; ok {
+			duplicates = append(duplicates, &NearDuplicate{Document: document, Similarity: record.Similarity, DetectedAt: record.DetectedAt})
+		}
+	}
+	return duplicates, nil
+}
+
+// search returns the documents of the chunks closest to a vector, scored by
+// their best chunk
+func (s *SemanticService) search(ctx context.Context, query []float32, limit int, filter vector.Filter) ([]*SemanticHit, error) {
+	matches, err := s.index.Search(ctx, query, limit*chunksPerDocument, filter)
+	if err != nil {
+		logger.Error("failed to search document chunks", "error", err)
+		return nil, errors.New("failed to search documents")
+	}
+
+	best := make(map[string]vector.Match)
+	var documentIDs, chunkIDs []string
+	for _, match := range matches {
+		if _, ok := best[match.DocumentID]; ok || match.Score <= 0 {
+			continue
+		}
+		best[match.DocumentID] = match
+		documentIDs = append(documentIDs, match.DocumentID)
+		chunkIDs = append(chunkIDs, match.ChunkID)
+	}
+
+	documents, err := s.findDocuments(documentIDs)
+	if err != nil {
+		return nil, errors.New("failed to search documents")
+	}
+	var chunks []*domain.DocumentChunk
+	if len(chunkIDs) > 0 {
+		if err := s.db.Select("id", "content").Where("id IN ?", chunkIDs).Find(&chunks).Error; err != nil {
+			logger.Error("failed to find document chunks", "error", err)
+			return nil, errors.New("failed to search documents")
+		}
+	}
+	passages := make(map[string]string, len(chunks))
+	for _, chunk := range chunks {
+		passages[chunk.ID] = truncatePassage(chunk.Content)
+	}
+
+	hits := make([]*SemanticHit, 0, len(documentIDs))
+	for _, documentID := range documentIDs {
+		document, ok := documents[documentID]
+		if !ok {
+			continue
+		}
+		match := best[documentID]
+		hits = append(hits, &SemanticHit{Document: document, Score: match.Score, Passage: passages[match.ChunkID]})
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits, nil
+}
+
+// detectDuplicates records the documents of the team of a document whose
+// centroid is at least duplicateThreshold similar to its own
+func (s *SemanticService) detectDuplicates(document *domain.Document, centroid []float32) error {
+	var others []*domain.DocumentEmbedding
+	if err := s.db.Select("document_id", "centroid").
+		Where("team_id = ? AND document_id <> ?", document.TeamID, document.ID).
+		Find(&others).Error; err != nil {
+		logger.Error("failed to find document embeddings", "error", err, "document_id", document.ID)
+		return errors.New("failed to detect near-duplicates")
+	}
+
+	var duplicates []*domain.DocumentDuplicate
+	now := time.Now()
+	for _, other := range others {
+		similarity := vector.Cosine(centroid, vector.Decode(other.Centroid))
+		if similarity < s.duplicateThreshold {
+			continue
+		}
+		duplicates = append(duplicates,
+			&domain.DocumentDuplicate{DocumentID: document.ID, DuplicateID: other.DocumentID, Similarity: similarity, DetectedAt: now},
+			&domain.DocumentDuplicate{DocumentID: other.DocumentID, DuplicateID: document.ID, Similarity: similarity, DetectedAt: now},
+		)
+		logger.Info("near-duplicate document detected", "document_id", document.ID, "duplicate_id", other.DocumentID, "similarity", similarity)
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ? OR duplicate_id = ?", document.ID, document.ID).Delete(&domain.DocumentDuplicate{}).Error; err != nil {
+			return err
+		}
+		if len(duplicates) == 0 {
+			return nil
+		}
+		return tx.Create(duplicates).Error
+	})
+	if err != nil {
+		logger.Error("failed to save near-duplicates", "error", err, "document_id", document.ID)
+		return errors.New("failed to detect near-duplicates")
+	}
+	return nil
+}
+
+// findEmbedding returns the embedding record of a document, or nil when it was not embedded
+func (s *SemanticService) findEmbedding(docID string) (*domain.DocumentEmbedding, error) {
+	var embedding domain.DocumentEmbedding
+	if err := s.db.Where("document_id = ?", docID).First(&embedding).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		logger.Error("failed to find document embedding", "error", err, "document_id", docID)
+		return nil, err
+	}
+	return &embedding, nil
+}
+
+// findDocuments loads documents keyed by ID
+func (s *SemanticService) findDocuments(ids []string) (map[string]*domain.Document, error) {
+	documents := make(map[string]*domain.Document, len(ids))
+	if len(ids) == 0 {
+		return documents, nil
+	}
+	var rows []*domain.Document
+	if err := s.db.Where("id IN ?", ids).Find(&rows).Error; err != nil {
+		logger.Error("failed to find documents", "error", err)
+		return nil, err
+	}
+	for _, row := range rows {
+		documents[row.ID] = row
+	}
+	return documents, nil
+}
+
+// fingerprint identifies what the chunks of a document are embedded from
+func (s *SemanticService) fingerprint(document *domain.Document, text string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\n%s\n%d/%d\n%s", s.embedder.Model(), document.TeamID, s.chunkSize, s.chunkOverlap, text)))
+	return hex.EncodeToString(sum[:])
+}
+
+// semanticLimit validates the number of documents to return
+func semanticLimit(limit int) int {
+	if limit < 1 {
+		return defaultSemanticLimit
+	}
+	if limit > maxSemanticLimit {
+		return maxSemanticLimit
+	}
+	return limit
+}
+
+// truncatePassage shortens a passage to maxPassageLength bytes at a word boundary
+func truncatePassage(passage string) string {
+	if len(passage) <= maxPassageLength {
+		return passage
+	}
+	cut := strings.LastIndexByte(passage[:maxPassageLength], ' ')
+	if cut <= 0 {
+		cut = maxPassageLength
+		for cut > 0 && !utf8.RuneStart(passage[cut]) {
+			cut--
+		}
+	}
+	return passage[:cut] + "…"
+}
+
+// nonEmpty returns the values that are not blank
+func nonEmpty(values ...string) []string {
+	var kept []string
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
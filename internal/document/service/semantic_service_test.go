@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"cdk-office/internal/document/domain"
+	"cdk-office/internal/document/storage"
+	"cdk-office/internal/document/vector"
+	"cdk-office/internal/shared/testutils"
+	"cdk-office/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSemanticService tests semantic search, more-like-this and near-duplicate
+// detection over documents embedded on upload with the hash embedder
+func TestSemanticService(t *testing.T) {
+	ctx := context.Background()
+	db := testutils.SetupTestDB()
+	driver, err := storage.NewLocalDriver(t.TempDir())
+	require.NoError(t, err)
+	storageService := NewStorageServiceWithDriver(driver)
+	semanticService := NewSemanticServiceWithDeps(db, vector.NewHashEmbedder(256), vector.NewHNSWIndex(db, 256), &config.Default().Vector)
+	documentService := NewDocumentServiceWithIndexer(db, storageService, DocumentIndexers{NewSearchServiceWithStorage(db, storageService), semanticService})
+
+	upload := func(title, text, teamID string) *domain.Document {
+		stored, err := storageService.SaveFile(ctx, strings.NewReader(text))
+		require.NoError(t, err)
+		document, err := documentService.Upload(ctx, &UploadRequest{
+			Title: title, FilePath: stored.Key, FileSize: stored.Size, MimeType: "text/plain", OwnerID: "user_1", TeamID: teamID,
+		})
+		require.NoError(t, err)
+		return document
+	}
+	travel := upload("Travel policy", "Hotels are reimbursed up to 150 euros per night. Flights are booked by the office in economy class.", "team_1")
+	travelCopy := upload("Travel policy (copy)", "Hotels are reimbursed up to 150 euros per night. Flights are booked by the office in economy class.", "team_1")
+	minutes := upload("Board minutes", "The board approved the quarterly budget and the hiring plan.", "team_1")
+	upload("Travel policy", "Hotels are reimbursed up to 150 euros per night. Flights are booked by the office in economy class.", "team_2")
+
+	hits, err := semanticService.SemanticSearch(ctx, "hotel reimbursement per night", "team_1", 10)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(hits), 2)
+	assert.ElementsMatch(t, []string{travel.ID, travelCopy.ID}, []string{hits[0].Document.ID, hits[1].Document.ID})
+	assert.Contains(t, hits[0].Passage, "Hotels are reimbursed")
+
+	similar, err := semanticService.MoreLikeThis(ctx, travel.ID, 10)
+	require.NoError(t, err)
+	require.NotEmpty(t, similar)
+	assert.Equal(t, travelCopy.ID, similar[0].Document.ID)
+	for _, hit := range similar {
+		assert.NotEqual(t, travel.ID, hit.Document.ID)
+		assert.Equal(t, "team_1", hit.Document.TeamID)
+	}
+
+	// The copy is a near-duplicate, in both directions, unlike documents of other teams
+	duplicates, err := semanticService.GetNearDuplicates(ctx, travel.ID)
+	require.NoError(t, err)
+	require.Len(t, duplicates, 1)
+	assert.Equal(t, travelCopy.ID, duplicates[0].Document.ID)
+	assert.GreaterOrEqual(t, duplicates[0].Similarity, 0.95)
+	duplicates, err = semanticService.GetNearDuplicates(ctx, minutes.ID)
+	require.NoError(t, err)
+	assert.Empty(t, duplicates)
+
+	// Deleting a document removes its chunks and duplicate records
+	require.NoError(t, documentService.DeleteDocument(ctx, travelCopy.ID))
+	duplicates, err = semanticService.GetNearDuplicates(ctx, travel.ID)
+	require.NoError(t, err)
+	assert.Empty(t, duplicates)
+	var count int64
+	db.Model(&domain.DocumentChunk{}).Where("document_id = ?", travelCopy.ID).Count(&count)
+	assert.Zero(t, count)
+
+	_, err = semanticService.SemanticSearch(ctx, " ", "team_1", 10)
+	assert.EqualError(t, err, "query is required")
+	_, err = semanticService.MoreLikeThis(ctx, "doc_missing", 10)
+	assert.EqualError(t, err, "document not found")
+	_, err = NewSemanticServiceWithDeps(db, nil, nil, &config.Default().Vector).SemanticSearch(ctx, "hotels", "team_1", 10)
+	assert.EqualError(t, err, "semantic search is not configured")
+}
@@ -2,112 +2,185 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
-	"mime/multipart"
 	"os"
-	"path/filepath"
-	"time"
 
+	"cdk-office/internal/document/storage"
+	"cdk-office/pkg/config"
 	"cdk-office/pkg/logger"
 )
 
 // StorageServiceInterface defines the interface for storage service
 type StorageServiceInterface interface {
-	SaveFile(ctx context.Context, file multipart.File, header *multipart.FileHeader, userID string) (string, error)
-	DeleteFile(ctx context.Context, filePath string) error
-	GetFile(ctx context.Context, filePath string) (io.ReadCloser, error)
+	SaveFile(ctx context.Context, file io.Reader) (*StoredFile, error)
+	PutObject(ctx context.Context, key string, r io.Reader, size int64) error
+	DeleteFile(ctx context.Context, key string) error
+	GetFile(ctx context.Context, key string) (io.ReadCloser, error)
+	LocalCopy(ctx context.Context, key string) (string, func(), error)
 }
 
-// StorageService implements the StorageServiceInterface
+// StoredFile describes a file saved in the storage backend
+type StoredFile struct {
+	Key         string `json:"key"`
+	ContentHash string `json:"content_hash"`
+	Size        int64  `json:"size"`
+}
+
+// StorageService implements the StorageServiceInterface.
+// Files are stored under the SHA-256 hash of their content, so saving the same
+// content twice (re-uploads, unchanged versions) only stores it once.
 type StorageService struct {
-	storagePath string
+	driver storage.Driver
 }
 
-// NewStorageService creates a new instance of StorageService
-func NewStorageService() *StorageService {
-	// In a real application, this path would be configurable
-	storagePath := "/var/cdk-office/storage"
-	
-	// Create storage directory if it doesn't exist
-	if err := os.MkdirAll(storagePath, 0755); err != nil {
-		logger.Error("failed to create storage directory", "error", err)
-		// In a real application, you might want to handle this error more gracefully
-		// For now, we'll just log it and continue with a fallback path
-		storagePath = "./storage"
-		os.MkdirAll(storagePath, 0755)
-	}
-	
+// NewStorageService creates a new instance of StorageService using the configured driver
+func NewStorageService(cfg *config.StorageConfig) (*StorageService, error) {
+	driver, err := storage.NewDriver(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	return NewStorageServiceWithDriver(driver), nil
+}
+
+// NewStorageServiceWithDriver creates a new instance of StorageService with a specific driver
+func NewStorageServiceWithDriver(driver storage.Driver) *StorageService {
 	return &StorageService{
-		storagePath: storagePath,
+		driver: driver,
 	}
 }
 
-// SaveFile saves a file to the storage system
-func (s *StorageService) SaveFile(ctx context.Context, file multipart.File, header *multipart.FileHeader, userID string) (string, error) {
-	// Generate a unique file name
-	fileName := fmt.Sprintf("%s_%s%s", 
-		userID, 
-		time.Now().Format("20060102150405"), 
-		filepath.Ext(header.Filename))
-	
-	// Create user directory if it doesn't exist
-	userDir := filepath.Join(s.storagePath, userID)
-	if err := os.MkdirAll(userDir, 0755); err != nil {
-		logger.Error("failed to create user directory", "error", err)
-		return "", errors.New("failed to save file")
-	}
-	
-	// Create file path
-	filePath := filepath.Join(userDir, fileName)
-	
-	// Create the file
-	dst, err := os.Create(filePath)
+// SaveFile saves a file to the storage system and returns its content-addressed key
+func (s *StorageService) SaveFile(ctx context.Context, file io.Reader) (*StoredFile, error) {
+	// Spool the content to a temporary file while hashing it, since the key
+	// is only known once the whole content has been read
+	tmp, err := os.CreateTemp("", "cdk-office-upload-*")
 	if err != nil {
-		logger.Error("failed to create file", "error", err)
-		return "", errors.New("failed to save file")
+		logger.Error("failed to create temporary file", "error", err)
+		return nil, errors.New("failed to save file")
 	}
-	defer dst.Close()
-	
-	// Copy file content
-	if _, err := io.Copy(dst, file); err != nil {
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), file)
+	if err != nil {
 		logger.Error("failed to copy file content", "error", err)
-		return "", errors.New("failed to save file")
+		return nil, errors.New("failed to save file")
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	stored := &StoredFile{
+		Key:         storage.ContentKey(hash),
+		ContentHash: hash,
+		Size:        size,
 	}
-	
-	return filePath, nil
+
+	// Skip the upload if the same content is already stored
+	exists, err := s.driver.Exists(ctx, stored.Key)
+	if err != nil {
+		logger.Error("failed to check file existence", "error", err)
+		return nil, errors.New("failed to save file")
+	}
+	if exists {
+		return stored, nil
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		logger.Error("failed to rewind temporary file", "error", err)
+		return nil, errors.New("failed to save file")
+	}
+
+	if err := s.driver.Put(ctx, stored.Key, tmp, size); err != nil {
+		logger.Error("failed to store file", "error", err)
+		return nil, errors.New("failed to save file")
+	}
+
+	return stored, nil
+}
+
+// PutObject stores content under an explicit key. It is used for transient
+// objects such as upload chunks that must not be content-addressed.
+func (s *StorageService) PutObject(ctx context.Context, key string, r io.Reader, size int64) error {
+	if err := s.driver.Put(ctx, key, r, size); err != nil {
+		logger.Error("failed to store object", "key", key, "error", err)
+		return errors.New("failed to save file")
+	}
+
+	return nil
 }
 
 // DeleteFile deletes a file from the storage system
-func (s *StorageService) DeleteFile(ctx context.Context, filePath string) error {
-	// Check if file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return errors.New("file not found")
-	}
-	
-	// Delete the file
-	if err := os.Remove(filePath); err != nil {
+func (s *StorageService) DeleteFile(ctx context.Context, key string) error {
+	if err := s.driver.Delete(ctx, key); err != nil {
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			return errors.New("file not found")
+		}
 		logger.Error("failed to delete file", "error", err)
 		return errors.New("failed to delete file")
 	}
-	
+
 	return nil
 }
 
 // GetFile retrieves a file from the storage system
-func (s *StorageService) GetFile(ctx context.Context, filePath string) (io.ReadCloser, error) {
-	// Check if file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return nil, errors.New("file not found")
-	}
-	
-	// Open the file
-	file, err := os.Open(filePath)
+func (s *StorageService) GetFile(ctx context.Context, key string) (io.ReadCloser, error) {
+	file, err := s.driver.Get(ctx, key)
 	if err != nil {
-		logger.Error("failed to open file", "error", err)
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			return nil, errors.New("file not found")
+		}
+		logger.Error("failed to get file", "error", err)
 		return nil, errors.New("failed to get file")
 	}
-	
+
 	return file, nil
-}
\ No newline at end of file
+}
+
+// LocalCopy returns a local filesystem path holding the file's content, for
+// extractors that need random access. The returned cleanup function must be
+// called once the path is no longer needed.
+func (s *StorageService) LocalCopy(ctx context.Context, key string) (string, func(), error) {
+	// Local drivers can hand out the stored file directly
+	if pather, ok := s.driver.(storage.LocalPather); ok {
+		filePath, err := pather.LocalPath(key)
+		if err != nil {
+			return "", nil, errors.New("file not found")
+		}
+		if _, err := os.Stat(filePath); os.IsNotExist(err) {
+			return "", nil, errors.New("file not found")
+		}
+		return filePath, func() {}, nil
+	}
+
+	file, err := s.GetFile(ctx, key)
+	if err != nil {
+		return "", nil, err
+	}
+	defer file.Close()
+
+	tmp, err := os.CreateTemp("", "cdk-office-download-*")
+	if err != nil {
+		logger.Error("failed to create temporary file", "error", err)
+		return "", nil, errors.New("failed to get file")
+	}
+	cleanup := func() { os.Remove(tmp.Name()) }
+
+	if _, err := io.Copy(tmp, file); err != nil {
+		tmp.Close()
+		cleanup()
+		logger.Error("failed to download file", "error", err)
+		return "", nil, errors.New("failed to get file")
+	}
+	if err := tmp.Close(); err != nil {
+		cleanup()
+		logger.Error("failed to close temporary file", "error", err)
+		return "", nil, errors.New("failed to get file")
+	}
+
+	return tmp.Name(), cleanup, nil
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cdk-office/internal/document/storage"
+	"cdk-office/internal/shared/testutils"
+	"cdk-office/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestNewStorageService tests that the storage service fails to initialize
+// rather than running without a driver
+func TestNewStorageService(t *testing.T) {
+	dir := t.TempDir()
+
+	storageService, err := NewStorageService(&config.StorageConfig{Driver: "local", LocalPath: filepath.Join(dir, "files")})
+	assert.NoError(t, err)
+	assert.NotNil(t, storageService)
+
+	// A driver that can not be initialized fails instead of falling back to
+	// another location
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "blocked"), nil, 0644))
+	storageService, err = NewStorageService(&config.StorageConfig{Driver: "local", LocalPath: filepath.Join(dir, "blocked", "files")})
+	assert.ErrorContains(t, err, "failed to initialize storage")
+	assert.Nil(t, storageService)
+
+	storageService, err = NewStorageService(&config.StorageConfig{Driver: "s3", S3: config.S3Config{Bucket: "cdk-office"}})
+	assert.EqualError(t, err, "failed to initialize storage: s3 endpoint is required")
+	assert.Nil(t, storageService)
+}
+
+// TestStorageService tests the StorageService
+func TestStorageService(t *testing.T) {
+	ctx := context.Background()
+	driver, err := storage.NewLocalDriver(t.TempDir())
+	assert.NoError(t, err)
+	storageService := NewStorageServiceWithDriver(driver)
+
+	t.Run("SaveFileIsContentAddressed", func(t *testing.T) {
+		first, err := storageService.SaveFile(ctx, strings.NewReader("same content"))
+		assert.NoError(t, err)
+		second, err := storageService.SaveFile(ctx, strings.NewReader("same content"))
+		assert.NoError(t, err)
+		other, err := storageService.SaveFile(ctx, strings.NewReader("other content"))
+		assert.NoError(t, err)
+
+		assert.Equal(t, first.Key, second.Key)
+		assert.NotEqual(t, first.Key, other.Key)
+		assert.Equal(t, int64(12), first.Size)
+		assert.Equal(t, storage.ContentKey(first.ContentHash), first.Key)
+
+		r, err := storageService.GetFile(ctx, first.Key)
+		assert.NoError(t, err)
+		defer r.Close()
+		data, _ := io.ReadAll(r)
+		assert.Equal(t, "same content", string(data))
+	})
+
+	t.Run("LocalCopy", func(t *testing.T) {
+		stored, err := storageService.SaveFile(ctx, strings.NewReader("local"))
+		assert.NoError(t, err)
+
+		filePath, cleanup, err := storageService.LocalCopy(ctx, stored.Key)
+		assert.NoError(t, err)
+		defer cleanup()
+		assert.NotEmpty(t, filePath)
+
+		_, _, err = storageService.LocalCopy(ctx, storage.ContentKey(strings.Repeat("0", 64)))
+		assert.EqualError(t, err, "file not found")
+	})
+
+	t.Run("DeleteFile", func(t *testing.T) {
+		stored, err := storageService.SaveFile(ctx, strings.NewReader("to delete"))
+		assert.NoError(t, err)
+
+		assert.NoError(t, storageService.DeleteFile(ctx, stored.Key))
+		assert.EqualError(t, storageService.DeleteFile(ctx, stored.Key), "file not found")
+		_, err = storageService.GetFile(ctx, stored.Key)
+		assert.EqualError(t, err, "file not found")
+	})
+}
+
+// TestDocumentService_DeleteReleasesFiles tests that deleting a document only removes unshared files
+func TestDocumentService_DeleteReleasesFiles(t *testing.T) {
+	ctx := context.Background()
+	testDB := testutils.SetupTestDB()
+	driver, err := storage.NewLocalDriver(t.TempDir())
+	assert.NoError(t, err)
+	storageService := NewStorageServiceWithDriver(driver)
+	documentService := NewDocumentServiceWithStorage(testDB, storageService)
+
+	shared, err := storageService.SaveFile(ctx, strings.NewReader("shared content"))
+	assert.NoError(t, err)
+	unique, err := storageService.SaveFile(ctx, strings.NewReader("unique content"))
+	assert.NoError(t, err)
+
+	first, err := documentService.Upload(ctx, &UploadRequest{
+		Title: "First", FilePath: shared.Key, FileSize: shared.Size, MimeType: "text/plain", OwnerID: "user_1", TeamID: "team_1",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, shared.ContentHash, first.ContentHash)
+
+	second, err := documentService.Upload(ctx, &UploadRequest{
+		Title: "Second", FilePath: shared.Key, FileSize: shared.Size, MimeType: "text/plain", OwnerID: "user_1", TeamID: "team_1",
+	})
+	assert.NoError(t, err)
+
+	third, err := documentService.Upload(ctx, &UploadRequest{
+		Title: "Third", FilePath: unique.Key, FileSize: unique.Size, MimeType: "text/plain", OwnerID: "user_1", TeamID: "team_1",
+	})
+	assert.NoError(t, err)
+
+	// The shared file is still used by the second document
+	assert.NoError(t, documentService.DeleteDocument(ctx, first.ID))
+	exists, _ := driver.Exists(ctx, shared.Key)
+	assert.True(t, exists)
+
+	assert.NoError(t, documentService.DeleteDocument(ctx, second.ID))
+	exists, _ = driver.Exists(ctx, shared.Key)
+	assert.False(t, exists)
+
+	assert.NoError(t, documentService.DeleteDocument(ctx, third.ID))
+	exists, _ = driver.Exists(ctx, unique.Key)
+	assert.False(t, exists)
+}
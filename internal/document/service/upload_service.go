@@ -0,0 +1,522 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"cdk-office/internal/document/domain"
+	"cdk-office/internal/shared/database"
+	"cdk-office/internal/shared/utils"
+	"cdk-office/pkg/config"
+	"cdk-office/pkg/logger"
+	"gorm.io/gorm"
+)
+
+const (
+	// MinChunkSize is the smallest chunk size a client may request (except for the last chunk)
+	MinChunkSize = 256 * 1024
+	// MaxChunkSize is the largest chunk size a client may request
+	MaxChunkSize = 16 * 1024 * 1024
+	// MaxUploadSize is the largest file that can be uploaded through a session
+	MaxUploadSize = 2 * 1024 * 1024 * 1024
+	// UploadSessionTTL is how long a session stays alive after its last activity
+	UploadSessionTTL = 24 * time.Hour
+)
+
+// UploadServiceInterface defines the interface for resumable upload service
+type UploadServiceInterface interface {
+	InitUpload(ctx context.Context, req *InitUploadRequest) (*domain.UploadSession, error)
+	UploadChunk(ctx context.Context, sessionID, userID string, chunkIndex int, checksum string, data io.Reader) (*domain.UploadChunk, error)
+	GetUploadStatus(ctx context.Context, sessionID, userID string) (*UploadStatus, error)
+	CompleteUpload(ctx context.Context, sessionID, userID string) (*domain.Document, error)
+	AbortUpload(ctx context.Context, sessionID, userID string) error
+	CleanupExpiredSessions(ctx context.Context) (int, error)
+}
+
+// UploadService implements the UploadServiceInterface
+type UploadService struct {
+	db              *gorm.DB
+	storageService  StorageServiceInterface
+	documentService DocumentServiceInterface
+}
+
+// NewUploadService creates a new instance of UploadService
+func NewUploadService(cfg *config.Config) (*UploadService, error) {
+	db := database.GetDB()
+	storageService, err := NewStorageService(&cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	return &UploadService{
+		db:              db,
+		storageService:  storageService,
+		documentService: NewDocumentServiceWithIndexer(db, storageService, newDocumentIndexer(db, storageService, cfg)),
+	}, nil
+}
+
+// NewUploadServiceWithDeps creates a new instance of UploadService with specific dependencies
+func NewUploadServiceWithDeps(db *gorm.DB, storageService StorageServiceInterface, documentService DocumentServiceInterface) *UploadService {
+	return &UploadService{
+		db:              db,
+		storageService:  storageService,
+		documentService: documentService,
+	}
+}
+
+// InitUploadRequest represents the request for starting a resumable upload
+type InitUploadRequest struct {
+	OwnerID     string `json:"owner_id"`
+	TeamID      string `json:"team_id" binding:"required"`
+	Title       string `json:"title" binding:"required"`
+	Description string `json:"description"`
+	Tags        string `json:"tags"`
+	FileName    string `json:"file_name" binding:"required"`
+	MimeType    string `json:"mime_type" binding:"required"`
+	TotalSize   int64  `json:"total_size" binding:"required"`
+	ChunkSize   int64  `json:"chunk_size" binding:"required"`
+	FileHash    string `json:"file_hash"`
+}
+
+// ByteRange represents an inclusive range of received bytes
+type ByteRange struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// UploadStatus represents the progress of an upload session
+type UploadStatus struct {
+	Session        *domain.UploadSession `json:"session"`
+	ReceivedChunks []int                 `json:"received_chunks"`
+	MissingChunks  []int                 `json:"missing_chunks"`
+	ReceivedRanges []ByteRange           `json:"received_ranges"`
+	ReceivedBytes  int64                 `json:"received_bytes"`
+}
+
+// InitUpload starts a new resumable upload session
+func (s *UploadService) InitUpload(ctx context.Context, req *InitUploadRequest) (*domain.UploadSession, error) {
+	if req.OwnerID == "" {
+		return nil, errors.New("owner id is required")
+	}
+	if req.TotalSize <= 0 || req.TotalSize > MaxUploadSize {
+		return nil, fmt.Errorf("total size must be between 1 and %d bytes", MaxUploadSize)
+	}
+	if req.ChunkSize < MinChunkSize || req.ChunkSize > MaxChunkSize {
+		return nil, fmt.Errorf("chunk size must be between %d and %d bytes", MinChunkSize, MaxChunkSize)
+	}
+	fileHash := strings.ToLower(req.FileHash)
+	if fileHash != "" && !isSHA256Hex(fileHash) {
+		return nil, errors.New("file hash must be a hex-encoded SHA-256 digest")
+	}
+
+	// The completed upload becomes a document of the team, so only its members may start one
+	member, err := isTeamMember(ctx, s.db, req.OwnerID, req.TeamID)
+	if err != nil {
+		return nil, errors.New("failed to init upload")
+	}
+	if !member {
+		return nil, errors.New("user is not a member of the team")
+	}
+
+	now := time.Now()
+	session := &domain.UploadSession{
+		ID:          utils.GenerateUploadSessionID(),
+		OwnerID:     req.OwnerID,
+		TeamID:      req.TeamID,
+		Title:       req.Title,
+		Description: req.Description,
+		Tags:        req.Tags,
+		FileName:    req.FileName,
+		MimeType:    req.MimeType,
+		TotalSize:   req.TotalSize,
+		ChunkSize:   req.ChunkSize,
+		TotalChunks: int((req.TotalSize + req.ChunkSize - 1) / req.ChunkSize),
+		FileHash:    fileHash,
+		Status:      "uploading",
+		ExpiresAt:   now.Add(UploadSessionTTL),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if err := s.db.Create(session).Error; err != nil {
+		logger.Error("failed to create upload session", "error", err)
+		return nil, errors.New("failed to init upload")
+	}
+
+	return session, nil
+}
+
+// UploadChunk stores one chunk of an upload session after verifying its SHA-256 checksum.
+// Uploading a chunk that was already received replaces it, so clients can safely retry.
+func (s *UploadService) UploadChunk(ctx context.Context, sessionID, userID string, chunkIndex int, checksum string, data io.Reader) (*domain.UploadChunk, error) {
+	session, err := s.getActiveSession(sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if chunkIndex < 0 || chunkIndex >= session.TotalChunks {
+		return nil, errors.New("invalid chunk index")
+	}
+	checksum = strings.ToLower(checksum)
+	if !isSHA256Hex(checksum) {
+		return nil, errors.New("chunk checksum must be a hex-encoded SHA-256 digest")
+	}
+
+	// Read at most one byte more than expected so oversized chunks are detected
+	expectedSize := chunkLength(session, chunkIndex)
+	content, err := io.ReadAll(io.LimitReader(data, expectedSize+1))
+	if err != nil {
+		logger.Error("failed to read chunk", "error", err)
+		return nil, errors.New("failed to upload chunk")
+	}
+	if int64(len(content)) != expectedSize {
+		return nil, fmt.Errorf("chunk size mismatch: expected %d bytes, got %d", expectedSize, len(content))
+	}
+
+	sum := sha256.Sum256(content)
+	if hex.EncodeToString(sum[:]) != checksum {
+		return nil, errors.New("chunk checksum mismatch")
+	}
+
+	key := chunkStorageKey(session.ID, chunkIndex)
+	if err := s.storageService.PutObject(ctx, key, bytes.NewReader(content), expectedSize); err != nil {
+		return nil, errors.New("failed to upload chunk")
+	}
+
+	chunk := &domain.UploadChunk{
+		ID:         utils.GenerateUploadChunkID(),
+		SessionID:  session.ID,
+		ChunkIndex: chunkIndex,
+		Size:       expectedSize,
+		Checksum:   checksum,
+		StorageKey: key,
+		CreatedAt:  time.Now(),
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("session_id = ? AND chunk_index = ?", session.ID, chunkIndex).Delete(&domain.UploadChunk{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(chunk).Error; err != nil {
+			return err
+		}
+		// Keep the session alive while the client is making progress
+		return tx.Model(session).Updates(map[string]interface{}{
+			"expires_at": time.Now().Add(UploadSessionTTL),
+			"updated_at": time.Now(),
+		}).Error
+	})
+	if err != nil {
+		logger.Error("failed to record chunk", "error", err)
+		return nil, errors.New("failed to upload chunk")
+	}
+
+	return chunk, nil
+}
+
+// GetUploadStatus returns the received chunks and byte ranges of an upload session
+func (s *UploadService) GetUploadStatus(ctx context.Context, sessionID, userID string) (*UploadStatus, error) {
+	session, err := s.getSession(sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks, err := s.listChunks(session.ID)
+	if err != nil {
+		return nil, errors.New("failed to get upload status")
+	}
+
+	status := &UploadStatus{
+		Session:        session,
+		ReceivedChunks: []int{},
+		MissingChunks:  []int{},
+		ReceivedRanges: []ByteRange{},
+	}
+
+	received := make(map[int]bool, len(chunks))
+	for _, chunk := range chunks {
+		received[chunk.ChunkIndex] = true
+		status.ReceivedBytes += chunk.Size
+	}
+
+	for i := 0; i < session.TotalChunks; i++ {
+		if !received[i] {
+			status.MissingChunks = append(status.MissingChunks, i)
+			continue
+		}
+		status.ReceivedChunks = append(status.ReceivedChunks, i)
+
+		// Merge adjacent chunks into contiguous byte ranges
+		start := int64(i) * session.ChunkSize
+		end := start + chunkLength(session, i) - 1
+		if n := len(status.ReceivedRanges); n > 0 && status.ReceivedRanges[n-1].End+1 == start {
+			status.ReceivedRanges[n-1].End = end
+		} else {
+			status.ReceivedRanges = append(status.ReceivedRanges, ByteRange{Start: start, End: end})
+		}
+	}
+
+	return status, nil
+}
+
+// CompleteUpload assembles the received chunks into a stored file and creates the document
+func (s *UploadService) CompleteUpload(ctx context.Context, sessionID, userID string) (*domain.Document, error) {
+	session, err := s.getActiveSession(sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks, err := s.listChunks(session.ID)
+	if err != nil {
+		return nil, errors.New("failed to complete upload")
+	}
+	if len(chunks) != session.TotalChunks {
+		return nil, fmt.Errorf("upload incomplete: received %d of %d chunks", len(chunks), session.TotalChunks)
+	}
+
+	// Stream the chunks in order into content-addressed storage
+	reader := &chunkReader{ctx: ctx, storageService: s.storageService, chunks: chunks}
+	stored, err := s.storageService.SaveFile(ctx, reader)
+	reader.Close()
+	if err != nil {
+		logger.Error("failed to assemble upload", "session_id", session.ID, "error", err)
+		return nil, errors.New("failed to complete upload")
+	}
+
+	if stored.Size != session.TotalSize {
+		releaseStoredFiles(ctx, s.db, s.storageService, []string{stored.Key})
+		return nil, errors.New("assembled file size mismatch")
+	}
+	if session.FileHash != "" && stored.ContentHash != session.FileHash {
+		releaseStoredFiles(ctx, s.db, s.storageService, []string{stored.Key})
+		return nil, errors.New("file checksum mismatch")
+	}
+
+	document, err := s.documentService.Upload(ctx, &UploadRequest{
+		Title:       session.Title,
+		Description: session.Description,
+		FilePath:    stored.Key,
+		FileSize:    stored.Size,
+		MimeType:    session.MimeType,
+		OwnerID:     session.OwnerID,
+		TeamID:      session.TeamID,
+		Tags:        session.Tags,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Model(session).Updates(map[string]interface{}{
+		"status":      "completed",
+		"document_id": document.ID,
+		"updated_at":  time.Now(),
+	}).Error; err != nil {
+		logger.Error("failed to mark upload session completed", "error", err)
+	}
+
+	// The chunks are no longer needed once the file is assembled
+	s.deleteChunks(ctx, session.ID, chunks)
+
+	return document, nil
+}
+
+// AbortUpload cancels an upload session and discards its chunks
+func (s *UploadService) AbortUpload(ctx context.Context, sessionID, userID string) error {
+	session, err := s.getActiveSession(sessionID, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.removeSession(ctx, session); err != nil {
+		return errors.New("failed to abort upload")
+	}
+
+	return nil
+}
+
+// CleanupExpiredSessions removes abandoned upload sessions and their chunks.
+// It returns the number of sessions that were removed.
+func (s *UploadService) CleanupExpiredSessions(ctx context.Context) (int, error) {
+	var sessions []*domain.UploadSession
+	if err := s.db.Where("status <> ? AND expires_at < ?", "completed", time.Now()).Find(&sessions).Error; err != nil {
+		logger.Error("failed to find expired upload sessions", "error", err)
+		return 0, errors.New("failed to cleanup upload sessions")
+	}
+
+	removed := 0
+	for _, session := range sessions {
+		if err := s.removeSession(ctx, session); err != nil {
+			continue
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+// StartCleanup periodically removes expired upload sessions until the context is cancelled
+func (s *UploadService) StartCleanup(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if removed, err := s.CleanupExpiredSessions(ctx); err == nil && removed > 0 {
+					logger.Info("removed expired upload sessions", "count", removed)
+				}
+			}
+		}
+	}()
+}
+
+// getSession retrieves an upload session owned by the user
+func (s *UploadService) getSession(sessionID, userID string) (*domain.UploadSession, error) {
+	var session domain.UploadSession
+	if err := s.db.Where("id = ?", sessionID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("upload session not found")
+		}
+		logger.Error("failed to find upload session", "error", err)
+		return nil, errors.New("failed to get upload session")
+	}
+
+	// Sessions are private to the user who started them
+	if session.OwnerID != userID {
+		return nil, errors.New("upload session not found")
+	}
+
+	return &session, nil
+}
+
+// getActiveSession retrieves an upload session that can still receive chunks
+func (s *UploadService) getActiveSession(sessionID, userID string) (*domain.UploadSession, error) {
+	session, err := s.getSession(sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if session.Status == "completed" {
+		return nil, errors.New("upload session already completed")
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return nil, errors.New("upload session expired")
+	}
+
+	return session, nil
+}
+
+// listChunks lists the received chunks of a session in order
+func (s *UploadService) listChunks(sessionID string) ([]*domain.UploadChunk, error) {
+	var chunks []*domain.UploadChunk
+	if err := s.db.Where("session_id = ?", sessionID).Order("chunk_index asc").Find(&chunks).Error; err != nil {
+		logger.Error("failed to find upload chunks", "error", err)
+		return nil, err
+	}
+	return chunks, nil
+}
+
+// removeSession deletes a session together with its chunks
+func (s *UploadService) removeSession(ctx context.Context, session *domain.UploadSession) error {
+	chunks, err := s.listChunks(session.ID)
+	if err != nil {
+		return err
+	}
+
+	s.deleteChunks(ctx, session.ID, chunks)
+
+	if err := s.db.Delete(session).Error; err != nil {
+		logger.Error("failed to delete upload session", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// deleteChunks removes chunk objects from storage and their records from the database
+func (s *UploadService) deleteChunks(ctx context.Context, sessionID string, chunks []*domain.UploadChunk) {
+	for _, chunk := range chunks {
+		if err := s.storageService.DeleteFile(ctx, chunk.StorageKey); err != nil && err.Error() != "file not found" {
+			logger.Error("failed to delete upload chunk", "key", chunk.StorageKey, "error", err)
+		}
+	}
+
+	if err := s.db.Where("session_id = ?", sessionID).Delete(&domain.UploadChunk{}).Error; err != nil {
+		logger.Error("failed to delete upload chunk records", "error", err)
+	}
+}
+
+// chunkLength returns the expected size of a chunk; only the last chunk may be shorter
+func chunkLength(session *domain.UploadSession, chunkIndex int) int64 {
+	if chunkIndex == session.TotalChunks-1 {
+		return session.TotalSize - int64(chunkIndex)*session.ChunkSize
+	}
+	return session.ChunkSize
+}
+
+// chunkStorageKey returns the storage key of a chunk
+func chunkStorageKey(sessionID string, chunkIndex int) string {
+	return fmt.Sprintf("uploads/%s/%06d", sessionID, chunkIndex)
+}
+
+// isSHA256Hex checks whether s is a lowercase hex-encoded SHA-256 digest
+func isSHA256Hex(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// chunkReader reads the chunks of a session from storage one after another
+type chunkReader struct {
+	ctx            context.Context
+	storageService StorageServiceInterface
+	chunks         []*domain.UploadChunk
+	current        io.ReadCloser
+}
+
+// Read implements io.Reader
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.chunks) == 0 {
+				return 0, io.EOF
+			}
+			file, err := r.storageService.GetFile(r.ctx, r.chunks[0].StorageKey)
+			if err != nil {
+				return 0, err
+			}
+			r.current = file
+			r.chunks = r.chunks[1:]
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+// Close releases the chunk currently being read
+func (r *chunkReader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
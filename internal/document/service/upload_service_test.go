@@ -0,0 +1,158 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"testing"
+	"time"
+
+	"cdk-office/internal/document/domain"
+	"cdk-office/internal/document/storage"
+	employeedomain "cdk-office/internal/employee/domain"
+	"cdk-office/internal/shared/testutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func checksumOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// TestUploadService tests the resumable UploadService
+func TestUploadService(t *testing.T) {
+	ctx := context.Background()
+	testDB := testutils.SetupTestDB()
+	assert.NoError(t, testDB.Create(&employeedomain.Employee{ID: "emp_1", UserID: "user_1", TeamID: "team_1", EmployeeID: "E1", Status: "active"}).Error)
+	driver, err := storage.NewLocalDriver(t.TempDir())
+	assert.NoError(t, err)
+	storageService := NewStorageServiceWithDriver(driver)
+	uploadService := NewUploadServiceWithDeps(testDB, storageService, NewDocumentServiceWithStorage(testDB, storageService))
+
+	// 2.5 chunks of content
+	content := bytes.Repeat([]byte("0123456789abcdef"), MinChunkSize*5/2/16)
+	chunks := [][]byte{content[:MinChunkSize], content[MinChunkSize : 2*MinChunkSize], content[2*MinChunkSize:]}
+
+	newSession := func(t *testing.T) *domain.UploadSession {
+		session, err := uploadService.InitUpload(ctx, &InitUploadRequest{
+			OwnerID:   "user_1",
+			TeamID:    "team_1",
+			Title:     "Large scan",
+			FileName:  "scan.pdf",
+			MimeType:  "application/pdf",
+			TotalSize: int64(len(content)),
+			ChunkSize: MinChunkSize,
+			FileHash:  checksumOf(content),
+		})
+		assert.NoError(t, err)
+		return session
+	}
+
+	t.Run("InitUploadValidation", func(t *testing.T) {
+		_, err := uploadService.InitUpload(ctx, &InitUploadRequest{OwnerID: "user_1", TotalSize: 100, ChunkSize: 1})
+		assert.Error(t, err)
+
+		session := newSession(t)
+		assert.Equal(t, 3, session.TotalChunks)
+		assert.Equal(t, "uploading", session.Status)
+
+		_, err = uploadService.InitUpload(ctx, &InitUploadRequest{
+			OwnerID:   "user_1",
+			TeamID:    "team_2",
+			Title:     "Large scan",
+			FileName:  "scan.pdf",
+			MimeType:  "application/pdf",
+			TotalSize: int64(len(content)),
+			ChunkSize: MinChunkSize,
+		})
+		assert.EqualError(t, err, "user is not a member of the team")
+	})
+
+	t.Run("ResumeAndComplete", func(t *testing.T) {
+		session := newSession(t)
+
+		// Upload chunks out of order, leaving a gap
+		_, err := uploadService.UploadChunk(ctx, session.ID, "user_1", 2, checksumOf(chunks[2]), bytes.NewReader(chunks[2]))
+		assert.NoError(t, err)
+		_, err = uploadService.UploadChunk(ctx, session.ID, "user_1", 0, checksumOf(chunks[0]), bytes.NewReader(chunks[0]))
+		assert.NoError(t, err)
+
+		status, err := uploadService.GetUploadStatus(ctx, session.ID, "user_1")
+		assert.NoError(t, err)
+		assert.Equal(t, []int{0, 2}, status.ReceivedChunks)
+		assert.Equal(t, []int{1}, status.MissingChunks)
+		assert.Equal(t, []ByteRange{
+			{Start: 0, End: MinChunkSize - 1},
+			{Start: 2 * MinChunkSize, End: int64(len(content)) - 1},
+		}, status.ReceivedRanges)
+
+		_, err = uploadService.CompleteUpload(ctx, session.ID, "user_1")
+		assert.Error(t, err)
+
+		_, err = uploadService.UploadChunk(ctx, session.ID, "user_1", 1, checksumOf(chunks[1]), bytes.NewReader(chunks[1]))
+		assert.NoError(t, err)
+
+		document, err := uploadService.CompleteUpload(ctx, session.ID, "user_1")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(content)), document.FileSize)
+		assert.Equal(t, checksumOf(content), document.ContentHash)
+
+		r, err := storageService.GetFile(ctx, document.FilePath)
+		assert.NoError(t, err)
+		data, _ := io.ReadAll(r)
+		r.Close()
+		assert.Equal(t, content, data)
+
+		var versions []domain.DocumentVersion
+		testDB.Where("document_id = ?", document.ID).Find(&versions)
+		assert.Len(t, versions, 1)
+
+		// Chunks are discarded after assembly
+		var chunkCount int64
+		testDB.Model(&domain.UploadChunk{}).Where("session_id = ?", session.ID).Count(&chunkCount)
+		assert.Equal(t, int64(0), chunkCount)
+		exists, _ := driver.Exists(ctx, chunkStorageKey(session.ID, 0))
+		assert.False(t, exists)
+
+		_, err = uploadService.CompleteUpload(ctx, session.ID, "user_1")
+		assert.EqualError(t, err, "upload session already completed")
+	})
+
+	t.Run("RejectsBadChunks", func(t *testing.T) {
+		session := newSession(t)
+
+		_, err := uploadService.UploadChunk(ctx, session.ID, "user_1", 0, checksumOf(chunks[1][:10]), bytes.NewReader(chunks[0]))
+		assert.EqualError(t, err, "chunk checksum mismatch")
+
+		_, err = uploadService.UploadChunk(ctx, session.ID, "user_1", 0, checksumOf(chunks[0][:10]), bytes.NewReader(chunks[0][:10]))
+		assert.Error(t, err)
+
+		_, err = uploadService.UploadChunk(ctx, session.ID, "user_1", 3, checksumOf(chunks[0]), bytes.NewReader(chunks[0]))
+		assert.EqualError(t, err, "invalid chunk index")
+
+		_, err = uploadService.UploadChunk(ctx, session.ID, "user_2", 0, checksumOf(chunks[0]), bytes.NewReader(chunks[0]))
+		assert.EqualError(t, err, "upload session not found")
+	})
+
+	t.Run("CleanupExpiredSessions", func(t *testing.T) {
+		session := newSession(t)
+		_, err := uploadService.UploadChunk(ctx, session.ID, "user_1", 0, checksumOf(chunks[0]), bytes.NewReader(chunks[0]))
+		assert.NoError(t, err)
+
+		testDB.Model(&domain.UploadSession{}).Where("id = ?", session.ID).Update("expires_at", time.Now().Add(-time.Minute))
+
+		_, err = uploadService.UploadChunk(ctx, session.ID, "user_1", 1, checksumOf(chunks[1]), bytes.NewReader(chunks[1]))
+		assert.EqualError(t, err, "upload session expired")
+
+		removed, err := uploadService.CleanupExpiredSessions(ctx)
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, removed, 1)
+
+		_, err = uploadService.GetUploadStatus(ctx, session.ID, "user_1")
+		assert.EqualError(t, err, "upload session not found")
+		exists, _ := driver.Exists(ctx, chunkStorageKey(session.ID, 0))
+		assert.False(t, exists)
+	})
+}
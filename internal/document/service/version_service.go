@@ -6,7 +6,10 @@ import (
 	"time"
 
 	"cdk-office/internal/document/domain"
+	"cdk-office/internal/document/storage"
+	"cdk-office/internal/shared/cache"
 	"cdk-office/internal/shared/database"
+	"cdk-office/pkg/config"
 	"cdk-office/pkg/logger"
 	"gorm.io/gorm"
 )
@@ -22,13 +25,28 @@ type VersionServiceInterface interface {
 
 // VersionService implements the VersionServiceInterface
 type VersionService struct {
-	db *gorm.DB
+	db      *gorm.DB
+	indexer DocumentIndexer
 }
 
 // NewVersionService creates a new instance of VersionService
-func NewVersionService() *VersionService {
+func NewVersionService(cfg *config.Config) (*VersionService, error) {
+	db := database.GetDB()
+	storageService, err := NewStorageService(&cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	return &VersionService{
+		db:      db,
+		indexer: newDocumentIndexer(db, storageService, cfg),
+	}, nil
+}
+
+// NewVersionServiceWithDeps creates a new instance of VersionService with specific dependencies
+func NewVersionServiceWithDeps(db *gorm.DB, indexer DocumentIndexer) *VersionService {
 	return &VersionService{
-		db: database.GetDB(),
+		db:      db,
+		indexer: indexer,
 	}
 }
 
@@ -59,14 +77,21 @@ func (s *VersionService) CreateVersion(ctx context.Context, documentID, filePath
 		versionNumber = latestVersion.Version + 1
 	}
 
+	// Re-uploading unchanged content does not create a new version
+	contentHash := storage.HashFromKey(filePath)
+	if contentHash != "" && versionNumber > 1 && latestVersion.ContentHash == contentHash {
+		return &latestVersion, nil
+	}
+
 	// Create new version
 	version := &domain.DocumentVersion{
-		ID:         generateID(),
-		DocumentID: documentID,
-		Version:    versionNumber,
-		FilePath:   filePath,
-		FileSize:   fileSize,
-		CreatedAt:  time.Now(),
+		ID:          generateID(),
+		DocumentID:  documentID,
+		Version:     versionNumber,
+		FilePath:    filePath,
+		FileSize:    fileSize,
+		ContentHash: contentHash,
+		CreatedAt:   time.Now(),
 	}
 
 	// Save version to database
@@ -75,6 +100,20 @@ func (s *VersionService) CreateVersion(ctx context.Context, documentID, filePath
 		return nil, errors.New("failed to create version")
 	}
 
+	// Point the document at the new content
+	if err := s.db.Model(&document).Updates(map[string]interface{}{
+		"file_path":    filePath,
+		"file_size":    fileSize,
+		"content_hash": contentHash,
+		"updated_at":   time.Now(),
+	}).Error; err != nil {
+		logger.Error("failed to update document file", "error", err)
+	}
+	cache.Delete("document:" + documentID)
+	cache.Delete("document_versions:" + documentID)
+
+	s.indexDocument(ctx, documentID)
+
 	return version, nil
 }
 
@@ -164,6 +203,7 @@ func (s *VersionService) RestoreVersion(ctx context.Context, versionID string) e
 	// Update document with version details
 	document.FilePath = version.FilePath
 	document.FileSize = version.FileSize
+	document.ContentHash = version.ContentHash
 	document.UpdatedAt = time.Now()
 
 	// Save updated document to database
@@ -175,12 +215,13 @@ func (s *VersionService) RestoreVersion(ctx context.Context, versionID string) e
 	// Create a new version to record the restoration
 	// This creates a new version with the same content as the restored version
 	newVersion := &domain.DocumentVersion{
-		ID:         generateID(),
-		DocumentID: document.ID,
-		Version:    version.Version + 1,
-		FilePath:   version.FilePath,
-		FileSize:   version.FileSize,
-		CreatedAt:  time.Now(),
+		ID:          generateID(),
+		DocumentID:  document.ID,
+		Version:     version.Version + 1,
+		FilePath:    version.FilePath,
+		FileSize:    version.FileSize,
+		ContentHash: version.ContentHash,
+		CreatedAt:   time.Now(),
 	}
 
 	if err := s.db.Create(newVersion).Error; err != nil {
@@ -189,5 +230,17 @@ func (s *VersionService) RestoreVersion(ctx context.Context, versionID string) e
 		// For now, we'll just log it and continue
 	}
 
+	s.indexDocument(ctx, document.ID)
+
 	return nil
+}
+
+// indexDocument updates the search index for a document after its content changed
+func (s *VersionService) indexDocument(ctx context.Context, documentID string) {
+	if s.indexer == nil {
+		return
+	}
+	if err := s.indexer.IndexDocument(ctx, documentID); err != nil {
+		logger.Error("failed to index document", "error", err, "document_id", documentID)
+	}
 }
\ No newline at end of file
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"cdk-office/pkg/config"
+)
+
+// ErrObjectNotFound is returned when an object does not exist in the backend
+var ErrObjectNotFound = errors.New("object not found")
+
+// ErrInvalidKey is returned when a storage key is malformed
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// Driver defines the interface for an object storage backend
+type Driver interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	Exists(ctx context.Context, key string) (bool, error)
+}
+
+// LocalPather is implemented by drivers whose objects live on the local filesystem
+type LocalPather interface {
+	LocalPath(key string) (string, error)
+}
+
+// NewDriver creates the storage driver selected by the configuration
+func NewDriver(cfg *config.StorageConfig) (Driver, error) {
+	switch cfg.Driver {
+	case "", "local":
+		return NewLocalDriver(cfg.LocalPath)
+	case "s3":
+		return NewS3Driver(&cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %s", cfg.Driver)
+	}
+}
+
+const contentKeyPrefix = "sha256/"
+
+var sha256Hex = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// ContentKey returns the content-addressed storage key for a SHA-256 hex digest.
+// Keys are fanned out over two directory levels to keep directories small.
+func ContentKey(hash string) string {
+	return contentKeyPrefix + hash[0:2] + "/" + hash[2:4] + "/" + hash
+}
+
+// HashFromKey returns the SHA-256 digest encoded in a content-addressed key,
+// or an empty string if the key is not content-addressed
+func HashFromKey(key string) string {
+	if !strings.HasPrefix(key, contentKeyPrefix) {
+		return ""
+	}
+	hash := path.Base(key)
+	if !sha256Hex.MatchString(hash) || ContentKey(hash) != key {
+		return ""
+	}
+	return hash
+}
+
+// validateKey rejects keys that are empty, absolute or escape the storage root
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return ErrInvalidKey
+	}
+	if path.Clean(key) != key || key == ".." || strings.HasPrefix(key, "../") {
+		return ErrInvalidKey
+	}
+	return nil
+}
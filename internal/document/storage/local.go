@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// LocalDriver stores objects on the local filesystem
+type LocalDriver struct {
+	root string
+}
+
+// NewLocalDriver creates a new instance of LocalDriver rooted at the given directory
+func NewLocalDriver(root string) (*LocalDriver, error) {
+	if root == "" {
+		return nil, errors.New("local storage path is required")
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	return &LocalDriver{
+		root: root,
+	}, nil
+}
+
+// LocalPath returns the filesystem path of an object
+func (d *LocalDriver) LocalPath(key string) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(d.root, filepath.FromSlash(key)), nil
+}
+
+// Put stores an object. The data is written to a temporary file first and
+// renamed into place so that concurrent readers never see a partial object.
+func (d *LocalDriver) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	filePath, err := d.LocalPath(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filePath)
+}
+
+// Get opens an object for reading
+func (d *LocalDriver) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	filePath, err := d.LocalPath(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	return file, nil
+}
+
+// Delete removes an object
+func (d *LocalDriver) Delete(ctx context.Context, key string) error {
+	filePath, err := d.LocalPath(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filePath); err != nil {
+		if os.IsNotExist(err) {
+			return ErrObjectNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Exists checks whether an object exists
+func (d *LocalDriver) Exists(ctx context.Context, key string) (bool, error) {
+	filePath, err := d.LocalPath(key)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := os.Stat(filePath); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalDriver(t *testing.T) {
+	ctx := context.Background()
+	driver, err := NewLocalDriver(t.TempDir())
+	assert.NoError(t, err)
+
+	key := ContentKey(strings.Repeat("ab", 32))
+
+	t.Run("PutAndGet", func(t *testing.T) {
+		err := driver.Put(ctx, key, strings.NewReader("hello"), 5)
+		assert.NoError(t, err)
+
+		exists, err := driver.Exists(ctx, key)
+		assert.NoError(t, err)
+		assert.True(t, exists)
+
+		r, err := driver.Get(ctx, key)
+		assert.NoError(t, err)
+		defer r.Close()
+		data, _ := io.ReadAll(r)
+		assert.Equal(t, "hello", string(data))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.NoError(t, driver.Delete(ctx, key))
+
+		exists, err := driver.Exists(ctx, key)
+		assert.NoError(t, err)
+		assert.False(t, exists)
+
+		_, err = driver.Get(ctx, key)
+		assert.ErrorIs(t, err, ErrObjectNotFound)
+		assert.ErrorIs(t, driver.Delete(ctx, key), ErrObjectNotFound)
+	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		for _, key := range []string{"", "/etc/passwd", "../outside", "a/../../b", "a\\b"} {
+			err := driver.Put(ctx, key, strings.NewReader("x"), 1)
+			assert.ErrorIs(t, err, ErrInvalidKey, key)
+		}
+	})
+}
+
+func TestContentKey(t *testing.T) {
+	hash := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	key := ContentKey(hash)
+
+	assert.Equal(t, "sha256/9f/86/"+hash, key)
+	assert.Equal(t, hash, HashFromKey(key))
+	assert.Empty(t, HashFromKey("/path/to/document.pdf"))
+	assert.Empty(t, HashFromKey("sha256/00/00/"+hash))
+}
@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"cdk-office/pkg/config"
+)
+
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3Driver stores objects in an S3-compatible object store (AWS S3, MinIO, ...)
+type S3Driver struct {
+	endpoint     *url.URL
+	region       string
+	bucket       string
+	accessKey    string
+	secretKey    string
+	usePathStyle bool
+	httpClient   *http.Client
+	now          func() time.Time
+}
+
+// NewS3Driver creates a new instance of S3Driver
+func NewS3Driver(cfg *config.S3Config) (*S3Driver, error) {
+	if cfg.Endpoint == "" {
+		return nil, errors.New("s3 endpoint is required")
+	}
+	if cfg.Bucket == "" {
+		return nil, errors.New("s3 bucket is required")
+	}
+
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %v", err)
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint: %s", cfg.Endpoint)
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &S3Driver{
+		endpoint:     endpoint,
+		region:       region,
+		bucket:       cfg.Bucket,
+		accessKey:    cfg.AccessKey,
+		secretKey:    cfg.SecretKey,
+		usePathStyle: cfg.UsePathStyle,
+		httpClient:   &http.Client{},
+		now:          time.Now,
+	}, nil
+}
+
+// Put uploads an object
+func (d *S3Driver) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	if size < 0 {
+		return errors.New("object size is required")
+	}
+
+	resp, err := d.do(ctx, http.MethodPut, key, r, size)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkResponse(resp, http.MethodPut, key)
+}
+
+// Get downloads an object. The caller must close the returned reader.
+func (d *S3Driver) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := d.do(ctx, http.MethodGet, key, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkResponse(resp, http.MethodGet, key); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Delete removes an object. Deleting a missing object is not an error in S3.
+func (d *S3Driver) Delete(ctx context.Context, key string) error {
+	resp, err := d.do(ctx, http.MethodDelete, key, nil, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return checkResponse(resp, http.MethodDelete, key)
+}
+
+// Exists checks whether an object exists
+func (d *S3Driver) Exists(ctx context.Context, key string) (bool, error) {
+	resp, err := d.do(ctx, http.MethodHead, key, nil, 0)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if err := checkResponse(resp, http.MethodHead, key); err != nil {
+		if errors.Is(err, ErrObjectNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// do builds, signs and sends a request for the given object key
+func (d *S3Driver) do(ctx context.Context, method, key string, body io.Reader, size int64) (*http.Response, error) {
+	if err := validateKey(key); err != nil {
+		return nil, err
+	}
+
+	objectURL := d.objectURL(key)
+	req, err := http.NewRequestWithContext(ctx, method, objectURL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+
+	d.sign(req, objectURL.EscapedPath())
+
+	return d.httpClient.Do(req)
+}
+
+// objectURL returns the URL of an object using path-style or virtual-hosted-style addressing
+func (d *S3Driver) objectURL(key string) *url.URL {
+	u := *d.endpoint
+	basePath := strings.TrimSuffix(u.Path, "/")
+	if d.usePathStyle {
+		u.Path = basePath + "/" + d.bucket + "/" + key
+		u.RawPath = basePath + "/" + escapePath(d.bucket) + "/" + escapePath(key)
+	} else {
+		u.Host = d.bucket + "." + u.Host
+		u.Path = basePath + "/" + key
+		u.RawPath = basePath + "/" + escapePath(key)
+	}
+	return &u
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to the request
+func (d *S3Driver) sign(req *http.Request, canonicalURI string) {
+	now := d.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	dateStamp := now.Format("20060102")
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", unsignedPayload)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + unsignedPayload + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		"",
+		canonicalHeaders,
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+
+	scope := dateStamp + "/" + d.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+d.secretKey), dateStamp)
+	signingKey = hmacSHA256(signingKey, d.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		d.accessKey, scope, signedHeaders, signature))
+}
+
+// checkResponse converts a non-2xx response into an error
+func checkResponse(resp *http.Response, method, key string) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrObjectNotFound
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("s3 %s %s failed: %s %s", method, key, resp.Status, strings.TrimSpace(string(body)))
+}
+
+// hmacSHA256 computes an HMAC-SHA256 of data with the given key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// escapePath URI-encodes every path segment as required by SigV4
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = escapeSegment(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// escapeSegment percent-encodes everything except RFC 3986 unreserved characters
+func escapeSegment(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
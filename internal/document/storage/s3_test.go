@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"cdk-office/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeS3 is a minimal in-memory stand-in for an S3-compatible server such as MinIO
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=test-access/") ||
+		r.Header.Get("x-amz-date") == "" || r.Header.Get("x-amz-content-sha256") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := r.URL.Path
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		if int64(len(data)) != r.ContentLength {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.objects[path] = data
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet, http.MethodHead:
+		data, ok := f.objects[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case http.MethodDelete:
+		delete(f.objects, path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestS3Driver(t *testing.T) {
+	fake := &fakeS3{objects: make(map[string][]byte)}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	driver, err := NewDriver(&config.StorageConfig{
+		Driver: "s3",
+		S3: config.S3Config{
+			Endpoint:     server.URL,
+			Region:       "us-east-1",
+			Bucket:       "documents",
+			AccessKey:    "test-access",
+			SecretKey:    "test-secret",
+			UsePathStyle: true,
+		},
+	})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	key := ContentKey(strings.Repeat("cd", 32))
+
+	t.Run("PutAndGet", func(t *testing.T) {
+		err := driver.Put(ctx, key, strings.NewReader("object content"), 14)
+		assert.NoError(t, err)
+		assert.Contains(t, fake.objects, "/documents/"+key)
+
+		exists, err := driver.Exists(ctx, key)
+		assert.NoError(t, err)
+		assert.True(t, exists)
+
+		r, err := driver.Get(ctx, key)
+		assert.NoError(t, err)
+		defer r.Close()
+		data, _ := io.ReadAll(r)
+		assert.Equal(t, "object content", string(data))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		assert.NoError(t, driver.Delete(ctx, key))
+
+		exists, err := driver.Exists(ctx, key)
+		assert.NoError(t, err)
+		assert.False(t, exists)
+
+		_, err = driver.Get(ctx, key)
+		assert.ErrorIs(t, err, ErrObjectNotFound)
+	})
+
+	t.Run("BadCredentials", func(t *testing.T) {
+		badDriver, err := NewS3Driver(&config.S3Config{
+			Endpoint:     server.URL,
+			Bucket:       "documents",
+			AccessKey:    "wrong",
+			UsePathStyle: true,
+		})
+		assert.NoError(t, err)
+
+		err = badDriver.Put(ctx, key, strings.NewReader("x"), 1)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "403")
+	})
+}
+
+func TestNewDriver_Unknown(t *testing.T) {
+	_, err := NewDriver(&config.StorageConfig{Driver: "ftp"})
+	assert.Error(t, err)
+}
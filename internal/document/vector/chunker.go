@@ -0,0 +1,125 @@
+package vector
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// unit is a word of the chunked text, or a single character of CJK text,
+// which is not separated by spaces
+type unit struct {
+	text      string
+	attached  bool // joined to the previous unit without a space
+	paragraph bool // first unit of a paragraph
+}
+
+// Chunk splits text into passages of at most size words, the last overlap words
+// of a passage repeated at the start of the next so that text cut at a boundary
+// keeps its context. Passages end at a paragraph break when one falls in their
+// second half. CJK characters count as words.
+func Chunk(text string, size, overlap int) []string {
+	if size < 1 {
+		return nil
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	units := splitUnits(text)
+	var chunks []string
+	for start := 0; start < len(units); {
+		end := start + size
+		if end >= len(units) {
+			end = len(units)
+		} else {
+			for i := end; i > start+size/2; i-- {
+				if units[i].paragraph {
+					end = i
+					break
+				}
+			}
+		}
+		chunks = append(chunks, joinUnits(units[start:end]))
+		if end == len(units) {
+			break
+		}
+		next := end - overlap
+		if next <= start {
+			next = end
+		}
+		start = next
+	}
+	return chunks
+}
+
+// splitUnits splits text into words and CJK characters, marking paragraph starts
+func splitUnits(text string) []unit {
+	var units []unit
+	for _, paragraph := range strings.Split(normalizeNewlines(text), "\n\n") {
+		first := true
+		for _, word := range strings.Fields(paragraph) {
+			for i, part := range splitCJK(word) {
+				units = append(units, unit{text: part, attached: i > 0, paragraph: first})
+				first = false
+			}
+		}
+	}
+	return units
+}
+
+// normalizeNewlines turns page breaks and carriage returns into newlines and
+// blank lines made of spaces into empty lines, so paragraphs split on "\n\n"
+func normalizeNewlines(text string) string {
+	text = strings.NewReplacer("\r\n", "\n", "\r", "\n", "\f", "\n\n").Replace(text)
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			lines[i] = ""
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// splitCJK splits the CJK characters of a word into parts of their own
+func splitCJK(word string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(word); {
+		r, size := utf8.DecodeRuneInString(word[i:])
+		if isCJK(r) {
+			if i > start {
+				parts = append(parts, word[start:i])
+			}
+			parts = append(parts, word[i:i+size])
+			start = i + size
+		}
+		i += size
+	}
+	if start < len(word) {
+		parts = append(parts, word[start:])
+	}
+	return parts
+}
+
+// joinUnits joins units back into text, paragraphs separated by blank lines
+func joinUnits(units []unit) string {
+	var b strings.Builder
+	for i, u := range units {
+		switch {
+		case i == 0:
+		case u.paragraph:
+			b.WriteString("\n\n")
+		case !u.attached:
+			b.WriteByte(' ')
+		}
+		b.WriteString(u.text)
+	}
+	return b.String()
+}
+
+// isCJK reports whether r is a Han, Hiragana, Katakana or Hangul character
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
@@ -0,0 +1,39 @@
+package vector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChunk(t *testing.T) {
+	words := func(from, to int) string {
+		var w []string
+		for i := from; i <= to; i++ {
+			w = append(w, "w"+strings.Repeat("x", i%3)+string(rune('a'+i%26)))
+		}
+		return strings.Join(w, " ")
+	}
+
+	// Overlapping windows of at most size words
+	chunks := Chunk(words(1, 25), 10, 2)
+	assert.Len(t, chunks, 3)
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, len(strings.Fields(chunk)), 10)
+	}
+	assert.Equal(t, strings.Fields(chunks[0])[8:], strings.Fields(chunks[1])[:2])
+
+	// Chunks end at a paragraph break in their second half
+	text := words(1, 7) + "\n\n" + words(8, 20)
+	chunks = Chunk(text, 10, 0)
+	assert.Equal(t, words(1, 7), chunks[0])
+	assert.Equal(t, words(8, 17), chunks[1])
+
+	// Page breaks separate paragraphs, CJK characters count as words
+	chunks = Chunk("第一页\f年假政策", 4, 0)
+	assert.Equal(t, []string{"第一页", "年假政策"}, chunks)
+
+	assert.Empty(t, Chunk("  \n\n ", 10, 2))
+	assert.Equal(t, []string{"short text"}, Chunk("short text", 10, 20))
+}
@@ -0,0 +1,193 @@
+package vector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"cdk-office/internal/document/search"
+)
+
+// Embedder turns texts into vectors whose cosine similarity reflects how
+// related the texts are. Vectors are normalised to unit length.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	Dimensions() int
+	Model() string
+}
+
+// HashEmbedder is a deterministic embedder that needs no model: the terms of a
+// text and the pairs of consecutive terms are hashed into signed buckets. Texts
+// sharing vocabulary end up close, which suits offline deployments and tests.
+type HashEmbedder struct {
+	dimensions int
+}
+
+// NewHashEmbedder creates a new instance of HashEmbedder
+func NewHashEmbedder(dimensions int) *HashEmbedder {
+	return &HashEmbedder{dimensions: dimensions}
+}
+
+// Dimensions returns the length of the vectors
+func (e *HashEmbedder) Dimensions() int {
+	return e.dimensions
+}
+
+// Model returns the name of the embedding model
+func (e *HashEmbedder) Model() string {
+	return fmt.Sprintf("hash-%d", e.dimensions)
+}
+
+// Embed embeds texts. Texts without terms get a zero vector.
+func (e *HashEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = e.embed(text)
+	}
+	return vectors, nil
+}
+
+func (e *HashEmbedder) embed(text string) []float32 {
+	vector := make([]float32, e.dimensions)
+	add := func(feature string, weight float32) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		if sum>>63 == 1 {
+			weight = -weight
+		}
+		vector[sum%uint64(e.dimensions)] += weight
+	}
+
+	previous := ""
+	for _, token := range search.Tokenize(text) {
+		add(token.Term, 1)
+		if previous != "" {
+			add(previous+" "+token.Term, 0.5)
+		}
+		previous = token.Term
+	}
+	Normalize(vector)
+	return vector
+}
+
+// HTTPEmbedder embeds texts with an OpenAI compatible embeddings API, such as
+// those of OpenAI, Dify model providers, Ollama or text-embeddings-inference
+type HTTPEmbedder struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	dimensions int
+	batchSize  int
+	httpClient *http.Client
+}
+
+// NewHTTPEmbedder creates a new instance of HTTPEmbedder. Texts are sent in
+// batches of batchSize.
+func NewHTTPEmbedder(baseURL, apiKey, model string, dimensions, batchSize int) *HTTPEmbedder {
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	return &HTTPEmbedder{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		model:      model,
+		dimensions: dimensions,
+		batchSize:  batchSize,
+		httpClient: &http.Client{Timeout: time.Minute},
+	}
+}
+
+// Dimensions returns the length of the vectors
+func (e *HTTPEmbedder) Dimensions() int {
+	return e.dimensions
+}
+
+// Model returns the name of the embedding model
+func (e *HTTPEmbedder) Model() string {
+	return e.model
+}
+
+// embeddingRequest represents the request of the embeddings API
+type embeddingRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
+
+// embeddingResponse represents the response of the embeddings API
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// Embed embeds texts, batch by batch
+func (e *HTTPEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += e.batchSize {
+		end := start + e.batchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+		batch, err := e.embedBatch(ctx, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+func (e *HTTPEmbedder) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(&embeddingRequest{Model: e.model, Input: texts, Dimensions: e.dimensions})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("embeddings API returned %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	var result embeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid embeddings response: %w", err)
+	}
+	if len(result.Data) != len(texts) {
+		return nil, fmt.Errorf("embeddings API returned %d vectors for %d texts", len(result.Data), len(texts))
+	}
+
+	sort.Slice(result.Data, func(i, j int) bool { return result.Data[i].Index < result.Data[j].Index })
+	vectors := make([][]float32, len(result.Data))
+	for i, data := range result.Data {
+		if len(data.Embedding) != e.dimensions {
+			return nil, fmt.Errorf("embedding has %d dimensions, expected %d", len(data.Embedding), e.dimensions)
+		}
+		Normalize(data.Embedding)
+		vectors[i] = data.Embedding
+	}
+	return vectors, nil
+}
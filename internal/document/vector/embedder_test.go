@@ -0,0 +1,65 @@
+package vector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashEmbedder(t *testing.T) {
+	embedder := NewHashEmbedder(256)
+	vectors, err := embedder.Embed(context.Background(), []string{
+		"Hotels are reimbursed up to 150 euros per night",
+		"Hotels are reimbursed up to 150 euros per night",
+		"Hotel costs are reimbursed up to 150 euros a night",
+		"The board meets every quarter to approve the budget",
+		"",
+	})
+	require.NoError(t, err)
+	require.Len(t, vectors, 5)
+	assert.Len(t, vectors[0], 256)
+
+	assert.InDelta(t, 1, Cosine(vectors[0], vectors[1]), 1e-6)
+	assert.Greater(t, Cosine(vectors[0], vectors[2]), Cosine(vectors[0], vectors[3]))
+	assert.Equal(t, make([]float32, 256), vectors[4])
+	assert.Equal(t, vectors[0], Decode(Encode(vectors[0])))
+}
+
+func TestHTTPEmbedder(t *testing.T) {
+	var batches [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/embeddings", r.URL.Path)
+		assert.Equal(t, "Bearer test-key", r.Header.Get("Authorization"))
+		var req embeddingRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "test-model", req.Model)
+		batches = append(batches, req.Input)
+
+		// Vectors in reverse order, as the index field gives their position
+		type data struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		}
+		var result []data
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			result = append(result, data{Index: i, Embedding: []float32{float32(len(req.Input[i])), 0, 0}})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": result})
+	}))
+	defer server.Close()
+
+	embedder := NewHTTPEmbedder(server.URL+"/v1/", "test-key", "test-model", 3, 2)
+	vectors, err := embedder.Embed(context.Background(), []string{"a", "bb", "ccc"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"a", "bb"}, {"ccc"}}, batches)
+	assert.Equal(t, [][]float32{{1, 0, 0}, {1, 0, 0}, {1, 0, 0}}, vectors)
+
+	embedder = NewHTTPEmbedder(server.URL+"/v1", "test-key", "test-model", 4, 2)
+	_, err = embedder.Embed(context.Background(), []string{"a"})
+	assert.EqualError(t, err, "embedding has 3 dimensions, expected 4")
+}
@@ -0,0 +1,427 @@
+package vector
+
+import (
+	"container/heap"
+	"context"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"cdk-office/internal/document/domain"
+	"gorm.io/gorm"
+)
+
+// HNSW graph parameters
+const (
+	hnswM              = 16  // neighbours per node above layer 0, twice as many on layer 0
+	hnswEfConstruction = 200 // candidates considered when linking a new node
+	hnswEfSearch       = 64  // candidates considered when searching
+)
+
+// chunkBatchSize is the number of chunks written or loaded per batch
+const chunkBatchSize = 100
+
+// HNSWIndex keeps the chunk vectors in an in-process hierarchical navigable
+// small world graph, loaded from the document_chunks table on first use. The
+// graph lives in the memory of one server; deployments running several
+// servers should use pgvector.
+type HNSWIndex struct {
+	db         *gorm.DB
+	dimensions int
+
+	mu     sync.RWMutex
+	graph  *hnswGraph
+	loaded bool
+}
+
+// NewHNSWIndex creates a new instance of HNSWIndex
+func NewHNSWIndex(db *gorm.DB, dimensions int) *HNSWIndex {
+	return &HNSWIndex{db: db, dimensions: dimensions, graph: newHNSWGraph()}
+}
+
+// Upsert replaces the chunks of a document
+func (x *HNSWIndex) Upsert(ctx context.Context, documentID string, chunks []*domain.DocumentChunk) error {
+	err := x.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ?", documentID).Delete(&domain.DocumentChunk{}).Error; err != nil {
+			return err
+		}
+		if len(chunks) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(chunks, chunkBatchSize).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if !x.loaded {
+		// The first load reads the chunks just written
+		return x.load(ctx)
+	}
+	x.graph.removeDocument(documentID)
+	for _, chunk := range chunks {
+		x.graph.insert(x.node(chunk))
+	}
+	x.graph.compact()
+	return nil
+}
+
+// Remove deletes the chunks of a document
+func (x *HNSWIndex) Remove(ctx context.Context, documentID string) error {
+	if err := x.db.WithContext(ctx).Where("document_id = ?", documentID).Delete(&domain.DocumentChunk{}).Error; err != nil {
+		return err
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.loaded {
+		x.graph.removeDocument(documentID)
+		x.graph.compact()
+	}
+	return nil
+}
+
+// Search returns the k chunks closest to a vector. The graph is searched first;
+// when the filter leaves fewer than k of the candidates, as for a small team in
+// a large index, every chunk is compared.
+func (x *HNSWIndex) Search(ctx context.Context, vector []float32, k int, filter Filter) ([]Match, error) {
+	x.mu.RLock()
+	loaded := x.loaded
+	x.mu.RUnlock()
+	if !loaded {
+		x.mu.Lock()
+		err := x.load(ctx)
+		x.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	accept := func(n *hnswNode) bool { return filter.accepts(n.documentID, n.teamID) }
+	ef := hnswEfSearch
+	if k > ef {
+		ef = k
+	}
+	found := x.graph.search(vector, k, ef, accept)
+	if len(found) < k {
+		found = x.graph.bruteForce(vector, k, accept)
+	}
+
+	matches := make([]Match, len(found))
+	for i, c := range found {
+		n := x.graph.nodes[c.id]
+		matches[i] = Match{ChunkID: n.chunkID, DocumentID: n.documentID, Score: 1 - float64(c.distance)}
+	}
+	return matches, nil
+}
+
+// load builds the graph from the stored chunks. The caller holds the write lock.
+func (x *HNSWIndex) load(ctx context.Context) error {
+	if x.loaded {
+		return nil
+	}
+	graph := newHNSWGraph()
+	var chunks []*domain.DocumentChunk
+	err := x.db.WithContext(ctx).Select("id", "document_id", "team_id", "embedding").Order("id").
+		FindInBatches(&chunks, chunkBatchSize, func(tx *gorm.DB, batch int) error {
+			for _, chunk := range chunks {
+				if len(chunk.Embedding) == 4*x.dimensions {
+					graph.insert(x.node(chunk))
+				}
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return err
+	}
+	x.graph = graph
+	x.loaded = true
+	return nil
+}
+
+// node returns the graph node of a chunk
+func (x *HNSWIndex) node(chunk *domain.DocumentChunk) *hnswNode {
+	return &hnswNode{chunkID: chunk.ID, documentID: chunk.DocumentID, teamID: chunk.TeamID, vector: Decode(chunk.Embedding)}
+}
+
+// hnswNode is a chunk in the graph
+type hnswNode struct {
+	chunkID    string
+	documentID string
+	teamID     string
+	vector     []float32
+	neighbors  [][]int // node IDs per layer
+	deleted    bool
+}
+
+// hnswGraph is a hierarchical navigable small world graph over cosine distance.
+// Removed nodes stay in the graph to keep it navigable and are skipped in
+// results; the graph is rebuilt when they outnumber the live ones.
+type hnswGraph struct {
+	nodes      []*hnswNode
+	byDocument map[string][]int
+	entry      int
+	maxLevel   int
+	deleted    int
+	rng        *rand.Rand
+}
+
+func newHNSWGraph() *hnswGraph {
+	return &hnswGraph{
+		byDocument: make(map[string][]int),
+		entry:      -1,
+		rng:        rand.New(rand.NewSource(1)),
+	}
+}
+
+// scored is a node and its distance to a query
+type scored struct {
+	id       int
+	distance float32
+}
+
+// distance returns the cosine distance of two normalised vectors
+func distance(a, b []float32) float32 {
+	return float32(1 - Cosine(a, b))
+}
+
+// randomLevel draws the top layer of a new node, exponentially rarer by layer
+func (g *hnswGraph) randomLevel() int {
+	return int(math.Floor(-math.Log(1-g.rng.Float64()) / math.Log(hnswM)))
+}
+
+// insert adds a node, linking it to its nearest neighbours on each of its layers
+func (g *hnswGraph) insert(node *hnswNode) {
+	level := g.randomLevel()
+	node.neighbors = make([][]int, level+1)
+	id := len(g.nodes)
+	g.nodes = append(g.nodes, node)
+	g.byDocument[node.documentID] = append(g.byDocument[node.documentID], id)
+
+	if g.entry < 0 {
+		g.entry, g.maxLevel = id, level
+		return
+	}
+
+	entry := g.entry
+	for l := g.maxLevel; l > level; l-- {
+		entry = g.greedy(node.vector, entry, l)
+	}
+	entries := []int{entry}
+	for l := min(level, g.maxLevel); l >= 0; l-- {
+		candidates := g.searchLayer(node.vector, entries, hnswEfConstruction, l)
+		maxConnections := hnswM
+		if l == 0 {
+			maxConnections = 2 * hnswM
+		}
+		for _, c := range g.selectNeighbors(candidates, hnswM) {
+			node.neighbors[l] = append(node.neighbors[l], c.id)
+			neighbor := g.nodes[c.id]
+			neighbor.neighbors[l] = append(neighbor.neighbors[l], id)
+			if len(neighbor.neighbors[l]) > maxConnections {
+				g.prune(neighbor, l, maxConnections)
+			}
+		}
+		entries = entries[:0]
+		for _, c := range candidates {
+			entries = append(entries, c.id)
+		}
+	}
+	if level > g.maxLevel {
+		g.entry, g.maxLevel = id, level
+	}
+}
+
+// greedy walks a layer towards the node closest to a vector
+func (g *hnswGraph) greedy(vector []float32, entry, layer int) int {
+	best := distance(vector, g.nodes[entry].vector)
+	for improved := true; improved; {
+		improved = false
+		for _, id := range g.nodes[entry].neighbors[layer] {
+			if d := distance(vector, g.nodes[id].vector); d < best {
+				best, entry, improved = d, id, true
+			}
+		}
+	}
+	return entry
+}
+
+// searchLayer returns the ef nodes of a layer closest to a vector, nearest first
+func (g *hnswGraph) searchLayer(vector []float32, entries []int, ef, layer int) []scored {
+	visited := make(map[int]bool, ef*4)
+	candidates := &minHeap{}
+	results := &maxHeap{}
+	for _, id := range entries {
+		visited[id] = true
+		c := scored{id: id, distance: distance(vector, g.nodes[id].vector)}
+		heap.Push(candidates, c)
+		heap.Push(results, c)
+	}
+
+	for candidates.Len() > 0 {
+		current := heap.Pop(candidates).(scored)
+		if results.Len() >= ef && current.distance > (*results)[0].distance {
+			break
+		}
+		for _, id := range g.nodes[current.id].neighbors[layer] {
+			if visited[id] {
+				continue
+			}
+			visited[id] = true
+			d := distance(vector, g.nodes[id].vector)
+			if results.Len() < ef || d < (*results)[0].distance {
+				heap.Push(candidates, scored{id: id, distance: d})
+				heap.Push(results, scored{id: id, distance: d})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	found := make([]scored, results.Len())
+	for i := len(found) - 1; i >= 0; i-- {
+		found[i] = heap.Pop(results).(scored)
+	}
+	return found
+}
+
+// selectNeighbors picks up to m of the candidates, nearest first, skipping
+// those closer to an already selected neighbour than to the new node so links
+// spread in every direction. Skipped candidates fill the remaining slots.
+func (g *hnswGraph) selectNeighbors(candidates []scored, m int) []scored {
+	selected := make([]scored, 0, m)
+	var skipped []scored
+	for _, c := range candidates {
+		if len(selected) == m {
+			break
+		}
+		diverse := true
+		for _, s := range selected {
+			if distance(g.nodes[c.id].vector, g.nodes[s.id].vector) < c.distance {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			selected = append(selected, c)
+		} else {
+			skipped = append(skipped, c)
+		}
+	}
+	for _, c := range skipped {
+		if len(selected) == m {
+			break
+		}
+		selected = append(selected, c)
+	}
+	return selected
+}
+
+// prune keeps the closest neighbours of a node on a layer
+func (g *hnswGraph) prune(node *hnswNode, layer, maxConnections int) {
+	neighbors := make([]scored, len(node.neighbors[layer]))
+	for i, id := range node.neighbors[layer] {
+		neighbors[i] = scored{id: id, distance: distance(node.vector, g.nodes[id].vector)}
+	}
+	sort.Slice(neighbors, func(i, j int) bool { return neighbors[i].distance < neighbors[j].distance })
+	node.neighbors[layer] = node.neighbors[layer][:0]
+	for _, n := range neighbors[:maxConnections] {
+		node.neighbors[layer] = append(node.neighbors[layer], n.id)
+	}
+}
+
+// search returns the k accepted live nodes closest to a vector, nearest first
+func (g *hnswGraph) search(vector []float32, k, ef int, accept func(*hnswNode) bool) []scored {
+	if g.entry < 0 {
+		return nil
+	}
+	entry := g.entry
+	for l := g.maxLevel; l > 0; l-- {
+		entry = g.greedy(vector, entry, l)
+	}
+
+	var found []scored
+	for _, c := range g.searchLayer(vector, []int{entry}, ef, 0) {
+		if n := g.nodes[c.id]; !n.deleted && accept(n) {
+			found = append(found, c)
+			if len(found) == k {
+				break
+			}
+		}
+	}
+	return found
+}
+
+// bruteForce compares a vector with every accepted live node
+func (g *hnswGraph) bruteForce(vector []float32, k int, accept func(*hnswNode) bool) []scored {
+	var found []scored
+	for id, n := range g.nodes {
+		if !n.deleted && accept(n) {
+			found = append(found, scored{id: id, distance: distance(vector, n.vector)})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].distance < found[j].distance })
+	if len(found) > k {
+		found = found[:k]
+	}
+	return found
+}
+
+// removeDocument marks the nodes of a document deleted
+func (g *hnswGraph) removeDocument(documentID string) {
+	for _, id := range g.byDocument[documentID] {
+		if !g.nodes[id].deleted {
+			g.nodes[id].deleted = true
+			g.deleted++
+		}
+	}
+	delete(g.byDocument, documentID)
+}
+
+// compact rebuilds the graph without its deleted nodes once they are the majority
+func (g *hnswGraph) compact() {
+	if g.deleted*2 <= len(g.nodes) {
+		return
+	}
+	rebuilt := newHNSWGraph()
+	for _, n := range g.nodes {
+		if !n.deleted {
+			rebuilt.insert(&hnswNode{chunkID: n.chunkID, documentID: n.documentID, teamID: n.teamID, vector: n.vector})
+		}
+	}
+	*g = *rebuilt
+}
+
+// minHeap orders nodes nearest first
+type minHeap []scored
+
+func (h minHeap) Len() int            { return len(h) }
+func (h minHeap) Less(i, j int) bool  { return h[i].distance < h[j].distance }
+func (h minHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *minHeap) Push(x interface{}) { *h = append(*h, x.(scored)) }
+func (h *minHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// maxHeap orders nodes farthest first
+type maxHeap []scored
+
+func (h maxHeap) Len() int            { return len(h) }
+func (h maxHeap) Less(i, j int) bool  { return h[i].distance > h[j].distance }
+func (h maxHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *maxHeap) Push(x interface{}) { *h = append(*h, x.(scored)) }
+func (h *maxHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
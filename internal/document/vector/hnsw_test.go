@@ -0,0 +1,91 @@
+package vector
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"cdk-office/internal/document/domain"
+	"cdk-office/internal/shared/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// randomVector returns a random normalised vector
+func randomVector(rng *rand.Rand, dimensions int) []float32 {
+	vector := make([]float32, dimensions)
+	for i := range vector {
+		vector[i] = float32(rng.NormFloat64())
+	}
+	Normalize(vector)
+	return vector
+}
+
+// TestHNSWGraphRecall tests that the graph finds nearly all of the true nearest neighbours
+func TestHNSWGraphRecall(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	graph := newHNSWGraph()
+	for i := 0; i < 2000; i++ {
+		graph.insert(&hnswNode{chunkID: fmt.Sprint(i), documentID: fmt.Sprint(i), vector: randomVector(rng, 32)})
+	}
+
+	all := func(*hnswNode) bool { return true }
+	found, total := 0, 0
+	for q := 0; q < 50; q++ {
+		query := randomVector(rng, 32)
+		exact := make(map[int]bool)
+		for _, c := range graph.bruteForce(query, 10, all) {
+			exact[c.id] = true
+		}
+		for _, c := range graph.search(query, 10, hnswEfSearch, all) {
+			if exact[c.id] {
+				found++
+			}
+		}
+		total += 10
+	}
+	assert.GreaterOrEqual(t, float64(found)/float64(total), 0.9)
+}
+
+// TestHNSWIndex tests that chunks are replaced, filtered and removed, and that
+// the graph is rebuilt from the database
+func TestHNSWIndex(t *testing.T) {
+	ctx := context.Background()
+	db := testutils.SetupTestDB()
+	index := NewHNSWIndex(db, 3)
+
+	chunk := func(id, documentID, teamID string, vector ...float32) *domain.DocumentChunk {
+		Normalize(vector)
+		return &domain.DocumentChunk{ID: id, DocumentID: documentID, TeamID: teamID, Embedding: Encode(vector)}
+	}
+	require.NoError(t, index.Upsert(ctx, "doc_1", []*domain.DocumentChunk{chunk("c1", "doc_1", "team_1", 1, 0, 0), chunk("c2", "doc_1", "team_1", 0, 1, 0)}))
+	require.NoError(t, index.Upsert(ctx, "doc_2", []*domain.DocumentChunk{chunk("c3", "doc_2", "team_1", 1, 0.1, 0)}))
+	require.NoError(t, index.Upsert(ctx, "doc_3", []*domain.DocumentChunk{chunk("c4", "doc_3", "team_2", 1, 0, 0)}))
+
+	matches, err := index.Search(ctx, []float32{1, 0, 0}, 2, Filter{TeamID: "team_1"})
+	require.NoError(t, err)
+	require.Len(t, matches, 2)
+	assert.Equal(t, "c1", matches[0].ChunkID)
+	assert.InDelta(t, 1, matches[0].Score, 1e-6)
+	assert.Equal(t, "doc_2", matches[1].DocumentID)
+
+	matches, err = index.Search(ctx, []float32{1, 0, 0}, 5, Filter{TeamID: "team_1", ExcludeDocumentID: "doc_1"})
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "c3", matches[0].ChunkID)
+
+	// Replacing a document drops its previous chunks
+	require.NoError(t, index.Upsert(ctx, "doc_1", []*domain.DocumentChunk{chunk("c5", "doc_1", "team_1", 0, 0, 1)}))
+	require.NoError(t, index.Remove(ctx, "doc_2"))
+	matches, err = index.Search(ctx, []float32{1, 0, 0}, 5, Filter{TeamID: "team_1"})
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, "c5", matches[0].ChunkID)
+
+	// A new index loads the stored chunks
+	matches, err = NewHNSWIndex(db, 3).Search(ctx, []float32{1, 0, 0}, 5, Filter{})
+	require.NoError(t, err)
+	require.Len(t, matches, 2)
+	assert.Equal(t, "c4", matches[0].ChunkID)
+}
@@ -0,0 +1,106 @@
+package vector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"cdk-office/internal/document/domain"
+	"gorm.io/gorm"
+)
+
+// PgvectorIndex searches chunk vectors in Postgres with the pgvector extension.
+// Vectors are kept in an embedding_vector column next to the portable
+// embedding column, with an HNSW index over cosine distance.
+type PgvectorIndex struct {
+	db         *gorm.DB
+	dimensions int
+	schemaOnce sync.Once
+	schemaErr  error
+}
+
+// NewPgvectorIndex creates a new instance of PgvectorIndex
+func NewPgvectorIndex(db *gorm.DB, dimensions int) *PgvectorIndex {
+	return &PgvectorIndex{db: db, dimensions: dimensions}
+}
+
+// ensureSchema installs the extension and adds the vector column and its index
+func (x *PgvectorIndex) ensureSchema(ctx context.Context) error {
+	x.schemaOnce.Do(func() {
+		db := x.db.WithContext(ctx)
+		for _, statement := range []string{
+			"CREATE EXTENSION IF NOT EXISTS vector",
+			fmt.Sprintf("ALTER TABLE document_chunks ADD COLUMN IF NOT EXISTS embedding_vector vector(%d)", x.dimensions),
+			"CREATE INDEX IF NOT EXISTS idx_document_chunks_embedding_vector ON document_chunks USING hnsw (embedding_vector vector_cosine_ops)",
+		} {
+			if x.schemaErr = db.Exec(statement).Error; x.schemaErr != nil {
+				return
+			}
+		}
+	})
+	return x.schemaErr
+}
+
+// Upsert replaces the chunks of a document
+func (x *PgvectorIndex) Upsert(ctx context.Context, documentID string, chunks []*domain.DocumentChunk) error {
+	if err := x.ensureSchema(ctx); err != nil {
+		return err
+	}
+
+	return x.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ?", documentID).Delete(&domain.DocumentChunk{}).Error; err != nil {
+			return err
+		}
+		if len(chunks) == 0 {
+			return nil
+		}
+		if err := tx.CreateInBatches(chunks, chunkBatchSize).Error; err != nil {
+			return err
+		}
+		for _, chunk := range chunks {
+			if err := tx.Exec("UPDATE document_chunks SET embedding_vector = CAST(? AS vector) WHERE id = ?",
+				literal(Decode(chunk.Embedding)), chunk.ID).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Remove deletes the chunks of a document
+func (x *PgvectorIndex) Remove(ctx context.Context, documentID string) error {
+	return x.db.WithContext(ctx).Where("document_id = ?", documentID).Delete(&domain.DocumentChunk{}).Error
+}
+
+// Search returns the k chunks closest to a vector
+func (x *PgvectorIndex) Search(ctx context.Context, vector []float32, k int, filter Filter) ([]Match, error) {
+	if err := x.ensureSchema(ctx); err != nil {
+		return nil, err
+	}
+
+	query := literal(vector)
+	tx := x.db.WithContext(ctx).Table("document_chunks").
+		Select("id AS chunk_id, document_id, 1 - (embedding_vector <=> CAST(? AS vector)) AS score", query).
+		Where("embedding_vector IS NOT NULL")
+	if filter.TeamID != "" {
+		tx = tx.Where("team_id = ?", filter.TeamID)
+	}
+	if filter.ExcludeDocumentID != "" {
+		tx = tx.Where("document_id <> ?", filter.ExcludeDocumentID)
+	}
+
+	var matches []Match
+	err := tx.Order(gorm.Expr("embedding_vector <=> CAST(? AS vector)", query)).Limit(k).Scan(&matches).Error
+	return matches, err
+}
+
+// literal formats a vector as a pgvector literal such as [0.1,0.2]
+func literal(vector []float32) string {
+	values := make([]string, len(vector))
+	for i, v := range vector {
+		values[i] = strconv.FormatFloat(float64(v), 'g', -1, 32)
+	}
+	return "[" + strings.Join(values, ",") + "]"
+}
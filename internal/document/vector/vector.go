@@ -0,0 +1,140 @@
+package vector
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"sync"
+
+	"cdk-office/internal/document/domain"
+	"gorm.io/gorm"
+)
+
+// Index kinds
+const (
+	IndexAuto     = "auto"
+	IndexPgvector = "pgvector"
+	IndexHNSW     = "hnsw"
+)
+
+// Index stores the embedded chunks of documents for nearest neighbour search
+type Index interface {
+	// Upsert replaces the chunks of a document
+	Upsert(ctx context.Context, documentID string, chunks []*domain.DocumentChunk) error
+	Remove(ctx context.Context, documentID string) error
+	Search(ctx context.Context, vector []float32, k int, filter Filter) ([]Match, error)
+}
+
+// Filter narrows a search to the chunks of a team, excluding a document
+type Filter struct {
+	TeamID            string
+	ExcludeDocumentID string
+}
+
+// accepts reports whether a chunk passes the filter
+func (f Filter) accepts(documentID, teamID string) bool {
+	return (f.TeamID == "" || f.TeamID == teamID) && documentID != f.ExcludeDocumentID
+}
+
+// Match represents a chunk close to a query vector
+type Match struct {
+	ChunkID    string
+	DocumentID string
+	Score      float64 // cosine similarity
+}
+
+var (
+	hnswMu      sync.Mutex
+	hnswIndexes = make(map[*gorm.DB]*HNSWIndex)
+)
+
+// NewIndex returns the index of a kind. Auto uses pgvector when the database is
+// Postgres with the vector extension available, and the in-process HNSW index
+// otherwise. HNSW indexes are shared per database, so every service of the
+// process sees the same graph.
+func NewIndex(db *gorm.DB, dimensions int, kind string) Index {
+	if kind == IndexPgvector || (kind == IndexAuto && pgvectorAvailable(db)) {
+		return NewPgvectorIndex(db, dimensions)
+	}
+
+	hnswMu.Lock()
+	defer hnswMu.Unlock()
+	index, ok := hnswIndexes[db]
+	if !ok || index.dimensions != dimensions {
+		index = NewHNSWIndex(db, dimensions)
+		hnswIndexes[db] = index
+	}
+	return index
+}
+
+// pgvectorAvailable reports whether the database is Postgres with the vector extension installable
+func pgvectorAvailable(db *gorm.DB) bool {
+	if db == nil || db.Dialector == nil || db.Dialector.Name() != "postgres" {
+		return false
+	}
+	var available bool
+	if err := db.Raw("SELECT EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'vector')").Scan(&available).Error; err != nil {
+		return false
+	}
+	return available
+}
+
+// Normalize scales a vector to unit length in place. Zero vectors are left as is.
+func Normalize(vector []float32) {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range vector {
+		vector[i] /= norm
+	}
+}
+
+// Cosine returns the cosine similarity of two normalised vectors
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot
+}
+
+// Mean returns the normalised mean of vectors
+func Mean(vectors [][]float32) []float32 {
+	if len(vectors) == 0 {
+		return nil
+	}
+	mean := make([]float32, len(vectors[0]))
+	for _, vector := range vectors {
+		for i, v := range vector {
+			mean[i] += v
+		}
+	}
+	Normalize(mean)
+	return mean
+}
+
+// Encode serialises a vector as little-endian float32 values
+func Encode(vector []float32) []byte {
+	data := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(v))
+	}
+	return data
+}
+
+// Decode deserialises a vector serialised by Encode
+func Decode(data []byte) []float32 {
+	vector := make([]float32, len(data)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return vector
+}
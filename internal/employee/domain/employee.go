@@ -4,6 +4,12 @@ import (
 	"time"
 )
 
+// Approval entity type and action of employees, for approval definitions
+const (
+	ApprovalEntityEmployee = "employee"
+	ApprovalActionPromote  = "promote"
+)
+
 // Employee represents an employee in the system
 type Employee struct {
 	ID            string    `json:"id" gorm:"primaryKey"`
@@ -28,6 +34,7 @@ type Department struct {
 	Description string    `json:"description" gorm:"type:text"`
 	TeamID      string    `json:"team_id" gorm:"index"`
 	ParentID    string    `json:"parent_id" gorm:"index"`
+	ManagerID   string    `json:"manager_id" gorm:"size:36"` // user heading the department
 	Level       int       `json:"level"`
 	SortOrder   int       `json:"sort_order"`
 	CreatedAt   time.Time `json:"created_at"`
@@ -34,6 +34,7 @@ type CreateDepartmentRequest struct {
 	Description string `json:"description"`
 	TeamID      string `json:"team_id" binding:"required"`
 	ParentID    string `json:"parent_id"`
+	ManagerID   string `json:"manager_id"`
 }
 
 // UpdateDepartmentRequest represents the request for updating a department
@@ -41,6 +42,7 @@ type UpdateDepartmentRequest struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	ParentID    string `json:"parent_id"`
+	ManagerID   string `json:"manager_id"`
 }
 
 // CreateDepartment handles creating a new department
@@ -57,6 +59,7 @@ func (h *DepartmentHandler) CreateDepartment(c *gin.Context) {
 		Description: req.Description,
 		TeamID:      req.TeamID,
 		ParentID:    req.ParentID,
+		ManagerID:   req.ManagerID,
 	}); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -84,6 +87,7 @@ func (h *DepartmentHandler) UpdateDepartment(c *gin.Context) {
 		Name:        req.Name,
 		Description: req.Description,
 		ParentID:    req.ParentID,
+		ManagerID:   req.ManagerID,
 	}); err != nil {
 		if err.Error() == "department not found" {
 			c.JSON(http.StatusNotFound, gin.H{"error": "department not found"})
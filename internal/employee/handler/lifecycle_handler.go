@@ -1,9 +1,12 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
+	approvalservice "cdk-office/internal/approval/service"
 	"cdk-office/internal/employee/service"
 	"github.com/gin-gonic/gin"
 )
@@ -28,6 +31,13 @@ func NewLifecycleHandler() *LifecycleHandler {
 	}
 }
 
+// NewLifecycleHandlerWithService creates a new instance of LifecycleHandler with a specific lifecycle service
+func NewLifecycleHandlerWithService(lifecycleService service.LifecycleServiceInterface) *LifecycleHandler {
+	return &LifecycleHandler{
+		lifecycleService: lifecycleService,
+	}
+}
+
 // PromoteEmployeeRequest represents the request for promoting an employee
 type PromoteEmployeeRequest struct {
 	EmployeeID string `json:"employee_id" binding:"required"`
@@ -56,7 +66,16 @@ func (h *LifecycleHandler) PromoteEmployee(c *gin.Context) {
 	}
 
 	// Call service to promote employee
-	if err := h.lifecycleService.PromoteEmployee(c.Request.Context(), req.EmployeeID, req.NewPosition); err != nil {
+	if err := h.lifecycleService.PromoteEmployee(c.Request.Context(), req.EmployeeID, req.NewPosition, c.GetString("user_id")); err != nil {
+		var required *approvalservice.ApprovalRequiredError
+		if errors.As(err, &required) {
+			c.JSON(http.StatusAccepted, gin.H{"message": "promotion submitted for approval", "approval_request": required.Request})
+			return
+		}
+		if err.Error() == "approval is already pending" || strings.HasPrefix(err.Error(), "no approvers found") {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		if err.Error() == "employee not found" {
 			c.JSON(http.StatusNotFound, gin.H{"error": "employee not found"})
 			return
@@ -9,6 +9,8 @@ import (
 	"testing"
 	"time"
 
+	approvaldomain "cdk-office/internal/approval/domain"
+	approvalservice "cdk-office/internal/approval/service"
 	"cdk-office/internal/employee/service"
 	"cdk-office/internal/shared/testutils"
 
@@ -22,8 +24,8 @@ type MockLifecycleService struct {
 	mock.Mock
 }
 
-func (m *MockLifecycleService) PromoteEmployee(ctx context.Context, employeeID, newPosition string) error {
-	args := m.Called(ctx, employeeID, newPosition)
+func (m *MockLifecycleService) PromoteEmployee(ctx context.Context, employeeID, newPosition, userID string) error {
+	args := m.Called(ctx, employeeID, newPosition, userID)
 	return args.Error(0)
 }
 
@@ -67,6 +69,9 @@ func TestPromoteEmployee(t *testing.T) {
 
 	// Create test router
 	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", "user_1")
+	})
 	router.POST("/lifecycle/promote", handler.PromoteEmployee)
 
 	// Test successful promotion
@@ -78,7 +83,7 @@ func TestPromoteEmployee(t *testing.T) {
 		}
 
 		// Mock service response
-		mockService.On("PromoteEmployee", mock.Anything, "emp_123", "Senior Engineer").Return(nil).Once()
+		mockService.On("PromoteEmployee", mock.Anything, "emp_123", "Senior Engineer", "user_1").Return(nil).Once()
 
 		// Create request
 		jsonValue, _ := json.Marshal(reqBody)
@@ -124,7 +129,7 @@ func TestPromoteEmployee(t *testing.T) {
 		}
 
 		// Mock service response
-		mockService.On("PromoteEmployee", mock.Anything, "emp_456", "Senior Engineer").Return(testutils.NewError("employee not found")).Once()
+		mockService.On("PromoteEmployee", mock.Anything, "emp_456", "Senior Engineer", "user_1").Return(testutils.NewError("employee not found")).Once()
 
 		// Create request
 		jsonValue, _ := json.Marshal(reqBody)
@@ -154,7 +159,7 @@ func TestPromoteEmployee(t *testing.T) {
 		}
 
 		// Mock service response
-		mockService.On("PromoteEmployee", mock.Anything, "emp_123", "Senior Engineer").Return(testutils.NewError("internal error")).Once()
+		mockService.On("PromoteEmployee", mock.Anything, "emp_123", "Senior Engineer", "user_1").Return(testutils.NewError("internal error")).Once()
 
 		// Create request
 		jsonValue, _ := json.Marshal(reqBody)
@@ -174,6 +179,23 @@ func TestPromoteEmployee(t *testing.T) {
 		// Assert mock expectations
 		mockService.AssertExpectations(t)
 	})
+	// Test a promotion submitted for approval
+	t.Run("ApprovalRequired", func(t *testing.T) {
+		request := &approvaldomain.ApprovalRequest{ID: "apr_1", EntityID: "emp_123", Status: approvaldomain.ApprovalPending}
+		mockService.On("PromoteEmployee", mock.Anything, "emp_123", "Senior Engineer", "user_1").
+			Return(&approvalservice.ApprovalRequiredError{Request: request}).Once()
+
+		jsonValue, _ := json.Marshal(PromoteEmployeeRequest{EmployeeID: "emp_123", NewPosition: "Senior Engineer"})
+		req, _ := http.NewRequest(http.MethodPost, "/lifecycle/promote", bytes.NewBuffer(jsonValue))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Contains(t, w.Body.String(), "promotion submitted for approval")
+		assert.Contains(t, w.Body.String(), "apr_1")
+		mockService.AssertExpectations(t)
+	})
 }
 
 // TestTransferEmployee tests the TransferEmployee handler
@@ -39,6 +39,7 @@ type CreateDepartmentRequest struct {
 	Description string `json:"description"`
 	TeamID      string `json:"team_id" binding:"required"`
 	ParentID    string `json:"parent_id"`
+	ManagerID   string `json:"manager_id"`
 }
 
 // UpdateDepartmentRequest represents the request for updating a department
@@ -46,6 +47,7 @@ type UpdateDepartmentRequest struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	ParentID    string `json:"parent_id"`
+	ManagerID   string `json:"manager_id"`
 }
 
 // CreateDepartment creates a new department
@@ -80,6 +82,7 @@ func (s *DepartmentService) CreateDepartment(ctx context.Context, req *CreateDep
 		Description: req.Description,
 		TeamID:      req.TeamID,
 		ParentID:    req.ParentID,
+		ManagerID:   req.ManagerID,
 		Level:       level,
 		SortOrder:   0, // Default sort order
 		CreatedAt:   time.Now(),
@@ -114,6 +117,9 @@ func (s *DepartmentService) UpdateDepartment(ctx context.Context, deptID string,
 	if req.Description != "" {
 		department.Description = req.Description
 	}
+	if req.ManagerID != "" {
+		department.ManagerID = req.ManagerID
+	}
 	if req.ParentID != "" {
 		// Check if parent department exists
 		var parentDept domain.Department
@@ -2,9 +2,12 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"time"
 
+	approvaldomain "cdk-office/internal/approval/domain"
+	approvalservice "cdk-office/internal/approval/service"
 	"cdk-office/internal/employee/domain"
 	"cdk-office/internal/shared/database"
 	"cdk-office/internal/shared/utils"
@@ -14,7 +17,7 @@ import (
 
 // LifecycleServiceInterface defines the interface for employee lifecycle service
 type LifecycleServiceInterface interface {
-	PromoteEmployee(ctx context.Context, empID string, newPosition string) error
+	PromoteEmployee(ctx context.Context, empID, newPosition, userID string) error
 	TransferEmployee(ctx context.Context, empID, newDeptID string) error
 	TerminateEmployee(ctx context.Context, empID string, terminationDate time.Time, reason string) error
 	GetEmployeeLifecycleHistory(ctx context.Context, empID string) ([]*EmployeeLifecycleEvent, error)
@@ -22,7 +25,8 @@ type LifecycleServiceInterface interface {
 
 // LifecycleService implements the LifecycleServiceInterface
 type LifecycleService struct {
-	db *gorm.DB
+	db        *gorm.DB
+	approvals approvalservice.ApprovalServiceInterface
 }
 
 // NewLifecycleService creates a new instance of LifecycleService
@@ -32,6 +36,25 @@ func NewLifecycleService() *LifecycleService {
 	}
 }
 
+// NewLifecycleServiceWithDB creates a new instance of LifecycleService with a specific database connection
+func NewLifecycleServiceWithDB(db *gorm.DB) *LifecycleService {
+	return &LifecycleService{
+		db: db,
+	}
+}
+
+// UseApprovals makes promoting employees require approval in teams with an
+// approval definition for it, and promotes employees once approved
+func (s *LifecycleService) UseApprovals(approvals approvalservice.ApprovalServiceInterface) {
+	s.approvals = approvals
+	approvals.RegisterHook(domain.ApprovalEntityEmployee, approvalservice.HookFuncs{OnApproved: s.promoteApprovedEmployee})
+}
+
+// promotion is the payload of a promotion approval request
+type promotion struct {
+	NewPosition string `json:"new_position"`
+}
+
 // EmployeeLifecycleEvent represents an event in an employee's lifecycle
 type EmployeeLifecycleEvent struct {
 	ID             string    `json:"id" gorm:"primaryKey"`
@@ -44,18 +67,61 @@ type EmployeeLifecycleEvent struct {
 	CreatedAt      time.Time `json:"created_at"`
 }
 
-// PromoteEmployee promotes an employee to a new position
-func (s *LifecycleService) PromoteEmployee(ctx context.Context, empID string, newPosition string) error {
-	// Find employee by ID
+// PromoteEmployee promotes an employee to a new position. When the team of the
+// employee requires approval to promote employees, the promotion is submitted
+// for approval, by the heads of the employee's department, and applied once approved.
+func (s *LifecycleService) PromoteEmployee(ctx context.Context, empID, newPosition, userID string) error {
+	employee, err := s.findPromotedEmployee(empID)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(promotion{NewPosition: newPosition})
+	if err != nil {
+		return errors.New("failed to promote employee")
+	}
+	if err := approvalservice.RequireApproval(ctx, s.approvals, &approvalservice.SubmitRequest{
+		TeamID:       employee.TeamID,
+		EntityType:   domain.ApprovalEntityEmployee,
+		EntityID:     employee.ID,
+		Action:       domain.ApprovalActionPromote,
+		RequestedBy:  userID,
+		DepartmentID: employee.DeptID,
+		Payload:      string(payload),
+	}); err != nil {
+		return err
+	}
+	return s.promoteEmployee(employee, newPosition)
+}
+
+// promoteApprovedEmployee applies a promotion that was approved
+func (s *LifecycleService) promoteApprovedEmployee(ctx context.Context, request *approvaldomain.ApprovalRequest) error {
+	var p promotion
+	if err := json.Unmarshal([]byte(request.Payload), &p); err != nil || p.NewPosition == "" {
+		return errors.New("invalid promotion payload")
+	}
+	employee, err := s.findPromotedEmployee(request.EntityID)
+	if err != nil {
+		return err
+	}
+	return s.promoteEmployee(employee, p.NewPosition)
+}
+
+// findPromotedEmployee finds the employee of a promotion
+func (s *LifecycleService) findPromotedEmployee(empID string) (*domain.Employee, error) {
 	var employee domain.Employee
 	if err := s.db.Where("id = ?", empID).First(&employee).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New("employee not found")
+			return nil, errors.New("employee not found")
 		}
 		logger.Error("failed to find employee", "error", err)
-		return errors.New("failed to promote employee")
+		return nil, errors.New("failed to promote employee")
 	}
+	return &employee, nil
+}
 
+// promoteEmployee moves an employee to a new position, recording the promotion
+func (s *LifecycleService) promoteEmployee(employee *domain.Employee, newPosition string) error {
 	// Store old position
 	oldPosition := employee.Position
 
@@ -64,7 +130,7 @@ func (s *LifecycleService) PromoteEmployee(ctx context.Context, empID string, ne
 	employee.UpdatedAt = time.Now()
 
 	// Save updated employee to database
-	if err := s.db.Save(&employee).Error; err != nil {
+	if err := s.db.Save(employee).Error; err != nil {
 		logger.Error("failed to update employee position", "error", err)
 		return errors.New("failed to promote employee")
 	}
@@ -72,7 +138,7 @@ func (s *LifecycleService) PromoteEmployee(ctx context.Context, empID string, ne
 	// Create lifecycle event
 	event := &EmployeeLifecycleEvent{
 		ID:            utils.GenerateLifecycleID(),
-		EmployeeID:    empID,
+		EmployeeID:    employee.ID,
 		EventType:     "promotion",
 		OldValue:      oldPosition,
 		NewValue:      newPosition,
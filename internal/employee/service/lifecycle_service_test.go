@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	approvaldomain "cdk-office/internal/approval/domain"
+	approvalservice "cdk-office/internal/approval/service"
+	"cdk-office/internal/employee/domain"
+	"cdk-office/internal/shared/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPromoteEmployeeWithApproval tests promotions waiting for the approval of the department head
+func TestPromoteEmployeeWithApproval(t *testing.T) {
+	testDB := testutils.SetupTestDB()
+	require.NoError(t, testDB.AutoMigrate(&EmployeeLifecycleEvent{}))
+	approvals := approvalservice.NewApprovalServiceWithDB(testDB)
+	lifecycleService := NewLifecycleServiceWithDB(testDB)
+	lifecycleService.UseApprovals(approvals)
+	ctx := context.Background()
+
+	require.NoError(t, testDB.Create(&domain.Department{ID: "dept_1", Name: "Engineering", TeamID: "team_1", ManagerID: "manager"}).Error)
+	require.NoError(t, testDB.Create(&domain.Employee{ID: "emp_1", UserID: "bob", TeamID: "team_1", DeptID: "dept_1", EmployeeID: "E001", Position: "Engineer", Status: "active"}).Error)
+
+	// Without a definition the promotion applies right away
+	require.NoError(t, lifecycleService.PromoteEmployee(ctx, "emp_1", "Senior Engineer", "hr"))
+	var employee domain.Employee
+	require.NoError(t, testDB.Where("id = ?", "emp_1").First(&employee).Error)
+	assert.Equal(t, "Senior Engineer", employee.Position)
+
+	_, err := approvals.CreateDefinition(ctx, &approvalservice.CreateDefinitionRequest{
+		TeamID: "team_1", Name: "Promotions", EntityType: domain.ApprovalEntityEmployee, Action: domain.ApprovalActionPromote,
+		IsActive: true, CreatedBy: "admin",
+		Steps: []approvaldomain.ApprovalStep{{Name: "Head", Approvers: []approvaldomain.ApproverRule{{Type: approvaldomain.ApproverDepartmentHead}}}},
+	})
+	require.NoError(t, err)
+
+	// The promotion waits for the head of the employee's department
+	err = lifecycleService.PromoteEmployee(ctx, "emp_1", "Staff Engineer", "hr")
+	var required *approvalservice.ApprovalRequiredError
+	require.ErrorAs(t, err, &required)
+	require.NoError(t, testDB.Where("id = ?", "emp_1").First(&employee).Error)
+	assert.Equal(t, "Senior Engineer", employee.Position)
+	assert.EqualError(t, lifecycleService.PromoteEmployee(ctx, "emp_1", "Principal Engineer", "hr"), "approval is already pending")
+
+	require.NoError(t, approvals.Approve(ctx, required.Request.ID, "manager", ""))
+	require.NoError(t, testDB.Where("id = ?", "emp_1").First(&employee).Error)
+	assert.Equal(t, "Staff Engineer", employee.Position)
+
+	var events []*EmployeeLifecycleEvent
+	require.NoError(t, testDB.Where("employee_id = ?", "emp_1").Order("new_value asc").Find(&events).Error)
+	assert.Len(t, events, 2)
+}
@@ -12,6 +12,7 @@ import (
 	docservice "cdk-office/internal/document/service"
 	empservice "cdk-office/internal/employee/service"
 	"cdk-office/internal/shared/testutils"
+	"cdk-office/pkg/config"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -22,7 +23,7 @@ func TestAppWithQRCodeIntegration(t *testing.T) {
 	defer db.Migrator().DropTable(&appdomain.QRCode{}, &appdomain.Application{})
 
 	appService := service.NewAppServiceWithDB(db)
-	qrCodeService := service.NewQRCodeService()
+	qrCodeService := service.NewQRCodeService(&config.Default().QRCode)
 
 	ctx := context.Background()
 
@@ -300,7 +301,10 @@ func TestDocumentWithEmployeeIntegration(t *testing.T) {
 	assert.Equal(t, "archived", updatedDoc.Status)
 
 	// List documents for the employee's team using SearchService
-	searchService := docservice.NewSearchService()
+	storageConfig := config.Default().Storage
+	storageConfig.LocalPath = t.TempDir()
+	searchService, err := docservice.NewSearchService(&storageConfig)
+	assert.NoError(t, err)
 	docs, total, err := searchService.SearchDocuments(ctx, "", createdEmployee.TeamID, 1, 10)
 	assert.NoError(t, err)
 	assert.Equal(t, int64(1), total)
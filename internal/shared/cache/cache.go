@@ -4,9 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"log"
-	"os"
 	"time"
 
+	"cdk-office/pkg/config"
 	"github.com/go-redis/redis/v8"
 )
 
@@ -19,15 +19,11 @@ var (
 type RedisCache struct{}
 
 // InitRedis initializes the Redis client
-func InitRedis() {
-	redisAddr := getEnv("REDIS_ADDR", "localhost:6379")
-	redisPassword := getEnv("REDIS_PASSWORD", "")
-	redisDB := getEnvInt("REDIS_DB", 0)
-
+func InitRedis(config *config.RedisConfig) {
 	redisClient = redis.NewClient(&redis.Options{
-		Addr:     redisAddr,
-		Password: redisPassword,
-		DB:       redisDB,
+		Addr:     config.Addr,
+		Password: config.Password,
+		DB:       config.DB,
 	})
 
 	// Test the connection
@@ -138,27 +134,3 @@ func Exists(key string) (bool, error) {
 	}
 	return result > 0, nil
 }
-
-// getEnv returns the value of the environment variable or a default value
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-	return value
-}
-
-// getEnvInt returns the integer value of the environment variable or a default value
-func getEnvInt(key string, defaultValue int) int {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-
-	var result int
-	err := json.Unmarshal([]byte(value), &result)
-	if err != nil {
-		return defaultValue
-	}
-	return result
-}
\ No newline at end of file
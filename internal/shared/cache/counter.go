@@ -0,0 +1,149 @@
+package cache
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// CounterInterface defines the interface for counters that expire
+type CounterInterface interface {
+	// Increment adds one to the counter at key and returns the new value. A new
+	// counter expires after expiration; incrementing does not extend it.
+	Increment(key string, expiration time.Duration) (int64, error)
+	Delete(key string) error
+}
+
+// StoreInterface combines cache and counter operations
+type StoreInterface interface {
+	CacheInterface
+	CounterInterface
+}
+
+// NewStore returns a store backed by Redis once InitRedis has run, and by an
+// in-process store shared by all callers otherwise
+func NewStore() StoreInterface {
+	if redisClient == nil {
+		sharedMemoryOnce.Do(func() {
+			sharedMemory = NewMemoryCache()
+		})
+		return sharedMemory
+	}
+	return &RedisCache{}
+}
+
+// sharedMemory is the in-process store returned by NewStore without Redis
+var (
+	sharedMemory     *MemoryCache
+	sharedMemoryOnce sync.Once
+)
+
+// incrementScript increments a counter and sets its expiration when it is created
+var incrementScript = redis.NewScript(`
+local value = redis.call("INCR", KEYS[1])
+if value == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return value
+`)
+
+// Increment adds one to a counter in Redis
+func (r *RedisCache) Increment(key string, expiration time.Duration) (int64, error) {
+	return incrementScript.Run(ctx, redisClient, []string{key}, expiration.Milliseconds()).Int64()
+}
+
+// memoryEntry is a value of the in-process store
+type memoryEntry struct {
+	data      []byte
+	count     int64
+	expiresAt time.Time // zero for no expiration
+}
+
+// MemoryCache is an in-process implementation of StoreInterface for single
+// instance deployments without Redis, and for tests
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+}
+
+// NewMemoryCache creates a new MemoryCache instance
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[string]*memoryEntry)}
+}
+
+// Set stores a value with an expiration time
+func (m *MemoryCache) Set(key string, value interface{}, expiration time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = &memoryEntry{data: data, expiresAt: expiry(expiration)}
+	return nil
+}
+
+// Get retrieves a value, returning redis.Nil when the key does not exist
+func (m *MemoryCache) Get(key string, dest interface{}) error {
+	m.mu.Lock()
+	entry := m.entry(key)
+	m.mu.Unlock()
+
+	if entry == nil || entry.data == nil {
+		return redis.Nil
+	}
+	return json.Unmarshal(entry.data, dest)
+}
+
+// Delete removes a key
+func (m *MemoryCache) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+// Exists checks if a key exists
+func (m *MemoryCache) Exists(key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.entry(key) != nil, nil
+}
+
+// Increment adds one to a counter
+func (m *MemoryCache) Increment(key string, expiration time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := m.entry(key)
+	if entry == nil {
+		entry = &memoryEntry{expiresAt: expiry(expiration)}
+		m.entries[key] = entry
+	}
+	entry.count++
+	return entry.count, nil
+}
+
+// entry returns the live entry of key, dropping it once expired. The caller holds the lock.
+func (m *MemoryCache) entry(key string) *memoryEntry {
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil
+	}
+	if !entry.expiresAt.IsZero() && !time.Now().Before(entry.expiresAt) {
+		delete(m.entries, key)
+		return nil
+	}
+	return entry
+}
+
+// expiry returns the time an entry stored now expires, zero for no expiration
+func expiry(expiration time.Duration) time.Time {
+	if expiration <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(expiration)
+}
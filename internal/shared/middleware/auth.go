@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"strings"
 
@@ -18,6 +19,7 @@ type AuthMiddlewareInterface interface {
 type AuthMiddleware struct {
 	jwtManager      *jwt.JWTManager
 	tokenBlacklist  TokenBlacklistInterface
+	sessions        SessionCheckerInterface
 }
 
 // TokenBlacklistInterface defines the interface for token blacklist
@@ -25,6 +27,11 @@ type TokenBlacklistInterface interface {
 	IsBlacklisted(token string) (bool, error)
 }
 
+// SessionCheckerInterface defines the interface for checking login sessions
+type SessionCheckerInterface interface {
+	CheckSession(ctx context.Context, sessionID string) (bool, error)
+}
+
 // NewAuthMiddleware creates a new instance of AuthMiddleware
 func NewAuthMiddleware(jwtManager *jwt.JWTManager) *AuthMiddleware {
 	return &AuthMiddleware{
@@ -41,6 +48,16 @@ func NewAuthMiddlewareWithBlacklist(jwtManager *jwt.JWTManager, tokenBlacklist T
 	}
 }
 
+// NewAuthMiddlewareWithSessions creates a new instance of AuthMiddleware that only
+// accepts tokens of active login sessions, so that revoked sessions are logged out
+func NewAuthMiddlewareWithSessions(jwtManager *jwt.JWTManager, sessions SessionCheckerInterface) *AuthMiddleware {
+	return &AuthMiddleware{
+		jwtManager:     jwtManager,
+		tokenBlacklist: jwt.NewTokenBlacklist(),
+		sessions:       sessions,
+	}
+}
+
 // Authenticate returns a Gin middleware function for JWT authentication
 func (m *AuthMiddleware) Authenticate() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -86,10 +103,34 @@ func (m *AuthMiddleware) Authenticate() gin.HandlerFunc {
 			return
 		}
 
+		// Check the session of the token (if sessions are checked)
+		if m.sessions != nil {
+			// Tokens issued outside of sessions cannot be revoked and are refused
+			if claims.SessionID == "" {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+				c.Abort()
+				return
+			}
+
+			active, err := m.sessions.CheckSession(c.Request.Context(), claims.SessionID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check session status"})
+				c.Abort()
+				return
+			}
+
+			if !active {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "session has been revoked"})
+				c.Abort()
+				return
+			}
+		}
+
 		// Set user information in the context
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
 		c.Set("role", claims.Role)
+		c.Set("session_id", claims.SessionID)
 
 		// Continue with the next handler
 		c.Next()
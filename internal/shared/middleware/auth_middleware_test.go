@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -23,6 +24,16 @@ func (m *MockTokenBlacklist) IsBlacklisted(token string) (bool, error) {
 	return args.Bool(0), args.Error(1)
 }
 
+// MockSessionChecker is a mock implementation of the SessionCheckerInterface
+type MockSessionChecker struct {
+	mock.Mock
+}
+
+func (m *MockSessionChecker) CheckSession(ctx context.Context, sessionID string) (bool, error) {
+	args := m.Called(sessionID)
+	return args.Bool(0), args.Error(1)
+}
+
 func TestAuthMiddleware_Authenticate_NoAuthHeader(t *testing.T) {
 	// Setup
 	gin.SetMode(gin.TestMode)
@@ -208,4 +219,56 @@ func TestAuthMiddleware_Authenticate_ValidToken(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "user123")
 	assert.Contains(t, w.Body.String(), "testuser")
 	assert.Contains(t, w.Body.String(), "user")
-}
\ No newline at end of file
+}
+
+func TestAuthMiddleware_Authenticate_Sessions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jwtManager := jwt.NewJWTManager(&jwt.JWTConfig{
+		SecretKey:       "test_secret",
+		AccessTokenExp:  time.Hour,
+		RefreshTokenExp: time.Hour * 24,
+	})
+
+	mockBlacklist := new(MockTokenBlacklist)
+	mockBlacklist.On("IsBlacklisted", mock.Anything).Return(false, nil)
+	mockSessions := new(MockSessionChecker)
+	mockSessions.On("CheckSession", "session_active").Return(true, nil)
+	mockSessions.On("CheckSession", "session_revoked").Return(false, nil)
+
+	authMiddleware := &AuthMiddleware{
+		jwtManager:     jwtManager,
+		tokenBlacklist: mockBlacklist,
+		sessions:       mockSessions,
+	}
+	router := gin.New()
+	router.Use(authMiddleware.Authenticate())
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"session_id": c.GetString("session_id")})
+	})
+
+	request := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	token, err := jwtManager.GenerateSessionAccessToken("user123", "testuser", "user", "session_active")
+	assert.NoError(t, err)
+	w := request(token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "session_active")
+
+	token, err = jwtManager.GenerateSessionAccessToken("user123", "testuser", "user", "session_revoked")
+	assert.NoError(t, err)
+	w = request(token)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "session has been revoked")
+
+	// Tokens without a session cannot be revoked and are refused
+	token, err = jwtManager.GenerateAccessToken("user123", "testuser", "user")
+	assert.NoError(t, err)
+	w = request(token)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,78 @@
+// Package notify delivers messages to users by mail or SMS through pluggable senders.
+package notify
+
+import (
+	"context"
+	"errors"
+
+	"cdk-office/pkg/config"
+)
+
+// Message channels
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+)
+
+// Message is a message to a user
+type Message struct {
+	Channel string
+	To      string // mail address or phone number
+	Subject string // ignored by SMS
+	Body    string
+}
+
+// Sender delivers messages
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// SenderFunc adapts a function to the Sender interface
+type SenderFunc func(ctx context.Context, msg *Message) error
+
+// Send calls f
+func (f SenderFunc) Send(ctx context.Context, msg *Message) error {
+	return f(ctx, msg)
+}
+
+// Router delivers each message with the sender of its channel
+type Router struct {
+	senders map[string]Sender
+}
+
+// NewRouter creates a router without senders
+func NewRouter() *Router {
+	return &Router{senders: make(map[string]Sender)}
+}
+
+// NewDefaultSender returns a router with the mail and SMS senders of a configuration
+func NewDefaultSender(cfg *config.NotifyConfig) *Router {
+	router := NewRouter()
+	if cfg.SMTPHost != "" {
+		router.Handle(ChannelEmail, NewSMTPSender(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom))
+	}
+	if cfg.SMSWebhookURL != "" {
+		router.Handle(ChannelSMS, NewWebhookSender(cfg.SMSWebhookURL, cfg.SMSWebhookToken))
+	}
+	return router
+}
+
+// Handle sets the sender of a channel
+func (r *Router) Handle(channel string, sender Sender) {
+	r.senders[channel] = sender
+}
+
+// Supports reports whether the router has a sender for a channel
+func (r *Router) Supports(channel string) bool {
+	_, ok := r.senders[channel]
+	return ok
+}
+
+// Send delivers a message with the sender of its channel
+func (r *Router) Send(ctx context.Context, msg *Message) error {
+	sender, ok := r.senders[msg.Channel]
+	if !ok {
+		return errors.New("no sender configured for channel " + msg.Channel)
+	}
+	return sender.Send(ctx, msg)
+}
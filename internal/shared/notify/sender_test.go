@@ -0,0 +1,54 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter(t *testing.T) {
+	var sent []*Message
+	router := NewRouter()
+	router.Handle(ChannelEmail, SenderFunc(func(ctx context.Context, msg *Message) error {
+		sent = append(sent, msg)
+		return nil
+	}))
+
+	assert.True(t, router.Supports(ChannelEmail))
+	assert.False(t, router.Supports(ChannelSMS))
+
+	require.NoError(t, router.Send(context.Background(), &Message{Channel: ChannelEmail, To: "a@example.com"}))
+	assert.Len(t, sent, 1)
+	err := router.Send(context.Background(), &Message{Channel: ChannelSMS, To: "13800000000"})
+	assert.EqualError(t, err, "no sender configured for channel sms")
+}
+
+func TestWebhookSender(t *testing.T) {
+	var payload webhookPayload
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if payload.To == "fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	sender := NewWebhookSender(server.URL, "gateway-token")
+	require.NoError(t, sender.Send(context.Background(), &Message{Channel: ChannelSMS, To: "13800000000", Body: "code 123"}))
+	assert.Equal(t, "Bearer gateway-token", authorization)
+	assert.Equal(t, "13800000000", payload.To)
+	assert.Equal(t, "code 123", payload.Body)
+
+	err := sender.Send(context.Background(), &Message{Channel: ChannelSMS, To: "fail"})
+	assert.EqualError(t, err, "gateway responded with status 502")
+}
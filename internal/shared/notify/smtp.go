@@ -0,0 +1,50 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTPSender sends mail through an SMTP server
+type SMTPSender struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPSender creates a sender for an SMTP server, authenticating with PLAIN
+// when a username is given
+func NewSMTPSender(host string, port int, username, password, from string) *SMTPSender {
+	sender := &SMTPSender{
+		addr: host + ":" + strconv.Itoa(port),
+		from: from,
+	}
+	if username != "" {
+		sender.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return sender
+}
+
+// Send sends a plain text mail
+func (s *SMTPSender) Send(ctx context.Context, msg *Message) error {
+	if strings.ContainsAny(msg.To, "\r\n") {
+		return fmt.Errorf("invalid recipient %q", msg.To)
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", s.from)
+	fmt.Fprintf(&body, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&body, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&body, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	body.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	body.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	return smtp.SendMail(s.addr, s.auth, s.from, []string{msg.To}, []byte(body.String()))
+}
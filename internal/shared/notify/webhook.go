@@ -0,0 +1,68 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WebhookSender posts messages as JSON to a gateway, such as an SMS provider
+type WebhookSender struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewWebhookSender creates a sender posting to url, authenticating with a bearer
+// token when one is given
+func NewWebhookSender(url, token string) *WebhookSender {
+	return &WebhookSender{
+		url:    url,
+		token:  token,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// webhookPayload is the body posted to the gateway
+type webhookPayload struct {
+	Channel string `json:"channel"`
+	To      string `json:"to"`
+	Subject string `json:"subject,omitempty"`
+	Body    string `json:"body"`
+}
+
+// Send posts a message to the gateway
+func (s *WebhookSender) Send(ctx context.Context, msg *Message) error {
+	payload, err := json.Marshal(&webhookPayload{
+		Channel: msg.Channel,
+		To:      msg.To,
+		Subject: msg.Subject,
+		Body:    msg.Body,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("gateway responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,398 @@
+package pdf
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf16"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// unicodeFont names the TrueType font of a document in page resources
+const unicodeFont = "F3"
+
+// Font is a TrueType font drawing the characters that the built-in fonts
+// cannot, such as Chinese. Documents embed the subset of the glyphs they use
+// as a composite font, with a ToUnicode map so that text can be copied and
+// searched. A Font is safe for concurrent use.
+type Font struct {
+	name      string
+	data      []byte
+	sfnt      *sfnt.Font
+	ppem      fixed.Int26_6
+	bounds    [4]int
+	ascent    int
+	descent   int
+	capHeight int
+
+	mu     sync.Mutex
+	buf    sfnt.Buffer
+	glyphs map[rune]glyph
+}
+
+// glyph is the index of a character in a font and its width in thousandths of
+// the font size. Characters the font does not cover have index 0.
+type glyph struct {
+	index sfnt.GlyphIndex
+	width int
+}
+
+var currentFont atomic.Pointer[Font]
+
+// SetFont sets the TrueType font that documents created afterwards draw
+// characters outside Latin-1 with. Without a font they are drawn as '?'.
+// It is meant to be called once at startup.
+func SetFont(f *Font) {
+	currentFont.Store(f)
+}
+
+// CurrentFont returns the font set by SetFont, or nil
+func CurrentFont() *Font {
+	return currentFont.Load()
+}
+
+// LoadFont reads a TrueType font file
+func LoadFont(path string) (*Font, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read font: %w", err)
+	}
+	return ParseFont(data)
+}
+
+// ParseFont parses a TrueType font. Fonts with PostScript outlines and font
+// collections are not supported.
+func ParseFont(data []byte) (*Font, error) {
+	tables, err := readTables(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range []string{"head", "hhea", "hmtx", "loca", "glyf", "maxp"} {
+		if tables[tag] == nil {
+			return nil, fmt.Errorf("invalid font: no %s table, only TrueType outlines are supported", tag)
+		}
+	}
+	parsed, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid font: %w", err)
+	}
+
+	f := &Font{
+		data:   data,
+		sfnt:   parsed,
+		ppem:   fixed.I(int(parsed.UnitsPerEm())),
+		glyphs: map[rune]glyph{},
+	}
+	f.name, err = parsed.Name(&f.buf, sfnt.NameIDPostScript)
+	if err != nil || f.name == "" {
+		f.name = "Embedded"
+	}
+	f.name = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || strings.ContainsRune("()<>[]{}/%#", r) {
+			return -1
+		}
+		return r
+	}, f.name)
+
+	metrics, err := parsed.Metrics(&f.buf, f.ppem, font.HintingNone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid font: %w", err)
+	}
+	bounds, err := parsed.Bounds(&f.buf, f.ppem, font.HintingNone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid font: %w", err)
+	}
+	// sfnt measures down from the baseline, PDF up
+	f.ascent = f.scale(metrics.Ascent)
+	f.descent = -f.scale(metrics.Descent)
+	f.capHeight = f.scale(metrics.CapHeight)
+	f.bounds = [4]int{f.scale(bounds.Min.X), -f.scale(bounds.Max.Y), f.scale(bounds.Max.X), -f.scale(bounds.Min.Y)}
+	return f, nil
+}
+
+// scale converts a length in font units to thousandths of the font size
+func (f *Font) scale(v fixed.Int26_6) int {
+	return int(int64(v) * 1000 / int64(f.ppem))
+}
+
+// Covers reports whether the font has a glyph for r
+func (f *Font) Covers(r rune) bool {
+	return f.glyph(r).index != 0
+}
+
+// Face returns a face rasterising the font at size pixels, for drawing text
+// into images. Unlike the Font, a face is not safe for concurrent use.
+func (f *Font) Face(size float64) (font.Face, error) {
+	return opentype.NewFace(f.sfnt, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+}
+
+// glyph looks a character up in the font
+func (f *Font) glyph(r rune) glyph {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if g, ok := f.glyphs[r]; ok {
+		return g
+	}
+	var g glyph
+	if index, err := f.sfnt.GlyphIndex(&f.buf, r); err == nil && index != 0 {
+		if advance, err := f.sfnt.GlyphAdvance(&f.buf, index, f.ppem, font.HintingNone); err == nil {
+			g = glyph{index: index, width: f.scale(advance)}
+		}
+	}
+	f.glyphs[r] = g
+	return g
+}
+
+// writeFont writes the objects of the font subset holding the used glyphs,
+// keyed by glyph with the character each draws, into the reserved Type0 font
+// object
+func (d *Document) writeFont(f *Font, id int, used map[sfnt.GlyphIndex]rune) error {
+	indexes := make([]sfnt.GlyphIndex, 0, len(used))
+	for index := range used {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+
+	subset, err := subsetTrueType(f.data, indexes)
+	if err != nil {
+		return err
+	}
+	// Subsets are named with a tag derived from the glyphs they hold
+	sum := sha256.Sum256([]byte(fmt.Sprint(indexes)))
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = 'A' + sum[i]%26
+	}
+	name := string(tag) + "+" + f.name
+
+	fileID := d.addStream(fmt.Sprintf("/Length1 %d", len(subset)), subset)
+	descriptorID := d.add([]byte(fmt.Sprintf(
+		"<< /Type /FontDescriptor /FontName /%s /Flags 4 /FontBBox [%d %d %d %d] /ItalicAngle 0 "+
+			"/Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		name, f.bounds[0], f.bounds[1], f.bounds[2], f.bounds[3], f.ascent, f.descent, f.capHeight, fileID)))
+
+	var widths, cmap strings.Builder
+	for i, index := range indexes {
+		fmt.Fprintf(&widths, " %d [%d]", index, f.glyph(used[index]).width)
+		if i%100 == 0 {
+			if i > 0 {
+				cmap.WriteString("endbfchar\n")
+			}
+			fmt.Fprintf(&cmap, "%d beginbfchar\n", min(100, len(indexes)-i))
+		}
+		fmt.Fprintf(&cmap, "<%04X> <", index)
+		for _, unit := range utf16.Encode([]rune{used[index]}) {
+			fmt.Fprintf(&cmap, "%04X", unit)
+		}
+		cmap.WriteString(">\n")
+	}
+	if len(indexes) > 0 {
+		cmap.WriteString("endbfchar\n")
+	}
+	cidFontID := d.add([]byte(fmt.Sprintf(
+		"<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> "+
+			"/FontDescriptor %d 0 R /DW 1000 /W [%s ] /CIDToGIDMap /Identity >>", name, descriptorID, widths.String())))
+	toUnicodeID := d.addStream("", []byte(
+		"/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n"+
+			"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n"+
+			"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n"+
+			"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n"+
+			cmap.String()+
+			"endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend"))
+
+	d.objects[id-1] = []byte(fmt.Sprintf(
+		"<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		name, cidFontID, toUnicodeID))
+	return nil
+}
+
+// TrueType composite glyph flags
+const (
+	argsAreWords   = 0x0001
+	hasScale       = 0x0008
+	moreComponents = 0x0020
+	hasXYScale     = 0x0040
+	hasTwoByTwo    = 0x0080
+)
+
+// subsetTables are the tables a PDF viewer needs to draw the glyphs of an
+// embedded TrueType font, in tag order
+var subsetTables = []string{"cvt ", "fpgm", "glyf", "head", "hhea", "hmtx", "loca", "maxp", "prep"}
+
+// subsetTrueType returns a copy of a TrueType font that keeps the outlines of
+// the given glyphs, and of the glyphs they are composed of, only. Glyph
+// indexes are unchanged, so that they serve as the CIDs of the font.
+func subsetTrueType(data []byte, indexes []sfnt.GlyphIndex) ([]byte, error) {
+	tables, err := readTables(data)
+	if err != nil {
+		return nil, err
+	}
+	head, maxp, loca, glyf := tables["head"], tables["maxp"], tables["loca"], tables["glyf"]
+	if len(head) < 54 || len(maxp) < 6 {
+		return nil, errors.New("invalid font: truncated head or maxp table")
+	}
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+	longOffsets := binary.BigEndian.Uint16(head[50:]) != 0
+
+	offsets := make([]int, numGlyphs+1)
+	for i := range offsets {
+		switch {
+		case longOffsets && 4*i+4 <= len(loca):
+			offsets[i] = int(binary.BigEndian.Uint32(loca[4*i:]))
+		case !longOffsets && 2*i+2 <= len(loca):
+			offsets[i] = 2 * int(binary.BigEndian.Uint16(loca[2*i:]))
+		default:
+			return nil, errors.New("invalid font: truncated loca table")
+		}
+		if offsets[i] > len(glyf) || (i > 0 && offsets[i] < offsets[i-1]) {
+			return nil, errors.New("invalid font: bad glyph offset")
+		}
+	}
+
+	// Keep .notdef, the glyphs and the components of composite glyphs
+	keep := map[int]bool{0: true}
+	pending := []int{0}
+	for _, index := range indexes {
+		pending = append(pending, int(index))
+	}
+	for len(pending) > 0 {
+		index := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if index >= numGlyphs {
+			return nil, fmt.Errorf("invalid font: no glyph %d", index)
+		}
+		keep[index] = true
+		outline := glyf[offsets[index]:offsets[index+1]]
+		if len(outline) < 10 || int16(binary.BigEndian.Uint16(outline)) >= 0 {
+			continue
+		}
+		for p := 10; p+4 <= len(outline); {
+			flags := binary.BigEndian.Uint16(outline[p:])
+			component := int(binary.BigEndian.Uint16(outline[p+2:]))
+			if !keep[component] {
+				pending = append(pending, component)
+				keep[component] = true
+			}
+			p += 4
+			if flags&argsAreWords != 0 {
+				p += 4
+			} else {
+				p += 2
+			}
+			switch {
+			case flags&hasScale != 0:
+				p += 2
+			case flags&hasXYScale != 0:
+				p += 4
+			case flags&hasTwoByTwo != 0:
+				p += 8
+			}
+			if flags&moreComponents == 0 {
+				break
+			}
+		}
+	}
+
+	// Rebuild glyf and loca with long offsets, leaving the other glyphs empty
+	var newGlyf, newLoca bytes.Buffer
+	for index := 0; index < numGlyphs; index++ {
+		binary.Write(&newLoca, binary.BigEndian, uint32(newGlyf.Len()))
+		if keep[index] {
+			newGlyf.Write(glyf[offsets[index]:offsets[index+1]])
+			for newGlyf.Len()%4 != 0 {
+				newGlyf.WriteByte(0)
+			}
+		}
+	}
+	binary.Write(&newLoca, binary.BigEndian, uint32(newGlyf.Len()))
+	newHead := append([]byte(nil), head...)
+	binary.BigEndian.PutUint32(newHead[8:], 0)  // checkSumAdjustment, set below
+	binary.BigEndian.PutUint16(newHead[50:], 1) // indexToLocFormat: long offsets
+	tables["glyf"], tables["loca"], tables["head"] = newGlyf.Bytes(), newLoca.Bytes(), newHead
+
+	var kept []string
+	for _, tag := range subsetTables {
+		if tables[tag] != nil {
+			kept = append(kept, tag)
+		}
+	}
+	var out bytes.Buffer
+	entrySelector := 0
+	for 2<<entrySelector <= len(kept) {
+		entrySelector++
+	}
+	searchRange := 16 << entrySelector
+	binary.Write(&out, binary.BigEndian, []uint16{1, 0, uint16(len(kept)), uint16(searchRange), uint16(entrySelector), uint16(16*len(kept) - searchRange)})
+	offset := 12 + 16*len(kept)
+	for _, tag := range kept {
+		table := tables[tag]
+		out.WriteString(tag)
+		binary.Write(&out, binary.BigEndian, []uint32{checksum(table), uint32(offset), uint32(len(table))})
+		offset += (len(table) + 3) &^ 3
+	}
+	headOffset := 0
+	for _, tag := range kept {
+		if tag == "head" {
+			headOffset = out.Len()
+		}
+		out.Write(tables[tag])
+		for out.Len()%4 != 0 {
+			out.WriteByte(0)
+		}
+	}
+	file := out.Bytes()
+	binary.BigEndian.PutUint32(file[headOffset+8:], 0xB1B0AFBA-checksum(file))
+	return file, nil
+}
+
+// readTables returns the tables of a TrueType font by tag
+func readTables(data []byte) (map[string][]byte, error) {
+	if len(data) < 12 {
+		return nil, errors.New("invalid font: too short")
+	}
+	switch binary.BigEndian.Uint32(data) {
+	case 0x00010000, 0x74727565: // TrueType, "true"
+	case 0x74746366: // "ttcf"
+		return nil, errors.New("invalid font: font collections are not supported, use a single .ttf font")
+	case 0x4f54544f: // "OTTO"
+		return nil, errors.New("invalid font: PostScript outlines are not supported, use a TrueType font")
+	default:
+		return nil, errors.New("invalid font: not a TrueType font")
+	}
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	if len(data) < 12+16*numTables {
+		return nil, errors.New("invalid font: truncated table directory")
+	}
+	tables := make(map[string][]byte, numTables)
+	for i := 0; i < numTables; i++ {
+		record := data[12+16*i:]
+		offset, length := int(binary.BigEndian.Uint32(record[8:])), int(binary.BigEndian.Uint32(record[12:]))
+		if offset < 0 || length < 0 || offset+length > len(data) || offset+length < offset {
+			return nil, errors.New("invalid font: table out of bounds")
+		}
+		tables[string(record[:4])] = data[offset : offset+length]
+	}
+	return tables, nil
+}
+
+// checksum sums a table as big-endian 32-bit words, zero padded
+func checksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
@@ -0,0 +1,435 @@
+// Package pdf writes small PDF files drawn with vector operators, text in the
+// built-in Helvetica fonts and RGB images, without external dependencies.
+// Text outside Latin-1, such as Chinese, is drawn with an embedded TrueType
+// font when one is set with SetFont.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// Page sizes in points
+const (
+	A4Width  = 595.28
+	A4Height = 841.89
+)
+
+// Fonts of a document
+const (
+	Regular = "F1" // Helvetica
+	Bold    = "F2" // Helvetica-Bold
+)
+
+// helveticaWidths holds the glyph widths of Helvetica for the printable ASCII
+// characters, in thousandths of the font size
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556, // 0 to ?
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778, // @ to O
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556, // P to _
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556, // ` to o
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584, // p to ~
+}
+
+// helveticaBoldWidths holds the glyph widths of Helvetica-Bold in the same layout
+var helveticaBoldWidths = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611, // 0 to ?
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778, // @ to O
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556, // P to _
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611, // ` to o
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584, // p to ~
+}
+
+// Document assembles a PDF file page by page. All pages share the fonts and
+// the images added to the document.
+type Document struct {
+	objects [][]byte
+	pages   []int
+	pagesID int
+	fonts   map[string]int
+	images  map[string]int
+	info    map[string]string
+	font    *Font
+	glyphs  map[sfnt.GlyphIndex]rune
+}
+
+// NewDocument creates an empty document
+func NewDocument() *Document {
+	d := &Document{images: map[string]int{}, info: map[string]string{}}
+	d.pagesID = d.add(nil) // written once all pages are known
+	d.fonts = map[string]int{
+		Regular: d.add([]byte("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")),
+		Bold:    d.add([]byte("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")),
+	}
+	return d
+}
+
+// SetInfo sets an entry of the document information dictionary, such as Title
+func (d *Document) SetInfo(key, value string) {
+	d.info[key] = value
+}
+
+// add appends an object and returns its number
+func (d *Document) add(body []byte) int {
+	d.objects = append(d.objects, body)
+	return len(d.objects)
+}
+
+// addStream appends a compressed stream object with extra dictionary entries
+func (d *Document) addStream(dict string, data []byte) int {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(data)
+	zw.Close()
+
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "<< %s /Length %d /Filter /FlateDecode >>\nstream\n", dict, compressed.Len())
+	body.Write(compressed.Bytes())
+	body.WriteString("\nendstream")
+	return d.add(body.Bytes())
+}
+
+// AddImage adds an RGB image drawn over the background colour, since images are
+// drawn without transparency. Pages draw it by name.
+func (d *Document) AddImage(name string, img image.Image, background color.RGBA) {
+	bounds := img.Bounds()
+	data := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			data = append(data,
+				blend(r, background.R, a), blend(g, background.G, a), blend(b, background.B, a))
+		}
+	}
+	dict := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8",
+		bounds.Dx(), bounds.Dy())
+	d.images[name] = d.addStream(dict, data)
+}
+
+// AddPage appends a page of the given size in points
+func (d *Document) AddPage(width, height float64, canvas *Canvas) {
+	if len(canvas.glyphs) > 0 {
+		if _, ok := d.fonts[unicodeFont]; !ok {
+			d.fonts[unicodeFont] = d.add(nil) // written once all glyphs are known
+			d.font = canvas.font
+			d.glyphs = map[sfnt.GlyphIndex]rune{}
+		}
+		for index, r := range canvas.glyphs {
+			d.glyphs[index] = r
+		}
+	}
+	contentID := d.addStream("", canvas.buf.Bytes())
+	resources := "/Font <<" + resourceList(d.fonts) + " >>"
+	if len(d.images) > 0 {
+		resources += " /XObject <<" + resourceList(d.images) + " >>"
+	}
+	page := fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << %s >> /Contents %d 0 R >>",
+		d.pagesID, num(width), num(height), resources, contentID)
+	d.pages = append(d.pages, d.add([]byte(page)))
+}
+
+// Write writes the complete document with its cross-reference table
+func (d *Document) Write(w io.Writer) error {
+	if id, ok := d.fonts[unicodeFont]; ok {
+		if err := d.writeFont(d.font, id, d.glyphs); err != nil {
+			return err
+		}
+	}
+	kids := make([]string, len(d.pages))
+	for i, id := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", id)
+	}
+	d.objects[d.pagesID-1] = []byte(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	catalogID := d.add([]byte(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", d.pagesID)))
+	trailer := fmt.Sprintf("/Root %d 0 R", catalogID)
+	if len(d.info) > 0 {
+		keys := make([]string, 0, len(d.info))
+		for key := range d.info {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		var info strings.Builder
+		info.WriteString("<<")
+		for _, key := range keys {
+			fmt.Fprintf(&info, " /%s %s", key, textString(d.info[key]))
+		}
+		info.WriteString(" >>")
+		trailer += fmt.Sprintf(" /Info %d 0 R", d.add([]byte(info.String())))
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(d.objects))
+	for i, body := range d.objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n", i+1)
+		out.Write(body)
+		out.WriteString("\nendobj\n")
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(d.objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d %s >>\nstartxref\n%d\n%%%%EOF\n", len(d.objects)+1, trailer, xref)
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// Canvas records the drawing operators of a page. Coordinates are given from
+// the top-left corner and converted to PDF's bottom-left origin.
+type Canvas struct {
+	buf    bytes.Buffer
+	height float64
+	font   *Font
+	glyphs map[sfnt.GlyphIndex]rune
+}
+
+// NewCanvas creates a canvas for a page of the given height in points
+func NewCanvas(height float64) *Canvas {
+	return &Canvas{height: height, font: currentFont.Load()}
+}
+
+// SetFill selects the fill colour, which is also the colour of text
+func (p *Canvas) SetFill(c color.RGBA) {
+	fmt.Fprintf(&p.buf, "%s %s %s rg\n", num(float64(c.R)/255), num(float64(c.G)/255), num(float64(c.B)/255))
+}
+
+// Rect adds a rectangle to the current path
+func (p *Canvas) Rect(x, y, w, h float64) {
+	fmt.Fprintf(&p.buf, "%s %s %s %s re\n", num(x), num(p.height-y-h), num(w), num(h))
+}
+
+// Fill fills the current path
+func (p *Canvas) Fill() {
+	p.buf.WriteString("f\n")
+}
+
+// Line strokes a line of the given width
+func (p *Canvas) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(&p.buf, "%s w %s %s m %s %s l S\n", num(width), num(x1), num(p.height-y1), num(x2), num(p.height-y2))
+}
+
+// Image draws the named image of the document into a rectangle
+func (p *Canvas) Image(name string, x, y, w, h float64) {
+	fmt.Fprintf(&p.buf, "q %s 0 0 %s %s %s cm /%s Do Q\n", num(w), num(h), num(x), num(p.height-y-h), name)
+}
+
+// Text draws a line of text starting at x with its baseline at y. Characters
+// outside Latin-1 are drawn with the font set by SetFont, in its regular
+// weight, and as '?' when it does not cover them.
+func (p *Canvas) Text(s, font string, size, x, y float64) {
+	var ops, run strings.Builder
+	current := ""
+	flush := func() {
+		if run.Len() == 0 {
+			return
+		}
+		if current == unicodeFont {
+			fmt.Fprintf(&ops, " /%s %s Tf <%s> Tj", current, num(size), run.String())
+		} else {
+			fmt.Fprintf(&ops, " /%s %s Tf (%s) Tj", current, num(size), pdfString(run.String()))
+		}
+		run.Reset()
+	}
+	for _, r := range s {
+		r, g := resolve(r, p.font)
+		name := font
+		if g.index != 0 {
+			name = unicodeFont
+		}
+		if name != current {
+			flush()
+			current = name
+		}
+		if g.index == 0 {
+			run.WriteRune(r)
+			continue
+		}
+		fmt.Fprintf(&run, "%04X", uint16(g.index))
+		if p.glyphs == nil {
+			p.glyphs = map[sfnt.GlyphIndex]rune{}
+		}
+		if _, ok := p.glyphs[g.index]; !ok {
+			p.glyphs[g.index] = r
+		}
+	}
+	flush()
+	if ops.Len() == 0 {
+		return
+	}
+	fmt.Fprintf(&p.buf, "BT %s %s Td%s ET\n", num(x), num(p.height-y), ops.String())
+}
+
+// CenteredText draws a line of text centred on cx with its baseline at y,
+// truncating it to maxWidth
+func (p *Canvas) CenteredText(s, font string, size, cx, y, maxWidth float64) {
+	s = FitText(s, font, size, maxWidth)
+	p.Text(s, font, size, cx-TextWidth(s, font, size)/2, y)
+}
+
+// TextWidth returns the width of s in a font at the given size
+func TextWidth(s, font string, size float64) float64 {
+	widths := &helveticaWidths
+	if font == Bold {
+		widths = &helveticaBoldWidths
+	}
+	f := currentFont.Load()
+	width := 0
+	for _, r := range s {
+		r, g := resolve(r, f)
+		switch {
+		case g.index != 0:
+			width += g.width
+		case r <= 0x7e:
+			width += widths[r-0x20]
+		default:
+			width += 556
+		}
+	}
+	return float64(width) * size / 1000
+}
+
+// resolve returns how a character is drawn: printable Latin-1 characters with
+// the built-in fonts, others with their glyph in the TrueType font, and '?'
+// when the font does not cover them
+func resolve(r rune, f *Font) (rune, glyph) {
+	if (r >= 0x20 && r <= 0x7e) || (r >= 0xa0 && r <= 0xff) {
+		return r, glyph{}
+	}
+	if f != nil {
+		if g := f.glyph(r); g.index != 0 {
+			return r, g
+		}
+	}
+	return '?', glyph{}
+}
+
+// FitText shortens s with an ellipsis until it fits in maxWidth
+func FitText(s, font string, size, maxWidth float64) string {
+	if TextWidth(s, font, size) <= maxWidth {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		if candidate := string(runes) + "..."; TextWidth(candidate, font, size) <= maxWidth {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// Wrap breaks text into lines no wider than maxWidth. Line breaks in the text
+// are kept, and words longer than a line are split.
+func Wrap(text, font string, size, maxWidth float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if TextWidth(candidate, font, size) <= maxWidth {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+			// Split words that do not fit on a line of their own
+			runes := []rune(word)
+			for TextWidth(string(runes), font, size) > maxWidth && len(runes) > 1 {
+				n := len(runes) - 1
+				for n > 1 && TextWidth(string(runes[:n]), font, size) > maxWidth {
+					n--
+				}
+				lines = append(lines, string(runes[:n]))
+				runes = runes[n:]
+			}
+			line = string(runes)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Latin1 replaces characters that the built-in fonts cannot draw with '?'
+func Latin1(s string) string {
+	runes := []rune(s)
+	for i, r := range runes {
+		if r < 0x20 || (r > 0x7e && r < 0xa0) || r > 0xff {
+			runes[i] = '?'
+		}
+	}
+	return string(runes)
+}
+
+// textString encodes s as a PDF text string, in UTF-16 when it is not Latin-1
+func textString(s string) string {
+	if Latin1(s) == s {
+		return "(" + pdfString(s) + ")"
+	}
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, unit := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", unit)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// pdfString escapes s for a PDF literal string, encoding it as WinAnsi bytes
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte(byte(r))
+		}
+	}
+	return b.String()
+}
+
+// resourceList lists named objects as PDF resource dictionary entries
+func resourceList(objects map[string]int) string {
+	names := make([]string, 0, len(objects))
+	for name := range objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, " /%s %d 0 R", name, objects[name])
+	}
+	return b.String()
+}
+
+// blend composites a premultiplied colour channel over an opaque background channel
+func blend(channel uint32, background uint8, alpha uint32) byte {
+	return byte((channel + uint32(background)*257*(0xffff-alpha)/0xffff) >> 8)
+}
+
+// num formats a coordinate with at most three decimals
+func num(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+}
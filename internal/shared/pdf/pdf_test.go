@@ -0,0 +1,136 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image/color"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWrap tests breaking text into lines
+func TestWrap(t *testing.T) {
+	width := TextWidth("aaaa aaaa", Regular, 10)
+	assert.Equal(t, []string{"aaaa aaaa", "aaaa", "", "b"}, Wrap("aaaa aaaa aaaa\r\n\nb", Regular, 10, width))
+
+	// Words longer than a line are split
+	assert.Equal(t, []string{"aaaa", "aaaa", "aa"}, Wrap("aaaaaaaaaa", Regular, 10, TextWidth("aaaa", Regular, 10)))
+	assert.Greater(t, TextWidth("Wide", Bold, 10), TextWidth("Wide", Regular, 10))
+}
+
+// TestDocument tests writing a document with text and an info dictionary
+func TestDocument(t *testing.T) {
+	doc := NewDocument()
+	doc.SetInfo("Title", "Report (draft)")
+	for i := 0; i < 2; i++ {
+		canvas := NewCanvas(A4Height)
+		canvas.SetFill(color.RGBA{A: 255})
+		canvas.Text("Hello", Bold, 12, 50, 50)
+		canvas.Line(50, 60, 200, 60, 1)
+		doc.AddPage(A4Width, A4Height, canvas)
+	}
+
+	var out bytes.Buffer
+	assert.NoError(t, doc.Write(&out))
+	file := out.String()
+	assert.True(t, strings.HasPrefix(file, "%PDF-1.4"))
+	assert.Contains(t, file, "/Count 2")
+	assert.Contains(t, file, "/BaseFont /Helvetica-Bold")
+	assert.Contains(t, file, `/Title (Report \(draft\))`)
+	assert.True(t, strings.HasSuffix(file, "%%EOF\n"))
+}
+
+// TestUnicodeText tests drawing Chinese text with an embedded TrueType font
+func TestUnicodeText(t *testing.T) {
+	_, err := ParseFont([]byte("OTTO\x00\x01\x00\x00\x00\x00\x00\x00"))
+	assert.EqualError(t, err, "invalid font: PostScript outlines are not supported, use a TrueType font")
+	font, err := LoadFont("testdata/cjk.ttf")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Without a font, characters outside Latin-1 are drawn as '?'
+	assert.Equal(t, TextWidth("??", Regular, 10), TextWidth("合同", Regular, 10))
+
+	SetFont(font)
+	defer SetFont(nil)
+	assert.Equal(t, 20.0, TextWidth("合同", Regular, 10))
+	assert.Equal(t, []string{"合同书甲", "乙方"}, Wrap("合同书甲乙方", Regular, 10, 40))
+
+	doc := NewDocument()
+	doc.SetInfo("Title", "劳动合同")
+	canvas := NewCanvas(A4Height)
+	canvas.Text("Contract 合同 (双方) 😀", Regular, 12, 50, 50)
+	doc.AddPage(A4Width, A4Height, canvas)
+
+	var out bytes.Buffer
+	assert.NoError(t, doc.Write(&out))
+	file := out.String()
+	assert.Contains(t, file, "/Subtype /Type0")
+	assert.Contains(t, file, "/Encoding /Identity-H")
+	assert.Contains(t, file, "/CIDFontType2")
+	assert.Regexp(t, `/BaseFont /[A-Z]{6}\+CdkTestCJK`, file)
+	assert.Contains(t, file, "/Title <FEFF52B352A85408540C>")
+
+	streams := inflateStreams(t, file)
+	// Latin text stays in Helvetica, the rest switches to the TrueType font
+	assert.Contains(t, streams, "/F1 12 Tf (Contract ) Tj /F3 12 Tf <00030004> Tj /F1 12 Tf ( \\() Tj /F3 12 Tf <00180008> Tj /F1 12 Tf (\\) ?) Tj")
+	assert.Contains(t, streams, "<0003> <5408>")
+	assert.Contains(t, streams, "<0018> <53CC>")
+
+	// The embedded subset keeps the outlines of the used glyphs, and of 又 that
+	// 双 is composed of
+	embedded := 0
+	for _, stream := range rawStreams(t, file) {
+		if !strings.Contains(stream.dict, "/Length1") {
+			continue
+		}
+		embedded++
+		tables, err := readTables(stream.data)
+		if !assert.NoError(t, err) {
+			return
+		}
+		loca := tables["loca"]
+		for index, used := range map[int]bool{3: true, 4: true, 8: true, 23: true, 24: true, 5: false} {
+			length := binary.BigEndian.Uint32(loca[4*index+4:]) - binary.BigEndian.Uint32(loca[4*index:])
+			assert.Equal(t, used, length > 0, "glyph %d", index)
+		}
+	}
+	assert.Equal(t, 1, embedded)
+}
+
+// stream is a decompressed stream of a PDF file with its dictionary
+type stream struct {
+	dict string
+	data []byte
+}
+
+// rawStreams decompresses the streams of a PDF file
+func rawStreams(t *testing.T, file string) []stream {
+	var streams []stream
+	for _, match := range regexp.MustCompile(`(?s)<<([^\n]*?)>>\nstream\n(.*?)\nendstream`).FindAllStringSubmatch(file, -1) {
+		zr, err := zlib.NewReader(strings.NewReader(match[2]))
+		if !assert.NoError(t, err) {
+			continue
+		}
+		data, err := io.ReadAll(zr)
+		assert.NoError(t, err)
+		streams = append(streams, stream{dict: match[1], data: data})
+	}
+	return streams
+}
+
+// inflateStreams returns the decompressed streams of a PDF file as one string
+func inflateStreams(t *testing.T, file string) string {
+	var all strings.Builder
+	for _, stream := range rawStreams(t, file) {
+		all.Write(stream.data)
+		all.WriteString("\n")
+	}
+	return all.String()
+}
@@ -0,0 +1,163 @@
+//go:build ignore
+
+// cjkfont writes cjk.ttf, a small TrueType font for tests whose glyphs are
+// plain boxes for a few Chinese characters. 双 is a composite glyph made of
+// two 又, so that subsetting must keep glyphs only referenced by components.
+//
+//	go run cjkfont.go
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+	"os"
+	"sort"
+	"unicode/utf16"
+)
+
+const (
+	chars      = "中文合同书甲乙方张伟王芳会议室签署人劳动测试又"
+	composite  = '双'
+	unitsPerEm = 1000
+	ascender   = 880
+	descender  = -120
+)
+
+func main() {
+	runes := []rune(chars)
+	numGlyphs := len(runes) + 2 // .notdef, the characters and the composite
+
+	var glyf bytes.Buffer
+	loca := []uint32{0, 0} // .notdef is empty
+	for i := range runes {
+		// Boxes of slightly different widths tell the glyphs apart
+		box(&glyf, 100, -80, 860-int16(i%5)*20, 760)
+		loca = append(loca, uint32(glyf.Len()))
+	}
+	// 双 draws 又 twice, side by side at half the width
+	you := uint16(len(runes))
+	w(&glyf, int16(-1), int16(100), int16(-80), int16(900), int16(760))
+	w(&glyf, uint16(0x0001|0x0002|0x0008|0x0020), you, int16(0), int16(0), uint16(0x2000))
+	w(&glyf, uint16(0x0001|0x0002|0x0008), you, int16(450), int16(0), uint16(0x2000))
+	loca = append(loca, uint32(glyf.Len()))
+
+	var hmtx bytes.Buffer
+	w(&hmtx, uint16(500), int16(0))
+	for i := 1; i < numGlyphs; i++ {
+		w(&hmtx, uint16(1000), int16(100))
+	}
+
+	var locaTable bytes.Buffer
+	w(&locaTable, loca)
+
+	var head bytes.Buffer
+	w(&head, uint32(0x00010000), uint32(0x00010000), uint32(0), uint32(0x5F0F3CF5), uint16(0x000B), uint16(unitsPerEm),
+		uint64(0), uint64(0), int16(100), int16(-80), int16(900), int16(760), uint16(0), uint16(8), int16(2), int16(1), int16(0))
+
+	var hhea bytes.Buffer
+	w(&hhea, uint32(0x00010000), int16(ascender), int16(descender), int16(0), uint16(1000), int16(0), int16(100), int16(900),
+		int16(1), int16(0), int16(0), [4]int16{}, int16(0), uint16(numGlyphs))
+
+	var maxp bytes.Buffer
+	w(&maxp, uint32(0x00010000), uint16(numGlyphs), uint16(4), uint16(1), uint16(8), uint16(2), uint16(2),
+		[6]uint16{}, uint16(2), uint16(1))
+
+	// Windows Unicode BMP cmap, one segment per character
+	mapped := map[rune]uint16{composite: uint16(numGlyphs - 1)}
+	for i, r := range runes {
+		mapped[r] = uint16(i + 1)
+	}
+	codes := make([]rune, 0, len(mapped))
+	for r := range mapped {
+		codes = append(codes, r)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	segments := len(codes) + 1
+	var ends, starts, deltas, offsets []uint16
+	for _, r := range codes {
+		ends = append(ends, uint16(r))
+		starts = append(starts, uint16(r))
+		deltas = append(deltas, mapped[r]-uint16(r))
+		offsets = append(offsets, 0)
+	}
+	ends, starts, deltas, offsets = append(ends, 0xFFFF), append(starts, 0xFFFF), append(deltas, 1), append(offsets, 0)
+	var subtable bytes.Buffer
+	w(&subtable, ends, uint16(0), starts, deltas, offsets)
+	var cmap bytes.Buffer
+	w(&cmap, uint16(0), uint16(1), uint16(3), uint16(1), uint32(12),
+		uint16(4), uint16(14+subtable.Len()), uint16(0), uint16(2*segments), uint16(0), uint16(0), uint16(0))
+	cmap.Write(subtable.Bytes())
+
+	var name bytes.Buffer
+	postScriptName := utf16.Encode([]rune("CdkTestCJK"))
+	w(&name, uint16(0), uint16(1), uint16(18), uint16(3), uint16(1), uint16(0x409), uint16(6), uint16(2*len(postScriptName)), uint16(0), postScriptName)
+
+	os2 := make([]byte, 96)
+	binary.BigEndian.PutUint16(os2[0:], 4)
+	binary.BigEndian.PutUint16(os2[2:], 1000)
+	binary.BigEndian.PutUint16(os2[4:], 400)
+	binary.BigEndian.PutUint16(os2[6:], 5)
+	typoDescender := int16(descender)
+	binary.BigEndian.PutUint16(os2[68:], ascender)
+	binary.BigEndian.PutUint16(os2[70:], uint16(typoDescender))
+	binary.BigEndian.PutUint16(os2[74:], ascender)
+	binary.BigEndian.PutUint16(os2[76:], -descender)
+	binary.BigEndian.PutUint16(os2[86:], 500)
+	binary.BigEndian.PutUint16(os2[88:], 700)
+
+	var post bytes.Buffer
+	w(&post, uint32(0x00030000), uint32(0), int16(-100), int16(50), [5]uint32{})
+
+	tables := map[string][]byte{
+		"OS/2": os2, "cmap": cmap.Bytes(), "glyf": glyf.Bytes(), "head": head.Bytes(), "hhea": hhea.Bytes(),
+		"hmtx": hmtx.Bytes(), "loca": locaTable.Bytes(), "maxp": maxp.Bytes(), "name": name.Bytes(), "post": post.Bytes(),
+	}
+	if err := os.WriteFile("cjk.ttf", font(tables), 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// box writes a simple glyph of one rectangular contour
+func box(b *bytes.Buffer, x0, y0, x1, y1 int16) {
+	w(b, int16(1), x0, y0, x1, y1, uint16(3), uint16(0), [4]uint8{1, 1, 1, 1},
+		[4]int16{x0, 0, x1 - x0, 0}, [4]int16{y0, y1 - y0, 0, y0 - y1})
+	for b.Len()%4 != 0 {
+		b.WriteByte(0)
+	}
+}
+
+// font assembles tables into a font file, sorted by tag
+func font(tables map[string][]byte) []byte {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	var out bytes.Buffer
+	w(&out, uint32(0x00010000), uint16(len(tags)), uint16(128), uint16(3), uint16(16*len(tags)-128))
+	offset := 12 + 16*len(tags)
+	for _, tag := range tags {
+		data := tables[tag]
+		out.WriteString(tag)
+		w(&out, uint32(0), uint32(offset), uint32(len(data)))
+		offset += (len(data) + 3) &^ 3
+	}
+	for _, tag := range tags {
+		out.Write(tables[tag])
+		for out.Len()%4 != 0 {
+			out.WriteByte(0)
+		}
+	}
+	return out.Bytes()
+}
+
+// w writes values in big-endian order
+func w(b *bytes.Buffer, values ...interface{}) {
+	for _, v := range values {
+		if err := binary.Write(b, binary.BigEndian, v); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -2,6 +2,10 @@ package testutils
 
 import (
 	appdomain "cdk-office/internal/app/domain"
+	approvaldomain "cdk-office/internal/approval/domain"
+	authdomain "cdk-office/internal/auth/domain"
+	businessdomain "cdk-office/internal/business/domain"
+	difydomain "cdk-office/internal/dify/domain"
 	documentdomain "cdk-office/internal/document/domain"
 	employeedomain "cdk-office/internal/employee/domain"
 	"cdk-office/internal/shared/database"
@@ -21,6 +25,7 @@ func SetupTestDB() *gorm.DB {
 	// Migrate the schema
 	db.AutoMigrate(&appdomain.Application{})
 	db.AutoMigrate(&appdomain.QRCode{})
+	db.AutoMigrate(&appdomain.QRCodeScan{})
 	db.AutoMigrate(&appdomain.AppPermission{})
 	db.AutoMigrate(&appdomain.AppUserPermission{})
 	db.AutoMigrate(&appdomain.BatchQRCode{})
@@ -30,10 +35,46 @@ func SetupTestDB() *gorm.DB {
 	db.AutoMigrate(&appdomain.FormData{})
 	db.AutoMigrate(&appdomain.FormDataEntry{})
 	db.AutoMigrate(&appdomain.FormDesign{})
+	db.AutoMigrate(&approvaldomain.ApprovalDefinition{})
+	db.AutoMigrate(&approvaldomain.ApprovalRequest{})
+	db.AutoMigrate(&approvaldomain.ApprovalTask{})
+	db.AutoMigrate(&approvaldomain.ApprovalDelegation{})
+	db.AutoMigrate(&authdomain.User{})
+	db.AutoMigrate(&authdomain.UserRole{})
+	db.AutoMigrate(&authdomain.Role{})
+	db.AutoMigrate(&authdomain.Permission{})
+	db.AutoMigrate(&authdomain.RolePermission{})
+	db.AutoMigrate(&authdomain.UserMFA{})
+	db.AutoMigrate(&authdomain.MFARecoveryCode{})
+	db.AutoMigrate(&authdomain.MFARolePolicy{})
+	db.AutoMigrate(&authdomain.Session{})
+	db.AutoMigrate(&authdomain.PasswordHistory{})
+	db.AutoMigrate(&authdomain.PasswordResetToken{})
+	db.AutoMigrate(&authdomain.UserIdentity{})
+	db.AutoMigrate(&businessdomain.Contract{})
+	db.AutoMigrate(&businessdomain.ContractSigner{})
+	db.AutoMigrate(&businessdomain.ContractEvent{})
+	db.AutoMigrate(&businessdomain.ContractTemplate{})
+	db.AutoMigrate(&difydomain.Conversation{})
+	db.AutoMigrate(&difydomain.ConversationMessage{})
+	db.AutoMigrate(&difydomain.ConversationCitation{})
+	db.AutoMigrate(&difydomain.AgentRun{})
+	db.AutoMigrate(&difydomain.AgentRunStep{})
 	db.AutoMigrate(&documentdomain.Document{})
 	db.AutoMigrate(&documentdomain.DocumentVersion{})
 	db.AutoMigrate(&documentdomain.DocumentCategory{})
 	db.AutoMigrate(&documentdomain.DocumentCategoryRelation{})
+	db.AutoMigrate(&documentdomain.UploadSession{})
+	db.AutoMigrate(&documentdomain.UploadChunk{})
+	db.AutoMigrate(&documentdomain.SearchIndexEntry{})
+	db.AutoMigrate(&documentdomain.SearchPosting{})
+	db.AutoMigrate(&documentdomain.ProcessingJob{})
+	db.AutoMigrate(&documentdomain.ProcessingStep{})
+	db.AutoMigrate(&documentdomain.KnowledgeDataset{})
+	db.AutoMigrate(&documentdomain.KnowledgeDocument{})
+	db.AutoMigrate(&documentdomain.DocumentChunk{})
+	db.AutoMigrate(&documentdomain.DocumentEmbedding{})
+	db.AutoMigrate(&documentdomain.DocumentDuplicate{})
 	db.AutoMigrate(&employeedomain.Employee{})
 	db.AutoMigrate(&employeedomain.Department{})
 	db.AutoMigrate(&employeedomain.PerformanceReview{})
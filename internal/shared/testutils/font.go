@@ -0,0 +1,13 @@
+package testutils
+
+import (
+	"path/filepath"
+	"runtime"
+)
+
+// CJKFontPath returns the path of a small TrueType font covering a few Chinese
+// characters, such as 合同, for tests of documents and images with Chinese text
+func CJKFontPath() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "pdf", "testdata", "cjk.ttf")
+}
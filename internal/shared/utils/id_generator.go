@@ -1,6 +1,7 @@
 package utils
 
 import (
+	crand "crypto/rand"
 	"fmt"
 	"math/rand"
 	"time"
@@ -72,6 +73,90 @@ func GenerateContractID() string {
 	return "contract_" + time.Now().Format("20060102150405") + generateRandomSuffix()
 }
 
+// GenerateContractSignerID generates a unique ID for contract signers
+func GenerateContractSignerID() string {
+	// In a real application, use a proper ID generation library like uuid
+	return "contract_signer_" + time.Now().Format("20060102150405") + generateRandomSuffix()
+}
+
+// GenerateContractEventID generates a unique ID for contract audit events
+func GenerateContractEventID() string {
+	// In a real application, use a proper ID generation library like uuid
+	return "contract_event_" + time.Now().Format("20060102150405") + generateRandomSuffix()
+}
+
+// GenerateContractTemplateID generates a unique ID for contract templates
+func GenerateContractTemplateID() string {
+	// In a real application, use a proper ID generation library like uuid
+	return "contract_tpl_" + time.Now().Format("20060102150405") + generateRandomSuffix()
+}
+
+// GenerateApprovalDefinitionID generates a unique ID for approval definitions
+func GenerateApprovalDefinitionID() string {
+	// In a real application, use a proper ID generation library like uuid
+	return "approval_def_" + time.Now().Format("20060102150405") + generateRandomSuffix()
+}
+
+// GenerateApprovalRequestID generates a unique ID for approval requests
+func GenerateApprovalRequestID() string {
+	// In a real application, use a proper ID generation library like uuid
+	return "approval_req_" + time.Now().Format("20060102150405") + generateRandomSuffix()
+}
+
+// GenerateApprovalTaskID generates a unique ID for approval tasks
+func GenerateApprovalTaskID() string {
+	// In a real application, use a proper ID generation library like uuid
+	return "approval_task_" + time.Now().Format("20060102150405") + generateRandomSuffix()
+}
+
+// GenerateApprovalDelegationID generates a unique ID for approval delegations
+func GenerateApprovalDelegationID() string {
+	// In a real application, use a proper ID generation library like uuid
+	return "approval_dlg_" + time.Now().Format("20060102150405") + generateRandomSuffix()
+}
+
+// GenerateMFARecoveryCodeID generates a unique ID for MFA recovery codes
+func GenerateMFARecoveryCodeID() string {
+	// In a real application, use a proper ID generation library like uuid
+	return "mfa_rc_" + time.Now().Format("20060102150405") + generateRandomSuffix()
+}
+
+// GenerateSessionID generates a unique ID for login sessions
+func GenerateSessionID() string {
+	// In a real application, use a proper ID generation library like uuid
+	return "session_" + time.Now().Format("20060102150405") + generateRandomSuffix()
+}
+
+// GeneratePasswordHistoryID generates a unique ID for password history entries
+func GeneratePasswordHistoryID() string {
+	// In a real application, use a proper ID generation library like uuid
+	return "pwd_hist_" + time.Now().Format("20060102150405") + generateRandomSuffix()
+}
+
+// GeneratePasswordResetTokenID generates a unique ID for password reset tokens
+func GeneratePasswordResetTokenID() string {
+	// In a real application, use a proper ID generation library like uuid
+	return "pwd_reset_" + time.Now().Format("20060102150405") + generateRandomSuffix()
+}
+
+// GenerateUserIdentityID generates a unique ID for user identities
+func GenerateUserIdentityID() string {
+	// In a real application, use a proper ID generation library like uuid
+	return "identity_" + time.Now().Format("20060102150405") + generateRandomSuffix()
+}
+
+// GenerateUserID generates a unique ID for users
+func GenerateUserID() string {
+	// In a real application, use a proper ID generation library like uuid
+	return "user_" + time.Now().Format("20060102150405") + generateRandomSuffix()
+}
+
+// GenerateUserRoleID generates a unique ID for user roles
+func GenerateUserRoleID() string {
+	// In a real application, use a proper ID generation library like uuid
+	return "user_role_" + time.Now().Format("20060102150405") + generateRandomSuffix()
+}
+
 // GenerateModuleID generates a unique ID for modules
 func GenerateModuleID() string {
 	// In a real application, use a proper ID generation library like uuid
@@ -120,12 +205,82 @@ func GenerateDocumentVersionID() string {
 	return "doc_ver_" + time.Now().Format("20060102150405") + generateRandomSuffix()
 }
 
+// GenerateUploadSessionID generates a unique ID for upload sessions
+func GenerateUploadSessionID() string {
+	// In a real application, use a proper ID generation library like uuid
+	return "upload_" + time.Now().Format("20060102150405") + generateRandomSuffix()
+}
+
+// GenerateUploadChunkID generates a unique ID for upload chunks
+func GenerateUploadChunkID() string {
+	// In a real application, use a proper ID generation library like uuid
+	return "upload_chunk_" + time.Now().Format("20060102150405") + generateRandomSuffix()
+}
+
+// GenerateProcessingJobID generates a unique ID for document processing jobs
+func GenerateProcessingJobID() string {
+	// In a real application, use a proper ID generation library like uuid
+	return "proc_job_" + time.Now().Format("20060102150405") + generateRandomSuffix()
+}
+
+// GenerateProcessingStepID generates a unique ID for document processing steps
+func GenerateProcessingStepID() string {
+	// In a real application, use a proper ID generation library like uuid
+	return "proc_step_" + time.Now().Format("20060102150405") + generateRandomSuffix()
+}
+
 // GenerateSurveyResponseID generates a unique ID for survey responses
 func GenerateSurveyResponseID() string {
 	// In a real application, use a proper ID generation library like uuid
 	return "survey_resp_" + time.Now().Format("20060102150405") + generateRandomSuffix()
 }
 
+// GenerateQRCodeScanID generates a unique ID for QR code scans
+func GenerateQRCodeScanID() string {
+	// In a real application, use a proper ID generation library like uuid
+	return "qrscan_" + time.Now().Format("20060102150405") + generateRandomSuffix()
+}
+
+// GenerateConversationID generates a unique ID for conversations
+func GenerateConversationID() string {
+	// In a real application, use a proper ID generation library like uuid
+	return "conv_" + time.Now().Format("20060102150405") + generateRandomSuffix()
+}
+
+// GenerateConversationMessageID generates a unique ID for conversation messages
+func GenerateConversationMessageID() string {
+	// In a real application, use a proper ID generation library like uuid
+	return "conv_msg_" + time.Now().Format("20060102150405") + generateRandomSuffix()
+}
+
+// GenerateConversationCitationID generates a unique ID for conversation citations
+func GenerateConversationCitationID() string {
+	// In a real application, use a proper ID generation library like uuid
+	return "conv_cite_" + time.Now().Format("20060102150405") + generateRandomSuffix()
+}
+
+// GenerateAgentRunID generates a unique ID for agent runs
+func GenerateAgentRunID() string {
+	// In a real application, use a proper ID generation library like uuid
+	return "agent_run_" + time.Now().Format("20060102150405") + generateRandomSuffix()
+}
+
+// shortCodeAlphabet holds the characters used in short codes
+const shortCodeAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// GenerateShortCode generates a random code of the given length for short links.
+// Codes are published, so they come from a cryptographically secure source.
+func GenerateShortCode(length int) string {
+	code := make([]byte, length)
+	if _, err := crand.Read(code); err != nil {
+		panic(err)
+	}
+	for i, b := range code {
+		code[i] = shortCodeAlphabet[int(b)%len(shortCodeAlphabet)]
+	}
+	return string(code)
+}
+
 // generateRandomSuffix generates a random suffix to ensure uniqueness
 func generateRandomSuffix() string {
 	return fmt.Sprintf("%06d", rand.Intn(1000000))
@@ -464,4 +464,15 @@ func TestGenerateRandomSuffix(t *testing.T) {
 	assert.Len(t, suffix3, 6)
 	// Note: There's a small chance these could be equal, but it's very unlikely
 	// We're not asserting they're different because randomness can occasionally produce the same value
-}
\ No newline at end of file
+}
+func TestGenerateShortCode(t *testing.T) {
+	code1 := GenerateShortCode(8)
+	code2 := GenerateShortCode(8)
+
+	// Assertions
+	assert.Len(t, code1, 8)
+	assert.NotEqual(t, code1, code2)
+	for _, r := range code1 {
+		assert.True(t, strings.ContainsRune(shortCodeAlphabet, r))
+	}
+}
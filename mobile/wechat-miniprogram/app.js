@@ -74,10 +74,7 @@ App({
       },
       success: (res) => {
         if (res.statusCode === 200) {
-          // 保存token到本地存储
-          wx.setStorageSync('token', res.data.token);
-          this.globalData.token = res.data.token;
-          this.globalData.userInfo = res.data.user;
+          this.saveLogin(res.data);
           successCallback(res.data);
         } else {
           errorCallback(res.data.error);
@@ -99,10 +96,10 @@ App({
       },
       success: (res) => {
         if (res.statusCode === 200) {
-          // 保存token到本地存储
-          wx.setStorageSync('token', res.data.token);
-          this.globalData.token = res.data.token;
-          this.globalData.userInfo = res.data.user;
+          // 微信账号未绑定时返回绑定令牌，由调用方引导用户输入账号密码
+          if (!res.data.wechat_bind_required) {
+            this.saveLogin(res.data);
+          }
           successCallback(res.data);
         } else {
           errorCallback(res.data.error);
@@ -112,5 +109,96 @@ App({
         errorCallback('网络错误');
       }
     });
+  },
+
+  // 用账号密码登录并绑定微信
+  wechatBindLogin(bindToken, username, password, successCallback, errorCallback) {
+    wx.request({
+      url: `${this.globalData.apiUrl}/auth/wechat/bind-login`,
+      method: 'POST',
+      data: {
+        bind_token: bindToken,
+        username: username,
+        password: password
+      },
+      success: (res) => {
+        if (res.statusCode === 200) {
+          this.saveLogin(res.data);
+          successCallback(res.data);
+        } else {
+          errorCallback(res.data.error);
+        }
+      },
+      fail: (err) => {
+        errorCallback('网络错误');
+      }
+    });
+  },
+
+  // 解密并绑定用户授权的手机号，需要新的登录凭证以获取会话密钥
+  bindPhoneNumber(encryptedData, iv, successCallback, errorCallback) {
+    wx.login({
+      success: (loginRes) => {
+        wx.request({
+          url: `${this.globalData.apiUrl}/auth/wechat/phone`,
+          method: 'POST',
+          header: {
+            'Authorization': `Bearer ${this.globalData.token}`
+          },
+          data: {
+            code: loginRes.code,
+            encrypted_data: encryptedData,
+            iv: iv
+          },
+          success: (res) => {
+            if (res.statusCode === 200) {
+              successCallback(res.data.phone);
+            } else {
+              errorCallback(res.data.error);
+            }
+          },
+          fail: (err) => {
+            errorCallback('网络错误');
+          }
+        });
+      },
+      fail: () => {
+        errorCallback('微信登录失败');
+      }
+    });
+  },
+
+  // 解除微信绑定
+  unbindWechat(successCallback, errorCallback) {
+    wx.request({
+      url: `${this.globalData.apiUrl}/auth/wechat/bind`,
+      method: 'DELETE',
+      header: {
+        'Authorization': `Bearer ${this.globalData.token}`
+      },
+      success: (res) => {
+        if (res.statusCode === 200) {
+          successCallback();
+        } else {
+          errorCallback(res.data.error);
+        }
+      },
+      fail: (err) => {
+        errorCallback('网络错误');
+      }
+    });
+  },
+
+  // 保存登录结果
+  saveLogin(data) {
+    // 需要二次验证时尚未签发token
+    if (!data.access_token) {
+      return;
+    }
+    // 保存token到本地存储
+    wx.setStorageSync('token', data.access_token);
+    wx.setStorageSync('refreshToken', data.refresh_token);
+    this.globalData.token = data.access_token;
+    this.globalData.userInfo = data.user;
   }
 })
\ No newline at end of file
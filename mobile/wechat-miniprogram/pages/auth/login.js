@@ -3,7 +3,8 @@ Page({
   data: {
     username: '',
     password: '',
-    isLoggingIn: false
+    isLoggingIn: false,
+    bindToken: '' // 微信账号未绑定时，用账号密码登录以完成绑定
   },
 
   onLoad() {
@@ -57,9 +58,12 @@ Page({
       isLoggingIn: true
     });
     
-    // 调用应用实例的登录方法
+    // 调用应用实例的登录方法，微信账号待绑定时同时完成绑定
     const app = getApp();
-    app.login(username, password, (res) => {
+    const login = this.data.bindToken
+      ? (success, fail) => app.wechatBindLogin(this.data.bindToken, username, password, success, fail)
+      : (success, fail) => app.login(username, password, success, fail);
+    login((res) => {
       // 登录成功
       this.setData({
         isLoggingIn: false
@@ -102,6 +106,19 @@ Page({
           // 调用应用实例的微信登录方法
           const app = getApp();
           app.wechatLogin(res.code, (res) => {
+            // 微信账号尚未绑定，提示输入账号密码
+            if (res.wechat_bind_required) {
+              this.setData({
+                isLoggingIn: false,
+                bindToken: res.wechat_bind_token
+              });
+              wx.showToast({
+                title: '请输入账号密码以绑定微信',
+                icon: 'none'
+              });
+              return;
+            }
+
             // 登录成功
             this.setData({
               isLoggingIn: false
@@ -146,6 +146,56 @@ Page({
     }
   },
 
+  // 绑定微信授权的手机号
+  onGetPhoneNumber(e) {
+    if (!e.detail.encryptedData) {
+      // 用户拒绝授权
+      return;
+    }
+    
+    const app = getApp();
+    app.bindPhoneNumber(e.detail.encryptedData, e.detail.iv, (phone) => {
+      const userInfo = Object.assign({}, this.data.userInfo, { phone: phone });
+      app.globalData.userInfo = userInfo;
+      this.setData({
+        userInfo: userInfo
+      })
+      
+      wx.showToast({
+        title: '手机号已绑定',
+        icon: 'success'
+      })
+    }, (error) => {
+      wx.showToast({
+        title: error || '绑定手机号失败',
+        icon: 'none'
+      })
+    })
+  },
+
+  // 解除微信绑定
+  unbindWechat() {
+    wx.showModal({
+      title: '解除绑定',
+      content: '解除后将无法使用微信登录，确定继续吗？',
+      success: (res) => {
+        if (res.confirm) {
+          getApp().unbindWechat(() => {
+            wx.showToast({
+              title: '已解除绑定',
+              icon: 'success'
+            })
+          }, (error) => {
+            wx.showToast({
+              title: error || '解除绑定失败',
+              icon: 'none'
+            })
+          })
+        }
+      }
+    })
+  },
+
   // 退出登录
   logout() {
     wx.showModal({
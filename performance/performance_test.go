@@ -15,6 +15,7 @@ import (
 	"cdk-office/internal/document/handler"
 	"cdk-office/internal/document/service"
 	"cdk-office/internal/dify/client"
+	"cdk-office/pkg/config"
 	"github.com/gin-gonic/gin"
 )
 
@@ -118,7 +119,7 @@ func TestDocumentUploadPerformance(t *testing.T) {
 			FilePath:    req.FilePath,
 			FileSize:    req.FileSize,
 			MimeType:    req.MimeType,
-			OwnerID:     req.OwnerID,
+			OwnerID:     c.GetString("user_id"),
 			TeamID:      req.TeamID,
 			Tags:        req.Tags,
 		})
@@ -513,7 +514,12 @@ func BenchmarkDocumentUpload(b *testing.B) {
 	router := gin.New()
 	
 	// Create document handler
-	docHandler := handler.NewDocumentHandler()
+	cfg := config.Default()
+	cfg.Storage.LocalPath = b.TempDir()
+	docHandler, err := handler.NewDocumentHandler(cfg)
+	if err != nil {
+		b.Fatal(err)
+	}
 	
 	// Register routes
 	router.POST("/documents", docHandler.Upload)
@@ -566,7 +572,12 @@ func BenchmarkDocumentSearch(b *testing.B) {
 	router := gin.New()
 	
 	// Create search handler
-	searchHandler := handler.NewSearchHandler()
+	cfg := config.Default()
+	cfg.Storage.LocalPath = b.TempDir()
+	searchHandler, err := handler.NewSearchHandler(&cfg.Storage)
+	if err != nil {
+		b.Fatal(err)
+	}
 	
 	// Register routes
 	router.GET("/documents/search", searchHandler.SearchDocuments)
@@ -0,0 +1,106 @@
+package config
+
+import "time"
+
+// LoginThrottleConfig holds the configuration of login failure limits
+type LoginThrottleConfig struct {
+	Window             time.Duration `yaml:"window"`               // period failures are counted over
+	MaxAccountFailures int           `yaml:"max_account_failures"` // failures on an account before it is locked
+	MaxIPFailures      int           `yaml:"max_ip_failures"`      // failures from an address before it is locked
+	LockoutBase        time.Duration `yaml:"lockout_base"`         // first lockout, doubled by each further lockout
+	LockoutMax         time.Duration `yaml:"lockout_max"`
+	LockoutMemory      time.Duration `yaml:"lockout_memory"` // how long a lockout lengthens the next one
+}
+
+// defaultLoginThrottleConfig returns the default login failure limits
+func defaultLoginThrottleConfig() LoginThrottleConfig {
+	return LoginThrottleConfig{
+		Window:             15 * time.Minute,
+		MaxAccountFailures: 5,
+		MaxIPFailures:      20,
+		LockoutBase:        5 * time.Minute,
+		LockoutMax:         24 * time.Hour,
+		LockoutMemory:      24 * time.Hour,
+	}
+}
+
+// loadEnv applies the LOGIN_* environment variables
+func (c *LoginThrottleConfig) loadEnv(env *envLoader) {
+	env.duration("LOGIN_FAILURE_WINDOW", &c.Window)
+	env.int("LOGIN_MAX_ACCOUNT_FAILURES", &c.MaxAccountFailures)
+	env.int("LOGIN_MAX_IP_FAILURES", &c.MaxIPFailures)
+	env.duration("LOGIN_LOCKOUT_BASE", &c.LockoutBase)
+	env.duration("LOGIN_LOCKOUT_MAX", &c.LockoutMax)
+	env.duration("LOGIN_LOCKOUT_MEMORY", &c.LockoutMemory)
+}
+
+// PasswordPolicyConfig holds the rules new passwords must follow
+type PasswordPolicyConfig struct {
+	MinLength      int    `yaml:"min_length"`
+	MinCharClasses int    `yaml:"min_char_classes"` // of lower case letters, upper case letters, digits and symbols
+	BreachListPath string `yaml:"breach_list_path"` // file of breached passwords, one per line; empty to skip the check
+	HistorySize    int    `yaml:"history_size"`     // recent passwords, the current one included, that may not be reused
+}
+
+// defaultPasswordPolicyConfig returns the default password policy
+func defaultPasswordPolicyConfig() PasswordPolicyConfig {
+	return PasswordPolicyConfig{
+		MinLength:      10,
+		MinCharClasses: 3,
+		HistorySize:    5,
+	}
+}
+
+// loadEnv applies the PASSWORD_* environment variables
+func (c *PasswordPolicyConfig) loadEnv(env *envLoader) {
+	env.int("PASSWORD_MIN_LENGTH", &c.MinLength)
+	env.int("PASSWORD_MIN_CHAR_CLASSES", &c.MinCharClasses)
+	env.string("PASSWORD_BREACH_LIST_PATH", &c.BreachListPath)
+	env.int("PASSWORD_HISTORY_SIZE", &c.HistorySize)
+}
+
+// PasswordResetConfig holds the configuration of self-service password resets
+type PasswordResetConfig struct {
+	TokenTTL    time.Duration `yaml:"token_ttl"`
+	ResetURL    string        `yaml:"reset_url"`    // page of the web app that takes the token as its token query parameter
+	MaxRequests int           `yaml:"max_requests"` // reset messages a user can be sent per hour
+}
+
+// defaultPasswordResetConfig returns the default password reset configuration
+func defaultPasswordResetConfig() PasswordResetConfig {
+	return PasswordResetConfig{
+		TokenTTL:    30 * time.Minute,
+		ResetURL:    "http://localhost:8080/reset-password",
+		MaxRequests: 5,
+	}
+}
+
+// loadEnv applies the PASSWORD_RESET_* environment variables
+func (c *PasswordResetConfig) loadEnv(env *envLoader) {
+	env.duration("PASSWORD_RESET_TOKEN_TTL", &c.TokenTTL)
+	env.string("PASSWORD_RESET_URL", &c.ResetURL)
+	env.int("PASSWORD_RESET_MAX_REQUESTS", &c.MaxRequests)
+}
+
+// JWTConfig holds the configuration of the access and refresh tokens
+type JWTConfig struct {
+	Secret          string        `yaml:"secret"`
+	AccessTokenTTL  time.Duration `yaml:"access_token_ttl"`
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl"`
+}
+
+// defaultJWTConfig returns the default token configuration. There is no
+// default secret.
+func defaultJWTConfig() JWTConfig {
+	return JWTConfig{
+		AccessTokenTTL:  2 * time.Hour,
+		RefreshTokenTTL: 7 * 24 * time.Hour,
+	}
+}
+
+// loadEnv applies the JWT_* environment variables
+func (c *JWTConfig) loadEnv(env *envLoader) {
+	env.string("JWT_SECRET", &c.Secret)
+	env.duration("JWT_ACCESS_TOKEN_TTL", &c.AccessTokenTTL)
+	env.duration("JWT_REFRESH_TOKEN_TTL", &c.RefreshTokenTTL)
+}
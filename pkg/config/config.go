@@ -1,7 +1,199 @@
 package config
 
-// Init initializes the configuration
-func Init() {
-	// Initialize configuration
-	// This function can be expanded to load configuration from files or environment variables
-}
\ No newline at end of file
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Profiles
+const (
+	ProfileDevelopment = "development"
+	ProfileTest        = "test"
+	ProfileProduction  = "production" // validated strictly
+)
+
+// Config is the configuration of the application. It is loaded from the
+// defaults, then YAML files, then environment variables.
+type Config struct {
+	Profile        string               `yaml:"profile"`
+	Server         ServerConfig         `yaml:"server"`
+	JWT            JWTConfig            `yaml:"jwt"`
+	Database       DatabaseConfig       `yaml:"database"`
+	Redis          RedisConfig          `yaml:"redis"`
+	Storage        StorageConfig        `yaml:"storage"`
+	Dify           DifyConfig           `yaml:"dify"`
+	Processing     ProcessingConfig     `yaml:"processing"`
+	BatchQRCode    BatchQRCodeConfig    `yaml:"batch_qrcode"`
+	QRCode         QRCodeConfig         `yaml:"qrcode"`
+	Contract       ContractConfig       `yaml:"contract"`
+	Font           FontConfig           `yaml:"font"`
+	MFA            MFAConfig            `yaml:"mfa"`
+	LoginThrottle  LoginThrottleConfig  `yaml:"login_throttle"`
+	PasswordPolicy PasswordPolicyConfig `yaml:"password_policy"`
+	PasswordReset  PasswordResetConfig  `yaml:"password_reset"`
+	Notify         NotifyConfig         `yaml:"notify"`
+	WeChat         WeChatConfig         `yaml:"wechat"`
+	SSO            SSOConfig            `yaml:"sso"`
+	Vector         VectorConfig         `yaml:"vector"`
+}
+
+// Default returns the default configuration
+func Default() *Config {
+	return &Config{
+		Profile:        ProfileDevelopment,
+		Server:         defaultServerConfig(),
+		JWT:            defaultJWTConfig(),
+		Database:       defaultDatabaseConfig(),
+		Redis:          defaultRedisConfig(),
+		Storage:        defaultStorageConfig(),
+		Dify:           defaultDifyConfig(),
+		Processing:     defaultProcessingConfig(),
+		BatchQRCode:    defaultBatchQRCodeConfig(),
+		QRCode:         defaultQRCodeConfig(),
+		MFA:            defaultMFAConfig(),
+		LoginThrottle:  defaultLoginThrottleConfig(),
+		PasswordPolicy: defaultPasswordPolicyConfig(),
+		PasswordReset:  defaultPasswordResetConfig(),
+		Notify:         defaultNotifyConfig(),
+		WeChat:         defaultWeChatConfig(),
+		SSO:            defaultSSOConfig(),
+		Vector:         defaultVectorConfig(),
+	}
+}
+
+// Options select what a configuration is loaded from
+type Options struct {
+	// Files are YAML files applied in order, later files overriding earlier ones
+	Files []string
+	// Profile overrides the profile of the files when set
+	Profile string
+	// LookupEnv looks environment variables up, os.LookupEnv for the process
+	// environment; nil skips environment overrides
+	LookupEnv func(key string) (string, bool)
+}
+
+// Load loads and validates a configuration
+func Load(opts Options) (*Config, error) {
+	cfg, err := load(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// load loads a configuration without validating it
+func load(opts Options) (*Config, error) {
+	cfg := Default()
+	for _, file := range opts.Files {
+		if err := cfg.loadFile(file); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Profile != "" {
+		cfg.Profile = opts.Profile
+	}
+
+	if opts.LookupEnv != nil {
+		env := &envLoader{lookup: opts.LookupEnv}
+		cfg.loadEnv(env)
+		if len(env.errs) > 0 {
+			return nil, &ValidationError{Problems: env.errs}
+		}
+	}
+	return cfg, nil
+}
+
+// loadFile applies a YAML file. Unknown keys are rejected so typos do not
+// silently leave the default in place.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv applies the environment variable overrides
+func (c *Config) loadEnv(env *envLoader) {
+	env.string("APP_ENV", &c.Profile)
+	c.Server.loadEnv(env)
+	c.JWT.loadEnv(env)
+	c.Database.loadEnv(env)
+	c.Redis.loadEnv(env)
+	c.Storage.loadEnv(env)
+	c.Dify.loadEnv(env)
+	c.Processing.loadEnv(env)
+	c.BatchQRCode.loadEnv(env)
+	c.QRCode.loadEnv(env)
+	c.Contract.loadEnv(env)
+	c.Font.loadEnv(env)
+	c.MFA.loadEnv(env)
+	c.LoginThrottle.loadEnv(env)
+	c.PasswordPolicy.loadEnv(env)
+	c.PasswordReset.loadEnv(env)
+	c.Notify.loadEnv(env)
+	c.WeChat.loadEnv(env)
+	c.SSO.loadEnv(env)
+	c.Vector.loadEnv(env)
+}
+
+// ProfileFiles returns the files of a profile in a config directory:
+// config.yaml shared by all profiles, then <profile>.yaml. Missing files are
+// skipped.
+func ProfileFiles(dir, profile string) []string {
+	var files []string
+	for _, name := range []string{"config.yaml", profile + ".yaml"} {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+var (
+	mu       sync.RWMutex
+	current  *Config
+	fallback *Config
+	once     sync.Once
+)
+
+// Init sets the configuration of the process, read by the Get functions
+func Init(cfg *Config) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = cfg
+}
+
+// Get returns the configuration of the process. Before Init it returns the
+// defaults with the environment overrides that parse, unvalidated.
+func Get() *Config {
+	mu.RLock()
+	cfg := current
+	mu.RUnlock()
+	if cfg != nil {
+		return cfg
+	}
+
+	once.Do(func() {
+		fallback = Default()
+		fallback.loadEnv(&envLoader{lookup: os.LookupEnv})
+	})
+	return fallback
+}
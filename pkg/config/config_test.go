@@ -0,0 +1,299 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testFont is a TrueType font of the repository for font.path
+var testFont = filepath.Join("..", "..", "internal", "shared", "pdf", "testdata", "cjk.ttf")
+
+// mapEnv returns a LookupEnv function reading from a map
+func mapEnv(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := vars[key]
+		return value, ok
+	}
+}
+
+// writeFile writes a file in a temporary directory and returns its path
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+// validationProblems returns the problems of a validation error
+func validationProblems(t *testing.T, err error) []string {
+	t.Helper()
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr), "expected a validation error, got %v", err)
+	return validationErr.Problems
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+jwt:
+  secret: from-file
+  access_token_ttl: 15m
+server:
+  port: 9000
+processing:
+  workers: 8
+`)
+
+	cfg, err := Load(Options{
+		Files: []string{file},
+		LookupEnv: mapEnv(map[string]string{
+			"SERVER_PORT":            "9100",
+			"SERVER_TRUSTED_PROXIES": "10.0.0.1, 172.16.0.0/12",
+			"REDIS_ADDR":             "redis:6379",
+			"DB_HOST":                "",
+			"JWT_SECRET":             "from-env",
+			"DIFY_API_KEY":           "key",
+		}),
+	})
+	require.NoError(t, err)
+
+	// Environment over file over defaults
+	assert.Equal(t, "from-env", cfg.JWT.Secret)
+	assert.Equal(t, 9100, cfg.Server.Port)
+	assert.Equal(t, []string{"10.0.0.1", "172.16.0.0/12"}, cfg.Server.TrustedProxies)
+	assert.Equal(t, "redis:6379", cfg.Redis.Addr)
+	assert.Equal(t, 15*time.Minute, cfg.JWT.AccessTokenTTL)
+	assert.Equal(t, 8, cfg.Processing.Workers)
+	assert.Equal(t, 5, cfg.Processing.MaxAttempts)
+	// Empty variables are ignored
+	assert.Equal(t, "localhost", cfg.Database.Host)
+	assert.Equal(t, ProfileDevelopment, cfg.Profile)
+}
+
+func TestLoadLaterFilesOverride(t *testing.T) {
+	base := writeFile(t, "config.yaml", "jwt:\n  secret: base\nserver:\n  port: 9000\n")
+	profile := writeFile(t, "test.yaml", "profile: test\nserver:\n  port: 9001\n")
+
+	cfg, err := Load(Options{Files: []string{base, profile}})
+	require.NoError(t, err)
+	assert.Equal(t, ProfileTest, cfg.Profile)
+	assert.Equal(t, 9001, cfg.Server.Port)
+	assert.Equal(t, "base", cfg.JWT.Secret)
+}
+
+func TestLoadSecretFile(t *testing.T) {
+	secret := writeFile(t, "jwt_secret", "mounted-secret\n")
+
+	cfg, err := Load(Options{LookupEnv: mapEnv(map[string]string{
+		"JWT_SECRET":      "ignored",
+		"JWT_SECRET_FILE": secret,
+	})})
+	require.NoError(t, err)
+	assert.Equal(t, "mounted-secret", cfg.JWT.Secret)
+
+	_, err = Load(Options{LookupEnv: mapEnv(map[string]string{
+		"JWT_SECRET_FILE": filepath.Join(t.TempDir(), "missing"),
+	})})
+	problems := validationProblems(t, err)
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0], "JWT_SECRET_FILE")
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	file := writeFile(t, "config.yaml", "jwt:\n  secret: s\n  secert_ttl: 1h\n")
+
+	_, err := Load(Options{Files: []string{file}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "secert_ttl")
+}
+
+func TestLoadReportsInvalidEnvironment(t *testing.T) {
+	_, err := Load(Options{LookupEnv: mapEnv(map[string]string{
+		"JWT_SECRET":         "s",
+		"SERVER_PORT":        "http",
+		"PROCESSING_WORKERS": "four",
+		"MFA_CHALLENGE_TTL":  "5",
+	})})
+
+	problems := validationProblems(t, err)
+	assert.Len(t, problems, 3)
+	assert.Contains(t, err.Error(), `SERVER_PORT: "http" is not an integer`)
+}
+
+func TestValidateListsAllProblems(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+profile: staging
+server:
+  port: 70000
+  trusted_proxies: [10.0.0.1, proxy.internal]
+processing:
+  queue_driver: kafka
+  workers: 0
+storage:
+  driver: s3
+`)
+
+	_, err := Load(Options{Files: []string{file}})
+	problems := validationProblems(t, err)
+	message := strings.Join(problems, "\n")
+	assert.Contains(t, message, "profile: must be one of")
+	assert.Contains(t, message, "server.port: must be between 1 and 65535")
+	assert.Contains(t, message, `server.trusted_proxies: must be IP addresses or CIDR ranges, got "proxy.internal"`)
+	assert.Contains(t, message, "jwt.secret: is required")
+	assert.Contains(t, message, "processing.queue_driver: must be one of db, redis")
+	assert.Contains(t, message, "processing.workers: must be at least 1")
+	assert.Contains(t, message, "storage.s3.access_key: is required")
+	assert.Contains(t, message, "storage.s3.endpoint: is required")
+}
+
+func TestValidateFont(t *testing.T) {
+	cfg := Default()
+	cfg.JWT.Secret = "secret"
+	cfg.Font.Path = filepath.Join(t.TempDir(), "missing.ttf")
+	err := cfg.Validate()
+	assert.Contains(t, strings.Join(validationProblems(t, err), "\n"), "font.path: must be a readable file")
+
+	cfg.Font.Path = testFont
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestValidateBreachList(t *testing.T) {
+	cfg := Default()
+	cfg.JWT.Secret = "secret"
+	cfg.PasswordPolicy.BreachListPath = filepath.Join(t.TempDir(), "missing.txt")
+	err := cfg.Validate()
+	assert.Contains(t, strings.Join(validationProblems(t, err), "\n"), "password_policy.breach_list_path: must be a readable file")
+
+	cfg.PasswordPolicy.BreachListPath = writeFile(t, "breached.txt", "letmein\n")
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestValidateProduction(t *testing.T) {
+	file := writeFile(t, "production.yaml", "profile: production\njwt:\n  secret: your_jwt_secret\n")
+
+	_, err := Load(Options{Files: []string{file}})
+	message := strings.Join(validationProblems(t, err), "\n")
+	assert.Contains(t, message, "jwt.secret: must be at least 32 characters in production")
+	assert.Contains(t, message, "jwt.secret: is an example value")
+	assert.Contains(t, message, "contract.signing_key: is required in production")
+	assert.Contains(t, message, "font.path: is required in production")
+
+	// The same file passes once the secrets come from the environment
+	cfg, err := Load(Options{Files: []string{file}, LookupEnv: mapEnv(map[string]string{
+		"JWT_SECRET":           strings.Repeat("x", 40),
+		"CONTRACT_SIGNING_KEY": "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+		"FONT_PATH":            testFont,
+	})})
+	require.NoError(t, err)
+	assert.Equal(t, ProfileProduction, cfg.Profile)
+
+	// The development profile does not apply the production rules
+	_, err = Load(Options{Files: []string{file}, Profile: ProfileDevelopment})
+	assert.NoError(t, err)
+}
+
+func TestValidateVector(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+jwt:
+  secret: test-secret
+vector:
+  enabled: true
+  embedder: http
+  index: faiss
+  chunk_size: 50
+  chunk_overlap: 50
+`)
+
+	_, err := Load(Options{Files: []string{file}, LookupEnv: mapEnv(map[string]string{"VECTOR_DUPLICATE_THRESHOLD": "1.5"})})
+	message := strings.Join(validationProblems(t, err), "\n")
+	assert.Contains(t, message, "vector.base_url: must be an absolute URL")
+	assert.Contains(t, message, "vector.index: must be one of auto, pgvector, hnsw")
+	assert.Contains(t, message, "vector.chunk_overlap: must be between 0 and vector.chunk_size")
+	assert.Contains(t, message, "vector.duplicate_threshold: must be above 0 and at most 1, got 1.5")
+
+	cfg, err := Load(Options{Files: []string{file}, LookupEnv: mapEnv(map[string]string{
+		"VECTOR_EMBEDDER":      "hash",
+		"VECTOR_INDEX":         "hnsw",
+		"VECTOR_CHUNK_OVERLAP": "10",
+	})})
+	require.NoError(t, err)
+	assert.Equal(t, 256, cfg.Vector.Dimensions)
+	assert.Equal(t, 0.95, cfg.Vector.DuplicateThreshold)
+}
+
+func TestLoadSSOProviders(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+jwt:
+  secret: s
+sso:
+  providers:
+    - name: corp
+      issuer_url: https://idp.example.com
+      client_id: office
+      redirect_url: https://office.example.com/auth/sso/corp/callback
+    - name: directory
+      type: ldap
+      url: ldap://ldap.example.com
+      base_dn: dc=example,dc=com
+`)
+
+	cfg, err := Load(Options{Files: []string{file}, LookupEnv: mapEnv(map[string]string{
+		"SSO_CORP_CLIENT_SECRET":    "secret",
+		"SSO_DIRECTORY_GROUP_ROLES": "cn=admins,dc=example,dc=com:admin;staff:user",
+	})})
+	require.NoError(t, err)
+	require.Len(t, cfg.SSO.Providers, 2)
+
+	corp := cfg.SSO.Provider("corp")
+	require.NotNil(t, corp)
+	assert.Equal(t, SSOProviderOIDC, corp.Type)
+	assert.Equal(t, "corp", corp.DisplayName)
+	assert.Equal(t, []string{"openid", "profile", "email"}, corp.Scopes)
+	assert.Equal(t, "secret", corp.ClientSecret)
+
+	directory := cfg.SSO.Provider("directory")
+	require.NotNil(t, directory)
+	assert.Equal(t, "(uid=%s)", directory.UserFilter)
+	assert.Equal(t, []SSOGroupRole{
+		{Group: "cn=admins,dc=example,dc=com", Role: "admin"},
+		{Group: "staff", Role: "user"},
+	}, directory.GroupRoles)
+
+	// SSO_PROVIDERS selects the providers, keeping the file settings
+	cfg, err = Load(Options{Files: []string{file}, LookupEnv: mapEnv(map[string]string{
+		"SSO_PROVIDERS": "directory",
+	})})
+	require.NoError(t, err)
+	require.Len(t, cfg.SSO.Providers, 1)
+	assert.Equal(t, "dc=example,dc=com", cfg.SSO.Providers[0].BaseDN)
+}
+
+func TestProfileFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "production.yaml"), nil, 0600))
+
+	assert.Equal(t, []string{filepath.Join(dir, "production.yaml")}, ProfileFiles(dir, ProfileProduction))
+	assert.Empty(t, ProfileFiles(dir, ProfileDevelopment))
+}
+
+func TestRepositoryConfigFiles(t *testing.T) {
+	dir := filepath.Join("..", "..", "config")
+
+	_, err := Load(Options{Files: ProfileFiles(dir, ProfileDevelopment)})
+	assert.NoError(t, err)
+
+	cfg, err := Load(Options{Files: ProfileFiles(dir, ProfileProduction), LookupEnv: mapEnv(map[string]string{
+		"JWT_SECRET":           strings.Repeat("x", 40),
+		"CONTRACT_SIGNING_KEY": "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+		// The font of the image is not installed where tests run
+		"FONT_PATH": testFont,
+	})})
+	require.NoError(t, err)
+	assert.Equal(t, "postgres", cfg.Database.Host)
+}
@@ -0,0 +1,11 @@
+package config
+
+// ContractConfig holds the configuration of contract signing
+type ContractConfig struct {
+	SigningKey string `yaml:"signing_key"` // base64 Ed25519 seed that signs the signatures of contracts
+}
+
+// loadEnv applies the CONTRACT_* environment variables
+func (c *ContractConfig) loadEnv(env *envLoader) {
+	env.string("CONTRACT_SIGNING_KEY", &c.SigningKey)
+}
@@ -3,7 +3,6 @@ package config
 import (
 	"fmt"
 	"log"
-	"os"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -11,30 +10,47 @@ import (
 
 // DatabaseConfig holds the database configuration
 type DatabaseConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
-	SSLMode  string
+	URL      string `yaml:"url"` // connection URL, used instead of the other fields when set
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	DBName   string `yaml:"name"`
+	SSLMode  string `yaml:"ssl_mode"`
 }
 
-// GetDatabaseConfig returns the database configuration from environment variables
-func GetDatabaseConfig() *DatabaseConfig {
-	return &DatabaseConfig{
-		Host:     getEnv("DB_HOST", "localhost"),
-		Port:     getEnv("DB_PORT", "5432"),
-		User:     getEnv("DB_USER", "postgres"),
-		Password: getEnv("DB_PASSWORD", "postgres"),
-		DBName:   getEnv("DB_NAME", "cdk_office"),
-		SSLMode:  getEnv("DB_SSL_MODE", "disable"),
+// defaultDatabaseConfig returns the default database configuration
+func defaultDatabaseConfig() DatabaseConfig {
+	return DatabaseConfig{
+		Host:     "localhost",
+		Port:     "5432",
+		User:     "postgres",
+		Password: "postgres",
+		DBName:   "cdk_office",
+		SSLMode:  "disable",
 	}
 }
 
+// loadEnv applies the DATABASE_URL and DB_* environment variables
+func (c *DatabaseConfig) loadEnv(env *envLoader) {
+	env.string("DATABASE_URL", &c.URL)
+	env.string("DB_HOST", &c.Host)
+	env.string("DB_PORT", &c.Port)
+	env.string("DB_USER", &c.User)
+	env.string("DB_PASSWORD", &c.Password)
+	env.string("DB_NAME", &c.DBName)
+	env.string("DB_SSL_MODE", &c.SSLMode)
+}
+
+// GetDatabaseConfig returns the database configuration
+func GetDatabaseConfig() *DatabaseConfig {
+	return &Get().Database
+}
+
 // InitDatabase initializes the database connection with connection pooling
-func InitDatabase() *gorm.DB {
-	// Check if DATABASE_URL environment variable is set
-	databaseURL := os.Getenv("DATABASE_URL")
+func InitDatabase(config *DatabaseConfig) *gorm.DB {
+	// Prefer the connection URL when set
+	databaseURL := config.URL
 	if databaseURL != "" {
 		// Use DATABASE_URL for connection
 		db, err := gorm.Open(postgres.Open(databaseURL), &gorm.Config{})
@@ -56,9 +72,6 @@ func InitDatabase() *gorm.DB {
 		return db
 	}
 
-	// Fallback to individual environment variables
-	config := GetDatabaseConfig()
-
 	// Create connection string
 	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s TimeZone=Asia/Shanghai",
 		config.Host, config.User, config.Password, config.DBName, config.Port, config.SSLMode)
@@ -82,12 +95,3 @@ func InitDatabase() *gorm.DB {
 
 	return db
 }
-
-// getEnv returns the value of the environment variable or a default value
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-	return value
-}
\ No newline at end of file
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envLoader applies environment variables to configuration fields, collecting
+// the values that do not parse. String variables can instead name a file
+// holding the value in <KEY>_FILE, for secrets mounted into containers.
+type envLoader struct {
+	lookup func(key string) (string, bool)
+	errs   []string
+}
+
+// get returns the value of a variable, or false when unset or empty
+func (l *envLoader) get(key string) (string, bool) {
+	value, ok := l.lookup(key)
+	return value, ok && value != ""
+}
+
+// string applies a string variable, or the file named by <KEY>_FILE
+func (l *envLoader) string(key string, target *string) {
+	if path, ok := l.get(key + "_FILE"); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			l.errs = append(l.errs, fmt.Sprintf("%s_FILE: %v", key, err))
+			return
+		}
+		*target = strings.TrimRight(string(data), "\r\n")
+		return
+	}
+	if value, ok := l.get(key); ok {
+		*target = value
+	}
+}
+
+// int applies an integer variable
+func (l *envLoader) int(key string, target *int) {
+	value, ok := l.get(key)
+	if !ok {
+		return
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Sprintf("%s: %q is not an integer", key, value))
+		return
+	}
+	*target = parsed
+}
+
+// float applies a floating point variable
+func (l *envLoader) float(key string, target *float64) {
+	value, ok := l.get(key)
+	if !ok {
+		return
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Sprintf("%s: %q is not a number", key, value))
+		return
+	}
+	*target = parsed
+}
+
+// bool applies a boolean variable
+func (l *envLoader) bool(key string, target *bool) {
+	value, ok := l.get(key)
+	if !ok {
+		return
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Sprintf("%s: %q is not a boolean", key, value))
+		return
+	}
+	*target = parsed
+}
+
+// duration applies a duration variable, such as 30s or 1h
+func (l *envLoader) duration(key string, target *time.Duration) {
+	value, ok := l.get(key)
+	if !ok {
+		return
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Sprintf("%s: %q is not a duration", key, value))
+		return
+	}
+	*target = parsed
+}
+
+// list applies a comma or semicolon separated list variable
+func (l *envLoader) list(key string, target *[]string) {
+	if value, ok := l.get(key); ok {
+		*target = splitList(value)
+	}
+}
+
+// splitList splits a comma or semicolon separated list, dropping empty items.
+// Semicolons allow items containing commas, such as DNs.
+func splitList(value string) []string {
+	separator := ","
+	if strings.Contains(value, ";") {
+		separator = ";"
+	}
+	var items []string
+	for _, item := range strings.Split(value, separator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
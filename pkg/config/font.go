@@ -0,0 +1,13 @@
+package config
+
+// FontConfig holds the fonts of generated PDFs and images
+type FontConfig struct {
+	// Path is a TrueType font (.ttf) drawing the characters outside Latin-1,
+	// such as Chinese; without it they are drawn as '?'
+	Path string `yaml:"path"`
+}
+
+// loadEnv applies the FONT_* environment variables
+func (c *FontConfig) loadEnv(env *envLoader) {
+	env.string("FONT_PATH", &c.Path)
+}
@@ -0,0 +1,29 @@
+package config
+
+import "time"
+
+// MFAConfig holds the configuration of two-factor authentication
+type MFAConfig struct {
+	Issuer          string        `yaml:"issuer"`        // name authenticator apps show next to the account
+	ChallengeTTL    time.Duration `yaml:"challenge_ttl"` // lifetime of the token between the password and the second factor
+	MaxAttempts     int           `yaml:"max_attempts"`  // failed codes before the second factor is locked
+	LockoutDuration time.Duration `yaml:"lockout_duration"`
+}
+
+// defaultMFAConfig returns the default two-factor authentication configuration
+func defaultMFAConfig() MFAConfig {
+	return MFAConfig{
+		Issuer:          "CDK Office",
+		ChallengeTTL:    5 * time.Minute,
+		MaxAttempts:     5,
+		LockoutDuration: 15 * time.Minute,
+	}
+}
+
+// loadEnv applies the MFA_* environment variables
+func (c *MFAConfig) loadEnv(env *envLoader) {
+	env.string("MFA_ISSUER", &c.Issuer)
+	env.duration("MFA_CHALLENGE_TTL", &c.ChallengeTTL)
+	env.int("MFA_MAX_ATTEMPTS", &c.MaxAttempts)
+	env.duration("MFA_LOCKOUT_DURATION", &c.LockoutDuration)
+}
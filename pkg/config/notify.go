@@ -0,0 +1,31 @@
+package config
+
+// NotifyConfig holds the configuration of the mail and SMS senders
+type NotifyConfig struct {
+	SMTPHost        string `yaml:"smtp_host"` // empty disables mail
+	SMTPPort        int    `yaml:"smtp_port"`
+	SMTPUsername    string `yaml:"smtp_username"`
+	SMTPPassword    string `yaml:"smtp_password"`
+	SMTPFrom        string `yaml:"smtp_from"`
+	SMSWebhookURL   string `yaml:"sms_webhook_url"`   // SMS gateway messages are posted to; empty disables SMS
+	SMSWebhookToken string `yaml:"sms_webhook_token"` // bearer token of the SMS gateway
+}
+
+// defaultNotifyConfig returns the default sender configuration
+func defaultNotifyConfig() NotifyConfig {
+	return NotifyConfig{
+		SMTPPort: 587,
+		SMTPFrom: "no-reply@cdk-office.local",
+	}
+}
+
+// loadEnv applies the SMTP_* and SMS_* environment variables
+func (c *NotifyConfig) loadEnv(env *envLoader) {
+	env.string("SMTP_HOST", &c.SMTPHost)
+	env.int("SMTP_PORT", &c.SMTPPort)
+	env.string("SMTP_USERNAME", &c.SMTPUsername)
+	env.string("SMTP_PASSWORD", &c.SMTPPassword)
+	env.string("SMTP_FROM", &c.SMTPFrom)
+	env.string("SMS_WEBHOOK_URL", &c.SMSWebhookURL)
+	env.string("SMS_WEBHOOK_TOKEN", &c.SMSWebhookToken)
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"time"
+)
+
+// ProcessingConfig holds the configuration of the document processing job queue
+type ProcessingConfig struct {
+	QueueDriver  string        `yaml:"queue_driver"` // db or redis
+	Workers      int           `yaml:"workers"`
+	MaxAttempts  int           `yaml:"max_attempts"`
+	BaseBackoff  time.Duration `yaml:"base_backoff"`
+	MaxBackoff   time.Duration `yaml:"max_backoff"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+	LockTimeout  time.Duration `yaml:"lock_timeout"`
+}
+
+// DifyConfig holds the Dify API configuration
+type DifyConfig struct {
+	BaseURL           string        `yaml:"base_url"`
+	APIKey            string        `yaml:"api_key"`
+	DatasetAPIKey     string        `yaml:"dataset_api_key"`     // knowledge base API key; documents are not synced to Dify without it
+	IndexingTechnique string        `yaml:"indexing_technique"`  // high_quality or economy
+	Timeout           time.Duration `yaml:"timeout"`             // blocking requests and the start of streamed answers
+	StreamIdleTimeout time.Duration `yaml:"stream_idle_timeout"` // streamed answers silent for longer are cut
+}
+
+// defaultProcessingConfig returns the default processing queue configuration
+func defaultProcessingConfig() ProcessingConfig {
+	return ProcessingConfig{
+		QueueDriver:  "db",
+		Workers:      4,
+		MaxAttempts:  5,
+		BaseBackoff:  30 * time.Second,
+		MaxBackoff:   30 * time.Minute,
+		PollInterval: 2 * time.Second,
+		LockTimeout:  10 * time.Minute,
+	}
+}
+
+// loadEnv applies the PROCESSING_* environment variables
+func (c *ProcessingConfig) loadEnv(env *envLoader) {
+	env.string("PROCESSING_QUEUE_DRIVER", &c.QueueDriver)
+	env.int("PROCESSING_WORKERS", &c.Workers)
+	env.int("PROCESSING_MAX_ATTEMPTS", &c.MaxAttempts)
+	env.duration("PROCESSING_BASE_BACKOFF", &c.BaseBackoff)
+	env.duration("PROCESSING_MAX_BACKOFF", &c.MaxBackoff)
+	env.duration("PROCESSING_POLL_INTERVAL", &c.PollInterval)
+	env.duration("PROCESSING_LOCK_TIMEOUT", &c.LockTimeout)
+}
+
+// defaultDifyConfig returns the default Dify API configuration
+func defaultDifyConfig() DifyConfig {
+	return DifyConfig{
+		BaseURL:           "http://localhost:8000",
+		IndexingTechnique: "high_quality",
+		Timeout:           2 * time.Minute,
+		StreamIdleTimeout: time.Minute,
+	}
+}
+
+// loadEnv applies the DIFY_* environment variables
+func (c *DifyConfig) loadEnv(env *envLoader) {
+	env.string("DIFY_BASE_URL", &c.BaseURL)
+	env.string("DIFY_API_KEY", &c.APIKey)
+	env.string("DIFY_DATASET_API_KEY", &c.DatasetAPIKey)
+	env.string("DIFY_INDEXING_TECHNIQUE", &c.IndexingTechnique)
+	env.duration("DIFY_TIMEOUT", &c.Timeout)
+	env.duration("DIFY_STREAM_IDLE_TIMEOUT", &c.StreamIdleTimeout)
+}
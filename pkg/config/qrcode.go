@@ -0,0 +1,46 @@
+package config
+
+import "time"
+
+// BatchQRCodeConfig holds the configuration of the batch QR code generation workers
+type BatchQRCodeConfig struct {
+	Workers      int           `yaml:"workers"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+	LockTimeout  time.Duration `yaml:"lock_timeout"`
+}
+
+// defaultBatchQRCodeConfig returns the default batch QR code worker configuration
+func defaultBatchQRCodeConfig() BatchQRCodeConfig {
+	return BatchQRCodeConfig{
+		Workers:      2,
+		PollInterval: 2 * time.Second,
+		LockTimeout:  5 * time.Minute,
+	}
+}
+
+// loadEnv applies the BATCH_QRCODE_* environment variables
+func (c *BatchQRCodeConfig) loadEnv(env *envLoader) {
+	env.int("BATCH_QRCODE_WORKERS", &c.Workers)
+	env.duration("BATCH_QRCODE_POLL_INTERVAL", &c.PollInterval)
+	env.duration("BATCH_QRCODE_LOCK_TIMEOUT", &c.LockTimeout)
+}
+
+// QRCodeConfig holds the configuration of QR code image output
+type QRCodeConfig struct {
+	ImageDir         string `yaml:"image_dir"`
+	ShortLinkBaseURL string `yaml:"short_link_base_url"` // public address that serves the /q/:code redirects
+}
+
+// defaultQRCodeConfig returns the default QR code image configuration
+func defaultQRCodeConfig() QRCodeConfig {
+	return QRCodeConfig{
+		ImageDir:         "/tmp/qrcodes",
+		ShortLinkBaseURL: "http://localhost:8080",
+	}
+}
+
+// loadEnv applies the QRCODE_* environment variables
+func (c *QRCodeConfig) loadEnv(env *envLoader) {
+	env.string("QRCODE_IMAGE_DIR", &c.ImageDir)
+	env.string("QRCODE_SHORT_LINK_BASE_URL", &c.ShortLinkBaseURL)
+}
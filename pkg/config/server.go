@@ -0,0 +1,57 @@
+package config
+
+import (
+	"net"
+	"strconv"
+)
+
+// ServerConfig holds the configuration of the HTTP server
+type ServerConfig struct {
+	Host string `yaml:"host"`
+	Port int    `yaml:"port"`
+	// TrustedProxies are the addresses and CIDR ranges of the reverse proxies
+	// whose X-Forwarded-For header names the client. Without any, the client
+	// is the address the request came from.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// defaultServerConfig returns the default HTTP server configuration
+func defaultServerConfig() ServerConfig {
+	return ServerConfig{
+		Host: "0.0.0.0",
+		Port: 8080,
+	}
+}
+
+// loadEnv applies the SERVER_* environment variables
+func (c *ServerConfig) loadEnv(env *envLoader) {
+	env.string("SERVER_HOST", &c.Host)
+	env.int("SERVER_PORT", &c.Port)
+	env.list("SERVER_TRUSTED_PROXIES", &c.TrustedProxies)
+}
+
+// Address returns the address the server listens on
+func (c *ServerConfig) Address() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// RedisConfig holds the Redis connection configuration
+type RedisConfig struct {
+	Addr     string `yaml:"addr"`
+	Password string `yaml:"password"`
+	DB       int    `yaml:"db"`
+}
+
+// defaultRedisConfig returns the default Redis connection configuration
+func defaultRedisConfig() RedisConfig {
+	return RedisConfig{
+		Addr: "localhost:6379",
+	}
+}
+
+// loadEnv applies the REDIS_* environment variables
+func (c *RedisConfig) loadEnv(env *envLoader) {
+	env.string("REDIS_ADDR", &c.Addr)
+	env.string("REDIS_PASSWORD", &c.Password)
+	env.int("REDIS_DB", &c.DB)
+}
@@ -0,0 +1,195 @@
+package config
+
+import (
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SSO provider types
+const (
+	SSOProviderOIDC = "oidc"
+	SSOProviderLDAP = "ldap"
+)
+
+// SSOConfig holds the configuration of single sign-on
+type SSOConfig struct {
+	StateTTL  time.Duration        `yaml:"state_ttl"` // lifetime of an authorization started at an OIDC provider
+	Providers []*SSOProviderConfig `yaml:"providers"`
+}
+
+// SSOProviderConfig holds the configuration of one identity provider. Its
+// variables are prefixed with SSO_<NAME>_, NAME being the upper-cased name.
+type SSOProviderConfig struct {
+	Name        string `yaml:"name"` // used in URLs and to link identities, must not change once in use
+	Type        string `yaml:"type"` // oidc or ldap
+	DisplayName string `yaml:"display_name"`
+
+	// OIDC
+	IssuerURL    string   `yaml:"issuer_url"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url"`
+	Scopes       []string `yaml:"scopes"`
+	GroupsClaim  string   `yaml:"groups_claim"` // ID token claim holding the groups of the user
+
+	// LDAP
+	URL          string        `yaml:"url"`     // ldap:// or ldaps://
+	BindDN       string        `yaml:"bind_dn"` // service account searching for users; empty binds anonymously
+	BindPassword string        `yaml:"bind_password"`
+	BaseDN       string        `yaml:"base_dn"`
+	UserFilter   string        `yaml:"user_filter"` // %s is replaced with the escaped username
+	UsernameAttr string        `yaml:"username_attr"`
+	EmailAttr    string        `yaml:"email_attr"`
+	NameAttr     string        `yaml:"name_attr"`
+	PhoneAttr    string        `yaml:"phone_attr"`
+	GroupAttr    string        `yaml:"group_attr"`
+	SubjectAttr  string        `yaml:"subject_attr"` // stable identifier of the entry, the DN when missing
+	Timeout      time.Duration `yaml:"timeout"`
+
+	// Provisioning
+	AutoProvision bool           `yaml:"auto_provision"` // create unknown users on their first login
+	LinkByEmail   bool           `yaml:"link_by_email"`  // link to the existing user with the verified email of the identity
+	DefaultRole   string         `yaml:"default_role"`   // role of users no group maps a role to
+	GroupRoles    []SSOGroupRole `yaml:"group_roles"`
+
+	// SkipMFA trusts a redirect provider to have asked for a second factor, so
+	// its users are not asked for theirs. Users are asked by default.
+	SkipMFA bool `yaml:"skip_mfa"`
+}
+
+// SSOGroupRole maps a group of the provider to a role, earlier mappings
+// taking priority for the primary role of the user
+type SSOGroupRole struct {
+	Group string `yaml:"group"` // group name, or DN matched in full or by its first RDN value
+	Role  string `yaml:"role"`
+}
+
+// Provider returns the configuration of a provider by name, or nil
+func (c *SSOConfig) Provider(name string) *SSOProviderConfig {
+	for _, provider := range c.Providers {
+		if provider.Name == name {
+			return provider
+		}
+	}
+	return nil
+}
+
+// defaultSSOConfig returns the default single sign-on configuration, without providers
+func defaultSSOConfig() SSOConfig {
+	return SSOConfig{
+		StateTTL: 10 * time.Minute,
+	}
+}
+
+// defaultSSOProviderConfig returns the defaults of a provider
+func defaultSSOProviderConfig(name string) *SSOProviderConfig {
+	return &SSOProviderConfig{
+		Name:          name,
+		Type:          SSOProviderOIDC,
+		DisplayName:   name,
+		Scopes:        []string{"openid", "profile", "email"},
+		GroupsClaim:   "groups",
+		UserFilter:    "(uid=%s)",
+		UsernameAttr:  "uid",
+		EmailAttr:     "mail",
+		NameAttr:      "cn",
+		PhoneAttr:     "telephoneNumber",
+		GroupAttr:     "memberOf",
+		SubjectAttr:   "entryUUID",
+		Timeout:       10 * time.Second,
+		AutoProvision: true,
+		LinkByEmail:   true,
+		DefaultRole:   "user",
+	}
+}
+
+// UnmarshalYAML decodes a provider over its defaults
+func (c *SSOProviderConfig) UnmarshalYAML(node *yaml.Node) error {
+	type plain SSOProviderConfig
+	provider := (*plain)(defaultSSOProviderConfig(""))
+	if err := node.Decode(provider); err != nil {
+		return err
+	}
+	if provider.DisplayName == "" {
+		provider.DisplayName = provider.Name
+	}
+	*c = SSOProviderConfig(*provider)
+	return nil
+}
+
+// loadEnv applies the SSO_* environment variables. SSO_PROVIDERS lists the
+// names of the providers to enable, replacing those of the config files;
+// providers keep the settings of the config files under the same name.
+func (c *SSOConfig) loadEnv(env *envLoader) {
+	env.duration("SSO_STATE_TTL", &c.StateTTL)
+
+	var names []string
+	env.list("SSO_PROVIDERS", &names)
+	if names != nil {
+		providers := make([]*SSOProviderConfig, 0, len(names))
+		for _, name := range names {
+			provider := c.Provider(name)
+			if provider == nil {
+				provider = defaultSSOProviderConfig(name)
+			}
+			providers = append(providers, provider)
+		}
+		c.Providers = providers
+	}
+
+	for _, provider := range c.Providers {
+		provider.loadEnv(env)
+	}
+}
+
+// loadEnv applies the SSO_<NAME>_* environment variables
+func (c *SSOProviderConfig) loadEnv(env *envLoader) {
+	prefix := "SSO_" + strings.ToUpper(strings.ReplaceAll(c.Name, "-", "_")) + "_"
+	env.string(prefix+"TYPE", &c.Type)
+	env.string(prefix+"DISPLAY_NAME", &c.DisplayName)
+
+	env.string(prefix+"ISSUER_URL", &c.IssuerURL)
+	env.string(prefix+"CLIENT_ID", &c.ClientID)
+	env.string(prefix+"CLIENT_SECRET", &c.ClientSecret)
+	env.string(prefix+"REDIRECT_URL", &c.RedirectURL)
+	env.list(prefix+"SCOPES", &c.Scopes)
+	env.string(prefix+"GROUPS_CLAIM", &c.GroupsClaim)
+
+	env.string(prefix+"URL", &c.URL)
+	env.string(prefix+"BIND_DN", &c.BindDN)
+	env.string(prefix+"BIND_PASSWORD", &c.BindPassword)
+	env.string(prefix+"BASE_DN", &c.BaseDN)
+	env.string(prefix+"USER_FILTER", &c.UserFilter)
+	env.string(prefix+"USERNAME_ATTR", &c.UsernameAttr)
+	env.string(prefix+"EMAIL_ATTR", &c.EmailAttr)
+	env.string(prefix+"NAME_ATTR", &c.NameAttr)
+	env.string(prefix+"PHONE_ATTR", &c.PhoneAttr)
+	env.string(prefix+"GROUP_ATTR", &c.GroupAttr)
+	env.string(prefix+"SUBJECT_ATTR", &c.SubjectAttr)
+	env.duration(prefix+"TIMEOUT", &c.Timeout)
+
+	env.bool(prefix+"AUTO_PROVISION", &c.AutoProvision)
+	env.bool(prefix+"LINK_BY_EMAIL", &c.LinkByEmail)
+	env.string(prefix+"DEFAULT_ROLE", &c.DefaultRole)
+
+	// GROUP_ROLES is a list of group:role pairs, e.g. "cn=admins,ou=groups,dc=example,dc=com:admin"
+	var pairs []string
+	env.list(prefix+"GROUP_ROLES", &pairs)
+	if pairs == nil {
+		return
+	}
+	c.GroupRoles = nil
+	for _, pair := range pairs {
+		separator := strings.LastIndex(pair, ":")
+		if separator <= 0 || separator == len(pair)-1 {
+			env.errs = append(env.errs, prefix+"GROUP_ROLES: "+pair+" is not a group:role pair")
+			continue
+		}
+		c.GroupRoles = append(c.GroupRoles, SSOGroupRole{
+			Group: strings.TrimSpace(pair[:separator]),
+			Role:  strings.TrimSpace(pair[separator+1:]),
+		})
+	}
+}
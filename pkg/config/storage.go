@@ -0,0 +1,43 @@
+package config
+
+// StorageConfig holds the object storage configuration
+type StorageConfig struct {
+	Driver    string   `yaml:"driver"` // local or s3
+	LocalPath string   `yaml:"local_path"`
+	S3        S3Config `yaml:"s3"`
+}
+
+// S3Config holds the configuration of an S3-compatible object store
+type S3Config struct {
+	Endpoint     string `yaml:"endpoint"`
+	Region       string `yaml:"region"`
+	Bucket       string `yaml:"bucket"`
+	AccessKey    string `yaml:"access_key"`
+	SecretKey    string `yaml:"secret_key"`
+	UsePathStyle bool   `yaml:"use_path_style"`
+}
+
+// defaultStorageConfig returns the default storage configuration
+func defaultStorageConfig() StorageConfig {
+	return StorageConfig{
+		Driver:    "local",
+		LocalPath: "/var/cdk-office/storage",
+		S3: S3Config{
+			Region:       "us-east-1",
+			Bucket:       "cdk-office",
+			UsePathStyle: true,
+		},
+	}
+}
+
+// loadEnv applies the STORAGE_* and S3_* environment variables
+func (c *StorageConfig) loadEnv(env *envLoader) {
+	env.string("STORAGE_DRIVER", &c.Driver)
+	env.string("STORAGE_LOCAL_PATH", &c.LocalPath)
+	env.string("S3_ENDPOINT", &c.S3.Endpoint)
+	env.string("S3_REGION", &c.S3.Region)
+	env.string("S3_BUCKET", &c.S3.Bucket)
+	env.string("S3_ACCESS_KEY", &c.S3.AccessKey)
+	env.string("S3_SECRET_KEY", &c.S3.SecretKey)
+	env.bool("S3_USE_PATH_STYLE", &c.S3.UsePathStyle)
+}
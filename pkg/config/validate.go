@@ -0,0 +1,310 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// minProductionSecretLength is the shortest JWT secret accepted in production
+const minProductionSecretLength = 32
+
+// placeholderSecrets are example values that must not be used in production
+var placeholderSecrets = []string{"your_jwt_secret", "cdk-office-secret-key", "changeme", "secret"}
+
+// ValidationError lists the problems of a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// validator collects problems, keyed by the YAML path of the field
+type validator struct {
+	problems []string
+}
+
+func (v *validator) addf(path, format string, args ...interface{}) {
+	v.problems = append(v.problems, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validator) required(path, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.addf(path, "is required")
+	}
+}
+
+func (v *validator) positive(path string, value int) {
+	if value < 1 {
+		v.addf(path, "must be at least 1, got %d", value)
+	}
+}
+
+func (v *validator) positiveDuration(path string, value time.Duration) {
+	if value <= 0 {
+		v.addf(path, "must be a positive duration, got %s", value)
+	}
+}
+
+func (v *validator) oneOf(path, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.addf(path, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+}
+
+func (v *validator) file(path, value string) {
+	info, err := os.Stat(value)
+	if err != nil {
+		v.addf(path, "must be a readable file: %v", err)
+	} else if info.IsDir() {
+		v.addf(path, "must be a file, got directory %q", value)
+	}
+}
+
+func (v *validator) absoluteURL(path, value string, schemes ...string) {
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		v.addf(path, "must be an absolute URL, got %q", value)
+		return
+	}
+	v.oneOf(path+" scheme", u.Scheme, schemes...)
+}
+
+// Validate checks the configuration, reporting all problems at once
+func (c *Config) Validate() error {
+	v := &validator{}
+	v.oneOf("profile", c.Profile, ProfileDevelopment, ProfileTest, ProfileProduction)
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		v.addf("server.port", "must be between 1 and 65535, got %d", c.Server.Port)
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				v.addf("server.trusted_proxies", "must be IP addresses or CIDR ranges, got %q", proxy)
+			}
+		}
+	}
+
+	v.required("jwt.secret", c.JWT.Secret)
+	v.positiveDuration("jwt.access_token_ttl", c.JWT.AccessTokenTTL)
+	v.positiveDuration("jwt.refresh_token_ttl", c.JWT.RefreshTokenTTL)
+	if c.JWT.RefreshTokenTTL < c.JWT.AccessTokenTTL {
+		v.addf("jwt.refresh_token_ttl", "must not be shorter than jwt.access_token_ttl")
+	}
+
+	if c.Database.URL == "" {
+		v.required("database.host", c.Database.Host)
+		v.required("database.name", c.Database.DBName)
+		v.required("database.user", c.Database.User)
+		if port, err := strconv.Atoi(c.Database.Port); err != nil || port < 1 || port > 65535 {
+			v.addf("database.port", "must be a port number, got %q", c.Database.Port)
+		}
+	} else {
+		v.absoluteURL("database.url", c.Database.URL, "postgres", "postgresql")
+	}
+
+	v.required("redis.addr", c.Redis.Addr)
+	if c.Redis.DB < 0 {
+		v.addf("redis.db", "must not be negative, got %d", c.Redis.DB)
+	}
+
+	v.oneOf("storage.driver", c.Storage.Driver, "local", "s3")
+	switch c.Storage.Driver {
+	case "local":
+		v.required("storage.local_path", c.Storage.LocalPath)
+	case "s3":
+		v.required("storage.s3.endpoint", c.Storage.S3.Endpoint)
+		v.required("storage.s3.bucket", c.Storage.S3.Bucket)
+		v.required("storage.s3.access_key", c.Storage.S3.AccessKey)
+		v.required("storage.s3.secret_key", c.Storage.S3.SecretKey)
+		if c.Storage.S3.Endpoint != "" {
+			v.absoluteURL("storage.s3.endpoint", c.Storage.S3.Endpoint, "http", "https")
+		}
+	}
+
+	v.absoluteURL("dify.base_url", c.Dify.BaseURL, "http", "https")
+	v.oneOf("dify.indexing_technique", c.Dify.IndexingTechnique, "high_quality", "economy")
+	v.positiveDuration("dify.timeout", c.Dify.Timeout)
+	v.positiveDuration("dify.stream_idle_timeout", c.Dify.StreamIdleTimeout)
+
+	v.oneOf("processing.queue_driver", c.Processing.QueueDriver, "db", "redis")
+	v.positive("processing.workers", c.Processing.Workers)
+	v.positive("processing.max_attempts", c.Processing.MaxAttempts)
+	v.positiveDuration("processing.base_backoff", c.Processing.BaseBackoff)
+	v.positiveDuration("processing.max_backoff", c.Processing.MaxBackoff)
+	v.positiveDuration("processing.poll_interval", c.Processing.PollInterval)
+	v.positiveDuration("processing.lock_timeout", c.Processing.LockTimeout)
+
+	v.positive("batch_qrcode.workers", c.BatchQRCode.Workers)
+	v.positiveDuration("batch_qrcode.poll_interval", c.BatchQRCode.PollInterval)
+	v.positiveDuration("batch_qrcode.lock_timeout", c.BatchQRCode.LockTimeout)
+	v.required("qrcode.image_dir", c.QRCode.ImageDir)
+	v.absoluteURL("qrcode.short_link_base_url", c.QRCode.ShortLinkBaseURL, "http", "https")
+
+	if c.Contract.SigningKey != "" {
+		if seed, err := base64.StdEncoding.DecodeString(c.Contract.SigningKey); err != nil || len(seed) != 32 {
+			v.addf("contract.signing_key", "must be a base64 encoded 32 byte Ed25519 seed")
+		}
+	}
+
+	if c.Font.Path != "" {
+		v.file("font.path", c.Font.Path)
+	}
+
+	v.required("mfa.issuer", c.MFA.Issuer)
+	v.positiveDuration("mfa.challenge_ttl", c.MFA.ChallengeTTL)
+	v.positive("mfa.max_attempts", c.MFA.MaxAttempts)
+	v.positiveDuration("mfa.lockout_duration", c.MFA.LockoutDuration)
+
+	v.positiveDuration("login_throttle.window", c.LoginThrottle.Window)
+	v.positive("login_throttle.max_account_failures", c.LoginThrottle.MaxAccountFailures)
+	v.positive("login_throttle.max_ip_failures", c.LoginThrottle.MaxIPFailures)
+	v.positiveDuration("login_throttle.lockout_base", c.LoginThrottle.LockoutBase)
+	if c.LoginThrottle.LockoutMax < c.LoginThrottle.LockoutBase {
+		v.addf("login_throttle.lockout_max", "must not be shorter than login_throttle.lockout_base")
+	}
+
+	v.positive("password_policy.min_length", c.PasswordPolicy.MinLength)
+	if c.PasswordPolicy.MinCharClasses < 0 || c.PasswordPolicy.MinCharClasses > 4 {
+		v.addf("password_policy.min_char_classes", "must be between 0 and 4, got %d", c.PasswordPolicy.MinCharClasses)
+	}
+	if c.PasswordPolicy.HistorySize < 0 {
+		v.addf("password_policy.history_size", "must not be negative, got %d", c.PasswordPolicy.HistorySize)
+	}
+	if c.PasswordPolicy.BreachListPath != "" {
+		v.file("password_policy.breach_list_path", c.PasswordPolicy.BreachListPath)
+	}
+	v.positiveDuration("password_reset.token_ttl", c.PasswordReset.TokenTTL)
+	v.absoluteURL("password_reset.reset_url", c.PasswordReset.ResetURL, "http", "https")
+	v.positive("password_reset.max_requests", c.PasswordReset.MaxRequests)
+
+	if c.Notify.SMTPHost != "" {
+		if c.Notify.SMTPPort < 1 || c.Notify.SMTPPort > 65535 {
+			v.addf("notify.smtp_port", "must be between 1 and 65535, got %d", c.Notify.SMTPPort)
+		}
+		v.required("notify.smtp_from", c.Notify.SMTPFrom)
+	}
+	if c.Notify.SMSWebhookURL != "" {
+		v.absoluteURL("notify.sms_webhook_url", c.Notify.SMSWebhookURL, "http", "https")
+	}
+
+	if (c.WeChat.AppID == "") != (c.WeChat.AppSecret == "") {
+		v.addf("wechat", "app_id and app_secret must be set together")
+	}
+	v.absoluteURL("wechat.base_url", c.WeChat.BaseURL, "http", "https")
+	v.positiveDuration("wechat.bind_token_ttl", c.WeChat.BindTokenTTL)
+
+	c.validateSSO(v)
+
+	if c.Vector.Enabled {
+		c.validateVector(v)
+	}
+
+	if c.Profile == ProfileProduction {
+		c.validateProduction(v)
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// validateVector checks the semantic search
+func (c *Config) validateVector(v *validator) {
+	v.oneOf("vector.embedder", c.Vector.Embedder, "hash", "http")
+	if c.Vector.Embedder == "http" {
+		v.absoluteURL("vector.base_url", c.Vector.BaseURL, "http", "https")
+		v.required("vector.model", c.Vector.Model)
+	}
+	v.positive("vector.dimensions", c.Vector.Dimensions)
+	v.positive("vector.batch_size", c.Vector.BatchSize)
+	v.oneOf("vector.index", c.Vector.Index, "auto", "pgvector", "hnsw")
+	v.positive("vector.chunk_size", c.Vector.ChunkSize)
+	if c.Vector.ChunkOverlap < 0 || c.Vector.ChunkOverlap >= c.Vector.ChunkSize {
+		v.addf("vector.chunk_overlap", "must be between 0 and vector.chunk_size, got %d", c.Vector.ChunkOverlap)
+	}
+	if c.Vector.DuplicateThreshold <= 0 || c.Vector.DuplicateThreshold > 1 {
+		v.addf("vector.duplicate_threshold", "must be above 0 and at most 1, got %g", c.Vector.DuplicateThreshold)
+	}
+}
+
+// validateSSO checks the identity providers
+func (c *Config) validateSSO(v *validator) {
+	v.positiveDuration("sso.state_ttl", c.SSO.StateTTL)
+
+	seen := make(map[string]bool)
+	for i, provider := range c.SSO.Providers {
+		path := fmt.Sprintf("sso.providers[%d]", i)
+		if provider.Name == "" {
+			v.addf(path+".name", "is required")
+		} else {
+			path = fmt.Sprintf("sso.providers[%s]", provider.Name)
+			if seen[provider.Name] {
+				v.addf(path+".name", "is used by more than one provider")
+			}
+			seen[provider.Name] = true
+			if len(provider.Name) > 50 || strings.ContainsAny(provider.Name, "/?#: ") {
+				v.addf(path+".name", "must be at most 50 characters without /?#: or spaces")
+			}
+		}
+
+		switch provider.Type {
+		case SSOProviderOIDC:
+			v.absoluteURL(path+".issuer_url", provider.IssuerURL, "http", "https")
+			v.required(path+".client_id", provider.ClientID)
+			v.absoluteURL(path+".redirect_url", provider.RedirectURL, "http", "https")
+		case SSOProviderLDAP:
+			v.absoluteURL(path+".url", provider.URL, "ldap", "ldaps")
+			v.required(path+".base_dn", provider.BaseDN)
+			if strings.Count(provider.UserFilter, "%s") != 1 {
+				v.addf(path+".user_filter", "must contain %%s exactly once, got %q", provider.UserFilter)
+			}
+		default:
+			v.oneOf(path+".type", provider.Type, SSOProviderOIDC, SSOProviderLDAP)
+		}
+		if provider.AutoProvision {
+			v.required(path+".default_role", provider.DefaultRole)
+		}
+		for j, mapping := range provider.GroupRoles {
+			if mapping.Group == "" || mapping.Role == "" {
+				v.addf(fmt.Sprintf("%s.group_roles[%d]", path, j), "group and role are required")
+			}
+		}
+	}
+}
+
+// validateProduction applies the stricter rules of the production profile
+func (c *Config) validateProduction(v *validator) {
+	if c.JWT.Secret != "" {
+		if len(c.JWT.Secret) < minProductionSecretLength {
+			v.addf("jwt.secret", "must be at least %d characters in production", minProductionSecretLength)
+		}
+		for _, placeholder := range placeholderSecrets {
+			if strings.EqualFold(c.JWT.Secret, placeholder) {
+				v.addf("jwt.secret", "is an example value, set a random secret")
+			}
+		}
+	}
+	if c.Contract.SigningKey == "" {
+		v.addf("contract.signing_key", "is required in production, contract signatures cannot be verified after a restart without it")
+	}
+	if c.Font.Path == "" {
+		v.addf("font.path", "is required in production, Chinese text in contracts and QR code captions is drawn as '?' without it")
+	}
+	if c.Storage.Driver == "s3" && strings.HasPrefix(c.Storage.S3.AccessKey, "your_") {
+		v.addf("storage.s3.access_key", "is an example value")
+	}
+}
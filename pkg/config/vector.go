@@ -0,0 +1,46 @@
+package config
+
+// VectorConfig holds the configuration of the local semantic search, which
+// embeds document chunks without Dify
+type VectorConfig struct {
+	Enabled            bool    `yaml:"enabled"`
+	Embedder           string  `yaml:"embedder"` // hash (offline, deterministic) or http
+	BaseURL            string  `yaml:"base_url"` // OpenAI compatible API of the http embedder
+	APIKey             string  `yaml:"api_key"`
+	Model              string  `yaml:"model"`
+	Dimensions         int     `yaml:"dimensions"`
+	BatchSize          int     `yaml:"batch_size"`          // texts per embedding request
+	Index              string  `yaml:"index"`               // auto, pgvector or hnsw
+	ChunkSize          int     `yaml:"chunk_size"`          // words per chunk
+	ChunkOverlap       int     `yaml:"chunk_overlap"`       // words repeated at the start of the next chunk
+	DuplicateThreshold float64 `yaml:"duplicate_threshold"` // similarity from which documents are near-duplicates
+}
+
+// defaultVectorConfig returns the default semantic search configuration
+func defaultVectorConfig() VectorConfig {
+	return VectorConfig{
+		Embedder:           "hash",
+		Model:              "text-embedding-3-small",
+		Dimensions:         256,
+		BatchSize:          32,
+		Index:              "auto",
+		ChunkSize:          200,
+		ChunkOverlap:       40,
+		DuplicateThreshold: 0.95,
+	}
+}
+
+// loadEnv applies the VECTOR_* environment variables
+func (c *VectorConfig) loadEnv(env *envLoader) {
+	env.bool("VECTOR_ENABLED", &c.Enabled)
+	env.string("VECTOR_EMBEDDER", &c.Embedder)
+	env.string("VECTOR_BASE_URL", &c.BaseURL)
+	env.string("VECTOR_API_KEY", &c.APIKey)
+	env.string("VECTOR_MODEL", &c.Model)
+	env.int("VECTOR_DIMENSIONS", &c.Dimensions)
+	env.int("VECTOR_BATCH_SIZE", &c.BatchSize)
+	env.string("VECTOR_INDEX", &c.Index)
+	env.int("VECTOR_CHUNK_SIZE", &c.ChunkSize)
+	env.int("VECTOR_CHUNK_OVERLAP", &c.ChunkOverlap)
+	env.float("VECTOR_DUPLICATE_THRESHOLD", &c.DuplicateThreshold)
+}
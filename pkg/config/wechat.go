@@ -0,0 +1,27 @@
+package config
+
+import "time"
+
+// WeChatConfig holds the configuration of the WeChat mini-program login
+type WeChatConfig struct {
+	AppID        string        `yaml:"app_id"`
+	AppSecret    string        `yaml:"app_secret"`
+	BaseURL      string        `yaml:"base_url"`       // WeChat API base URL, overridable to point tests at a fake
+	BindTokenTTL time.Duration `yaml:"bind_token_ttl"` // lifetime of the token for binding an unknown WeChat account
+}
+
+// defaultWeChatConfig returns the default WeChat mini-program configuration
+func defaultWeChatConfig() WeChatConfig {
+	return WeChatConfig{
+		BaseURL:      "https://api.weixin.qq.com",
+		BindTokenTTL: 10 * time.Minute,
+	}
+}
+
+// loadEnv applies the WECHAT_* environment variables
+func (c *WeChatConfig) loadEnv(env *envLoader) {
+	env.string("WECHAT_APP_ID", &c.AppID)
+	env.string("WECHAT_APP_SECRET", &c.AppSecret)
+	env.string("WECHAT_API_BASE_URL", &c.BaseURL)
+	env.duration("WECHAT_BIND_TOKEN_TTL", &c.BindTokenTTL)
+}
@@ -1,6 +1,8 @@
 package jwt
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"errors"
 	"time"
 
@@ -19,6 +21,16 @@ type Claims struct {
 	UserID   string `json:"user_id"`
 	Username string `json:"username"`
 	Role     string `json:"role"`
+	// SessionID is the session the token was issued for, empty for tokens issued
+	// outside of sessions
+	SessionID string `json:"sid,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// RefreshClaims represents the claims of a refresh token. The subject is the
+// user and the token ID tells the tokens of a session apart.
+type RefreshClaims struct {
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
@@ -40,10 +52,16 @@ func NewJWTManager(config *JWTConfig) *JWTManager {
 
 // GenerateAccessToken generates an access token for a user
 func (j *JWTManager) GenerateAccessToken(userID, username, role string) (string, error) {
+	return j.GenerateSessionAccessToken(userID, username, role, "")
+}
+
+// GenerateSessionAccessToken generates an access token for a user within a session
+func (j *JWTManager) GenerateSessionAccessToken(userID, username, role, sessionID string) (string, error) {
 	claims := &Claims{
-		UserID:   userID,
-		Username: username,
-		Role:     role,
+		UserID:    userID,
+		Username:  username,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.accessTokenExp)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -55,19 +73,52 @@ func (j *JWTManager) GenerateAccessToken(userID, username, role string) (string,
 	return token.SignedString(j.SecretKey)
 }
 
-// GenerateRefreshToken generates a refresh token for a user
-func (j *JWTManager) GenerateRefreshToken(userID string) (string, error) {
-	claims := &jwt.RegisteredClaims{
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.refreshTokenExp)),
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		NotBefore: jwt.NewNumericDate(time.Now()),
-		Subject:   userID,
+// refreshAudience is the audience of refresh tokens
+const refreshAudience = "refresh"
+
+// GenerateRefreshToken generates the refresh token tokenID of a session
+func (j *JWTManager) GenerateRefreshToken(userID, sessionID, tokenID string) (string, error) {
+	claims := &RefreshClaims{
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.refreshTokenExp)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{refreshAudience},
+			ID:        tokenID,
+		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(j.SecretKey)
 }
 
+// ParseRefreshToken verifies a refresh token and returns its claims
+func (j *JWTManager) ParseRefreshToken(tokenString string) (*RefreshClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &RefreshClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, errors.New("unexpected signing method")
+		}
+		return j.SecretKey, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*RefreshClaims)
+	if !ok || !token.Valid || !claims.VerifyAudience(refreshAudience, true) ||
+		claims.Subject == "" || claims.SessionID == "" || claims.ID == "" {
+		return nil, errors.New("invalid refresh token")
+	}
+	return claims, nil
+}
+
+// RefreshTokenExp returns the lifetime of refresh tokens
+func (j *JWTManager) RefreshTokenExp() time.Duration {
+	return j.refreshTokenExp
+}
+
 // VerifyToken verifies a JWT token and returns the claims
 func (j *JWTManager) VerifyToken(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
@@ -78,7 +129,8 @@ func (j *JWTManager) VerifyToken(tokenString string) (*Claims, error) {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+	// Refresh tokens carry no user ID and are not accepted as access tokens
+	if claims, ok := token.Claims.(*Claims); ok && token.Valid && claims.UserID != "" {
 		return claims, nil
 	}
 
@@ -94,31 +146,47 @@ func (j *JWTManager) GetUserIDFromToken(tokenString string) (string, error) {
 	return claims.UserID, nil
 }
 
-// RefreshToken verifies a refresh token and generates new access and refresh tokens
-func (j *JWTManager) RefreshToken(refreshTokenString string) (string, string, error) {
-	token, err := jwt.ParseWithClaims(refreshTokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return j.SecretKey, nil
-	})
+// mfaAudience is the audience of MFA challenge tokens
+const mfaAudience = "mfa"
 
-	if err != nil {
-		return "", "", err
+// GenerateMFAToken generates a short-lived token stating that a user passed the
+// password check and has a second factor to present. It is signed with a key of
+// its own so it is never accepted as an access or refresh token.
+func (j *JWTManager) GenerateMFAToken(userID string, exp time.Duration) (string, error) {
+	claims := &jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(exp)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		NotBefore: jwt.NewNumericDate(time.Now()),
+		Subject:   userID,
+		Audience:  jwt.ClaimStrings{mfaAudience},
 	}
 
-	if claims, ok := token.Claims.(*jwt.RegisteredClaims); ok && token.Valid {
-		// Generate new tokens
-		// Note: In a real implementation, you would get the user details from the database
-		accessToken, err := j.GenerateAccessToken(claims.Subject, "", "")
-		if err != nil {
-			return "", "", err
-		}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(j.mfaKey())
+}
 
-		newRefreshToken, err := j.GenerateRefreshToken(claims.Subject)
-		if err != nil {
-			return "", "", err
+// VerifyMFAToken verifies an MFA challenge token and returns the user ID it was issued to
+func (j *JWTManager) VerifyMFAToken(tokenString string) (string, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, errors.New("unexpected signing method")
 		}
+		return j.mfaKey(), nil
+	})
+	if err != nil {
+		return "", err
+	}
 
-		return accessToken, newRefreshToken, nil
+	claims, ok := token.Claims.(*jwt.RegisteredClaims)
+	if !ok || !token.Valid || !claims.VerifyAudience(mfaAudience, true) || claims.Subject == "" {
+		return "", errors.New("invalid mfa token")
 	}
+	return claims.Subject, nil
+}
 
-	return "", "", errors.New("invalid refresh token")
-}
\ No newline at end of file
+// mfaKey derives the key of MFA challenge tokens from the secret key
+func (j *JWTManager) mfaKey() []byte {
+	mac := hmac.New(sha256.New, j.SecretKey)
+	mac.Write([]byte("mfa-challenge"))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,154 @@
+package ldap
+
+import (
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Filter choice tags
+const (
+	filterAnd      = 0
+	filterOr       = 1
+	filterNot      = 2
+	filterEquality = 3
+	filterPresent  = 7
+)
+
+// EscapeFilter escapes a value for use in a filter
+func EscapeFilter(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\\', '*', '(', ')', 0:
+			fmt.Fprintf(&b, "\\%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// compileFilter encodes a string filter
+func compileFilter(filter string) ([]byte, error) {
+	p := &filterParser{s: filter}
+	encoded, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.s) {
+		return nil, fmt.Errorf("invalid ldap filter %q", filter)
+	}
+	return encoded, nil
+}
+
+// filterParser parses RFC 4515 filters
+type filterParser struct {
+	s   string
+	pos int
+}
+
+// parse parses one parenthesized filter
+func (p *filterParser) parse() ([]byte, error) {
+	if p.pos >= len(p.s) || p.s[p.pos] != '(' {
+		return nil, fmt.Errorf("invalid ldap filter %q", p.s)
+	}
+	p.pos++
+	if p.pos >= len(p.s) {
+		return nil, fmt.Errorf("invalid ldap filter %q", p.s)
+	}
+
+	var encoded []byte
+	var err error
+	switch p.s[p.pos] {
+	case '&', '|':
+		tag := filterAnd
+		if p.s[p.pos] == '|' {
+			tag = filterOr
+		}
+		p.pos++
+		var children []byte
+		for p.pos < len(p.s) && p.s[p.pos] == '(' {
+			child, err := p.parse()
+			if err != nil {
+				return nil, err
+			}
+			children = append(children, child...)
+		}
+		encoded, err = asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: tag, IsCompound: true, Bytes: children})
+	case '!':
+		p.pos++
+		child, childErr := p.parse()
+		if childErr != nil {
+			return nil, childErr
+		}
+		encoded, err = asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: filterNot, IsCompound: true, Bytes: child})
+	default:
+		end := strings.IndexByte(p.s[p.pos:], ')')
+		if end < 0 {
+			return nil, fmt.Errorf("invalid ldap filter %q", p.s)
+		}
+		encoded, err = encodeItem(p.s[p.pos : p.pos+end])
+		p.pos += end
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos >= len(p.s) || p.s[p.pos] != ')' {
+		return nil, fmt.Errorf("invalid ldap filter %q", p.s)
+	}
+	p.pos++
+	return encoded, nil
+}
+
+// encodeItem encodes an attr=value item
+func encodeItem(item string) ([]byte, error) {
+	attr, value, ok := strings.Cut(item, "=")
+	if !ok || attr == "" || strings.ContainsAny(attr, "~<>:") {
+		return nil, fmt.Errorf("unsupported ldap filter item %q", item)
+	}
+	if value == "*" {
+		return asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: filterPresent, Bytes: []byte(attr)})
+	}
+	if strings.Contains(value, "*") {
+		return nil, fmt.Errorf("unsupported ldap filter item %q", item)
+	}
+
+	unescaped, err := unescapeFilter(value)
+	if err != nil {
+		return nil, err
+	}
+	attrBytes, err := asn1.Marshal([]byte(attr))
+	if err != nil {
+		return nil, err
+	}
+	valueBytes, err := asn1.Marshal(unescaped)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: filterEquality, IsCompound: true, Bytes: append(attrBytes, valueBytes...)})
+}
+
+// unescapeFilter decodes the \XX escapes of a filter value
+func unescapeFilter(value string) ([]byte, error) {
+	out := make([]byte, 0, len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			out = append(out, value[i])
+			continue
+		}
+		if i+3 > len(value) {
+			return nil, errors.New("invalid escape in ldap filter")
+		}
+		decoded, err := hex.DecodeString(value[i+1 : i+3])
+		if err != nil {
+			return nil, errors.New("invalid escape in ldap filter")
+		}
+		out = append(out, decoded...)
+		i += 2
+	}
+	return out, nil
+}
@@ -0,0 +1,359 @@
+// Package ldap is a minimal LDAPv3 client supporting simple binds and searches.
+package ldap
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Search scopes
+const (
+	ScopeBaseObject   = 0
+	ScopeSingleLevel  = 1
+	ScopeWholeSubtree = 2
+)
+
+// Result codes
+const (
+	ResultSuccess            = 0
+	ResultInvalidCredentials = 49
+)
+
+// Protocol operation tags
+const (
+	opBindRequest      = 0
+	opBindResponse     = 1
+	opUnbindRequest    = 2
+	opSearchRequest    = 3
+	opSearchEntry      = 4
+	opSearchDone       = 5
+	opSearchReference  = 19
+	maxPacketSize      = 16 << 20
+	defaultDialTimeout = 10 * time.Second
+)
+
+// Error is an LDAP result other than success
+type Error struct {
+	ResultCode int
+	Message    string
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	return fmt.Sprintf("ldap result code %d: %s", e.ResultCode, e.Message)
+}
+
+// IsInvalidCredentials reports whether err is an invalid credentials result
+func IsInvalidCredentials(err error) bool {
+	var ldapErr *Error
+	return errors.As(err, &ldapErr) && ldapErr.ResultCode == ResultInvalidCredentials
+}
+
+// Entry is a directory entry returned by a search
+type Entry struct {
+	DN         string
+	Attributes map[string][]string // keyed by the attribute name as returned
+}
+
+// GetAll returns the values of an attribute, matching its name case-insensitively
+func (e *Entry) GetAll(name string) []string {
+	for attr, values := range e.Attributes {
+		if strings.EqualFold(attr, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+// Get returns the first value of an attribute, or an empty string
+func (e *Entry) Get(name string) string {
+	if values := e.GetAll(name); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// SearchRequest describes a search
+type SearchRequest struct {
+	BaseDN     string
+	Scope      int
+	Filter     string // RFC 4515 filter; equality, presence, and, or and not are supported
+	Attributes []string
+	SizeLimit  int
+}
+
+// Conn is a connection to an LDAP server
+type Conn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+	nextID  int
+}
+
+// Dial connects to an ldap:// or ldaps:// URL. Operations time out after timeout.
+func Dial(rawURL string, timeout time.Duration) (*Conn, error) {
+	if timeout <= 0 {
+		timeout = defaultDialTimeout
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	switch u.Scheme {
+	case "ldap":
+		conn, err = dialer.Dial("tcp", hostPort(u, "389"))
+	case "ldaps":
+		conn, err = tls.DialWithDialer(dialer, "tcp", hostPort(u, "636"), &tls.Config{ServerName: u.Hostname()})
+	default:
+		return nil, fmt.Errorf("unsupported ldap url scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Conn{conn: conn, reader: bufio.NewReader(conn), timeout: timeout}, nil
+}
+
+// hostPort returns the address of a URL, with a default port
+func hostPort(u *url.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
+
+// bindRequest is a BindRequest with simple authentication
+type bindRequest struct {
+	Version  int
+	Name     []byte
+	Password []byte `asn1:"tag:0"`
+}
+
+// searchRequest is a SearchRequest
+type searchRequest struct {
+	BaseObject   []byte
+	Scope        asn1.Enumerated
+	DerefAliases asn1.Enumerated
+	SizeLimit    int
+	TimeLimit    int
+	TypesOnly    bool
+	Filter       asn1.RawValue
+	Attributes   [][]byte
+}
+
+// ldapResult is the LDAPResult of a response
+type ldapResult struct {
+	ResultCode asn1.Enumerated
+	MatchedDN  []byte
+	Message    []byte
+	Referral   asn1.RawValue `asn1:"optional,tag:3"`
+}
+
+// searchEntry is a SearchResultEntry
+type searchEntry struct {
+	ObjectName []byte
+	Attributes []attribute
+}
+
+// attribute is a PartialAttribute of a search entry
+type attribute struct {
+	Type   []byte
+	Values [][]byte `asn1:"set"`
+}
+
+// message is an LDAPMessage
+type message struct {
+	MessageID int
+	Op        asn1.RawValue
+	Controls  asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+// Bind authenticates the connection with a simple bind. An empty dn and
+// password bind anonymously.
+func (c *Conn) Bind(dn, password string) error {
+	op, err := asn1.MarshalWithParams(bindRequest{Version: 3, Name: []byte(dn), Password: []byte(password)},
+		fmt.Sprintf("application,tag:%d", opBindRequest))
+	if err != nil {
+		return err
+	}
+	id, err := c.send(op)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.receive(id)
+	if err != nil {
+		return err
+	}
+	if resp.Op.Class != asn1.ClassApplication || resp.Op.Tag != opBindResponse {
+		return fmt.Errorf("unexpected ldap response %d to bind", resp.Op.Tag)
+	}
+	return parseResult(resp.Op)
+}
+
+// Search runs a search and returns the entries found
+func (c *Conn) Search(req *SearchRequest) ([]*Entry, error) {
+	filter, err := compileFilter(req.Filter)
+	if err != nil {
+		return nil, err
+	}
+	attributes := make([][]byte, 0, len(req.Attributes))
+	for _, attr := range req.Attributes {
+		attributes = append(attributes, []byte(attr))
+	}
+
+	op, err := asn1.MarshalWithParams(searchRequest{
+		BaseObject: []byte(req.BaseDN),
+		Scope:      asn1.Enumerated(req.Scope),
+		SizeLimit:  req.SizeLimit,
+		TimeLimit:  int(c.timeout / time.Second),
+		Filter:     asn1.RawValue{FullBytes: filter},
+		Attributes: attributes,
+	}, fmt.Sprintf("application,tag:%d", opSearchRequest))
+	if err != nil {
+		return nil, err
+	}
+	id, err := c.send(op)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	for {
+		resp, err := c.receive(id)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Op.Class != asn1.ClassApplication {
+			return nil, errors.New("unexpected ldap response to search")
+		}
+		switch resp.Op.Tag {
+		case opSearchEntry:
+			var raw searchEntry
+			if _, err := asn1.UnmarshalWithParams(resp.Op.FullBytes, &raw, fmt.Sprintf("application,tag:%d", opSearchEntry)); err != nil {
+				return nil, err
+			}
+			entry := &Entry{DN: string(raw.ObjectName), Attributes: make(map[string][]string)}
+			for _, attr := range raw.Attributes {
+				values := make([]string, 0, len(attr.Values))
+				for _, value := range attr.Values {
+					values = append(values, string(value))
+				}
+				entry.Attributes[string(attr.Type)] = values
+			}
+			entries = append(entries, entry)
+		case opSearchReference:
+			// Referrals to other servers are not followed
+		case opSearchDone:
+			if err := parseResult(resp.Op); err != nil {
+				return nil, err
+			}
+			return entries, nil
+		default:
+			return nil, fmt.Errorf("unexpected ldap response %d to search", resp.Op.Tag)
+		}
+	}
+}
+
+// Close unbinds and closes the connection
+func (c *Conn) Close() error {
+	if op, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassApplication, Tag: opUnbindRequest}); err == nil {
+		c.send(op)
+	}
+	return c.conn.Close()
+}
+
+// send writes a request and returns its message ID
+func (c *Conn) send(op []byte) (int, error) {
+	c.nextID++
+	packet, err := asn1.Marshal(struct {
+		MessageID int
+		Op        asn1.RawValue
+	}{c.nextID, asn1.RawValue{FullBytes: op}})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	if _, err := c.conn.Write(packet); err != nil {
+		return 0, err
+	}
+	return c.nextID, nil
+}
+
+// receive reads the next response to a request
+func (c *Conn) receive(id int) (*message, error) {
+	packet, err := readPacket(c.reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var msg message
+	if _, err := asn1.Unmarshal(packet, &msg); err != nil {
+		return nil, err
+	}
+	if msg.MessageID != id {
+		return nil, fmt.Errorf("unexpected ldap message id %d", msg.MessageID)
+	}
+	return &msg, nil
+}
+
+// parseResult returns the LDAPResult of a response as an error
+func parseResult(op asn1.RawValue) error {
+	var result ldapResult
+	if _, err := asn1.UnmarshalWithParams(op.FullBytes, &result, fmt.Sprintf("application,tag:%d", op.Tag)); err != nil {
+		return err
+	}
+	if result.ResultCode != ResultSuccess {
+		return &Error{ResultCode: int(result.ResultCode), Message: string(result.Message)}
+	}
+	return nil
+}
+
+// readPacket reads one BER element
+func readPacket(r *bufio.Reader) ([]byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	length := int(header[1])
+	if header[1]&0x80 != 0 {
+		n := int(header[1] & 0x7f)
+		if n == 0 || n > 4 {
+			return nil, errors.New("invalid ldap packet length")
+		}
+		lengthBytes := make([]byte, n)
+		if _, err := io.ReadFull(r, lengthBytes); err != nil {
+			return nil, err
+		}
+		header = append(header, lengthBytes...)
+		length = 0
+		for _, b := range lengthBytes {
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxPacketSize {
+		return nil, errors.New("ldap packet too large")
+	}
+
+	packet := make([]byte, len(header)+length)
+	copy(packet, header)
+	if _, err := io.ReadFull(r, packet[len(header):]); err != nil {
+		return nil, err
+	}
+	return packet, nil
+}
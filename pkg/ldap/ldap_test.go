@@ -0,0 +1,76 @@
+package ldap
+
+import (
+	"testing"
+	"time"
+
+	"cdk-office/pkg/ldap/ldaptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBindAndSearch(t *testing.T) {
+	server := ldaptest.NewServer(
+		&ldaptest.Entry{DN: "cn=service,dc=example,dc=com", Password: "service-secret"},
+		&ldaptest.Entry{
+			DN:       "uid=alice,ou=people,dc=example,dc=com",
+			Password: "alice-secret",
+			Attributes: map[string][]string{
+				"uid":      {"alice"},
+				"mail":     {"alice@example.com"},
+				"memberOf": {"cn=hr,ou=groups,dc=example,dc=com", "cn=staff,ou=groups,dc=example,dc=com"},
+			},
+		},
+		&ldaptest.Entry{
+			DN:         "uid=bob,ou=people,dc=example,dc=com",
+			Attributes: map[string][]string{"uid": {"bob"}, "objectClass": {"person"}},
+		},
+	)
+	defer server.Close()
+
+	conn, err := Dial(server.URL, time.Second)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	err = conn.Bind("cn=service,dc=example,dc=com", "wrong")
+	assert.True(t, IsInvalidCredentials(err))
+	require.NoError(t, conn.Bind("cn=service,dc=example,dc=com", "service-secret"))
+
+	entries, err := conn.Search(&SearchRequest{
+		BaseDN:     "ou=people,dc=example,dc=com",
+		Scope:      ScopeWholeSubtree,
+		Filter:     "(&(uid=" + EscapeFilter("alice") + ")(!(objectClass=person)))",
+		Attributes: []string{"mail", "memberOf"},
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "uid=alice,ou=people,dc=example,dc=com", entries[0].DN)
+	assert.Equal(t, "alice@example.com", entries[0].Get("MAIL"))
+	assert.Len(t, entries[0].GetAll("memberof"), 2)
+	assert.Empty(t, entries[0].Get("uid"), "only requested attributes are returned")
+
+	entries, err = conn.Search(&SearchRequest{BaseDN: "dc=example,dc=com", Scope: ScopeWholeSubtree, Filter: "(|(uid=bob)(uid=carol))"})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "bob", entries[0].Get("uid"))
+
+	entries, err = conn.Search(&SearchRequest{BaseDN: "dc=example,dc=com", Scope: ScopeWholeSubtree, Filter: "(memberOf=*)"})
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	require.NoError(t, conn.Bind("uid=alice,ou=people,dc=example,dc=com", "alice-secret"))
+	assert.Equal(t, []string{"cn=service,dc=example,dc=com", "cn=service,dc=example,dc=com", "uid=alice,ou=people,dc=example,dc=com"}, server.Binds())
+}
+
+func TestFilters(t *testing.T) {
+	assert.Equal(t, `a\2a\28b\29\5c`, EscapeFilter(`a*(b)\`))
+
+	for _, filter := range []string{"(uid=alice)", "(&(uid=a)(mail=*))", "(!(uid=a\\2a))", "(|(uid=a)(&(cn=b)(sn=c)))"} {
+		_, err := compileFilter(filter)
+		assert.NoError(t, err, filter)
+	}
+	for _, filter := range []string{"uid=alice", "(uid=alice", "(uid=al*)", "(uid>=a)", "(uid=a\\2)", "(uid=a))"} {
+		_, err := compileFilter(filter)
+		assert.Error(t, err, filter)
+	}
+}
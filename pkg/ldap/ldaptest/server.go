@@ -0,0 +1,359 @@
+// Package ldaptest provides an in-memory LDAP directory for tests.
+package ldaptest
+
+import (
+	"bufio"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Entry is a directory entry. Binding as its DN requires Password.
+type Entry struct {
+	DN         string
+	Password   string
+	Attributes map[string][]string
+}
+
+// Server is an LDAP server answering simple binds and searches from a fixed
+// set of entries
+type Server struct {
+	URL string // ldap://127.0.0.1:port
+
+	listener net.Listener
+	entries  []*Entry
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	conns    map[net.Conn]struct{}
+	binds    []string
+	searches []string
+}
+
+// NewServer starts a server with the given entries
+func NewServer(entries ...*Entry) *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("ldaptest: failed to listen: %v", err))
+	}
+	s := &Server{
+		URL:      "ldap://" + listener.Addr().String(),
+		listener: listener,
+		entries:  entries,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Close stops the server and closes its connections
+func (s *Server) Close() {
+	s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Binds returns the DNs bound as so far
+func (s *Server) Binds() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.binds...)
+}
+
+// Searches returns the base DNs searched so far
+func (s *Server) Searches() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.searches...)
+}
+
+// serve accepts connections until the listener is closed
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+			conn.Close()
+			s.mu.Lock()
+			delete(s.conns, conn)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// message is an LDAPMessage
+type message struct {
+	MessageID int
+	Op        asn1.RawValue
+	Controls  asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+type bindRequest struct {
+	Version  int
+	Name     []byte
+	Password []byte `asn1:"tag:0"`
+}
+
+type searchRequest struct {
+	BaseObject   []byte
+	Scope        asn1.Enumerated
+	DerefAliases asn1.Enumerated
+	SizeLimit    int
+	TimeLimit    int
+	TypesOnly    bool
+	Filter       asn1.RawValue
+	Attributes   [][]byte
+}
+
+type ldapResult struct {
+	ResultCode asn1.Enumerated
+	MatchedDN  []byte
+	Message    []byte
+}
+
+type searchEntry struct {
+	ObjectName []byte
+	Attributes []attribute
+}
+
+type attribute struct {
+	Type   []byte
+	Values [][]byte `asn1:"set"`
+}
+
+// handle answers the requests of a connection
+func (s *Server) handle(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	for {
+		packet, err := readPacket(reader)
+		if err != nil {
+			return
+		}
+		var msg message
+		if _, err := asn1.Unmarshal(packet, &msg); err != nil || msg.Op.Class != asn1.ClassApplication {
+			return
+		}
+
+		switch msg.Op.Tag {
+		case 0:
+			var req bindRequest
+			if _, err := asn1.UnmarshalWithParams(msg.Op.FullBytes, &req, "application,tag:0"); err != nil {
+				return
+			}
+			code := 49
+			if s.bind(string(req.Name), string(req.Password)) {
+				code = 0
+			}
+			if !s.respond(conn, msg.MessageID, 1, ldapResult{ResultCode: asn1.Enumerated(code)}) {
+				return
+			}
+		case 2:
+			return
+		case 3:
+			var req searchRequest
+			if _, err := asn1.UnmarshalWithParams(msg.Op.FullBytes, &req, "application,tag:3"); err != nil {
+				return
+			}
+			if !s.search(conn, msg.MessageID, &req) {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// bind checks a simple bind
+func (s *Server) bind(dn, password string) bool {
+	s.mu.Lock()
+	s.binds = append(s.binds, dn)
+	s.mu.Unlock()
+
+	if dn == "" && password == "" {
+		return true
+	}
+	for _, entry := range s.entries {
+		if strings.EqualFold(entry.DN, dn) {
+			return entry.Password != "" && entry.Password == password
+		}
+	}
+	return false
+}
+
+// search sends the entries matching a search
+func (s *Server) search(conn net.Conn, id int, req *searchRequest) bool {
+	base := strings.ToLower(string(req.BaseObject))
+	s.mu.Lock()
+	s.searches = append(s.searches, string(req.BaseObject))
+	s.mu.Unlock()
+
+	for _, entry := range s.entries {
+		if !strings.HasSuffix(strings.ToLower(entry.DN), base) {
+			continue
+		}
+		matched, err := match(req.Filter, entry)
+		if err != nil {
+			return s.respond(conn, id, 5, ldapResult{ResultCode: 2, Message: []byte(err.Error())})
+		}
+		if !matched {
+			continue
+		}
+
+		result := searchEntry{ObjectName: []byte(entry.DN)}
+		for name, values := range entry.Attributes {
+			if !requested(req.Attributes, name) {
+				continue
+			}
+			attr := attribute{Type: []byte(name)}
+			for _, value := range values {
+				attr.Values = append(attr.Values, []byte(value))
+			}
+			result.Attributes = append(result.Attributes, attr)
+		}
+		if !s.respond(conn, id, 4, result) {
+			return false
+		}
+	}
+	return s.respond(conn, id, 5, ldapResult{})
+}
+
+// respond sends a response
+func (s *Server) respond(conn net.Conn, id, tag int, op interface{}) bool {
+	opBytes, err := asn1.MarshalWithParams(op, fmt.Sprintf("application,tag:%d", tag))
+	if err != nil {
+		return false
+	}
+	packet, err := asn1.Marshal(struct {
+		MessageID int
+		Op        asn1.RawValue
+	}{id, asn1.RawValue{FullBytes: opBytes}})
+	if err != nil {
+		return false
+	}
+	_, err = conn.Write(packet)
+	return err == nil
+}
+
+// requested reports whether an attribute was asked for; none asks for all
+func requested(attributes [][]byte, name string) bool {
+	if len(attributes) == 0 {
+		return true
+	}
+	for _, attr := range attributes {
+		if strings.EqualFold(string(attr), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// match evaluates an encoded filter against an entry
+func match(filter asn1.RawValue, entry *Entry) (bool, error) {
+	if filter.Class != asn1.ClassContextSpecific {
+		return false, errors.New("invalid filter")
+	}
+
+	switch filter.Tag {
+	case 0, 1:
+		rest := filter.Bytes
+		for len(rest) > 0 {
+			var child asn1.RawValue
+			var err error
+			if rest, err = asn1.Unmarshal(rest, &child); err != nil {
+				return false, err
+			}
+			matched, err := match(child, entry)
+			if err != nil {
+				return false, err
+			}
+			if filter.Tag == 0 && !matched {
+				return false, nil
+			}
+			if filter.Tag == 1 && matched {
+				return true, nil
+			}
+		}
+		return filter.Tag == 0, nil
+	case 2:
+		var child asn1.RawValue
+		if _, err := asn1.Unmarshal(filter.Bytes, &child); err != nil {
+			return false, err
+		}
+		matched, err := match(child, entry)
+		return !matched, err
+	case 3:
+		var attr, value []byte
+		rest, err := asn1.Unmarshal(filter.Bytes, &attr)
+		if err != nil {
+			return false, err
+		}
+		if _, err := asn1.Unmarshal(rest, &value); err != nil {
+			return false, err
+		}
+		for _, v := range values(entry, string(attr)) {
+			if strings.EqualFold(v, string(value)) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case 7:
+		return len(values(entry, string(filter.Bytes))) > 0, nil
+	default:
+		return false, fmt.Errorf("unsupported filter %d", filter.Tag)
+	}
+}
+
+// values returns the values of an attribute of an entry, by case-insensitive name
+func values(entry *Entry, name string) []string {
+	for attr, vals := range entry.Attributes {
+		if strings.EqualFold(attr, name) {
+			return vals
+		}
+	}
+	return nil
+}
+
+// readPacket reads one BER element
+func readPacket(r *bufio.Reader) ([]byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := int(header[1])
+	if header[1]&0x80 != 0 {
+		lengthBytes := make([]byte, header[1]&0x7f)
+		if _, err := io.ReadFull(r, lengthBytes); err != nil {
+			return nil, err
+		}
+		header = append(header, lengthBytes...)
+		length = 0
+		for _, b := range lengthBytes {
+			length = length<<8 | int(b)
+		}
+	}
+	packet := make([]byte, len(header)+length)
+	copy(packet, header)
+	if _, err := io.ReadFull(r, packet[len(header):]); err != nil {
+		return nil, err
+	}
+	return packet, nil
+}
@@ -0,0 +1,111 @@
+// Package totp implements the time-based one-time passwords of RFC 6238 used
+// by authenticator apps, with the otpauth URI format they enrol from.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Period is the lifetime of a code
+	Period = 30 * time.Second
+	// Digits is the length of a code
+	Digits = 6
+	// Skew is the number of periods before and after the current one whose codes
+	// are still accepted, allowing for clock drift
+	Skew = 1
+
+	secretSize = 20 // the HMAC-SHA1 block recommended by RFC 4226
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a new random secret in base32, as authenticator apps expect it
+func GenerateSecret() (string, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(secret), nil
+}
+
+// Step returns the time step t falls in
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// Code returns the code of secret for the time step
+func Code(secret string, step int64) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation, RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", Digits, value%mod), nil
+}
+
+// Validate checks code against the steps around t and returns the step it
+// matched, so callers can refuse a code that was already used
+func Validate(secret, code string, t time.Time) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != Digits {
+		return 0, false
+	}
+	current := Step(t)
+	for step := current - Skew; step <= current+Skew; step++ {
+		expected, err := Code(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// URI returns the otpauth URI authenticator apps enrol secret from
+func URI(issuer, account, secret string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(Digits))
+	params.Set("period", fmt.Sprint(int(Period/time.Second)))
+
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// decodeSecret decodes a base32 secret, ignoring case, spaces and padding
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	key, err := encoding.DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(key) == 0 {
+		return nil, errors.New("invalid totp secret")
+	}
+	return key, nil
+}
@@ -0,0 +1,75 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA1 seed of the RFC 6238 test vectors
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	// The RFC lists 8 digit codes; the 6 digit codes are their last digits
+	cases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tc := range cases {
+		code, err := Code(rfcSecret, Step(time.Unix(tc.unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, tc.code, code, "time %d", tc.unix)
+	}
+
+	_, err := Code("not base32!", 1)
+	assert.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	now := time.Unix(1700000000, 0)
+	code, err := Code(secret, Step(now))
+	require.NoError(t, err)
+
+	step, ok := Validate(secret, code, now)
+	assert.True(t, ok)
+	assert.Equal(t, Step(now), step)
+
+	// Drift of one period either way is tolerated
+	step, ok = Validate(secret, code[:3]+" "+code[3:], now.Add(Period))
+	assert.True(t, ok)
+	assert.Equal(t, Step(now), step)
+
+	_, ok = Validate(secret, code, now.Add(3*Period))
+	assert.False(t, ok)
+	_, ok = Validate(secret, "12345", now)
+	assert.False(t, ok)
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("CDK Office", "alice@example.com", "JBSWY3DPEHPK3PXP")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/CDK%20Office:alice@example.com?"))
+
+	parsed, err := url.Parse(uri)
+	require.NoError(t, err)
+	query := parsed.Query()
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", query.Get("secret"))
+	assert.Equal(t, "CDK Office", query.Get("issuer"))
+	assert.Equal(t, "6", query.Get("digits"))
+	assert.Equal(t, "30", query.Get("period"))
+}
@@ -0,0 +1,185 @@
+// Package wechat is a client for the WeChat mini-program login API.
+package wechat
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultBaseURL is the base URL of the WeChat API
+const DefaultBaseURL = "https://api.weixin.qq.com"
+
+// ErrInvalidCode is returned for login codes WeChat rejects as invalid or used
+var ErrInvalidCode = errors.New("invalid wechat login code")
+
+// APIError is an error reported by the WeChat API
+type APIError struct {
+	Code    int
+	Message string
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	return fmt.Sprintf("wechat api error %d: %s", e.Code, e.Message)
+}
+
+// Session is the result of exchanging a mini-program login code
+type Session struct {
+	OpenID     string `json:"openid"`
+	UnionID    string `json:"unionid"` // empty unless the app is bound to an open platform account
+	SessionKey string `json:"session_key"`
+}
+
+// PhoneNumber is a phone number decrypted from getPhoneNumber data
+type PhoneNumber struct {
+	PhoneNumber     string `json:"phoneNumber"` // with the country code for foreign numbers
+	PurePhoneNumber string `json:"purePhoneNumber"`
+	CountryCode     string `json:"countryCode"`
+	Watermark       struct {
+		AppID     string `json:"appid"`
+		Timestamp int64  `json:"timestamp"`
+	} `json:"watermark"`
+}
+
+// ClientInterface defines the interface for the WeChat API client
+type ClientInterface interface {
+	Code2Session(ctx context.Context, code string) (*Session, error)
+	DecryptPhoneNumber(sessionKey, encryptedData, iv string) (*PhoneNumber, error)
+}
+
+// Client implements the ClientInterface
+type Client struct {
+	baseURL    string
+	appID      string
+	appSecret  string
+	httpClient *http.Client
+}
+
+// NewClient creates a new instance of Client. baseURL defaults to DefaultBaseURL.
+func NewClient(baseURL, appID, appSecret string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		appID:     appID,
+		appSecret: appSecret,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// code2SessionResponse is the body of a jscode2session response
+type code2SessionResponse struct {
+	Session
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+// Code2Session exchanges a login code from wx.login for the user's openid and
+// session key
+func (c *Client) Code2Session(ctx context.Context, code string) (*Session, error) {
+	if c.appID == "" || c.appSecret == "" {
+		return nil, errors.New("wechat app id and secret are not configured")
+	}
+
+	query := url.Values{}
+	query.Set("appid", c.appID)
+	query.Set("secret", c.appSecret)
+	query.Set("js_code", code)
+	query.Set("grant_type", "authorization_code")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/sns/jscode2session?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("wechat api responded with status %d", resp.StatusCode)
+	}
+
+	var result code2SessionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	switch result.ErrCode {
+	case 0:
+	case 40029, 40163: // invalid code, code already used
+		return nil, ErrInvalidCode
+	default:
+		return nil, &APIError{Code: result.ErrCode, Message: result.ErrMsg}
+	}
+	if result.OpenID == "" || result.SessionKey == "" {
+		return nil, errors.New("wechat api returned no openid")
+	}
+
+	return &result.Session, nil
+}
+
+// DecryptPhoneNumber decrypts the data of a getPhoneNumber button event with the
+// session key of the user, checking that it was issued to this app
+func (c *Client) DecryptPhoneNumber(sessionKey, encryptedData, iv string) (*PhoneNumber, error) {
+	plaintext, err := decrypt(sessionKey, encryptedData, iv)
+	if err != nil {
+		return nil, err
+	}
+
+	var phone PhoneNumber
+	if err := json.Unmarshal(plaintext, &phone); err != nil {
+		return nil, err
+	}
+	if phone.Watermark.AppID != c.appID {
+		return nil, errors.New("phone number data was issued to another app")
+	}
+	if phone.PurePhoneNumber == "" {
+		return nil, errors.New("phone number data has no phone number")
+	}
+
+	return &phone, nil
+}
+
+// decrypt decrypts AES-128-CBC data with PKCS#7 padding, all base64 encoded
+func decrypt(key, data, iv string) ([]byte, error) {
+	keyBytes, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, errors.New("invalid session key")
+	}
+	ivBytes, err := base64.StdEncoding.DecodeString(iv)
+	if err != nil || len(ivBytes) != aes.BlockSize {
+		return nil, errors.New("invalid iv")
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(data)
+	if err != nil || len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, errors.New("invalid encrypted data")
+	}
+
+	block, err := aes.NewCipher(keyBytes)
+	if err != nil {
+		return nil, errors.New("invalid session key")
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, ivBytes).CryptBlocks(plaintext, ciphertext)
+
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > aes.BlockSize || !bytes.Equal(plaintext[len(plaintext)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, errors.New("invalid encrypted data")
+	}
+	return plaintext[:len(plaintext)-padding], nil
+}
@@ -0,0 +1,90 @@
+package wechat
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCode2Session(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/sns/jscode2session", r.URL.Path)
+		assert.Equal(t, "wx-app", r.URL.Query().Get("appid"))
+		assert.Equal(t, "app-secret", r.URL.Query().Get("secret"))
+		assert.Equal(t, "authorization_code", r.URL.Query().Get("grant_type"))
+		switch r.URL.Query().Get("js_code") {
+		case "good":
+			w.Write([]byte(`{"openid":"o-123","unionid":"u-456","session_key":"a2V5"}`))
+		case "used":
+			w.Write([]byte(`{"errcode":40163,"errmsg":"code been used"}`))
+		default:
+			w.Write([]byte(`{"errcode":45011,"errmsg":"api minute-quota reach limit"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL+"/", "wx-app", "app-secret")
+	session, err := client.Code2Session(context.Background(), "good")
+	require.NoError(t, err)
+	assert.Equal(t, &Session{OpenID: "o-123", UnionID: "u-456", SessionKey: "a2V5"}, session)
+
+	_, err = client.Code2Session(context.Background(), "used")
+	assert.ErrorIs(t, err, ErrInvalidCode)
+	_, err = client.Code2Session(context.Background(), "other")
+	assert.EqualError(t, err, "wechat api error 45011: api minute-quota reach limit")
+
+	_, err = NewClient(server.URL, "", "").Code2Session(context.Background(), "good")
+	assert.EqualError(t, err, "wechat app id and secret are not configured")
+}
+
+func TestDecryptPhoneNumber(t *testing.T) {
+	key := bytes.Repeat([]byte{0x11}, 16)
+	iv := bytes.Repeat([]byte{0x22}, 16)
+	encrypt := func(v interface{}) string {
+		plaintext, err := json.Marshal(v)
+		require.NoError(t, err)
+		padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+		plaintext = append(plaintext, bytes.Repeat([]byte{byte(padding)}, padding)...)
+		block, err := aes.NewCipher(key)
+		require.NoError(t, err)
+		ciphertext := make([]byte, len(plaintext))
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, plaintext)
+		return base64.StdEncoding.EncodeToString(ciphertext)
+	}
+	sessionKey := base64.StdEncoding.EncodeToString(key)
+	ivString := base64.StdEncoding.EncodeToString(iv)
+
+	client := NewClient("", "wx-app", "app-secret")
+	data := encrypt(map[string]interface{}{
+		"phoneNumber":     "13800138000",
+		"purePhoneNumber": "13800138000",
+		"countryCode":     "86",
+		"watermark":       map[string]interface{}{"appid": "wx-app", "timestamp": 1700000000},
+	})
+	phone, err := client.DecryptPhoneNumber(sessionKey, data, ivString)
+	require.NoError(t, err)
+	assert.Equal(t, "13800138000", phone.PurePhoneNumber)
+	assert.Equal(t, "86", phone.CountryCode)
+
+	other := encrypt(map[string]interface{}{
+		"purePhoneNumber": "13800138000",
+		"watermark":       map[string]interface{}{"appid": "wx-other"},
+	})
+	_, err = client.DecryptPhoneNumber(sessionKey, other, ivString)
+	assert.EqualError(t, err, "phone number data was issued to another app")
+
+	wrongKey := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0x33}, 16))
+	_, err = client.DecryptPhoneNumber(wrongKey, data, ivString)
+	assert.Error(t, err)
+	_, err = client.DecryptPhoneNumber(sessionKey, "not base64", ivString)
+	assert.EqualError(t, err, "invalid encrypted data")
+}